@@ -0,0 +1,203 @@
+package restys
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// BatchErrorMode is the way DoBatch handles the failed requests, see
+// BatchOptions.ErrorMode.
+type BatchErrorMode int
+
+const (
+	// BatchCollectAll executes all the requests and collects the errors of
+	// the failed ones, which is the default.
+	BatchCollectAll BatchErrorMode = iota
+	// BatchFailFast cancels the executing requests and skips the pending
+	// ones once a request fails.
+	BatchFailFast
+)
+
+// BatchProgress is the progress of DoBatch, see BatchOptions.ProgressCallback.
+type BatchProgress struct {
+	// Total is the number of requests in the batch.
+	Total int
+	// Completed is the number of requests which have completed, including
+	// the failed ones.
+	Completed int
+	// Failed is the number of requests which have failed.
+	Failed int
+	// Elapsed is the time since the batch started.
+	Elapsed time.Duration
+}
+
+// BatchOptions controls the execution of DoBatch.
+type BatchOptions struct {
+	// Concurrency is the max number of requests executing at the same
+	// time, default is 10.
+	Concurrency int
+	// RequestsPerSecond is the max number of requests started per second,
+	// which is shared by all the requests of the batch, default is 0 (no
+	// limit).
+	RequestsPerSecond float64
+	// ErrorMode is the way of handling the failed requests, default is
+	// BatchCollectAll.
+	ErrorMode BatchErrorMode
+	// ProgressCallback is called after each request completes, the calls
+	// are serialized.
+	ProgressCallback func(p BatchProgress)
+}
+
+// BatchError is the error returned by DoBatch if any request fails.
+type BatchError struct {
+	// Errors is the errors of the requests in the same order of the
+	// requests, the error of the succeeded request is nil.
+	Errors []error
+}
+
+func (e *BatchError) Error() string {
+	var first error
+	n := 0
+	for _, err := range e.Errors {
+		if err != nil {
+			if first == nil {
+				first = err
+			}
+			n++
+		}
+	}
+	return fmt.Sprintf("%d of %d batch requests failed, first error: %v", n, len(e.Errors), first)
+}
+
+// Unwrap returns the errors of the failed requests, which makes
+// errors.Is and errors.As work with them.
+func (e *BatchError) Unwrap() []error {
+	var errs []error
+	for _, err := range e.Errors {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// errBatchSkipped is the error of the requests which are not executed
+// because of a failed request in BatchFailFast mode.
+var errBatchSkipped = errors.New("batch request skipped after a failed request")
+
+// DoBatch executes reqs with bounded concurrency, and returns the responses
+// in the same order of reqs, the response is always not nil. The context
+// of each request is derived from ctx, and the requests still go through
+// the scheduler (see Client.EnableScheduler) if it's enabled. A request
+// fails if Response.Err is not nil, the returned error is a *BatchError if
+// any request fails, opts can be nil to use the default options.
+func (c *Client) DoBatch(ctx context.Context, reqs []*Request, opts *BatchOptions) ([]*Response, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var o BatchOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.Concurrency <= 0 {
+		o.Concurrency = 10
+	}
+	if o.Concurrency > len(reqs) {
+		o.Concurrency = len(reqs)
+	}
+	var limiter *rateLimiter
+	if o.RequestsPerSecond > 0 {
+		limiter = &rateLimiter{rate: o.RequestsPerSecond, burst: 1, tokens: 1, last: time.Now()}
+	}
+
+	var (
+		start    = time.Now()
+		resps    = make([]*Response, len(reqs))
+		errs     = make([]error, len(reqs))
+		cancels  = make([]context.CancelFunc, len(reqs))
+		mu       sync.Mutex // guards the fields below and cancels
+		next     int
+		failed   bool
+		progress = BatchProgress{Total: len(reqs)}
+	)
+	done := func(i int, resp *Response) {
+		mu.Lock()
+		defer mu.Unlock()
+		resps[i], errs[i] = resp, resp.Err
+		progress.Completed++
+		if resp.Err != nil {
+			progress.Failed++
+			if o.ErrorMode == BatchFailFast && !failed {
+				failed = true
+				for j, cancel := range cancels {
+					if j != i && cancel != nil && resps[j] == nil {
+						cancel()
+					}
+				}
+			}
+		}
+		if o.ProgressCallback != nil {
+			progress.Elapsed = time.Since(start)
+			o.ProgressCallback(progress)
+		}
+	}
+	// take returns the index, the context and its cancel function of the
+	// next request, or -1 if no more request should be executed.
+	take := func() (int, context.Context, context.CancelFunc) {
+		mu.Lock()
+		defer mu.Unlock()
+		if next >= len(reqs) || failed {
+			return -1, nil, nil
+		}
+		i := next
+		next++
+		rctx, cancel := context.WithCancel(ctx)
+		cancels[i] = cancel
+		return i, rctx, cancel
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < o.Concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				i, rctx, cancel := take()
+				if i < 0 {
+					return
+				}
+				r := reqs[i]
+				if limiter != nil {
+					if err := limiter.wait(rctx, 1); err != nil {
+						cancel()
+						done(i, r.newErrorResponse(err))
+						continue
+					}
+				}
+				resp := r.Do(rctx)
+				// the context is kept until the body is closed if it's
+				// not read automatically.
+				r.cancelOnBodyClose(resp, cancel)
+				done(i, resp)
+			}
+		}()
+	}
+	wg.Wait()
+
+	var batchErr *BatchError
+	for i, resp := range resps {
+		if resp == nil { // skipped in BatchFailFast mode
+			resps[i], errs[i] = reqs[i].newErrorResponse(errBatchSkipped), errBatchSkipped
+		}
+		if errs[i] != nil && batchErr == nil {
+			batchErr = &BatchError{Errors: errs}
+		}
+	}
+	if batchErr != nil {
+		return resps, batchErr
+	}
+	return resps, nil
+}
@@ -0,0 +1,113 @@
+package restys
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/luoxk/restys/internal/tests"
+)
+
+func TestDoBatch(t *testing.T) {
+	var running, maxRunning int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			m := atomic.LoadInt32(&maxRunning)
+			if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+				break
+			}
+		}
+		if r.URL.Query().Get("fail") != "" {
+			time.Sleep(10 * time.Millisecond)
+			panic(http.ErrAbortHandler)
+		}
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte(r.URL.Query().Get("i")))
+	}))
+	defer server.Close()
+
+	c := C().SetBaseURL(server.URL)
+	newReqs := func(n, fail int) []*Request {
+		reqs := make([]*Request, n)
+		for i := range reqs {
+			reqs[i] = c.R().SetURL("/").SetQueryParam("i", strconv.Itoa(i))
+			if i == fail {
+				reqs[i].SetQueryParam("fail", "1")
+			}
+		}
+		return reqs
+	}
+
+	// collect all, in order.
+	var progress []BatchProgress
+	resps, err := c.DoBatch(context.Background(), newReqs(10, -1), &BatchOptions{
+		Concurrency: 3,
+		ProgressCallback: func(p BatchProgress) {
+			progress = append(progress, p)
+		},
+	})
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, 10, len(resps))
+	for i, resp := range resps {
+		tests.AssertEqual(t, strconv.Itoa(i), resp.String())
+	}
+	tests.AssertEqual(t, int32(3), atomic.LoadInt32(&maxRunning))
+	tests.AssertEqual(t, 10, len(progress))
+	tests.AssertEqual(t, 10, progress[9].Total)
+	tests.AssertEqual(t, 10, progress[9].Completed)
+	tests.AssertEqual(t, 0, progress[9].Failed)
+	// the context of the finished request is released.
+	tests.AssertEqual(t, context.Canceled, resps[0].Request.Context().Err())
+
+	// unless the body is left unread.
+	resps, err = c.DoBatch(context.Background(), []*Request{c.R().SetURL("/?i=7").DisableAutoReadResponse()}, nil)
+	tests.AssertNoError(t, err)
+	tests.AssertIsNil(t, resps[0].Request.Context().Err())
+	b, err := io.ReadAll(resps[0].Body)
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, "7", string(b))
+	resps[0].Body.Close()
+	tests.AssertEqual(t, context.Canceled, resps[0].Request.Context().Err())
+
+	// collect all with failure.
+	resps, err = c.DoBatch(context.Background(), newReqs(5, 2), nil)
+	var batchErr *BatchError
+	tests.AssertEqual(t, true, errors.As(err, &batchErr))
+	for i, resp := range resps {
+		if i == 2 {
+			tests.AssertNotNil(t, resp.Err)
+			tests.AssertNotNil(t, batchErr.Errors[i])
+		} else {
+			tests.AssertNoError(t, resp.Err)
+			tests.AssertIsNil(t, batchErr.Errors[i])
+		}
+	}
+
+	// fail fast skips the pending requests.
+	resps, err = c.DoBatch(context.Background(), newReqs(10, 0), &BatchOptions{
+		Concurrency: 2,
+		ErrorMode:   BatchFailFast,
+	})
+	tests.AssertNotNil(t, err)
+	tests.AssertEqual(t, 10, len(resps))
+	tests.AssertEqual(t, true, errors.Is(err, errBatchSkipped))
+	tests.AssertEqual(t, errBatchSkipped, resps[9].Err)
+
+	// shared rate limit.
+	start := time.Now()
+	_, err = c.DoBatch(context.Background(), newReqs(4, -1), &BatchOptions{
+		RequestsPerSecond: 20,
+	})
+	tests.AssertNoError(t, err)
+	if d := time.Since(start); d < 150*time.Millisecond {
+		t.Errorf("rate limited batch finished too fast: %v", d)
+	}
+}
@@ -0,0 +1,264 @@
+// Package capture converts the traffic intercepted by the proxy tools into
+// request templates runnable through restys, which accelerates turning the
+// captured traffic into code. The supported formats are HAR (exported by
+// browsers, Charles, Fiddler and mitmproxy), Charles JSON session (.chlsj),
+// Burp Suite XML export and Fiddler session archive (.saz).
+//
+//	templates, err := capture.Load("session.har")
+//	if err != nil {
+//	    return err
+//	}
+//	client := restys.C()
+//	for _, t := range templates {
+//	    resp, err := t.Request(client).Send(t.Method, t.URL)
+//	    ...
+//	}
+//
+// The header order, header case and body of the captured requests are
+// preserved.
+package capture
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/luoxk/restys"
+)
+
+// Header is a captured header field, the case of Name is preserved.
+type Header struct {
+	Name  string
+	Value string
+}
+
+// Template is a captured request.
+type Template struct {
+	Method  string
+	URL     string
+	Proto   string // e.g. "HTTP/1.1", "HTTP/2", may be empty
+	Headers []Header
+	Body    []byte
+}
+
+// skipHeaders are the headers which are managed by the transport, so they
+// are not replayed.
+var skipHeaders = map[string]bool{
+	"Content-Length":      true,
+	"Transfer-Encoding":   true,
+	"Connection":          true,
+	"Keep-Alive":          true,
+	"Proxy-Connection":    true,
+	"Proxy-Authorization": true,
+}
+
+func replayable(name string) bool {
+	return name != "" && !strings.HasPrefix(name, ":") && !skipHeaders[http.CanonicalHeaderKey(name)]
+}
+
+// Header returns the first value of the header named key (case-insensitive).
+func (t *Template) Header(key string) string {
+	for _, h := range t.Headers {
+		if strings.EqualFold(h.Name, key) {
+			return h.Value
+		}
+	}
+	return ""
+}
+
+// Request creates a request of the client from the template, with the
+// headers (in captured order and case) and body set, send it with
+// Request.Send(t.Method, t.URL).
+func (t *Template) Request(c *restys.Client) *restys.Request {
+	r := c.R()
+	var order []string
+	for _, h := range t.Headers {
+		if !replayable(h.Name) {
+			continue
+		}
+		// HTTP/2 and HTTP/3 captures have the lower case names, which are
+		// canonicalized to avoid duplicating the common headers of client.
+		if h.Name == strings.ToLower(h.Name) || h.Name == http.CanonicalHeaderKey(h.Name) {
+			r.Headers = addHeader(r.Headers, http.CanonicalHeaderKey(h.Name), h.Value)
+		} else {
+			r.SetHeaderNonCanonical(h.Name, h.Value)
+		}
+		order = append(order, h.Name)
+	}
+	if len(order) > 0 {
+		r.SetHeaderOrder(order...)
+	}
+	if len(t.Body) > 0 {
+		r.SetBodyBytes(t.Body)
+	}
+	return r
+}
+
+func addHeader(h http.Header, key, value string) http.Header {
+	if h == nil {
+		h = make(http.Header)
+	}
+	h[key] = append(h[key], value)
+	return h
+}
+
+// GoCode returns the Go code which sends the request with restys, e.g.
+//
+//	resp, err := client.R().
+//		SetHeaderOrder("accept", "user-agent").
+//		SetHeader("Accept", "*/*").
+//		SetHeader("User-Agent", "curl/8.0").
+//		Send("GET", "https://example.com/")
+func (t *Template) GoCode() string {
+	var b strings.Builder
+	b.WriteString("resp, err := client.R().\n")
+	var order []string
+	var headers []string
+	for _, h := range t.Headers {
+		if !replayable(h.Name) {
+			continue
+		}
+		order = append(order, strconv.Quote(h.Name))
+		if h.Name == http.CanonicalHeaderKey(h.Name) || h.Name == strings.ToLower(h.Name) {
+			headers = append(headers, fmt.Sprintf("\tSetHeader(%q, %s).\n", http.CanonicalHeaderKey(h.Name), quote(h.Value)))
+		} else {
+			headers = append(headers, fmt.Sprintf("\tSetHeaderNonCanonical(%q, %s).\n", h.Name, quote(h.Value)))
+		}
+	}
+	if len(order) > 0 {
+		fmt.Fprintf(&b, "\tSetHeaderOrder(%s).\n", strings.Join(order, ", "))
+	}
+	for _, h := range headers {
+		b.WriteString(h)
+	}
+	if len(t.Body) > 0 {
+		fmt.Fprintf(&b, "\tSetBodyString(%s).\n", quote(string(t.Body)))
+	}
+	fmt.Fprintf(&b, "\tSend(%q, %q)\n", t.Method, t.URL)
+	return b.String()
+}
+
+// quote returns the raw string literal if possible, which is easier to read
+// for JSON bodies.
+func quote(s string) string {
+	if strings.ContainsRune(s, '"') && strconv.CanBackquote(s) {
+		return "`" + s + "`"
+	}
+	return strconv.Quote(s)
+}
+
+// Load loads the templates from the capture file, the format is detected by
+// the file extension: ".har", ".chlsj", ".xml" (Burp) or ".saz".
+func Load(filename string) ([]*Template, error) {
+	ext := strings.ToLower(filepath.Ext(filename))
+	if ext == ".saz" {
+		f, err := os.Open(filename)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		fi, err := f.Stat()
+		if err != nil {
+			return nil, err
+		}
+		return ReadSAZ(f, fi.Size())
+	}
+	var read func(io.Reader) ([]*Template, error)
+	switch ext {
+	case ".har":
+		read = ReadHAR
+	case ".chlsj":
+		read = ReadCharles
+	case ".xml":
+		read = ReadBurp
+	case ".chls":
+		return nil, fmt.Errorf("capture: binary Charles session %s is not supported, export it as HAR or JSON session (.chlsj)", filename)
+	default:
+		return nil, fmt.Errorf("capture: unknown capture format of %s", filename)
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return read(f)
+}
+
+// ParseRawRequest parses the raw HTTP/1.x request, the header order and case
+// are preserved. The URL is built from the request target if it is absolute
+// (proxy form), otherwise from scheme, the Host header and the target.
+func ParseRawRequest(raw []byte, scheme string) (*Template, error) {
+	br := bufio.NewReader(bytes.NewReader(raw))
+	line, err := readLine(br)
+	if err != nil {
+		return nil, err
+	}
+	parts := strings.SplitN(line, " ", 3)
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("capture: malformed request line %q", line)
+	}
+	t := &Template{Method: parts[0]}
+	if len(parts) == 3 {
+		t.Proto = parts[2]
+	}
+	for {
+		line, err = readLine(br)
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if line == "" {
+			break
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("capture: malformed header line %q", line)
+		}
+		t.Headers = append(t.Headers, Header{Name: strings.TrimSpace(name), Value: strings.TrimSpace(value)})
+		if err == io.EOF {
+			break
+		}
+	}
+
+	target := parts[1]
+	if strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://") {
+		t.URL = target
+	} else {
+		if scheme == "" {
+			scheme = "https"
+		}
+		u := &url.URL{Scheme: scheme, Host: t.Header("Host")}
+		t.URL = u.String() + target
+	}
+
+	var body io.Reader = br
+	if strings.EqualFold(t.Header("Transfer-Encoding"), "chunked") {
+		body = httputil.NewChunkedReader(br)
+	} else if cl := t.Header("Content-Length"); cl != "" {
+		if n, err := strconv.ParseInt(cl, 10, 64); err == nil {
+			body = io.LimitReader(br, n)
+		}
+	}
+	if t.Body, err = io.ReadAll(body); err != nil {
+		return nil, err
+	}
+	if len(t.Body) == 0 {
+		t.Body = nil
+	}
+	return t, nil
+}
+
+func readLine(br *bufio.Reader) (string, error) {
+	line, err := br.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), err
+}
@@ -0,0 +1,152 @@
+package capture
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/luoxk/restys"
+	"github.com/luoxk/restys/internal/tests"
+)
+
+func TestParseRawRequest(t *testing.T) {
+	raw := "POST /api/login?x=1 HTTP/1.1\r\nHost: example.com:8443\r\nx-Custom: a\r\nContent-Type: application/json\r\nTransfer-Encoding: chunked\r\n\r\n" +
+		"7\r\n{\"a\":1}\r\n0\r\n\r\n"
+	tpl, err := ParseRawRequest([]byte(raw), "https")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, "POST", tpl.Method)
+	tests.AssertEqual(t, "HTTP/1.1", tpl.Proto)
+	tests.AssertEqual(t, "https://example.com:8443/api/login?x=1", tpl.URL)
+	tests.AssertEqual(t, 4, len(tpl.Headers))
+	tests.AssertEqual(t, "x-Custom", tpl.Headers[1].Name)
+	tests.AssertEqual(t, `{"a":1}`, string(tpl.Body))
+
+	tpl, err = ParseRawRequest([]byte("GET http://example.com/ HTTP/1.1\nHost: example.com\n"), "")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, "http://example.com/", tpl.URL)
+	tests.AssertIsNil(t, tpl.Body)
+
+	_, err = ParseRawRequest([]byte("GET\r\n\r\n"), "")
+	tests.AssertErrorContains(t, err, "malformed request line")
+}
+
+const testHAR = `{"log":{"version":"1.2","entries":[
+{"request":{"method":"POST","url":"https://example.com/a","httpVersion":"HTTP/2","headers":[
+{"name":":authority","value":"example.com"},{"name":"content-type","value":"application/json"},
+{"name":"x-token","value":"t"},{"name":"content-length","value":"7"}],
+"postData":{"mimeType":"application/json","text":"{\"a\":1}"}}},
+{"request":{"method":"GET","url":"https://example.com/b","httpVersion":"HTTP/1.1","headers":[]}}]}}`
+
+func TestReadHAR(t *testing.T) {
+	templates, err := ReadHAR(strings.NewReader(testHAR))
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, 2, len(templates))
+	tpl := templates[0]
+	tests.AssertEqual(t, "POST", tpl.Method)
+	tests.AssertEqual(t, "https://example.com/a", tpl.URL)
+	tests.AssertEqual(t, "t", tpl.Header("X-Token"))
+	tests.AssertEqual(t, `{"a":1}`, string(tpl.Body))
+
+	code := tpl.GoCode()
+	tests.AssertEqual(t, "resp, err := client.R().\n"+
+		"\tSetHeaderOrder(\"content-type\", \"x-token\").\n"+
+		"\tSetHeader(\"Content-Type\", \"application/json\").\n"+
+		"\tSetHeader(\"X-Token\", \"t\").\n"+
+		"\tSetBodyString(`{\"a\":1}`).\n"+
+		"\tSend(\"POST\", \"https://example.com/a\")\n", code)
+}
+
+func TestReadCharles(t *testing.T) {
+	doc := `[{"method":"CONNECT","scheme":"https","host":"example.com","actualPort":443},
+{"method":"PUT","protocolVersion":"HTTP/1.1","scheme":"http","host":"example.com","actualPort":8080,"path":"/p","query":"q=1",
+"request":{"header":{"headers":[{"name":"Host","value":"example.com:8080"},{"name":"X-A","value":"1"}]},
+"body":{"encoded":"` + base64.StdEncoding.EncodeToString([]byte{0, 1, 2}) + `"}}}]`
+	templates, err := ReadCharles(strings.NewReader(doc))
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, 1, len(templates))
+	tests.AssertEqual(t, "http://example.com:8080/p?q=1", templates[0].URL)
+	tests.AssertEqual(t, "1", templates[0].Header("x-a"))
+	tests.AssertEqual(t, []byte{0, 1, 2}, templates[0].Body)
+}
+
+func TestReadBurp(t *testing.T) {
+	raw := "POST /submit HTTP/1.1\r\nHost: example.com\r\nContent-Length: 3\r\n\r\na=1"
+	doc := `<?xml version="1.0"?><items burpVersion="2023.1">
+<item><url><![CDATA[https://example.com/submit]]></url><protocol>https</protocol><method>POST</method>
+<request base64="true"><![CDATA[` + base64.StdEncoding.EncodeToString([]byte(raw)) + `]]></request></item>
+<item><url><![CDATA[http://example.com/plain]]></url><protocol>http</protocol>
+<request base64="false"><![CDATA[GET /plain HTTP/1.1
+Host: example.com
+
+]]></request></item></items>`
+	templates, err := ReadBurp(strings.NewReader(doc))
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, 2, len(templates))
+	tests.AssertEqual(t, "https://example.com/submit", templates[0].URL)
+	tests.AssertEqual(t, "a=1", string(templates[0].Body))
+	tests.AssertEqual(t, "GET", templates[1].Method)
+	tests.AssertEqual(t, "http://example.com/plain", templates[1].URL)
+}
+
+func TestReadSAZ(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	files := map[string]string{
+		"raw/10_c.txt": "GET https://example.com/10 HTTP/1.1\r\nHost: example.com\r\n\r\n",
+		"raw/2_c.txt":  "GET https://example.com/2 HTTP/1.1\r\nHost: example.com\r\n\r\n",
+		"raw/2_s.txt":  "HTTP/1.1 200 OK\r\n\r\n",
+		"raw/1_c.txt":  "CONNECT example.com:443 HTTP/1.1\r\nHost: example.com:443\r\n\r\n",
+	}
+	for name, content := range files {
+		w, err := zw.Create(name)
+		tests.AssertNoError(t, err)
+		io.WriteString(w, content)
+	}
+	tests.AssertNoError(t, zw.Close())
+
+	filename := filepath.Join(t.TempDir(), "session.saz")
+	tests.AssertNoError(t, os.WriteFile(filename, buf.Bytes(), 0o644))
+	templates, err := Load(filename)
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, 2, len(templates))
+	tests.AssertEqual(t, "https://example.com/2", templates[0].URL)
+	tests.AssertEqual(t, "https://example.com/10", templates[1].URL)
+
+	_, err = Load("session.chls")
+	tests.AssertErrorContains(t, err, "not supported")
+}
+
+func TestTemplateRequest(t *testing.T) {
+	var gotHeader http.Header
+	var gotBody []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHeader = r.Header
+		gotBody, _ = io.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	tpl := &Template{
+		Method: "POST",
+		URL:    srv.URL + "/a",
+		Headers: []Header{
+			{Name: ":method", Value: "POST"},
+			{Name: "user-agent", Value: "captured/1.0"},
+			{Name: "X-Trace", Value: "1"},
+			{Name: "Content-Length", Value: "100"},
+		},
+		Body: []byte("hello"),
+	}
+	resp, err := tpl.Request(restys.C()).Send(tpl.Method, tpl.URL)
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, http.StatusOK, resp.StatusCode)
+	tests.AssertEqual(t, []string{"captured/1.0"}, gotHeader["User-Agent"])
+	tests.AssertEqual(t, "1", gotHeader.Get("X-Trace"))
+	tests.AssertEqual(t, "hello", string(gotBody))
+}
@@ -0,0 +1,218 @@
+package capture
+
+import (
+	"archive/zip"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ReadHAR reads the templates from the HAR (HTTP Archive) document.
+func ReadHAR(r io.Reader) ([]*Template, error) {
+	var doc struct {
+		Log struct {
+			Entries []struct {
+				Request struct {
+					Method      string `json:"method"`
+					URL         string `json:"url"`
+					HTTPVersion string `json:"httpVersion"`
+					Headers     []struct {
+						Name  string `json:"name"`
+						Value string `json:"value"`
+					} `json:"headers"`
+					PostData *struct {
+						Text     string `json:"text"`
+						Encoding string `json:"encoding"` // non-standard, used by some tools
+					} `json:"postData"`
+				} `json:"request"`
+			} `json:"entries"`
+		} `json:"log"`
+	}
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("capture: invalid HAR: %w", err)
+	}
+	templates := make([]*Template, 0, len(doc.Log.Entries))
+	for _, e := range doc.Log.Entries {
+		t := &Template{
+			Method: e.Request.Method,
+			URL:    e.Request.URL,
+			Proto:  e.Request.HTTPVersion,
+		}
+		for _, h := range e.Request.Headers {
+			t.Headers = append(t.Headers, Header{Name: h.Name, Value: h.Value})
+		}
+		if pd := e.Request.PostData; pd != nil && pd.Text != "" {
+			body, err := decodeText(pd.Text, pd.Encoding)
+			if err != nil {
+				return nil, err
+			}
+			t.Body = body
+		}
+		templates = append(templates, t)
+	}
+	return templates, nil
+}
+
+func decodeText(text, encoding string) ([]byte, error) {
+	if encoding == "base64" {
+		body, err := base64.StdEncoding.DecodeString(text)
+		if err != nil {
+			return nil, fmt.Errorf("capture: invalid base64 body: %w", err)
+		}
+		return body, nil
+	}
+	return []byte(text), nil
+}
+
+// ReadCharles reads the templates from the Charles JSON session (.chlsj).
+func ReadCharles(r io.Reader) ([]*Template, error) {
+	var transactions []struct {
+		Method          string `json:"method"`
+		ProtocolVersion string `json:"protocolVersion"`
+		Scheme          string `json:"scheme"`
+		Host            string `json:"host"`
+		Port            int    `json:"port"`
+		ActualPort      int    `json:"actualPort"`
+		Path            string `json:"path"`
+		Query           string `json:"query"`
+		Request         struct {
+			Header struct {
+				Headers []struct {
+					Name  string `json:"name"`
+					Value string `json:"value"`
+				} `json:"headers"`
+			} `json:"header"`
+			Body *struct {
+				Text    string `json:"text"`
+				Encoded string `json:"encoded"`
+			} `json:"body"`
+		} `json:"request"`
+	}
+	if err := json.NewDecoder(r).Decode(&transactions); err != nil {
+		return nil, fmt.Errorf("capture: invalid Charles JSON session: %w", err)
+	}
+	templates := make([]*Template, 0, len(transactions))
+	for _, tx := range transactions {
+		// the tunnels of the HTTPS requests which are not decrypted.
+		if tx.Method == "CONNECT" {
+			continue
+		}
+		u := &url.URL{Scheme: tx.Scheme, Host: tx.Host, Path: tx.Path, RawQuery: tx.Query}
+		port := tx.ActualPort
+		if port == 0 {
+			port = tx.Port
+		}
+		if port != 0 && !(tx.Scheme == "http" && port == 80) && !(tx.Scheme == "https" && port == 443) {
+			u.Host = net.JoinHostPort(tx.Host, strconv.Itoa(port))
+		}
+		t := &Template{Method: tx.Method, URL: u.String(), Proto: tx.ProtocolVersion}
+		for _, h := range tx.Request.Header.Headers {
+			t.Headers = append(t.Headers, Header{Name: h.Name, Value: h.Value})
+		}
+		if b := tx.Request.Body; b != nil {
+			if b.Encoded != "" {
+				body, err := decodeText(b.Encoded, "base64")
+				if err != nil {
+					return nil, err
+				}
+				t.Body = body
+			} else if b.Text != "" {
+				t.Body = []byte(b.Text)
+			}
+		}
+		templates = append(templates, t)
+	}
+	return templates, nil
+}
+
+// ReadBurp reads the templates from the XML exported by Burp Suite ("Save
+// items" of proxy history), the raw requests may be base64 encoded or not.
+func ReadBurp(r io.Reader) ([]*Template, error) {
+	var doc struct {
+		Items []struct {
+			URL      string `xml:"url"`
+			Protocol string `xml:"protocol"`
+			Request  struct {
+				Base64 bool   `xml:"base64,attr"`
+				Data   string `xml:",chardata"`
+			} `xml:"request"`
+		} `xml:"item"`
+	}
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("capture: invalid Burp XML: %w", err)
+	}
+	templates := make([]*Template, 0, len(doc.Items))
+	for _, item := range doc.Items {
+		raw := []byte(item.Request.Data)
+		if item.Request.Base64 {
+			var err error
+			if raw, err = decodeText(strings.TrimSpace(item.Request.Data), "base64"); err != nil {
+				return nil, err
+			}
+		}
+		t, err := ParseRawRequest(raw, item.Protocol)
+		if err != nil {
+			return nil, err
+		}
+		if item.URL != "" {
+			t.URL = item.URL
+		}
+		templates = append(templates, t)
+	}
+	return templates, nil
+}
+
+// ReadSAZ reads the templates from the Fiddler session archive (.saz), which
+// is a zip file contains the raw requests as "raw/<n>_c.txt".
+func ReadSAZ(r io.ReaderAt, size int64) ([]*Template, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("capture: invalid SAZ: %w", err)
+	}
+	type session struct {
+		n    int
+		file *zip.File
+	}
+	var sessions []session
+	for _, f := range zr.File {
+		name := strings.ReplaceAll(f.Name, "\\", "/")
+		if !strings.HasPrefix(name, "raw/") || !strings.HasSuffix(name, "_c.txt") {
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(name, "raw/"), "_c.txt"))
+		if err != nil {
+			continue
+		}
+		sessions = append(sessions, session{n, f})
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].n < sessions[j].n })
+
+	templates := make([]*Template, 0, len(sessions))
+	for _, s := range sessions {
+		rc, err := s.file.Open()
+		if err != nil {
+			return nil, err
+		}
+		raw, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		t, err := ParseRawRequest(raw, "")
+		if err != nil {
+			return nil, fmt.Errorf("capture: session %d: %w", s.n, err)
+		}
+		if t.Method == "CONNECT" {
+			continue
+		}
+		templates = append(templates, t)
+	}
+	return templates, nil
+}
@@ -0,0 +1,210 @@
+// Package cdp hands the browser session over between a Chrome DevTools
+// Protocol (CDP) endpoint and a restys Client, which enables the hybrid
+// browser + HTTP automation flows, e.g. log in with the browser (or
+// Playwright, Puppeteer), then continue with the fast HTTP client:
+//
+//	s, err := cdp.Dial(ctx, "http://127.0.0.1:9222")
+//	if err != nil {
+//	    return err
+//	}
+//	defer s.Close()
+//	client := restys.C().ImpersonateChrome()
+//	err = s.ImportTo(ctx, client, &cdp.ImportOptions{PageURL: "https://example.com"})
+//
+// The browser must be started with the remote debugging port, and allow
+// the origin of the WebSocket handshake, e.g.
+// "chrome --remote-debugging-port=9222 --remote-allow-origins=*".
+package cdp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/websocket"
+)
+
+// Error is the error returned by the CDP endpoint.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("cdp: %s (%d)", e.Message, e.Code)
+}
+
+// ErrClosed is returned by the calls after the Session is closed.
+var ErrClosed = errors.New("cdp: session closed")
+
+type message struct {
+	ID        int64           `json:"id,omitempty"`
+	Method    string          `json:"method,omitempty"`
+	Params    any             `json:"params,omitempty"`
+	SessionID string          `json:"sessionId,omitempty"`
+	Result    json.RawMessage `json:"result,omitempty"`
+	Error     *Error          `json:"error,omitempty"`
+}
+
+// Session is the connection to the browser-level CDP endpoint, it is safe
+// for concurrent use.
+type Session struct {
+	conn *websocket.Conn
+
+	mu      sync.Mutex
+	nextID  int64
+	pending map[int64]chan *message
+	err     error // set when the connection is broken
+	done    chan struct{}
+}
+
+// Dial connects to the CDP endpoint, which is either the WebSocket URL of
+// the browser (e.g. "ws://127.0.0.1:9222/devtools/browser/<id>"), or the
+// HTTP URL of the remote debugging port (e.g. "http://127.0.0.1:9222")
+// whose WebSocket URL is discovered from "/json/version".
+func Dial(ctx context.Context, endpoint string) (*Session, error) {
+	wsURL := endpoint
+	if strings.HasPrefix(endpoint, "http://") || strings.HasPrefix(endpoint, "https://") {
+		var err error
+		if wsURL, err = discover(ctx, endpoint); err != nil {
+			return nil, err
+		}
+	}
+	u, err := url.Parse(wsURL)
+	if err != nil {
+		return nil, err
+	}
+	origin := "http://" + u.Host
+	if u.Scheme == "wss" {
+		origin = "https://" + u.Host
+	}
+	config, err := websocket.NewConfig(wsURL, origin)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := config.DialContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s := &Session{
+		conn:    conn,
+		pending: make(map[int64]chan *message),
+		done:    make(chan struct{}),
+	}
+	go s.readLoop()
+	return s, nil
+}
+
+func discover(ctx context.Context, endpoint string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(endpoint, "/")+"/json/version", nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("cdp: unexpected status %s of %s", resp.Status, req.URL)
+	}
+	var v struct {
+		WebSocketDebuggerURL string `json:"webSocketDebuggerUrl"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&v); err != nil {
+		return "", err
+	}
+	if v.WebSocketDebuggerURL == "" {
+		return "", errors.New("cdp: missing webSocketDebuggerUrl")
+	}
+	return v.WebSocketDebuggerURL, nil
+}
+
+func (s *Session) readLoop() {
+	var err error
+	for {
+		var msg message
+		if err = websocket.JSON.Receive(s.conn, &msg); err != nil {
+			break
+		}
+		if msg.ID == 0 { // event
+			continue
+		}
+		s.mu.Lock()
+		ch := s.pending[msg.ID]
+		delete(s.pending, msg.ID)
+		s.mu.Unlock()
+		if ch != nil {
+			ch <- &msg
+		}
+	}
+	s.mu.Lock()
+	if s.err == nil {
+		s.err = err
+	}
+	s.pending = nil
+	s.mu.Unlock()
+	close(s.done)
+}
+
+// Close closes the connection.
+func (s *Session) Close() error {
+	s.mu.Lock()
+	if s.err == nil {
+		s.err = ErrClosed
+	}
+	s.mu.Unlock()
+	return s.conn.Close()
+}
+
+// Call invokes the CDP method with params, and unmarshals the result into
+// result if not nil. sessionID is the ID of the target session attached by
+// "Target.attachToTarget", empty means the browser.
+func (s *Session) Call(ctx context.Context, sessionID, method string, params, result any) error {
+	ch := make(chan *message, 1)
+	s.mu.Lock()
+	if s.err != nil {
+		err := s.err
+		s.mu.Unlock()
+		return err
+	}
+	s.nextID++
+	id := s.nextID
+	s.pending[id] = ch
+	s.mu.Unlock()
+
+	err := websocket.JSON.Send(s.conn, &message{ID: id, Method: method, Params: params, SessionID: sessionID})
+	if err != nil {
+		s.removePending(id)
+		return err
+	}
+	select {
+	case msg := <-ch:
+		if msg.Error != nil {
+			return msg.Error
+		}
+		if result != nil {
+			return json.Unmarshal(msg.Result, result)
+		}
+		return nil
+	case <-s.done:
+		s.mu.Lock()
+		err = s.err
+		s.mu.Unlock()
+		return err
+	case <-ctx.Done():
+		s.removePending(id)
+		return ctx.Err()
+	}
+}
+
+func (s *Session) removePending(id int64) {
+	s.mu.Lock()
+	delete(s.pending, id)
+	s.mu.Unlock()
+}
@@ -0,0 +1,176 @@
+package cdp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/luoxk/restys"
+	"github.com/luoxk/restys/internal/tests"
+	"golang.org/x/net/websocket"
+)
+
+type fakeBrowser struct {
+	mu      sync.Mutex
+	cookies []*Cookie
+	state   string
+}
+
+func (b *fakeBrowser) handle(ws *websocket.Conn) {
+	for {
+		var msg message
+		if err := websocket.JSON.Receive(ws, &msg); err != nil {
+			return
+		}
+		resp := map[string]any{"id": msg.ID}
+		b.mu.Lock()
+		switch msg.Method {
+		case "Storage.getCookies":
+			resp["result"] = map[string]any{"cookies": b.cookies}
+		case "Storage.setCookies":
+			var params struct {
+				Cookies []*Cookie `json:"cookies"`
+			}
+			data, _ := json.Marshal(msg.Params)
+			json.Unmarshal(data, &params)
+			b.cookies = append(b.cookies, params.Cookies...)
+			resp["result"] = map[string]any{}
+		case "Browser.getVersion":
+			resp["result"] = map[string]any{"userAgent": "FakeBrowser/1.0"}
+		case "Target.getTargets":
+			resp["result"] = map[string]any{"targetInfos": []map[string]any{
+				{"targetId": "sw", "type": "service_worker", "url": "https://example.com/sw.js"},
+				{"targetId": "page1", "type": "page", "url": "https://example.com/home"},
+			}}
+		case "Target.attachToTarget":
+			resp["result"] = map[string]any{"sessionId": "session1"}
+		case "Target.detachFromTarget":
+			resp["result"] = map[string]any{}
+		case "Runtime.evaluate":
+			if msg.SessionID != "session1" {
+				resp["error"] = map[string]any{"code": -32001, "message": "Session not found"}
+			} else {
+				resp["result"] = map[string]any{"result": map[string]any{"type": "string", "value": b.state}}
+			}
+		default:
+			resp["error"] = map[string]any{"code": -32601, "message": "'" + msg.Method + "' wasn't found"}
+		}
+		b.mu.Unlock()
+		if err := websocket.JSON.Send(ws, resp); err != nil {
+			return
+		}
+	}
+}
+
+func newFakeBrowser(t *testing.T, b *fakeBrowser) *httptest.Server {
+	mux := http.NewServeMux()
+	var srv *httptest.Server
+	mux.HandleFunc("/json/version", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"webSocketDebuggerUrl": "ws" + strings.TrimPrefix(srv.URL, "http") + "/devtools/browser/1",
+		})
+	})
+	mux.Handle("/devtools/browser/1", websocket.Handler(b.handle))
+	srv = httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestImportTo(t *testing.T) {
+	state, _ := json.Marshal(&PageState{
+		URL:            "https://example.com/home",
+		UserAgent:      "Mozilla/5.0 Fake",
+		Languages:      []string{"en-US", "en", "zh-CN"},
+		Brands:         []Brand{{Brand: "Chromium", Version: "120"}, {Brand: "Not_A Brand", Version: "8"}},
+		Platform:       "macOS",
+		LocalStorage:   map[string]string{"token": "abc"},
+		SessionStorage: map[string]string{},
+	})
+	b := &fakeBrowser{
+		cookies: []*Cookie{
+			{Name: "sid", Value: "123", Domain: "example.com", Path: "/", Secure: true},
+			{Name: "pref", Value: "dark", Domain: ".example.com", Path: "/", Expires: -1},
+		},
+		state: string(state),
+	}
+	srv := newFakeBrowser(t, b)
+
+	ctx := context.Background()
+	s, err := Dial(ctx, srv.URL)
+	tests.AssertNoError(t, err)
+	defer s.Close()
+
+	c := restys.C()
+	err = s.ImportTo(ctx, c, &ImportOptions{
+		PageURL: "https://example.com",
+		StorageHeaders: func(local, session map[string]string) map[string]string {
+			return map[string]string{"Authorization": "Bearer " + local["token"]}
+		},
+	})
+	tests.AssertNoError(t, err)
+
+	cookies, err := c.GetCookies("https://www.example.com/")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, 1, len(cookies)) // sid is host-only
+	tests.AssertEqual(t, "pref", cookies[0].Name)
+	cookies, err = c.GetCookies("https://example.com/")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, 2, len(cookies))
+
+	tests.AssertEqual(t, "Mozilla/5.0 Fake", c.Headers.Get("User-Agent"))
+	tests.AssertEqual(t, `"Chromium";v="120", "Not_A Brand";v="8"`, c.Headers.Get("Sec-CH-UA"))
+	tests.AssertEqual(t, "?0", c.Headers.Get("Sec-CH-UA-Mobile"))
+	tests.AssertEqual(t, `"macOS"`, c.Headers.Get("Sec-CH-UA-Platform"))
+	tests.AssertEqual(t, "en-US,en;q=0.9,zh-CN;q=0.8", c.Headers.Get("Accept-Language"))
+	tests.AssertEqual(t, "Bearer abc", c.Headers.Get("Authorization"))
+
+	c2 := restys.C()
+	tests.AssertNoError(t, s.ImportTo(ctx, c2, &ImportOptions{SkipPage: true}))
+	tests.AssertEqual(t, "FakeBrowser/1.0", c2.Headers.Get("User-Agent"))
+
+	err = s.ImportTo(ctx, restys.C(), &ImportOptions{PageURL: "https://other.com"})
+	tests.AssertErrorContains(t, err, "no page matches")
+}
+
+func TestExportFrom(t *testing.T) {
+	b := &fakeBrowser{}
+	srv := newFakeBrowser(t, b)
+
+	ctx := context.Background()
+	wsURL := "ws" + strings.TrimPrefix(srv.URL, "http") + "/devtools/browser/1"
+	s, err := Dial(ctx, wsURL)
+	tests.AssertNoError(t, err)
+	defer s.Close()
+
+	c := restys.C()
+	u, _ := url.Parse("https://example.com/")
+	c.GetClient().Jar.SetCookies(u, []*http.Cookie{{Name: "sid", Value: "456"}})
+	tests.AssertNoError(t, s.ExportFrom(ctx, c, "https://example.com/"))
+	cookies, err := s.Cookies(ctx)
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, 1, len(cookies))
+	tests.AssertEqual(t, "sid", cookies[0].Name)
+	tests.AssertEqual(t, "example.com", cookies[0].Domain)
+	tests.AssertEqual(t, true, cookies[0].Secure)
+}
+
+func TestCallError(t *testing.T) {
+	srv := newFakeBrowser(t, &fakeBrowser{})
+	s, err := Dial(context.Background(), srv.URL)
+	tests.AssertNoError(t, err)
+
+	err = s.Call(context.Background(), "", "Page.navigate", nil, nil)
+	tests.AssertErrorContains(t, err, "wasn't found")
+	if e, ok := err.(*Error); !ok || e.Code != -32601 {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	s.Close()
+	err = s.Call(context.Background(), "", "Browser.getVersion", nil, nil)
+	tests.AssertEqual(t, ErrClosed, err)
+}
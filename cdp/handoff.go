@@ -0,0 +1,313 @@
+package cdp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/luoxk/restys"
+)
+
+// Cookie is the cookie of the browser, see Network.Cookie of CDP.
+type Cookie struct {
+	Name     string  `json:"name"`
+	Value    string  `json:"value"`
+	Domain   string  `json:"domain"`
+	Path     string  `json:"path"`
+	Expires  float64 `json:"expires,omitempty"` // seconds since epoch, -1 for session cookie
+	HTTPOnly bool    `json:"httpOnly"`
+	Secure   bool    `json:"secure"`
+	SameSite string  `json:"sameSite,omitempty"`
+}
+
+// HTTPCookie converts the cookie to *http.Cookie.
+func (c *Cookie) HTTPCookie() *http.Cookie {
+	hc := &http.Cookie{
+		Name:     c.Name,
+		Value:    c.Value,
+		Path:     c.Path,
+		HttpOnly: c.HTTPOnly,
+		Secure:   c.Secure,
+	}
+	// the domain of host-only cookie has no leading dot.
+	if strings.HasPrefix(c.Domain, ".") {
+		hc.Domain = c.Domain
+	}
+	if c.Expires > 0 {
+		hc.Expires = time.Unix(0, int64(c.Expires*float64(time.Second)))
+	}
+	switch c.SameSite {
+	case "Strict":
+		hc.SameSite = http.SameSiteStrictMode
+	case "Lax":
+		hc.SameSite = http.SameSiteLaxMode
+	case "None":
+		hc.SameSite = http.SameSiteNoneMode
+	}
+	return hc
+}
+
+// url returns the URL the cookie belongs to, which is used to store the
+// cookie into the cookie jar.
+func (c *Cookie) url() *url.URL {
+	scheme := "http"
+	if c.Secure {
+		scheme = "https"
+	}
+	path := c.Path
+	if path == "" {
+		path = "/"
+	}
+	return &url.URL{Scheme: scheme, Host: strings.TrimPrefix(c.Domain, "."), Path: path}
+}
+
+// Cookies returns all cookies of the browser.
+func (s *Session) Cookies(ctx context.Context) ([]*Cookie, error) {
+	var result struct {
+		Cookies []*Cookie `json:"cookies"`
+	}
+	if err := s.Call(ctx, "", "Storage.getCookies", nil, &result); err != nil {
+		return nil, err
+	}
+	return result.Cookies, nil
+}
+
+// SetCookies sets the cookies into the browser.
+func (s *Session) SetCookies(ctx context.Context, cookies []*Cookie) error {
+	return s.Call(ctx, "", "Storage.setCookies", map[string]any{"cookies": cookies}, nil)
+}
+
+// UserAgent returns the User-Agent of the browser.
+func (s *Session) UserAgent(ctx context.Context) (string, error) {
+	var result struct {
+		UserAgent string `json:"userAgent"`
+	}
+	if err := s.Call(ctx, "", "Browser.getVersion", nil, &result); err != nil {
+		return "", err
+	}
+	return result.UserAgent, nil
+}
+
+// Brand is the brand of the User-Agent client hints.
+type Brand struct {
+	Brand   string `json:"brand"`
+	Version string `json:"version"`
+}
+
+// PageState is the state of a page which is useful to the HTTP client.
+type PageState struct {
+	URL       string   `json:"url"`
+	UserAgent string   `json:"userAgent"`
+	Languages []string `json:"languages"`
+	// Brands, Mobile and Platform are the User-Agent client hints, which
+	// are empty if the browser does not support them.
+	Brands   []Brand `json:"brands"`
+	Mobile   bool    `json:"mobile"`
+	Platform string  `json:"platform"`
+	// LocalStorage and SessionStorage are the items of the storages of the
+	// page origin.
+	LocalStorage   map[string]string `json:"localStorage"`
+	SessionStorage map[string]string `json:"sessionStorage"`
+}
+
+// ClientHintHeaders returns the low entropy client hint headers (Sec-CH-UA,
+// Sec-CH-UA-Mobile, Sec-CH-UA-Platform) which the browser sends by
+// default, and the Accept-Language header.
+func (p *PageState) ClientHintHeaders() map[string]string {
+	h := make(map[string]string)
+	if len(p.Brands) > 0 {
+		brands := make([]string, len(p.Brands))
+		for i, b := range p.Brands {
+			brands[i] = fmt.Sprintf("%q;v=%q", b.Brand, b.Version)
+		}
+		h["Sec-CH-UA"] = strings.Join(brands, ", ")
+		h["Sec-CH-UA-Mobile"] = "?0"
+		if p.Mobile {
+			h["Sec-CH-UA-Mobile"] = "?1"
+		}
+		if p.Platform != "" {
+			h["Sec-CH-UA-Platform"] = fmt.Sprintf("%q", p.Platform)
+		}
+	}
+	if len(p.Languages) > 0 {
+		langs := make([]string, len(p.Languages))
+		for i, l := range p.Languages {
+			if i == 0 {
+				langs[i] = l
+			} else {
+				q := 1 - 0.1*float64(i)
+				if q < 0.1 {
+					q = 0.1
+				}
+				langs[i] = fmt.Sprintf("%s;q=%.1f", l, q)
+			}
+		}
+		h["Accept-Language"] = strings.Join(langs, ",")
+	}
+	return h
+}
+
+const pageStateScript = `(async () => {
+	const d = navigator.userAgentData;
+	const items = (s) => { const m = {}; try { for (let i = 0; i < s.length; i++) { const k = s.key(i); m[k] = s.getItem(k); } } catch (e) {} return m; };
+	return JSON.stringify({
+		url: location.href,
+		userAgent: navigator.userAgent,
+		languages: navigator.languages,
+		brands: d ? d.brands : [],
+		mobile: d ? d.mobile : false,
+		platform: d ? d.platform : "",
+		localStorage: items(window.localStorage),
+		sessionStorage: items(window.sessionStorage),
+	});
+})()`
+
+type targetInfo struct {
+	TargetID string `json:"targetId"`
+	Type     string `json:"type"`
+	URL      string `json:"url"`
+}
+
+// PageState returns the state of the first page whose URL has the prefix
+// pageURL, or the first page if pageURL is empty.
+func (s *Session) PageState(ctx context.Context, pageURL string) (*PageState, error) {
+	var targets struct {
+		TargetInfos []targetInfo `json:"targetInfos"`
+	}
+	if err := s.Call(ctx, "", "Target.getTargets", nil, &targets); err != nil {
+		return nil, err
+	}
+	var target *targetInfo
+	for i, t := range targets.TargetInfos {
+		if t.Type == "page" && strings.HasPrefix(t.URL, pageURL) {
+			target = &targets.TargetInfos[i]
+			break
+		}
+	}
+	if target == nil {
+		return nil, fmt.Errorf("cdp: no page matches %q", pageURL)
+	}
+
+	var attached struct {
+		SessionID string `json:"sessionId"`
+	}
+	err := s.Call(ctx, "", "Target.attachToTarget", map[string]any{"targetId": target.TargetID, "flatten": true}, &attached)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Call(context.Background(), "", "Target.detachFromTarget", map[string]any{"sessionId": attached.SessionID}, nil)
+
+	var evaluated struct {
+		Result struct {
+			Value string `json:"value"`
+		} `json:"result"`
+		ExceptionDetails *struct {
+			Text string `json:"text"`
+		} `json:"exceptionDetails"`
+	}
+	err = s.Call(ctx, attached.SessionID, "Runtime.evaluate", map[string]any{
+		"expression":    pageStateScript,
+		"awaitPromise":  true,
+		"returnByValue": true,
+	}, &evaluated)
+	if err != nil {
+		return nil, err
+	}
+	if evaluated.ExceptionDetails != nil {
+		return nil, errors.New("cdp: failed to evaluate page state: " + evaluated.ExceptionDetails.Text)
+	}
+	state := &PageState{}
+	if err = json.Unmarshal([]byte(evaluated.Result.Value), state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// ImportOptions controls what is imported into the Client, see
+// Session.ImportTo.
+type ImportOptions struct {
+	// PageURL selects the page whose client hints and storages are imported
+	// by URL prefix, empty means the first page.
+	PageURL string
+	// SkipPage only imports the cookies and the User-Agent, without
+	// reading the state of the page.
+	SkipPage bool
+	// StorageHeaders optionally derives the common headers from the
+	// localStorage and sessionStorage of the page, e.g. the bearer token
+	// which the single page application keeps in localStorage.
+	StorageHeaders func(localStorage, sessionStorage map[string]string) map[string]string
+}
+
+// ImportTo imports the browser session into the client: the cookies are
+// stored into the cookie jar of the client, and the User-Agent, client
+// hints, Accept-Language and the headers derived from storages (see
+// ImportOptions.StorageHeaders) are set as the common headers.
+func (s *Session) ImportTo(ctx context.Context, c *restys.Client, opts *ImportOptions) error {
+	if opts == nil {
+		opts = &ImportOptions{}
+	}
+	jar := c.GetClient().Jar
+	if jar == nil {
+		return errors.New("cdp: the client has no cookie jar")
+	}
+	cookies, err := s.Cookies(ctx)
+	if err != nil {
+		return err
+	}
+	for _, cookie := range cookies {
+		jar.SetCookies(cookie.url(), []*http.Cookie{cookie.HTTPCookie()})
+	}
+
+	if opts.SkipPage {
+		ua, err := s.UserAgent(ctx)
+		if err != nil {
+			return err
+		}
+		c.SetUserAgent(ua)
+		return nil
+	}
+	state, err := s.PageState(ctx, opts.PageURL)
+	if err != nil {
+		return err
+	}
+	c.SetUserAgent(state.UserAgent)
+	c.SetCommonHeaders(state.ClientHintHeaders())
+	if opts.StorageHeaders != nil {
+		c.SetCommonHeaders(opts.StorageHeaders(state.LocalStorage, state.SessionStorage))
+	}
+	return nil
+}
+
+// ExportFrom exports the cookies of the client for the urls into the
+// browser, since the cookie jar can not be enumerated.
+func (s *Session) ExportFrom(ctx context.Context, c *restys.Client, urls ...string) error {
+	var cookies []*Cookie
+	for _, rawURL := range urls {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return err
+		}
+		hcs, err := c.GetCookies(rawURL)
+		if err != nil {
+			return err
+		}
+		for _, hc := range hcs {
+			cookies = append(cookies, &Cookie{
+				Name:   hc.Name,
+				Value:  hc.Value,
+				Domain: u.Hostname(),
+				Path:   "/",
+				Secure: u.Scheme == "https",
+			})
+		}
+	}
+	if len(cookies) == 0 {
+		return nil
+	}
+	return s.SetCookies(ctx, cookies)
+}
@@ -0,0 +1,97 @@
+package restys
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// ErrCertificatePinMismatch is returned when none of the SPKI hashes of the
+// server certificate chain matches the pins of the host (see
+// Client.SetCertificatePins), use errors.Is to check it.
+var ErrCertificatePinMismatch = errors.New("certificate pin mismatch")
+
+type certificatePin struct {
+	pattern string
+	hashes  [][]byte
+}
+
+type certificatePins []certificatePin
+
+func parseCertificatePins(pins map[string][]string) (certificatePins, error) {
+	var errs []error
+	var result certificatePins
+	for host, values := range pins {
+		pin := certificatePin{pattern: strings.ToLower(host)}
+		for _, value := range values {
+			hash, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, "sha256/"))
+			if err != nil || len(hash) != sha256.Size {
+				errs = append(errs, fmt.Errorf("invalid certificate pin %q of %s", value, host))
+				continue
+			}
+			pin.hashes = append(pin.hashes, hash)
+		}
+		if len(pin.hashes) > 0 {
+			result = append(result, pin)
+		}
+	}
+	return result, errors.Join(errs...)
+}
+
+// verify checks the SPKI hashes of the certificate chain sent by host
+// against the pins of host, the hosts without pins are not checked.
+func (pins certificatePins) verify(host string, state *tls.ConnectionState) error {
+	host = strings.ToLower(host)
+	var hashes [][]byte
+	for _, pin := range pins {
+		if ok, _ := path.Match(pin.pattern, host); ok {
+			hashes = append(hashes, pin.hashes...)
+		}
+	}
+	if len(hashes) == 0 {
+		return nil
+	}
+	for _, cert := range state.PeerCertificates {
+		sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+		for _, hash := range hashes {
+			if subtle.ConstantTimeCompare(sum[:], hash) == 1 {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("%w for %s", ErrCertificatePinMismatch, host)
+}
+
+// SetCertificatePins set the SPKI pins per host, the connection to a pinned
+// host fails with ErrCertificatePinMismatch unless one of the certificates
+// sent by the server has a public key whose SHA-256 hash matches one of the
+// pins, which detects the MITM interception even if the interceptor's CA is
+// trusted, e.g.
+//
+//	client.SetCertificatePins(map[string][]string{
+//		"api.example.com": {"sha256/47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="},
+//		"*.example.com":   {"47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="},
+//	})
+//
+// The pins are the base64 encoded SHA-256 hashes of the SubjectPublicKeyInfo
+// with an optional "sha256/" prefix, and the host is matched with path.Match
+// syntax like Client.ForHost. It's checked after the tls handshake of HTTP1,
+// HTTP2 and HTTP3, including the tls fingerprint (e.g. SetTLSFingerprint).
+// Invalid pins are logged and ignored, pass nil to disable pinning.
+func (c *Client) SetCertificatePins(pins map[string][]string) *Client {
+	certPins, err := parseCertificatePins(pins)
+	if err != nil {
+		c.log.Errorf("%s", err.Error())
+	}
+	if len(certPins) == 0 {
+		c.Transport.VerifyConnection = nil
+		return c
+	}
+	c.Transport.VerifyConnection = certPins.verify
+	return c
+}
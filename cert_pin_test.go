@@ -0,0 +1,48 @@
+package restys
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/luoxk/restys/internal/tests"
+)
+
+func TestSetCertificatePins(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	h1 := httptest.NewTLSServer(handler)
+	defer h1.Close()
+	h2 := httptest.NewUnstartedServer(handler)
+	h2.EnableHTTP2 = true
+	h2.StartTLS()
+	defer h2.Close()
+
+	sum := sha256.Sum256(h1.Certificate().RawSubjectPublicKeyInfo)
+	pin := "sha256/" + base64.StdEncoding.EncodeToString(sum[:])
+	wrong := base64.StdEncoding.EncodeToString(make([]byte, sha256.Size))
+
+	for _, c := range []*Client{
+		C().SetBaseURL(h1.URL),
+		C().SetBaseURL(h2.URL),
+		C().SetBaseURL(h2.URL).EnableForceHTTP2(),
+		C().SetBaseURL(h2.URL).SetTLSFingerprintChrome(),
+	} {
+		c.EnableInsecureSkipVerify().DisableKeepAlives()
+		resp, err := c.SetCertificatePins(map[string][]string{"127.0.0.*": {wrong, pin}}).R().Get("/")
+		assertSuccess(t, resp, err)
+
+		_, err = c.SetCertificatePins(map[string][]string{"127.0.0.1": {wrong}}).R().Get("/")
+		tests.AssertEqual(t, true, errors.Is(err, ErrCertificatePinMismatch))
+
+		resp, err = c.SetCertificatePins(map[string][]string{"example.com": {wrong}}).R().Get("/")
+		assertSuccess(t, resp, err)
+	}
+
+	c := tc().SetCertificatePins(map[string][]string{"example.com": {"invalid"}})
+	tests.AssertIsNil(t, c.VerifyConnection)
+}
@@ -0,0 +1,218 @@
+package restys
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// Challenge is an anti-bot challenge detected from the response, see
+// Client.SetChallengeSolver.
+type Challenge struct {
+	// Kind is the kind of the challenge returned by the detector, e.g.
+	// "cloudflare".
+	Kind string
+	// Attempt is the number of the challenges solved for the request
+	// before this one, starts from 0.
+	Attempt int
+	// Response is the challenge response, the body is available via
+	// Response.Bytes unless the response body is not read automatically.
+	Response *Response
+}
+
+// ChallengeSolution is the solved challenge, which is attached to the
+// re-issued request.
+type ChallengeSolution struct {
+	// Cookies is the cookies to send, which are saved into the cookie jar
+	// if the cookie jar is enabled, so the following requests also send
+	// them.
+	Cookies []*http.Cookie
+	// Headers is the headers set on the re-issued request.
+	Headers map[string]string
+}
+
+// ChallengeSolver solves the anti-bot challenge, e.g. by calling a token
+// service or an external solving API.
+type ChallengeSolver interface {
+	Solve(ctx context.Context, ch *Challenge) (*ChallengeSolution, error)
+}
+
+// ChallengeSolverFunc is an adapter to allow the use of ordinary functions
+// as ChallengeSolver.
+type ChallengeSolverFunc func(ctx context.Context, ch *Challenge) (*ChallengeSolution, error)
+
+// Solve calls f(ctx, ch).
+func (f ChallengeSolverFunc) Solve(ctx context.Context, ch *Challenge) (*ChallengeSolution, error) {
+	return f(ctx, ch)
+}
+
+// ChallengeDetector detects the anti-bot challenge from the response, and
+// returns the kind of the challenge, or empty string if the response is
+// not a challenge.
+type ChallengeDetector func(resp *Response) string
+
+// ChallengeOptions controls the challenge detecting and solving, see
+// Client.SetChallengeSolver.
+type ChallengeOptions struct {
+	// Detectors is the challenge detectors which are checked in order,
+	// default is DefaultChallengeDetectors().
+	Detectors []ChallengeDetector
+	// MaxAttempts is the max number of the challenges solved for a
+	// request, default is 1. The last challenge response is returned as is
+	// if the request is still challenged.
+	MaxAttempts int
+}
+
+// DefaultChallengeDetectors returns the detectors of the common JS and
+// managed challenges.
+func DefaultChallengeDetectors() []ChallengeDetector {
+	return []ChallengeDetector{
+		CloudflareChallengeDetector(),
+		AWSWAFChallengeDetector(),
+		DataDomeChallengeDetector(),
+		PerimeterXChallengeDetector(),
+	}
+}
+
+// CloudflareChallengeDetector detects the Cloudflare JS and managed
+// challenges, the kind is "cloudflare".
+func CloudflareChallengeDetector() ChallengeDetector {
+	return func(resp *Response) string {
+		if strings.EqualFold(resp.GetHeader("Cf-Mitigated"), "challenge") {
+			return "cloudflare"
+		}
+		if (resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusServiceUnavailable) &&
+			strings.EqualFold(resp.GetHeader("Server"), "cloudflare") &&
+			bodyContainsAny(resp, "challenge-platform", "cf_chl_opt") {
+			return "cloudflare"
+		}
+		return ""
+	}
+}
+
+// AWSWAFChallengeDetector detects the AWS WAF challenge and CAPTCHA
+// actions, the kind is "awswaf".
+func AWSWAFChallengeDetector() ChallengeDetector {
+	return func(resp *Response) string {
+		switch strings.ToLower(resp.GetHeader("X-Amzn-Waf-Action")) {
+		case "challenge", "captcha":
+			return "awswaf"
+		}
+		return ""
+	}
+}
+
+// DataDomeChallengeDetector detects the DataDome challenge, the kind is
+// "datadome".
+func DataDomeChallengeDetector() ChallengeDetector {
+	return func(resp *Response) string {
+		if resp.StatusCode == http.StatusForbidden &&
+			(resp.GetHeader("X-Datadome") != "" || bodyContainsAny(resp, "captcha-delivery.com")) {
+			return "datadome"
+		}
+		return ""
+	}
+}
+
+// PerimeterXChallengeDetector detects the PerimeterX (HUMAN) challenge,
+// the kind is "perimeterx".
+func PerimeterXChallengeDetector() ChallengeDetector {
+	return func(resp *Response) string {
+		if resp.StatusCode == http.StatusForbidden && bodyContainsAny(resp, "_pxCaptcha", "px-captcha", "_pxAppId") {
+			return "perimeterx"
+		}
+		return ""
+	}
+}
+
+func bodyContainsAny(resp *Response, markers ...string) bool {
+	body := resp.Bytes()
+	for _, marker := range markers {
+		if bytes.Contains(body, []byte(marker)) {
+			return true
+		}
+	}
+	return false
+}
+
+type challengeHandler struct {
+	solver      ChallengeSolver
+	detectors   []ChallengeDetector
+	maxAttempts int
+}
+
+// SetChallengeSolver enables the anti-bot challenge handling, the responses
+// are checked by the challenge detectors, and the detected challenge is
+// solved by solver, then the original request is re-issued with the solved
+// cookies and headers attached, which is numbered as an attempt (see
+// Request.RetryAttempt) but doesn't count against the retry count. Pass
+// nil solver to disable it, opts can be nil to use the default options.
+func (c *Client) SetChallengeSolver(solver ChallengeSolver, opts *ChallengeOptions) *Client {
+	if solver == nil {
+		c.challenge = nil
+		return c
+	}
+	h := &challengeHandler{solver: solver, maxAttempts: 1}
+	if opts != nil {
+		h.detectors = opts.Detectors
+		if opts.MaxAttempts > 0 {
+			h.maxAttempts = opts.MaxAttempts
+		}
+	}
+	if len(h.detectors) == 0 {
+		h.detectors = DefaultChallengeDetectors()
+	}
+	c.challenge = h
+	return c
+}
+
+func (h *challengeHandler) detect(resp *Response) string {
+	for _, detect := range h.detectors {
+		if kind := detect(resp); kind != "" {
+			return kind
+		}
+	}
+	return ""
+}
+
+// handle solves the challenge of resp if any, and attaches the solution to
+// the request, it reports whether the request should be re-issued.
+func (h *challengeHandler) handle(r *Request, resp *Response) (bool, error) {
+	if resp.Response == nil || r.challengeAttempt >= h.maxAttempts {
+		return false, nil
+	}
+	kind := h.detect(resp)
+	if kind == "" {
+		return false, nil
+	}
+	solution, err := h.solver.Solve(r.Context(), &Challenge{
+		Kind:     kind,
+		Attempt:  r.challengeAttempt,
+		Response: resp,
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to solve %s challenge: %w", kind, err)
+	}
+	r.challengeAttempt++
+	if solution == nil {
+		return true, nil
+	}
+	if len(solution.Cookies) > 0 {
+		if jar := r.client.httpClient.Jar; jar != nil {
+			jar.SetCookies(r.URL, solution.Cookies)
+		} else {
+			// replace the cookies of the last solution.
+			r.Cookies = slices.DeleteFunc(r.Cookies, func(cookie *http.Cookie) bool {
+				return slices.ContainsFunc(solution.Cookies, func(c *http.Cookie) bool { return c.Name == cookie.Name })
+			})
+			r.SetCookies(solution.Cookies...)
+		}
+	}
+	for k, v := range solution.Headers {
+		r.SetHeader(k, v)
+	}
+	return true, nil
+}
@@ -0,0 +1,118 @@
+package restys
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/luoxk/restys/internal/tests"
+)
+
+func TestChallengeSolver(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cookie, _ := r.Cookie("clearance")
+		cleared := cookie != nil && cookie.Value == "ok"
+		if r.URL.Path == "/token" {
+			cleared = cleared && r.Header.Get("X-Token") == "token"
+		}
+		if !cleared {
+			w.Header().Set("Server", "cloudflare")
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`<script src="/cdn-cgi/challenge-platform/h/b/orchestrate/chl_page/v1"></script>`))
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	var challenges []*Challenge
+	solver := ChallengeSolverFunc(func(ctx context.Context, ch *Challenge) (*ChallengeSolution, error) {
+		challenges = append(challenges, ch)
+		return &ChallengeSolution{
+			Cookies: []*http.Cookie{{Name: "clearance", Value: "ok"}},
+			Headers: map[string]string{"X-Token": "token"},
+		}, nil
+	})
+	c := C().SetBaseURL(server.URL).SetChallengeSolver(solver, nil)
+	resp, err := c.R().SetRetryCount(0).EnableTrace().Get("/token")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "ok", resp.String())
+	// the re-issue is numbered as an attempt, but not counted as a retry.
+	tests.AssertEqual(t, 1, resp.Request.RetryAttempt)
+	infos := resp.Request.TraceInfos()
+	tests.AssertEqual(t, 2, len(infos))
+	tests.AssertEqual(t, 0, infos[0].Attempt)
+	tests.AssertEqual(t, 1, infos[1].Attempt)
+	tests.AssertEqual(t, 1, len(challenges))
+	tests.AssertEqual(t, "cloudflare", challenges[0].Kind)
+
+	// the solved cookie is saved into the cookie jar.
+	resp, err = c.R().Get("/")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, 1, len(challenges))
+
+	// the challenge response is returned if it's still challenged.
+	challenges = nil
+	c = C().SetBaseURL(server.URL).SetChallengeSolver(ChallengeSolverFunc(func(ctx context.Context, ch *Challenge) (*ChallengeSolution, error) {
+		challenges = append(challenges, ch)
+		return nil, nil
+	}), &ChallengeOptions{MaxAttempts: 2})
+	resp, err = c.R().Get("/")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, http.StatusForbidden, resp.StatusCode)
+	tests.AssertEqual(t, 2, len(challenges))
+	tests.AssertEqual(t, 1, challenges[1].Attempt)
+
+	// the solved cookies replace the last ones without the cookie jar, and
+	// the counter is reset when the request is sent again.
+	values := []string{"bad", "ok"}
+	challenges = nil
+	c = C().SetBaseURL(server.URL).SetCookieJar(nil).SetChallengeSolver(ChallengeSolverFunc(func(ctx context.Context, ch *Challenge) (*ChallengeSolution, error) {
+		challenges = append(challenges, ch)
+		return &ChallengeSolution{Cookies: []*http.Cookie{{Name: "clearance", Value: values[ch.Attempt]}}}, nil
+	}), &ChallengeOptions{MaxAttempts: 2})
+	r := c.R()
+	resp, err = r.Get("/")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "ok", resp.String())
+	tests.AssertEqual(t, 1, len(r.Cookies))
+	r.Cookies = nil
+	resp, err = r.Get("/")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, 4, len(challenges))
+	tests.AssertEqual(t, 1, challenges[3].Attempt)
+
+	errSolve := errors.New("no token")
+	c.SetChallengeSolver(ChallengeSolverFunc(func(ctx context.Context, ch *Challenge) (*ChallengeSolution, error) {
+		return nil, errSolve
+	}), nil)
+	_, err = c.R().Get("/")
+	tests.AssertEqual(t, true, errors.Is(err, errSolve))
+}
+
+func TestChallengeDetectors(t *testing.T) {
+	newResp := func(code int, header http.Header, body string) *Response {
+		resp := &Response{Response: &http.Response{StatusCode: code, Header: header}}
+		resp.body = []byte(body)
+		return resp
+	}
+	cases := []struct {
+		resp *Response
+		want string
+	}{
+		{newResp(403, http.Header{"Cf-Mitigated": {"challenge"}}, ""), "cloudflare"},
+		{newResp(503, http.Header{"Server": {"cloudflare"}}, "window._cf_chl_opt={}"), "cloudflare"},
+		{newResp(503, http.Header{"Server": {"cloudflare"}}, "service unavailable"), ""},
+		{newResp(202, http.Header{"X-Amzn-Waf-Action": {"challenge"}}, ""), "awswaf"},
+		{newResp(403, http.Header{}, `<script src="https://ct.captcha-delivery.com/c.js"></script>`), "datadome"},
+		{newResp(403, http.Header{}, `<div id="px-captcha"></div>`), "perimeterx"},
+		{newResp(403, http.Header{}, "forbidden"), ""},
+		{newResp(200, http.Header{}, "px-captcha"), ""},
+	}
+	h := &challengeHandler{detectors: DefaultChallengeDetectors()}
+	for _, c := range cases {
+		tests.AssertEqual(t, c.want, h.detect(c.resp))
+	}
+}
@@ -6,7 +6,6 @@ import (
 	"crypto/sha256"
 	"crypto/tls"
 	"crypto/x509"
-	"encoding/json"
 	"encoding/xml"
 	"errors"
 	"fmt"
@@ -15,9 +14,13 @@ import (
 	"net"
 	"net/http"
 	"net/http/cookiejar"
+	"net/http/httptrace"
+	"net/netip"
+	"net/textproto"
 	urlpkg "net/url"
 	"os"
 	"reflect"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -27,18 +30,25 @@ import (
 
 	"github.com/luoxk/restys/http2"
 	"github.com/luoxk/restys/internal/header"
+	h2internal "github.com/luoxk/restys/internal/http2"
+	"github.com/luoxk/restys/internal/netutil"
+	"github.com/luoxk/restys/internal/tlsutil"
 	"github.com/luoxk/restys/internal/util"
 )
 
 // DefaultClient returns the global default Client.
 func DefaultClient() *Client {
+	defaultClientMu.RLock()
+	defer defaultClientMu.RUnlock()
 	return defaultClient
 }
 
 // SetDefaultClient override the global default Client.
 func SetDefaultClient(c *Client) {
 	if c != nil {
+		defaultClientMu.Lock()
 		defaultClient = c
+		defaultClientMu.Unlock()
 	}
 }
 
@@ -58,11 +68,16 @@ type Client struct {
 	trace                   bool
 	disableAutoReadResponse bool
 	commonErrorType         reflect.Type
+	statusResultTypes       map[int]reflect.Type
+	contentTypeResultTypes  map[string]reflect.Type
+	unknownResultHandler    func(resp *Response) error
 	retryOption             *retryOption
 	jsonMarshal             func(v interface{}) ([]byte, error)
 	jsonUnmarshal           func(data []byte, v interface{}) error
 	xmlMarshal              func(v interface{}) ([]byte, error)
 	xmlUnmarshal            func(data []byte, v interface{}) error
+	responseDecoders        map[string]func(data []byte, v interface{}) error
+	spillThreshold          int64
 	multipartBoundaryFunc   func() string
 	outputDirectory         string
 	scheme                  string
@@ -75,8 +90,29 @@ type Client struct {
 	wrappedRoundTrip        RoundTripper
 	roundTripWrappers       []RoundTripWrapper
 	responseBodyTransformer func(rawBody []byte, req *Request, resp *Response) (transformedBody []byte, err error)
+	bodyStreamTransformer   func(body io.Reader, info ResponseBodyInfo, req *Request, resp *Response) (io.Reader, error)
 	resultStateCheckFunc    func(resp *Response) ResultState
 	onError                 ErrorHook
+	onRedirect              func(req *http.Request, via []*http.Request) error
+	redirectBehavior        *RedirectBehavior
+	urlNormalization        URLNormalization
+	challenge               *challengeHandler
+	connHooks               connHooks
+	requestIDOption         *requestIDOption
+	inflight                *inflightRegistry
+	stats                   *statsCollector
+	observers               []Observer
+	hostConfigs             []*HostConfig
+	scheduler               *scheduler
+	schedule                *schedulePolicy
+	warmPool                *warmPool
+	headerFuncs             []commonHeaderFunc
+	cloneSource             *Client // only set while applying the options of CloneWith
+	sni                     string
+	ja3Options              *Ja3Options
+	tlsExtensionShuffle     bool
+	proxyPool               ProxyPool
+	closed                  int32
 }
 
 type ErrorHook func(client *Client, req *Request, resp *Response, err error)
@@ -166,11 +202,26 @@ func (c *Client) GetTransport() *Transport {
 
 // SetResponseBodyTransformer set the response body transformer, which can modify the
 // response body before unmarshalled if auto-read response body is not disabled.
+//
+// The rawBody is owned by the transformer, which can be modified in place
+// and returned to avoid the copy.
 func (c *Client) SetResponseBodyTransformer(fn func(rawBody []byte, req *Request, resp *Response) (transformedBody []byte, err error)) *Client {
 	c.responseBodyTransformer = fn
 	return c
 }
 
+// SetResponseBodyStreamTransformer set the streaming response body
+// transformer, which wraps the response body reader before it's read, so
+// the decryption or decompression layers can operate without buffering the
+// whole body, e.g. for the downloads and Request.Stream. The returned reader
+// is closed (if it's an io.Closer) with the response body, and the
+// transformer set by SetResponseBodyTransformer is still applied to the
+// auto-read body after that.
+func (c *Client) SetResponseBodyStreamTransformer(fn func(body io.Reader, info ResponseBodyInfo, req *Request, resp *Response) (io.Reader, error)) *Client {
+	c.bodyStreamTransformer = fn
+	return c
+}
+
 // SetCommonError set the common result that response body will be unmarshalled to
 // if no error occurs but Response.ResultState returns ErrorState, by default it
 // is HTTP status `code >= 400`, you can also use SetCommonResultStateChecker
@@ -192,6 +243,47 @@ func (c *Client) SetCommonErrorResult(err interface{}) *Client {
 	return c
 }
 
+// SetResultForStatus set the result that response body will be unmarshalled
+// to if the HTTP status code is code, a new instance of the result type is
+// created for each response, which is available via Response.Result if the
+// ResultState is not ErrorState, otherwise via Response.ErrorResult. It
+// takes precedence over SetResultForContentType and SetCommonErrorResult,
+// but not the results set by Request.SetSuccessResult and
+// Request.SetErrorResult.
+func (c *Client) SetResultForStatus(code int, result interface{}) *Client {
+	if result != nil {
+		if c.statusResultTypes == nil {
+			c.statusResultTypes = make(map[int]reflect.Type)
+		}
+		c.statusResultTypes[code] = util.GetType(result)
+	}
+	return c
+}
+
+// SetResultForContentType is like SetResultForStatus, but routes by the
+// media type of the Content-Type header (without parameters, e.g.
+// "application/problem+json").
+func (c *Client) SetResultForContentType(contentType string, result interface{}) *Client {
+	if result != nil {
+		if c.contentTypeResultTypes == nil {
+			c.contentTypeResultTypes = make(map[string]reflect.Type)
+		}
+		c.contentTypeResultTypes[strings.ToLower(contentType)] = util.GetType(result)
+	}
+	return c
+}
+
+// SetCommonUnknownResultHandlerFunc set the handler which is invoked if no
+// error occurs but Response.ResultState returns UnknownState (by default it
+// is HTTP status `code < 200` or `code >= 300 and code < 400`), and the
+// result is not routed by SetResultForStatus or SetResultForContentType,
+// the error returned by the handler is returned as the request error. It
+// can be overridden by Request.SetUnknownResultHandlerFunc.
+func (c *Client) SetCommonUnknownResultHandlerFunc(fn func(resp *Response) error) *Client {
+	c.unknownResultHandler = fn
+	return c
+}
+
 // ResultState represents the state of the result.
 type ResultState int
 
@@ -325,16 +417,64 @@ func (c *Client) GetTLSClientConfig() *tls.Config {
 	return c.TLSClientConfig
 }
 
+// OnRedirect set the hook which is called when the redirect request is
+// about to be sent and it's allowed by the redirect policy (see
+// SetRedirectPolicy), via is the requests made already, oldest first. The
+// hook can mutate req (e.g. the headers and cookies), or stop the chain by
+// returning an error, if http.ErrUseLastResponse is returned, the redirect
+// response is returned as is without error, e.g. to capture the Location
+// of the OAuth flows instead of following it.
+func (c *Client) OnRedirect(fn func(req *http.Request, via []*http.Request) error) *Client {
+	c.onRedirect = fn
+	return c
+}
+
+// SetRedirectBehavior set how the redirect request is made from the
+// previous request, e.g. whether POST is changed to GET on 301 and 302, see
+// RedirectBehavior. It can be overridden by Request.SetRedirectBehavior.
+func (c *Client) SetRedirectBehavior(behavior RedirectBehavior) *Client {
+	c.redirectBehavior = &behavior
+	return c
+}
+
+// SetURLNormalization set the normalizations applied to the request URL
+// before sending, e.g. NormalizeDefaultPort|NormalizeDotSegments, see
+// URLNormalization, default is 0 (no normalization). The non-ASCII
+// hostname is always converted to its IDNA ASCII form (punycode).
+func (c *Client) SetURLNormalization(n URLNormalization) *Client {
+	c.urlNormalization = n
+	return c
+}
+
 // SetRedirectPolicy set the RedirectPolicy which controls the behavior of receiving redirect
 // responses (usually responses with 301 and 302 status code), see the predefined
 // AllowedDomainRedirectPolicy, AllowedHostRedirectPolicy, DefaultRedirectPolicy, MaxRedirectPolicy,
 // NoRedirectPolicy, SameDomainRedirectPolicy and SameHostRedirectPolicy.
+// It can be overridden by Request.SetRedirectPolicy.
 func (c *Client) SetRedirectPolicy(policies ...RedirectPolicy) *Client {
 	if len(policies) == 0 {
 		return c
 	}
 	c.httpClient.CheckRedirect = func(req *http.Request, via []*http.Request) error {
-		for _, f := range policies {
+		var r *Request
+		if resp := redirectResponse(req); resp != nil {
+			resp.redirects = append(resp.redirects, time.Now())
+			r = resp.Request
+		}
+		rb := c.redirectBehavior
+		if r != nil && r.redirectBehavior != nil {
+			rb = r.redirectBehavior
+		}
+		if rb != nil {
+			if err := rb.apply(req, via); err != nil {
+				return err
+			}
+		}
+		ps := policies
+		if r != nil && len(r.redirectPolicies) > 0 {
+			ps = r.redirectPolicies
+		}
+		for _, f := range ps {
 			if f == nil {
 				continue
 			}
@@ -343,8 +483,25 @@ func (c *Client) SetRedirectPolicy(policies ...RedirectPolicy) *Client {
 				return err
 			}
 		}
+		if c.onRedirect != nil {
+			if err := c.onRedirect(req, via); err != nil {
+				return err
+			}
+		}
+		if r != nil && r.onRedirect != nil {
+			if err := r.onRedirect(req, via); err != nil {
+				return err
+			}
+		}
+		for _, o := range c.observers {
+			o.OnRedirect(req, via)
+		}
 		if c.DebugLog {
-			c.log.Debugf("<redirect> %s %s", req.Method, req.URL.String())
+			if opt := c.requestIDOption; opt != nil {
+				c.log.Debugf("<redirect> [%s] %s %s", req.Header.Get(opt.header), req.Method, req.URL.String())
+			} else {
+				c.log.Debugf("<redirect> %s %s", req.Method, req.URL.String())
+			}
 		}
 		return nil
 	}
@@ -486,6 +643,22 @@ func (c *Client) SetCommonQueryParam(key, value string) *Client {
 	return c
 }
 
+// RemoveCommonQueryParam removes the URL query parameter for requests
+// fired from the client.
+func (c *Client) RemoveCommonQueryParam(key string) *Client {
+	c.QueryParams.Del(key)
+	return c
+}
+
+// RemoveCommonQueryParams removes the URL query parameters for requests
+// fired from the client.
+func (c *Client) RemoveCommonQueryParams(keys ...string) *Client {
+	for _, key := range keys {
+		c.RemoveCommonQueryParam(key)
+	}
+	return c
+}
+
 // SetCommonQueryString set URL query parameters with a raw query string
 // for requests fired from the client.
 func (c *Client) SetCommonQueryString(query string) *Client {
@@ -511,6 +684,35 @@ func (c *Client) SetCommonCookies(cookies ...*http.Cookie) *Client {
 	return c
 }
 
+// RemoveCommonCookie removes the cookies with the name for requests fired
+// from the client which are set by SetCommonCookies, the cookies stored in
+// the cookie jar are not affected.
+func (c *Client) RemoveCommonCookie(name string) *Client {
+	cookies := c.Cookies[:0:0]
+	for _, cookie := range c.Cookies {
+		if cookie.Name != name {
+			cookies = append(cookies, cookie)
+		}
+	}
+	c.Cookies = cookies
+	return c
+}
+
+// ResetCommonState removes all common headers (including those set by
+// SetCommonHeaderFunc), query parameters, path parameters, form data and
+// cookies of the client, so that a long-lived client can be reconfigured.
+// Like other setters, it should not be called while requests are in flight,
+// consider using Client.Freeze for concurrent reconfiguration.
+func (c *Client) ResetCommonState() *Client {
+	c.Headers = nil
+	c.headerFuncs = nil
+	c.QueryParams = nil
+	c.PathParams = nil
+	c.FormData = nil
+	c.Cookies = nil
+	return c
+}
+
 // DisableDebugLog disable debug level log (disabled by default).
 func (c *Client) DisableDebugLog() *Client {
 	c.DebugLog = false
@@ -523,16 +725,58 @@ func (c *Client) EnableDebugLog() *Client {
 	return c
 }
 
+// EnableRequestID enable stamping every request with a unique ID in the
+// header (DefaultRequestIDHeader if headerName is empty), which is kept
+// on retries, the ID is generated by generator (NewRequestID if nil) unless
+// the request header has been set. The ID can be obtained by
+// Response.RequestID, and is included in debug log, dump and TraceInfo,
+// so that a single exchange can be correlated across logs and server-side
+// traces.
+func (c *Client) EnableRequestID(headerName string, generator RequestIDGenerator) *Client {
+	if headerName == "" {
+		headerName = DefaultRequestIDHeader
+	}
+	if generator == nil {
+		generator = NewRequestID
+	}
+	c.requestIDOption = &requestIDOption{
+		header:    headerName,
+		generator: generator,
+	}
+	return c
+}
+
+// DisableRequestID disable stamping request with the request ID (disabled
+// by default).
+func (c *Client) DisableRequestID() *Client {
+	c.requestIDOption = nil
+	return c
+}
+
 // DevMode enables:
-// 1. Dump content of all requests and responses to see details.
+// 1. Dump content of all requests and responses to see details, which is
+// formatted by CurlDumpFormatter (colorized if the output is a terminal)
+// unless the dump format has been set.
 // 2. Output debug level log for deeper insights.
 // 3. Trace all requests, so you can get trace info to analyze performance.
 func (c *Client) DevMode() *Client {
+	if opt := c.getDumpOptions(); opt.Formatter == nil && opt.Format == DumpFormatText {
+		opt.Formatter = CurlDumpFormatter{Color: isTerminal(opt.Output)}
+	}
 	return c.EnableDumpAll().
 		EnableDebugLog().
 		EnableTraceAll()
 }
 
+// SetCommonSNI set the server name sent in the tls handshake (SNI) for all
+// requests of the client, which is independent of the request URL and the
+// Host header, see Request.SetSNI. Pass an empty string to use the host of
+// request URL again (default).
+func (c *Client) SetCommonSNI(host string) *Client {
+	c.sni = host
+	return c
+}
+
 // SetScheme set the default scheme for client, will be used when
 // there is no scheme in the request URL (e.g. "github.com/imroc/req").
 func (c *Client) SetScheme(scheme string) *Client {
@@ -597,6 +841,26 @@ func (c *Client) EnableDumpAllToFile(filename string) *Client {
 	return c
 }
 
+// EnableDumpAllToRotateFile enable dump for requests fired from the
+// client and output to the specified file, which is rotated according
+// to opts (nil means the default options, see DumpRotateOptions), e.g.
+//
+//	client.EnableDumpAllToRotateFile("dump.log", &req.DumpRotateOptions{
+//		MaxSize:  10 << 20,
+//		MaxFiles: 3,
+//		Compress: true,
+//	})
+func (c *Client) EnableDumpAllToRotateFile(filename string, opts *DumpRotateOptions) *Client {
+	file, err := newRotateFile(filename, opts)
+	if err != nil {
+		c.log.Errorf("create dump file error: %v", err)
+		return c
+	}
+	c.getDumpOptions().Output = file
+	c.EnableDumpAll()
+	return c
+}
+
 // EnableDumpAllTo enable dump for requests fired from the
 // client and output to the specified io.Writer.
 func (c *Client) EnableDumpAllTo(output io.Writer) *Client {
@@ -607,7 +871,8 @@ func (c *Client) EnableDumpAllTo(output io.Writer) *Client {
 
 // EnableDumpAllAsync enable dump for requests fired from the
 // client and output asynchronously, can be used for debugging
-// in production environment without affecting performance.
+// in production environment without affecting performance. The
+// queue of pending dumps is bounded, see DumpOptions.AsyncOverflow.
 func (c *Client) EnableDumpAllAsync() *Client {
 	o := c.getDumpOptions()
 	o.Async = true
@@ -615,6 +880,25 @@ func (c *Client) EnableDumpAllAsync() *Client {
 	return c
 }
 
+// FlushDump waits until the queued async dumps are written, or ctx is
+// done, which guarantees the delivery of dumps before shutdown (also done
+// by Client.Close).
+func (c *Client) FlushDump(ctx context.Context) error {
+	if c.Dump == nil {
+		return nil
+	}
+	return c.Dump.Flush(ctx)
+}
+
+// DumpDropped returns the number of async dumps dropped by the overflow
+// policy (see DumpOptions.AsyncOverflow).
+func (c *Client) DumpDropped() uint64 {
+	if c.Dump == nil {
+		return 0
+	}
+	return c.Dump.Dropped()
+}
+
 // EnableDumpAllWithoutRequestBody enable dump for requests fired
 // from the client without request body, can be used in the upload
 // request to avoid dumping the unreadable binary content.
@@ -781,6 +1065,15 @@ func (c *Client) DisableAutoReadResponse() *Client {
 	return c
 }
 
+// SetResponseBodySpillThreshold set the size threshold in bytes, response
+// bodies larger than it are auto-read into a temporary file instead of
+// memory, use Response.BodyReader to read it and Response.Close to remove
+// the temporary file early. Zero or negative disables spilling (default).
+func (c *Client) SetResponseBodySpillThreshold(threshold int64) *Client {
+	c.spillThreshold = threshold
+	return c
+}
+
 // EnableAutoReadResponse enable read response body automatically (enabled by default).
 func (c *Client) EnableAutoReadResponse() *Client {
 	c.disableAutoReadResponse = false
@@ -818,6 +1111,29 @@ func (c *Client) EnableAutoDecode() *Client {
 	return c
 }
 
+// DisableAutoRedispatch disable re-dispatching the requests failed on the
+// cached HTTP/2 and HTTP/3 connections (enabled by default), see
+// Transport.EnableAutoRedispatch.
+func (c *Client) DisableAutoRedispatch() *Client {
+	c.Transport.DisableAutoRedispatch()
+	return c
+}
+
+// EnableAutoRedispatch enable re-dispatching the requests failed on the
+// cached HTTP/2 and HTTP/3 connections (enabled by default), see
+// Transport.EnableAutoRedispatch.
+func (c *Client) EnableAutoRedispatch() *Client {
+	c.Transport.EnableAutoRedispatch()
+	return c
+}
+
+// OnRedispatch set the hook which is called before re-dispatching the
+// request, see Transport.OnRedispatch.
+func (c *Client) OnRedispatch(fn func(req *http.Request, err error)) *Client {
+	c.Transport.OnRedispatch(fn)
+	return c
+}
+
 // SetUserAgent set the "User-Agent" header for requests fired from the client.
 func (c *Client) SetUserAgent(userAgent string) *Client {
 	return c.SetCommonHeader(header.UserAgent, userAgent)
@@ -891,6 +1207,63 @@ func (c *Client) SetCommonHeadersNonCanonical(hdrs map[string]string) *Client {
 	return c
 }
 
+// RemoveCommonHeader removes the header for requests fired from the client,
+// including the non-canonical one and the one set by SetCommonHeaderFunc.
+func (c *Client) RemoveCommonHeader(key string) *Client {
+	delete(c.Headers, key)
+	key = http.CanonicalHeaderKey(key)
+	delete(c.Headers, key)
+	for i, hf := range c.headerFuncs {
+		if hf.key == key {
+			c.headerFuncs = append(c.headerFuncs[:i:i], c.headerFuncs[i+1:]...)
+			break
+		}
+	}
+	return c
+}
+
+// RemoveCommonHeaders removes the headers for requests fired from the
+// client.
+func (c *Client) RemoveCommonHeaders(keys ...string) *Client {
+	for _, key := range keys {
+		c.RemoveCommonHeader(key)
+	}
+	return c
+}
+
+// HeaderFunc returns the header value for the request, which is evaluated
+// right before each attempt is sent, the header is not set if empty.
+type HeaderFunc func(req *Request) string
+
+type commonHeaderFunc struct {
+	key string
+	fn  HeaderFunc
+}
+
+// SetCommonHeaderFunc set a header for requests fired from the client whose
+// value is returned by fn at send time (e.g. rotating device IDs, fresh
+// timestamps, sequence counters), fn is called for each attempt including
+// retries and must be safe for concurrent use. It replaces the common
+// header with the same key set by SetCommonHeader, and the header set by
+// the request takes precedence. For example:
+//
+//	var seq atomic.Int64
+//	client.SetCommonHeaderFunc("X-Seq", func(req *req.Request) string {
+//	    return strconv.FormatInt(seq.Add(1), 10)
+//	})
+func (c *Client) SetCommonHeaderFunc(key string, fn HeaderFunc) *Client {
+	key = http.CanonicalHeaderKey(key)
+	c.Headers.Del(key)
+	for i, hf := range c.headerFuncs {
+		if hf.key == key {
+			c.headerFuncs[i].fn = fn
+			return c
+		}
+	}
+	c.headerFuncs = append(c.headerFuncs, commonHeaderFunc{key: key, fn: fn})
+	return c
+}
+
 // SetCommonHeaderOrder set the order of the http header requests fired from the
 // client (case-insensitive).
 // For example:
@@ -901,13 +1274,17 @@ func (c *Client) SetCommonHeadersNonCanonical(hdrs map[string]string) *Client {
 //	    "user-agent",
 //	    "accept-encoding",
 //	).Get(url
+//
+// The order set by Request.SetHeaderOrder takes precedence.
 func (c *Client) SetCommonHeaderOrder(keys ...string) *Client {
 	c.Transport.WrapRoundTripFunc(func(rt http.RoundTripper) HttpRoundTripFunc {
 		return func(req *http.Request) (resp *http.Response, err error) {
 			if req.Header == nil {
 				req.Header = make(http.Header)
 			}
-			req.Header[HeaderOderKey] = keys
+			if len(req.Header[HeaderOderKey]) == 0 {
+				req.Header[HeaderOderKey] = keys
+			}
 			return rt.RoundTrip(req)
 		}
 	})
@@ -925,13 +1302,17 @@ func (c *Client) SetCommonHeaderOrder(keys ...string) *Client {
 //	    ":path",
 //	    ":method",
 //	)
+//
+// The order set by Request.SetPseudoHeaderOrder takes precedence.
 func (c *Client) SetCommonPseudoHeaderOder(keys ...string) *Client {
 	c.Transport.WrapRoundTripFunc(func(rt http.RoundTripper) HttpRoundTripFunc {
 		return func(req *http.Request) (resp *http.Response, err error) {
 			if req.Header == nil {
 				req.Header = make(http.Header)
 			}
-			req.Header[PseudoHeaderOderKey] = keys
+			if len(req.Header[PseudoHeaderOderKey]) == 0 {
+				req.Header[PseudoHeaderOderKey] = keys
+			}
 			return rt.RoundTrip(req)
 		}
 	})
@@ -940,8 +1321,46 @@ func (c *Client) SetCommonPseudoHeaderOder(keys ...string) *Client {
 
 type H2Spec struct {
 	InitialSetting []http2.Setting
-	ConnFlow       uint32   //WINDOW_UPDATE:15663105
-	OrderHeaders   []string //example：[]string{":method",":authority",":scheme",":path"}
+	ConnFlow       uint32                //WINDOW_UPDATE:15663105
+	PriorityFrames []http2.PriorityFrame //example：3:0:0:201,5:0:0:101
+	OrderHeaders   []string              //example：[]string{":method",":authority",":scheme",":path"}
+}
+
+// createH2PriorityFrames parses the priority frames of the akamai
+// fingerprint, which is "0" if there is none, or the comma separated
+// "StreamID:Exclusive:StreamDep:Weight" entries, e.g. "3:0:0:201,5:0:0:101".
+func createH2PriorityFrames(str string) ([]http2.PriorityFrame, error) {
+	if str == "0" {
+		return nil, nil
+	}
+	var frames []http2.PriorityFrame
+	for _, priority := range strings.Split(str, ",") {
+		tts := strings.Split(priority, ":")
+		if len(tts) != 4 {
+			return nil, errors.New("h2 priority frame error: " + priority)
+		}
+		var vals [4]uint64
+		for i, tt := range tts {
+			n, err := strconv.ParseUint(tt, 10, 31)
+			if err != nil {
+				return nil, errors.New("h2 priority frame error: " + priority)
+			}
+			vals[i] = n
+		}
+		streamID, exclusive, streamDep, weight := vals[0], vals[1], vals[2], vals[3]
+		if streamID == 0 || exclusive > 1 || weight < 1 || weight > 256 {
+			return nil, errors.New("h2 priority frame error: " + priority)
+		}
+		frames = append(frames, http2.PriorityFrame{
+			StreamID: uint32(streamID),
+			PriorityParam: http2.PriorityParam{
+				StreamDep: uint32(streamDep),
+				Exclusive: exclusive == 1,
+				Weight:    uint8(weight - 1),
+			},
+		})
+	}
+	return frames, nil
 }
 
 func createH2SpecWithStr(h2ja3SpecStr string) (h2ja3Spec H2Spec, err error) {
@@ -951,17 +1370,22 @@ func createH2SpecWithStr(h2ja3SpecStr string) (h2ja3Spec H2Spec, err error) {
 		return
 	}
 	h2ja3Spec.InitialSetting = []http2.Setting{}
-	for _, setting := range strings.Split(tokens[0], ",") {
+	// the settings are separated by ";" in the akamai fingerprint, "," is
+	// also accepted.
+	settings := strings.FieldsFunc(tokens[0], func(r rune) bool { return r == ';' || r == ',' })
+	for _, setting := range settings {
 		tts := strings.Split(setting, ":")
 		if len(tts) != 2 {
 			err = errors.New("h2 setting error")
 			return
 		}
-		var ttKey, ttVal int
-		if ttKey, err = strconv.Atoi(tts[0]); err != nil {
+		var ttKey, ttVal uint64
+		if ttKey, err = strconv.ParseUint(tts[0], 10, 16); err != nil {
+			err = errors.New("h2 setting error")
 			return
 		}
-		if ttVal, err = strconv.Atoi(tts[1]); err != nil {
+		if ttVal, err = strconv.ParseUint(tts[1], 10, 32); err != nil {
+			err = errors.New("h2 setting error")
 			return
 		}
 		h2ja3Spec.InitialSetting = append(h2ja3Spec.InitialSetting, http2.Setting{
@@ -969,11 +1393,15 @@ func createH2SpecWithStr(h2ja3SpecStr string) (h2ja3Spec H2Spec, err error) {
 			Val: uint32(ttVal),
 		})
 	}
-	var connFlow int
-	if connFlow, err = strconv.Atoi(tokens[1]); err != nil {
+	var connFlow uint64
+	if connFlow, err = strconv.ParseUint(tokens[1], 10, 32); err != nil {
+		err = errors.New("h2 connection flow error")
 		return
 	}
 	h2ja3Spec.ConnFlow = uint32(connFlow)
+	if h2ja3Spec.PriorityFrames, err = createH2PriorityFrames(tokens[2]); err != nil {
+		return
+	}
 	h2ja3Spec.OrderHeaders = []string{}
 	for _, hkey := range strings.Split(tokens[3], ",") {
 		switch hkey {
@@ -985,20 +1413,54 @@ func createH2SpecWithStr(h2ja3SpecStr string) (h2ja3Spec H2Spec, err error) {
 			h2ja3Spec.OrderHeaders = append(h2ja3Spec.OrderHeaders, ":scheme")
 		case "p":
 			h2ja3Spec.OrderHeaders = append(h2ja3Spec.OrderHeaders, ":path")
+		default:
+			err = errors.New("h2 pseudo header order error: " + hkey)
+			return
 		}
 	}
 	return
 }
 
+// ValidateAkamai reports the error if the akamai http2 fingerprint string is
+// malformed, see Client.SetAkamaiWithStr.
+func ValidateAkamai(str string) error {
+	_, err := createH2SpecWithStr(str)
+	return err
+}
+
+// SetAkamaiWithStr set the http2 fingerprint with the akamai fingerprint
+// string, e.g. "1:65536;2:0;4:6291456;6:262144|15663105|0|m,a,s,p", which
+// configures the settings frame, connection flow, priority frames and
+// pseudo header order. The client is left unchanged and the error is logged
+// if the string is malformed, use TrySetAkamaiWithStr to get the error.
 func (c *Client) SetAkamaiWithStr(str string) *Client {
+	if _, err := c.TrySetAkamaiWithStr(str); err != nil {
+		c.log.Errorf("failed to create http2 fingerprint from akamai: %v", err)
+	}
+	return c
+}
+
+// TrySetAkamaiWithStr is like SetAkamaiWithStr, but returns the error if the
+// string is malformed, in which case the client is left unchanged.
+func (c *Client) TrySetAkamaiWithStr(str string) (*Client, error) {
 	h2spec, err := createH2SpecWithStr(str)
 	if err != nil {
-		return c
+		return c, err
 	}
 
 	c.Transport.SetHTTP2SettingsFrame(h2spec.InitialSetting...)
 	c.Transport.SetHTTP2ConnectionFlow(h2spec.ConnFlow)
+	c.Transport.SetHTTP2PriorityFrames(h2spec.PriorityFrames...)
 	c.SetCommonPseudoHeaderOder(h2spec.OrderHeaders...)
+	return c, nil
+}
+
+// MustSetAkamaiWithStr is like SetAkamaiWithStr, but panics if the string is
+// malformed.
+func (c *Client) MustSetAkamaiWithStr(str string) *Client {
+	if _, err := c.TrySetAkamaiWithStr(str); err != nil {
+		panic(err)
+	}
 	return c
 }
 
@@ -1256,6 +1718,18 @@ func (c *Client) SetHTTP2PriorityFrames(frames ...http2.PriorityFrame) *Client {
 	return c
 }
 
+// SetHTTP2HeaderTableSize set the SETTINGS_HEADER_TABLE_SIZE sent in the
+// http2 settings frame (e.g. 65536 like Chrome), which is the max size of
+// the HPACK dynamic table the server can use to encode the response headers,
+// the response headers are decoded with a table of the same size. It
+// overrides the header table size in SetHTTP2SettingsFrame (or SetAkamaiWithStr)
+// and is appended to the settings if not present, but the SETTINGS frame of
+// Request.SetH2Settings is sent as is. Zero means not overridden.
+func (c *Client) SetHTTP2HeaderTableSize(size uint32) *Client {
+	c.Transport.SetHTTP2HeaderTableSize(size)
+	return c
+}
+
 // SetCommonContentType set the `Content-Type` header for requests fired
 // from the client.
 func (c *Client) SetCommonContentType(ct string) *Client {
@@ -1404,6 +1878,51 @@ func (c *Client) SetXmlUnmarshal(fn func(data []byte, v interface{}) error) *Cli
 	return c
 }
 
+// SetResponseDecoder set the unmarshal function which will be used to
+// unmarshal response body with the specified `Content-Type` (e.g.
+// "application/x-protobuf", "application/msgpack", "text/csv") into the
+// result, it takes precedence over the builtin JSON and XML unmarshal
+// functions. A nil fn removes the registered decoder.
+func (c *Client) SetResponseDecoder(contentType string, fn func(data []byte, v interface{}) error) *Client {
+	mediaType := normalizeMediaType(contentType)
+	if fn == nil {
+		delete(c.responseDecoders, mediaType)
+		return c
+	}
+	if c.responseDecoders == nil {
+		c.responseDecoders = make(map[string]func(data []byte, v interface{}) error)
+	}
+	c.responseDecoders[mediaType] = fn
+	return c
+}
+
+// getResponseDecoder returns the unmarshal function for the response, the
+// request-level unmarshal function takes precedence, then the decoder that
+// registered for the response `Content-Type`, and fallback to JSON.
+func (c *Client) getResponseDecoder(r *Response) func(data []byte, v interface{}) error {
+	if r.Request.resultUnmarshal != nil {
+		return r.Request.resultUnmarshal
+	}
+	ct := r.GetContentType()
+	if fn, ok := c.responseDecoders[normalizeMediaType(ct)]; ok {
+		return fn
+	}
+	if util.IsJSONType(ct) {
+		return c.jsonUnmarshal
+	} else if util.IsXMLType(ct) {
+		return c.xmlUnmarshal
+	}
+	if c.DebugLog {
+		c.log.Debugf("cannot determine the unmarshal function with %q Content-Type, default to json", ct)
+	}
+	return c.jsonUnmarshal
+}
+
+func normalizeMediaType(contentType string) string {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	return strings.ToLower(strings.TrimSpace(mediaType))
+}
+
 // SetDialTLS set the customized `DialTLSContext` function to Transport.
 // Make sure the returned `conn` implements pkg/tls.Conn if you want your
 // customized `conn` supports HTTP2.
@@ -1418,6 +1937,134 @@ func (c *Client) SetDial(fn func(ctx context.Context, network, addr string) (net
 	return c
 }
 
+// SetDNSServers set the DNS servers (e.g. "1.1.1.1:53") used to look up
+// host names, which bypasses the system resolver.
+func (c *Client) SetDNSServers(servers ...string) *Client {
+	c.Transport.SetDNSServers(servers...)
+	return c
+}
+
+// SetDNSResolver set the DNS resolver used to look up host names, instead
+// of the system resolver.
+func (c *Client) SetDNSResolver(resolver *net.Resolver) *Client {
+	c.Transport.SetDNSResolver(resolver)
+	return c
+}
+
+// SetDialTimeout set the maximum amount of time that dialing a TCP
+// connection can take, without replacing the default dial function.
+func (c *Client) SetDialTimeout(d time.Duration) *Client {
+	c.Transport.SetDialTimeout(d)
+	return c
+}
+
+// SetTCPKeepAlive set the interval between TCP keep-alive probes, zero
+// means the default (15 seconds), negative disables TCP keep-alive.
+func (c *Client) SetTCPKeepAlive(interval time.Duration) *Client {
+	c.Transport.SetTCPKeepAlive(interval)
+	return c
+}
+
+// SetTCPNoDelay set whether TCP_NODELAY is set on the TCP connections
+// (true by default), false enables Nagle's algorithm.
+func (c *Client) SetTCPNoDelay(noDelay bool) *Client {
+	c.Transport.SetTCPNoDelay(noDelay)
+	return c
+}
+
+// EnableTCPFastOpen enables TCP Fast Open on connect where the platform
+// supports it (Linux 4.11+), otherwise the regular handshake is used.
+func (c *Client) EnableTCPFastOpen() *Client {
+	c.Transport.EnableTCPFastOpen()
+	return c
+}
+
+// DisableTCPFastOpen disables TCP Fast Open (disabled by default).
+func (c *Client) DisableTCPFastOpen() *Client {
+	c.Transport.DisableTCPFastOpen()
+	return c
+}
+
+// EnableMultipathTCP uses Multipath TCP where the platform and the server
+// support it, otherwise it falls back to TCP.
+func (c *Client) EnableMultipathTCP() *Client {
+	c.Transport.EnableMultipathTCP()
+	return c
+}
+
+// DisableMultipathTCP disables Multipath TCP (disabled by default).
+func (c *Client) DisableMultipathTCP() *Client {
+	c.Transport.DisableMultipathTCP()
+	return c
+}
+
+// SetLocalAddr set the local IP address of the connections (including
+// HTTP3), for the hosts with multiple egress IP addresses, e.g.
+//
+//	client.SetLocalAddr("192.0.2.10")
+//
+// Only the remote addresses of the same family are dialed, the empty string
+// clears it. The invalid IP address is logged and ignored.
+func (c *Client) SetLocalAddr(ip string) *Client {
+	if ip == "" {
+		c.Transport.SetLocalAddr(netip.Addr{})
+		return c
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		c.log.Errorf("failed to parse local address %s: %v", ip, err)
+		return c
+	}
+	c.Transport.SetLocalAddr(addr)
+	return c
+}
+
+// SetInterface set the name of the network interface (e.g. "eth1") which
+// the connections (including HTTP3) are bound to, see
+// Transport.SetInterface.
+func (c *Client) SetInterface(name string) *Client {
+	c.Transport.SetInterface(name)
+	return c
+}
+
+// SetHosts set the static host mapping from hostname (or "host:port") to IP
+// address, like `curl --resolve`, the connections are dialed to the IP address
+// while TLS SNI and `Host` header still use the hostname, e.g.
+//
+//	client.SetHosts(map[string]string{"api.example.com": "10.0.0.8"})
+//
+// Entries with invalid IP address are ignored, which are logged if the
+// debug log is enabled (see EnableDebugLog).
+func (c *Client) SetHosts(hosts map[string]string) *Client {
+	c.Transport.SetHosts(hosts)
+	return c
+}
+
+// SetAddrSelector set the function to reorder or filter the resolved IP
+// addresses of host before dialing, the addresses are tried in the returned
+// order, e.g. prefer the addresses of a specific CDN POP:
+//
+//	client.SetAddrSelector(func(host string, addrs []netip.Addr) []netip.Addr {
+//		sort.SliceStable(addrs, func(i, j int) bool {
+//			return pop.Contains(addrs[i]) && !pop.Contains(addrs[j])
+//		})
+//		return addrs
+//	})
+//
+// The address that actually connected can be obtained by Response.RemoteAddr.
+func (c *Client) SetAddrSelector(fn AddrSelectorFunc) *Client {
+	c.Transport.SetAddrSelector(fn)
+	return c
+}
+
+// SetDNSCache set the DNS cache (see NewDNSCache) used to look up host
+// names when dialing, which can be shared by multiple clients, nil
+// disables the DNS cache.
+func (c *Client) SetDNSCache(cache *DNSCache) *Client {
+	c.Transport.SetDNSCache(cache)
+	return c
+}
+
 // SetTLSFingerprintChrome uses tls fingerprint of Chrome browser.
 func (c *Client) SetTLSFingerprintChrome() *Client {
 	return c.SetTLSFingerprint(utls.HelloChrome_Auto)
@@ -1462,7 +2109,7 @@ func createTlsVersion(ver uint16) (tlsMaxVersion uint16, tlsMinVersion uint16, t
 	return
 }
 
-func createCiphers(ciphers []string) ([]uint16, error) {
+func createCiphers(ciphers []string, grease bool) ([]uint16, error) {
 	cipherSuites := []uint16{}
 	for i, val := range ciphers {
 		var cipherSuite uint16
@@ -1471,7 +2118,7 @@ func createCiphers(ciphers []string) ([]uint16, error) {
 		} else {
 			cipherSuite = uint16(n)
 		}
-		if i == 0 {
+		if i == 0 && grease {
 			if cipherSuite != utls.GREASE_PLACEHOLDER {
 				cipherSuites = append(cipherSuites, utls.GREASE_PLACEHOLDER)
 			}
@@ -1481,7 +2128,35 @@ func createCiphers(ciphers []string) ([]uint16, error) {
 	return cipherSuites, nil
 }
 
-func createCurves(curves []string) (curvesExtension utls.TLSExtension, err error) {
+// createKeyShareExtension creates the key_share extension matching the
+// supported curves if they contain a post-quantum curve, the key shares are
+// sent for the post-quantum curve and X25519 like Chrome, otherwise it
+// returns false to use the default key shares.
+func createKeyShareExtension(curvesExtension utls.TLSExtension) (utls.TLSExtension, bool) {
+	curves, ok := curvesExtension.(*utls.SupportedCurvesExtension)
+	if !ok {
+		return nil, false
+	}
+	var pq utls.CurveID
+	var hasX25519 bool
+	for _, curve := range curves.Curves {
+		if pq == 0 && (curve == utls.X25519MLKEM768 || curve == utls.X25519Kyber768Draft00) {
+			pq = curve
+		}
+		hasX25519 = hasX25519 || curve == utls.X25519
+	}
+	if pq == 0 {
+		return nil, false
+	}
+	keyShares := []utls.KeyShare{{Group: utls.CurveID(utls.GREASE_PLACEHOLDER), Data: []byte{0}}}
+	keyShares = append(keyShares, utls.KeyShare{Group: pq})
+	if hasX25519 {
+		keyShares = append(keyShares, utls.KeyShare{Group: utls.X25519})
+	}
+	return &utls.KeyShareExtension{KeyShares: keyShares}, true
+}
+
+func createCurves(curves []string, grease bool) (curvesExtension utls.TLSExtension, err error) {
 	curveIds := []utls.CurveID{}
 	for i, val := range curves {
 		var curveId utls.CurveID
@@ -1490,7 +2165,7 @@ func createCurves(curves []string) (curvesExtension utls.TLSExtension, err error
 		} else {
 			curveId = utls.CurveID(uint16(n))
 		}
-		if i == 0 {
+		if i == 0 && grease {
 			if curveId != utls.GREASE_PLACEHOLDER {
 				curveIds = append(curveIds, utls.GREASE_PLACEHOLDER)
 			}
@@ -1866,9 +2541,13 @@ func createExtension(extensionId uint16, options ...extensionOption) (utls.TLSEx
 	}
 }
 
-func createExtensions(extensions []string, tlsExtension, curvesExtension, pointExtension utls.TLSExtension) ([]utls.TLSExtension, error) {
+// createExtensions creates the extensions of the ja3 string, the GREASE
+// extensions are injected at greasePositions (see
+// Ja3Options.GREASEExtensionPositions) unless there is a GREASE extension
+// next to the position already.
+func createExtensions(extensions []string, tlsExtension, curvesExtension, pointExtension utls.TLSExtension, greasePositions []int) ([]utls.TLSExtension, error) {
 	allExtensions := []utls.TLSExtension{}
-	for i, extension := range extensions {
+	for _, extension := range extensions {
 		var extensionId uint16
 		if n, err := strconv.ParseUint(extension, 10, 16); err != nil {
 			return nil, errors.New("ja3Str extension error,utls not support: " + extension)
@@ -1883,38 +2562,34 @@ func createExtensions(extensions []string, tlsExtension, curvesExtension, pointE
 			ext = pointExtension
 		case 43:
 			ext = tlsExtension
+		case 51:
+			var ok bool
+			if ext, ok = createKeyShareExtension(curvesExtension); !ok {
+				ext, _ = createExtension(extensionId)
+			}
 		default:
 			ext, _ = createExtension(extensionId)
 			if ext == nil {
 				ext = &utls.GenericExtension{Id: extensionId}
 			}
 		}
-		if i == 0 {
-			if _, ok := ext.(*utls.UtlsGREASEExtension); !ok {
-				allExtensions = append(allExtensions, &utls.UtlsGREASEExtension{})
-			}
-		}
 		allExtensions = append(allExtensions, ext)
 	}
-	if l := len(allExtensions); l > 0 {
-		if _, ok := allExtensions[l-1].(*utls.UtlsGREASEExtension); !ok {
-			allExtensions = append(allExtensions, &utls.UtlsGREASEExtension{})
-		}
-	}
-	return allExtensions, nil
+	return injectGREASEExtensions(allExtensions, greasePositions), nil
 }
 
-// ja3 字符串中生成 clientHello
-func (c *Client) SetJa3WithStr(ja3Str string) (this *Client) {
-	this = c
-	clientHelloSpec := utls.ClientHelloSpec{}
+// createSpecWithJa3Str creates the ClientHelloSpec from the ja3 string, opts
+// can be nil to use the default options.
+func createSpecWithJa3Str(ja3Str string, opts *Ja3Options) (clientHelloSpec utls.ClientHelloSpec, err error) {
 	tokens := strings.Split(ja3Str, ",")
 	if len(tokens) != 5 {
-		return this
+		err = errors.New("ja3Str format error")
+		return
 	}
 	ver, err := strconv.ParseUint(tokens[0], 10, 16)
 	if err != nil {
-		return this
+		err = errors.New("ja3Str tls version error")
+		return
 	}
 	ciphers := strings.Split(tokens[1], "-")
 	extensions := strings.Split(tokens[2], "-")
@@ -1922,29 +2597,123 @@ func (c *Client) SetJa3WithStr(ja3Str string) (this *Client) {
 	pointFormats := strings.Split(tokens[4], "-")
 	tlsMaxVersion, tlsMinVersion, tlsExtension, err := createTlsVersion(uint16(ver))
 	if err != nil {
-		return this
+		return
 	}
 	clientHelloSpec.TLSVersMax = tlsMaxVersion
 	clientHelloSpec.TLSVersMin = tlsMinVersion
-	if clientHelloSpec.CipherSuites, err = createCiphers(ciphers); err != nil {
+	grease := opts == nil || !opts.DisableGREASE
+	if clientHelloSpec.CipherSuites, err = createCiphers(ciphers, grease); err != nil {
 		return
 	}
-	curvesExtension, err := createCurves(curves)
+	curvesExtension, err := createCurves(curves, grease)
 	if err != nil {
-		return this
+		return
 	}
 	pointExtension, err := createPointFormats(pointFormats)
 	if err != nil {
-		return this
+		return
 	}
 	clientHelloSpec.CompressionMethods = []byte{0}
 	clientHelloSpec.GetSessionID = sha256.Sum256
-	clientHelloSpec.Extensions, err = createExtensions(extensions, tlsExtension, curvesExtension, pointExtension)
-	if err == nil {
-		c.SetTLSFingerprintRaw(clientHelloSpec)
+	clientHelloSpec.Extensions, err = createExtensions(extensions, tlsExtension, curvesExtension, pointExtension, opts.greaseExtensionPositions())
+	return
+}
+
+// ValidateJa3 reports the error if the ja3 string is malformed, see
+// Client.SetJa3WithStr.
+func ValidateJa3(ja3Str string) error {
+	_, err := createSpecWithJa3Str(ja3Str, nil)
+	return err
+}
+
+// SetJa3WithStr set the tls fingerprint with the ja3 string, the client is
+// left unchanged and the error is logged if the ja3 string is malformed, use
+// TrySetJa3WithStr to get the error.
+func (c *Client) SetJa3WithStr(ja3Str string) *Client {
+	if _, err := c.TrySetJa3WithStr(ja3Str); err != nil {
+		c.log.Errorf("failed to create tls fingerprint from ja3: %v", err)
 	}
+	return c
+}
 
-	return this
+// TrySetJa3WithStr is like SetJa3WithStr, but returns the error if the ja3
+// string is malformed, in which case the client is left unchanged.
+func (c *Client) TrySetJa3WithStr(ja3Str string) (*Client, error) {
+	spec, err := createSpecWithJa3Str(ja3Str, c.ja3Options)
+	if err != nil {
+		return c, err
+	}
+	return c.setTLSFingerprintSpec(spec, c.ja3Options != nil && c.ja3Options.ShuffleExtensions), nil
+}
+
+// MustSetJa3WithStr is like SetJa3WithStr, but panics if the ja3 string is
+// malformed.
+func (c *Client) MustSetJa3WithStr(ja3Str string) *Client {
+	if _, err := c.TrySetJa3WithStr(ja3Str); err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// createSpecWithClientHello creates the ClientHelloSpec from the captured
+// ClientHello, which is either the tls record or the handshake message.
+func createSpecWithClientHello(raw []byte) (*utls.ClientHelloSpec, error) {
+	if len(raw) > 0 && raw[0] == 1 {
+		// the handshake message (e.g. dumped by EnableClientHelloDump),
+		// wrap it into a tls record.
+		raw = append([]byte{22, 3, 1, byte(len(raw) >> 8), byte(len(raw))}, raw...)
+	}
+	f := &utls.Fingerprinter{AllowBluntMimicry: true}
+	spec, err := f.FingerprintClientHello(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid client hello: %w", err)
+	}
+	return spec, nil
+}
+
+// SetTLSFingerprintFromBytes set the tls fingerprint by replaying the
+// captured ClientHello (e.g. exported from a pcap by Wireshark, or dumped by
+// EnableClientHelloDump), which is either the tls record or the handshake
+// message. The cipher suites, extensions and their order, curves and ALPN
+// are cloned, while the random, session id, key shares and SNI are
+// generated for each connection, the unknown extensions are sent as-is.
+// The client is left unchanged and the error is logged if the ClientHello is
+// malformed, use TrySetTLSFingerprintFromBytes to get the error.
+func (c *Client) SetTLSFingerprintFromBytes(raw []byte) *Client {
+	if _, err := c.TrySetTLSFingerprintFromBytes(raw); err != nil {
+		c.log.Errorf("failed to create tls fingerprint from bytes: %v", err)
+	}
+	return c
+}
+
+// TrySetTLSFingerprintFromBytes is like SetTLSFingerprintFromBytes, but
+// returns the error if the ClientHello is malformed, in which case the
+// client is left unchanged.
+func (c *Client) TrySetTLSFingerprintFromBytes(raw []byte) (*Client, error) {
+	spec, err := createSpecWithClientHello(raw)
+	if err != nil {
+		return c, err
+	}
+	return c.SetTLSFingerprintRaw(*spec), nil
+}
+
+// EnableTLSExtensionShuffle shuffles the order of the ClientHello extensions
+// for each connection like Chrome's extension permutation, so that the
+// repeated connections don't present the byte-identical ClientHello, the
+// GREASE, padding and pre_shared_key extensions are kept in place. It
+// applies to the custom ClientHelloSpec (e.g. SetJa3WithStr,
+// SetTLSFingerprintRaw and SetTLSFingerprintFromBytes), the Chrome presets
+// of SetTLSFingerprint shuffle the extensions already.
+func (c *Client) EnableTLSExtensionShuffle() *Client {
+	c.tlsExtensionShuffle = true
+	return c
+}
+
+// DisableTLSExtensionShuffle disables the extension shuffle (disabled by
+// default), see EnableTLSExtensionShuffle.
+func (c *Client) DisableTLSExtensionShuffle() *Client {
+	c.tlsExtensionShuffle = false
+	return c
 }
 
 // SetTLSFingerprintFirefox uses tls fingerprint of Firefox browser.
@@ -2007,10 +2776,18 @@ func (conn *uTLSConn) ConnectionState() tls.ConnectionState {
 		SignedCertificateTimestamps: cs.SignedCertificateTimestamps,
 		OCSPResponse:                cs.OCSPResponse,
 		TLSUnique:                   cs.TLSUnique,
+		ECHAccepted:                 cs.ECHAccepted,
 	}
 }
 
 func (c *Client) SetTLSFingerprintRaw(spec utls.ClientHelloSpec) *Client {
+	return c.setTLSFingerprintSpec(spec, false)
+}
+
+// setTLSFingerprintSpec set the tls fingerprint with the ClientHelloSpec,
+// the extensions are shuffled for each connection if shuffle is true or
+// EnableTLSExtensionShuffle is called.
+func (c *Client) setTLSFingerprintSpec(spec utls.ClientHelloSpec, shuffle bool) *Client {
 	fn := func(ctx context.Context, addr string, plainConn net.Conn) (conn net.Conn, tlsState *tls.ConnectionState, err error) {
 		colonPos := strings.LastIndex(addr, ":")
 		if colonPos == -1 {
@@ -2035,13 +2812,21 @@ func (c *Client) SetTLSFingerprintRaw(spec utls.ClientHelloSpec) *Client {
 			PreferSkipResumptionOnNilExtension: true,
 		}
 
+		s := spec
+		if shuffle || c.tlsExtensionShuffle {
+			s.Extensions = utls.ShuffleChromeTLSExtensions(slices.Clone(spec.Extensions))
+		}
+		c.Transport.applyUTLSECH(ctx, utlsConfig, hostname, s.Extensions)
 		uconn := &uTLSConn{utls.UClient(plainConn, utlsConfig, utls.HelloCustom)}
-		err = uconn.ApplyPreset(&spec)
+		err = uconn.ApplyPreset(&s)
 		if err != nil {
 			return
 		}
 		err = uconn.HandshakeContext(ctx)
 		if err != nil {
+			if utlsConfig.EncryptedClientHelloConfigList != nil {
+				c.Transport.handleECHRejection(hostname, err)
+			}
 			return
 		}
 		cs := uconn.Conn.ConnectionState()
@@ -2059,6 +2844,7 @@ func (c *Client) SetTLSFingerprintRaw(spec utls.ClientHelloSpec) *Client {
 			SignedCertificateTimestamps: cs.SignedCertificateTimestamps,
 			OCSPResponse:                cs.OCSPResponse,
 			TLSUnique:                   cs.TLSUnique,
+			ECHAccepted:                 cs.ECHAccepted,
 		}
 		return
 	}
@@ -2094,9 +2880,17 @@ func (c *Client) SetTLSFingerprint(clientHelloID utls.ClientHelloID) *Client {
 			KeyLogWriter:                tlsConfig.KeyLogWriter,
 		}
 
+		if c.ech != nil {
+			if spec, err := utls.UTLSIdToSpec(clientHelloID); err == nil {
+				c.Transport.applyUTLSECH(ctx, utlsConfig, hostname, spec.Extensions)
+			}
+		}
 		uconn := &uTLSConn{utls.UClient(plainConn, utlsConfig, clientHelloID)}
 		err = uconn.HandshakeContext(ctx)
 		if err != nil {
+			if utlsConfig.EncryptedClientHelloConfigList != nil {
+				c.Transport.handleECHRejection(hostname, err)
+			}
 			return
 		}
 		cs := uconn.Conn.ConnectionState()
@@ -2114,6 +2908,7 @@ func (c *Client) SetTLSFingerprint(clientHelloID utls.ClientHelloID) *Client {
 			SignedCertificateTimestamps: cs.SignedCertificateTimestamps,
 			OCSPResponse:                cs.OCSPResponse,
 			TLSUnique:                   cs.TLSUnique,
+			ECHAccepted:                 cs.ECHAccepted,
 		}
 		return
 	}
@@ -2135,6 +2930,96 @@ func (c *Client) SetTLSHandshakeTimeout(timeout time.Duration) *Client {
 	return c
 }
 
+// SetMaxIdleConns set the maximum number of idle (keep-alive) connections
+// across all hosts, see Transport.SetMaxIdleConns.
+func (c *Client) SetMaxIdleConns(max int) *Client {
+	c.Transport.SetMaxIdleConns(max)
+	return c
+}
+
+// SetMaxIdleConnsPerHost set the maximum idle (keep-alive) connections to
+// keep per-host, see Transport.SetMaxIdleConnsPerHost.
+func (c *Client) SetMaxIdleConnsPerHost(max int) *Client {
+	c.Transport.SetMaxIdleConnsPerHost(max)
+	return c
+}
+
+// SetMaxConnsPerHost set the maximum number of connections per host,
+// see Transport.SetMaxConnsPerHost.
+func (c *Client) SetMaxConnsPerHost(max int) *Client {
+	c.Transport.SetMaxConnsPerHost(max)
+	return c
+}
+
+// SetResponseHeaderTimeout set the amount of time to wait for a server's
+// response headers after fully writing the request, for HTTP/1.1, HTTP/2 and
+// HTTP/3, see Transport.SetResponseHeaderTimeout.
+func (c *Client) SetResponseHeaderTimeout(timeout time.Duration) *Client {
+	c.Transport.SetResponseHeaderTimeout(timeout)
+	return c
+}
+
+// SetExpectContinueTimeout set the amount of time to wait for a server's
+// "100 Continue" before sending the body of the request with an
+// "Expect: 100-continue" header, for HTTP/1.1, HTTP/2 and HTTP/3, see
+// Transport.SetExpectContinueTimeout.
+func (c *Client) SetExpectContinueTimeout(timeout time.Duration) *Client {
+	c.Transport.SetExpectContinueTimeout(timeout)
+	return c
+}
+
+// SetIdleConnTimeout set the maximum amount of time an idle connection
+// will remain idle before closing itself, see Transport.SetIdleConnTimeout.
+func (c *Client) SetIdleConnTimeout(timeout time.Duration) *Client {
+	c.Transport.SetIdleConnTimeout(timeout)
+	return c
+}
+
+// SetMaxIdleConnTimeout set the maximum amount of time an idle connection
+// will remain idle before closing itself, it's an alias of
+// SetIdleConnTimeout. Together with SetMaxConnsPerHost and
+// SetMaxIdleConnsPerHost, it applies to the HTTP/1, HTTP/2 and HTTP/3
+// pools, and the pools can be inspected by PoolStats, e.g.
+//
+//	client.SetMaxConnsPerHost(4).SetMaxIdleConnsPerHost(2).SetMaxIdleConnTimeout(30 * time.Second)
+//	for host, stats := range client.PoolStats() {
+//		fmt.Println(host, stats.Idle, stats.InFlight, stats.ReuseRatio)
+//	}
+func (c *Client) SetMaxIdleConnTimeout(timeout time.Duration) *Client {
+	c.Transport.SetMaxIdleConnTimeout(timeout)
+	return c
+}
+
+// SetMaxConnAge set the maximum amount of time a connection may be reused
+// since it's established, see Transport.SetMaxConnAge.
+func (c *Client) SetMaxConnAge(age time.Duration) *Client {
+	c.Transport.SetMaxConnAge(age)
+	return c
+}
+
+// RegisterProtocol registers a new protocol with scheme, the requests using
+// the given scheme are passed to rt, e.g. the "ftp" and "sftp" protocols
+// provided by the ftp package.
+func (c *Client) RegisterProtocol(scheme string, rt http.RoundTripper) *Client {
+	c.Transport.RegisterProtocol(scheme, rt)
+	return c
+}
+
+// EnableFileScheme enables the "file:" scheme URLs, the files are served
+// from the root directory (disabled by default). The paths outside root are
+// forbidden, use "/" to allow any file. The "data:" scheme URLs are always
+// supported.
+func (c *Client) EnableFileScheme(root string) *Client {
+	c.Transport.EnableFileScheme(root)
+	return c
+}
+
+// DisableFileScheme disables the "file:" scheme URLs.
+func (c *Client) DisableFileScheme() *Client {
+	c.Transport.DisableFileScheme()
+	return c
+}
+
 // EnableForceHTTP1 enable force using HTTP1 (disabled by default).
 //
 // Attention: This method should not be called when ImpersonateXXX, SetTLSFingerPrint or
@@ -2359,8 +3244,8 @@ func (c *Client) SetHTTP2WriteByteTimeout(timeout time.Duration) *Client {
 }
 
 // NewClient is the alias of C
-func NewClient() *Client {
-	return C()
+func NewClient(opts ...Option) *Client {
+	return C(opts...)
 }
 
 // Clone copy and returns the Client
@@ -2392,8 +3277,26 @@ func (c *Client) Clone() *Client {
 	cc.beforeRequest = cloneSlice(c.beforeRequest)
 	cc.udBeforeRequest = cloneSlice(c.udBeforeRequest)
 	cc.afterResponse = cloneSlice(c.afterResponse)
+	cc.observers = cloneSlice(c.observers)
+	cc.headerFuncs = cloneSlice(c.headerFuncs)
+	cc.hostConfigs = cloneHostConfigs(&cc, c.hostConfigs)
 	cc.dumpOptions = c.dumpOptions.Clone()
 	cc.retryOption = c.retryOption.Clone()
+	cc.responseDecoders = cloneMap(c.responseDecoders)
+	cc.statusResultTypes = cloneMap(c.statusResultTypes)
+	cc.contentTypeResultTypes = cloneMap(c.contentTypeResultTypes)
+	cc.inflight = newInflightRegistry()
+	cc.closed = 0
+	cc.warmPool = nil
+	if c.schedule != nil {
+		cc.schedule = newSchedulePolicy(c.schedule.Schedule)
+	}
+	if c.scheduler != nil {
+		cc.scheduler = newScheduler(c.scheduler.maxConcurrent)
+	}
+	if c.stats != nil {
+		cc.stats = newStatsCollector()
+	}
 	return &cc
 }
 
@@ -2402,8 +3305,8 @@ func memoryCookieJarFactory() *cookiejar.Jar {
 	return jar
 }
 
-// C create a new client.
-func C() *Client {
+// C create a new client, which is configured with the options if any.
+func C(opts ...Option) *Client {
 	t := T()
 
 	httpClient := &http.Client{
@@ -2411,10 +3314,14 @@ func C() *Client {
 		Timeout:   2 * time.Minute,
 	}
 	beforeRequest := []RequestMiddleware{
+		parseRequestURL,
+		parseRequestContextOptions,
+		parseRequestHostConfig,
 		parseRequestHeader,
+		parseRequestID,
 		parseRequestCookie,
-		parseRequestURL,
 		parseRequestBody,
+		parseRequestResume,
 	}
 	afterResponse := []ResponseMiddleware{
 		parseResponseBody,
@@ -2427,16 +3334,23 @@ func C() *Client {
 		log:                   createDefaultLogger(),
 		httpClient:            httpClient,
 		Transport:             t,
-		jsonMarshal:           json.Marshal,
-		jsonUnmarshal:         json.Unmarshal,
+		jsonMarshal:           DefaultJSONEngine.Marshal,
+		jsonUnmarshal:         DefaultJSONEngine.Unmarshal,
 		xmlMarshal:            xml.Marshal,
 		xmlUnmarshal:          xml.Unmarshal,
 		cookiejarFactory:      memoryCookieJarFactory,
+		inflight:              newInflightRegistry(),
 	}
 	c.SetRedirectPolicy(DefaultRedirectPolicy())
 	c.initCookieJar()
 
 	c.initTransport()
+	for _, opt := range globalOptions() {
+		opt(c)
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
 	return c
 }
 
@@ -2529,9 +3443,117 @@ func (c *Client) WrapRoundTrip(wrappers ...RoundTripWrapper) *Client {
 	return c
 }
 
-// RoundTrip implements RoundTripper
+// attemptContext returns the context of the request attempt, which sets up
+// the trace, the connection hooks and the response body handling of resp.
+func (c *Client) attemptContext(r *Request, resp *Response) context.Context {
+	ctx := r.ctx
+	if r.trace != nil {
+		ctx = r.trace.createContext(r.Context())
+	}
+	ctx = c.connHooks.withConnHooks(ctx)
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	sni := r.sni
+	if sni == "" {
+		sni = c.sni
+	}
+	if sni != "" {
+		ctx = netutil.WithServerName(ctx, sni)
+	}
+	if len(r.h2Settings) > 0 {
+		ctx = h2internal.WithSettings(ctx, r.h2Settings)
+	}
+	if len(r.neverIndexedHeaders) > 0 {
+		ctx = h2internal.WithNeverIndexedHeaders(ctx, r.neverIndexedHeaders)
+	}
+	resp.h2Fingerprint = new(h2internal.FingerprintRecorder)
+	ctx = h2internal.WithFingerprintRecorder(ctx, resp.h2Fingerprint)
+	if c.proxyPool != nil {
+		ctx = context.WithValue(ctx, proxyPoolAttemptKey{}, &proxyPoolAttempt{})
+	}
+	ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn: func(ci httptrace.GotConnInfo) {
+			if ci.Conn != nil {
+				resp.remoteAddr = ci.Conn.RemoteAddr()
+				resp.serverHello = tlsutil.ServerHelloOf(ci.Conn)
+			}
+		},
+	})
+	ctx = context.WithValue(ctx, redirectResponseKey, resp)
+
+	if fn := r.onInformationalResponse; fn != nil {
+		ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+			Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+				fn(code, http.Header(header))
+				return nil
+			},
+		})
+	}
+
+	var wrap wrapResponseBodyFunc
+	if r.isSaveResponse && r.downloadCallback != nil {
+		wrap = func(rc io.ReadCloser) io.ReadCloser {
+			return &callbackReader{
+				ReadCloser: rc,
+				callback: func(read int64) {
+					r.downloadCallback(DownloadInfo{
+						Response:       resp,
+						DownloadedSize: read,
+					})
+				},
+				lastTime: time.Now(),
+				interval: r.downloadCallbackInterval,
+			}
+		}
+	}
+	if r.downloadRateLimit > 0 {
+		limiter := newRateLimiter(r.downloadRateLimit)
+		callbackWrap := wrap
+		limitCtx := ctx
+		wrap = func(rc io.ReadCloser) io.ReadCloser {
+			if callbackWrap != nil {
+				rc = callbackWrap(rc)
+			}
+			return &rateLimitReader{ReadCloser: rc, limiter: limiter, ctx: limitCtx}
+		}
+	}
+	if wrap != nil {
+		ctx = context.WithValue(ctx, wrapResponseBodyKey, wrap)
+	}
+	if r.responseCharset != "" {
+		ctx = context.WithValue(ctx, responseCharsetKey, r.responseCharset)
+	}
+	if r.stream {
+		ctx = context.WithValue(ctx, rawResponseBodyKey, true)
+	}
+	return ctx
+}
+
+// mayMutateRequestHeader reports whether the header of the http.Request
+// may be mutated by the cookie jar or the round trip wrappers (see
+// Transport.WrapRoundTripFunc), in which case it can't share the
+// Request.Headers.
+func (c *Client) mayMutateRequestHeader(r *Request) bool {
+	if t, ok := c.httpClient.Transport.(*Transport); !ok || t.wrappedRoundTrip != nil {
+		return true
+	}
+	jar := c.httpClient.Jar
+	return jar != nil && len(jar.Cookies(r.URL)) > 0
+}
+
 func (c *Client) roundTrip(r *Request) (resp *Response, err error) {
-	resp = &Response{Request: r}
+	// reuse the Response of the last attempt if it's a retry, see
+	// Request.do. The context is always rebuilt as the request may be
+	// changed by the retry hook.
+	last := r.lastAttempt
+	r.lastAttempt = attemptState{}
+	if last.reusable {
+		resp = last.resp
+		*resp = Response{Request: r}
+	} else {
+		resp = &Response{Request: r}
+	}
 	defer func() {
 		if err != nil {
 			resp.Err = err
@@ -2545,23 +3567,18 @@ func (c *Client) roundTrip(r *Request) (resp *Response, err error) {
 		r.trace = &clientTrace{}
 	}
 
-	ctx := r.ctx
-
-	if r.trace != nil {
-		ctx = r.trace.createContext(r.Context())
-	}
+	ctx := c.attemptContext(r, resp)
 
 	// setup url and host
 	var host string
-	if h := r.getHeader("Host"); h != "" {
+	if r.hostHeader != "" {
+		host = r.hostHeader
+	} else if h := r.getHeader("Host"); h != "" {
 		host = h // Host header override
 	} else {
 		host = r.URL.Host
 	}
 
-	// setup header
-	contentLength := int64(len(r.Body))
-
 	var reqBody io.ReadCloser
 	if r.GetBody != nil {
 		reqBody, resp.Err = r.GetBody()
@@ -2569,56 +3586,130 @@ func (c *Client) roundTrip(r *Request) (resp *Response, err error) {
 			return
 		}
 	}
-	req := &http.Request{
-		Method:        r.Method,
-		Header:        r.Headers.Clone(),
-		URL:           r.URL,
-		Host:          host,
-		Proto:         "HTTP/1.1",
-		ProtoMajor:    1,
-		ProtoMinor:    1,
-		ContentLength: contentLength,
-		Body:          reqBody,
-		GetBody:       r.GetBody,
-		Close:         r.close,
+
+	// setup header, which is shared with r.Headers unless it may be mutated.
+	header := r.Headers
+	cloned := false
+	if len(r.Cookies) > 0 || c.mayMutateRequestHeader(r) {
+		header, cloned = header.Clone(), true
 	}
-	for _, cookie := range r.Cookies {
-		req.AddCookie(cookie)
+	if r.http10KeepAlive {
+		if !cloned {
+			header, cloned = header.Clone(), true
+		}
+		if header == nil {
+			header = make(http.Header)
+		}
+		header.Set("Connection", "keep-alive")
 	}
-	if r.isSaveResponse && r.downloadCallback != nil {
-		var wrap wrapResponseBodyFunc = func(rc io.ReadCloser) io.ReadCloser {
-			return &callbackReader{
-				ReadCloser: rc,
-				callback: func(read int64) {
-					r.downloadCallback(DownloadInfo{
-						Response:       resp,
-						DownloadedSize: read,
-					})
-				},
-				lastTime: time.Now(),
-				interval: r.downloadCallbackInterval,
-			}
+	for _, hf := range c.headerFuncs {
+		if len(r.Headers[hf.key]) > 0 {
+			continue
 		}
-		if ctx == nil {
-			ctx = context.Background()
+		if v := hf.fn(r); v != "" {
+			if !cloned {
+				header, cloned = header.Clone(), true
+			}
+			if header == nil {
+				header = make(http.Header)
+			}
+			header.Set(hf.key, v)
 		}
-		ctx = context.WithValue(ctx, wrapResponseBodyKey, wrap)
 	}
-	if ctx != nil {
-		req = req.WithContext(ctx)
+
+	contentLength := int64(len(r.Body))
+	var transferEncoding []string
+	proto, protoMinor := "HTTP/1.1", 1
+	if r.http10 {
+		proto, protoMinor = "HTTP/1.0", 0
+		if r.contentLength != nil {
+			contentLength = *r.contentLength
+		} else if reqBody != nil && contentLength == 0 {
+			// HTTP/1.0 has no chunked transfer encoding, buffer the body
+			// of unknown size to send the Content-Length.
+			var body []byte
+			body, resp.Err = io.ReadAll(reqBody)
+			reqBody.Close()
+			if resp.Err != nil {
+				return
+			}
+			reqBody, contentLength = io.NopCloser(bytes.NewReader(body)), int64(len(body))
+		}
+	} else if r.chunked {
+		contentLength, transferEncoding = -1, []string{"chunked"}
+	} else if r.contentLength != nil {
+		contentLength = *r.contentLength
+	}
+
+	req := (&http.Request{
+		Method:           r.Method,
+		Header:           header,
+		URL:              r.URL,
+		Host:             host,
+		Proto:            proto,
+		ProtoMajor:       1,
+		ProtoMinor:       protoMinor,
+		ContentLength:    contentLength,
+		TransferEncoding: transferEncoding,
+		Body:             reqBody,
+		GetBody:          r.GetBody,
+		Close:            r.close || r.http10 && !r.http10KeepAlive,
+	}).WithContext(ctx)
+	for _, cookie := range r.Cookies {
+		req.AddCookie(cookie)
 	}
+	r.lastAttempt = attemptState{resp: resp}
 	r.RawRequest = req
 	r.StartTime = time.Now()
+	if r.trace != nil {
+		r.trace.attempt = r.RetryAttempt
+		r.trace.startTime = r.StartTime
+		r.trace.requestID = r.requestID
+	}
+	if c.DebugLog && r.requestID != "" {
+		c.log.Debugf("<request> [%s] %s %s", r.requestID, r.Method, r.URL.String())
+	}
 
+	httpClient := c.httpClient
+	if r.hostConfig != nil && r.hostConfig.timeout > 0 {
+		hc := *httpClient
+		hc.Timeout = r.hostConfig.timeout
+		httpClient = &hc
+	}
 	var httpResponse *http.Response
-	httpResponse, resp.Err = c.httpClient.Do(r.RawRequest)
+	httpResponse, resp.Err = httpClient.Do(r.RawRequest)
 	resp.Response = httpResponse
+	c.reportProxy(ctx, httpResponse, resp.Err)
+	if r.trace != nil && httpResponse != nil {
+		r.trace.gotHeader = time.Now()
+		r.trace.proto = httpResponse.Proto
+	}
+	if resp.Err == nil {
+		resp.Err = resp.transformBodyStream()
+	}
 
 	// auto-read response body if possible
-	if resp.Err == nil && !c.disableAutoReadResponse && !r.isSaveResponse && !r.disableAutoReadResponse && resp.StatusCode > 199 {
-		resp.ToBytes()
+	if resp.Err == nil && !c.disableAutoReadResponse && !r.isSaveResponse && !r.stream && !r.disableAutoReadResponse && resp.StatusCode > 199 {
+		threshold := c.spillThreshold
+		if r.spillThreshold != 0 {
+			threshold = r.spillThreshold
+		}
+		if threshold > 0 {
+			resp.spillBody(threshold)
+		} else {
+			resp.ToBytes()
+		}
 		// restore body for re-reads
-		resp.Body = io.NopCloser(bytes.NewReader(resp.body))
+		if resp.bodyFile != nil {
+			resp.Body = io.NopCloser(io.NewSectionReader(resp.bodyFile, 0, resp.bodyFile.size))
+		} else {
+			resp.Body = io.NopCloser(bytes.NewReader(resp.body))
+		}
+	}
+
+	dumpFormatted(resp, c.Dump)
+	if c.stats != nil {
+		c.stats.record(resp)
 	}
 
 	for _, f := range c.afterResponse {
@@ -2629,6 +3720,7 @@ func (c *Client) roundTrip(r *Request) (resp *Response, err error) {
 	return
 }
 
+// RoundTrip implements RoundTripper
 func (c *Client) RoundTrip(r *Request) (resp *Response, err error) {
 	return c.roundTrip(r)
 }
@@ -0,0 +1,92 @@
+package restys
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/luoxk/restys/internal/tlsutil"
+)
+
+// joinUint16s joins vals with "-" like the ja3 string, the GREASE values
+// are skipped if skipGREASE is true.
+func joinUint16s(vals []uint16, skipGREASE bool) string {
+	strs := make([]string, 0, len(vals))
+	for _, v := range vals {
+		if skipGREASE && isGREASE(v) {
+			continue
+		}
+		strs = append(strs, strconv.Itoa(int(v)))
+	}
+	return strings.Join(strs, "-")
+}
+
+// dumpClientHello formats the ClientHello message sent to host, which
+// contains the decoded summary and the hex dump of the raw bytes.
+func dumpClientHello(host string, msg []byte) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "ClientHello to %s (%d bytes)\n", host, len(msg))
+	hello, err := tlsutil.ParseClientHello(msg)
+	if err != nil {
+		fmt.Fprintf(&buf, "  error:      %v\n", err)
+	} else {
+		curves := make([]string, len(hello.Curves))
+		for i, curve := range hello.Curves {
+			if isGREASE(curve) {
+				curves[i] = fmt.Sprintf("%d (GREASE)", curve)
+			} else {
+				curves[i] = fmt.Sprintf("%d (%v)", curve, tls.CurveID(curve))
+			}
+		}
+		points := make([]uint16, len(hello.PointFormats))
+		for i, point := range hello.PointFormats {
+			points[i] = uint16(point)
+		}
+		fmt.Fprintf(&buf, "  version:    %d\n", hello.Version)
+		fmt.Fprintf(&buf, "  ciphers:    %s\n", joinUint16s(hello.CipherSuites, false))
+		fmt.Fprintf(&buf, "  extensions: %s\n", joinUint16s(hello.Extensions, false))
+		fmt.Fprintf(&buf, "  curves:     %s\n", strings.Join(curves, ", "))
+		fmt.Fprintf(&buf, "  points:     %s\n", joinUint16s(points, false))
+		fmt.Fprintf(&buf, "  ja3:        %d,%s,%s,%s,%s\n", hello.Version,
+			joinUint16s(hello.CipherSuites, true), joinUint16s(hello.Extensions, true),
+			joinUint16s(hello.Curves, true), joinUint16s(points, false))
+	}
+	buf.WriteString(hex.Dump(msg))
+	buf.WriteByte('\n')
+	return buf.Bytes()
+}
+
+// EnableClientHelloDump writes the exact ClientHello sent by each tls
+// handshake to w, including the decoded summary (version, ciphers, the order
+// of extensions, curves and the ja3 string, the GREASE values are kept
+// except in ja3) and the hex dump of the raw message, so what the client
+// sends (e.g. with SetTLSFingerprint or SetJa3WithStr) can be diffed
+// against a browser capture without Wireshark. It's safe for concurrent
+// connections, not valid for HTTP3.
+func (c *Client) EnableClientHelloDump(w io.Writer) *Client {
+	if w == nil {
+		return c.DisableClientHelloDump()
+	}
+	var mu sync.Mutex
+	c.Transport.OnClientHello = func(host string, msg []byte) {
+		dump := dumpClientHello(host, msg)
+		mu.Lock()
+		defer mu.Unlock()
+		if _, err := w.Write(dump); err != nil {
+			c.log.Errorf("failed to dump client hello: %v", err)
+		}
+	}
+	return c
+}
+
+// DisableClientHelloDump disables the ClientHello dump (disabled by
+// default), see EnableClientHelloDump.
+func (c *Client) DisableClientHelloDump() *Client {
+	c.Transport.OnClientHello = nil
+	return c
+}
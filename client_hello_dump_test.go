@@ -0,0 +1,35 @@
+package restys
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/luoxk/restys/internal/tests"
+)
+
+func TestEnableClientHelloDump(t *testing.T) {
+	// no SNI (0) is sent to the ip address.
+	ja3 := "771,4865-4866-4867-49195-49199,23-65281-10-11-35-16-5-13-18-51-45-43,29-23-24,0"
+	var buf bytes.Buffer
+	c := tc().EnableClientHelloDump(&buf).SetJa3WithStr(ja3)
+	resp, err := c.R().Get("/")
+	assertSuccess(t, resp, err)
+
+	dump := buf.String()
+	tests.AssertEqual(t, true, strings.HasPrefix(dump, "ClientHello to 127.0.0.1 ("))
+	tests.AssertEqual(t, true, strings.Contains(dump, "  ja3:        "+ja3+"\n"))
+	tests.AssertEqual(t, true, strings.Contains(dump, " (GREASE), 29 (X25519), 23 (CurveP256), 24 (CurveP384)\n"))
+	tests.AssertEqual(t, true, strings.Contains(dump, "00000000  01 00 "))
+
+	buf.Reset()
+	resp, err = tc().EnableClientHelloDump(&buf).EnableForceHTTP1().R().Get("/")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, true, strings.Contains(buf.String(), "  ciphers:    "))
+
+	buf.Reset()
+	c.DisableClientHelloDump().CloseIdleConnections()
+	resp, err = c.R().Get("/")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "", buf.String())
+}
@@ -141,6 +141,7 @@ func (c *Client) ImpersonateChrome() *Client {
 	return c
 }
 
+// ImpersonateEdge impersonates Edge browser with a random Chromium fingerprint.
 func (c *Client) ImpersonateEdge() *Client {
 	fingerprint := GenerateRandomFingerprint(0)
 	chromeHeaders := map[string]string{
@@ -370,3 +371,15 @@ func (c *Client) ImpersonateSafari() *Client {
 		SetMultipartBoundaryFunc(webkitMultipartBoundaryFunc)
 	return c
 }
+
+// ImpersonateIOS impersonates Safari browser on iOS (version 18).
+func (c *Client) ImpersonateIOS() *Client {
+	builtinProfiles["ios_18"].apply(c)
+	return c
+}
+
+// ImpersonateOkHttp impersonates OkHttp client on Android (version 4).
+func (c *Client) ImpersonateOkHttp() *Client {
+	builtinProfiles["okhttp_4"].apply(c)
+	return c
+}
@@ -0,0 +1,120 @@
+package restys
+
+import (
+	"net/http"
+	"time"
+)
+
+// Option is the functional option to configure the Client, which can be
+// passed to C (NewClient) and Client.CloneWith, a custom option is just a
+// function which calls the setters of Client, e.g.
+//
+//	withDebug := func(c *req.Client) { c.EnableDebugLog() }
+//	client := req.C(req.WithBaseURL("https://api.example.com"), withDebug)
+type Option func(c *Client)
+
+// WithBaseURL is the Option of Client.SetBaseURL.
+func WithBaseURL(u string) Option {
+	return func(c *Client) {
+		c.SetBaseURL(u)
+	}
+}
+
+// WithTimeout is the Option of Client.SetTimeout.
+func WithTimeout(d time.Duration) Option {
+	return func(c *Client) {
+		c.SetTimeout(d)
+	}
+}
+
+// WithProxyURL is the Option of Client.SetProxyURL.
+func WithProxyURL(proxyURL string) Option {
+	return func(c *Client) {
+		c.SetProxyURL(proxyURL)
+	}
+}
+
+// WithCookieJar is the Option of Client.SetCookieJar.
+func WithCookieJar(jar http.CookieJar) Option {
+	return func(c *Client) {
+		c.SetCookieJar(jar)
+	}
+}
+
+// WithUserAgent is the Option of Client.SetUserAgent.
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) {
+		c.SetUserAgent(userAgent)
+	}
+}
+
+// WithCommonHeader is the Option of Client.SetCommonHeader.
+func WithCommonHeader(key, value string) Option {
+	return func(c *Client) {
+		c.SetCommonHeader(key, value)
+	}
+}
+
+// WithCommonHeaders is the Option of Client.SetCommonHeaders.
+func WithCommonHeaders(hdrs map[string]string) Option {
+	return func(c *Client) {
+		c.SetCommonHeaders(hdrs)
+	}
+}
+
+// ShareTransport is the Option of Client.CloneWith which makes the clone
+// share the Transport with the original client instead of copying it, so
+// they share the connection pool. Note the Transport also holds the proxy,
+// the TLS and HTTP/2 fingerprint, and the common headers and cookies, so
+// the options after ShareTransport which modify them also affect the
+// original client. It takes no effect outside of Client.CloneWith.
+func ShareTransport() Option {
+	return func(c *Client) {
+		src := c.cloneSource
+		if src == nil {
+			return
+		}
+		c.Transport = src.Transport
+		c.httpClient.Transport = src.Transport
+		if len(c.roundTripWrappers) > 0 {
+			c.wrappedRoundTrip = roundTripImpl{c}
+			for _, w := range c.roundTripWrappers {
+				c.wrappedRoundTrip = w(c.wrappedRoundTrip)
+			}
+		}
+	}
+}
+
+// ShareCookieJar is the Option of Client.CloneWith which makes the clone
+// share the cookie jar with the original client, instead of creating a new
+// one with the cookie jar factory (see Client.SetCookieJarFactory). It
+// takes no effect outside of Client.CloneWith.
+func ShareCookieJar() Option {
+	return func(c *Client) {
+		if src := c.cloneSource; src != nil {
+			c.httpClient.Jar = src.httpClient.Jar
+		}
+	}
+}
+
+// CloneWith returns a clone of the client (see Client.Clone) with the
+// options applied in order, which derives a client atomically without
+// modifying the original one, e.g. with the same transport but different
+// proxy and cookie jar:
+//
+//	derived := client.CloneWith(
+//	    req.WithProxyURL("http://127.0.0.1:8080"),
+//	    req.WithCookieJar(jar),
+//	)
+//
+// By default everything is copied, use ShareTransport and ShareCookieJar to
+// share them with the original client explicitly.
+func (c *Client) CloneWith(opts ...Option) *Client {
+	cc := c.Clone()
+	cc.cloneSource = c
+	for _, opt := range opts {
+		opt(cc)
+	}
+	cc.cloneSource = nil
+	return cc
+}
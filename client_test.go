@@ -2,23 +2,39 @@ package restys
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
 	"crypto/tls"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
 	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"net/netip"
 	"net/url"
 	"os"
+	"path/filepath"
 	"regexp"
+	"runtime"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/luoxk/restys/http2"
 	"github.com/luoxk/restys/internal/header"
+	h2internal "github.com/luoxk/restys/internal/http2"
+	"github.com/luoxk/restys/internal/http3"
 	"github.com/luoxk/restys/internal/tests"
+	"github.com/luoxk/restys/internal/tlsutil"
+	utls "github.com/refraction-networking/utls"
 	"golang.org/x/net/publicsuffix"
 )
 
@@ -98,6 +114,50 @@ func TestSetDial(t *testing.T) {
 	tests.AssertEqual(t, testErr, err)
 }
 
+func TestSetDNSServers(t *testing.T) {
+	c := tc().SetDNSServers("127.0.0.1:5353", "127.0.0.2")
+	tests.AssertNotNil(t, c.Resolver)
+	conn, err := c.Resolver.Dial(context.Background(), "udp", "ignored:53")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, "127.0.0.1:5353", conn.RemoteAddr().String())
+	conn.Close()
+
+	c.SetDNSServers("127.0.0.2")
+	conn, err = c.Resolver.Dial(context.Background(), "udp", "ignored:53")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, "127.0.0.2:53", conn.RemoteAddr().String())
+	conn.Close()
+
+	// fail over to the next server once the query to the first one fails.
+	down, err := net.ListenPacket("udp", "127.0.0.1:0")
+	tests.AssertNoError(t, err)
+	down.Close()
+	var queries atomic.Int32
+	c.SetDNSServers(down.LocalAddr().String(), serveTestDNS(t, &queries))
+	ips, err := c.Resolver.LookupNetIP(context.Background(), "ip4", "cache.test")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, "127.0.0.1", ips[0].String())
+	tests.AssertEqual(t, true, queries.Load() > 0)
+
+	c.SetDNSServers()
+	tests.AssertIsNil(t, c.Resolver)
+}
+
+func TestSetDNSResolver(t *testing.T) {
+	testErr := errors.New("test")
+	var dnsDialed bool
+	c := tc().SetDNSResolver(&net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			dnsDialed = true
+			return nil, testErr
+		},
+	})
+	_, err := c.R().Get("http://restys.example/")
+	tests.AssertNotNil(t, err)
+	tests.AssertEqual(t, true, dnsDialed)
+}
+
 func TestSetDialTLS(t *testing.T) {
 	testErr := errors.New("test")
 	testDialTLS := func(ctx context.Context, network, addr string) (net.Conn, error) {
@@ -193,6 +253,19 @@ func TestSetCommonHeader(t *testing.T) {
 	tests.AssertEqual(t, "my-value", c.Headers.Get("my-header"))
 }
 
+func TestCommonHeaderCopyOnWrite(t *testing.T) {
+	c := tc().SetCommonHeader("X-Common", "common")
+	c.Headers["X-Multi"] = append(make([]string, 0, 4), "v1")
+	r := c.R().SetHeader("X-Request", "request").SetHeader("X-Common", "override")
+	tests.AssertNoError(t, parseRequestHeader(c, r))
+	tests.AssertEqual(t, "override", r.Headers.Get("X-Common"))
+	tests.AssertEqual(t, "request", r.Headers.Get("X-Request"))
+	r.Headers.Add("X-Multi", "v2")
+	tests.AssertEqual(t, []string{"v1", "v2"}, r.Headers["X-Multi"])
+	tests.AssertEqual(t, []string{"v1"}, c.Headers["X-Multi"])
+	tests.AssertEqual(t, "", c.Headers["X-Multi"][:2][1]) // backing array is untouched
+}
+
 func TestSetCommonHeaderNonCanonical(t *testing.T) {
 	c := tc().SetCommonHeaderNonCanonical("my-Header", "my-value")
 	tests.AssertEqual(t, "my-value", c.Headers["my-Header"][0])
@@ -256,6 +329,95 @@ func TestAutoDecode(t *testing.T) {
 	tests.AssertContains(t, resp.String(), "我是roc", true)
 }
 
+func TestSetResponseCharset(t *testing.T) {
+	resp, err := tc().DisableAutoDecode().R().SetResponseCharset("gbk").Get("/gbk")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "我是roc", resp.String())
+
+	resp, err = tc().R().SetResponseCharset("sjis").Get("/shift-jis-wrong-charset")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "こんにちは", resp.String())
+
+	_, err = tc().R().SetResponseCharset("not-a-charset").Get("/gbk")
+	tests.AssertErrorContains(t, err, "unsupported charset")
+}
+
+func TestSetJSONEngine(t *testing.T) {
+	var marshals, unmarshals int32
+	engine := &JSONEngine{
+		Name: "counting",
+		Marshal: func(v interface{}) ([]byte, error) {
+			atomic.AddInt32(&marshals, 1)
+			return StdJSONEngine.Marshal(v)
+		},
+		Unmarshal: func(data []byte, v interface{}) error {
+			atomic.AddInt32(&unmarshals, 1)
+			return StdJSONEngine.Unmarshal(data, v)
+		},
+	}
+	c := tc().SetJSONEngine(engine)
+
+	var user UserInfo
+	var errMsg ErrorMessage
+	resp, err := c.R().SetBody(map[string]string{"a": "b"}).
+		SetSuccessResult(&user).SetErrorResult(&errMsg).
+		SetQueryParam("username", "imroc").Post("/search")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "imroc", user.Username)
+	tests.AssertEqual(t, int32(1), atomic.LoadInt32(&marshals))
+	tests.AssertEqual(t, int32(1), atomic.LoadInt32(&unmarshals))
+
+	resp, err = c.R().SetSuccessResult(&user).SetErrorResult(&errMsg).Get("/search")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, true, resp.IsErrorState())
+	tests.AssertEqual(t, 10000, errMsg.ErrorCode)
+	tests.AssertEqual(t, int32(2), atomic.LoadInt32(&unmarshals))
+
+	tests.AssertNoError(t, resp.UnmarshalJson(&errMsg))
+	tests.AssertEqual(t, int32(3), atomic.LoadInt32(&unmarshals))
+
+	// nil resets to the default engine.
+	c.SetJSONEngine(nil)
+	_, err = c.R().SetSuccessResult(&user).SetQueryParam("username", "imroc").Get("/search")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, int32(3), atomic.LoadInt32(&unmarshals))
+	tests.AssertEqual(t, "encoding/json", DefaultJSONEngine.Name)
+}
+
+func TestSetResponseDecoder(t *testing.T) {
+	type user struct {
+		Name string
+		Age  string
+	}
+	csvUnmarshal := func(data []byte, v interface{}) error {
+		name, age, _ := strings.Cut(string(data), ",")
+		*(v.(*user)) = user{Name: name, Age: age}
+		return nil
+	}
+	c := tc().SetResponseDecoder("Text/CSV", csvUnmarshal)
+	var u user
+	resp, err := c.R().SetSuccessResult(&u).Get("/csv")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, user{Name: "roc", Age: "18"}, u)
+
+	u = user{}
+	tests.AssertNoError(t, resp.Unmarshal(&u))
+	tests.AssertEqual(t, "roc", u.Name)
+
+	// request-level unmarshal function takes precedence.
+	resp, err = c.R().SetSuccessResult(&u).SetResultUnmarshal(func(data []byte, v interface{}) error {
+		v.(*user).Name = "override"
+		return nil
+	}).Get("/csv")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "override", u.Name)
+
+	// removed decoder falls back to json.
+	_, err = c.Clone().SetResponseDecoder("text/csv", nil).R().SetSuccessResult(&u).Get("/csv")
+	tests.AssertNotNil(t, err)
+	tests.AssertEqual(t, 1, len(c.responseDecoders))
+}
+
 func TestSetTimeout(t *testing.T) {
 	timeout := 100 * time.Second
 	c := tc().SetTimeout(timeout)
@@ -403,6 +565,130 @@ func TestRedirect(t *testing.T) {
 	tests.AssertEqual(t, "test", newHeader.Get("Authorization"))
 }
 
+func TestRedirectHistory(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			time.Sleep(10 * time.Millisecond)
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "restys"})
+			http.Redirect(w, r, "/home", http.StatusFound)
+		case "/home":
+			http.Redirect(w, r, "/final", http.StatusMovedPermanently)
+		default:
+			w.Write([]byte(r.URL.Path))
+		}
+	}))
+	defer server.Close()
+
+	resp, err := C().R().Post(server.URL + "/login")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, "/final", resp.String())
+	hops := resp.RedirectHistory()
+	tests.AssertEqual(t, 2, len(hops))
+	tests.AssertEqual(t, http.MethodPost, hops[0].Method)
+	tests.AssertEqual(t, "/login", hops[0].URL.Path)
+	tests.AssertEqual(t, http.StatusFound, hops[0].StatusCode)
+	tests.AssertEqual(t, "/home", hops[0].Header.Get("Location"))
+	tests.AssertEqual(t, 1, len(hops[0].Cookies))
+	tests.AssertEqual(t, "restys", hops[0].Cookies[0].Value)
+	tests.AssertEqual(t, true, hops[0].Duration >= 10*time.Millisecond)
+	tests.AssertEqual(t, resp.Request.StartTime, hops[0].StartTime)
+	tests.AssertEqual(t, http.MethodGet, hops[1].Method)
+	tests.AssertEqual(t, "/home", hops[1].URL.Path)
+	tests.AssertEqual(t, http.StatusMovedPermanently, hops[1].StatusCode)
+	tests.AssertEqual(t, 0, len(hops[1].Cookies))
+	tests.AssertEqual(t, true, !hops[1].StartTime.Before(hops[0].StartTime.Add(hops[0].Duration)))
+
+	resp, err = C().SetRedirectPolicy(NoRedirectPolicy()).R().Get(server.URL + "/login")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, http.StatusFound, resp.StatusCode)
+	tests.AssertEqual(t, 0, len(resp.RedirectHistory()))
+}
+
+func TestRedirectHook(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/authorize":
+			http.Redirect(w, r, "/callback?code=restys", http.StatusFound)
+		default:
+			w.Write([]byte(r.Header.Get("X-Hop")))
+		}
+	}))
+	defer server.Close()
+
+	// the request level policy overrides the client's.
+	c := C().SetBaseURL(server.URL).SetRedirectPolicy(NoRedirectPolicy())
+	resp, err := c.R().Get("/authorize")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, http.StatusFound, resp.StatusCode)
+	resp, err = c.R().SetRedirectPolicy(DefaultRedirectPolicy()).Get("/authorize")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, http.StatusOK, resp.StatusCode)
+
+	// the hooks mutate the redirect request.
+	c = C().SetBaseURL(server.URL).OnRedirect(func(req *http.Request, via []*http.Request) error {
+		req.Header.Set("X-Hop", strconv.Itoa(len(via)))
+		return nil
+	})
+	resp, err = c.R().Get("/authorize")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, "1", resp.String())
+
+	// the request level hook captures the Location.
+	var location string
+	resp, err = c.R().OnRedirect(func(req *http.Request, via []*http.Request) error {
+		location = req.URL.String()
+		return http.ErrUseLastResponse
+	}).Get("/authorize")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, http.StatusFound, resp.StatusCode)
+	tests.AssertEqual(t, server.URL+"/callback?code=restys", location)
+}
+
+func TestRedirectBehavior(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if code := r.URL.Query().Get("code"); code != "" {
+			status, _ := strconv.Atoi(code)
+			location := "/echo"
+			if next := r.URL.Query().Get("next"); next != "" {
+				location = "/?code=" + next
+			}
+			http.Redirect(w, r, location, status)
+			return
+		}
+		b, _ := io.ReadAll(r.Body)
+		fmt.Fprintf(w, "%s %s %s", r.Method, b, r.Header.Get("Content-Type"))
+	}))
+	defer server.Close()
+
+	send := func(c *Client, code int, behavior *RedirectBehavior, next ...int) string {
+		r := c.R().SetBody("restys").SetContentType("text/plain").SetQueryParam("code", strconv.Itoa(code))
+		if len(next) > 0 {
+			r.SetQueryParam("next", strconv.Itoa(next[0]))
+		}
+		if behavior != nil {
+			r.SetRedirectBehavior(*behavior)
+		}
+		resp, err := r.Post(server.URL)
+		tests.AssertNoError(t, err)
+		return resp.String()
+	}
+	c := C()
+	tests.AssertEqual(t, "GET  ", send(c, http.StatusFound, nil))
+	tests.AssertEqual(t, "POST restys text/plain", send(c, http.StatusTemporaryRedirect, nil))
+	tests.AssertEqual(t, "POST restys text/plain", send(c, http.StatusFound, &RedirectBehavior{KeepMethodOn301And302: true}))
+	tests.AssertEqual(t, "GET  ", send(c, http.StatusSeeOther, &RedirectBehavior{KeepMethodOn301And302: true}))
+	tests.AssertEqual(t, "POST  ", send(c, http.StatusPermanentRedirect, &RedirectBehavior{DropBodyOn307And308: true}))
+	tests.AssertEqual(t, "GET  text/plain", send(c, http.StatusMovedPermanently, &RedirectBehavior{KeepContentHeaders: true}))
+	// 307 after 301 keeps the method and the body of the 301 redirect.
+	tests.AssertEqual(t, "GET  ", send(c, http.StatusMovedPermanently, nil, http.StatusTemporaryRedirect))
+	tests.AssertEqual(t, "POST restys text/plain", send(c, http.StatusMovedPermanently, &RedirectBehavior{KeepMethodOn301And302: true}, http.StatusTemporaryRedirect))
+
+	c.SetRedirectBehavior(RedirectBehavior{KeepMethodOn301And302: true})
+	tests.AssertEqual(t, "POST restys text/plain", send(c, http.StatusMovedPermanently, nil))
+	tests.AssertEqual(t, "GET  ", send(c, http.StatusMovedPermanently, &RedirectBehavior{}))
+}
+
 func TestGetTLSClientConfig(t *testing.T) {
 	c := tc()
 	config := c.GetTLSClientConfig()
@@ -638,6 +924,122 @@ func TestEnableDumpAllToFile(t *testing.T) {
 	tests.AssertContains(t, dump, "testpost: text response", true)
 }
 
+func TestEnableDumpAllToRotateFile(t *testing.T) {
+	dumpFile := filepath.Join(t.TempDir(), "dump.log")
+	c := tc().EnableDumpAllToRotateFile(dumpFile, &DumpRotateOptions{
+		MaxSize:  256,
+		MaxFiles: 2,
+		Compress: true,
+	})
+	for i := 0; i < 5; i++ {
+		resp, err := c.R().SetBody("test body").Post("/")
+		assertSuccess(t, resp, err)
+	}
+	c.DisableDumpAll()
+
+	for _, name := range []string{dumpFile, dumpFile + ".1.gz", dumpFile + ".2.gz"} {
+		_, err := os.Stat(name)
+		tests.AssertNoError(t, err)
+	}
+	_, err := os.Stat(dumpFile + ".3.gz")
+	tests.AssertEqual(t, true, os.IsNotExist(err))
+
+	f, err := os.Open(dumpFile + ".1.gz")
+	tests.AssertNoError(t, err)
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	tests.AssertNoError(t, err)
+	b, err := io.ReadAll(zr)
+	tests.AssertNoError(t, err)
+	tests.AssertContains(t, string(b), "test body", true)
+}
+
+func TestDumpBodySizeLimit(t *testing.T) {
+	testWithAllTransport(t, func(t *testing.T, c *Client) {
+		buf := new(bytes.Buffer)
+		c.SetCommonDumpOptions(&DumpOptions{
+			Output:              buf,
+			RequestBody:         true,
+			ResponseBody:        true,
+			MaxRequestBodySize:  4,
+			MaxResponseBodySize: 8,
+		}).EnableDumpAll()
+		resp, err := c.R().SetBody("test body").Post("/")
+		assertSuccess(t, resp, err)
+		dump := buf.String()
+		tests.AssertContains(t, dump, "test body", false)
+		tests.AssertContains(t, dump, "test\r\n... (body truncated)", true)
+		tests.AssertContains(t, dump, "testpost\r\n... (body truncated)", true)
+	})
+}
+
+func TestDumpHTTP2Frames(t *testing.T) {
+	buf := new(bytes.Buffer)
+	c := tc()
+	c.SetCommonDumpOptions(&DumpOptions{
+		Output:         buf,
+		ResponseHeader: true,
+		HTTP2Frames:    true,
+	}).EnableDumpAll()
+	resp, err := c.R().SetBody("test body").Post("/")
+	assertSuccess(t, resp, err)
+	dump := buf.String()
+	for _, s := range []string{
+		"[h2] > settings len=",
+		"[h2] > headers flags=end_headers stream=1 len=",
+		"[h2] >   :method: post\n",
+		"[h2] > data flags=end_stream stream=1 len=9\n",
+		"[h2] < settings",
+		"[h2] < headers flags=end_headers stream=1 len=",
+		"[h2] <   :status: 200\n",
+		"[h2] < window_update",
+	} {
+		tests.AssertContains(t, dump, s, true)
+	}
+	tests.AssertContains(t, dump, "test body", false)
+}
+
+func TestDumpHTTP2FramesPriority(t *testing.T) {
+	buf := new(bytes.Buffer)
+	c := tc().SetHTTP2PriorityFrames(http2.PriorityFrame{
+		StreamID:      3,
+		PriorityParam: http2.PriorityParam{Weight: 200},
+	}).SetHTTP2HeaderPriority(http2.PriorityParam{StreamDep: 3, Exclusive: true, Weight: 255})
+	c.SetCommonDumpOptions(&DumpOptions{Output: buf, HTTP2Frames: true}).EnableDumpAll()
+	resp, err := c.R().Get("/")
+	assertSuccess(t, resp, err)
+	dump := buf.String()
+	for _, s := range []string{
+		"[h2] > priority stream=3 len=5, priority: stream_dep=0 weight=201 exclusive=false\n",
+		"[h2] > headers flags=end_stream|end_headers|priority stream=5 len=",
+		", priority: stream_dep=3 weight=256 exclusive=true, hpack: block=",
+		"[h2] < headers flags=end_headers stream=5 len=",
+		" header_list=",
+	} {
+		tests.AssertContains(t, dump, s, true)
+	}
+}
+
+func TestSetHTTP2HeaderTableSize(t *testing.T) {
+	buf := new(bytes.Buffer)
+	c := tc().SetHTTP2HeaderTableSize(65536)
+	c.SetCommonDumpOptions(&DumpOptions{Output: buf, HTTP2Frames: true}).EnableDumpAll()
+	resp, err := c.R().Get("/")
+	assertSuccess(t, resp, err)
+	tests.AssertContains(t, buf.String(), ", header_table_size=65536\n", true)
+	tests.AssertContains(t, resp.H2Fingerprint(), ";1:65536|", true)
+
+	// override the one in the settings frame.
+	buf.Reset()
+	c = tc().SetAkamaiWithStr("1:4096;2:0;4:6291456|15663105|0|m,a,s,p").SetHTTP2HeaderTableSize(65536)
+	c.SetCommonDumpOptions(&DumpOptions{Output: buf, HTTP2Frames: true}).EnableDumpAll()
+	resp, err = c.R().Get("/")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "1:65536;2:0;4:6291456|15663105|0|m,a,s,p", resp.H2Fingerprint())
+	tests.AssertEqual(t, uint32(4096), c.t2.Settings[0].Val)
+	tests.AssertEqual(t, uint32(65536), c.Clone().t2.HeaderTableSize)
+}
+
 func TestEnableDumpAllAsync(t *testing.T) {
 	c := tc()
 	buf := new(bytes.Buffer)
@@ -645,6 +1047,125 @@ func TestEnableDumpAllAsync(t *testing.T) {
 	tests.AssertEqual(t, true, c.getDumpOptions().Async)
 }
 
+// blockingWriter blocks the writes until release is closed.
+type blockingWriter struct {
+	release chan struct{}
+	buf     bytes.Buffer
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	<-w.release
+	return w.buf.Write(p)
+}
+
+func TestDumpAsyncOverflow(t *testing.T) {
+	w := &blockingWriter{release: make(chan struct{})}
+	c := tc().SetCommonDumpOptions(&DumpOptions{
+		Output:         w,
+		RequestHeader:  true,
+		ResponseHeader: true,
+		Async:          true,
+		AsyncQueueSize: 1,
+		AsyncOverflow:  DumpOverflowDrop,
+	}).EnableDumpAll()
+	resp, err := c.R().Get("/")
+	assertSuccess(t, resp, err) // not blocked by the dump
+	dropped := c.DumpDropped()
+	if dropped == 0 {
+		t.Fatal("no dumps are dropped")
+	}
+	close(w.release)
+	tests.AssertNoError(t, c.FlushDump(context.Background()))
+	tests.AssertEqual(t, dropped, c.DumpDropped())
+	tests.AssertEqual(t, true, w.buf.Len() > 0)
+
+	// block until the dumps are written.
+	buf := new(bytes.Buffer)
+	c = tc().SetCommonDumpOptions(&DumpOptions{
+		Output:         buf,
+		RequestHeader:  true,
+		Async:          true,
+		AsyncQueueSize: 1,
+	}).EnableDumpAll()
+	resp, err = c.R().Get("/")
+	assertSuccess(t, resp, err)
+	tests.AssertNoError(t, c.FlushDump(context.Background()))
+	tests.AssertEqual(t, uint64(0), c.DumpDropped())
+	tests.AssertContains(t, buf.String(), ":method: get", true)
+}
+
+func TestDumpRedaction(t *testing.T) {
+	testWithAllTransport(t, func(t *testing.T, c *Client) {
+		buf := new(bytes.Buffer)
+		c.SetCommonDumpOptions(&DumpOptions{
+			Output:         buf,
+			RequestHeader:  true,
+			RequestBody:    true,
+			ResponseHeader: true,
+			RedactBody: func(isRequest bool, p []byte) []byte {
+				if isRequest {
+					return bytes.ReplaceAll(p, []byte("secret"), []byte("******"))
+				}
+				return p
+			},
+		}).EnableDumpAll()
+		resp, err := c.R().
+			SetBearerAuthToken("mytoken").
+			SetHeader("Cookie", "session=abc").
+			SetBody("password=secret").
+			Post("/")
+		assertSuccess(t, resp, err)
+		dump := buf.String()
+		tests.AssertContains(t, dump, "authorization: [redacted]", true)
+		tests.AssertContains(t, dump, "mytoken", false)
+		tests.AssertContains(t, dump, "session=abc", false)
+		tests.AssertContains(t, dump, "password=******", true)
+
+		buf.Reset()
+		c.getDumpOptions().RedactHeaders = []string{}
+		resp, err = c.R().SetBearerAuthToken("mytoken").Get("/")
+		assertSuccess(t, resp, err)
+		tests.AssertContains(t, buf.String(), "mytoken", true)
+	})
+}
+
+func TestDumpFormatJSON(t *testing.T) {
+	testWithAllTransport(t, func(t *testing.T, c *Client) {
+		buf := new(bytes.Buffer)
+		c.SetCommonDumpOptions(&DumpOptions{
+			Output:         buf,
+			RequestHeader:  true,
+			RequestBody:    true,
+			ResponseHeader: true,
+			ResponseBody:   true,
+			Format:         DumpFormatJSON,
+		}).EnableDumpAll()
+		resp, err := c.R().
+			SetBearerAuthToken("mytoken").
+			SetBody("test body").
+			SetRetryCount(1).
+			SetRetryCondition(func(resp *Response, err error) bool {
+				return resp.Request.RetryAttempt == 0
+			}).
+			Post("/")
+		assertSuccess(t, resp, err)
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		tests.AssertEqual(t, 2, len(lines))
+		for i, line := range lines {
+			var e DumpEntry
+			tests.AssertNoError(t, json.Unmarshal([]byte(line), &e))
+			tests.AssertEqual(t, i, e.Attempt)
+			tests.AssertEqual(t, http.MethodPost, e.Method)
+			tests.AssertEqual(t, http.StatusOK, e.StatusCode)
+			tests.AssertEqual(t, "test body", string(e.RequestBody))
+			tests.AssertEqual(t, "TestPost: text response", string(e.ResponseBody))
+			tests.AssertEqual(t, "[REDACTED]", e.RequestHeader.Get("Authorization"))
+			tests.AssertEqual(t, true, e.Timings.Total > 0)
+		}
+	})
+}
+
 func TestSetResponseBodyTransformer(t *testing.T) {
 	c := tc().SetResponseBodyTransformer(func(rawBody []byte, req *Request, resp *Response) (transformedBody []byte, err error) {
 		if resp.IsSuccessState() {
@@ -698,3 +1219,853 @@ func TestCloneCookieJar(t *testing.T) {
 	tests.AssertEqual(t, true, c2.cookiejarFactory == nil)
 	tests.AssertEqual(t, true, c2.httpClient.Jar == nil)
 }
+
+func TestSetResponseBodySpillThreshold(t *testing.T) {
+	c := tc().SetResponseBodySpillThreshold(10)
+	resp, err := c.R().Get("/range")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, true, resp.IsBodySpilled())
+	file := resp.bodyFile.Name()
+	tests.AssertEqual(t, 0, len(resp.Bytes()))
+	s, err := resp.ToString()
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, rangeContent, s)
+
+	rs, err := resp.BodyReader()
+	tests.AssertNoError(t, err)
+	_, err = rs.Seek(10, io.SeekStart)
+	tests.AssertNoError(t, err)
+	b, err := io.ReadAll(rs)
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, rangeContent[10:], string(b))
+
+	b, err = io.ReadAll(resp.Body)
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, rangeContent, string(b))
+
+	tests.AssertNoError(t, resp.Close())
+	_, err = os.Stat(file)
+	tests.AssertEqual(t, true, os.IsNotExist(err))
+
+	// small body is kept in memory.
+	resp, err = c.R().SetResponseBodySpillThreshold(100).Get("/range")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, false, resp.IsBodySpilled())
+	tests.AssertEqual(t, rangeContent, resp.String())
+
+	// small body is transformed once.
+	transformed := 0
+	c.SetResponseBodyTransformer(func(rawBody []byte, req *Request, resp *Response) ([]byte, error) {
+		transformed++
+		return append(rawBody, '!'), nil
+	})
+	resp, err = c.R().SetResponseBodySpillThreshold(100).Get("/range")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, rangeContent+"!", resp.String())
+	b, err = io.ReadAll(resp.Body)
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, rangeContent+"!", string(b))
+	tests.AssertEqual(t, 1, transformed)
+	c.SetResponseBodyTransformer(nil)
+
+	// disabled at request level.
+	resp, err = c.R().SetResponseBodySpillThreshold(-1).Get("/range")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, false, resp.IsBodySpilled())
+	tests.AssertEqual(t, rangeContent, resp.String())
+}
+
+func TestSetHosts(t *testing.T) {
+	u, err := url.Parse(getTestServerURL())
+	tests.AssertNoError(t, err)
+	c := tc().SetHosts(map[string]string{
+		"Hosts.test":            "127.0.0.1",
+		"port.test:" + u.Port(): "127.0.0.1",
+		"invalid.test":          "not-an-ip",
+	})
+	tests.AssertEqual(t, 2, len(c.Hosts))
+
+	buf := new(bytes.Buffer)
+	l := NewLogger(buf, "", 0)
+	hosts := map[string]string{"invalid.test": "not-an-ip"}
+	tc().SetLogger(l).SetHosts(hosts)
+	tests.AssertEqual(t, "", buf.String())
+	tc().SetLogger(l).EnableDebugLog().SetHosts(hosts)
+	tests.AssertContains(t, buf.String(), "debug", true)
+	tests.AssertContains(t, buf.String(), "invalid.test", true)
+
+	testWithAllTransport(t, func(t *testing.T, c2 *Client) {
+		c2.Hosts = c.Hosts
+		for _, host := range []string{"hosts.test", "port.test"} {
+			hostport := net.JoinHostPort(host, u.Port())
+			resp, err := c2.R().Get("https://" + hostport + "/host-header")
+			assertSuccess(t, resp, err)
+			tests.AssertEqual(t, hostport, resp.String())
+		}
+	})
+}
+
+func TestSetAddrSelector(t *testing.T) {
+	u, err := url.Parse(getTestServerURL())
+	tests.AssertNoError(t, err)
+	cache := NewDNSCache()
+	tests.AssertNoError(t, cache.Inject("pop.test", 0, "127.0.0.2", "127.0.0.1"))
+
+	testWithAllTransport(t, func(t *testing.T, c *Client) {
+		var got []netip.Addr
+		c.SetDNSCache(cache).EnableTraceAll().SetAddrSelector(func(host string, addrs []netip.Addr) []netip.Addr {
+			tests.AssertEqual(t, "pop.test", host)
+			got = addrs
+			return []netip.Addr{addrs[1]}
+		})
+		resp, err := c.R().Get("https://" + net.JoinHostPort("pop.test", u.Port()) + "/")
+		assertSuccess(t, resp, err)
+		tests.AssertEqual(t, 2, len(got))
+		tests.AssertEqual(t, net.JoinHostPort("127.0.0.1", u.Port()), resp.RemoteAddr().String())
+		ti := resp.TraceInfo()
+		tests.AssertEqual(t, 2, len(ti.ResolvedAddrs))
+		tests.AssertEqual(t, resp.RemoteAddr().String(), ti.RemoteAddr.String())
+
+		// the addresses cached by the DNS cache are not modified.
+		c.GetTransport().CloseIdleConnections()
+		c.SetAddrSelector(func(host string, addrs []netip.Addr) []netip.Addr {
+			slices.Reverse(addrs)
+			return addrs
+		})
+		resp, err = c.R().Get("https://" + net.JoinHostPort("pop.test", u.Port()) + "/")
+		assertSuccess(t, resp, err)
+		cached, err := cache.LookupNetIP(context.Background(), nil, "pop.test")
+		tests.AssertNoError(t, err)
+		tests.AssertEqual(t, "127.0.0.2", cached[0].String())
+
+		c.GetTransport().CloseIdleConnections()
+		c.SetAddrSelector(func(host string, addrs []netip.Addr) []netip.Addr {
+			return nil
+		})
+		_, err = c.R().Get("https://" + net.JoinHostPort("pop.test", u.Port()) + "/")
+		tests.AssertErrorContains(t, err, "no suitable address")
+	})
+}
+
+func TestDialerOptions(t *testing.T) {
+	c := tc().
+		SetDialTimeout(3 * time.Second).
+		SetTCPKeepAlive(-1).
+		SetTCPNoDelay(false)
+	d := c.Dialer()
+	tests.AssertEqual(t, 3*time.Second, d.Timeout)
+	tests.AssertEqual(t, time.Duration(-1), d.KeepAlive)
+	tests.AssertEqual(t, true, c.DisableTCPNoDelay)
+
+	resp, err := c.R().Get("/")
+	assertSuccess(t, resp, err)
+
+	u, err := url.Parse(getTestServerURL())
+	tests.AssertNoError(t, err)
+	conn, err := c.DialDefault(context.Background(), "tcp", u.Host)
+	tests.AssertNoError(t, err)
+	conn.Close()
+	c.SetTCPNoDelay(true)
+	tests.AssertEqual(t, false, c.DisableTCPNoDelay)
+}
+
+func TestTCPFastOpenAndMultipathTCP(t *testing.T) {
+	c := tc().EnableTCPFastOpen().EnableMultipathTCP()
+	tests.AssertEqual(t, true, c.Dialer().MultipathTCP())
+	tests.AssertNotNil(t, c.Dialer().Control)
+	testWithAllTransport(t, func(t *testing.T, c2 *Client) {
+		resp, err := c2.EnableTCPFastOpen().EnableMultipathTCP().R().Get("/")
+		assertSuccess(t, resp, err)
+	})
+	c.DisableTCPFastOpen().DisableMultipathTCP()
+	tests.AssertEqual(t, false, c.Dialer().MultipathTCP())
+}
+
+func TestSetLocalAddrAndInterface(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("127.0.0.2 and SO_BINDTODEVICE are only available on linux")
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, _ := net.SplitHostPort(r.RemoteAddr)
+		w.Write([]byte(host))
+	}))
+	defer ts.Close()
+
+	c := C().SetLocalAddr("127.0.0.2")
+	resp, err := c.R().Get(ts.URL)
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "127.0.0.2", resp.String())
+	conn, err := c.ListenUDP(context.Background())
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, "127.0.0.2", conn.LocalAddr().(*net.UDPAddr).IP.String())
+	conn.Close()
+
+	c.SetLocalAddr("bad")
+	tests.AssertEqual(t, netip.MustParseAddr("127.0.0.2"), c.LocalIP)
+	c.SetLocalAddr("")
+	tests.AssertEqual(t, false, c.LocalIP.IsValid())
+
+	_, err = C().SetInterface("no-such-iface0").R().Get(ts.URL)
+	tests.AssertErrorContains(t, err, "failed to bind to interface no-such-iface0")
+	_, err = C().SetInterface("no-such-iface0").ListenUDP(context.Background())
+	tests.AssertErrorContains(t, err, "failed to bind to interface no-such-iface0")
+}
+
+func TestSetCommonHeaderFunc(t *testing.T) {
+	var seq int32
+	c := tc().SetCommonHeader("X-Seq", "static").
+		SetCommonHeaderFunc("x-seq", func(req *Request) string {
+			return strconv.Itoa(int(atomic.AddInt32(&seq, 1)))
+		}).
+		SetCommonHeaderFunc("X-Empty", func(req *Request) string {
+			return ""
+		})
+
+	for i := 1; i <= 2; i++ {
+		resp, err := c.R().Get("/header")
+		assertSuccess(t, resp, err)
+		var h http.Header
+		tests.AssertNoError(t, json.Unmarshal(resp.Bytes(), &h))
+		tests.AssertEqual(t, strconv.Itoa(i), h.Get("X-Seq"))
+		tests.AssertEqual(t, false, len(h.Values("X-Empty")) > 0)
+	}
+
+	resp, err := c.R().SetHeader("X-Seq", "request").Get("/header")
+	assertSuccess(t, resp, err)
+	var h http.Header
+	tests.AssertNoError(t, json.Unmarshal(resp.Bytes(), &h))
+	tests.AssertEqual(t, "request", h.Get("X-Seq"))
+	tests.AssertEqual(t, int32(2), atomic.LoadInt32(&seq))
+
+	// evaluated for each retry attempt.
+	resp, err = c.R().SetRetryCount(1).
+		SetRetryCondition(func(resp *Response, err error) bool { return true }).
+		Get("/header")
+	assertSuccess(t, resp, err)
+	tests.AssertNoError(t, json.Unmarshal(resp.Bytes(), &h))
+	tests.AssertEqual(t, "4", h.Get("X-Seq"))
+}
+
+func TestRemoveCommonState(t *testing.T) {
+	c := tc().
+		SetCommonHeaders(map[string]string{"X-A": "a", "X-B": "b"}).
+		SetCommonHeaderNonCanonical("x-lower", "l").
+		SetCommonHeaderFunc("X-Func", func(req *Request) string { return "f" }).
+		SetCommonQueryParams(map[string]string{"a": "1", "b": "2", "c": "3"}).
+		SetCommonCookies(&http.Cookie{Name: "foo", Value: "1"}, &http.Cookie{Name: "bar", Value: "2"})
+
+	c.RemoveCommonHeaders("x-a", "x-lower", "X-Func").
+		RemoveCommonQueryParams("a", "b").
+		RemoveCommonCookie("foo")
+	tests.AssertEqual(t, "", c.Headers.Get("X-A"))
+	tests.AssertEqual(t, "b", c.Headers.Get("X-B"))
+	tests.AssertEqual(t, 0, len(c.Headers["x-lower"]))
+	tests.AssertEqual(t, 0, len(c.headerFuncs))
+	tests.AssertEqual(t, "c=3", c.QueryParams.Encode())
+	tests.AssertEqual(t, 1, len(c.Cookies))
+	tests.AssertEqual(t, "bar", c.Cookies[0].Name)
+
+	resp, err := c.R().Get("/query-parameter")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "c=3", resp.String())
+
+	c.ResetCommonState()
+	tests.AssertEqual(t, 0, len(c.Headers))
+	tests.AssertEqual(t, 0, len(c.QueryParams))
+	tests.AssertEqual(t, 0, len(c.Cookies))
+	resp, err = c.R().Get("/query-parameter")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "", resp.String())
+}
+
+func TestCloneWith(t *testing.T) {
+	c := C(WithBaseURL(getTestServerURL()), WithTimeout(time.Second), WithUserAgent("base")).
+		EnableInsecureSkipVerify()
+	tests.AssertEqual(t, getTestServerURL(), c.BaseURL)
+	tests.AssertEqual(t, time.Second, c.GetClient().Timeout)
+
+	jar, _ := cookiejar.New(nil)
+	cc := c.CloneWith(
+		WithProxyURL("http://127.0.0.1:8080"),
+		WithCookieJar(jar),
+		WithCommonHeader("X-Derived", "1"),
+	)
+	tests.AssertEqual(t, true, cc.Transport != c.Transport)
+	tests.AssertEqual(t, true, cc.Proxy != nil)
+	tests.AssertEqual(t, http.CookieJar(jar), cc.GetClient().Jar)
+	tests.AssertEqual(t, "", c.Headers.Get("X-Derived"))
+	tests.AssertEqual(t, "base", cc.Headers.Get("User-Agent"))
+	tests.AssertEqual(t, true, cc.cloneSource == nil)
+
+	shared := c.CloneWith(ShareTransport(), ShareCookieJar(), WithTimeout(2*time.Second))
+	tests.AssertEqual(t, true, shared.Transport == c.Transport)
+	tests.AssertEqual(t, true, shared.GetClient().Jar == c.GetClient().Jar)
+	tests.AssertEqual(t, time.Second, c.GetClient().Timeout)
+	tests.AssertEqual(t, 2*time.Second, shared.GetClient().Timeout)
+	resp, err := shared.R().Get("/")
+	assertSuccess(t, resp, err)
+
+	// no effect outside of CloneWith.
+	tests.AssertEqual(t, true, C(ShareTransport(), ShareCookieJar()).Transport != nil)
+}
+
+func TestHTTP2ConnPoolStats(t *testing.T) {
+	c := tc().EnableForceHTTP2()
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := c.R().Get("/")
+			assertSuccess(t, resp, err)
+		}()
+	}
+	wg.Wait()
+	stats := c.HTTP2ConnPoolStats()
+	tests.AssertEqual(t, uint64(1), stats.Dials) // the concurrent dials are coalesced
+	tests.AssertEqual(t, true, stats.CoalescedDials <= 19)
+}
+
+// waitPoolStats polls the pool statistics of addr until cond is true.
+func waitPoolStats(c *Client, addr string, cond func(HostPoolStats) bool) HostPoolStats {
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		stats := c.PoolStats()[addr]
+		if cond(stats) || time.Now().After(deadline) {
+			return stats
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestPoolStats(t *testing.T) {
+	u, err := url.Parse(getTestServerURL())
+	tests.AssertNoError(t, err)
+	for _, forceHTTP1 := range []bool{true, false} {
+		c := tc()
+		if forceHTTP1 {
+			c.EnableForceHTTP1()
+		} else {
+			c.EnableForceHTTP2()
+		}
+		for i := 0; i < 3; i++ {
+			resp, err := c.R().Get("/")
+			assertSuccess(t, resp, err)
+		}
+		stats := waitPoolStats(c, u.Host, func(s HostPoolStats) bool { return s.Idle == 1 })
+		tests.AssertEqual(t, HostPoolStats{
+			Idle:       1,
+			Dials:      1,
+			Requests:   3,
+			ReuseRatio: 2.0 / 3,
+		}, stats)
+
+		resp, err := c.R().DisableAutoReadResponse().Get("/")
+		assertSuccess(t, resp, err)
+		tests.AssertEqual(t, int64(1), c.PoolStats()[u.Host].InFlight)
+		resp.Body.Close()
+		tests.AssertEqual(t, int64(0), c.PoolStats()[u.Host].InFlight)
+		tests.AssertEqual(t, uint64(0), c.Clone().PoolStats()[u.Host].Requests)
+	}
+
+	// the idle HTTP/3 connection is closed after the idle timeout.
+	addr := serveHTTP3(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	}), false)
+	c := tc().SetMaxIdleConnTimeout(100 * time.Millisecond)
+	c.t3 = &http3.RoundTripper{
+		Options:         &c.Transport.Options,
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}
+	defer c.t3.Close()
+	req, err := http.NewRequest(http.MethodGet, "https://"+addr+"/", nil)
+	tests.AssertNoError(t, err)
+	resp, err := c.t3.RoundTrip(req)
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, 0, c.PoolStats()[addr].Idle)
+	_, err = io.ReadAll(resp.Body)
+	tests.AssertNoError(t, err)
+	resp.Body.Close()
+	stats := c.PoolStats()[addr]
+	tests.AssertEqual(t, 1, stats.Idle)
+	tests.AssertEqual(t, uint64(1), stats.Dials)
+	stats = waitPoolStats(c, addr, func(s HostPoolStats) bool { return s.Idle == 0 })
+	tests.AssertEqual(t, 0, stats.Idle)
+
+	// the hosts without in-flight requests are evicted once too many hosts
+	// are requested.
+	c = tc()
+	inFlight := c.ConnStats.StartRequest("in-flight:443")
+	for i := 0; i < 2000; i++ {
+		c.ConnStats.StartRequest(fmt.Sprintf("host%d:443", i))()
+	}
+	tests.AssertEqual(t, true, len(c.PoolStats()) <= 1024)
+	tests.AssertEqual(t, int64(1), c.PoolStats()["in-flight:443"].InFlight)
+	inFlight()
+}
+
+func TestSetMaxConnAge(t *testing.T) {
+	for _, forceHTTP1 := range []bool{true, false} {
+		c := tc().SetMaxConnAge(50 * time.Millisecond).SetMaxIdleConnsPerHost(4)
+		if forceHTTP1 {
+			c.EnableForceHTTP1()
+		} else {
+			c.EnableForceHTTP2()
+		}
+		var connects int32
+		c.OnConnectDone(func(info ConnectDoneInfo) {
+			atomic.AddInt32(&connects, 1)
+		})
+		for i := 0; i < 2; i++ {
+			resp, err := c.R().Get("/")
+			assertSuccess(t, resp, err)
+		}
+		tests.AssertEqual(t, int32(1), atomic.LoadInt32(&connects))
+		time.Sleep(80 * time.Millisecond)
+		if !forceHTTP1 {
+			// the idle HTTP/2 connection is closed once it's too old.
+			u, _ := url.Parse(c.BaseURL)
+			tests.AssertEqual(t, 0, waitPoolStats(c, u.Host, func(s HostPoolStats) bool { return s.Idle == 0 }).Idle)
+		}
+		resp, err := c.R().Get("/")
+		assertSuccess(t, resp, err)
+		tests.AssertEqual(t, int32(2), atomic.LoadInt32(&connects))
+	}
+
+	// the idle HTTP/3 connection is closed once it's too old.
+	addr := serveHTTP3(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	}), false)
+	c := tc().SetMaxConnAge(50 * time.Millisecond).SetMaxIdleConnTimeout(0)
+	c.t3 = &http3.RoundTripper{
+		Options:         &c.Transport.Options,
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}
+	defer c.t3.Close()
+	req, err := http.NewRequest(http.MethodGet, "https://"+addr+"/", nil)
+	tests.AssertNoError(t, err)
+	resp, err := c.t3.RoundTrip(req)
+	tests.AssertNoError(t, err)
+	io.ReadAll(resp.Body)
+	resp.Body.Close()
+	tests.AssertEqual(t, 1, c.PoolStats()[addr].Idle)
+	tests.AssertEqual(t, 0, waitPoolStats(c, addr, func(s HostPoolStats) bool { return s.Idle == 0 }).Idle)
+}
+
+func TestWarmPool(t *testing.T) {
+	for _, forceHTTP1 := range []bool{true, false} {
+		c := tc()
+		if forceHTTP1 {
+			c.EnableForceHTTP1()
+		} else {
+			c.EnableForceHTTP2()
+		}
+		var connects int32
+		c.OnConnectDone(func(info ConnectDoneInfo) {
+			atomic.AddInt32(&connects, 1)
+		})
+		c.EnableWarmPool([]string{c.BaseURL, "::invalid"}, &WarmPoolOptions{
+			Conns:    2,
+			Interval: 20 * time.Millisecond,
+		})
+		want := int32(2)
+		if !forceHTTP1 {
+			want = 1 // the HTTP/2 connection is multiplexed
+		}
+		for i := 0; i < 50 && atomic.LoadInt32(&connects) < want; i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+		time.Sleep(50 * time.Millisecond)
+		tests.AssertEqual(t, want, atomic.LoadInt32(&connects))
+
+		c.DisableWarmPool()
+		tests.AssertIsNil(t, c.warmPool)
+		resp, err := c.R().Get("/")
+		assertSuccess(t, resp, err)
+		tests.AssertEqual(t, want, atomic.LoadInt32(&connects))
+	}
+}
+
+func TestResponseHeaderTimeout(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(300 * time.Millisecond)
+		}
+		w.Write([]byte("ok"))
+	})
+	h1 := httptest.NewServer(handler)
+	defer h1.Close()
+	h2 := httptest.NewUnstartedServer(handler)
+	h2.EnableHTTP2 = true
+	h2.StartTLS()
+	defer h2.Close()
+
+	for _, c := range []*Client{
+		C().SetBaseURL(h1.URL),
+		C().SetBaseURL(h2.URL).EnableInsecureSkipVerify().EnableForceHTTP2(),
+	} {
+		c.SetResponseHeaderTimeout(50 * time.Millisecond)
+		resp, err := c.R().Get("/")
+		assertSuccess(t, resp, err)
+		_, err = c.R().Get("/slow")
+		tests.AssertErrorContains(t, err, "timeout awaiting response headers")
+	}
+}
+
+func TestExpectContinueTimeout(t *testing.T) {
+	var got100 atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/reject" {
+			w.WriteHeader(http.StatusExpectationFailed)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	c := C().SetBaseURL(server.URL).SetExpectContinueTimeout(time.Second)
+	resp, err := c.R().
+		SetHeader("Expect", "100-continue").
+		SetBody("hello").
+		SetContext(httptrace.WithClientTrace(context.Background(), &httptrace.ClientTrace{
+			Got100Continue: func() { got100.Store(true) },
+		})).
+		Post("/")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "hello", resp.String())
+	tests.AssertEqual(t, true, got100.Load())
+
+	resp, err = c.R().SetHeader("Expect", "100-continue").SetBody("hello").Post("/reject")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, http.StatusExpectationFailed, resp.StatusCode)
+}
+
+func TestResultRouting(t *testing.T) {
+	type NotFound struct {
+		Resource string `json:"resource"`
+	}
+	type Problem struct {
+		Title string `json:"title"`
+	}
+	type Moved struct {
+		Location string `json:"location"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/missing":
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"resource": "user"}`))
+		case "/problem":
+			w.Header().Set("Content-Type", "application/problem+json; charset=utf-8")
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"title": "bad input"}`))
+		case "/moved":
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotModified)
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusConflict)
+			w.Write([]byte(`{"error_code": 409, "error_message": "conflict"}`))
+		}
+	}))
+	defer server.Close()
+
+	var unknown int
+	c := C().SetBaseURL(server.URL).
+		SetResultForStatus(http.StatusNotFound, &NotFound{}).
+		SetResultForContentType("application/problem+json", Problem{}).
+		SetCommonErrorResult(&ErrorMessage{}).
+		SetCommonUnknownResultHandlerFunc(func(resp *Response) error {
+			unknown++
+			return nil
+		})
+
+	resp, err := c.R().Get("/missing")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, &NotFound{Resource: "user"}, resp.ErrorResult())
+
+	resp, err = c.R().Get("/problem")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, &Problem{Title: "bad input"}, resp.ErrorResult())
+
+	resp, err = c.R().Get("/conflict")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, &ErrorMessage{ErrorCode: 409, ErrorMessage: "conflict"}, resp.ErrorResult())
+
+	// the request level result takes precedence.
+	var e ErrorMessage
+	resp, err = c.R().SetErrorResult(&e).Get("/missing")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, &e, resp.ErrorResult())
+
+	resp, err = c.R().Get("/moved")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, 1, unknown)
+
+	errUnknown := errors.New("unknown result")
+	_, err = c.R().SetUnknownResultHandlerFunc(func(resp *Response) error {
+		return errUnknown
+	}).Get("/moved")
+	tests.AssertEqual(t, errUnknown, err)
+	tests.AssertEqual(t, 1, unknown)
+}
+
+type xorReader struct {
+	r   io.Reader
+	key byte
+}
+
+func (x *xorReader) Read(p []byte) (int, error) {
+	n, err := x.r.Read(p)
+	for i := 0; i < n; i++ {
+		p[i] ^= x.key
+	}
+	return n, err
+}
+
+func TestResponseBodyStreamTransformer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(&xorReader{r: strings.NewReader("secret message"), key: 0x5a})
+		if r.URL.Path == "/gzip" {
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Header().Set("Content-Type", "application/x-encrypted")
+			gw := gzip.NewWriter(w)
+			gw.Write(body)
+			gw.Close()
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-encrypted")
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	var infos []ResponseBodyInfo
+	c := C().SetBaseURL(server.URL).SetResponseBodyStreamTransformer(func(body io.Reader, info ResponseBodyInfo, req *Request, resp *Response) (io.Reader, error) {
+		infos = append(infos, info)
+		if info.ContentType != "application/x-encrypted" {
+			return body, nil
+		}
+		return &xorReader{r: body, key: 0x5a}, nil
+	})
+	resp, err := c.R().Get("/")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "secret message", resp.String())
+	tests.AssertEqual(t, ResponseBodyInfo{ContentType: "application/x-encrypted", ContentLength: 14}, infos[0])
+
+	resp, err = c.R().Get("/gzip")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "secret message", resp.String())
+	tests.AssertEqual(t, "", infos[1].ContentEncoding)
+
+	body, _, err := c.R().Stream()
+	tests.AssertNoError(t, err)
+	b, err := io.ReadAll(body)
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, "secret message", string(b))
+	body.Close()
+
+	errTransform := errors.New("bad key")
+	c.SetResponseBodyStreamTransformer(func(body io.Reader, info ResponseBodyInfo, req *Request, resp *Response) (io.Reader, error) {
+		return nil, errTransform
+	})
+	_, err = c.R().Get("/")
+	tests.AssertEqual(t, true, errors.Is(err, errTransform))
+}
+
+func TestAutoRedispatch(t *testing.T) {
+	c := tc()
+	get, _ := http.NewRequest(http.MethodGet, "https://example.com", nil)
+	post, _ := http.NewRequest(http.MethodPost, "https://example.com", strings.NewReader("data"))
+	post.GetBody = nil
+	goAway := h2internal.GoAwayError{ErrCode: h2internal.ErrCodeNo}
+	rejected := &http3.Error{Remote: true, ErrorCode: http3.ErrCodeRequestRejected}
+
+	tests.AssertEqual(t, true, c.shouldRedispatch(get, goAway))
+	tests.AssertEqual(t, false, c.shouldRedispatch(post, goAway))
+	tests.AssertEqual(t, true, c.shouldRedispatch(post, rejected))
+	tests.AssertEqual(t, false, c.shouldRedispatch(get, errors.New("other")))
+
+	var redispatched []error
+	c.OnRedispatch(func(req *http.Request, err error) {
+		redispatched = append(redispatched, err)
+	})
+	calls := 0
+	_, resp, done, err := c.roundTripCachedConn(get, func(req *http.Request) (*http.Response, error) {
+		calls++
+		if calls == 1 {
+			return nil, goAway
+		}
+		return &http.Response{StatusCode: http.StatusOK}, nil
+	}, errors.New("no cached conn"))
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, true, done)
+	tests.AssertEqual(t, http.StatusOK, resp.StatusCode)
+	tests.AssertEqual(t, 2, calls)
+	tests.AssertEqual(t, 1, len(redispatched))
+
+	c.DisableAutoRedispatch()
+	tests.AssertEqual(t, false, c.shouldRedispatch(get, goAway))
+	tests.AssertEqual(t, false, c.shouldRedispatch(post, rejected))
+}
+
+func TestSetFingerprintWithStrError(t *testing.T) {
+	ja3 := "771,4865-4866-4867-49195-49199,51-16-11-10-43-65281-13-5,29-23-24,0"
+	tests.AssertNoError(t, ValidateJa3(ja3))
+	tests.AssertErrorContains(t, ValidateJa3("771,4865-4866"), "format error")
+	tests.AssertErrorContains(t, ValidateJa3("999,4865,51,29,0"), "tls version error")
+	tests.AssertErrorContains(t, ValidateJa3("771,abc,51,29,0"), "cipherSuites error")
+
+	akamai := "1:65536,2:0,4:6291456,6:262144|15663105|0|m,a,s,p"
+	tests.AssertNoError(t, ValidateAkamai(akamai))
+	tests.AssertNoError(t, ValidateAkamai("1:65536|15663105|3:0:0:201,5:0:0:101|m,p,a,s"))
+	tests.AssertErrorContains(t, ValidateAkamai("1:65536|15663105|0"), "format error")
+	tests.AssertErrorContains(t, ValidateAkamai("1:x|15663105|0|m,a,s,p"), "setting error")
+	tests.AssertErrorContains(t, ValidateAkamai("1:65536|15663105|1:2|m,a,s,p"), "priority frame error")
+	tests.AssertErrorContains(t, ValidateAkamai("1:65536|15663105|0|m,x"), "pseudo header order error")
+
+	c := tc()
+	_, err := c.TrySetJa3WithStr("771,abc,51,29,0")
+	tests.AssertNotNil(t, err)
+	tests.AssertIsNil(t, c.TLSHandshakeContext)
+	_, err = c.TrySetJa3WithStr(ja3)
+	tests.AssertNoError(t, err)
+	tests.AssertNotNil(t, c.TLSHandshakeContext)
+
+	_, err = c.TrySetAkamaiWithStr("1:65536|15663105|0|m,x")
+	tests.AssertNotNil(t, err)
+	_, err = c.TrySetAkamaiWithStr(akamai)
+	tests.AssertNoError(t, err)
+
+	defer func() {
+		tests.AssertNotNil(t, recover())
+	}()
+	c.MustSetAkamaiWithStr("bad")
+}
+
+func TestJa3PostQuantumKeyShare(t *testing.T) {
+	// 772 advertises TLS 1.3 in the supported_versions extension, which the
+	// key shares are used by.
+	ja3 := "772,4865-4866-4867-49195-49199,0-10-11-13-16-43-51-65281,4588-29-23-24,0"
+	spec, err := createSpecWithJa3Str(ja3, nil)
+	tests.AssertNoError(t, err)
+	var curves *utls.SupportedCurvesExtension
+	var keyShare *utls.KeyShareExtension
+	for _, ext := range spec.Extensions {
+		switch e := ext.(type) {
+		case *utls.SupportedCurvesExtension:
+			curves = e
+		case *utls.KeyShareExtension:
+			keyShare = e
+		}
+	}
+	tests.AssertNotNil(t, curves)
+	tests.AssertEqual(t, utls.X25519MLKEM768, curves.Curves[1])
+	tests.AssertNotNil(t, keyShare)
+	tests.AssertEqual(t, 3, len(keyShare.KeyShares))
+	tests.AssertEqual(t, utls.X25519MLKEM768, keyShare.KeyShares[1].Group)
+	tests.AssertEqual(t, utls.X25519, keyShare.KeyShares[2].Group)
+
+	// the server only supports ML-KEM.
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	server.TLS = &tls.Config{CurvePreferences: []tls.CurveID{tls.X25519MLKEM768}}
+	server.StartTLS()
+	defer server.Close()
+	resp, err := C().EnableInsecureSkipVerify().SetJa3WithStr(ja3).R().Get(server.URL)
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "ok", resp.String())
+}
+
+func TestSetTLSFingerprintFromBytes(t *testing.T) {
+	var captured []byte
+	c := tc().SetTLSFingerprintChrome()
+	c.Transport.OnClientHello = func(host string, msg []byte) {
+		captured = append([]byte(nil), msg...)
+	}
+	resp, err := c.R().Get("/")
+	assertSuccess(t, resp, err)
+	tests.AssertNotNil(t, captured)
+	want, err := tlsutil.ParseClientHello(captured)
+	tests.AssertNoError(t, err)
+
+	// both the handshake message and the tls record are accepted.
+	record := append([]byte{22, 3, 1, byte(len(captured) >> 8), byte(len(captured))}, captured...)
+	for _, raw := range [][]byte{captured, record} {
+		var replayed []byte
+		c := tc().SetTLSFingerprintFromBytes(raw)
+		c.Transport.OnClientHello = func(host string, msg []byte) {
+			replayed = append([]byte(nil), msg...)
+		}
+		resp, err := c.R().Get("/")
+		assertSuccess(t, resp, err)
+		got, err := tlsutil.ParseClientHello(replayed)
+		tests.AssertNoError(t, err)
+		tests.AssertEqual(t, len(want.CipherSuites), len(got.CipherSuites))
+		tests.AssertEqual(t, want.CipherSuites[1:], got.CipherSuites[1:])
+		tests.AssertEqual(t, len(want.Extensions), len(got.Extensions))
+		tests.AssertEqual(t, want.Curves[1:], got.Curves[1:])
+	}
+
+	c = tc()
+	_, err = c.TrySetTLSFingerprintFromBytes([]byte{22, 3, 1, 0, 1, 2})
+	tests.AssertErrorContains(t, err, "invalid client hello")
+	tests.AssertIsNil(t, c.TLSHandshakeContext)
+}
+
+func TestAkamaiPriorityFrames(t *testing.T) {
+	spec, err := createH2SpecWithStr("1:65536;4:131072;5:16384|12517377|3:0:0:201,5:1:3:101|m,p,a,s")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, []http2.PriorityFrame{
+		{StreamID: 3, PriorityParam: http2.PriorityParam{StreamDep: 0, Exclusive: false, Weight: 200}},
+		{StreamID: 5, PriorityParam: http2.PriorityParam{StreamDep: 3, Exclusive: true, Weight: 100}},
+	}, spec.PriorityFrames)
+
+	spec, err = createH2SpecWithStr("1:65536|15663105|0|m,a,s,p")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, 0, len(spec.PriorityFrames))
+
+	for _, priority := range []string{"0:0:0:201", "3:2:0:201", "3:0:0:0", "3:0:0:257", "3:0:0"} {
+		_, err = createH2SpecWithStr("1:65536|15663105|" + priority + "|m,a,s,p")
+		tests.AssertErrorContains(t, err, "h2 priority frame error")
+	}
+
+	c := tc().SetAkamaiWithStr("1:65536;4:131072;5:16384|12517377|3:0:0:201,5:1:3:101|m,p,a,s")
+	tests.AssertEqual(t, 2, len(c.t2.PriorityFrames))
+	tests.AssertEqual(t, uint32(12517377), c.t2.ConnectionFlow)
+}
+
+func TestResponseH2Fingerprint(t *testing.T) {
+	for _, akamai := range []string{
+		"1:65536;2:0;4:6291456;6:262144|15663105|0|m,a,s,p",
+		"1:65536;4:131072;5:16384|12517377|3:0:0:201,5:1:3:101|m,p,a,s",
+	} {
+		c := tc().SetAkamaiWithStr(akamai)
+		resp, err := c.R().Get("/")
+		assertSuccess(t, resp, err)
+		tests.AssertEqual(t, akamai, resp.H2Fingerprint())
+
+		// the pseudo header order of the request on the reused connection.
+		resp, err = c.R().SetPseudoHeaderOrder(":path", ":authority", ":method", ":scheme").Get("/")
+		assertSuccess(t, resp, err)
+		tests.AssertEqual(t, akamai[:strings.LastIndex(akamai, "|")]+"|p,a,m,s", resp.H2Fingerprint())
+	}
+
+	// the fingerprint of the retried request.
+	akamai := "1:65536;2:0;4:6291456;6:262144|15663105|0|m,a,s,p"
+	resp, err := tc().SetAkamaiWithStr(akamai).R().
+		SetRetryCount(1).
+		SetRetryCondition(func(resp *Response, err error) bool {
+			return resp.Request.RetryAttempt == 0
+		}).
+		Get("/")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, 1, resp.Request.RetryAttempt)
+	tests.AssertEqual(t, akamai, resp.H2Fingerprint())
+
+	resp, err = tc().EnableForceHTTP1().R().Get("/")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "", resp.H2Fingerprint())
+}
@@ -39,6 +39,24 @@ func SetCommonErrorResult(err interface{}) *Client {
 	return defaultClient.SetCommonErrorResult(err)
 }
 
+// SetResultForStatus is a global wrapper methods which delegated
+// to the default client's Client.SetResultForStatus.
+func SetResultForStatus(code int, result interface{}) *Client {
+	return defaultClient.SetResultForStatus(code, result)
+}
+
+// SetResultForContentType is a global wrapper methods which delegated
+// to the default client's Client.SetResultForContentType.
+func SetResultForContentType(contentType string, result interface{}) *Client {
+	return defaultClient.SetResultForContentType(contentType, result)
+}
+
+// SetCommonUnknownResultHandlerFunc is a global wrapper methods which delegated
+// to the default client's Client.SetCommonUnknownResultHandlerFunc.
+func SetCommonUnknownResultHandlerFunc(fn func(resp *Response) error) *Client {
+	return defaultClient.SetCommonUnknownResultHandlerFunc(fn)
+}
+
 // SetResultStateCheckFunc is a global wrapper methods which delegated
 // to the default client's Client.SetCommonResultStateCheckFunc.
 func SetResultStateCheckFunc(fn func(resp *Response) ResultState) *Client {
@@ -105,6 +123,24 @@ func GetTLSClientConfig() *tls.Config {
 	return defaultClient.GetTLSClientConfig()
 }
 
+// OnRedirect is a global wrapper methods which delegated
+// to the default client's Client.OnRedirect.
+func OnRedirect(fn func(req *http.Request, via []*http.Request) error) *Client {
+	return defaultClient.OnRedirect(fn)
+}
+
+// SetRedirectBehavior is a global wrapper methods which delegated
+// to the default client's Client.SetRedirectBehavior.
+func SetRedirectBehavior(behavior RedirectBehavior) *Client {
+	return defaultClient.SetRedirectBehavior(behavior)
+}
+
+// SetURLNormalization is a global wrapper methods which delegated
+// to the default client's Client.SetURLNormalization.
+func SetURLNormalization(n URLNormalization) *Client {
+	return defaultClient.SetURLNormalization(n)
+}
+
 // SetRedirectPolicy is a global wrapper methods which delegated
 // to the default client's Client.SetRedirectPolicy.
 func SetRedirectPolicy(policies ...RedirectPolicy) *Client {
@@ -201,6 +237,42 @@ func SetCommonCookies(cookies ...*http.Cookie) *Client {
 	return defaultClient.SetCommonCookies(cookies...)
 }
 
+// RemoveCommonCookie is a global wrapper methods which delegated
+// to the default client's Client.RemoveCommonCookie.
+func RemoveCommonCookie(name string) *Client {
+	return defaultClient.RemoveCommonCookie(name)
+}
+
+// ResetCommonState is a global wrapper methods which delegated
+// to the default client's Client.ResetCommonState.
+func ResetCommonState() *Client {
+	return defaultClient.ResetCommonState()
+}
+
+// RemoveCommonQueryParam is a global wrapper methods which delegated
+// to the default client's Client.RemoveCommonQueryParam.
+func RemoveCommonQueryParam(key string) *Client {
+	return defaultClient.RemoveCommonQueryParam(key)
+}
+
+// RemoveCommonQueryParams is a global wrapper methods which delegated
+// to the default client's Client.RemoveCommonQueryParams.
+func RemoveCommonQueryParams(keys ...string) *Client {
+	return defaultClient.RemoveCommonQueryParams(keys...)
+}
+
+// RemoveCommonHeader is a global wrapper methods which delegated
+// to the default client's Client.RemoveCommonHeader.
+func RemoveCommonHeader(key string) *Client {
+	return defaultClient.RemoveCommonHeader(key)
+}
+
+// RemoveCommonHeaders is a global wrapper methods which delegated
+// to the default client's Client.RemoveCommonHeaders.
+func RemoveCommonHeaders(keys ...string) *Client {
+	return defaultClient.RemoveCommonHeaders(keys...)
+}
+
 // DisableDebugLog is a global wrapper methods which delegated
 // to the default client's Client.DisableDebugLog.
 func DisableDebugLog() *Client {
@@ -213,6 +285,36 @@ func EnableDebugLog() *Client {
 	return defaultClient.EnableDebugLog()
 }
 
+// EnableRequestID is a global wrapper methods which delegated
+// to the default client's Client.EnableRequestID.
+func EnableRequestID(headerName string, generator RequestIDGenerator) *Client {
+	return defaultClient.EnableRequestID(headerName, generator)
+}
+
+// DisableRequestID is a global wrapper methods which delegated
+// to the default client's Client.DisableRequestID.
+func DisableRequestID() *Client {
+	return defaultClient.DisableRequestID()
+}
+
+// EnableStats is a global wrapper methods which delegated
+// to the default client's Client.EnableStats.
+func EnableStats() *Client {
+	return defaultClient.EnableStats()
+}
+
+// DisableStats is a global wrapper methods which delegated
+// to the default client's Client.DisableStats.
+func DisableStats() *Client {
+	return defaultClient.DisableStats()
+}
+
+// ResetStats is a global wrapper methods which delegated
+// to the default client's Client.ResetStats.
+func ResetStats() *Client {
+	return defaultClient.ResetStats()
+}
+
 // DevMode is a global wrapper methods which delegated
 // to the default client's Client.DevMode.
 func DevMode() *Client {
@@ -249,6 +351,12 @@ func EnableDumpAllToFile(filename string) *Client {
 	return defaultClient.EnableDumpAllToFile(filename)
 }
 
+// EnableDumpAllToRotateFile is a global wrapper methods which delegated
+// to the default client's Client.EnableDumpAllToRotateFile.
+func EnableDumpAllToRotateFile(filename string, opts *DumpRotateOptions) *Client {
+	return defaultClient.EnableDumpAllToRotateFile(filename, opts)
+}
+
 // EnableDumpAllTo is a global wrapper methods which delegated
 // to the default client's Client.EnableDumpAllTo.
 func EnableDumpAllTo(output io.Writer) *Client {
@@ -261,6 +369,18 @@ func EnableDumpAllAsync() *Client {
 	return defaultClient.EnableDumpAllAsync()
 }
 
+// FlushDump is a global wrapper methods which delegated
+// to the default client's Client.FlushDump.
+func FlushDump(ctx context.Context) error {
+	return defaultClient.FlushDump(ctx)
+}
+
+// DumpDropped is a global wrapper methods which delegated
+// to the default client's Client.DumpDropped.
+func DumpDropped() uint64 {
+	return defaultClient.DumpDropped()
+}
+
 // EnableDumpAllWithoutRequestBody is a global wrapper methods which delegated
 // to the default client's Client.EnableDumpAllWithoutRequestBody.
 func EnableDumpAllWithoutRequestBody() *Client {
@@ -345,6 +465,12 @@ func DisableAutoReadResponse() *Client {
 	return defaultClient.DisableAutoReadResponse()
 }
 
+// SetResponseBodySpillThreshold is a global wrapper methods which delegated
+// to the default client's Client.SetResponseBodySpillThreshold.
+func SetResponseBodySpillThreshold(threshold int64) *Client {
+	return defaultClient.SetResponseBodySpillThreshold(threshold)
+}
+
 // EnableAutoReadResponse is a global wrapper methods which delegated
 // to the default client's Client.EnableAutoReadResponse.
 func EnableAutoReadResponse() *Client {
@@ -375,6 +501,24 @@ func DisableAutoDecode() *Client {
 	return defaultClient.DisableAutoDecode()
 }
 
+// DisableAutoRedispatch is a global wrapper methods which delegated
+// to the default client's Client.DisableAutoRedispatch.
+func DisableAutoRedispatch() *Client {
+	return defaultClient.DisableAutoRedispatch()
+}
+
+// EnableAutoRedispatch is a global wrapper methods which delegated
+// to the default client's Client.EnableAutoRedispatch.
+func EnableAutoRedispatch() *Client {
+	return defaultClient.EnableAutoRedispatch()
+}
+
+// OnRedispatch is a global wrapper methods which delegated
+// to the default client's Client.OnRedispatch.
+func OnRedispatch(fn func(req *http.Request, err error)) *Client {
+	return defaultClient.OnRedispatch(fn)
+}
+
 // EnableAutoDecode is a global wrapper methods which delegated
 // to the default client's Client.EnableAutoDecode.
 func EnableAutoDecode() *Client {
@@ -411,12 +555,60 @@ func SetCommonHeaders(hdrs map[string]string) *Client {
 	return defaultClient.SetCommonHeaders(hdrs)
 }
 
+// SetJa3Options is a global wrapper methods which delegated
+// to the default client's Client.SetJa3Options.
+func SetJa3Options(opts *Ja3Options) *Client {
+	return defaultClient.SetJa3Options(opts)
+}
+
+// EnableClientHelloDump is a global wrapper methods which delegated
+// to the default client's Client.EnableClientHelloDump.
+func EnableClientHelloDump(w io.Writer) *Client {
+	return defaultClient.EnableClientHelloDump(w)
+}
+
+// DisableClientHelloDump is a global wrapper methods which delegated
+// to the default client's Client.DisableClientHelloDump.
+func DisableClientHelloDump() *Client {
+	return defaultClient.DisableClientHelloDump()
+}
+
+// EnableTLSKeyLog is a global wrapper methods which delegated
+// to the default client's Client.EnableTLSKeyLog.
+func EnableTLSKeyLog(path string) *Client {
+	return defaultClient.EnableTLSKeyLog(path)
+}
+
+// DisableTLSKeyLog is a global wrapper methods which delegated
+// to the default client's Client.DisableTLSKeyLog.
+func DisableTLSKeyLog() *Client {
+	return defaultClient.DisableTLSKeyLog()
+}
+
+// SetCertificatePins is a global wrapper methods which delegated
+// to the default client's Client.SetCertificatePins.
+func SetCertificatePins(pins map[string][]string) *Client {
+	return defaultClient.SetCertificatePins(pins)
+}
+
+// SetCommonSNI is a global wrapper methods which delegated
+// to the default client's Client.SetCommonSNI.
+func SetCommonSNI(host string) *Client {
+	return defaultClient.SetCommonSNI(host)
+}
+
 // SetCommonHeader is a global wrapper methods which delegated
 // to the default client's Client.SetCommonHeader.
 func SetCommonHeader(key, value string) *Client {
 	return defaultClient.SetCommonHeader(key, value)
 }
 
+// SetCommonHeaderFunc is a global wrapper methods which delegated
+// to the default client's Client.SetCommonHeaderFunc.
+func SetCommonHeaderFunc(key string, fn HeaderFunc) *Client {
+	return defaultClient.SetCommonHeaderFunc(key, fn)
+}
+
 // SetCommonHeaderOrder is a global wrapper methods which delegated
 // to the default client's Client.SetCommonHeaderOrder.
 func SetCommonHeaderOrder(keys ...string) *Client {
@@ -453,6 +645,12 @@ func SetHTTP2PriorityFrames(frames ...http2.PriorityFrame) *Client {
 	return defaultClient.SetHTTP2PriorityFrames(frames...)
 }
 
+// SetHTTP2HeaderTableSize is a global wrapper methods which delegated
+// to the default client's Client.SetHTTP2HeaderTableSize.
+func SetHTTP2HeaderTableSize(size uint32) *Client {
+	return defaultClient.SetHTTP2HeaderTableSize(size)
+}
+
 // SetHTTP2MaxHeaderListSize is a global wrapper methods which delegated
 // to the default client's Client.SetHTTP2MaxHeaderListSize.
 func SetHTTP2MaxHeaderListSize(max uint32) *Client {
@@ -489,16 +687,34 @@ func ImpersonateChrome() *Client {
 	return defaultClient.ImpersonateChrome()
 }
 
-// ImpersonateChrome is a global wrapper methods which delegated
-// to the default client's Client.ImpersonateChrome.
+// ImpersonateFirefox is a global wrapper methods which delegated
+// to the default client's Client.ImpersonateFirefox.
 func ImpersonateFirefox() *Client {
 	return defaultClient.ImpersonateFirefox()
 }
 
-// ImpersonateChrome is a global wrapper methods which delegated
-// to the default client's Client.ImpersonateChrome.
+// ImpersonateSafari is a global wrapper methods which delegated
+// to the default client's Client.ImpersonateSafari.
 func ImpersonateSafari() *Client {
-	return defaultClient.ImpersonateFirefox()
+	return defaultClient.ImpersonateSafari()
+}
+
+// ImpersonateEdge is a global wrapper methods which delegated
+// to the default client's Client.ImpersonateEdge.
+func ImpersonateEdge() *Client {
+	return defaultClient.ImpersonateEdge()
+}
+
+// ImpersonateIOS is a global wrapper methods which delegated
+// to the default client's Client.ImpersonateIOS.
+func ImpersonateIOS() *Client {
+	return defaultClient.ImpersonateIOS()
+}
+
+// ImpersonateOkHttp is a global wrapper methods which delegated
+// to the default client's Client.ImpersonateOkHttp.
+func ImpersonateOkHttp() *Client {
+	return defaultClient.ImpersonateOkHttp()
 }
 
 // SetCommonContentType is a global wrapper methods which delegated
@@ -525,6 +741,66 @@ func SetProxy(proxy func(*http.Request) (*url.URL, error)) *Client {
 	return defaultClient.SetProxy(proxy)
 }
 
+// OnDNSStart is a global wrapper methods which delegated
+// to the default client's Client.OnDNSStart.
+func OnDNSStart(fn func(info DNSStartInfo)) *Client {
+	return defaultClient.OnDNSStart(fn)
+}
+
+// OnDNSDone is a global wrapper methods which delegated
+// to the default client's Client.OnDNSDone.
+func OnDNSDone(fn func(info DNSDoneInfo)) *Client {
+	return defaultClient.OnDNSDone(fn)
+}
+
+// OnConnectStart is a global wrapper methods which delegated
+// to the default client's Client.OnConnectStart.
+func OnConnectStart(fn func(info ConnectStartInfo)) *Client {
+	return defaultClient.OnConnectStart(fn)
+}
+
+// OnConnectDone is a global wrapper methods which delegated
+// to the default client's Client.OnConnectDone.
+func OnConnectDone(fn func(info ConnectDoneInfo)) *Client {
+	return defaultClient.OnConnectDone(fn)
+}
+
+// OnTLSHandshakeDone is a global wrapper methods which delegated
+// to the default client's Client.OnTLSHandshakeDone.
+func OnTLSHandshakeDone(fn func(info TLSHandshakeDoneInfo)) *Client {
+	return defaultClient.OnTLSHandshakeDone(fn)
+}
+
+// AddObserver is a global wrapper methods which delegated
+// to the default client's Client.AddObserver.
+func AddObserver(o Observer) *Client {
+	return defaultClient.AddObserver(o)
+}
+
+// EnableScheduler is a global wrapper methods which delegated
+// to the default client's Client.EnableScheduler.
+func EnableScheduler(maxConcurrent int) *Client {
+	return defaultClient.EnableScheduler(maxConcurrent)
+}
+
+// DoBatch is a global wrapper methods which delegated
+// to the default client's Client.DoBatch.
+func DoBatch(ctx context.Context, reqs []*Request, opts *BatchOptions) ([]*Response, error) {
+	return defaultClient.DoBatch(ctx, reqs, opts)
+}
+
+// DisableScheduler is a global wrapper methods which delegated
+// to the default client's Client.DisableScheduler.
+func DisableScheduler() *Client {
+	return defaultClient.DisableScheduler()
+}
+
+// ForHost is a global wrapper methods which delegated
+// to the default client's Client.ForHost.
+func ForHost(pattern string) *HostConfig {
+	return defaultClient.ForHost(pattern)
+}
+
 // OnBeforeRequest is a global wrapper methods which delegated
 // to the default client's Client.OnBeforeRequest.
 func OnBeforeRequest(m RequestMiddleware) *Client {
@@ -537,6 +813,30 @@ func OnAfterResponse(m ResponseMiddleware) *Client {
 	return defaultClient.OnAfterResponse(m)
 }
 
+// SetProxyPool is a global wrapper methods which delegated
+// to the default client's Client.SetProxyPool.
+func SetProxyPool(pool ProxyPool) *Client {
+	return defaultClient.SetProxyPool(pool)
+}
+
+// SetHTTP3Proxy is a global wrapper methods which delegated
+// to the default client's Client.SetHTTP3Proxy.
+func SetHTTP3Proxy(proxyURL string) *Client {
+	return defaultClient.SetHTTP3Proxy(proxyURL)
+}
+
+// SetProxyBypass is a global wrapper methods which delegated
+// to the default client's Client.SetProxyBypass.
+func SetProxyBypass(patterns ...string) *Client {
+	return defaultClient.SetProxyBypass(patterns...)
+}
+
+// SetProxyFromEnvironment is a global wrapper methods which delegated
+// to the default client's Client.SetProxyFromEnvironment.
+func SetProxyFromEnvironment() *Client {
+	return defaultClient.SetProxyFromEnvironment()
+}
+
 // SetProxyURL is a global wrapper methods which delegated
 // to the default client's Client.SetProxyURL.
 func SetProxyURL(proxyUrl string) *Client {
@@ -573,6 +873,12 @@ func ClearCookies() *Client {
 	return defaultClient.ClearCookies()
 }
 
+// SetJSONEngine is a global wrapper methods which delegated
+// to the default client's Client.SetJSONEngine.
+func SetJSONEngine(engine *JSONEngine) *Client {
+	return defaultClient.SetJSONEngine(engine)
+}
+
 // SetJsonMarshal is a global wrapper methods which delegated
 // to the default client's Client.SetJsonMarshal.
 func SetJsonMarshal(fn func(v interface{}) ([]byte, error)) *Client {
@@ -591,6 +897,12 @@ func SetXmlMarshal(fn func(v interface{}) ([]byte, error)) *Client {
 	return defaultClient.SetXmlMarshal(fn)
 }
 
+// SetResponseDecoder is a global wrapper methods which delegated
+// to the default client's Client.SetResponseDecoder.
+func SetResponseDecoder(contentType string, fn func(data []byte, v interface{}) error) *Client {
+	return defaultClient.SetResponseDecoder(contentType, fn)
+}
+
 // SetXmlUnmarshal is a global wrapper methods which delegated
 // to the default client's Client.SetXmlUnmarshal.
 func SetXmlUnmarshal(fn func(data []byte, v interface{}) error) *Client {
@@ -603,6 +915,102 @@ func SetDialTLS(fn func(ctx context.Context, network, addr string) (net.Conn, er
 	return defaultClient.SetDialTLS(fn)
 }
 
+// SetDNSServers is a global wrapper methods which delegated
+// to the default client's Client.SetDNSServers.
+func SetDNSServers(servers ...string) *Client {
+	return defaultClient.SetDNSServers(servers...)
+}
+
+// SetDNSResolver is a global wrapper methods which delegated
+// to the default client's Client.SetDNSResolver.
+func SetDNSResolver(resolver *net.Resolver) *Client {
+	return defaultClient.SetDNSResolver(resolver)
+}
+
+// SetDialTimeout is a global wrapper methods which delegated
+// to the default client's Client.SetDialTimeout.
+func SetDialTimeout(d time.Duration) *Client {
+	return defaultClient.SetDialTimeout(d)
+}
+
+// SetTCPKeepAlive is a global wrapper methods which delegated
+// to the default client's Client.SetTCPKeepAlive.
+func SetTCPKeepAlive(interval time.Duration) *Client {
+	return defaultClient.SetTCPKeepAlive(interval)
+}
+
+// SetTCPNoDelay is a global wrapper methods which delegated
+// to the default client's Client.SetTCPNoDelay.
+func SetTCPNoDelay(noDelay bool) *Client {
+	return defaultClient.SetTCPNoDelay(noDelay)
+}
+
+// SetForbiddenNetworks is a global wrapper methods which delegated
+// to the default client's Client.SetForbiddenNetworks.
+func SetForbiddenNetworks(cidrs ...string) *Client {
+	return defaultClient.SetForbiddenNetworks(cidrs...)
+}
+
+// SetAllowedNetworks is a global wrapper methods which delegated
+// to the default client's Client.SetAllowedNetworks.
+func SetAllowedNetworks(cidrs ...string) *Client {
+	return defaultClient.SetAllowedNetworks(cidrs...)
+}
+
+// EnableTCPFastOpen is a global wrapper methods which delegated
+// to the default client's Client.EnableTCPFastOpen.
+func EnableTCPFastOpen() *Client {
+	return defaultClient.EnableTCPFastOpen()
+}
+
+// DisableTCPFastOpen is a global wrapper methods which delegated
+// to the default client's Client.DisableTCPFastOpen.
+func DisableTCPFastOpen() *Client {
+	return defaultClient.DisableTCPFastOpen()
+}
+
+// EnableMultipathTCP is a global wrapper methods which delegated
+// to the default client's Client.EnableMultipathTCP.
+func EnableMultipathTCP() *Client {
+	return defaultClient.EnableMultipathTCP()
+}
+
+// DisableMultipathTCP is a global wrapper methods which delegated
+// to the default client's Client.DisableMultipathTCP.
+func DisableMultipathTCP() *Client {
+	return defaultClient.DisableMultipathTCP()
+}
+
+// SetLocalAddr is a global wrapper methods which delegated
+// to the default client's Client.SetLocalAddr.
+func SetLocalAddr(ip string) *Client {
+	return defaultClient.SetLocalAddr(ip)
+}
+
+// SetInterface is a global wrapper methods which delegated
+// to the default client's Client.SetInterface.
+func SetInterface(name string) *Client {
+	return defaultClient.SetInterface(name)
+}
+
+// SetHosts is a global wrapper methods which delegated
+// to the default client's Client.SetHosts.
+func SetHosts(hosts map[string]string) *Client {
+	return defaultClient.SetHosts(hosts)
+}
+
+// SetAddrSelector is a global wrapper methods which delegated
+// to the default client's Client.SetAddrSelector.
+func SetAddrSelector(fn AddrSelectorFunc) *Client {
+	return defaultClient.SetAddrSelector(fn)
+}
+
+// SetDNSCache is a global wrapper methods which delegated
+// to the default client's Client.SetDNSCache.
+func SetDNSCache(cache *DNSCache) *Client {
+	return defaultClient.SetDNSCache(cache)
+}
+
 // SetDial is a global wrapper methods which delegated
 // to the default client's Client.SetDial.
 func SetDial(fn func(ctx context.Context, network, addr string) (net.Conn, error)) *Client {
@@ -615,6 +1023,84 @@ func SetTLSHandshakeTimeout(timeout time.Duration) *Client {
 	return defaultClient.SetTLSHandshakeTimeout(timeout)
 }
 
+// SetMaxIdleConns is a global wrapper methods which delegated
+// to the default client's Client.SetMaxIdleConns.
+func SetMaxIdleConns(max int) *Client {
+	return defaultClient.SetMaxIdleConns(max)
+}
+
+// SetMaxIdleConnsPerHost is a global wrapper methods which delegated
+// to the default client's Client.SetMaxIdleConnsPerHost.
+func SetMaxIdleConnsPerHost(max int) *Client {
+	return defaultClient.SetMaxIdleConnsPerHost(max)
+}
+
+// SetMaxConnsPerHost is a global wrapper methods which delegated
+// to the default client's Client.SetMaxConnsPerHost.
+func SetMaxConnsPerHost(max int) *Client {
+	return defaultClient.SetMaxConnsPerHost(max)
+}
+
+// SetResponseHeaderTimeout is a global wrapper methods which delegated
+// to the default client's Client.SetResponseHeaderTimeout.
+func SetResponseHeaderTimeout(timeout time.Duration) *Client {
+	return defaultClient.SetResponseHeaderTimeout(timeout)
+}
+
+// SetExpectContinueTimeout is a global wrapper methods which delegated
+// to the default client's Client.SetExpectContinueTimeout.
+func SetExpectContinueTimeout(timeout time.Duration) *Client {
+	return defaultClient.SetExpectContinueTimeout(timeout)
+}
+
+// SetIdleConnTimeout is a global wrapper methods which delegated
+// to the default client's Client.SetIdleConnTimeout.
+func SetIdleConnTimeout(timeout time.Duration) *Client {
+	return defaultClient.SetIdleConnTimeout(timeout)
+}
+
+// SetMaxIdleConnTimeout is a global wrapper methods which delegated
+// to the default client's Client.SetMaxIdleConnTimeout.
+func SetMaxIdleConnTimeout(timeout time.Duration) *Client {
+	return defaultClient.SetMaxIdleConnTimeout(timeout)
+}
+
+// SetMaxConnAge is a global wrapper methods which delegated
+// to the default client's Client.SetMaxConnAge.
+func SetMaxConnAge(age time.Duration) *Client {
+	return defaultClient.SetMaxConnAge(age)
+}
+
+// EnableWarmPool is a global wrapper methods which delegated
+// to the default client's Client.EnableWarmPool.
+func EnableWarmPool(origins []string, opts *WarmPoolOptions) *Client {
+	return defaultClient.EnableWarmPool(origins, opts)
+}
+
+// DisableWarmPool is a global wrapper methods which delegated
+// to the default client's Client.DisableWarmPool.
+func DisableWarmPool() *Client {
+	return defaultClient.DisableWarmPool()
+}
+
+// RegisterProtocol is a global wrapper methods which delegated
+// to the default client's Client.RegisterProtocol.
+func RegisterProtocol(scheme string, rt http.RoundTripper) *Client {
+	return defaultClient.RegisterProtocol(scheme, rt)
+}
+
+// EnableFileScheme is a global wrapper methods which delegated
+// to the default client's Client.EnableFileScheme.
+func EnableFileScheme(root string) *Client {
+	return defaultClient.EnableFileScheme(root)
+}
+
+// DisableFileScheme is a global wrapper methods which delegated
+// to the default client's Client.DisableFileScheme.
+func DisableFileScheme() *Client {
+	return defaultClient.DisableFileScheme()
+}
+
 // EnableForceHTTP1 is a global wrapper methods which delegated
 // to the default client's Client.EnableForceHTTP1.
 func EnableForceHTTP1() *Client {
@@ -735,6 +1221,24 @@ func SetTLSFingerprint(clientHelloID utls.ClientHelloID) *Client {
 	return defaultClient.SetTLSFingerprint(clientHelloID)
 }
 
+// EnableTLSExtensionShuffle is a global wrapper methods which delegated
+// to the default client's Client.EnableTLSExtensionShuffle.
+func EnableTLSExtensionShuffle() *Client {
+	return defaultClient.EnableTLSExtensionShuffle()
+}
+
+// DisableTLSExtensionShuffle is a global wrapper methods which delegated
+// to the default client's Client.DisableTLSExtensionShuffle.
+func DisableTLSExtensionShuffle() *Client {
+	return defaultClient.DisableTLSExtensionShuffle()
+}
+
+// SetTLSFingerprintFromBytes is a global wrapper methods which delegated
+// to the default client's Client.SetTLSFingerprintFromBytes.
+func SetTLSFingerprintFromBytes(raw []byte) *Client {
+	return defaultClient.SetTLSFingerprintFromBytes(raw)
+}
+
 // SetTLSFingerprintRandomized is a global wrapper methods which delegated
 // to the default client's Client.SetTLSFingerprintRandomized.
 func SetTLSFingerprintRandomized() *Client {
@@ -789,6 +1293,30 @@ func SetTLSFingerprintSafari() *Client {
 	return defaultClient.SetTLSFingerprintSafari()
 }
 
+// EnableECH is a global wrapper methods which delegated
+// to the default client's Client.EnableECH.
+func EnableECH(resolver ECHConfigResolver) *Client {
+	return defaultClient.EnableECH(resolver)
+}
+
+// DisableECH is a global wrapper methods which delegated
+// to the default client's Client.DisableECH.
+func DisableECH() *Client {
+	return defaultClient.DisableECH()
+}
+
+// SetQUICFingerprint is a global wrapper methods which delegated
+// to the default client's Client.SetQUICFingerprint.
+func SetQUICFingerprint(fp *QUICFingerprint) *Client {
+	return defaultClient.SetQUICFingerprint(fp)
+}
+
+// SetJa3ForHTTP3 is a global wrapper methods which delegated
+// to the default client's Client.SetJa3ForHTTP3.
+func SetJa3ForHTTP3(ja3Str string) *Client {
+	return defaultClient.SetJa3ForHTTP3(ja3Str)
+}
+
 // GetClient is a global wrapper methods which delegated
 // to the default client's Client.GetClient.
 func GetClient() *http.Client {
@@ -806,3 +1334,21 @@ func NewRequest() *Request {
 func R() *Request {
 	return defaultClient.R()
 }
+
+// SetChallengeSolver is a global wrapper methods which delegated
+// to the default client's Client.SetChallengeSolver.
+func SetChallengeSolver(solver ChallengeSolver, opts *ChallengeOptions) *Client {
+	return defaultClient.SetChallengeSolver(solver, opts)
+}
+
+// SetSchedule is a global wrapper methods which delegated
+// to the default client's Client.SetSchedule.
+func SetSchedule(s *Schedule) *Client {
+	return defaultClient.SetSchedule(s)
+}
+
+// SetResponseBodyStreamTransformer is a global wrapper methods which delegated
+// to the default client's Client.SetResponseBodyStreamTransformer.
+func SetResponseBodyStreamTransformer(fn func(body io.Reader, info ResponseBodyInfo, req *Request, resp *Response) (io.Reader, error)) *Client {
+	return defaultClient.SetResponseBodyStreamTransformer(fn)
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/luoxk/restys"
+)
+
+// harRecorder records the request attempts as the entries of HAR 1.2
+// (http://www.softwareishard.com/blog/har-12-spec/), which implements
+// restys.DumpFormatter.
+type harRecorder struct {
+	mu      sync.Mutex
+	entries []harEntry
+}
+
+type harLog struct {
+	Log struct {
+		Version string     `json:"version"`
+		Creator harCreator `json:"creator"`
+		Entries []harEntry `json:"entries"`
+	} `json:"log"`
+}
+
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harEntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+	ServerIPAddress string      `json:"serverIPAddress,omitempty"`
+	Comment         string      `json:"comment,omitempty"`
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Headers     []harNameValue `json:"headers"`
+	QueryString []harNameValue `json:"queryString"`
+	Cookies     []harNameValue `json:"cookies"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+	PostData    *harPostData   `json:"postData,omitempty"`
+}
+
+type harPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type harResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Headers     []harNameValue `json:"headers"`
+	Cookies     []harNameValue `json:"cookies"`
+	Content     harContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+type harTimings struct {
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	SSL     float64 `json:"ssl"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+func harHeaders(h http.Header) []harNameValue {
+	nvs := []harNameValue{}
+	for k, vs := range h {
+		for _, v := range vs {
+			nvs = append(nvs, harNameValue{Name: k, Value: v})
+		}
+	}
+	sort.Slice(nvs, func(i, j int) bool {
+		return nvs[i].Name < nvs[j].Name
+	})
+	return nvs
+}
+
+func harCookies(cookies []*http.Cookie) []harNameValue {
+	nvs := []harNameValue{}
+	for _, c := range cookies {
+		nvs = append(nvs, harNameValue{Name: c.Name, Value: c.Value})
+	}
+	return nvs
+}
+
+func harQueryString(rawURL string) []harNameValue {
+	nvs := []harNameValue{}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nvs
+	}
+	for k, vs := range u.Query() {
+		for _, v := range vs {
+			nvs = append(nvs, harNameValue{Name: k, Value: v})
+		}
+	}
+	sort.Slice(nvs, func(i, j int) bool {
+		return nvs[i].Name < nvs[j].Name
+	})
+	return nvs
+}
+
+// harDuration converts d to milliseconds, -1 means not available.
+func harDuration(d time.Duration) float64 {
+	if d <= 0 {
+		return -1
+	}
+	return float64(d) / float64(time.Millisecond)
+}
+
+// FormatDump records the entry and dumps nothing.
+func (h *harRecorder) FormatDump(e *restys.DumpEntry) []byte {
+	entry := harEntry{
+		StartedDateTime: e.Time.Format(time.RFC3339Nano),
+		Time:            float64(e.Timings.Total) / float64(time.Millisecond),
+		Request: harRequest{
+			Method:      e.Method,
+			URL:         e.URL,
+			HTTPVersion: e.Proto,
+			Headers:     harHeaders(e.RequestHeader),
+			QueryString: harQueryString(e.URL),
+			Cookies:     harCookies((&http.Request{Header: e.RequestHeader}).Cookies()),
+			HeadersSize: -1,
+			BodySize:    len(e.RequestBody),
+		},
+		Response: harResponse{
+			Status:      e.StatusCode,
+			StatusText:  http.StatusText(e.StatusCode),
+			HTTPVersion: e.Proto,
+			Headers:     harHeaders(e.ResponseHeader),
+			Cookies:     harCookies((&http.Response{Header: e.ResponseHeader}).Cookies()),
+			RedirectURL: e.ResponseHeader.Get("Location"),
+			HeadersSize: -1,
+			BodySize:    len(e.ResponseBody),
+			Content: harContent{
+				Size:     len(e.ResponseBody),
+				MimeType: e.ResponseHeader.Get("Content-Type"),
+			},
+		},
+		Timings: harTimings{
+			DNS:     harDuration(e.Timings.DNSLookup),
+			Connect: harDuration(e.Timings.TCPConnect),
+			SSL:     harDuration(e.Timings.TLSHandshake),
+			Send:    0,
+			Wait:    harDuration(e.Timings.FirstByte),
+			Receive: harDuration(e.Timings.BodyRead),
+		},
+		Comment: e.Error,
+	}
+	if entry.Response.HTTPVersion == "" {
+		entry.Request.HTTPVersion = "HTTP/1.1"
+		entry.Response.HTTPVersion = "HTTP/1.1"
+	}
+	if entry.Timings.Wait < 0 {
+		entry.Timings.Wait = entry.Time
+	}
+	if entry.Timings.Receive < 0 {
+		entry.Timings.Receive = 0
+	}
+	if len(e.RequestBody) > 0 {
+		entry.Request.PostData = &harPostData{
+			MimeType: e.RequestHeader.Get("Content-Type"),
+			Text:     string(e.RequestBody),
+		}
+	}
+	if body := e.ResponseBody; len(body) > 0 {
+		if utf8.Valid(body) {
+			entry.Response.Content.Text = string(body)
+		} else {
+			entry.Response.Content.Text = base64.StdEncoding.EncodeToString(body)
+			entry.Response.Content.Encoding = "base64"
+		}
+	}
+	if e.RemoteAddr != "" {
+		if host, _, err := net.SplitHostPort(e.RemoteAddr); err == nil {
+			entry.ServerIPAddress = host
+		}
+	}
+	h.mu.Lock()
+	h.entries = append(h.entries, entry)
+	h.mu.Unlock()
+	return nil
+}
+
+func (h *harRecorder) writeFile(filename string) error {
+	var l harLog
+	l.Log.Version = "1.2"
+	l.Log.Creator = harCreator{Name: "restys", Version: "1.0"}
+	h.mu.Lock()
+	l.Log.Entries = append([]harEntry{}, h.entries...)
+	h.mu.Unlock()
+	b, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, b, 0o644)
+}
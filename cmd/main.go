@@ -1,28 +1,338 @@
+// Command restys is a curl-like CLI built on restys, which makes the
+// capabilities of the package (e.g. TLS and HTTP/2 fingerprint,
+// impersonation, retries, dump and HAR export) usable for quick one-off
+// reproductions without writing Go.
+//
+// Usage:
+//
+//	restys [flags] URL
+//
+// For example:
+//
+//	restys -impersonate chrome -v https://tls.browserleaks.com/json
+//	restys -X POST -H "Content-Type: application/json" -d '{"a":1}' https://httpbin.org/post
+//	restys -ja3 "771,4865-..." -akamai "1:65536,...|15663106|0|m,a,s,p" -har out.har https://example.com
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
-	"log"
-	"restys"
+	"io"
+	"net/http"
+	"os"
+	"strings"
 	"time"
+
+	"github.com/luoxk/restys"
 )
 
-func tc() *restys.Client {
-	return restys.C().
-		EnableInsecureSkipVerify()
+// headerFlags is the repeatable -H flag.
+type headerFlags []string
+
+func (h *headerFlags) String() string {
+	return strings.Join(*h, ", ")
 }
 
-func main() {
-	client := tc().
-		SetCommonRetryCount(2).
-		SetCommonRetryBackoffInterval(1*time.Second, 5*time.Second).
-		ImpersonateChrome().
-		SetJa3WithStr("771,4865-4866-4867-49195-49199-49196-49200-52393-52392-49171-49172-156-157-47-53,51-16-11-10-18-45-35-17513-27-23-0-43-65037-65281-13-5,4588-29-23-24,0").
-		SetAkamaiWithStr("1:65536,2:0,4:6291456,6:262144|15663106|0|m,a,s,p")
-
-	res, err := client.R().Get("https://tls.browserleaks.com/json")
+func (h *headerFlags) Set(v string) error {
+	if !strings.Contains(v, ":") {
+		return fmt.Errorf("invalid header %q, expected \"Name: value\"", v)
+	}
+	*h = append(*h, v)
+	return nil
+}
+
+type options struct {
+	method      string
+	headers     headerFlags
+	data        string
+	cookie      string
+	userAgent   string
+	proxy       string
+	ja3         string
+	akamai      string
+	impersonate string
+	retry       int
+	maxTime     time.Duration
+	timeout     time.Duration
+	maxRedirs   int
+	output      string
+	include     bool
+	fail        bool
+	insecure    bool
+	http1       bool
+	http2       bool
+	http3       bool
+	verbose     bool
+	dump        bool
+	dumpFile    string
+	dumpFormat  string
+	dumpFrames  bool
+	noRedact    bool
+	har         string
+	url         string
+}
+
+func parseFlags(args []string, stderr io.Writer) (*options, error) {
+	o := &options{}
+	fs := flag.NewFlagSet("restys", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&o.method, "X", "", "request method (default GET, or POST if -d is set)")
+	fs.Var(&o.headers, "H", "request header \"Name: value\" (repeatable)")
+	fs.StringVar(&o.data, "d", "", "request body, \"@file\" reads it from file, \"@-\" from stdin")
+	fs.StringVar(&o.cookie, "b", "", "cookies \"name=value; name2=value2\"")
+	fs.StringVar(&o.userAgent, "A", "", "User-Agent header")
+	fs.StringVar(&o.proxy, "x", "", "proxy URL, e.g. http://127.0.0.1:8080 or socks5://127.0.0.1:1080")
+	fs.StringVar(&o.ja3, "ja3", "", "JA3 string of the TLS fingerprint")
+	fs.StringVar(&o.akamai, "akamai", "", "Akamai string of the HTTP/2 fingerprint")
+	fs.StringVar(&o.impersonate, "impersonate", "", "impersonate browser: chrome, edge, firefox or safari")
+	fs.IntVar(&o.retry, "retry", 0, "max retry count on error")
+	fs.DurationVar(&o.maxTime, "m", 0, "total time limit including retries and redirects, e.g. 10s")
+	fs.DurationVar(&o.timeout, "timeout", 0, "timeout of each attempt, e.g. 30s (default 2m)")
+	fs.IntVar(&o.maxRedirs, "max-redirs", -1, "max number of redirects, 0 disables redirect")
+	fs.StringVar(&o.output, "o", "", "write response body to file instead of stdout")
+	fs.BoolVar(&o.include, "i", false, "include response status and headers in the output")
+	fs.BoolVar(&o.fail, "f", false, "fail with exit code 22 on HTTP errors (status >= 400)")
+	fs.BoolVar(&o.insecure, "k", false, "skip TLS certificate verification")
+	fs.BoolVar(&o.http1, "http1.1", false, "force HTTP/1.1")
+	fs.BoolVar(&o.http2, "http2", false, "force HTTP/2")
+	fs.BoolVar(&o.http3, "http3", false, "force HTTP/3")
+	fs.BoolVar(&o.verbose, "v", false, "dump the request and response like curl -v to stderr")
+	fs.BoolVar(&o.dump, "dump", false, "dump the raw request and response to stderr")
+	fs.StringVar(&o.dumpFile, "dump-file", "", "dump the raw request and response to file")
+	fs.StringVar(&o.dumpFormat, "dump-format", "text", "dump format: text or json")
+	fs.BoolVar(&o.dumpFrames, "dump-h2-frames", false, "dump the HTTP/2 frames as well")
+	fs.BoolVar(&o.noRedact, "no-redact", false, "do not redact the sensitive headers in dump and HAR")
+	fs.StringVar(&o.har, "har", "", "export the request attempts to HAR file")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: restys [flags] URL")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return nil, errors.New("exactly one URL is required")
+	}
+	o.url = fs.Arg(0)
+	return o, nil
+}
+
+func readData(data string, stdin io.Reader) ([]byte, error) {
+	if !strings.HasPrefix(data, "@") {
+		return []byte(data), nil
+	}
+	if data == "@-" {
+		return io.ReadAll(stdin)
+	}
+	return os.ReadFile(data[1:])
+}
+
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+func newClient(o *options, stderr io.Writer) (*restys.Client, error) {
+	c := restys.C()
+	switch strings.ToLower(o.impersonate) {
+	case "":
+	case "chrome":
+		c.ImpersonateChrome()
+	case "edge":
+		c.ImpersonateEdge()
+	case "firefox":
+		c.ImpersonateFirefox()
+	case "safari":
+		c.ImpersonateSafari()
+	default:
+		return nil, fmt.Errorf("unsupported impersonate browser %q", o.impersonate)
+	}
+	if o.ja3 != "" {
+		c.SetJa3WithStr(o.ja3)
+	}
+	if o.akamai != "" {
+		c.SetAkamaiWithStr(o.akamai)
+	}
+	if o.proxy != "" {
+		c.SetProxyURL(o.proxy)
+	}
+	if o.insecure {
+		c.EnableInsecureSkipVerify()
+	}
+	switch {
+	case o.http1:
+		c.EnableForceHTTP1()
+	case o.http2:
+		c.EnableForceHTTP2()
+	case o.http3:
+		c.EnableForceHTTP3()
+	}
+	if o.timeout > 0 {
+		c.SetTimeout(o.timeout)
+	}
+	if o.maxRedirs == 0 {
+		c.SetRedirectPolicy(restys.NoRedirectPolicy())
+	} else if o.maxRedirs > 0 {
+		c.SetRedirectPolicy(restys.MaxRedirectPolicy(o.maxRedirs))
+	}
+	if o.retry > 0 {
+		c.SetCommonRetryCount(o.retry).
+			SetCommonRetryBackoffInterval(time.Second, 5*time.Second)
+	}
+
+	var dumpOutput io.Writer
+	switch {
+	case o.dumpFile != "":
+		f, err := os.Create(o.dumpFile)
+		if err != nil {
+			return nil, err
+		}
+		dumpOutput = f
+	case o.verbose || o.dump:
+		dumpOutput = stderr
+	}
+	if dumpOutput != nil {
+		opt := &restys.DumpOptions{
+			Output:         dumpOutput,
+			RequestHeader:  true,
+			RequestBody:    true,
+			ResponseHeader: true,
+			ResponseBody:   true,
+			HTTP2Frames:    o.dumpFrames,
+		}
+		if o.noRedact {
+			opt.RedactHeaders = []string{}
+		}
+		switch o.dumpFormat {
+		case "text":
+		case "json":
+			opt.Format = restys.DumpFormatJSON
+		default:
+			return nil, fmt.Errorf("unsupported dump format %q", o.dumpFormat)
+		}
+		if o.verbose && opt.Format == restys.DumpFormatText {
+			opt.ResponseBody = false // the body is written to the output
+			opt.Formatter = restys.CurlDumpFormatter{Color: isTerminal(dumpOutput)}
+		}
+		c.SetCommonDumpOptions(opt).EnableDumpAll()
+		if o.verbose {
+			c.EnableTraceAll()
+		}
+	}
+	return c, nil
+}
+
+func newRequest(c *restys.Client, o *options, stdin io.Reader, har *harRecorder) (*restys.Request, error) {
+	r := c.R()
+	for _, h := range o.headers {
+		k, v, _ := strings.Cut(h, ":")
+		r.SetHeader(strings.TrimSpace(k), strings.TrimSpace(v))
+	}
+	if o.userAgent != "" {
+		r.SetHeader("User-Agent", o.userAgent)
+	}
+	if o.cookie != "" {
+		r.SetHeader("Cookie", o.cookie)
+	}
+	method := o.method
+	if o.data != "" {
+		body, err := readData(o.data, stdin)
+		if err != nil {
+			return nil, err
+		}
+		r.SetBodyBytes(body)
+		if method == "" {
+			method = http.MethodPost
+		}
+	}
+	if method == "" {
+		method = http.MethodGet
+	}
+	r.Method = strings.ToUpper(method)
+	if o.maxTime > 0 {
+		r.SetTotalDeadline(o.maxTime)
+	}
+	if o.output != "" {
+		r.SetOutputFile(o.output)
+	}
+	if har != nil {
+		opt := &restys.DumpOptions{
+			RequestHeader:  true,
+			RequestBody:    true,
+			ResponseHeader: true,
+			ResponseBody:   true,
+			Formatter:      har,
+		}
+		if o.noRedact {
+			opt.RedactHeaders = []string{}
+		}
+		r.SetDumpOptions(opt).EnableDump().EnableTrace()
+	}
+	return r, nil
+}
+
+func writeHead(w io.Writer, resp *restys.Response) {
+	fmt.Fprintf(w, "%s %s\r\n", resp.Proto, resp.Status)
+	resp.Header.Write(w)
+	io.WriteString(w, "\r\n")
+}
+
+// run executes the CLI and returns the exit code, the codes follow curl
+// where possible: 2 for usage error, 22 for HTTP error with -f, and 1 for
+// other errors.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	o, err := parseFlags(args, stderr)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		fmt.Fprintln(stderr, "restys:", err)
+		return 2
+	}
+	c, err := newClient(o, stderr)
+	if err != nil {
+		fmt.Fprintln(stderr, "restys:", err)
+		return 2
+	}
+	var har *harRecorder
+	if o.har != "" {
+		har = &harRecorder{}
+	}
+	r, err := newRequest(c, o, stdin, har)
 	if err != nil {
-		log.Println(err)
+		fmt.Fprintln(stderr, "restys:", err)
+		return 2
+	}
+
+	resp, _ := r.Send(r.Method, o.url)
+	if har != nil {
+		if err := har.writeFile(o.har); err != nil {
+			fmt.Fprintln(stderr, "restys: failed to write HAR:", err)
+		}
 	}
-	fmt.Println(res.String())
+	if resp.Err != nil {
+		fmt.Fprintln(stderr, "restys:", resp.Err)
+		return 1
+	}
+	if o.include {
+		writeHead(stdout, resp)
+	}
+	if o.output == "" {
+		stdout.Write(resp.Bytes())
+	}
+	if o.fail && resp.StatusCode >= 400 {
+		fmt.Fprintf(stderr, "restys: the requested URL returned error: %s\n", resp.Status)
+		return 22
+	}
+	return 0
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
 }
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/luoxk/restys/internal/tests"
+)
+
+func newTestServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/404" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Method", r.Method)
+		w.Write([]byte(r.Header.Get("X-Foo") + "|" + string(body)))
+	}))
+}
+
+func TestRun(t *testing.T) {
+	server := newTestServer()
+	defer server.Close()
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"-i", "-H", "X-Foo: bar", "-d", "hello", server.URL}, nil, &stdout, &stderr)
+	tests.AssertEqual(t, 0, code)
+	out := stdout.String()
+	tests.AssertEqual(t, true, strings.HasPrefix(out, "HTTP/1.1 200 OK\r\n"))
+	tests.AssertContains(t, out, "x-method: post", true)
+	tests.AssertEqual(t, true, strings.HasSuffix(out, "bar|hello"))
+
+	stdout.Reset()
+	stderr.Reset()
+	code = run([]string{"-f", "-v", server.URL + "/404"}, nil, &stdout, &stderr)
+	tests.AssertEqual(t, 22, code)
+	tests.AssertContains(t, stderr.String(), "> get /404", true)
+	tests.AssertContains(t, stderr.String(), "< http/1.1 404", true)
+
+	tests.AssertEqual(t, 2, run([]string{"-impersonate", "netscape", server.URL}, nil, &stdout, &stderr))
+	tests.AssertEqual(t, 2, run([]string{}, nil, &stdout, &stderr))
+}
+
+func TestRunOutputAndHAR(t *testing.T) {
+	server := newTestServer()
+	defer server.Close()
+
+	dir := t.TempDir()
+	output := filepath.Join(dir, "out.txt")
+	harFile := filepath.Join(dir, "out.har")
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"-X", "put", "-d", "@-", "-H", "Authorization: secret", "-o", output, "-har", harFile, server.URL + "/?a=1"},
+		strings.NewReader("from stdin"), &stdout, &stderr)
+	tests.AssertEqual(t, 0, code)
+	tests.AssertEqual(t, 0, stdout.Len())
+	b, err := os.ReadFile(output)
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, "|from stdin", string(b))
+
+	b, err = os.ReadFile(harFile)
+	tests.AssertNoError(t, err)
+	var har harLog
+	tests.AssertNoError(t, json.Unmarshal(b, &har))
+	tests.AssertEqual(t, 1, len(har.Log.Entries))
+	e := har.Log.Entries[0]
+	tests.AssertEqual(t, "PUT", e.Request.Method)
+	tests.AssertEqual(t, 200, e.Response.Status)
+	tests.AssertNotNil(t, e.Request.PostData)
+	tests.AssertEqual(t, "from stdin", e.Request.PostData.Text)
+	tests.AssertEqual(t, []harNameValue{{Name: "a", Value: "1"}}, e.Request.QueryString)
+	for _, h := range e.Request.Headers {
+		if h.Name == "Authorization" {
+			tests.AssertEqual(t, "[REDACTED]", h.Value)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package restys
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// DNSStartInfo is the information of a DNS lookup which is about to start.
+type DNSStartInfo struct {
+	Host string
+}
+
+// DNSDoneInfo is the result of a DNS lookup.
+type DNSDoneInfo struct {
+	Host     string
+	Addrs    []net.IPAddr
+	Duration time.Duration
+	Err      error
+}
+
+// ConnectStartInfo is the information of a new connection which is about
+// to be dialed.
+type ConnectStartInfo struct {
+	Network string
+	Addr    string
+}
+
+// ConnectDoneInfo is the result of dialing a new connection.
+type ConnectDoneInfo struct {
+	Network  string
+	Addr     string
+	Duration time.Duration
+	Err      error
+}
+
+// TLSHandshakeDoneInfo is the result of a TLS handshake, State is zero
+// value if the handshake fails.
+type TLSHandshakeDoneInfo struct {
+	State    tls.ConnectionState
+	Duration time.Duration
+	Err      error
+}
+
+// connHooks is the client-level connection lifecycle hooks, which are
+// independent of the request trace (see Client.EnableTraceAll).
+type connHooks struct {
+	dnsStart         func(info DNSStartInfo)
+	dnsDone          func(info DNSDoneInfo)
+	connectStart     func(info ConnectStartInfo)
+	connectDone      func(info ConnectDoneInfo)
+	tlsHandshakeDone func(info TLSHandshakeDoneInfo)
+}
+
+func (h *connHooks) empty() bool {
+	return h.dnsStart == nil && h.dnsDone == nil && h.connectStart == nil &&
+		h.connectDone == nil && h.tlsHandshakeDone == nil
+}
+
+// OnDNSStart set the hook which is called when a DNS lookup starts for a
+// new connection.
+func (c *Client) OnDNSStart(fn func(info DNSStartInfo)) *Client {
+	c.connHooks.dnsStart = fn
+	return c
+}
+
+// OnDNSDone set the hook which is called when a DNS lookup ends, with the
+// resolved addresses, the duration and the error if any.
+func (c *Client) OnDNSDone(fn func(info DNSDoneInfo)) *Client {
+	c.connHooks.dnsDone = fn
+	return c
+}
+
+// OnConnectStart set the hook which is called when a new connection's
+// dial begins, it may be called multiple times for one connection if the
+// host resolves to multiple addresses.
+func (c *Client) OnConnectStart(fn func(info ConnectStartInfo)) *Client {
+	c.connHooks.connectStart = fn
+	return c
+}
+
+// OnConnectDone set the hook which is called when a new connection's dial
+// completes, with the duration and the error if any.
+func (c *Client) OnConnectDone(fn func(info ConnectDoneInfo)) *Client {
+	c.connHooks.connectDone = fn
+	return c
+}
+
+// OnTLSHandshakeDone set the hook which is called when the TLS handshake
+// of a new connection completes, with the connection state, the duration
+// and the error if any.
+func (c *Client) OnTLSHandshakeDone(fn func(info TLSHandshakeDoneInfo)) *Client {
+	c.connHooks.tlsHandshakeDone = fn
+	return c
+}
+
+// withConnHooks returns the context which calls the connection lifecycle
+// hooks, ctx is returned if no hook is set.
+func (h *connHooks) withConnHooks(ctx context.Context) context.Context {
+	if h.empty() {
+		return ctx
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var (
+		mu           sync.Mutex
+		dnsStart     time.Time
+		dnsHost      string
+		connectStart = make(map[string]time.Time)
+		tlsStart     time.Time
+	)
+	hooks := *h
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(info httptrace.DNSStartInfo) {
+			mu.Lock()
+			dnsStart, dnsHost = time.Now(), info.Host
+			mu.Unlock()
+			if hooks.dnsStart != nil {
+				hooks.dnsStart(DNSStartInfo{Host: info.Host})
+			}
+		},
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			mu.Lock()
+			d, host := time.Since(dnsStart), dnsHost
+			mu.Unlock()
+			if hooks.dnsDone != nil {
+				hooks.dnsDone(DNSDoneInfo{Host: host, Addrs: info.Addrs, Duration: d, Err: info.Err})
+			}
+		},
+		ConnectStart: func(network, addr string) {
+			mu.Lock()
+			connectStart[network+addr] = time.Now()
+			mu.Unlock()
+			if hooks.connectStart != nil {
+				hooks.connectStart(ConnectStartInfo{Network: network, Addr: addr})
+			}
+		},
+		ConnectDone: func(network, addr string, err error) {
+			mu.Lock()
+			d := time.Since(connectStart[network+addr])
+			delete(connectStart, network+addr)
+			mu.Unlock()
+			if hooks.connectDone != nil {
+				hooks.connectDone(ConnectDoneInfo{Network: network, Addr: addr, Duration: d, Err: err})
+			}
+		},
+		TLSHandshakeStart: func() {
+			mu.Lock()
+			tlsStart = time.Now()
+			mu.Unlock()
+		},
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+			mu.Lock()
+			d := time.Since(tlsStart)
+			mu.Unlock()
+			if hooks.tlsHandshakeDone != nil {
+				hooks.tlsHandshakeDone(TLSHandshakeDoneInfo{State: state, Duration: d, Err: err})
+			}
+		},
+	}
+	return httptrace.WithClientTrace(ctx, trace)
+}
@@ -0,0 +1,62 @@
+package restys
+
+import (
+	"net"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/luoxk/restys/internal/tests"
+)
+
+func TestConnHooks(t *testing.T) {
+	u, err := url.Parse(getTestServerURL())
+	tests.AssertNoError(t, err)
+	var (
+		mu          sync.Mutex
+		dnsStart    []DNSStartInfo
+		dnsDone     []DNSDoneInfo
+		connectDone []ConnectDoneInfo
+		tlsDone     []TLSHandshakeDoneInfo
+	)
+	c := tc().EnableForceHTTP1().EnableTraceAll().
+		SetHosts(map[string]string{"hooks.test": "127.0.0.1"}).
+		OnDNSStart(func(info DNSStartInfo) {
+			mu.Lock()
+			dnsStart = append(dnsStart, info)
+			mu.Unlock()
+		}).
+		OnDNSDone(func(info DNSDoneInfo) {
+			mu.Lock()
+			dnsDone = append(dnsDone, info)
+			mu.Unlock()
+		}).
+		OnConnectDone(func(info ConnectDoneInfo) {
+			mu.Lock()
+			connectDone = append(connectDone, info)
+			mu.Unlock()
+		}).
+		OnTLSHandshakeDone(func(info TLSHandshakeDoneInfo) {
+			mu.Lock()
+			tlsDone = append(tlsDone, info)
+			mu.Unlock()
+		})
+
+	resp, err := c.R().Get("https://" + net.JoinHostPort("hooks.test", u.Port()) + "/")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, true, resp.TraceInfo().ConnectTime > 0)
+
+	mu.Lock()
+	defer mu.Unlock()
+	tests.AssertEqual(t, []DNSStartInfo{{Host: "hooks.test"}}, dnsStart)
+	tests.AssertEqual(t, 1, len(dnsDone))
+	tests.AssertEqual(t, "hooks.test", dnsDone[0].Host)
+	tests.AssertEqual(t, "127.0.0.1", dnsDone[0].Addrs[0].IP.String())
+	tests.AssertEqual(t, 1, len(connectDone))
+	tests.AssertEqual(t, "127.0.0.1:"+u.Port(), connectDone[0].Addr)
+	tests.AssertNoError(t, connectDone[0].Err)
+	tests.AssertEqual(t, 1, len(tlsDone))
+	tests.AssertNoError(t, tlsDone[0].Err)
+	tests.AssertEqual(t, true, tlsDone[0].State.HandshakeComplete)
+	tests.AssertEqual(t, "hooks.test", tlsDone[0].State.ServerName)
+}
@@ -0,0 +1,84 @@
+package restys
+
+import (
+	"context"
+	"net/http"
+	urlpkg "net/url"
+)
+
+type contextOptionsKey struct{}
+
+// contextOptions is the request options attached to the context, which
+// is immutable once attached.
+type contextOptions struct {
+	headers  http.Header
+	proxyURL *urlpkg.URL
+}
+
+func getContextOptions(ctx context.Context) *contextOptions {
+	if ctx == nil {
+		return nil
+	}
+	o, _ := ctx.Value(contextOptionsKey{}).(*contextOptions)
+	return o
+}
+
+func withContextOptions(ctx context.Context, fn func(o *contextOptions)) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	o := &contextOptions{}
+	if old := getContextOptions(ctx); old != nil {
+		o.headers = old.headers.Clone()
+		o.proxyURL = old.proxyURL
+	}
+	fn(o)
+	return context.WithValue(ctx, contextOptionsKey{}, o)
+}
+
+// WithRequestHeader returns a copy of ctx which carries the header, the
+// client sets the header for any request with the context (see
+// Request.SetContext) unless the request sets it itself, which enables
+// cross-cutting layers (e.g. tenancy, auth) to influence the requests
+// without plumbing *Request everywhere, e.g.
+//
+//	ctx = req.WithRequestHeader(ctx, "X-Tenant-Id", tenantID)
+//	client.R().SetContext(ctx).Get(url)
+//
+// The header takes precedence over the client-level ones (including those
+// set by Client.ForHost).
+func WithRequestHeader(ctx context.Context, key, value string) context.Context {
+	return withContextOptions(ctx, func(o *contextOptions) {
+		if o.headers == nil {
+			o.headers = make(http.Header)
+		}
+		o.headers.Set(key, value)
+	})
+}
+
+// WithProxy returns a copy of ctx which carries the proxy, the client uses
+// the proxy instead of the client-level one (see Client.SetProxy) for any
+// request with the context, only valid for HTTP1 and HTTP2.
+func WithProxy(ctx context.Context, proxyURL *urlpkg.URL) context.Context {
+	return withContextOptions(ctx, func(o *contextOptions) {
+		o.proxyURL = proxyURL
+	})
+}
+
+// parseRequestContextOptions applies the headers attached to the context
+// of the request, it must be before parseRequestHostConfig and
+// parseRequestHeader.
+func parseRequestContextOptions(c *Client, r *Request) error {
+	o := getContextOptions(r.ctx)
+	if o == nil {
+		return nil
+	}
+	for k, vs := range o.headers {
+		if len(r.Headers[k]) == 0 {
+			// the capacity is limited so that appending to the request
+			// header doesn't write into the context's one.
+			r.Headers[k] = vs[:len(vs):len(vs)]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package restys
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+
+	"github.com/luoxk/restys/internal/tests"
+)
+
+func TestContextOptions(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("proxied " + r.URL.String() + " " + r.Header.Get("X-Tenant")))
+	}))
+	defer proxy.Close()
+	proxyURL, _ := url.Parse(proxy.URL)
+
+	c := tc().SetCommonHeader("X-Tenant", "client")
+	ctx := WithRequestHeader(context.Background(), "X-Tenant", "t1")
+	resp, err := c.R().SetContext(ctx).Get("/header")
+	assertSuccess(t, resp, err)
+	tests.AssertContains(t, resp.String(), `"x-tenant":["t1"]`, true)
+
+	resp, err = c.R().SetContext(ctx).SetHeader("X-Tenant", "request").Get("/header")
+	assertSuccess(t, resp, err)
+	tests.AssertContains(t, resp.String(), `"x-tenant":["request"]`, true)
+
+	ctx = WithProxy(ctx, proxyURL)
+	resp, err = C().R().SetContext(ctx).Get("http://example.invalid/path")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, "proxied http://example.invalid/path t1", resp.String())
+
+	// the parent context is not affected.
+	tests.AssertEqual(t, true, getContextOptions(context.Background()) == nil)
+	ctx2 := WithRequestHeader(ctx, "X-Other", "1")
+	tests.AssertEqual(t, "", getContextOptions(ctx).headers.Get("X-Other"))
+	tests.AssertEqual(t, "1", getContextOptions(ctx2).headers.Get("X-Other"))
+	tests.AssertEqual(t, proxyURL, getContextOptions(ctx2).proxyURL)
+
+	// the header slices are not shared with the context.
+	values := append(make([]string, 0, 2), "t1")
+	ctx = context.WithValue(context.Background(), contextOptionsKey{}, &contextOptions{headers: http.Header{"X-Tenant": values}})
+	r := c.R().SetContext(ctx)
+	r.Headers = make(http.Header)
+	tests.AssertNoError(t, parseRequestContextOptions(c, r))
+	r.Headers.Add("X-Tenant", "t2")
+	tests.AssertEqual(t, []string{"t1"}, getContextOptions(ctx).headers["X-Tenant"])
+	tests.AssertEqual(t, "", values[:2][1])
+}
+
+func TestContextProxyHTTP2(t *testing.T) {
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	}))
+	ts.EnableHTTP2 = true
+	ts.StartTLS()
+	defer ts.Close()
+
+	c := C().EnableInsecureSkipVerify()
+	resp, err := c.R().Get(ts.URL)
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "HTTP/2.0", resp.String())
+
+	// the request through the proxy never reuses the direct connection.
+	deadProxy, _ := url.Parse("http://127.0.0.1:1")
+	_, err = c.R().SetContext(WithProxy(context.Background(), deadProxy)).Get(ts.URL)
+	tests.AssertNotNil(t, err)
+
+	var connects atomic.Int32
+	proxyURL, _ := url.Parse(newConnectProxy(t, &connects))
+	ctx := WithProxy(context.Background(), proxyURL)
+	for i := 0; i < 2; i++ {
+		resp, err = c.R().SetContext(ctx).Get(ts.URL)
+		assertSuccess(t, resp, err)
+		tests.AssertEqual(t, "HTTP/2.0", resp.String())
+	}
+	tests.AssertEqual(t, int32(1), connects.Load())
+}
@@ -0,0 +1,46 @@
+package restys
+
+import (
+	"io"
+	"os"
+	"sync"
+)
+
+// largeCopyBufSize is the buffer size of copying the response body to the
+// file, which is larger than copyBufPoolSize to reduce the read and write
+// syscalls of the large downloads.
+const largeCopyBufSize = 256 << 10
+
+var largeCopyBufPool = sync.Pool{New: func() any { return new([largeCopyBufSize]byte) }}
+
+// copyLargeBuffer copies from src to dst with the pooled large buffer, the
+// io.ReaderFrom of dst is hidden like copyBuffer.
+func copyLargeBuffer(dst io.Writer, src io.Reader) (int64, error) {
+	buf := largeCopyBufPool.Get().(*[largeCopyBufSize]byte)
+	defer largeCopyBufPool.Put(buf)
+	return io.CopyBuffer(struct{ io.Writer }{dst}, src, buf[:])
+}
+
+// copyToFile copies from src to the file dst. If src can be copied without
+// the user space buffer (see canZeroCopy), e.g. a file on Linux, the data
+// is transferred in kernel by copy_file_range(2) or splice(2) through
+// (*os.File).ReadFrom, otherwise it's copied with the pooled large buffer.
+// The response bodies are always copied with the buffer as they're read
+// through the decoding (e.g. chunked, TLS and HTTP/2 framing) instead of
+// from the connection, only the merge of the parallel download parts is
+// copied in kernel.
+func copyToFile(dst *os.File, src io.Reader) (int64, error) {
+	if canZeroCopy(src) {
+		return dst.ReadFrom(src)
+	}
+	return copyLargeBuffer(dst, src)
+}
+
+// copyOutput copies the response body to the download output, the output
+// file is copied by copyToFile.
+func copyOutput(dst io.Writer, src io.Reader) (int64, error) {
+	if f, ok := dst.(*os.File); ok {
+		return copyToFile(f, src)
+	}
+	return copyBuffer(dst, src)
+}
@@ -0,0 +1,25 @@
+//go:build linux
+
+package restys
+
+import (
+	"io"
+	"net"
+	"os"
+)
+
+// canZeroCopy reports whether (*os.File).ReadFrom copies from src in
+// kernel, which uses copy_file_range(2) for the file and splice(2) for the
+// TCP and Unix socket, src can be limited by io.LimitReader. The response
+// body is never unwrapped to its connection, which is not safe as the
+// body is decoded from what's read from the connection.
+func canZeroCopy(src io.Reader) bool {
+	if lr, ok := src.(*io.LimitedReader); ok {
+		src = lr.R
+	}
+	switch src.(type) {
+	case *os.File, *net.TCPConn, *net.UnixConn:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+
+package restys
+
+import "io"
+
+// canZeroCopy reports whether (*os.File).ReadFrom copies from src in
+// kernel, which is only supported on Linux.
+func canZeroCopy(src io.Reader) bool {
+	return false
+}
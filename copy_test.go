@@ -0,0 +1,91 @@
+package restys
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/luoxk/restys/internal/tests"
+)
+
+func TestCopyToFile(t *testing.T) {
+	dir := t.TempDir()
+	content := bytes.Repeat([]byte("restys"), 100<<10)
+	src := filepath.Join(dir, "src")
+	tests.AssertNoError(t, os.WriteFile(src, content, 0666))
+
+	for name, open := range map[string]func() (io.Reader, error){
+		"file": func() (io.Reader, error) { return os.Open(src) },
+		"limited file": func() (io.Reader, error) {
+			f, err := os.Open(src)
+			return io.LimitReader(f, int64(len(content))), err
+		},
+		"reader": func() (io.Reader, error) { return onlyReader{bytes.NewReader(content)}, nil },
+	} {
+		r, err := open()
+		tests.AssertNoError(t, err)
+		dst, err := os.Create(filepath.Join(dir, "dst"))
+		tests.AssertNoError(t, err)
+		n, err := copyToFile(dst, r)
+		closeq(r)
+		dst.Close()
+		tests.AssertNoError(t, err)
+		tests.AssertEqual(t, int64(len(content)), n)
+		b, err := os.ReadFile(dst.Name())
+		tests.AssertNoError(t, err)
+		if !bytes.Equal(content, b) {
+			t.Errorf("copyToFile from %s: content mismatch", name)
+		}
+	}
+
+	// the response body is decoded from the connection, which is copied
+	// with the buffer.
+	resp, err := tc().R().DisableAutoReadResponse().Get("/")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, false, canZeroCopy(resp.Body))
+	resp.Body.Close()
+}
+
+// benchmarkDownloadSize is the size of the benchmark downloads, the gains
+// of the multi-GB downloads are the same as the throughput (MB/s) shows.
+const benchmarkDownloadSize = 64 << 20
+
+func benchmarkCopyToFile(b *testing.B, newReader func(f *os.File) io.Reader, copyFn func(dst *os.File, src io.Reader) (int64, error)) {
+	dir := b.TempDir()
+	src := filepath.Join(dir, "src")
+	if err := os.WriteFile(src, bytes.Repeat([]byte("restys!!"), benchmarkDownloadSize/8), 0666); err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(benchmarkDownloadSize)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		sf, err := os.Open(src)
+		if err != nil {
+			b.Fatal(err)
+		}
+		df, err := os.Create(filepath.Join(dir, "dst"))
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err = copyFn(df, newReader(sf)); err != nil {
+			b.Fatal(err)
+		}
+		sf.Close()
+		df.Close()
+	}
+}
+
+func BenchmarkCopyToFile(b *testing.B) {
+	generic := func(dst *os.File, src io.Reader) (int64, error) {
+		return io.Copy(onlyWriter{dst}, src)
+	}
+	file := func(f *os.File) io.Reader { return f }
+	body := func(f *os.File) io.Reader { return onlyReader{f} }
+	b.Run("file/io.Copy", func(b *testing.B) { benchmarkCopyToFile(b, file, generic) })
+	b.Run("file/copyToFile", func(b *testing.B) { benchmarkCopyToFile(b, file, copyToFile) })
+	b.Run("body/io.Copy", func(b *testing.B) { benchmarkCopyToFile(b, body, generic) })
+	b.Run("body/copyToFile", func(b *testing.B) { benchmarkCopyToFile(b, body, copyToFile) })
+}
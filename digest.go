@@ -58,6 +58,8 @@ func handleDigestAuthFunc(username, password string) ResponseMiddleware {
 				req.GetBody = r.GetBody
 			}
 		}
+		// the header may be shared with the Request.Headers.
+		req.Header = req.Header.Clone()
 		if req.Header == nil {
 			req.Header = make(http.Header)
 		}
@@ -0,0 +1,221 @@
+package restys
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/luoxk/restys/internal/dnsutil"
+)
+
+// DNSCache is an in-process DNS cache which can be shared by multiple
+// clients (see Client.SetDNSCache), it is safe for concurrent use.
+//
+// When the resolver has a custom Dial function (e.g. set by
+// Client.SetDNSServers), the DNS servers are queried directly so the TTLs
+// of the records are respected, otherwise the lookup is delegated to the
+// resolver and the default TTL is used since it does not report TTLs.
+// Either way the TTL is clamped between the minimum and maximum TTL.
+type DNSCache struct {
+	mu       sync.Mutex
+	entries  map[string]*dnsCacheEntry
+	inflight map[string]*dnsLookupCall
+
+	minTTL      time.Duration
+	maxTTL      time.Duration
+	defaultTTL  time.Duration
+	negativeTTL time.Duration
+}
+
+type dnsCacheEntry struct {
+	ips     []netip.Addr
+	err     error
+	expires time.Time
+}
+
+type dnsLookupCall struct {
+	done chan struct{}
+	ips  []netip.Addr
+	err  error
+}
+
+// NewDNSCache creates a DNSCache, the default TTL is 1 minute, which is
+// clamped between 5 seconds and 1 hour, and negative results (host not
+// found) are cached for 5 seconds.
+func NewDNSCache() *DNSCache {
+	return &DNSCache{
+		entries:     make(map[string]*dnsCacheEntry),
+		inflight:    make(map[string]*dnsLookupCall),
+		minTTL:      5 * time.Second,
+		maxTTL:      time.Hour,
+		defaultTTL:  time.Minute,
+		negativeTTL: 5 * time.Second,
+	}
+}
+
+// SetTTLRange set the minimum and maximum TTL that the cached records are
+// clamped to, zero max means no limit.
+func (c *DNSCache) SetTTLRange(min, max time.Duration) *DNSCache {
+	c.mu.Lock()
+	c.minTTL, c.maxTTL = min, max
+	c.mu.Unlock()
+	return c
+}
+
+// SetDefaultTTL set the TTL which is used if the resolver does not report
+// the TTL of the records.
+func (c *DNSCache) SetDefaultTTL(ttl time.Duration) *DNSCache {
+	c.mu.Lock()
+	c.defaultTTL = ttl
+	c.mu.Unlock()
+	return c
+}
+
+// SetNegativeTTL set how long the host not found result is cached, zero
+// disables negative caching.
+func (c *DNSCache) SetNegativeTTL(ttl time.Duration) *DNSCache {
+	c.mu.Lock()
+	c.negativeTTL = ttl
+	c.mu.Unlock()
+	return c
+}
+
+// Flush removes the cached records of the hosts, or all records if no
+// host is specified.
+func (c *DNSCache) Flush(hosts ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(hosts) == 0 {
+		c.entries = make(map[string]*dnsCacheEntry)
+		return
+	}
+	for _, host := range hosts {
+		delete(c.entries, normalizeHost(host))
+	}
+}
+
+// Inject adds the IP addresses of host to the cache, which expire after
+// ttl (not clamped), zero ttl means never expire.
+func (c *DNSCache) Inject(host string, ttl time.Duration, ips ...string) error {
+	addrs := make([]netip.Addr, 0, len(ips))
+	for _, ip := range ips {
+		addr, err := netip.ParseAddr(ip)
+		if err != nil {
+			return err
+		}
+		addrs = append(addrs, addr)
+	}
+	if len(addrs) == 0 {
+		return fmt.Errorf("no ip address to inject for %s", host)
+	}
+	entry := &dnsCacheEntry{ips: addrs}
+	if ttl > 0 {
+		entry.expires = time.Now().Add(ttl)
+	}
+	c.mu.Lock()
+	c.entries[normalizeHost(host)] = entry
+	c.mu.Unlock()
+	return nil
+}
+
+// LookupNetIP returns the IP addresses of host from the cache, or looks
+// them up with the resolver (nil means the system resolver) if not cached
+// or expired. Concurrent lookups of the same host are merged.
+func (c *DNSCache) LookupNetIP(ctx context.Context, resolver *net.Resolver, host string) ([]netip.Addr, error) {
+	key := normalizeHost(host)
+	c.mu.Lock()
+	if entry, ok := c.entries[key]; ok {
+		if entry.expires.IsZero() || time.Now().Before(entry.expires) {
+			c.mu.Unlock()
+			return entry.ips, entry.err
+		}
+		delete(c.entries, key)
+	}
+	if call, ok := c.inflight[key]; ok {
+		c.mu.Unlock()
+		select {
+		case <-call.done:
+			return call.ips, call.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	call := &dnsLookupCall{done: make(chan struct{})}
+	c.inflight[key] = call
+	c.mu.Unlock()
+
+	ips, ttl, err := c.lookup(ctx, resolver, host)
+	call.ips, call.err = ips, err
+
+	c.mu.Lock()
+	delete(c.inflight, key)
+	if entry := c.newEntry(ips, ttl, err); entry != nil {
+		c.entries[key] = entry
+	}
+	c.mu.Unlock()
+	close(call.done)
+	return ips, err
+}
+
+// newEntry creates the cache entry for the lookup result, returns nil if
+// the result should not be cached, c.mu must be held.
+func (c *DNSCache) newEntry(ips []netip.Addr, ttl time.Duration, err error) *dnsCacheEntry {
+	if err != nil {
+		var dnsErr *net.DNSError
+		if c.negativeTTL <= 0 || !errors.As(err, &dnsErr) || !dnsErr.IsNotFound {
+			return nil
+		}
+		return &dnsCacheEntry{err: err, expires: time.Now().Add(c.negativeTTL)}
+	}
+	if ttl <= 0 {
+		ttl = c.defaultTTL
+	}
+	if ttl < c.minTTL {
+		ttl = c.minTTL
+	}
+	if c.maxTTL > 0 && ttl > c.maxTTL {
+		ttl = c.maxTTL
+	}
+	return &dnsCacheEntry{ips: ips, expires: time.Now().Add(ttl)}
+}
+
+// lookup looks up the host, ttl is zero if unknown.
+func (c *DNSCache) lookup(ctx context.Context, resolver *net.Resolver, host string) ([]netip.Addr, time.Duration, error) {
+	if resolver != nil && resolver.Dial != nil {
+		// like net.Resolver, the dial function is called with the system
+		// name server, which is ignored by the one set by SetDNSServers.
+		ips, ttl, err := dnsutil.LookupNetIP(ctx, resolver.Dial, dnsutil.SystemNameServer(), host)
+		if err == nil {
+			return ips, ttl, nil
+		}
+		if err == dnsutil.ErrNotFound {
+			return nil, 0, &net.DNSError{Err: err.Error(), Name: host, IsNotFound: true}
+		}
+		// fallback to the resolver, e.g. the name is in the hosts file.
+	}
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	ips, err := resolver.LookupNetIP(ctx, "ip", host)
+	return ips, 0, err
+}
+
+func normalizeHost(host string) string {
+	return strings.ToLower(strings.TrimSuffix(host, "."))
+}
+
+// SetDNSCache set the DNS cache used to look up host names when dialing,
+// which can be shared by multiple clients, nil disables the DNS cache.
+func (t *Transport) SetDNSCache(cache *DNSCache) *Transport {
+	if cache == nil {
+		t.LookupNetIP = nil
+	} else {
+		t.LookupNetIP = cache.LookupNetIP
+	}
+	return t
+}
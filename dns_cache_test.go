@@ -0,0 +1,101 @@
+package restys
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/luoxk/restys/internal/tests"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// serveTestDNS starts a UDP DNS server which answers "cache.test." with
+// 127.0.0.1 and NXDOMAIN for others, and counts the queries.
+func serveTestDNS(t *testing.T, queries *atomic.Int32) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	tests.AssertNoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			var q dnsmessage.Message
+			if q.Unpack(buf[:n]) != nil {
+				continue
+			}
+			queries.Add(1)
+			resp := dnsmessage.Message{
+				Header:    dnsmessage.Header{ID: q.ID, Response: true},
+				Questions: q.Questions,
+			}
+			question := q.Questions[0]
+			if question.Name.String() != "cache.test." {
+				resp.RCode = dnsmessage.RCodeNameError
+			} else if question.Type == dnsmessage.TypeA {
+				resp.Answers = []dnsmessage.Resource{{
+					Header: dnsmessage.ResourceHeader{Name: question.Name, Type: question.Type, Class: dnsmessage.ClassINET, TTL: 300},
+					Body:   &dnsmessage.AResource{A: [4]byte{127, 0, 0, 1}},
+				}}
+			}
+			b, _ := resp.Pack()
+			conn.WriteTo(b, addr)
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func TestDNSCache(t *testing.T) {
+	var queries atomic.Int32
+	cache := NewDNSCache().SetTTLRange(0, time.Hour)
+	c := tc().SetDNSServers(serveTestDNS(t, &queries)).SetDNSCache(cache)
+
+	url := strings.Replace(getTestServerURL(), "127.0.0.1", "cache.test", 1)
+	resp, err := c.R().Get(url)
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, int32(2), queries.Load()) // A and AAAA
+
+	ips, err := cache.LookupNetIP(context.Background(), c.Resolver, "CACHE.test.")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, []netip.Addr{netip.MustParseAddr("127.0.0.1")}, ips)
+	tests.AssertEqual(t, int32(2), queries.Load())
+
+	// negative caching.
+	_, err = cache.LookupNetIP(context.Background(), c.Resolver, "missing.test")
+	var dnsErr *net.DNSError
+	tests.AssertEqual(t, true, errors.As(err, &dnsErr) && dnsErr.IsNotFound)
+	n := queries.Load()
+	_, err = cache.LookupNetIP(context.Background(), c.Resolver, "missing.test")
+	tests.AssertNotNil(t, err)
+	tests.AssertEqual(t, n, queries.Load())
+
+	// TTL is clamped by the max TTL.
+	cache.Flush()
+	cache.SetTTLRange(0, 10*time.Millisecond)
+	_, err = cache.LookupNetIP(context.Background(), c.Resolver, "cache.test")
+	tests.AssertNoError(t, err)
+	n = queries.Load()
+	time.Sleep(20 * time.Millisecond)
+	_, err = cache.LookupNetIP(context.Background(), c.Resolver, "cache.test")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, n+2, queries.Load())
+
+	// injected records are served without lookup.
+	tests.AssertNoError(t, cache.Inject("injected.test", 0, "127.0.0.1"))
+	n = queries.Load()
+	url = strings.Replace(getTestServerURL(), "127.0.0.1", "injected.test", 1)
+	resp, err = c.R().Get(url)
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, n, queries.Load())
+	cache.Flush("injected.test")
+	_, err = cache.LookupNetIP(context.Background(), c.Resolver, "injected.test")
+	tests.AssertNotNil(t, err)
+	tests.AssertErrorContains(t, cache.Inject("bad.test", 0, "not-an-ip"), "ParseAddr")
+}
@@ -0,0 +1,359 @@
+package restys
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/luoxk/restys/internal/util"
+)
+
+// resumeMetaSuffix is the suffix of the file which stores the validator
+// (ETag or Last-Modified) of an interrupted download, it only exists
+// while the download is incomplete.
+const resumeMetaSuffix = ".resume"
+
+// partFileSuffix is the suffix of the temporary file that an atomic
+// download is written to when the partial file should be kept for resuming.
+const partFileSuffix = ".part"
+
+// outputFilePath returns the path that the output file will be written to,
+// relative paths are resolved against the client's output directory.
+func (c *Client) outputFilePath(file string) string {
+	if c.outputDirectory != "" && !filepath.IsAbs(file) {
+		file = c.outputDirectory + string(filepath.Separator) + file
+	}
+	return filepath.Clean(file)
+}
+
+// parseRequestResume set the Range and If-Range header if the request
+// is resuming a partial download (see Request.SetOutputFileResume).
+func parseRequestResume(c *Client, r *Request) error {
+	if !r.resumeOutput || r.outputFile == "" {
+		return nil
+	}
+	// recalculated on every attempt, so a retry continues from the
+	// bytes that have been written by the previous attempt.
+	r.resumeOffset = 0
+	r.Headers.Del("Range")
+	r.Headers.Del("If-Range")
+
+	file := c.outputFilePath(r.outputFile)
+	if r.atomicOutput {
+		if !r.keepPartFile { // nothing is left to resume from.
+			return nil
+		}
+		file += partFileSuffix
+	}
+	info, err := os.Stat(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if info.IsDir() || info.Size() == 0 {
+		return nil
+	}
+	r.resumeOffset = info.Size()
+	r.SetHeader("Range", fmt.Sprintf("bytes=%d-", r.resumeOffset))
+	if b, err := os.ReadFile(file + resumeMetaSuffix); err == nil {
+		if validator := strings.TrimSpace(string(b)); validator != "" {
+			r.SetHeader("If-Range", validator)
+		}
+	}
+	return nil
+}
+
+// resumeValidator returns the validator that can be used in If-Range
+// header, weak ETag is not allowed by RFC 9110.
+func resumeValidator(h http.Header) string {
+	if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	return h.Get("Last-Modified")
+}
+
+// parseContentRange parses the `Content-Range` header value, e.g.
+// "bytes 100-199/200" or "bytes */200", total is -1 if unknown.
+func parseContentRange(s string) (start, end, total int64, err error) {
+	unit, rng, ok := util.CutString(strings.TrimSpace(s), " ")
+	if !ok || unit != "bytes" {
+		return 0, 0, 0, fmt.Errorf("invalid Content-Range %q", s)
+	}
+	rng, size, ok := util.CutString(rng, "/")
+	if !ok {
+		return 0, 0, 0, fmt.Errorf("invalid Content-Range %q", s)
+	}
+	total = -1
+	if size != "*" {
+		if total, err = strconv.ParseInt(size, 10, 64); err != nil {
+			return 0, 0, 0, fmt.Errorf("invalid Content-Range %q", s)
+		}
+	}
+	start, end = -1, -1
+	if rng != "*" {
+		first, last, ok := util.CutString(rng, "-")
+		if !ok {
+			return 0, 0, 0, fmt.Errorf("invalid Content-Range %q", s)
+		}
+		if start, err = strconv.ParseInt(first, 10, 64); err != nil {
+			return 0, 0, 0, fmt.Errorf("invalid Content-Range %q", s)
+		}
+		if end, err = strconv.ParseInt(last, 10, 64); err != nil {
+			return 0, 0, 0, fmt.Errorf("invalid Content-Range %q", s)
+		}
+	}
+	return
+}
+
+func handleResumeDownload(c *Client, r *Response, body io.ReadCloser) (err error) {
+	defer body.Close()
+	req := r.Request
+	file := c.outputFilePath(req.outputFile)
+	meta := file + resumeMetaSuffix
+
+	flag := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	switch code := r.StatusCode; {
+	case code == http.StatusPartialContent && req.resumeOffset > 0:
+		start, _, _, err := parseContentRange(r.Header.Get("Content-Range"))
+		if err != nil {
+			return err
+		}
+		if start != req.resumeOffset {
+			return fmt.Errorf("cannot resume download of %s: got range starting at %d, expected %d", file, start, req.resumeOffset)
+		}
+		flag = os.O_WRONLY | os.O_APPEND
+	case code == http.StatusRequestedRangeNotSatisfiable && req.resumeOffset > 0:
+		_, _, total, err := parseContentRange(r.Header.Get("Content-Range"))
+		if err != nil || total != req.resumeOffset {
+			return fmt.Errorf("cannot resume download of %s: range not satisfiable", file)
+		}
+		if c.DebugLog {
+			c.log.Debugf("%s is already completely downloaded", file)
+		}
+		if req.atomicOutput {
+			if err := os.Rename(file+partFileSuffix, file); err != nil {
+				return err
+			}
+			r.outputFile = file
+		}
+		os.Remove(meta)
+		r.setReceivedAt()
+		return nil
+	case code < 200 || code > 299:
+		// keep the partial file untouched, so it can be resumed later.
+		return nil
+	}
+
+	if err = util.CreateDirectory(filepath.Dir(file)); err != nil {
+		return err
+	}
+	if validator := resumeValidator(r.Header); validator != "" {
+		if err = os.WriteFile(meta, []byte(validator), 0644); err != nil {
+			return err
+		}
+	} else {
+		os.Remove(meta)
+	}
+	if req.atomicOutput {
+		if flag&os.O_APPEND == 0 && !req.keepPartFile {
+			return writeAtomicFile(r, body, file, false)
+		}
+		if c.DebugLog && flag&os.O_APPEND != 0 {
+			c.log.Debugf("resume download of %s from byte %d", file, req.resumeOffset)
+		}
+		if err = copyToPartFile(r, body, file, flag); err == nil {
+			os.Remove(meta)
+		}
+		return
+	}
+	output, err := os.OpenFile(file, flag, 0666)
+	if err != nil {
+		return err
+	}
+	defer output.Close()
+	r.outputFile = file
+	if c.DebugLog && flag&os.O_APPEND != 0 {
+		c.log.Debugf("resume download of %s from byte %d", file, req.resumeOffset)
+	}
+
+	_, err = copyToFile(output, body)
+	r.setReceivedAt()
+	if err == nil {
+		os.Remove(meta)
+	}
+	return
+}
+
+// copyToPartFile writes body to the "<file>.part" file with the open flag,
+// and renames it to file on success, the part file is kept on failure.
+func copyToPartFile(r *Response, body io.Reader, file string, flag int) error {
+	part := file + partFileSuffix
+	output, err := os.OpenFile(part, flag, 0666)
+	if err != nil {
+		return err
+	}
+	_, err = copyToFile(output, body)
+	r.setReceivedAt()
+	if e := output.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		return err
+	}
+	if err = os.Rename(part, file); err != nil {
+		return err
+	}
+	r.outputFile = file
+	return nil
+}
+
+// writeAtomicFile writes body to a temporary file in the directory of file,
+// and renames it to file on success. If placeholder is true, file has been
+// created by the collision policy to reserve the name, and is removed on
+// failure.
+func writeAtomicFile(r *Response, body io.Reader, file string, placeholder bool) (err error) {
+	defer func() {
+		if err != nil && placeholder {
+			os.Remove(file)
+		}
+	}()
+	if r.Request.keepPartFile {
+		return copyToPartFile(r, body, file, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	}
+	output, err := os.CreateTemp(filepath.Dir(file), filepath.Base(file)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmp := output.Name()
+	_, err = copyToFile(output, body)
+	r.setReceivedAt()
+	if e := output.Close(); err == nil {
+		err = e
+	}
+	if err == nil {
+		err = os.Rename(tmp, file)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	r.outputFile = file
+	return nil
+}
+
+// FileCollisionPolicy decides what to do if the output file already exists.
+type FileCollisionPolicy int
+
+const (
+	// CollisionOverwrite overwrites the existing file.
+	CollisionOverwrite FileCollisionPolicy = iota
+	// CollisionRename saves to a new file with a counter appended to the
+	// name, e.g. "report (1).pdf".
+	CollisionRename
+	// CollisionFail fails the download with an error.
+	CollisionFail
+)
+
+// contentDispositionFilename returns the sanitized filename of the download,
+// taken from the `Content-Disposition` header or the URL path.
+func contentDispositionFilename(r *Response) string {
+	if _, params, err := mime.ParseMediaType(r.GetHeader("Content-Disposition")); err == nil {
+		if name := sanitizeFilename(params["filename"]); name != "" {
+			return name
+		}
+	}
+	if u := r.Request.URL; u != nil {
+		if name := sanitizeFilename(path.Base(u.Path)); name != "" {
+			return name
+		}
+	}
+	return "download"
+}
+
+var windowsReservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true,
+	"COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true,
+	"LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// sanitizeFilename makes the server supplied filename safe to be used as
+// a file in the output directory: directory components are dropped, and
+// characters that are invalid on common file systems are replaced.
+func sanitizeFilename(name string) string {
+	name = strings.ReplaceAll(name, "\\", "/")
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f || strings.ContainsRune(`<>:"|?*`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	name = strings.Trim(name, " .")
+	if name == "" {
+		return ""
+	}
+	base, _, _ := strings.Cut(name, ".")
+	if windowsReservedNames[strings.ToUpper(base)] {
+		name = "_" + name
+	}
+	for len(name) > 255 {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+	return name
+}
+
+// createOutputFile creates the output file according to the collision
+// policy, and returns the path that is actually created.
+func createOutputFile(file string, policy FileCollisionPolicy) (*os.File, string, error) {
+	switch policy {
+	case CollisionFail:
+		f, err := os.OpenFile(file, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+		if os.IsExist(err) {
+			return nil, file, fmt.Errorf("output file %s already exists", file)
+		}
+		return f, file, err
+	case CollisionRename:
+		ext := filepath.Ext(file)
+		base := strings.TrimSuffix(file, ext)
+		name := file
+		for i := 1; ; i++ {
+			f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+			if !os.IsExist(err) {
+				return f, name, err
+			}
+			name = fmt.Sprintf("%s (%d)%s", base, i, ext)
+		}
+	}
+	f, err := os.Create(file)
+	return f, file, err
+}
+
+// handleAtomicDownload reserves the output file according to the collision
+// policy, and writes body to it atomically.
+func handleAtomicDownload(r *Response, body io.ReadCloser, file string) error {
+	defer body.Close()
+	placeholder := false
+	if r.Request.collisionPolicy != CollisionOverwrite {
+		f, name, err := createOutputFile(file, r.Request.collisionPolicy)
+		if err != nil {
+			return err
+		}
+		f.Close()
+		file, placeholder = name, true
+	}
+	return writeAtomicFile(r, body, file, placeholder)
+}
@@ -3,9 +3,46 @@ package restys
 import (
 	"github.com/luoxk/restys/internal/dump"
 	"io"
+	"net/http"
 	"os"
+	"strings"
+	"time"
 )
 
+// DumpFormat is the output format of dump.
+type DumpFormat int
+
+const (
+	// DumpFormatText dumps the raw HTTP message as plain text while
+	// transferring, which is the default format.
+	DumpFormatText DumpFormat = iota
+	// DumpFormatJSON dumps one JSON object per line for each request
+	// attempt after the response is received (see DumpEntry), the bodies
+	// are base64 encoded.
+	DumpFormatJSON
+)
+
+// DumpOverflowPolicy is the policy when the async dump queue is full, see
+// DumpOptions.AsyncOverflow. Note the dumps are dropped line by line or
+// chunk by chunk, so the dumped HTTP message may be incomplete.
+type DumpOverflowPolicy int
+
+const (
+	// DumpOverflowBlock blocks the request until the queue is not full.
+	DumpOverflowBlock DumpOverflowPolicy = dump.OverflowBlock
+	// DumpOverflowDrop drops the dump, the number of dropped dumps is
+	// reported by Client.DumpDropped.
+	DumpOverflowDrop DumpOverflowPolicy = dump.OverflowDrop
+	// DumpOverflowSample keeps 1 out of every N (DumpOptions.AsyncSampleRate)
+	// dumps while the queue is full, which blocks like DumpOverflowBlock,
+	// and drops the others like DumpOverflowDrop.
+	DumpOverflowSample DumpOverflowPolicy = dump.OverflowSample
+)
+
+// DefaultRedactHeaders is the headers whose values are redacted in the dump
+// by default.
+var DefaultRedactHeaders = []string{"Authorization", "Cookie", "Set-Cookie"}
+
 // DumpOptions controls the dump behavior.
 type DumpOptions struct {
 	Output               io.Writer
@@ -20,6 +57,70 @@ type DumpOptions struct {
 	ResponseHeader       bool
 	ResponseBody         bool
 	Async                bool
+	// AsyncQueueSize is the max number of the pending async dumps (each
+	// header line or body chunk is one), default is 20. It takes effect
+	// when the dump is enabled.
+	AsyncQueueSize int
+	// AsyncOverflow is the policy when the async dump queue is full,
+	// default is DumpOverflowBlock.
+	AsyncOverflow DumpOverflowPolicy
+	// AsyncSampleRate is the N of DumpOverflowSample, default is 10.
+	AsyncSampleRate int
+	// RedactHeaders is the headers (case-insensitive) whose values are
+	// replaced with "[REDACTED]" in the dump, nil means DefaultRedactHeaders,
+	// set it to an empty slice to dump all headers as is.
+	RedactHeaders []string
+	// RedactBody optionally redacts the request or response body before
+	// dumped. Note the body is dumped in chunks while transferring, so p
+	// may be part of the body.
+	RedactBody func(isRequest bool, p []byte) []byte
+	// Format is the dump output format, default is DumpFormatText.
+	Format DumpFormat
+	// Formatter optionally formats each request attempt after the response
+	// is received, which takes precedence over Format, e.g.
+	// CurlDumpFormatter.
+	Formatter DumpFormatter
+	// MaxRequestBodySize is the max bytes of request body dumped for each
+	// request, the exceeded part is truncated, zero means no limit.
+	MaxRequestBodySize int64
+	// MaxResponseBodySize is the max bytes of response body dumped for each
+	// response, the exceeded part is truncated, zero means no limit.
+	MaxResponseBodySize int64
+	// HTTP2Frames dumps the logical HTTP/2 frames (HEADERS after HPACK
+	// decode, SETTINGS, WINDOW_UPDATE, PRIORITY, RST_STREAM, etc.) with
+	// stream IDs in addition to the HTTP message, which only takes effect in
+	// the client-level dump (e.g. Client.EnableDumpAll) since the frames
+	// belong to the connection. The HEADERS frames include the priority and
+	// the HPACK encoded size of the header block, so the http2 fingerprint
+	// (e.g. the Akamai fingerprint) actually sent can be verified.
+	HTTP2Frames bool
+}
+
+// DumpRotateOptions controls the rotation of dump file.
+type DumpRotateOptions struct {
+	// MaxSize is the max bytes of the dump file before it is rotated,
+	// default is 100MB.
+	MaxSize int64
+	// MaxFiles is the max number of rotated files to keep, the rotated
+	// files are named with the suffix ".1", ".2" and so on, ".1" is the
+	// newest, default is 5.
+	MaxFiles int
+	// Compress compresses the rotated files with gzip (suffix ".gz").
+	Compress bool
+}
+
+func newRotateFile(filename string, opts *DumpRotateOptions) (*dump.RotateFile, error) {
+	var o DumpRotateOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.MaxSize <= 0 {
+		o.MaxSize = 100 << 20
+	}
+	if o.MaxFiles <= 0 {
+		o.MaxFiles = 5
+	}
+	return dump.NewRotateFile(filename, o.MaxSize, o.MaxFiles, o.Compress)
 }
 
 // Clone return a copy of DumpOptions
@@ -83,25 +184,87 @@ func (o dumpOptions) ResponseBodyOutput() io.Writer {
 }
 
 func (o dumpOptions) RequestHeader() bool {
-	return o.DumpOptions.RequestHeader
+	return o.DumpOptions.RequestHeader && o.formatter() == nil
 }
 
 func (o dumpOptions) RequestBody() bool {
-	return o.DumpOptions.RequestBody
+	return o.DumpOptions.RequestBody && o.formatter() == nil
 }
 
 func (o dumpOptions) ResponseHeader() bool {
-	return o.DumpOptions.ResponseHeader
+	return o.DumpOptions.ResponseHeader && o.formatter() == nil
 }
 
 func (o dumpOptions) ResponseBody() bool {
-	return o.DumpOptions.ResponseBody
+	return o.DumpOptions.ResponseBody && o.formatter() == nil
+}
+
+// formatter returns the DumpFormatter, nil means dumping the raw HTTP
+// message while transferring.
+func (o dumpOptions) formatter() DumpFormatter {
+	if o.Formatter != nil {
+		return o.Formatter
+	}
+	if o.Format == DumpFormatJSON {
+		return jsonDumpFormatter{}
+	}
+	return nil
 }
 
 func (o dumpOptions) Async() bool {
 	return o.DumpOptions.Async
 }
 
+func (o dumpOptions) AsyncQueueSize() int {
+	if o.DumpOptions.AsyncQueueSize <= 0 {
+		return 20
+	}
+	return o.DumpOptions.AsyncQueueSize
+}
+
+func (o dumpOptions) AsyncOverflow() int {
+	return int(o.DumpOptions.AsyncOverflow)
+}
+
+func (o dumpOptions) AsyncSampleRate() int {
+	if o.DumpOptions.AsyncSampleRate <= 0 {
+		return 10
+	}
+	return o.DumpOptions.AsyncSampleRate
+}
+
+func (o dumpOptions) RedactHeader(key string) bool {
+	headers := o.DumpOptions.RedactHeaders
+	if headers == nil {
+		headers = DefaultRedactHeaders
+	}
+	for _, h := range headers {
+		if strings.EqualFold(h, key) {
+			return true
+		}
+	}
+	return false
+}
+
+func (o dumpOptions) RedactBody(isRequest bool, p []byte) []byte {
+	if o.DumpOptions.RedactBody == nil || len(p) == 0 {
+		return p
+	}
+	return o.DumpOptions.RedactBody(isRequest, p)
+}
+
+func (o dumpOptions) MaxRequestBodySize() int64 {
+	return o.DumpOptions.MaxRequestBodySize
+}
+
+func (o dumpOptions) MaxResponseBodySize() int64 {
+	return o.DumpOptions.MaxResponseBodySize
+}
+
+func (o dumpOptions) HTTP2Frames() bool {
+	return o.DumpOptions.HTTP2Frames
+}
+
 func (o dumpOptions) Clone() dump.Options {
 	return dumpOptions{o.DumpOptions.Clone()}
 }
@@ -125,3 +288,123 @@ func newDumper(opt *DumpOptions) *dump.Dumper {
 	}
 	return dump.NewDumper(dumpOptions{opt})
 }
+
+// DumpEntry is the details of a request attempt dumped with DumpFormatJSON
+// or DumpFormatter.
+type DumpEntry struct {
+	Time           time.Time   `json:"time"`
+	Attempt        int         `json:"attempt"`
+	RequestID      string      `json:"request_id,omitempty"`
+	Method         string      `json:"method"`
+	URL            string      `json:"url"`
+	Proto          string      `json:"proto,omitempty"`
+	StatusCode     int         `json:"status_code,omitempty"`
+	Status         string      `json:"status,omitempty"`
+	RequestHeader  http.Header `json:"request_header,omitempty"`
+	RequestBody    []byte      `json:"request_body,omitempty"`
+	ResponseHeader http.Header `json:"response_header,omitempty"`
+	ResponseBody   []byte      `json:"response_body,omitempty"`
+	Timings        DumpTimings `json:"timings"`
+	RemoteAddr     string      `json:"remote_addr,omitempty"`
+	Error          string      `json:"error,omitempty"`
+}
+
+// DumpTimings is the timings of DumpEntry, the detailed timings are only
+// available if trace is enabled.
+type DumpTimings struct {
+	Total        time.Duration `json:"total"`
+	DNSLookup    time.Duration `json:"dns_lookup,omitempty"`
+	TCPConnect   time.Duration `json:"tcp_connect,omitempty"`
+	TLSHandshake time.Duration `json:"tls_handshake,omitempty"`
+	FirstByte    time.Duration `json:"first_byte,omitempty"`
+	BodyRead     time.Duration `json:"body_read,omitempty"`
+}
+
+func (o *DumpOptions) redactHeader(header http.Header) http.Header {
+	header = header.Clone()
+	opt := dumpOptions{o}
+	for k := range header {
+		if opt.RedactHeader(k) {
+			header[k] = []string{dump.RedactedValue}
+		}
+	}
+	return header
+}
+
+func (o *DumpOptions) newDumpEntry(resp *Response) *DumpEntry {
+	r := resp.Request
+	opt := dumpOptions{o}
+	e := &DumpEntry{
+		Time:      r.StartTime,
+		Attempt:   r.RetryAttempt,
+		RequestID: r.requestID,
+		Method:    r.RawRequest.Method,
+		URL:       r.RawRequest.URL.String(),
+	}
+	if o.RequestHeader {
+		e.RequestHeader = o.redactHeader(r.RawRequest.Header)
+	}
+	if o.RequestBody && len(r.Body) > 0 {
+		e.RequestBody = opt.RedactBody(true, truncateBody(r.Body, o.MaxRequestBodySize))
+	}
+	if resp.Response != nil {
+		e.Proto = resp.Proto
+		e.StatusCode = resp.StatusCode
+		e.Status = resp.Status
+		if o.ResponseHeader {
+			e.ResponseHeader = o.redactHeader(resp.Header)
+		}
+		if o.ResponseBody && len(resp.body) > 0 {
+			e.ResponseBody = opt.RedactBody(false, truncateBody(resp.body, o.MaxResponseBodySize))
+		}
+	}
+	if resp.Err != nil {
+		e.Error = resp.Err.Error()
+	}
+	if r.trace != nil {
+		ti := r.TraceInfo()
+		e.Timings = DumpTimings{
+			Total:        ti.TotalTime,
+			DNSLookup:    ti.DNSLookupTime,
+			TCPConnect:   ti.TCPConnectTime,
+			TLSHandshake: ti.TLSHandshakeTime,
+			FirstByte:    ti.TimeToFirstByte,
+			BodyRead:     ti.BodyReadTime,
+		}
+	} else {
+		end := resp.receivedAt
+		if end.IsZero() {
+			end = time.Now()
+		}
+		e.Timings.Total = end.Sub(r.StartTime)
+	}
+	if addr := resp.RemoteAddr(); addr != nil {
+		e.RemoteAddr = addr.String()
+	}
+	return e
+}
+
+func truncateBody(body []byte, limit int64) []byte {
+	if limit > 0 && int64(len(body)) > limit {
+		return body[:limit]
+	}
+	return body
+}
+
+// dumpFormatted dumps the request attempt with the dumpers which have
+// DumpFormatter.
+func dumpFormatted(resp *Response, d *dump.Dumper) {
+	r := resp.Request
+	if r.RawRequest == nil {
+		return
+	}
+	for _, d := range dump.GetDumpers(r.RawRequest.Context(), d) {
+		opt, ok := d.Options.(dumpOptions)
+		if !ok {
+			continue
+		}
+		if f := opt.formatter(); f != nil {
+			d.DumpDefault(f.FormatDump(opt.newDumpEntry(resp)))
+		}
+	}
+}
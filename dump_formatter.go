@@ -0,0 +1,195 @@
+package restys
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// DumpFormatter formats the request attempt to dump, see
+// DumpOptions.Formatter.
+type DumpFormatter interface {
+	FormatDump(e *DumpEntry) []byte
+}
+
+// DumpFormatterFunc is a DumpFormatter implemented by function.
+type DumpFormatterFunc func(e *DumpEntry) []byte
+
+// FormatDump implements DumpFormatter.
+func (f DumpFormatterFunc) FormatDump(e *DumpEntry) []byte {
+	return f(e)
+}
+
+type jsonDumpFormatter struct{}
+
+func (jsonDumpFormatter) FormatDump(e *DumpEntry) []byte {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return nil
+	}
+	return append(b, '\n')
+}
+
+const (
+	colorReset  = "\x1b[0m"
+	colorDim    = "\x1b[2m"
+	colorRed    = "\x1b[31m"
+	colorGreen  = "\x1b[32m"
+	colorYellow = "\x1b[33m"
+	colorCyan   = "\x1b[36m"
+)
+
+// CurlDumpFormatter formats the request attempt like `curl -v`, the request
+// lines are prefixed with ">", the response lines are prefixed with "<",
+// the info lines are prefixed with "*", and the binary bodies are collapsed.
+type CurlDumpFormatter struct {
+	// Color enables the ANSI color codes.
+	Color bool
+}
+
+// FormatDump implements DumpFormatter.
+func (f CurlDumpFormatter) FormatDump(e *DumpEntry) []byte {
+	buf := new(bytes.Buffer)
+	paint := func(color, s string) string {
+		if !f.Color {
+			return s
+		}
+		return color + s + colorReset
+	}
+
+	info := fmt.Sprintf("* %s %s", e.Method, e.URL)
+	if e.RequestID != "" {
+		info += fmt.Sprintf(" [%s]", e.RequestID)
+	}
+	if e.Attempt > 0 {
+		info += fmt.Sprintf(" (retry %d)", e.Attempt)
+	}
+	if e.RemoteAddr != "" {
+		info += " via " + e.RemoteAddr
+	}
+	buf.WriteString(paint(colorDim, info) + "\n")
+
+	target := e.URL
+	if u, err := url.Parse(e.URL); err == nil {
+		target = u.RequestURI()
+	}
+	proto := e.Proto
+	if proto == "" {
+		proto = "HTTP/1.1"
+	}
+	buf.WriteString(paint(colorCyan, fmt.Sprintf("> %s %s %s", e.Method, target, proto)) + "\n")
+	writeCurlHeader(buf, ">", e.RequestHeader, func(s string) string { return paint(colorCyan, s) })
+	if e.RequestHeader != nil {
+		buf.WriteString(paint(colorCyan, ">") + "\n")
+	}
+	writeCurlBody(buf, e.RequestBody, e.RequestHeader.Get("Content-Type"))
+
+	if e.StatusCode > 0 {
+		color := colorGreen
+		if e.StatusCode >= 500 {
+			color = colorRed
+		} else if e.StatusCode >= 300 {
+			color = colorYellow
+		}
+		buf.WriteString(paint(color, fmt.Sprintf("< %s %s", e.Proto, e.Status)) + "\n")
+		writeCurlHeader(buf, "<", e.ResponseHeader, func(s string) string { return paint(color, s) })
+		if e.ResponseHeader != nil {
+			buf.WriteString(paint(color, "<") + "\n")
+		}
+		writeCurlBody(buf, e.ResponseBody, e.ResponseHeader.Get("Content-Type"))
+	}
+	if e.Error != "" {
+		buf.WriteString(paint(colorRed, "* error: "+e.Error) + "\n")
+	}
+
+	t := e.Timings
+	timing := fmt.Sprintf("* total %v", t.Total)
+	var details []string
+	for _, d := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"dns", t.DNSLookup},
+		{"connect", t.TCPConnect},
+		{"tls", t.TLSHandshake},
+		{"ttfb", t.FirstByte},
+		{"body", t.BodyRead},
+	} {
+		if d.value > 0 {
+			details = append(details, fmt.Sprintf("%s %v", d.name, d.value))
+		}
+	}
+	if len(details) > 0 {
+		timing += " (" + strings.Join(details, ", ") + ")"
+	}
+	buf.WriteString(paint(colorDim, timing) + "\n\n")
+	return buf.Bytes()
+}
+
+func writeCurlHeader(buf *bytes.Buffer, prefix string, header http.Header, paint func(string) string) {
+	keys := make([]string, 0, len(header))
+	for k := range header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range header[k] {
+			buf.WriteString(paint(fmt.Sprintf("%s %s: %s", prefix, k, v)) + "\n")
+		}
+	}
+}
+
+func writeCurlBody(buf *bytes.Buffer, body []byte, contentType string) {
+	if len(body) == 0 {
+		return
+	}
+	if isBinaryBody(body, contentType) {
+		fmt.Fprintf(buf, "[binary body of %d bytes]\n", len(body))
+		return
+	}
+	buf.Write(body)
+	if body[len(body)-1] != '\n' {
+		buf.WriteByte('\n')
+	}
+}
+
+// isBinaryBody reports whether the body is binary according to the
+// content type, or the content if the content type is not textual.
+func isBinaryBody(body []byte, contentType string) bool {
+	ct := strings.ToLower(contentType)
+	for _, text := range []string{"text/", "json", "xml", "javascript", "x-www-form-urlencoded"} {
+		if strings.Contains(ct, text) {
+			return false
+		}
+	}
+	if len(body) > 512 {
+		body = body[:512]
+		// trim the incomplete rune at the end
+		for i := 1; i < utf8.UTFMax && !utf8.Valid(body); i++ {
+			body = body[:len(body)-1]
+		}
+	}
+	if !utf8.Valid(body) {
+		return true
+	}
+	return bytes.ContainsFunc(body, func(r rune) bool {
+		return r < 0x20 && r != '\t' && r != '\r' && r != '\n'
+	})
+}
+
+// isTerminal reports whether the writer is a terminal.
+func isTerminal(w any) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,70 @@
+package restys
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/luoxk/restys/internal/tests"
+)
+
+func TestCurlDumpFormatter(t *testing.T) {
+	e := &DumpEntry{
+		Method:         http.MethodPost,
+		URL:            "https://example.com/api?a=b",
+		Proto:          "HTTP/2.0",
+		StatusCode:     http.StatusOK,
+		Status:         "200 OK",
+		RequestHeader:  http.Header{"Content-Type": {"application/json"}},
+		RequestBody:    []byte(`{"k":"v"}`),
+		ResponseHeader: http.Header{"Content-Type": {"image/png"}},
+		ResponseBody:   []byte{0x89, 'P', 'N', 'G', 0x0d, 0x0a, 0x1a, 0x0a, 0x00},
+		Timings:        DumpTimings{Total: 3 * time.Millisecond, FirstByte: 2 * time.Millisecond},
+	}
+	out := string(CurlDumpFormatter{}.FormatDump(e))
+	for _, s := range []string{
+		"* POST https://example.com/api?a=b\n",
+		"> POST /api?a=b HTTP/2.0\n",
+		"> Content-Type: application/json\n>\n{\"k\":\"v\"}\n",
+		"< HTTP/2.0 200 OK\n",
+		"< Content-Type: image/png\n<\n[binary body of 9 bytes]\n",
+		"* total 3ms (ttfb 2ms)\n",
+	} {
+		tests.AssertContains(t, out, strings.ToLower(s), true)
+	}
+	tests.AssertEqual(t, false, strings.Contains(out, "\x1b["))
+
+	out = string(CurlDumpFormatter{Color: true}.FormatDump(e))
+	tests.AssertEqual(t, true, strings.Contains(out, colorGreen+"< HTTP/2.0 200 OK"+colorReset))
+}
+
+func TestDevModeDumpFormatter(t *testing.T) {
+	testWithAllTransport(t, func(t *testing.T, c *Client) {
+		buf := new(bytes.Buffer)
+		c.EnableDumpAllTo(buf).DevMode()
+		resp, err := c.R().SetBody("test body").Post("/")
+		assertSuccess(t, resp, err)
+		dump := buf.String()
+		tests.AssertContains(t, dump, "> post / ", true)
+		tests.AssertContains(t, dump, "test body\n< ", true)
+		tests.AssertContains(t, dump, strings.ToLower("< "+resp.Proto+" 200 OK"), true)
+		tests.AssertContains(t, dump, "testpost: text response\n* total ", true)
+		tests.AssertEqual(t, false, strings.Contains(dump, "\x1b["))
+	})
+}
+
+func TestDumpFormatterFunc(t *testing.T) {
+	buf := new(bytes.Buffer)
+	c := tc()
+	c.SetCommonDumpOptions(&DumpOptions{
+		Output: buf,
+		Formatter: DumpFormatterFunc(func(e *DumpEntry) []byte {
+			return []byte(e.Method + " " + e.Status + "\n")
+		}),
+	}).EnableDumpAll()
+	resp, err := c.R().Get("/")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "GET 200 OK\n", buf.String())
+}
@@ -0,0 +1,196 @@
+package restys
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"slices"
+	"sync"
+	"time"
+
+	utls "github.com/refraction-networking/utls"
+
+	"github.com/luoxk/restys/internal/dnsutil"
+)
+
+// ECHConfigResolver returns the ECHConfigList of the host, or nil if the
+// host doesn't support ECH, see Client.EnableECH.
+type ECHConfigResolver func(ctx context.Context, host string) ([]byte, error)
+
+// echConfigTTL is how long the resolved ECHConfigList is cached.
+const echConfigTTL = 5 * time.Minute
+
+type echCacheEntry struct {
+	configList []byte
+	expires    time.Time
+}
+
+type echHandler struct {
+	resolver ECHConfigResolver // nil means dnsECHConfigResolver
+
+	mu    sync.Mutex
+	cache map[string]echCacheEntry
+}
+
+// dnsECHConfigResolver queries the HTTPS record of the host from the system
+// name server, the dial function of t.Resolver (e.g. SetDNSServers) is used
+// if set.
+func (t *Transport) dnsECHConfigResolver(ctx context.Context, host string) ([]byte, error) {
+	var d net.Dialer
+	dial := d.DialContext
+	if t.Resolver != nil && t.Resolver.Dial != nil {
+		dial = t.Resolver.Dial
+	}
+	configList, err := dnsutil.LookupECHConfigList(ctx, dial, dnsutil.SystemNameServer(), host)
+	if err == dnsutil.ErrNotFound {
+		return nil, nil
+	}
+	return configList, err
+}
+
+// configList returns the cached ECHConfigList of host, or resolves it.
+func (h *echHandler) configList(ctx context.Context, t *Transport, host string) ([]byte, error) {
+	h.mu.Lock()
+	entry, ok := h.cache[host]
+	h.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.configList, nil
+	}
+	resolve := h.resolver
+	if resolve == nil {
+		resolve = t.dnsECHConfigResolver
+	}
+	configList, err := resolve(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	h.update(host, configList)
+	return configList, nil
+}
+
+func (h *echHandler) update(host string, configList []byte) {
+	h.mu.Lock()
+	h.cache[host] = echCacheEntry{configList: configList, expires: time.Now().Add(echConfigTTL)}
+	h.mu.Unlock()
+}
+
+// echConfigList returns the ECHConfigList of host, it's nil if ECH is
+// disabled, the host doesn't support ECH or the lookup fails, the handshake
+// falls back to the plain ClientHello then.
+func (t *Transport) echConfigList(ctx context.Context, host string) []byte {
+	if t.ech == nil {
+		return nil
+	}
+	configList, err := t.ech.configList(ctx, t, host)
+	if err != nil {
+		if t.Debugf != nil {
+			t.Debugf("failed to resolve ech config of %s, fallback to no ech: %v", host, err)
+		}
+		return nil
+	}
+	if len(configList) == 0 {
+		return nil
+	}
+	return configList
+}
+
+// applyECH sets the ECHConfigList of host to cfg.
+func (t *Transport) applyECH(ctx context.Context, cfg *tls.Config, host string) {
+	configList := t.echConfigList(ctx, host)
+	if configList == nil {
+		return
+	}
+	cfg.EncryptedClientHelloConfigList = configList
+	// ECH requires TLS 1.3.
+	cfg.MinVersion = tls.VersionTLS13
+}
+
+// applyUTLSECH sets the ECHConfigList of host to the utls cfg if the
+// ClientHello has the ECH extension (e.g. the GREASE ECH of Chrome), which
+// is replaced by the real one carrying the encrypted inner ClientHello, so
+// the fingerprint is kept. The plain ClientHello is sent if it has no ECH
+// extension.
+func (t *Transport) applyUTLSECH(ctx context.Context, cfg *utls.Config, host string, extensions []utls.TLSExtension) {
+	hasECH := slices.ContainsFunc(extensions, func(ext utls.TLSExtension) bool {
+		_, ok := ext.(utls.EncryptedClientHelloExtension)
+		return ok
+	})
+	if !hasECH {
+		return
+	}
+	configList := t.echConfigList(ctx, host)
+	if configList == nil {
+		return
+	}
+	cfg.EncryptedClientHelloConfigList = configList
+	cfg.MinVersion = utls.VersionTLS13
+	if cfg.MaxVersion != 0 && cfg.MaxVersion < utls.VersionTLS13 {
+		cfg.MaxVersion = utls.VersionTLS13
+	}
+}
+
+// handleECHRejection caches the retry configs of the rejected ECH, so the
+// next connection uses them.
+func (t *Transport) handleECHRejection(host string, err error) {
+	if t.ech == nil {
+		return
+	}
+	var echErr *tls.ECHRejectionError
+	var uechErr *utls.ECHRejectionError
+	switch {
+	case errors.As(err, &echErr):
+		t.ech.update(host, echErr.RetryConfigList)
+	case errors.As(err, &uechErr):
+		t.ech.update(host, uechErr.RetryConfigList)
+	}
+}
+
+// applyHTTP3ECH sets the ECH of the HTTP/3 connections.
+func (t *Transport) applyHTTP3ECH() {
+	if t.t3 == nil {
+		return
+	}
+	if t.ech == nil {
+		t.t3.ECHConfigList, t.t3.OnECHRejection = nil, nil
+		return
+	}
+	t.t3.ECHConfigList = t.echConfigList
+	t.t3.OnECHRejection = t.handleECHRejection
+}
+
+// EnableECH enables the Encrypted Client Hello, the ECHConfigList of the
+// target host is fetched by resolver and cached, resolver can be nil to
+// query the DNS HTTPS record of the host. The plain ClientHello is sent if
+// the host has no ECHConfigList. If the server rejects the ECH, the
+// connection fails with *tls.ECHRejectionError, and the retry configs
+// offered by the server are used for the next connection.
+//
+// It applies to HTTP/3 and the tls fingerprint (e.g. SetTLSFingerprint and
+// SetJa3WithStr) too, the ECH extension of the fingerprint (e.g. the GREASE
+// ECH of Chrome) carries the real ECH, and the fingerprint without the ECH
+// extension sends the plain ClientHello.
+func (t *Transport) EnableECH(resolver ECHConfigResolver) *Transport {
+	t.ech = &echHandler{resolver: resolver, cache: make(map[string]echCacheEntry)}
+	t.applyHTTP3ECH()
+	return t
+}
+
+// DisableECH disables the Encrypted Client Hello (disabled by default).
+func (t *Transport) DisableECH() *Transport {
+	t.ech = nil
+	t.applyHTTP3ECH()
+	return t
+}
+
+// EnableECH enables the Encrypted Client Hello, see Transport.EnableECH.
+func (c *Client) EnableECH(resolver ECHConfigResolver) *Client {
+	c.Transport.EnableECH(resolver)
+	return c
+}
+
+// DisableECH disables the Encrypted Client Hello (disabled by default).
+func (c *Client) DisableECH() *Client {
+	c.Transport.DisableECH()
+	return c
+}
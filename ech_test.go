@@ -0,0 +1,153 @@
+package restys
+
+import (
+	"context"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	utls "github.com/refraction-networking/utls"
+
+	"github.com/luoxk/restys/internal/http3"
+	"github.com/luoxk/restys/internal/tests"
+)
+
+// testECHConfig returns an ECHConfig with the X25519 publicKey and the
+// public name "public.example".
+func testECHConfig(publicKey []byte) []byte {
+	publicName := "public.example"
+	var contents []byte
+	contents = append(contents, 1)          // config_id
+	contents = append(contents, 0x00, 0x20) // DHKEM(X25519, HKDF-SHA256)
+	contents = append(contents, 0, byte(len(publicKey)))
+	contents = append(contents, publicKey...)
+	contents = append(contents, 0, 4, 0x00, 0x01, 0x00, 0x01) // HKDF-SHA256, AES-128-GCM
+	contents = append(contents, 0)                            // maximum_name_length
+	contents = append(contents, byte(len(publicName)))
+	contents = append(contents, publicName...)
+	contents = append(contents, 0, 0) // extensions
+
+	config := []byte{0xfe, 0x0d, byte(len(contents) >> 8), byte(len(contents))}
+	return append(config, contents...)
+}
+
+// testECHConfigList returns an ECHConfigList of the ECHConfig with a dummy
+// X25519 key, see testECHConfig.
+func testECHConfigList() []byte {
+	publicKey := make([]byte, 32)
+	for i := range publicKey {
+		publicKey[i] = byte(i + 1)
+	}
+	return echConfigListOf(testECHConfig(publicKey))
+}
+
+func echConfigListOf(config []byte) []byte {
+	return append([]byte{byte(len(config) >> 8), byte(len(config))}, config...)
+}
+
+func TestECH(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	var lookups atomic.Int32
+	c := C().EnableInsecureSkipVerify().EnableECH(func(ctx context.Context, host string) ([]byte, error) {
+		lookups.Add(1)
+		tests.AssertEqual(t, "127.0.0.1", host)
+		return testECHConfigList(), nil
+	})
+	// the test server doesn't support ECH, so it's rejected, and the
+	// certificate is verified against the public name of the ECH config.
+	_, err := c.R().Get(ts.URL)
+	tests.AssertErrorContains(t, err, "public.example")
+	_, err = c.R().Get(ts.URL)
+	tests.AssertErrorContains(t, err, "public.example")
+	tests.AssertEqual(t, int32(1), lookups.Load())
+
+	// the plain ClientHello is sent if there is no ECHConfigList.
+	c = C().EnableInsecureSkipVerify().EnableECH(func(ctx context.Context, host string) ([]byte, error) {
+		return nil, nil
+	})
+	resp, err := c.R().Get(ts.URL)
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "ok", resp.String())
+
+	c = C().EnableInsecureSkipVerify().EnableECH(func(ctx context.Context, host string) ([]byte, error) {
+		return nil, errors.New("lookup failed")
+	})
+	resp, err = c.R().Get(ts.URL)
+	assertSuccess(t, resp, err)
+
+	c.DisableECH()
+	tests.AssertIsNil(t, c.ech)
+}
+
+func TestECHWithTLSFingerprint(t *testing.T) {
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	tests.AssertNoError(t, err)
+	config := testECHConfig(key.PublicKey().Bytes())
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strconv.FormatBool(r.TLS.ECHAccepted)))
+	}))
+	ts.TLS = &tls.Config{
+		EncryptedClientHelloKeys: []tls.EncryptedClientHelloKey{{Config: config, PrivateKey: key.Bytes()}},
+	}
+	ts.StartTLS()
+	defer ts.Close()
+	resolver := func(ctx context.Context, host string) ([]byte, error) {
+		return echConfigListOf(config), nil
+	}
+
+	// the GREASE ECH extension of the fingerprint carries the real ECH.
+	for _, c := range []*Client{
+		C().SetTLSFingerprintChrome(),
+		C().SetJa3WithStr(chrome131Ja3),
+	} {
+		c.EnableInsecureSkipVerify().EnableECH(resolver)
+		resp, err := c.R().Get(ts.URL)
+		assertSuccess(t, resp, err)
+		tests.AssertEqual(t, "true", resp.String())
+	}
+
+	// the fingerprint without the ECH extension sends the plain ClientHello.
+	c := C().SetTLSFingerprint(utls.HelloChrome_100).EnableInsecureSkipVerify().EnableECH(resolver)
+	resp, err := c.R().Get(ts.URL)
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "false", resp.String())
+}
+
+func TestECHWithHTTP3(t *testing.T) {
+	addr := serveHTTP3(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}), false)
+
+	// HTTP/3 may be not supported by the go version.
+	c := tc().EnableECH(func(ctx context.Context, host string) ([]byte, error) {
+		return testECHConfigList(), nil
+	})
+	c.t3 = &http3.RoundTripper{
+		Options:         &c.Transport.Options,
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}
+	defer c.t3.Close()
+	c.applyHTTP3ECH()
+	req, err := http.NewRequest(http.MethodGet, "https://"+addr+"/", nil)
+	tests.AssertNoError(t, err)
+	// the test server doesn't support ECH, so it's rejected, and the
+	// certificate is verified against the public name of the ECH config.
+	_, err = c.t3.RoundTrip(req)
+	tests.AssertErrorContains(t, err, "public.example")
+
+	c.DisableECH()
+	tests.AssertIsNil(t, c.t3.ECHConfigList)
+	resp, err := c.t3.RoundTrip(req)
+	tests.AssertNoError(t, err)
+	resp.Body.Close()
+}
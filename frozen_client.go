@@ -0,0 +1,127 @@
+package restys
+
+import (
+	"fmt"
+	"net/http"
+	urlpkg "net/url"
+)
+
+// FrozenClient is an immutable handle of Client, which is the recommended
+// way to share a client between goroutines.
+//
+// The setters of Client mutate the shared state (e.g. the common headers
+// and the fingerprint), which races with the requests in flight. Instead,
+// configure a Client first, then call Client.Freeze to get a FrozenClient,
+// fire requests with FrozenClient.R concurrently, and derive new handles
+// with the With-style methods, which never modify the existing handle:
+//
+//	fc := req.C().SetTimeout(5 * time.Second).Freeze()
+//	userClient := fc.WithHeader("Authorization", "Bearer "+token)
+//	go userClient.R().Get(url)
+//
+// The handles derived by WithHeader, WithHeaders and WithCookies are cheap,
+// they share the underlying client and its connections; WithProxy and With
+// derive a new underlying client (see Client.Clone).
+type FrozenClient struct {
+	client  *Client
+	headers http.Header
+	cookies []*http.Cookie
+}
+
+// Freeze returns a FrozenClient with a snapshot of the client, the later
+// modification of the client does not affect the FrozenClient.
+func (c *Client) Freeze() *FrozenClient {
+	return &FrozenClient{client: c.Clone()}
+}
+
+// R create a new request, which is safe for concurrent use.
+func (f *FrozenClient) R() *Request {
+	r := f.client.R()
+	for k, vs := range f.headers {
+		for _, v := range vs {
+			r.SetHeaderNonCanonical(k, v)
+		}
+	}
+	if len(f.cookies) > 0 {
+		r.SetCookies(f.cookies...)
+	}
+	return r
+}
+
+func (f *FrozenClient) derive() *FrozenClient {
+	return &FrozenClient{
+		client:  f.client,
+		headers: f.headers.Clone(),
+		cookies: cloneSlice(f.cookies),
+	}
+}
+
+// WithHeader returns a FrozenClient derived from f, which sets the header
+// for each request.
+func (f *FrozenClient) WithHeader(key, value string) *FrozenClient {
+	ff := f.derive()
+	if ff.headers == nil {
+		ff.headers = make(http.Header)
+	}
+	ff.headers.Set(key, value)
+	return ff
+}
+
+// WithHeaders returns a FrozenClient derived from f, which sets the headers
+// for each request.
+func (f *FrozenClient) WithHeaders(hdrs map[string]string) *FrozenClient {
+	ff := f.derive()
+	if ff.headers == nil {
+		ff.headers = make(http.Header)
+	}
+	for k, v := range hdrs {
+		ff.headers.Set(k, v)
+	}
+	return ff
+}
+
+// WithCookies returns a FrozenClient derived from f, which sets the cookies
+// for each request.
+func (f *FrozenClient) WithCookies(cookies ...*http.Cookie) *FrozenClient {
+	ff := f.derive()
+	ff.cookies = append(ff.cookies, cookies...)
+	return ff
+}
+
+// WithProxy returns a FrozenClient derived from f which uses the proxy,
+// the derived FrozenClient does not share connections with f.
+func (f *FrozenClient) WithProxy(proxyURL string) (*FrozenClient, error) {
+	u, err := urlpkg.Parse(proxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse proxy url %s: %w", proxyURL, err)
+	}
+	return f.With(func(c *Client) {
+		c.SetProxy(http.ProxyURL(u))
+	}), nil
+}
+
+// With returns a FrozenClient derived from f, which is configured by
+// calling fn with a clone of the underlying client, the derived
+// FrozenClient does not share connections with f.
+func (f *FrozenClient) With(fn func(c *Client)) *FrozenClient {
+	ff := f.derive()
+	ff.client = f.client.Clone()
+	fn(ff.client)
+	return ff
+}
+
+// Unfreeze returns a new Client which is a clone of the underlying client
+// with the headers and cookies of f applied as the common ones.
+func (f *FrozenClient) Unfreeze() *Client {
+	c := f.client.Clone()
+	if len(f.headers) > 0 && c.Headers == nil {
+		c.Headers = make(http.Header)
+	}
+	for k, vs := range f.headers {
+		c.Headers[k] = cloneSlice(vs)
+	}
+	if len(f.cookies) > 0 {
+		c.SetCommonCookies(f.cookies...)
+	}
+	return c
+}
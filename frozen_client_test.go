@@ -0,0 +1,56 @@
+package restys
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/luoxk/restys/internal/tests"
+)
+
+func TestFrozenClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cookie, _ := r.Cookie("session")
+		if cookie != nil {
+			w.Header().Set("X-Session", cookie.Value)
+		}
+		w.Write([]byte(r.Header.Get("X-User")))
+	}))
+	defer server.Close()
+
+	c := C().SetCommonHeader("X-User", "base")
+	fc := c.Freeze()
+	c.SetCommonHeader("X-User", "mutated")
+
+	resp, err := fc.R().Get(server.URL)
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, "base", resp.String())
+
+	var wg sync.WaitGroup
+	for _, user := range []string{"alice", "bob", "carol"} {
+		wg.Add(1)
+		go func(user string) {
+			defer wg.Done()
+			uc := fc.WithHeader("X-User", user).WithCookies(&http.Cookie{Name: "session", Value: user})
+			resp, err := uc.R().Get(server.URL)
+			tests.AssertNoError(t, err)
+			tests.AssertEqual(t, user, resp.String())
+			tests.AssertEqual(t, user, resp.Header.Get("X-Session"))
+		}(user)
+	}
+	wg.Wait()
+
+	resp, err = fc.R().Get(server.URL)
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, "base", resp.String())
+	tests.AssertEqual(t, "", resp.Header.Get("X-Session"))
+
+	uc := fc.WithHeaders(map[string]string{"X-User": "dave"})
+	tests.AssertEqual(t, "dave", uc.Unfreeze().Headers.Get("X-User"))
+
+	_, err = fc.WithProxy("http://127.0.0.1:8080")
+	tests.AssertNoError(t, err)
+	_, err = fc.WithProxy("://bad")
+	tests.AssertEqual(t, true, err != nil)
+}
@@ -0,0 +1,354 @@
+// Package ftp provides the "ftp" and "sftp" protocols for restys, so the
+// mirror lists which mix http and ftp URLs can be downloaded through the
+// same Response and SetOutputFile machinery, including the download
+// callbacks and retries:
+//
+//	client := ftp.Register(restys.C(), &ssh.ClientConfig{
+//	    User:            "mirror",
+//	    Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+//	    HostKeyCallback: ssh.FixedHostKey(hostKey),
+//	})
+//	client.R().SetOutputFile("file.tar.gz").Get("ftp://ftp.example.com/pub/file.tar.gz")
+//	client.R().SetOutputFile("file.tar.gz").Get("sftp://example.com/~/file.tar.gz")
+//
+// Only the GET and HEAD methods are supported. The "Range: bytes=N-"
+// request header resumes the download from offset N, which is replied with
+// "206 Partial Content". The missing files are replied with "404 Not Found",
+// the other failures are returned as errors, so they can be retried.
+package ftp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/textproto"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/luoxk/restys"
+	"golang.org/x/crypto/ssh"
+)
+
+// Register registers the "ftp" protocol, and the "sftp" protocol if
+// sftpConfig is not nil, into the client.
+func Register(c *restys.Client, sftpConfig *ssh.ClientConfig) *restys.Client {
+	c.RegisterProtocol("ftp", &Transport{})
+	if sftpConfig != nil {
+		c.RegisterProtocol("sftp", &SFTPTransport{Config: sftpConfig})
+	}
+	return c
+}
+
+// DialContextFunc dials the network address with context.
+type DialContextFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+func dial(ctx context.Context, fn DialContextFunc, addr string) (net.Conn, error) {
+	if fn != nil {
+		return fn(ctx, "tcp", addr)
+	}
+	var d net.Dialer
+	return d.DialContext(ctx, "tcp", addr)
+}
+
+// Transport is the http.RoundTripper of the "ftp" protocol, the user and
+// password are taken from the URL, anonymous by default. The data
+// connection uses the passive mode (EPSV, or PASV as fallback).
+type Transport struct {
+	// DialContext dials the control and data connections, nil means using
+	// net.Dialer.
+	DialContext DialContextFunc
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (resp *http.Response, err error) {
+	if err = checkRequest(req); err != nil {
+		return nil, err
+	}
+	ctx := req.Context()
+	addr := hostPort(req.URL.Host, "21")
+	conn, err := dial(ctx, t.DialContext, addr)
+	if err != nil {
+		return nil, err
+	}
+	s := &ftpSession{conn: conn, text: textproto.NewConn(conn)}
+	s.stop = context.AfterFunc(ctx, s.abort)
+	defer func() {
+		if resp == nil || resp.Body == http.NoBody {
+			s.close()
+		}
+		if err != nil && ctx.Err() != nil {
+			err = ctx.Err()
+		}
+	}()
+
+	if _, _, err = s.text.ReadResponse(220); err != nil {
+		return nil, err
+	}
+	user, pass := "anonymous", "anonymous@"
+	if u := req.URL.User; u != nil {
+		user = u.Username()
+		if p, ok := u.Password(); ok {
+			pass = p
+		}
+	}
+	code, _, err := s.cmd("USER " + user)
+	if err != nil {
+		return nil, err
+	}
+	if code == 331 {
+		code, _, err = s.cmd("PASS " + pass)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if code == 530 {
+		return newResponse(req, http.StatusUnauthorized, nil, -1, nil), nil
+	}
+	if code != 230 && code != 202 {
+		return nil, fmt.Errorf("ftp: login failed with reply %d", code)
+	}
+	if err = s.expect(200, "TYPE I"); err != nil {
+		return nil, err
+	}
+
+	name := req.URL.Path
+	header := make(http.Header)
+	size := int64(-1)
+	if code, msg, err := s.cmd("SIZE " + name); err != nil {
+		return nil, err
+	} else if code == 213 {
+		size, _ = strconv.ParseInt(strings.TrimSpace(msg), 10, 64)
+	} else if code == 550 {
+		return newResponse(req, http.StatusNotFound, nil, -1, nil), nil
+	}
+	if code, msg, err := s.cmd("MDTM " + name); err != nil {
+		return nil, err
+	} else if code == 213 {
+		if mtime, err := time.Parse("20060102150405", strings.TrimSpace(msg)); err == nil {
+			header.Set("Last-Modified", mtime.UTC().Format(http.TimeFormat))
+		}
+	}
+	if req.Method == http.MethodHead {
+		s.quit()
+		return newResponse(req, http.StatusOK, header, size, nil), nil
+	}
+
+	status := http.StatusOK
+	offset, ok := parseRange(req.Header.Get("Range"))
+	if ok && offset > 0 && (size < 0 || offset < size) {
+		if err = s.expect(350, "REST "+strconv.FormatInt(offset, 10)); err != nil {
+			return nil, err
+		}
+		status = http.StatusPartialContent
+		if size >= 0 {
+			header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, size-1, size))
+			size -= offset
+		}
+	}
+
+	dataAddr, err := s.passive()
+	if err != nil {
+		return nil, err
+	}
+	data, err := dial(ctx, t.DialContext, dataAddr)
+	if err != nil {
+		return nil, err
+	}
+	s.setData(data)
+	code, msg, err := s.cmd("RETR " + name)
+	if err != nil {
+		return nil, err
+	}
+	switch code {
+	case 125, 150:
+	case 550:
+		return newResponse(req, http.StatusNotFound, nil, -1, nil), nil
+	default:
+		return nil, fmt.Errorf("ftp: RETR failed with reply %d %s", code, msg)
+	}
+	return newResponse(req, status, header, size, &ftpBody{s: s}), nil
+}
+
+// ftpSession is the control connection and the data connection.
+type ftpSession struct {
+	conn net.Conn
+	text *textproto.Conn
+	stop func() bool
+
+	mu   sync.Mutex
+	data net.Conn
+}
+
+func (s *ftpSession) setData(data net.Conn) {
+	s.mu.Lock()
+	s.data = data
+	s.mu.Unlock()
+}
+
+// abort closes the connections when the context is done.
+func (s *ftpSession) abort() {
+	s.mu.Lock()
+	if s.data != nil {
+		s.data.Close()
+	}
+	s.mu.Unlock()
+	s.conn.Close()
+}
+
+func (s *ftpSession) close() {
+	s.stop()
+	s.abort()
+}
+
+func (s *ftpSession) cmd(line string) (int, string, error) {
+	if err := s.text.PrintfLine("%s", line); err != nil {
+		return 0, "", err
+	}
+	return s.text.ReadResponse(0)
+}
+
+func (s *ftpSession) expect(code int, line string) error {
+	got, msg, err := s.cmd(line)
+	if err != nil {
+		return err
+	}
+	if got != code {
+		verb, _, _ := strings.Cut(line, " ")
+		return fmt.Errorf("ftp: %s failed with reply %d %s", verb, got, msg)
+	}
+	return nil
+}
+
+func (s *ftpSession) quit() {
+	s.cmd("QUIT")
+}
+
+// passive enters the passive mode and returns the address of the data
+// connection, the host of PASV reply is ignored in favor of the host of the
+// control connection, which works with the servers behind NAT.
+func (s *ftpSession) passive() (string, error) {
+	host, _, _ := net.SplitHostPort(s.conn.RemoteAddr().String())
+	code, msg, err := s.cmd("EPSV")
+	if err != nil {
+		return "", err
+	}
+	if code == 229 {
+		// e.g. "Entering Extended Passive Mode (|||6446|)"
+		start, end := strings.Index(msg, "(|||"), strings.LastIndex(msg, "|)")
+		if start >= 0 && end > start+4 {
+			return net.JoinHostPort(host, msg[start+4:end]), nil
+		}
+		return "", fmt.Errorf("ftp: invalid EPSV reply %q", msg)
+	}
+	code, msg, err = s.cmd("PASV")
+	if err != nil {
+		return "", err
+	}
+	if code != 227 {
+		return "", fmt.Errorf("ftp: PASV failed with reply %d %s", code, msg)
+	}
+	// e.g. "Entering Passive Mode (192,168,1,2,25,36)"
+	start, end := strings.Index(msg, "("), strings.LastIndex(msg, ")")
+	if start < 0 || end < start {
+		return "", fmt.Errorf("ftp: invalid PASV reply %q", msg)
+	}
+	parts := strings.Split(msg[start+1:end], ",")
+	if len(parts) != 6 {
+		return "", fmt.Errorf("ftp: invalid PASV reply %q", msg)
+	}
+	p1, err1 := strconv.Atoi(strings.TrimSpace(parts[4]))
+	p2, err2 := strconv.Atoi(strings.TrimSpace(parts[5]))
+	if err1 != nil || err2 != nil {
+		return "", fmt.Errorf("ftp: invalid PASV reply %q", msg)
+	}
+	return net.JoinHostPort(host, strconv.Itoa(p1<<8|p2)), nil
+}
+
+// ftpBody reads the data connection, and completes the transfer on Close.
+type ftpBody struct {
+	s    *ftpSession
+	once sync.Once
+	err  error
+}
+
+func (b *ftpBody) Read(p []byte) (int, error) {
+	return b.s.data.Read(p)
+}
+
+func (b *ftpBody) Close() error {
+	b.once.Do(func() {
+		b.s.data.Close()
+		if _, _, err := b.s.text.ReadResponse(0); err == nil {
+			b.s.quit()
+		}
+		b.s.close()
+	})
+	return nil
+}
+
+func checkRequest(req *http.Request) error {
+	if req.Method != "" && req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return fmt.Errorf("%s: unsupported method %s", req.URL.Scheme, req.Method)
+	}
+	if req.URL.Host == "" {
+		return fmt.Errorf("%s: missing host in URL", req.URL.Scheme)
+	}
+	return nil
+}
+
+func hostPort(host, defaultPort string) string {
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return host
+	}
+	return net.JoinHostPort(strings.Trim(host, "[]"), defaultPort)
+}
+
+// parseRange parses the "bytes=N-" range, the other forms are not
+// supported, which are ignored like the servers do.
+func parseRange(s string) (int64, bool) {
+	spec, ok := strings.CutPrefix(s, "bytes=")
+	if !ok || !strings.HasSuffix(spec, "-") || strings.Contains(spec, ",") {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(strings.TrimSuffix(spec, "-"), 10, 64)
+	return n, err == nil && n >= 0
+}
+
+func newResponse(req *http.Request, status int, header http.Header, size int64, body io.ReadCloser) *http.Response {
+	if header == nil {
+		header = make(http.Header)
+	}
+	if status == http.StatusOK || status == http.StatusPartialContent {
+		ct := mime.TypeByExtension(path.Ext(req.URL.Path))
+		if ct == "" {
+			ct = "application/octet-stream"
+		}
+		header.Set("Content-Type", ct)
+	}
+	if body == nil || req.Method == http.MethodHead {
+		if body != nil {
+			body.Close()
+		}
+		body = http.NoBody
+	}
+	if size >= 0 {
+		header.Set("Content-Length", strconv.FormatInt(size, 10))
+	}
+	return &http.Response{
+		Status:        strconv.Itoa(status) + " " + http.StatusText(status),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          body,
+		ContentLength: size,
+		Request:       req,
+	}
+}
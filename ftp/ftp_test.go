@@ -0,0 +1,299 @@
+package ftp
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/luoxk/restys"
+	"github.com/luoxk/restys/internal/tests"
+	"golang.org/x/crypto/ssh"
+)
+
+var testFiles = map[string]string{
+	"/pub/hello.txt": "hello, ftp world",
+}
+
+// startFTPServer starts a minimal FTP server serving testFiles, the first
+// failRETR RETR commands are replied with 421.
+func startFTPServer(t *testing.T, failRETR int32) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	tests.AssertNoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+	var retrs int32
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveFTP(conn, func() bool { return atomic.AddInt32(&retrs, 1) <= failRETR })
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func serveFTP(conn net.Conn, fail func() bool) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(format string, args ...any) { fmt.Fprintf(conn, format+"\r\n", args...) }
+	reply("220 ready")
+	var dataLn net.Listener
+	var offset int64
+	user := ""
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd, arg, _ := strings.Cut(strings.TrimSpace(line), " ")
+		content, exists := testFiles[arg]
+		switch cmd {
+		case "USER":
+			user = arg
+			reply("331 password required")
+		case "PASS":
+			if user == "bad" {
+				reply("530 login incorrect")
+			} else {
+				reply("230 logged in")
+			}
+		case "TYPE":
+			reply("200 type set")
+		case "SIZE":
+			if !exists {
+				reply("550 no such file")
+			} else {
+				reply("213 %d", len(content))
+			}
+		case "MDTM":
+			reply("213 20240102030405")
+		case "REST":
+			offset, _ = strconv.ParseInt(arg, 10, 64)
+			reply("350 restarting")
+		case "EPSV":
+			dataLn, _ = net.Listen("tcp", "127.0.0.1:0")
+			reply("229 Entering Extended Passive Mode (|||%d|)", dataLn.Addr().(*net.TCPAddr).Port)
+		case "RETR":
+			if fail() {
+				dataLn.Close()
+				reply("421 service not available")
+				return
+			}
+			if !exists {
+				dataLn.Close()
+				reply("550 no such file")
+				continue
+			}
+			reply("150 opening data connection")
+			data, err := dataLn.Accept()
+			dataLn.Close()
+			if err != nil {
+				return
+			}
+			io.WriteString(data, content[offset:])
+			data.Close()
+			reply("226 transfer complete")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
+
+func TestFTP(t *testing.T) {
+	addr := startFTPServer(t, 0)
+	c := Register(restys.C(), nil)
+
+	resp, err := c.R().Get("ftp://" + addr + "/pub/hello.txt")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, http.StatusOK, resp.StatusCode)
+	tests.AssertEqual(t, "hello, ftp world", resp.String())
+	tests.AssertEqual(t, "text/plain; charset=utf-8", resp.GetContentType())
+	tests.AssertEqual(t, "Tue, 02 Jan 2024 03:04:05 GMT", resp.Header.Get("Last-Modified"))
+
+	resp, err = c.R().SetHeader("Range", "bytes=7-").Get("ftp://user:pass@" + addr + "/pub/hello.txt")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, http.StatusPartialContent, resp.StatusCode)
+	tests.AssertEqual(t, "ftp world", resp.String())
+	tests.AssertEqual(t, "bytes 7-15/16", resp.Header.Get("Content-Range"))
+
+	resp, err = c.R().Head("ftp://" + addr + "/pub/hello.txt")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, "16", resp.Header.Get("Content-Length"))
+
+	resp, err = c.R().Get("ftp://" + addr + "/missing")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, http.StatusNotFound, resp.StatusCode)
+
+	resp, err = c.R().Get("ftp://bad:pass@" + addr + "/pub/hello.txt")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, http.StatusUnauthorized, resp.StatusCode)
+
+	_, err = c.R().Post("ftp://" + addr + "/pub/hello.txt")
+	tests.AssertErrorContains(t, err, "unsupported method")
+}
+
+func TestFTPOutputFileAndRetry(t *testing.T) {
+	addr := startFTPServer(t, 1)
+	c := Register(restys.C(), nil).SetCommonRetryCount(2)
+
+	var progress []int64
+	output := filepath.Join(t.TempDir(), "hello.txt")
+	resp, err := c.R().
+		SetOutputFile(output).
+		SetDownloadCallback(func(info restys.DownloadInfo) {
+			progress = append(progress, info.DownloadedSize)
+		}).
+		Get("ftp://" + addr + "/pub/hello.txt")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, 1, resp.Request.RetryAttempt)
+	content, err := os.ReadFile(output)
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, "hello, ftp world", string(content))
+	if len(progress) == 0 || progress[len(progress)-1] != 16 {
+		t.Errorf("unexpected download progress %v", progress)
+	}
+}
+
+// startSFTPServer starts a minimal SFTP server serving testFiles, which
+// accepts the public key of signer.
+func startSFTPServer(t *testing.T, signer ssh.Signer) string {
+	_, hostPriv, _ := ed25519.GenerateKey(rand.Reader)
+	hostSigner, err := ssh.NewSignerFromKey(hostPriv)
+	tests.AssertNoError(t, err)
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if conn.User() == "mirror" && string(key.Marshal()) == string(signer.PublicKey().Marshal()) {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("unknown public key")
+		},
+	}
+	config.AddHostKey(hostSigner)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	tests.AssertNoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_, chans, reqs, err := ssh.NewServerConn(conn, config)
+				if err != nil {
+					return
+				}
+				go ssh.DiscardRequests(reqs)
+				for nc := range chans {
+					ch, chReqs, err := nc.Accept()
+					if err != nil {
+						return
+					}
+					go func() {
+						for req := range chReqs {
+							req.Reply(req.Type == "subsystem", nil)
+							if req.Type == "subsystem" {
+								go serveSFTP(ch)
+							}
+						}
+					}()
+				}
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func serveSFTP(ch ssh.Channel) {
+	defer ch.Close()
+	s := &sftpSession{w: ch, r: ch}
+	handles := map[string]string{}
+	for {
+		typ, payload, err := s.recv()
+		if err != nil {
+			return
+		}
+		if typ == sshFxpInit {
+			s.send(sshFxpVersion, uint32(sshFileXferVer))
+			continue
+		}
+		r := sftpReader(payload)
+		id := r.uint32()
+		status := func(code uint32) { s.send(sshFxpStatus, id, code, "", "") }
+		switch typ {
+		case sshFxpOpen:
+			name := r.string()
+			if _, ok := testFiles[name]; !ok {
+				status(sshFxNoSuch)
+				continue
+			}
+			handles["h1"] = name
+			s.send(sshFxpHandle, id, "h1")
+		case sshFxpFstat:
+			content := testFiles[handles[r.string()]]
+			s.send(sshFxpAttrs, id, uint32(0x1|0x8), uint64(len(content)), uint32(0), uint32(1704164645))
+		case sshFxpRead:
+			content := testFiles[handles[r.string()]]
+			offset, n := r.uint64(), r.uint32()
+			if offset >= uint64(len(content)) {
+				status(sshFxEOF)
+				continue
+			}
+			end := offset + uint64(n)
+			if end > uint64(len(content)) {
+				end = uint64(len(content))
+			}
+			s.send(sshFxpData, id, content[offset:end])
+		case sshFxpClose:
+			status(sshFxOK)
+		default:
+			status(8) // SSH_FX_OP_UNSUPPORTED
+		}
+	}
+}
+
+func TestSFTP(t *testing.T) {
+	_, priv, _ := ed25519.GenerateKey(rand.Reader)
+	signer, err := ssh.NewSignerFromKey(priv)
+	tests.AssertNoError(t, err)
+	addr := startSFTPServer(t, signer)
+
+	c := Register(restys.C(), &ssh.ClientConfig{
+		User:            "nobody",
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	})
+	url := "sftp://mirror@" + addr + "/pub/hello.txt"
+	resp, err := c.R().Get(url)
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, http.StatusOK, resp.StatusCode)
+	tests.AssertEqual(t, "hello, ftp world", resp.String())
+	tests.AssertEqual(t, "Tue, 02 Jan 2024 03:04:05 GMT", resp.Header.Get("Last-Modified"))
+
+	resp, err = c.R().SetHeader("Range", "bytes=7-").Get(url)
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, http.StatusPartialContent, resp.StatusCode)
+	tests.AssertEqual(t, "ftp world", resp.String())
+
+	resp, err = c.R().Get("sftp://mirror@" + addr + "/missing")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, http.StatusNotFound, resp.StatusCode)
+
+	_, err = c.R().Get("sftp://" + addr + "/pub/hello.txt") // user nobody
+	tests.AssertErrorContains(t, err, "unable to authenticate")
+}
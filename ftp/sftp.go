@@ -0,0 +1,371 @@
+package ftp
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// SFTPTransport is the http.RoundTripper of the "sftp" protocol, which
+// speaks SFTP version 3 over SSH. The path of URL is absolute, or relative
+// to the home directory if it starts with "/~/".
+type SFTPTransport struct {
+	// Config is the SSH client config, e.g. the public key auth and the
+	// host key callback. The user of URL overrides Config.User if present.
+	Config *ssh.ClientConfig
+	// DialContext dials the SSH connection, nil means using net.Dialer.
+	DialContext DialContextFunc
+}
+
+const (
+	sshFxpInit     = 1
+	sshFxpVersion  = 2
+	sshFxpOpen     = 3
+	sshFxpClose    = 4
+	sshFxpRead     = 5
+	sshFxpFstat    = 8
+	sshFxpStatus   = 101
+	sshFxpHandle   = 102
+	sshFxpData     = 103
+	sshFxpAttrs    = 105
+	sshFxfRead     = 1
+	sshFxOK        = 0
+	sshFxEOF       = 1
+	sshFxNoSuch    = 2
+	sshFxPermDeny  = 3
+	sshFileXferVer = 3
+
+	sftpChunkSize = 32 << 10
+)
+
+// sftpStatusError is the SSH_FXP_STATUS reply.
+type sftpStatusError struct {
+	Code    uint32
+	Message string
+}
+
+func (e *sftpStatusError) Error() string {
+	return fmt.Sprintf("sftp: status %d: %s", e.Code, e.Message)
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *SFTPTransport) RoundTrip(req *http.Request) (resp *http.Response, err error) {
+	if err = checkRequest(req); err != nil {
+		return nil, err
+	}
+	if t.Config == nil {
+		return nil, errors.New("sftp: missing SSH client config")
+	}
+	ctx := req.Context()
+	addr := hostPort(req.URL.Host, "22")
+	conn, err := dial(ctx, t.DialContext, addr)
+	if err != nil {
+		return nil, err
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	var s *sftpSession
+	defer func() {
+		if resp == nil || resp.Body == http.NoBody {
+			if s != nil {
+				s.close()
+			}
+			stop()
+			conn.Close()
+		}
+		if err != nil && ctx.Err() != nil {
+			err = ctx.Err()
+		}
+	}()
+
+	config := *t.Config
+	if u := req.URL.User; u != nil && u.Username() != "" {
+		config.User = u.Username()
+	}
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, addr, &config)
+	if err != nil {
+		return nil, err
+	}
+	client := ssh.NewClient(sshConn, chans, reqs)
+	if s, err = newSFTPSession(client); err != nil {
+		client.Close()
+		return nil, err
+	}
+	s.stop = stop
+
+	name := req.URL.Path
+	if rest, ok := strings.CutPrefix(name, "/~/"); ok {
+		name = rest
+	}
+	handle, err := s.open(name)
+	if err != nil {
+		var se *sftpStatusError
+		if errors.As(err, &se) {
+			switch se.Code {
+			case sshFxNoSuch:
+				return newResponse(req, http.StatusNotFound, nil, -1, nil), nil
+			case sshFxPermDeny:
+				return newResponse(req, http.StatusForbidden, nil, -1, nil), nil
+			}
+		}
+		return nil, err
+	}
+	s.handle = handle
+	size, mtime, err := s.fstat(handle)
+	if err != nil {
+		return nil, err
+	}
+	header := make(http.Header)
+	if !mtime.IsZero() {
+		header.Set("Last-Modified", mtime.UTC().Format(http.TimeFormat))
+	}
+	if req.Method == http.MethodHead {
+		return newResponse(req, http.StatusOK, header, size, nil), nil
+	}
+
+	status := http.StatusOK
+	if offset, ok := parseRange(req.Header.Get("Range")); ok && offset > 0 && (size < 0 || offset < size) {
+		s.offset = uint64(offset)
+		status = http.StatusPartialContent
+		if size >= 0 {
+			header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, size-1, size))
+			size -= offset
+		}
+	}
+	return newResponse(req, status, header, size, &sftpBody{s: s}), nil
+}
+
+// sftpSession is a minimal SFTP client which sends the requests one by one.
+type sftpSession struct {
+	client  *ssh.Client
+	session *ssh.Session
+	w       io.WriteCloser
+	r       io.Reader
+	stop    func() bool
+	id      uint32
+	handle  string
+	offset  uint64
+}
+
+func newSFTPSession(client *ssh.Client) (*sftpSession, error) {
+	session, err := client.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	s := &sftpSession{client: client, session: session}
+	if s.w, err = session.StdinPipe(); err != nil {
+		s.close()
+		return nil, err
+	}
+	if s.r, err = session.StdoutPipe(); err != nil {
+		s.close()
+		return nil, err
+	}
+	if err = session.RequestSubsystem("sftp"); err != nil {
+		s.close()
+		return nil, err
+	}
+	if err = s.send(sshFxpInit, uint32(sshFileXferVer)); err != nil {
+		s.close()
+		return nil, err
+	}
+	typ, _, err := s.recv()
+	if err == nil && typ != sshFxpVersion {
+		err = fmt.Errorf("sftp: unexpected packet type %d", typ)
+	}
+	if err != nil {
+		s.close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *sftpSession) close() {
+	if s.handle != "" {
+		s.request(sshFxpClose, s.handle)
+		s.handle = ""
+	}
+	s.session.Close()
+	s.client.Close()
+}
+
+// send writes the packet, the fields are uint32, uint64 or string.
+func (s *sftpSession) send(typ byte, fields ...any) error {
+	b := []byte{0, 0, 0, 0, typ}
+	for _, f := range fields {
+		switch v := f.(type) {
+		case uint32:
+			b = binary.BigEndian.AppendUint32(b, v)
+		case uint64:
+			b = binary.BigEndian.AppendUint64(b, v)
+		case string:
+			b = binary.BigEndian.AppendUint32(b, uint32(len(v)))
+			b = append(b, v...)
+		}
+	}
+	binary.BigEndian.PutUint32(b, uint32(len(b)-4))
+	_, err := s.w.Write(b)
+	return err
+}
+
+func (s *sftpSession) recv() (byte, []byte, error) {
+	var hdr [5]byte
+	if _, err := io.ReadFull(s.r, hdr[:]); err != nil {
+		return 0, nil, err
+	}
+	n := binary.BigEndian.Uint32(hdr[:4])
+	if n < 1 || n > 1<<20 {
+		return 0, nil, fmt.Errorf("sftp: invalid packet length %d", n)
+	}
+	payload := make([]byte, n-1)
+	if _, err := io.ReadFull(s.r, payload); err != nil {
+		return 0, nil, err
+	}
+	return hdr[4], payload, nil
+}
+
+// request sends the request with a new id, and returns the reply, the
+// SSH_FXP_STATUS reply other than OK is returned as *sftpStatusError.
+func (s *sftpSession) request(typ byte, fields ...any) (byte, []byte, error) {
+	s.id++
+	if err := s.send(typ, append([]any{s.id}, fields...)...); err != nil {
+		return 0, nil, err
+	}
+	rtyp, payload, err := s.recv()
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(payload) < 4 || binary.BigEndian.Uint32(payload) != s.id {
+		return 0, nil, errors.New("sftp: mismatched reply id")
+	}
+	payload = payload[4:]
+	if rtyp == sshFxpStatus {
+		r := sftpReader(payload)
+		code := r.uint32()
+		if code != sshFxOK {
+			return 0, nil, &sftpStatusError{Code: code, Message: r.string()}
+		}
+	}
+	return rtyp, payload, nil
+}
+
+func (s *sftpSession) open(name string) (string, error) {
+	typ, payload, err := s.request(sshFxpOpen, name, uint32(sshFxfRead), uint32(0))
+	if err != nil {
+		return "", err
+	}
+	if typ != sshFxpHandle {
+		return "", fmt.Errorf("sftp: unexpected packet type %d", typ)
+	}
+	r := sftpReader(payload)
+	return r.string(), nil
+}
+
+func (s *sftpSession) fstat(handle string) (size int64, mtime time.Time, err error) {
+	typ, payload, err := s.request(sshFxpFstat, handle)
+	if err != nil {
+		return -1, mtime, err
+	}
+	if typ != sshFxpAttrs {
+		return -1, mtime, fmt.Errorf("sftp: unexpected packet type %d", typ)
+	}
+	r := sftpReader(payload)
+	flags := r.uint32()
+	size = -1
+	if flags&0x1 != 0 { // SSH_FILEXFER_ATTR_SIZE
+		size = int64(r.uint64())
+	}
+	if flags&0x2 != 0 { // SSH_FILEXFER_ATTR_UIDGID
+		r.uint32()
+		r.uint32()
+	}
+	if flags&0x4 != 0 { // SSH_FILEXFER_ATTR_PERMISSIONS
+		r.uint32()
+	}
+	if flags&0x8 != 0 { // SSH_FILEXFER_ATTR_ACMODTIME
+		r.uint32()
+		mtime = time.Unix(int64(r.uint32()), 0)
+	}
+	return size, mtime, nil
+}
+
+func (s *sftpSession) read(p []byte) (int, error) {
+	n := len(p)
+	if n > sftpChunkSize {
+		n = sftpChunkSize
+	}
+	typ, payload, err := s.request(sshFxpRead, s.handle, s.offset, uint32(n))
+	if err != nil {
+		var se *sftpStatusError
+		if errors.As(err, &se) && se.Code == sshFxEOF {
+			return 0, io.EOF
+		}
+		return 0, err
+	}
+	if typ != sshFxpData {
+		return 0, fmt.Errorf("sftp: unexpected packet type %d", typ)
+	}
+	r := sftpReader(payload)
+	data := r.string()
+	n = copy(p, data)
+	s.offset += uint64(n)
+	return n, nil
+}
+
+type sftpReader []byte
+
+func (r *sftpReader) uint32() uint32 {
+	if len(*r) < 4 {
+		*r = nil
+		return 0
+	}
+	v := binary.BigEndian.Uint32(*r)
+	*r = (*r)[4:]
+	return v
+}
+
+func (r *sftpReader) uint64() uint64 {
+	if len(*r) < 8 {
+		*r = nil
+		return 0
+	}
+	v := binary.BigEndian.Uint64(*r)
+	*r = (*r)[8:]
+	return v
+}
+
+func (r *sftpReader) string() string {
+	n := int(r.uint32())
+	if len(*r) < n {
+		*r = nil
+		return ""
+	}
+	v := string((*r)[:n])
+	*r = (*r)[n:]
+	return v
+}
+
+// sftpBody reads the opened file, and closes the session on Close.
+type sftpBody struct {
+	s    *sftpSession
+	once sync.Once
+}
+
+func (b *sftpBody) Read(p []byte) (int, error) {
+	return b.s.read(p)
+}
+
+func (b *sftpBody) Close() error {
+	b.once.Do(func() {
+		b.s.close()
+		b.s.stop()
+	})
+	return nil
+}
@@ -0,0 +1,66 @@
+package restys
+
+import "sync"
+
+var (
+	defaultClientMu sync.RWMutex
+
+	globalOptionsMu sync.RWMutex
+	globalOpts      []Option
+)
+
+// Configure configures the global default Client (see DefaultClient) with
+// fn under a lock, so that the concurrent configurations of the default
+// client, e.g. from the init functions of different packages, do not race
+// with each other. Note the requests fired concurrently still see the
+// modification in place, so it's better to configure the default client
+// before firing requests. For example:
+//
+//	req.Configure(func(c *req.Client) {
+//	    c.SetTimeout(10 * time.Second).SetUserAgent("my-app/1.0")
+//	})
+func Configure(fn func(c *Client)) {
+	defaultClientMu.Lock()
+	defer defaultClientMu.Unlock()
+	fn(defaultClient)
+}
+
+// AddGlobalOptions adds the options which are applied to all clients
+// created afterwards (see C), before the options passed to C. The clients
+// created before, including the default client, are not affected.
+func AddGlobalOptions(opts ...Option) {
+	globalOptionsMu.Lock()
+	globalOpts = append(globalOpts, opts...)
+	globalOptionsMu.Unlock()
+}
+
+// AddGlobalRequestMiddleware adds a request middleware (see
+// Client.OnBeforeRequest) to all clients created afterwards, e.g. to
+// sign or audit all outgoing requests of the process.
+func AddGlobalRequestMiddleware(m RequestMiddleware) {
+	AddGlobalOptions(func(c *Client) {
+		c.OnBeforeRequest(m)
+	})
+}
+
+// AddGlobalResponseMiddleware adds a response middleware (see
+// Client.OnAfterResponse) to all clients created afterwards.
+func AddGlobalResponseMiddleware(m ResponseMiddleware) {
+	AddGlobalOptions(func(c *Client) {
+		c.OnAfterResponse(m)
+	})
+}
+
+// ResetGlobalOptions removes all options added by AddGlobalOptions,
+// AddGlobalRequestMiddleware and AddGlobalResponseMiddleware.
+func ResetGlobalOptions() {
+	globalOptionsMu.Lock()
+	globalOpts = nil
+	globalOptionsMu.Unlock()
+}
+
+func globalOptions() []Option {
+	globalOptionsMu.RLock()
+	defer globalOptionsMu.RUnlock()
+	return globalOpts
+}
@@ -0,0 +1,63 @@
+package restys
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/luoxk/restys/internal/tests"
+)
+
+func TestConfigure(t *testing.T) {
+	old := DefaultClient()
+	defer SetDefaultClient(old)
+	SetDefaultClient(C())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			Configure(func(c *Client) {
+				c.SetCommonQueryParam("n", "1")
+				c.SetTimeout(time.Duration(len(c.QueryParams)) * time.Second)
+			})
+		}()
+	}
+	wg.Wait()
+	tests.AssertEqual(t, time.Second, DefaultClient().GetClient().Timeout)
+}
+
+func TestGlobalMiddleware(t *testing.T) {
+	defer ResetGlobalOptions()
+	before := tc()
+	var requests, responses int
+	AddGlobalRequestMiddleware(func(c *Client, r *Request) error {
+		requests++
+		r.SetHeader("X-Global", "1")
+		return nil
+	})
+	AddGlobalResponseMiddleware(func(c *Client, resp *Response) error {
+		responses++
+		return nil
+	})
+	AddGlobalOptions(WithUserAgent("global"))
+
+	c := tc()
+	resp, err := c.R().Get("/header")
+	assertSuccess(t, resp, err)
+	tests.AssertContains(t, resp.String(), `"x-global":["1"]`, true)
+	tests.AssertEqual(t, "global", c.Headers.Get("User-Agent"))
+	tests.AssertEqual(t, 1, requests)
+	tests.AssertEqual(t, 1, responses)
+
+	// the options of C take precedence.
+	tests.AssertEqual(t, "mine", C(WithUserAgent("mine")).Headers.Get("User-Agent"))
+
+	resp, err = before.R().Get("/")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, 1, requests)
+
+	ResetGlobalOptions()
+	tests.AssertEqual(t, 0, len(C().udBeforeRequest))
+}
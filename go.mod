@@ -1,8 +1,6 @@
 module github.com/luoxk/restys
 
-go 1.23.0
-
-toolchain go1.23.6
+go 1.24
 
 require (
 	github.com/andybalholm/brotli v1.1.1
@@ -10,8 +8,9 @@ require (
 	github.com/klauspost/compress v1.18.0
 	github.com/quic-go/qpack v0.5.1
 	github.com/quic-go/quic-go v0.48.2
-	github.com/refraction-networking/utls v1.6.7
-	golang.org/x/net v0.33.0
+	github.com/refraction-networking/utls v1.8.2
+	golang.org/x/crypto v0.37.0
+	golang.org/x/net v0.38.0
 	golang.org/x/text v0.24.0
 )
 
@@ -22,7 +21,6 @@ require (
 	github.com/hashicorp/errwrap v1.1.0 // indirect
 	github.com/onsi/ginkgo/v2 v2.22.0 // indirect
 	go.uber.org/mock v0.5.0 // indirect
-	golang.org/x/crypto v0.37.0 // indirect
 	golang.org/x/exp v0.0.0-20241215155358-4a5509556b9e // indirect
 	golang.org/x/mod v0.22.0 // indirect
 	golang.org/x/sync v0.13.0 // indirect
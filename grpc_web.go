@@ -0,0 +1,274 @@
+package restys
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/http"
+	urlpkg "net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GRPCResult is the result of a gRPC-Web or Connect unary call, see
+// Request.InvokeGRPCWeb and Request.InvokeConnect.
+type GRPCResult struct {
+	// Message is the serialized response message, e.g. in protobuf binary
+	// format, it's empty if the call failed.
+	Message []byte
+	// Header is the response metadata.
+	Header http.Header
+	// Trailer is the response trailing metadata.
+	Trailer http.Header
+	// Response is the underlying HTTP response.
+	Response *Response
+}
+
+// GRPCStatusError is the error of a gRPC-Web or Connect call whose status
+// is not OK.
+type GRPCStatusError struct {
+	// Code is the gRPC status code, e.g. 5 (NOT_FOUND).
+	Code int
+	// Message is the error message.
+	Message string
+}
+
+func (e *GRPCStatusError) Error() string {
+	return fmt.Sprintf("grpc error: code = %d (%s), message = %s", e.Code, grpcCodeName(e.Code), e.Message)
+}
+
+// the gRPC status codes in the order of their values, which are named in
+// the style of Connect protocol.
+var grpcCodeNames = []string{
+	"ok", "canceled", "unknown", "invalid_argument", "deadline_exceeded",
+	"not_found", "already_exists", "permission_denied", "resource_exhausted",
+	"failed_precondition", "aborted", "out_of_range", "unimplemented",
+	"internal", "unavailable", "data_loss", "unauthenticated",
+}
+
+func grpcCodeName(code int) string {
+	if code >= 0 && code < len(grpcCodeNames) {
+		return grpcCodeNames[code]
+	}
+	return "code_" + strconv.Itoa(code)
+}
+
+func grpcCodeFromName(name string) int {
+	for i, n := range grpcCodeNames {
+		if n == name {
+			return i
+		}
+	}
+	return 2 // unknown
+}
+
+const (
+	grpcFlagCompressed = 0x01
+	grpcFlagTrailer    = 0x80
+)
+
+// grpcTimeout returns the value of grpc-timeout header.
+func grpcTimeout(ctx context.Context) (time.Duration, bool) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return 0, false
+	}
+	d := time.Until(deadline)
+	if d < time.Millisecond {
+		d = time.Millisecond
+	}
+	return d, true
+}
+
+// InvokeGRPCWeb issues a gRPC-Web unary call to the url (e.g.
+// "https://example.com/pkg.Service/Method") with the serialized request
+// message, over the client transport, so browser-only gRPC endpoints can
+// be called with the client fingerprint. The message is framed with the
+// 5-byte prefix, and the trailers in the response body are parsed. The
+// content type is "application/grpc-web+proto" unless specified by
+// Request.SetContentType (e.g. "application/grpc-web+json"). It returns
+// *GRPCStatusError if the grpc-status is not OK. For example:
+//
+//	in, _ := proto.Marshal(&pb.GetUserRequest{Id: 1})
+//	result, err := client.R().InvokeGRPCWeb("https://example.com/user.UserService/GetUser", in)
+//	if err != nil {
+//	    return err
+//	}
+//	var user pb.User
+//	err = proto.Unmarshal(result.Message, &user)
+func (r *Request) InvokeGRPCWeb(url string, msg []byte) (*GRPCResult, error) {
+	contentType := r.getHeader("Content-Type")
+	if contentType == "" {
+		contentType = "application/grpc-web+proto"
+	}
+	r.SetHeader("Content-Type", contentType)
+	r.SetHeader("Accept", contentType)
+	r.SetHeader("X-Grpc-Web", "1")
+	if d, ok := grpcTimeout(r.Context()); ok {
+		r.SetHeader("Grpc-Timeout", strconv.FormatInt(d.Milliseconds(), 10)+"m")
+	}
+	frame := make([]byte, 5+len(msg))
+	binary.BigEndian.PutUint32(frame[1:5], uint32(len(msg)))
+	copy(frame[5:], msg)
+	r.SetBodyBytes(frame)
+
+	resp, err := r.Post(url)
+	if err != nil {
+		return nil, err
+	}
+	return parseGRPCWebResponse(resp)
+}
+
+func parseGRPCWebResponse(resp *Response) (*GRPCResult, error) {
+	result := &GRPCResult{
+		Header:   resp.Header,
+		Trailer:  make(http.Header),
+		Response: resp,
+	}
+	if resp.StatusCode != http.StatusOK {
+		return result, &GRPCStatusError{
+			Code:    grpcCodeFromHTTPStatus(resp.StatusCode),
+			Message: "unexpected HTTP status " + resp.Status,
+		}
+	}
+	body := resp.Bytes()
+	gotMessage := false
+	for len(body) > 0 {
+		if len(body) < 5 {
+			return result, errors.New("grpc-web: truncated frame header")
+		}
+		flag := body[0]
+		n := binary.BigEndian.Uint32(body[1:5])
+		if uint64(len(body)-5) < uint64(n) {
+			return result, errors.New("grpc-web: truncated frame")
+		}
+		data := body[5 : 5+n]
+		body = body[5+n:]
+		if flag&grpcFlagCompressed != 0 {
+			return result, errors.New("grpc-web: compressed frame is not supported")
+		}
+		if flag&grpcFlagTrailer != 0 {
+			parseGRPCWebTrailer(data, result.Trailer)
+			continue
+		}
+		if gotMessage {
+			return result, errors.New("grpc-web: unexpected multiple messages in unary response")
+		}
+		result.Message = data
+		gotMessage = true
+	}
+
+	// the status is in the headers for trailers-only response.
+	status := result.Trailer
+	if status.Get("Grpc-Status") == "" {
+		status = resp.Header
+	}
+	if err := grpcStatusError(status); err != nil {
+		result.Message = nil
+		return result, err
+	}
+	if !gotMessage {
+		return result, errors.New("grpc-web: missing response message")
+	}
+	return result, nil
+}
+
+func parseGRPCWebTrailer(data []byte, trailer http.Header) {
+	for _, line := range bytes.Split(data, []byte("\r\n")) {
+		k, v, ok := bytes.Cut(line, []byte(":"))
+		if !ok {
+			continue
+		}
+		trailer.Add(strings.TrimSpace(string(k)), strings.TrimSpace(string(v)))
+	}
+}
+
+func grpcStatusError(h http.Header) error {
+	s := h.Get("Grpc-Status")
+	if s == "" {
+		return &GRPCStatusError{Code: 2, Message: "missing grpc-status"}
+	}
+	code, err := strconv.Atoi(s)
+	if err != nil {
+		return &GRPCStatusError{Code: 2, Message: "invalid grpc-status " + s}
+	}
+	if code == 0 {
+		return nil
+	}
+	msg := h.Get("Grpc-Message")
+	if m, err := urlpkg.PathUnescape(msg); err == nil {
+		msg = m
+	}
+	return &GRPCStatusError{Code: code, Message: msg}
+}
+
+// grpcCodeFromHTTPStatus maps the HTTP status to the gRPC status code as
+// specified by gRPC and Connect protocol.
+func grpcCodeFromHTTPStatus(status int) int {
+	switch status {
+	case http.StatusBadRequest:
+		return 13 // internal
+	case http.StatusUnauthorized:
+		return 16 // unauthenticated
+	case http.StatusForbidden:
+		return 7 // permission_denied
+	case http.StatusNotFound:
+		return 12 // unimplemented
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return 14 // unavailable
+	default:
+		return 2 // unknown
+	}
+}
+
+// InvokeConnect issues a Connect protocol unary call to the url (e.g.
+// "https://example.com/pkg.Service/Method") with the serialized request
+// message, the content type is "application/proto" unless specified by
+// Request.SetContentType (e.g. "application/json"). It returns
+// *GRPCStatusError if the call failed with the Connect error.
+func (r *Request) InvokeConnect(url string, msg []byte) (*GRPCResult, error) {
+	if r.getHeader("Content-Type") == "" {
+		r.SetHeader("Content-Type", "application/proto")
+	}
+	r.SetHeader("Connect-Protocol-Version", "1")
+	if d, ok := grpcTimeout(r.Context()); ok {
+		r.SetHeader("Connect-Timeout-Ms", strconv.FormatInt(d.Milliseconds(), 10))
+	}
+	r.SetBodyBytes(msg)
+
+	resp, err := r.Post(url)
+	if err != nil {
+		return nil, err
+	}
+	result := &GRPCResult{
+		Header:   make(http.Header),
+		Trailer:  make(http.Header),
+		Response: resp,
+	}
+	// the trailers are sent as the headers prefixed with "Trailer-".
+	for k, vs := range resp.Header {
+		if t, ok := strings.CutPrefix(k, "Trailer-"); ok {
+			result.Trailer[http.CanonicalHeaderKey(t)] = vs
+		} else {
+			result.Header[k] = vs
+		}
+	}
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		}
+		if err := resp.UnmarshalJson(&e); err != nil || e.Code == "" {
+			return result, &GRPCStatusError{
+				Code:    grpcCodeFromHTTPStatus(resp.StatusCode),
+				Message: "unexpected HTTP status " + resp.Status,
+			}
+		}
+		return result, &GRPCStatusError{Code: grpcCodeFromName(e.Code), Message: e.Message}
+	}
+	result.Message = resp.Bytes()
+	return result, nil
+}
@@ -0,0 +1,93 @@
+package restys
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/luoxk/restys/internal/tests"
+)
+
+func grpcWebFrame(flag byte, data []byte) []byte {
+	b := make([]byte, 5+len(data))
+	b[0] = flag
+	binary.BigEndian.PutUint32(b[1:], uint32(len(data)))
+	copy(b[5:], data)
+	return b
+}
+
+func TestInvokeGRPCWeb(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Grpc-Web") != "1" || r.Header.Get("Content-Type") != "application/grpc-web+proto" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/grpc-web+proto")
+		if r.URL.Path == "/svc.Echo/Fail" {
+			w.Header().Set("Grpc-Status", "5")
+			w.Header().Set("Grpc-Message", "user%20not%20found")
+			return
+		}
+		if len(body) < 5 || int(binary.BigEndian.Uint32(body[1:5])) != len(body)-5 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write(grpcWebFrame(0, body[5:]))
+		w.Write(grpcWebFrame(0x80, []byte("grpc-status: 0\r\ngrpc-message: \r\nx-extra: 1\r\n")))
+	}))
+	defer server.Close()
+
+	c := tc()
+	result, err := c.R().InvokeGRPCWeb(server.URL+"/svc.Echo/Echo", []byte("hello"))
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, "hello", string(result.Message))
+	tests.AssertEqual(t, "1", result.Trailer.Get("X-Extra"))
+
+	_, err = c.R().InvokeGRPCWeb(server.URL+"/svc.Echo/Fail", []byte("hello"))
+	var se *GRPCStatusError
+	tests.AssertEqual(t, true, errors.As(err, &se))
+	tests.AssertEqual(t, 5, se.Code)
+	tests.AssertEqual(t, "user not found", se.Message)
+
+	_, err = c.R().SetContentType("application/grpc-web+json").InvokeGRPCWeb(server.URL+"/svc.Echo/Echo", nil)
+	tests.AssertEqual(t, true, errors.As(err, &se))
+	tests.AssertEqual(t, 13, se.Code)
+}
+
+func TestInvokeConnect(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Connect-Protocol-Version") != "1" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if r.URL.Path == "/svc.Echo/Fail" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"code":"not_found","message":"no such user"}`))
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", r.Header.Get("Content-Type"))
+		w.Header().Set("Trailer-X-Extra", "1")
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	c := tc()
+	result, err := c.R().InvokeConnect(server.URL+"/svc.Echo/Echo", []byte("hello"))
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, "hello", string(result.Message))
+	tests.AssertEqual(t, "application/proto", result.Header.Get("Content-Type"))
+	tests.AssertEqual(t, "1", result.Trailer.Get("X-Extra"))
+
+	_, err = c.R().SetContentType("application/json").InvokeConnect(server.URL+"/svc.Echo/Fail", []byte("{}"))
+	var se *GRPCStatusError
+	tests.AssertEqual(t, true, errors.As(err, &se))
+	tests.AssertEqual(t, 5, se.Code)
+	tests.AssertEqual(t, "no such user", se.Message)
+	tests.AssertContains(t, se.Error(), "not_found", true)
+}
@@ -0,0 +1,217 @@
+package restys
+
+import (
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+// HostConfig is the configuration overrides for the requests to the hosts
+// which match the pattern, see Client.ForHost.
+type HostConfig struct {
+	client      *Client
+	pattern     string
+	headers     http.Header
+	timeout     time.Duration
+	retryOption *retryOption
+	rateLimit   int
+	limiter     *rateLimiter
+}
+
+// ForHost returns the HostConfig of the host pattern, which is created if
+// not exists, so that one client can apply different headers, timeout,
+// retry policy and rate limit per destination host, e.g.
+//
+//	client.ForHost("api.example.com").
+//		SetHeader("Authorization", "Bearer "+token).
+//		SetTimeout(5 * time.Second).
+//		SetRetryCount(3).
+//		SetRateLimit(10)
+//
+// The pattern is matched against the hostname of request URL (or host:port
+// if the pattern contains ":") with path.Match syntax, e.g. "*.example.com"
+// matches the subdomains of example.com but not example.com itself. If
+// multiple patterns match, the first one added wins.
+//
+// The settings of HostConfig override the client-level ones, and are
+// overridden by the request-level ones.
+func (c *Client) ForHost(pattern string) *HostConfig {
+	pattern = strings.ToLower(pattern)
+	for _, hc := range c.hostConfigs {
+		if hc.pattern == pattern {
+			return hc
+		}
+	}
+	hc := &HostConfig{client: c, pattern: pattern}
+	c.hostConfigs = append(c.hostConfigs, hc)
+	return hc
+}
+
+// Client returns the Client of the HostConfig, which is useful to continue
+// the chained configuration of client.
+func (hc *HostConfig) Client() *Client {
+	return hc.client
+}
+
+// SetHeader set a header for requests to the host.
+func (hc *HostConfig) SetHeader(key, value string) *HostConfig {
+	if hc.headers == nil {
+		hc.headers = make(http.Header)
+	}
+	hc.headers.Set(key, value)
+	return hc
+}
+
+// SetHeaders set headers from a map for requests to the host.
+func (hc *HostConfig) SetHeaders(hdrs map[string]string) *HostConfig {
+	for k, v := range hdrs {
+		hc.SetHeader(k, v)
+	}
+	return hc
+}
+
+// SetTimeout set timeout for requests to the host, which overrides the
+// client-level timeout (see Client.SetTimeout).
+func (hc *HostConfig) SetTimeout(d time.Duration) *HostConfig {
+	hc.timeout = d
+	return hc
+}
+
+// getRetryOption inherits the client-level retry settings when it's first
+// modified.
+func (hc *HostConfig) getRetryOption() *retryOption {
+	if hc.retryOption == nil {
+		hc.retryOption = hc.client.retryOption.Clone()
+		if hc.retryOption == nil {
+			hc.retryOption = newDefaultRetryOption()
+		}
+	}
+	return hc.retryOption
+}
+
+// SetRetryCount enables retry and set the maximum retry count for requests
+// to the host. It will retry infinitely if count is negative.
+func (hc *HostConfig) SetRetryCount(count int) *HostConfig {
+	hc.getRetryOption().MaxRetries = count
+	return hc
+}
+
+// SetRetryInterval sets the custom GetRetryIntervalFunc for requests to
+// the host.
+func (hc *HostConfig) SetRetryInterval(getRetryIntervalFunc GetRetryIntervalFunc) *HostConfig {
+	hc.getRetryOption().GetRetryInterval = getRetryIntervalFunc
+	return hc
+}
+
+// SetRetryFixedInterval set retry to use a fixed interval for requests to
+// the host.
+func (hc *HostConfig) SetRetryFixedInterval(interval time.Duration) *HostConfig {
+	hc.getRetryOption().GetRetryInterval = func(resp *Response, attempt int) time.Duration {
+		return interval
+	}
+	return hc
+}
+
+// SetRetryBackoffInterval set retry to use a capped exponential backoff
+// with jitter for requests to the host.
+func (hc *HostConfig) SetRetryBackoffInterval(min, max time.Duration) *HostConfig {
+	hc.getRetryOption().GetRetryInterval = backoffInterval(min, max)
+	return hc
+}
+
+// SetRetryCondition sets the retry condition for requests to the host, it
+// will override the client-level retry conditions.
+func (hc *HostConfig) SetRetryCondition(condition RetryConditionFunc) *HostConfig {
+	hc.getRetryOption().RetryConditions = []RetryConditionFunc{condition}
+	return hc
+}
+
+// SetRetryHook set the retry hook for requests to the host, it will
+// override the client-level retry hooks.
+func (hc *HostConfig) SetRetryHook(hook RetryHookFunc) *HostConfig {
+	hc.getRetryOption().RetryHooks = []RetryHookFunc{hook}
+	return hc
+}
+
+// SetRateLimit limits the requests (including retries) to the host to
+// requestsPerSecond, zero means no limit.
+func (hc *HostConfig) SetRateLimit(requestsPerSecond int) *HostConfig {
+	hc.rateLimit = requestsPerSecond
+	hc.limiter = nil
+	if requestsPerSecond > 0 {
+		hc.limiter = newRateLimiter(int64(requestsPerSecond))
+	}
+	return hc
+}
+
+func (hc *HostConfig) match(r *Request) bool {
+	host := r.URL.Hostname()
+	if strings.Contains(hc.pattern, ":") {
+		host = r.URL.Host
+	}
+	ok, _ := path.Match(hc.pattern, strings.ToLower(host))
+	return ok
+}
+
+func (hc *HostConfig) clone(c *Client) *HostConfig {
+	h := &HostConfig{
+		client:      c,
+		pattern:     hc.pattern,
+		headers:     hc.headers.Clone(),
+		timeout:     hc.timeout,
+		retryOption: hc.retryOption.Clone(),
+	}
+	h.SetRateLimit(hc.rateLimit)
+	return h
+}
+
+func cloneHostConfigs(c *Client, configs []*HostConfig) []*HostConfig {
+	if len(configs) == 0 {
+		return nil
+	}
+	cc := make([]*HostConfig, len(configs))
+	for i, hc := range configs {
+		cc[i] = hc.clone(c)
+	}
+	return cc
+}
+
+func (c *Client) hostConfig(r *Request) *HostConfig {
+	if r.URL == nil {
+		return nil
+	}
+	for _, hc := range c.hostConfigs {
+		if hc.match(r) {
+			return hc
+		}
+	}
+	return nil
+}
+
+// parseRequestHostConfig applies the HostConfig which matches the request,
+// it must be after parseRequestURL and before parseRequestHeader.
+func parseRequestHostConfig(c *Client, r *Request) error {
+	hc := c.hostConfig(r)
+	r.hostConfig = hc
+	if hc == nil {
+		return nil
+	}
+	for k, vs := range hc.headers {
+		if len(r.Headers[k]) == 0 {
+			// the capacity is limited so that appending to the request
+			// header doesn't write into the HostConfig's one.
+			r.Headers[k] = vs[:len(vs):len(vs)]
+		}
+	}
+	if r.RetryAttempt == 0 && hc.retryOption != nil && !r.retryOptionModified {
+		r.retryOption = hc.retryOption.Clone()
+		if r.retryOption.MaxRetries != 0 && r.unReplayableBody != nil {
+			return errRetryableWithUnReplayableBody
+		}
+	}
+	if hc.limiter != nil {
+		return hc.limiter.wait(r.Context(), 1)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package restys
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/luoxk/restys/internal/tests"
+)
+
+func TestForHost(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/slow":
+			time.Sleep(200 * time.Millisecond)
+		case "/fail":
+			atomic.AddInt32(&calls, 1)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(r.Header.Get("X-Token")))
+	}))
+	defer server.Close()
+	// 127.0.0.1 is configured by ForHost, localhost is not.
+	otherURL := strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+
+	c := tc().SetCommonHeader("X-Token", "client").SetTimeout(time.Second)
+	c.ForHost("127.0.0.*").
+		SetHeader("X-Token", "host").
+		SetTimeout(50 * time.Millisecond).
+		SetRetryCount(2).
+		SetRetryFixedInterval(time.Millisecond).
+		SetRetryCondition(func(resp *Response, err error) bool {
+			return err == nil && resp.StatusCode == http.StatusInternalServerError
+		})
+
+	resp, err := c.R().Get(server.URL)
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, "host", resp.String())
+
+	resp, err = c.R().SetHeader("X-Token", "request").Get(server.URL)
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, "request", resp.String())
+
+	resp, err = c.R().Get(otherURL)
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, "client", resp.String())
+
+	_, err = c.R().Get(server.URL + "/slow")
+	tests.AssertEqual(t, true, err != nil)
+	_, err = c.R().Get(otherURL + "/slow")
+	tests.AssertNoError(t, err)
+
+	resp, _ = c.R().Get(server.URL + "/fail")
+	tests.AssertEqual(t, 2, resp.Request.RetryAttempt)
+	tests.AssertEqual(t, int32(3), atomic.LoadInt32(&calls))
+
+	resp, _ = c.R().SetRetryCount(1).Get(server.URL + "/fail")
+	tests.AssertEqual(t, 0, resp.Request.RetryAttempt)
+
+	tests.AssertEqual(t, true, c.ForHost("127.0.0.*") == c.ForHost("127.0.0.*"))
+	cc := c.Clone()
+	tests.AssertEqual(t, true, cc.ForHost("127.0.0.*") != c.ForHost("127.0.0.*"))
+	resp, err = cc.R().Get(server.URL)
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, "host", resp.String())
+
+	// the header slices are not shared with the HostConfig.
+	values := append(make([]string, 0, 2), "host")
+	c.ForHost("127.0.0.*").headers["X-Multi"] = values
+	r := c.R()
+	r.URL, _ = url.Parse(server.URL)
+	r.Headers = make(http.Header)
+	tests.AssertNoError(t, parseRequestHostConfig(c, r))
+	r.Headers.Add("X-Multi", "request")
+	tests.AssertEqual(t, "", values[:2][1])
+}
+
+func TestForHostRateLimit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	c := tc()
+	c.ForHost("127.0.0.1").SetRateLimit(10)
+	start := time.Now()
+	for i := 0; i < 12; i++ {
+		_, err := c.R().Get(server.URL)
+		tests.AssertNoError(t, err)
+	}
+	// the burst is 10, the other 2 requests wait about 100ms each.
+	tests.AssertEqual(t, true, time.Since(start) >= 150*time.Millisecond)
+}
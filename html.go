@@ -0,0 +1,94 @@
+package restys
+
+import (
+	"strings"
+
+	"github.com/luoxk/restys/internal/htmlselect"
+	"golang.org/x/net/html"
+)
+
+// HTMLNode is a node of the parsed HTML document, use Find or First to
+// query its descendants with CSS selectors, the underlying *html.Node
+// is available for advanced usage.
+type HTMLNode struct {
+	*html.Node
+}
+
+// HTML parses the response body as HTML document, the body is decoded to
+// utf-8 already if auto-decode is enabled (see Client.EnableAutoDecode and
+// Request.SetResponseCharset).
+func (r *Response) HTML() (*HTMLNode, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	s, err := r.ToString()
+	if err != nil {
+		return nil, err
+	}
+	doc, err := html.Parse(strings.NewReader(s))
+	if err != nil {
+		return nil, err
+	}
+	return &HTMLNode{doc}, nil
+}
+
+// Find returns all descendant elements matching the CSS selector, e.g.
+// "div#main > ul li.item a[href^=https]".
+func (n *HTMLNode) Find(selector string) ([]*HTMLNode, error) {
+	s, err := htmlselect.Compile(selector)
+	if err != nil {
+		return nil, err
+	}
+	var nodes []*HTMLNode
+	for _, node := range s.MatchAll(n.Node) {
+		nodes = append(nodes, &HTMLNode{node})
+	}
+	return nodes, nil
+}
+
+// First returns the first descendant element matching the CSS selector,
+// or nil if not found.
+func (n *HTMLNode) First(selector string) (*HTMLNode, error) {
+	s, err := htmlselect.Compile(selector)
+	if err != nil {
+		return nil, err
+	}
+	if node := s.MatchFirst(n.Node); node != nil {
+		return &HTMLNode{node}, nil
+	}
+	return nil, nil
+}
+
+// Attr returns the value of the attribute with the specified name, or
+// empty string if not exists.
+func (n *HTMLNode) Attr(name string) string {
+	for _, a := range n.Node.Attr {
+		if a.Namespace == "" && a.Key == name {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// Text returns the text content of the node and its descendants.
+func (n *HTMLNode) Text() string {
+	var sb strings.Builder
+	var f func(*html.Node)
+	f = func(node *html.Node) {
+		if node.Type == html.TextNode {
+			sb.WriteString(node.Data)
+		}
+		for c := node.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(n.Node)
+	return sb.String()
+}
+
+// OuterHTML renders the node and its descendants as HTML.
+func (n *HTMLNode) OuterHTML() string {
+	var sb strings.Builder
+	html.Render(&sb, n.Node)
+	return sb.String()
+}
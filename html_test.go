@@ -0,0 +1,31 @@
+package restys
+
+import (
+	"testing"
+
+	"github.com/luoxk/restys/internal/tests"
+)
+
+func TestResponseHTML(t *testing.T) {
+	resp, err := tc().SetAutoDecodeAllContentType().R().Get("/gbk-no-charset")
+	assertSuccess(t, resp, err)
+	doc, err := resp.HTML()
+	tests.AssertNoError(t, err)
+
+	title, err := doc.First("head > title")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, "我是roc", title.Text())
+	tests.AssertEqual(t, "<title>我是roc</title>", title.OuterHTML())
+
+	metas, err := doc.Find("meta[http-equiv]")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, 1, len(metas))
+	tests.AssertEqual(t, "text/html; charset=GBK", metas[0].Attr("content"))
+
+	node, err := doc.First("table")
+	tests.AssertNoError(t, err)
+	tests.AssertIsNil(t, node)
+
+	_, err = doc.Find("div[")
+	tests.AssertErrorContains(t, err, "invalid selector")
+}
@@ -0,0 +1,139 @@
+package restys
+
+import (
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	urlpkg "net/url"
+	"strings"
+	"testing"
+
+	"github.com/quic-go/quic-go"
+	quichttp3 "github.com/quic-go/quic-go/http3"
+
+	"github.com/luoxk/restys/internal/http3"
+	"github.com/luoxk/restys/internal/tests"
+)
+
+// serveHTTP3 serves the handler with HTTP/3 on a random UDP port of
+// 127.0.0.1 and returns the address.
+func serveHTTP3(t *testing.T, handler http.Handler, enableDatagrams bool) string {
+	ts := httptest.NewTLSServer(nil)
+	tlsConfig := ts.TLS.Clone()
+	ts.Close()
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	tests.AssertNoError(t, err)
+	server := &quichttp3.Server{
+		TLSConfig:       tlsConfig,
+		Handler:         handler,
+		EnableDatagrams: enableDatagrams,
+		QUICConfig: &quic.Config{
+			EnableDatagrams:   enableDatagrams,
+			InitialPacketSize: 1350,
+		},
+	}
+	if !enableDatagrams {
+		// the packets must fit into the DATAGRAM frames of the proxy.
+		server.QUICConfig.InitialPacketSize = 1200
+		server.QUICConfig.DisablePathMTUDiscovery = true
+	}
+	go server.Serve(conn)
+	t.Cleanup(func() {
+		server.Close()
+		conn.Close()
+	})
+	return conn.LocalAddr().String()
+}
+
+// masqueProxy is the CONNECT-UDP proxy which relays the HTTP datagrams to
+// the target.
+func masqueProxy(t *testing.T, paths chan<- string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths <- r.URL.Path
+		if r.Method != http.MethodConnect || r.Proto != "connect-udp" || r.Header.Get("Capsule-Protocol") != "?1" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if r.Header.Get("Proxy-Authorization") == "" {
+			w.WriteHeader(http.StatusProxyAuthRequired)
+			return
+		}
+		parts := strings.Split(r.URL.Path, "/")
+		target, err := net.Dial("udp", net.JoinHostPort(parts[4], parts[5]))
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		defer target.Close()
+		w.Header().Set("Capsule-Protocol", "?1")
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		str := w.(quichttp3.HTTPStreamer).HTTPStream()
+		go func() {
+			b := make([]byte, 1500)
+			for {
+				n, err := target.Read(b)
+				if err != nil {
+					return
+				}
+				str.SendDatagram(append([]byte{0}, b[:n]...))
+			}
+		}()
+		for {
+			data, err := str.ReceiveDatagram(r.Context())
+			if err != nil {
+				return
+			}
+			if data[0] == 0 {
+				target.Write(data[1:])
+			}
+		}
+	})
+}
+
+func TestHTTP3Proxy(t *testing.T) {
+	target := serveHTTP3(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello " + r.Proto))
+	}), false)
+	paths := make(chan string, 10)
+	proxy := serveHTTP3(t, masqueProxy(t, paths), true)
+
+	// HTTP/3 may be not supported by the go version.
+	c := tc().SetHTTP3Proxy("https://user:pass@" + proxy)
+	tests.AssertNotNil(t, c.http3Proxy)
+	tests.AssertNotNil(t, c.Clone().http3Proxy)
+	c.t3 = &http3.RoundTripper{
+		Options:         &c.Transport.Options,
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		Proxy:           c.http3Proxy,
+	}
+	defer c.t3.Close()
+	req, err := http.NewRequest(http.MethodGet, "https://"+target+"/", nil)
+	tests.AssertNoError(t, err)
+	resp, err := c.t3.RoundTrip(req)
+	tests.AssertNoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, "hello HTTP/3.0", string(body))
+	host, port, _ := net.SplitHostPort(target)
+	tests.AssertEqual(t, "/.well-known/masque/udp/"+host+"/"+port+"/", <-paths)
+
+	// the proxy rejects the request without the credentials.
+	c.t3 = &http3.RoundTripper{
+		Options:         &c.Transport.Options,
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		Proxy:           &urlpkg.URL{Scheme: "https", Host: proxy, Path: "/masque/{target_host}/{target_port}"},
+	}
+	defer c.t3.Close()
+	_, err = c.t3.RoundTrip(req)
+	tests.AssertErrorContains(t, err, "407")
+	tests.AssertEqual(t, "/masque/"+host+"/"+port, <-paths)
+
+	c.SetHTTP3Proxy("http://" + proxy)
+	tests.AssertEqual(t, "https://user:pass@"+proxy, c.http3Proxy.String())
+	c.SetHTTP3Proxy("")
+	tests.AssertIsNil(t, c.http3Proxy)
+}
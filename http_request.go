@@ -117,10 +117,16 @@ func requestMethodUsuallyLacksBody(method string) bool {
 // requiresHTTP1 reports whether this request requires being sent on
 // an HTTP/1 connection.
 func requestRequiresHTTP1(r *http.Request) bool {
-	return hasToken(r.Header.Get("Connection"), "upgrade") &&
+	return requestIsHTTP10(r) || hasToken(r.Header.Get("Connection"), "upgrade") &&
 		ascii.EqualFold(r.Header.Get("Upgrade"), "websocket")
 }
 
+// requestIsHTTP10 reports whether r is sent as HTTP/1.0, see
+// Request.EnableHTTP10.
+func requestIsHTTP10(r *http.Request) bool {
+	return r.ProtoMajor == 1 && r.ProtoMinor == 0
+}
+
 func isReplayable(r *http.Request) bool {
 	if r.Body == nil || r.Body == NoBody || r.GetBody != nil {
 		switch valueOrDefault(r.Method, "GET") {
@@ -0,0 +1,294 @@
+package restys
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/luoxk/restys/http2"
+	utls "github.com/refraction-networking/utls"
+)
+
+// ImpersonateProfile is the versioned client fingerprint preset, which
+// bundles the tls fingerprint, the http2 settings, the headers and the
+// header order, see Profiles and Client.Impersonate.
+type ImpersonateProfile struct {
+	// Name is the name of the profile, e.g. "chrome_131", which is case
+	// insensitive.
+	Name string
+	// Ja3 is the ja3 string of the tls fingerprint, which takes precedence
+	// over ClientHelloID.
+	Ja3 string
+	// ClientHelloID is the utls client hello of the tls fingerprint, which
+	// is used if Ja3 is empty.
+	ClientHelloID utls.ClientHelloID
+	// HTTP2Settings is the ordered http2 settings frame.
+	HTTP2Settings []http2.Setting
+	// HTTP2ConnectionFlow is the increment value of the initial
+	// WINDOW_UPDATE frame.
+	HTTP2ConnectionFlow uint32
+	// HTTP2PriorityFrames is the ordered http2 priority frames.
+	HTTP2PriorityFrames []http2.PriorityFrame
+	// HTTP2HeaderPriority is the priority of the http2 HEADERS frame.
+	HTTP2HeaderPriority http2.PriorityParam
+	// PseudoHeaderOrder is the order of the http2 pseudo headers.
+	PseudoHeaderOrder []string
+	// HeaderOrder is the order of the headers (lowercase).
+	HeaderOrder []string
+	// Headers is the common headers sent with every request.
+	Headers map[string]string
+	// MultipartBoundaryFunc generates the multipart boundary, the default
+	// one is used if it's nil.
+	MultipartBoundaryFunc func() string
+}
+
+// apply configures the client with the profile, the empty fields of the
+// profile are skipped, except the priority frames which are always reset so
+// the ones of the previous profile are not sent.
+func (p *ImpersonateProfile) apply(c *Client) error {
+	if p.Ja3 != "" {
+		if _, err := c.TrySetJa3WithStr(p.Ja3); err != nil {
+			return err
+		}
+	} else if p.ClientHelloID.Client != "" {
+		c.SetTLSFingerprint(p.ClientHelloID)
+	}
+	if len(p.HTTP2Settings) > 0 {
+		c.SetHTTP2SettingsFrame(p.HTTP2Settings...)
+	}
+	if p.HTTP2ConnectionFlow > 0 {
+		c.SetHTTP2ConnectionFlow(p.HTTP2ConnectionFlow)
+	}
+	c.SetHTTP2PriorityFrames(p.HTTP2PriorityFrames...)
+	if !p.HTTP2HeaderPriority.IsZero() {
+		c.SetHTTP2HeaderPriority(p.HTTP2HeaderPriority)
+	}
+	if len(p.PseudoHeaderOrder) > 0 {
+		c.SetCommonPseudoHeaderOder(p.PseudoHeaderOrder...)
+	}
+	if len(p.HeaderOrder) > 0 {
+		c.SetCommonHeaderOrder(p.HeaderOrder...)
+	}
+	if len(p.Headers) > 0 {
+		c.SetCommonHeaders(p.Headers)
+	}
+	if p.MultipartBoundaryFunc != nil {
+		c.SetMultipartBoundaryFunc(p.MultipartBoundaryFunc)
+	}
+	return nil
+}
+
+// ProfileRegistry is the registry of the impersonate profiles, which is
+// safe for concurrent use.
+type ProfileRegistry struct {
+	mu       sync.RWMutex
+	profiles map[string]*ImpersonateProfile
+}
+
+// Profiles is the registry of the impersonate profiles used by
+// Client.Impersonate, which contains the builtin presets "chrome_131",
+// "edge_131", "firefox_133", "safari_17", "ios_18" and "okhttp_4".
+var Profiles = &ProfileRegistry{profiles: make(map[string]*ImpersonateProfile)}
+
+// Get returns the profile with the name, or nil if it doesn't exist.
+func (r *ProfileRegistry) Get(name string) *ImpersonateProfile {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.profiles[strings.ToLower(name)]
+}
+
+// Register adds the profile to the registry, the profile with the same
+// name is replaced.
+func (r *ProfileRegistry) Register(profile *ImpersonateProfile) error {
+	if profile == nil || profile.Name == "" {
+		return errors.New("impersonate profile name is required")
+	}
+	if profile.Ja3 != "" {
+		if err := ValidateJa3(profile.Ja3); err != nil {
+			return err
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.profiles[strings.ToLower(profile.Name)] = profile
+	return nil
+}
+
+// Names returns the sorted names of the registered profiles.
+func (r *ProfileRegistry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.profiles))
+	for name := range r.profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Impersonate impersonates the client with the profile of the name in
+// Profiles, e.g. "chrome_131", it returns an error if the profile doesn't
+// exist.
+func (c *Client) Impersonate(name string) error {
+	profile := Profiles.Get(name)
+	if profile == nil {
+		return fmt.Errorf("impersonate profile %q not found", name)
+	}
+	return profile.apply(c)
+}
+
+// mustH2Spec parses the akamai fingerprint of the builtin profiles.
+func mustH2Spec(akamai string) H2Spec {
+	spec, err := createH2SpecWithStr(akamai)
+	if err != nil {
+		panic(err)
+	}
+	return spec
+}
+
+func newBuiltinProfile(name, ja3 string, clientHelloID utls.ClientHelloID, akamai string, headerPriority http2.PriorityParam, headerOrder []string, headers map[string]string, boundaryFunc func() string) *ImpersonateProfile {
+	h2 := mustH2Spec(akamai)
+	return &ImpersonateProfile{
+		Name:                  name,
+		Ja3:                   ja3,
+		ClientHelloID:         clientHelloID,
+		HTTP2Settings:         h2.InitialSetting,
+		HTTP2ConnectionFlow:   h2.ConnFlow,
+		HTTP2PriorityFrames:   h2.PriorityFrames,
+		HTTP2HeaderPriority:   headerPriority,
+		PseudoHeaderOrder:     h2.OrderHeaders,
+		HeaderOrder:           headerOrder,
+		Headers:               headers,
+		MultipartBoundaryFunc: boundaryFunc,
+	}
+}
+
+const chrome131Ja3 = "771,4865-4866-4867-49195-49199-49196-49200-52393-52392-49171-49172-156-157-47-53,0-23-65281-10-11-35-16-5-13-18-51-45-43-27-17513-65037,4588-29-23-24,0"
+
+const okhttp4Ja3 = "771,4865-4866-4867-49195-49196-52393-49199-49200-52392-49171-49172-156-157-47-53,0-23-65281-10-11-35-16-5-13-51-45-43-21,29-23-24,0"
+
+func chromiumHeaders(secCHUA, userAgent string) map[string]string {
+	return map[string]string{
+		"sec-ch-ua":                 secCHUA,
+		"sec-ch-ua-mobile":          "?0",
+		"sec-ch-ua-platform":        `"Windows"`,
+		"upgrade-insecure-requests": "1",
+		"user-agent":                userAgent,
+		"accept":                    "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/apng,*/*;q=0.8,application/signed-exchange;v=b3;q=0.7",
+		"sec-fetch-site":            "none",
+		"sec-fetch-mode":            "navigate",
+		"sec-fetch-user":            "?1",
+		"sec-fetch-dest":            "document",
+		"accept-language":           "en-US,en;q=0.9",
+		"priority":                  "u=0, i",
+	}
+}
+
+var chromiumHeaderOrder = []string{
+	"host",
+	"sec-ch-ua",
+	"sec-ch-ua-mobile",
+	"sec-ch-ua-platform",
+	"upgrade-insecure-requests",
+	"user-agent",
+	"accept",
+	"sec-fetch-site",
+	"sec-fetch-mode",
+	"sec-fetch-user",
+	"sec-fetch-dest",
+	"referer",
+	"accept-encoding",
+	"accept-language",
+	"cookie",
+	"priority",
+}
+
+var webkitHeaderOrder = []string{
+	"sec-fetch-dest",
+	"user-agent",
+	"accept",
+	"referer",
+	"sec-fetch-site",
+	"sec-fetch-mode",
+	"accept-language",
+	"priority",
+	"accept-encoding",
+	"cookie",
+}
+
+// builtinProfiles is the builtin presets by name, which are not affected by
+// the profiles registered later.
+var builtinProfiles = make(map[string]*ImpersonateProfile)
+
+func init() {
+	profiles := []*ImpersonateProfile{
+		newBuiltinProfile("chrome_131", chrome131Ja3, utls.ClientHelloID{},
+			"1:65536;2:0;4:6291456;6:262144|15663105|0|m,a,s,p",
+			chromeHeaderPriority, chromiumHeaderOrder,
+			chromiumHeaders(`"Google Chrome";v="131", "Chromium";v="131", "Not_A Brand";v="24"`,
+				"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/131.0.0.0 Safari/537.36"),
+			webkitMultipartBoundaryFunc),
+		newBuiltinProfile("edge_131", chrome131Ja3, utls.ClientHelloID{},
+			"1:65536;2:0;4:6291456;6:262144|15663105|0|m,a,s,p",
+			chromeHeaderPriority, chromiumHeaderOrder,
+			chromiumHeaders(`"Microsoft Edge";v="131", "Chromium";v="131", "Not_A Brand";v="24"`,
+				"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/131.0.0.0 Safari/537.36 Edg/131.0.0.0"),
+			webkitMultipartBoundaryFunc),
+		newBuiltinProfile("firefox_133", "", utls.HelloFirefox_120,
+			"1:65536;2:0;4:131072;5:16384|12517377|0|m,p,a,s",
+			http2.PriorityParam{Weight: 41}, firefoxHeaderOrder,
+			map[string]string{
+				"user-agent":                "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:133.0) Gecko/20100101 Firefox/133.0",
+				"accept":                    "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8",
+				"accept-language":           "en-US,en;q=0.5",
+				"upgrade-insecure-requests": "1",
+				"sec-fetch-dest":            "document",
+				"sec-fetch-mode":            "navigate",
+				"sec-fetch-site":            "none",
+				"sec-fetch-user":            "?1",
+				"priority":                  "u=0, i",
+			},
+			firefoxMultipartBoundaryFunc),
+		newBuiltinProfile("safari_17", "", utls.HelloSafari_16_0,
+			"2:0;4:4194304;3:100|10485760|0|m,s,p,a",
+			safariHeaderPriority, webkitHeaderOrder,
+			map[string]string{
+				"sec-fetch-dest":  "document",
+				"user-agent":      "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.6 Safari/605.1.15",
+				"accept":          "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8",
+				"sec-fetch-site":  "none",
+				"sec-fetch-mode":  "navigate",
+				"accept-language": "en-US,en;q=0.9",
+				"priority":        "u=0, i",
+			},
+			webkitMultipartBoundaryFunc),
+		newBuiltinProfile("ios_18", "", utls.HelloIOS_14,
+			"2:0;3:100;4:2097152;9:1|10420225|0|m,s,a,p",
+			safariHeaderPriority, webkitHeaderOrder,
+			map[string]string{
+				"sec-fetch-dest":  "document",
+				"user-agent":      "Mozilla/5.0 (iPhone; CPU iPhone OS 18_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/18.1 Mobile/15E148 Safari/604.1",
+				"accept":          "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8",
+				"sec-fetch-site":  "none",
+				"sec-fetch-mode":  "navigate",
+				"accept-language": "en-US,en;q=0.9",
+				"priority":        "u=0, i",
+			},
+			webkitMultipartBoundaryFunc),
+		newBuiltinProfile("okhttp_4", okhttp4Ja3, utls.ClientHelloID{},
+			"4:16777216|16711681|0|m,p,a,s",
+			http2.PriorityParam{}, []string{"content-type", "content-length", "host", "connection", "accept-encoding", "cookie", "user-agent"},
+			map[string]string{
+				"user-agent": "okhttp/4.12.0",
+			},
+			nil),
+	}
+	for _, profile := range profiles {
+		if err := Profiles.Register(profile); err != nil {
+			panic(err)
+		}
+		builtinProfiles[profile.Name] = profile
+	}
+}
@@ -0,0 +1,61 @@
+package restys
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/luoxk/restys/internal/tests"
+)
+
+func TestImpersonateProfiles(t *testing.T) {
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Proto", r.Proto)
+		w.Write([]byte(r.UserAgent()))
+	}))
+	ts.EnableHTTP2 = true
+	ts.StartTLS()
+	defer ts.Close()
+
+	tests.AssertEqual(t, []string{"chrome_131", "edge_131", "firefox_133", "ios_18", "okhttp_4", "safari_17"}, Profiles.Names())
+	for _, name := range Profiles.Names() {
+		c := C().EnableInsecureSkipVerify()
+		tests.AssertNoError(t, c.Impersonate(name))
+		resp, err := c.R().Get(ts.URL)
+		assertSuccess(t, resp, err)
+		tests.AssertEqual(t, Profiles.Get(name).Headers["user-agent"], resp.String())
+		tests.AssertEqual(t, "HTTP/2.0", resp.GetHeader("X-Proto"))
+	}
+
+	for _, impersonate := range []func(c *Client) *Client{
+		(*Client).ImpersonateChrome,
+		(*Client).ImpersonateEdge,
+		(*Client).ImpersonateFirefox,
+		(*Client).ImpersonateSafari,
+		(*Client).ImpersonateIOS,
+		(*Client).ImpersonateOkHttp,
+	} {
+		c := impersonate(C().EnableInsecureSkipVerify())
+		resp, err := c.R().Get(ts.URL)
+		assertSuccess(t, resp, err)
+		tests.AssertEqual(t, c.Headers.Get("User-Agent"), resp.String())
+		tests.AssertEqual(t, "HTTP/2.0", resp.GetHeader("X-Proto"))
+	}
+
+	tests.AssertErrorContains(t, C().Impersonate("netscape_4"), "not found")
+	tests.AssertNotNil(t, Profiles.Register(&ImpersonateProfile{}))
+	tests.AssertNotNil(t, Profiles.Register(&ImpersonateProfile{Name: "bad", Ja3: "771"}))
+
+	tests.AssertNoError(t, Profiles.Register(&ImpersonateProfile{
+		Name:    "My_Bot",
+		Headers: map[string]string{"user-agent": "my-bot/1.0"},
+	}))
+	defer func() {
+		Profiles.mu.Lock()
+		delete(Profiles.profiles, "my_bot")
+		Profiles.mu.Unlock()
+	}()
+	c := tc()
+	tests.AssertNoError(t, c.Impersonate("my_bot"))
+	tests.AssertEqual(t, "my-bot/1.0", c.Headers.Get("User-Agent"))
+}
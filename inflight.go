@@ -0,0 +1,130 @@
+package restys
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// InflightRequest is the snapshot of a request which is currently
+// executing, see Client.InflightRequests.
+type InflightRequest struct {
+	ID        uint64    `json:"id"`
+	Method    string    `json:"method"`
+	URL       string    `json:"url"`
+	StartTime time.Time `json:"start_time"`
+	Attempt   int       `json:"attempt"`
+	RequestID string    `json:"request_id,omitempty"`
+}
+
+// inflightRegistry records the requests which are currently executing.
+type inflightRegistry struct {
+	mu       sync.Mutex
+	nextID   uint64
+	requests map[uint64]*InflightRequest
+	drained  chan struct{} // closed when no request is executing
+}
+
+func newInflightRegistry() *inflightRegistry {
+	return &inflightRegistry{
+		requests: make(map[uint64]*InflightRequest),
+	}
+}
+
+func (ir *inflightRegistry) add(r *Request) uint64 {
+	ir.mu.Lock()
+	defer ir.mu.Unlock()
+	ir.nextID++
+	ir.requests[ir.nextID] = &InflightRequest{
+		ID:        ir.nextID,
+		Method:    r.Method,
+		URL:       r.RawURL,
+		StartTime: time.Now(),
+	}
+	return ir.nextID
+}
+
+// update updates the request snapshot before each attempt.
+func (ir *inflightRegistry) update(id uint64, r *Request) {
+	ir.mu.Lock()
+	defer ir.mu.Unlock()
+	req := ir.requests[id]
+	if req == nil {
+		return
+	}
+	req.Method = r.Method
+	if r.URL != nil {
+		req.URL = r.URL.String()
+	}
+	req.Attempt = r.RetryAttempt
+	req.RequestID = r.requestID
+}
+
+func (ir *inflightRegistry) remove(id uint64) {
+	ir.mu.Lock()
+	delete(ir.requests, id)
+	if len(ir.requests) == 0 && ir.drained != nil {
+		close(ir.drained)
+		ir.drained = nil
+	}
+	ir.mu.Unlock()
+}
+
+// wait blocks until no request is executing, or ctx is done.
+func (ir *inflightRegistry) wait(ctx context.Context) error {
+	ir.mu.Lock()
+	if len(ir.requests) == 0 {
+		ir.mu.Unlock()
+		return nil
+	}
+	if ir.drained == nil {
+		ir.drained = make(chan struct{})
+	}
+	drained := ir.drained
+	ir.mu.Unlock()
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (ir *inflightRegistry) list() []InflightRequest {
+	ir.mu.Lock()
+	requests := make([]InflightRequest, 0, len(ir.requests))
+	for _, r := range ir.requests {
+		requests = append(requests, *r)
+	}
+	ir.mu.Unlock()
+	sort.Slice(requests, func(i, j int) bool {
+		return requests[i].ID < requests[j].ID
+	})
+	return requests
+}
+
+// InflightRequests returns the snapshot of all requests which are currently
+// executing by the client in the order they started, which is useful to
+// diagnose stuck requests, it can also be published with expvar, e.g.
+//
+//	expvar.Publish("inflight_requests", expvar.Func(func() any {
+//		return client.InflightRequests()
+//	}))
+func (c *Client) InflightRequests() []InflightRequest {
+	return c.inflight.list()
+}
+
+// InflightRequestsHandler returns the http.Handler which responds the
+// in-flight requests of the client (see Client.InflightRequests) in JSON,
+// which can be registered to the debug HTTP server, e.g.
+//
+//	http.Handle("/debug/inflight", client.InflightRequestsHandler())
+func (c *Client) InflightRequestsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(c.InflightRequests())
+	})
+}
@@ -0,0 +1,50 @@
+package restys
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/luoxk/restys/internal/tests"
+)
+
+func TestInflightRequests(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+
+	c := C()
+	tests.AssertEqual(t, 0, len(c.InflightRequests()))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.R().Get(server.URL + "/stuck")
+	}()
+
+	var requests []InflightRequest
+	for i := 0; i < 100 && len(requests) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+		requests = c.InflightRequests()
+	}
+	tests.AssertEqual(t, 1, len(requests))
+	tests.AssertEqual(t, http.MethodGet, requests[0].Method)
+	tests.AssertEqual(t, server.URL+"/stuck", requests[0].URL)
+	tests.AssertEqual(t, 0, requests[0].Attempt)
+	tests.AssertEqual(t, false, requests[0].StartTime.IsZero())
+	tests.AssertEqual(t, 0, len(c.Clone().InflightRequests()))
+
+	rec := httptest.NewRecorder()
+	c.InflightRequestsHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/inflight", nil))
+	var got []InflightRequest
+	tests.AssertNoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+	tests.AssertEqual(t, 1, len(got))
+	tests.AssertEqual(t, requests[0].ID, got[0].ID)
+
+	close(release)
+	<-done
+	tests.AssertEqual(t, 0, len(c.InflightRequests()))
+}
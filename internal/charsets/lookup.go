@@ -0,0 +1,66 @@
+package charsets
+
+import (
+	"strings"
+
+	htmlcharset "golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/ianaindex"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/korean"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// aliases contains the common charset names which are used in the wild
+// but are not recognized by the WHATWG or IANA index.
+var aliases = map[string]struct {
+	enc  encoding.Encoding
+	name string
+}{
+	"utf8":        {unicode.UTF8, "utf-8"},
+	"sjis":        {japanese.ShiftJIS, "shift_jis"},
+	"shift-jis":   {japanese.ShiftJIS, "shift_jis"},
+	"cp932":       {japanese.ShiftJIS, "shift_jis"},
+	"ms932":       {japanese.ShiftJIS, "shift_jis"},
+	"windows-31j": {japanese.ShiftJIS, "shift_jis"},
+	"eucjp":       {japanese.EUCJP, "euc-jp"},
+	"euckr":       {korean.EUCKR, "euc-kr"},
+	"cp949":       {korean.EUCKR, "euc-kr"},
+	"uhc":         {korean.EUCKR, "euc-kr"},
+	"cp936":       {simplifiedchinese.GBK, "gbk"},
+	"gb2312":      {simplifiedchinese.GBK, "gbk"},
+	"hz-gb-2312":  {simplifiedchinese.HZGB2312, "hz-gb-2312"},
+	"cp950":       {traditionalchinese.Big5, "big5"},
+	"big5-hkscs":  {traditionalchinese.Big5, "big5"},
+	"cp1250":      {charmap.Windows1250, "windows-1250"},
+	"cp1251":      {charmap.Windows1251, "windows-1251"},
+	"cp1252":      {charmap.Windows1252, "windows-1252"},
+	"cp1253":      {charmap.Windows1253, "windows-1253"},
+	"cp1254":      {charmap.Windows1254, "windows-1254"},
+	"cp1255":      {charmap.Windows1255, "windows-1255"},
+	"cp1256":      {charmap.Windows1256, "windows-1256"},
+	"cp1257":      {charmap.Windows1257, "windows-1257"},
+	"cp1258":      {charmap.Windows1258, "windows-1258"},
+}
+
+// Lookup returns the encoding with the specified charset name, and its
+// canonical name. It returns nil if the charset is not supported.
+func Lookup(charset string) (enc encoding.Encoding, name string) {
+	charset = strings.ToLower(strings.TrimSpace(charset))
+	if a, ok := aliases[charset]; ok {
+		return a.enc, a.name
+	}
+	if enc, name = htmlcharset.Lookup(charset); enc != nil {
+		return
+	}
+	if enc, err := ianaindex.MIME.Encoding(charset); err == nil && enc != nil {
+		if name, err = ianaindex.MIME.Name(enc); err != nil {
+			name = charset
+		}
+		return enc, strings.ToLower(name)
+	}
+	return nil, ""
+}
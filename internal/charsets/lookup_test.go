@@ -0,0 +1,29 @@
+package charsets
+
+import (
+	"testing"
+
+	"github.com/luoxk/restys/internal/tests"
+)
+
+func TestLookup(t *testing.T) {
+	cases := []struct {
+		charset, want string
+	}{
+		{"GBK", "gbk"},
+		{"gb2312", "gbk"},
+		{"Shift_JIS", "shift_jis"},
+		{"sjis", "shift_jis"},
+		{"EUC-KR", "euc-kr"},
+		{"cp949", "euc-kr"},
+		{"windows-1251", "windows-1251"},
+		{"cp1252", "windows-1252"},
+		{"utf8", "utf-8"},
+		{"not-a-charset", ""},
+	}
+	for _, c := range cases {
+		enc, name := Lookup(c.charset)
+		tests.AssertEqual(t, c.want, name)
+		tests.AssertEqual(t, c.want != "", enc != nil)
+	}
+}
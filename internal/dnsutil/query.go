@@ -0,0 +1,248 @@
+// Package dnsutil implements a minimal DNS stub resolver which reports the
+// TTL of the answers, which is not exposed by net.Resolver.
+package dnsutil
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/netip"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// DialFunc dials the DNS server.
+type DialFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+// ErrNotFound is returned if the host name does not exist or has no
+// address records.
+var ErrNotFound = errors.New("no such host")
+
+// LookupNetIP queries the A and AAAA records of host from the DNS server
+// at address, it returns the addresses and the minimum TTL of them.
+func LookupNetIP(ctx context.Context, dial DialFunc, address, host string) (ips []netip.Addr, ttl time.Duration, err error) {
+	name, err := dnsmessage.NewName(strings.TrimSuffix(host, ".") + ".")
+	if err != nil {
+		return nil, 0, err
+	}
+	minTTL := uint32(0)
+	found := false
+	for _, qtype := range []dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA} {
+		msg, err := exchange(ctx, dial, address, name, qtype)
+		if err != nil {
+			return nil, 0, err
+		}
+		switch msg.RCode {
+		case dnsmessage.RCodeSuccess:
+		case dnsmessage.RCodeNameError:
+			return nil, 0, ErrNotFound
+		default:
+			return nil, 0, errors.New("dns: server responded with " + msg.RCode.String())
+		}
+		for _, answer := range msg.Answers {
+			var ip netip.Addr
+			switch body := answer.Body.(type) {
+			case *dnsmessage.AResource:
+				ip = netip.AddrFrom4(body.A)
+			case *dnsmessage.AAAAResource:
+				ip = netip.AddrFrom16(body.AAAA)
+			default: // e.g. CNAME, the TTL is still relevant.
+			}
+			if !found || answer.Header.TTL < minTTL {
+				minTTL = answer.Header.TTL
+				found = true
+			}
+			if ip.IsValid() {
+				ips = append(ips, ip)
+			}
+		}
+	}
+	if len(ips) == 0 {
+		return nil, 0, ErrNotFound
+	}
+	return ips, time.Duration(minTTL) * time.Second, nil
+}
+
+// typeHTTPS is the HTTPS resource record type (RFC 9460).
+const typeHTTPS = dnsmessage.Type(65)
+
+// svcParamECH is the SvcParamKey of the ECHConfigList.
+const svcParamECH = 5
+
+// LookupECHConfigList queries the HTTPS record of host from the DNS server
+// at address, it returns the ECHConfigList of the ServiceMode record with
+// the lowest priority, or nil if there is none.
+func LookupECHConfigList(ctx context.Context, dial DialFunc, address, host string) ([]byte, error) {
+	name, err := dnsmessage.NewName(strings.TrimSuffix(host, ".") + ".")
+	if err != nil {
+		return nil, err
+	}
+	msg, err := exchange(ctx, dial, address, name, typeHTTPS)
+	if err != nil {
+		return nil, err
+	}
+	switch msg.RCode {
+	case dnsmessage.RCodeSuccess:
+	case dnsmessage.RCodeNameError:
+		return nil, ErrNotFound
+	default:
+		return nil, errors.New("dns: server responded with " + msg.RCode.String())
+	}
+	var (
+		echConfigList []byte
+		minPriority   uint16
+	)
+	for _, answer := range msg.Answers {
+		body, ok := answer.Body.(*dnsmessage.UnknownResource)
+		if !ok || answer.Header.Type != typeHTTPS {
+			continue
+		}
+		priority, ech, err := parseSVCB(body.Data)
+		if err != nil {
+			return nil, err
+		}
+		// priority 0 is the AliasMode which has no SvcParams.
+		if priority == 0 || ech == nil || (echConfigList != nil && priority >= minPriority) {
+			continue
+		}
+		echConfigList, minPriority = ech, priority
+	}
+	return echConfigList, nil
+}
+
+// parseSVCB parses the SVCB RDATA, it returns the SvcPriority and the
+// value of the ech SvcParam.
+func parseSVCB(data []byte) (priority uint16, ech []byte, err error) {
+	errInvalid := errors.New("dns: invalid HTTPS record")
+	if len(data) < 3 {
+		return 0, nil, errInvalid
+	}
+	priority = binary.BigEndian.Uint16(data)
+	data = data[2:]
+	// TargetName, which is not compressed.
+	for {
+		if len(data) == 0 {
+			return 0, nil, errInvalid
+		}
+		n := int(data[0])
+		data = data[1:]
+		if n == 0 {
+			break
+		}
+		if len(data) < n {
+			return 0, nil, errInvalid
+		}
+		data = data[n:]
+	}
+	for len(data) > 0 {
+		if len(data) < 4 {
+			return 0, nil, errInvalid
+		}
+		key, n := binary.BigEndian.Uint16(data), int(binary.BigEndian.Uint16(data[2:]))
+		data = data[4:]
+		if len(data) < n {
+			return 0, nil, errInvalid
+		}
+		if key == svcParamECH {
+			ech = data[:n:n]
+		}
+		data = data[n:]
+	}
+	return priority, ech, nil
+}
+
+func exchange(ctx context.Context, dial DialFunc, address string, name dnsmessage.Name, qtype dnsmessage.Type) (*dnsmessage.Message, error) {
+	id := uint16(rand.Uint32())
+	query := dnsmessage.Message{
+		Header: dnsmessage.Header{ID: id, RecursionDesired: true},
+		Questions: []dnsmessage.Question{{
+			Name:  name,
+			Type:  qtype,
+			Class: dnsmessage.ClassINET,
+		}},
+	}
+	b, err := query.Pack()
+	if err != nil {
+		return nil, err
+	}
+	msg, err := roundTrip(ctx, dial, "udp", address, id, b)
+	if err == nil && msg.Truncated {
+		msg, err = roundTrip(ctx, dial, "tcp", address, id, b)
+	}
+	return msg, err
+}
+
+func roundTrip(ctx context.Context, dial DialFunc, network, address string, id uint16, query []byte) (*dnsmessage.Message, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+	}
+	conn, err := dial(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	var resp []byte
+	if _, isPacket := conn.(net.PacketConn); isPacket {
+		if _, err = conn.Write(query); err != nil {
+			return nil, err
+		}
+		resp = make([]byte, 1232)
+		n, err := conn.Read(resp)
+		if err != nil {
+			return nil, err
+		}
+		resp = resp[:n]
+	} else {
+		b := make([]byte, 2+len(query))
+		binary.BigEndian.PutUint16(b, uint16(len(query)))
+		copy(b[2:], query)
+		if _, err = conn.Write(b); err != nil {
+			return nil, err
+		}
+		if _, err = io.ReadFull(conn, b[:2]); err != nil {
+			return nil, err
+		}
+		resp = make([]byte, binary.BigEndian.Uint16(b[:2]))
+		if _, err = io.ReadFull(conn, resp); err != nil {
+			return nil, err
+		}
+	}
+
+	var msg dnsmessage.Message
+	if err = msg.Unpack(resp); err != nil {
+		return nil, err
+	}
+	if msg.ID != id || !msg.Response {
+		return nil, errors.New("dns: invalid response")
+	}
+	return &msg, nil
+}
+
+// SystemNameServer returns the first name server in /etc/resolv.conf, or
+// "127.0.0.1:53" if not found, which is the same default as net.Resolver.
+func SystemNameServer() string {
+	b, err := os.ReadFile("/etc/resolv.conf")
+	if err == nil {
+		for _, line := range strings.Split(string(b), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) >= 2 && fields[0] == "nameserver" {
+				if ip, err := netip.ParseAddr(fields[1]); err == nil {
+					return netip.AddrPortFrom(ip, 53).String()
+				}
+			}
+		}
+	}
+	return "127.0.0.1:53"
+}
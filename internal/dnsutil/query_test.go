@@ -0,0 +1,95 @@
+package dnsutil
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/luoxk/restys/internal/tests"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// svcb returns the SVCB RDATA with the target name "." and the ech
+// SvcParam if not nil.
+func svcb(priority uint16, ech []byte) []byte {
+	b := []byte{byte(priority >> 8), byte(priority), 0}
+	b = append(b, 0, 1, 0, 3, 2, 'h', '2') // alpn
+	if ech != nil {
+		b = append(b, 0, svcParamECH, byte(len(ech)>>8), byte(len(ech)))
+		b = append(b, ech...)
+	}
+	return b
+}
+
+// serveDNS starts a UDP DNS server which answers "example.test." with
+// 192.0.2.1 (TTL 60), 2001:db8::1 (TTL 30) and the HTTPS records, and
+// NXDOMAIN for others.
+func serveDNS(t *testing.T) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	tests.AssertNoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			var q dnsmessage.Message
+			if q.Unpack(buf[:n]) != nil {
+				continue
+			}
+			resp := dnsmessage.Message{
+				Header:    dnsmessage.Header{ID: q.ID, Response: true},
+				Questions: q.Questions,
+			}
+			question := q.Questions[0]
+			hdr := dnsmessage.ResourceHeader{Name: question.Name, Type: question.Type, Class: dnsmessage.ClassINET}
+			switch {
+			case question.Name.String() != "example.test.":
+				resp.RCode = dnsmessage.RCodeNameError
+			case question.Type == dnsmessage.TypeA:
+				hdr.TTL = 60
+				resp.Answers = append(resp.Answers, dnsmessage.Resource{Header: hdr, Body: &dnsmessage.AResource{A: [4]byte{192, 0, 2, 1}}})
+			case question.Type == dnsmessage.TypeAAAA:
+				hdr.TTL = 30
+				resp.Answers = append(resp.Answers, dnsmessage.Resource{Header: hdr, Body: &dnsmessage.AAAAResource{AAAA: netip.MustParseAddr("2001:db8::1").As16()}})
+			case question.Type == typeHTTPS:
+				for _, data := range [][]byte{svcb(2, []byte("ech2")), svcb(0, nil), svcb(1, []byte("ech1")), svcb(3, nil)} {
+					resp.Answers = append(resp.Answers, dnsmessage.Resource{Header: hdr, Body: &dnsmessage.UnknownResource{Type: typeHTTPS, Data: data}})
+				}
+			}
+			b, _ := resp.Pack()
+			conn.WriteTo(b, addr)
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func TestLookupNetIP(t *testing.T) {
+	addr := serveDNS(t)
+	var d net.Dialer
+	ips, ttl, err := LookupNetIP(context.Background(), d.DialContext, addr, "example.test")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, []netip.Addr{netip.MustParseAddr("192.0.2.1"), netip.MustParseAddr("2001:db8::1")}, ips)
+	tests.AssertEqual(t, 30*time.Second, ttl)
+
+	_, _, err = LookupNetIP(context.Background(), d.DialContext, addr, "missing.test")
+	tests.AssertEqual(t, ErrNotFound, err)
+}
+
+func TestLookupECHConfigList(t *testing.T) {
+	addr := serveDNS(t)
+	var d net.Dialer
+	ech, err := LookupECHConfigList(context.Background(), d.DialContext, addr, "example.test")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, []byte("ech1"), ech)
+
+	_, err = LookupECHConfigList(context.Background(), d.DialContext, addr, "missing.test")
+	tests.AssertEqual(t, ErrNotFound, err)
+
+	_, _, err = parseSVCB([]byte{0, 1, 3, 'a'})
+	tests.AssertErrorContains(t, err, "invalid HTTPS record")
+}
@@ -1,9 +1,12 @@
 package dump
 
 import (
+	"bytes"
 	"context"
 	"io"
 	"net/http"
+	"sync"
+	"sync/atomic"
 )
 
 // Options controls the dump behavior.
@@ -18,21 +21,30 @@ type Options interface {
 	ResponseHeader() bool
 	ResponseBody() bool
 	Async() bool
+	AsyncQueueSize() int
+	AsyncOverflow() int
+	AsyncSampleRate() int
+	RedactHeader(key string) bool
+	RedactBody(isRequest bool, p []byte) []byte
+	MaxRequestBodySize() int64
+	MaxResponseBodySize() int64
+	HTTP2Frames() bool
 	Clone() Options
 }
 
 func (d *Dumper) WrapResponseBodyReadCloser(rc io.ReadCloser) io.ReadCloser {
-	return &dumpReponseBodyReadCloser{rc, d}
+	return &dumpReponseBodyReadCloser{rc, d, d.newBodyDumper(false)}
 }
 
 type dumpReponseBodyReadCloser struct {
 	io.ReadCloser
 	dump *Dumper
+	body *bodyDumper
 }
 
 func (r *dumpReponseBodyReadCloser) Read(p []byte) (n int, err error) {
 	n, err = r.ReadCloser.Read(p)
-	r.dump.DumpResponseBody(p[:n])
+	r.body.Dump(p[:n])
 	if err == io.EOF {
 		r.dump.DumpDefault([]byte("\r\n"))
 	}
@@ -40,17 +52,17 @@ func (r *dumpReponseBodyReadCloser) Read(p []byte) (n int, err error) {
 }
 
 func (d *Dumper) WrapRequestBodyWriteCloser(rc io.WriteCloser) io.WriteCloser {
-	return &dumpRequestBodyWriteCloser{rc, d}
+	return &dumpRequestBodyWriteCloser{rc, d.newBodyDumper(true)}
 }
 
 type dumpRequestBodyWriteCloser struct {
 	io.WriteCloser
-	dump *Dumper
+	body *bodyDumper
 }
 
 func (w *dumpRequestBodyWriteCloser) Write(p []byte) (n int, err error) {
 	n, err = w.WriteCloser.Write(p)
-	w.dump.DumpRequestBody(p[:n])
+	w.body.Dump(p[:n])
 	return
 }
 
@@ -74,19 +86,75 @@ func (d *Dumper) WrapRequestHeaderWriter(w io.Writer) io.Writer {
 
 type dumpRequestBodyWriter struct {
 	w    io.Writer
-	dump *Dumper
+	body *bodyDumper
 }
 
 func (w *dumpRequestBodyWriter) Write(p []byte) (n int, err error) {
 	n, err = w.w.Write(p)
-	w.dump.DumpRequestBody(p[:n])
+	w.body.Dump(p[:n])
 	return
 }
 
 func (d *Dumper) WrapRequestBodyWriter(w io.Writer) io.Writer {
 	return &dumpRequestBodyWriter{
 		w:    w,
-		dump: d,
+		body: d.newBodyDumper(true),
+	}
+}
+
+// RequestBodyDumper returns the function to dump the request body of
+// an exchange in chunks, which truncates the body if it exceeds the
+// MaxRequestBodySize.
+func (d *Dumper) RequestBodyDumper() func(p []byte) {
+	return d.newBodyDumper(true).Dump
+}
+
+var truncatedBody = []byte("\r\n... (body truncated)")
+
+// bodyDumper dumps the body of an exchange, and truncates it if it
+// exceeds the size limit.
+type bodyDumper struct {
+	dump      *Dumper
+	isRequest bool
+	limit     int64
+	n         int64
+}
+
+func (d *Dumper) newBodyDumper(isRequest bool) *bodyDumper {
+	b := &bodyDumper{dump: d, isRequest: isRequest}
+	if isRequest {
+		b.limit = d.MaxRequestBodySize()
+	} else {
+		b.limit = d.MaxResponseBodySize()
+	}
+	return b
+}
+
+func (b *bodyDumper) Dump(p []byte) {
+	if b.limit > 0 {
+		remain := b.limit - b.n
+		b.n += int64(len(p))
+		if remain <= 0 {
+			return
+		}
+		if int64(len(p)) > remain {
+			b.dumpBody(p[:remain])
+			if b.isRequest {
+				b.dump.DumpTo(truncatedBody, b.dump.RequestBodyOutput())
+			} else {
+				b.dump.DumpTo(truncatedBody, b.dump.ResponseBodyOutput())
+			}
+			return
+		}
+	}
+	b.dumpBody(p)
+}
+
+func (b *bodyDumper) dumpBody(p []byte) {
+	if b.isRequest {
+		b.dump.DumpRequestBody(p)
+	} else {
+		b.dump.DumpResponseBody(p)
 	}
 }
 
@@ -116,22 +184,56 @@ func (ds Dumpers) DumpResponseHeader(p []byte) {
 	}
 }
 
+// The overflow policies of the async dump queue, see Options.AsyncOverflow.
+const (
+	OverflowBlock = iota
+	OverflowDrop
+	OverflowSample
+)
+
 // Dumper is the dump tool.
 type Dumper struct {
 	Options
-	ch chan *dumpTask
+	ch         chan *dumpTask
+	dropped    atomic.Uint64
+	overflowed atomic.Uint64
 }
 
 type dumpTask struct {
 	Data   []byte
 	Output io.Writer
+	done   chan struct{} // flush marker
+}
+
+// maxPooledTaskSize is the max capacity of the task data which is put back
+// to the pool, the dumps of large bodies are left to GC.
+const maxPooledTaskSize = 64 << 10
+
+// dumpTaskPool reuses the async dump tasks and their data buffers, each
+// header line and body chunk is a task under high throughput.
+var dumpTaskPool = sync.Pool{New: func() any { return new(dumpTask) }}
+
+func newDumpTask(p []byte, output io.Writer) *dumpTask {
+	t := dumpTaskPool.Get().(*dumpTask)
+	t.Data = append(t.Data[:0], p...)
+	t.Output = output
+	return t
+}
+
+func putDumpTask(t *dumpTask) {
+	if cap(t.Data) > maxPooledTaskSize {
+		return
+	}
+	t.Data = t.Data[:0]
+	t.Output = nil
+	dumpTaskPool.Put(t)
 }
 
 // NewDumper create a new Dumper.
 func NewDumper(opt Options) *Dumper {
 	d := &Dumper{
 		Options: opt,
-		ch:      make(chan *dumpTask, 20),
+		ch:      make(chan *dumpTask, opt.AsyncQueueSize()),
 	}
 	return d
 }
@@ -146,7 +248,7 @@ func (d *Dumper) Clone() *Dumper {
 	}
 	return &Dumper{
 		Options: d.Options.Clone(),
-		ch:      make(chan *dumpTask, 20),
+		ch:      make(chan *dumpTask, d.AsyncQueueSize()),
 	}
 }
 
@@ -155,44 +257,108 @@ func (d *Dumper) DumpTo(p []byte, output io.Writer) {
 		return
 	}
 	if d.Async() {
-		b := make([]byte, len(p))
-		copy(b, p)
-		d.ch <- &dumpTask{Data: b, Output: output}
+		d.enqueue(newDumpTask(p, output))
 		return
 	}
 	output.Write(p)
 }
 
+// enqueue queues the async dump task, applies the overflow policy if the
+// queue is full.
+func (d *Dumper) enqueue(t *dumpTask) {
+	policy := d.AsyncOverflow()
+	if policy == OverflowBlock {
+		d.ch <- t
+		return
+	}
+	select {
+	case d.ch <- t:
+		return
+	default:
+	}
+	if policy == OverflowSample {
+		if n := uint64(d.AsyncSampleRate()); n <= 1 || (d.overflowed.Add(1)-1)%n == 0 {
+			d.ch <- t
+			return
+		}
+	}
+	d.dropped.Add(1)
+	putDumpTask(t)
+}
+
+// Dropped returns the number of async dumps dropped by the overflow policy.
+func (d *Dumper) Dropped() uint64 {
+	return d.dropped.Load()
+}
+
 func (d *Dumper) DumpDefault(p []byte) {
 	d.DumpTo(p, d.Output())
 }
 
 func (d *Dumper) DumpRequestHeader(p []byte) {
-	d.DumpTo(p, d.RequestHeaderOutput())
+	d.DumpTo(d.redactHeader(p), d.RequestHeaderOutput())
 }
 
 func (d *Dumper) DumpRequestBody(p []byte) {
-	d.DumpTo(p, d.RequestBodyOutput())
+	d.DumpTo(d.RedactBody(true, p), d.RequestBodyOutput())
 }
 
 func (d *Dumper) DumpResponseHeader(p []byte) {
-	d.DumpTo(p, d.ResponseHeaderOutput())
+	d.DumpTo(d.redactHeader(p), d.ResponseHeaderOutput())
 }
 
 func (d *Dumper) DumpResponseBody(p []byte) {
-	d.DumpTo(p, d.ResponseBodyOutput())
+	d.DumpTo(d.RedactBody(false, p), d.ResponseBodyOutput())
+}
+
+// RedactedValue is the placeholder of redacted header value.
+const RedactedValue = "[REDACTED]"
+
+// redactHeader replaces the value of header line p with RedactedValue
+// if the header should be redacted, header is always dumped line by line.
+func (d *Dumper) redactHeader(p []byte) []byte {
+	i := bytes.IndexByte(p, ':')
+	if i <= 0 || !d.RedactHeader(string(bytes.TrimSpace(p[:i]))) {
+		return p
+	}
+	eol := len(bytes.TrimRight(p, "\r\n"))
+	b := make([]byte, 0, i+len(RedactedValue)+2+len(p)-eol)
+	b = append(b, p[:i]...)
+	b = append(b, ": "+RedactedValue...)
+	return append(b, p[eol:]...)
 }
 
 func (d *Dumper) Stop() {
 	d.ch <- nil
 }
 
+// Flush waits until the queued async dumps are written, or ctx is done.
+func (d *Dumper) Flush(ctx context.Context) error {
+	done := make(chan struct{})
+	select {
+	case d.ch <- &dumpTask{done: done}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 func (d *Dumper) Start() {
 	for t := range d.ch {
 		if t == nil {
 			return
 		}
+		if t.done != nil {
+			close(t.done)
+			continue
+		}
 		t.Output.Write(t.Data)
+		putDumpTask(t)
 	}
 }
 
@@ -0,0 +1,149 @@
+package dump
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// RotateFile is an io.WriteCloser which writes to the file, and rotates
+// it when its size exceeds MaxSize, the rotated files are renamed with
+// the suffix ".1", ".2" and so on (".1" is the newest), and only MaxFiles
+// of them are kept.
+type RotateFile struct {
+	Filename string
+	MaxSize  int64
+	MaxFiles int
+	Compress bool
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// NewRotateFile opens the file to append, and creates it if not exists.
+func NewRotateFile(filename string, maxSize int64, maxFiles int, compress bool) (*RotateFile, error) {
+	f := &RotateFile{
+		Filename: filename,
+		MaxSize:  maxSize,
+		MaxFiles: maxFiles,
+		Compress: compress,
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *RotateFile) open() error {
+	file, err := os.OpenFile(f.Filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file = file
+	f.size = info.Size()
+	return nil
+}
+
+// Write implements io.Writer.
+func (f *RotateFile) Write(p []byte) (n int, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+	if f.MaxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.MaxSize {
+		// keep writing to the current file if only the rotation fails.
+		if err = f.rotate(); err != nil && f.file == nil {
+			return
+		}
+	}
+	n, err = f.file.Write(p)
+	f.size += int64(n)
+	return
+}
+
+// Close implements io.Closer.
+func (f *RotateFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+func (f *RotateFile) rotatedName(i int) string {
+	name := fmt.Sprintf("%s.%d", f.Filename, i)
+	if f.Compress {
+		name += ".gz"
+	}
+	return name
+}
+
+// rotate rotates the file, the file is always reopened even if the
+// rotation fails, so the dump goes on with the current file and the
+// rotation is tried again on the next write.
+func (f *RotateFile) rotate() error {
+	err := f.file.Close()
+	f.file = nil
+	if err == nil {
+		err = f.shift()
+	}
+	if openErr := f.open(); openErr != nil {
+		return errors.Join(err, openErr)
+	}
+	return err
+}
+
+// shift renames the closed file to the rotated one, and removes the
+// oldest one.
+func (f *RotateFile) shift() error {
+	if f.MaxFiles <= 0 {
+		return os.Remove(f.Filename)
+	}
+	os.Remove(f.rotatedName(f.MaxFiles))
+	for i := f.MaxFiles - 1; i > 0; i-- {
+		os.Rename(f.rotatedName(i), f.rotatedName(i+1))
+	}
+	if f.Compress {
+		return compressFile(f.Filename, f.rotatedName(1))
+	}
+	return os.Rename(f.Filename, f.rotatedName(1))
+}
+
+// compressFile compresses src to dst with gzip, and removes src.
+func compressFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(out)
+	if _, err = io.Copy(zw, in); err == nil {
+		err = zw.Close()
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(dst)
+		return err
+	}
+	in.Close()
+	return os.Remove(src)
+}
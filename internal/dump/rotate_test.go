@@ -0,0 +1,38 @@
+package dump
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/luoxk/restys/internal/tests"
+)
+
+func TestRotateFileFailure(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "dump.log")
+	// the rotated file can't be renamed to a non-empty directory.
+	tests.AssertNoError(t, os.MkdirAll(filepath.Join(filename+".1", "busy"), 0755))
+	f, err := NewRotateFile(filename, 8, 1, false)
+	tests.AssertNoError(t, err)
+	defer f.Close()
+
+	for _, s := range []string{"12345678", "abcd", "efgh"} {
+		n, err := f.Write([]byte(s))
+		tests.AssertNoError(t, err)
+		tests.AssertEqual(t, len(s), n)
+	}
+	b, err := os.ReadFile(filename)
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, "12345678abcdefgh", string(b))
+
+	// rotated once the target is writable.
+	tests.AssertNoError(t, os.RemoveAll(filename+".1"))
+	_, err = f.Write([]byte("next"))
+	tests.AssertNoError(t, err)
+	b, err = os.ReadFile(filename + ".1")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, "12345678abcdefgh", string(b))
+	b, err = os.ReadFile(filename)
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, "next", string(b))
+}
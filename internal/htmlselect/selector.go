@@ -0,0 +1,362 @@
+// Package htmlselect implements a subset of CSS selectors for querying
+// the nodes of golang.org/x/net/html documents.
+//
+// Supported syntax: type (`div`), universal (`*`), id (`#main`), class
+// (`.item`), attribute (`[href]`, `[a=v]`, `[a~=v]`, `[a^=v]`, `[a$=v]`,
+// `[a*=v]`), `:first-child`, `:last-child`, descendant (` `) and child
+// (`>`) combinators, and selector groups (`a, b`).
+package htmlselect
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Selector is a compiled CSS selector group.
+type Selector []complexSelector
+
+// complexSelector is compound selectors joined by combinators, the
+// combinator at index i joins compounds[i-1] and compounds[i].
+type complexSelector struct {
+	compounds   []compound
+	combinators []byte
+}
+
+type compound struct {
+	tag      string
+	id       string
+	classes  []string
+	attrs    []attrSelector
+	pseudoes []string
+}
+
+type attrSelector struct {
+	key, op, val string
+}
+
+// Compile parses the selector.
+func Compile(sel string) (Selector, error) {
+	p := &parser{s: sel}
+	s, err := p.parseGroup()
+	if err != nil {
+		return nil, fmt.Errorf("htmlselect: invalid selector %q: %w", sel, err)
+	}
+	return s, nil
+}
+
+// Match reports whether the element node n matches the selector.
+func (s Selector) Match(n *html.Node) bool {
+	if n == nil || n.Type != html.ElementNode {
+		return false
+	}
+	for _, c := range s {
+		if c.match(n, len(c.compounds)-1) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchAll returns all descendant elements of root matching the
+// selector, in document order.
+func (s Selector) MatchAll(root *html.Node) []*html.Node {
+	var result []*html.Node
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if s.Match(c) {
+				result = append(result, c)
+			}
+			walk(c)
+		}
+	}
+	walk(root)
+	return result
+}
+
+// MatchFirst returns the first descendant element of root matching the
+// selector, or nil if not found.
+func (s Selector) MatchFirst(root *html.Node) *html.Node {
+	for c := root.FirstChild; c != nil; c = c.NextSibling {
+		if s.Match(c) {
+			return c
+		}
+		if n := s.MatchFirst(c); n != nil {
+			return n
+		}
+	}
+	return nil
+}
+
+func (c complexSelector) match(n *html.Node, i int) bool {
+	if !c.compounds[i].match(n) {
+		return false
+	}
+	if i == 0 {
+		return true
+	}
+	switch c.combinators[i] {
+	case '>':
+		p := n.Parent
+		return p != nil && p.Type == html.ElementNode && c.match(p, i-1)
+	default:
+		for p := n.Parent; p != nil && p.Type == html.ElementNode; p = p.Parent {
+			if c.match(p, i-1) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func (c *compound) match(n *html.Node) bool {
+	if c.tag != "" && c.tag != "*" && c.tag != n.Data {
+		return false
+	}
+	if c.id != "" && attr(n, "id") != c.id {
+		return false
+	}
+	if len(c.classes) > 0 {
+		classes := strings.Fields(attr(n, "class"))
+		for _, want := range c.classes {
+			if !contains(classes, want) {
+				return false
+			}
+		}
+	}
+	for _, a := range c.attrs {
+		if !a.match(n) {
+			return false
+		}
+	}
+	for _, p := range c.pseudoes {
+		switch p {
+		case "first-child":
+			for s := n.PrevSibling; s != nil; s = s.PrevSibling {
+				if s.Type == html.ElementNode {
+					return false
+				}
+			}
+		case "last-child":
+			for s := n.NextSibling; s != nil; s = s.NextSibling {
+				if s.Type == html.ElementNode {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+func (a *attrSelector) match(n *html.Node) bool {
+	for _, attr := range n.Attr {
+		if attr.Namespace != "" || attr.Key != a.key {
+			continue
+		}
+		v := attr.Val
+		switch a.op {
+		case "":
+			return true
+		case "=":
+			return v == a.val
+		case "~=":
+			return contains(strings.Fields(v), a.val)
+		case "^=":
+			return a.val != "" && strings.HasPrefix(v, a.val)
+		case "$=":
+			return a.val != "" && strings.HasSuffix(v, a.val)
+		case "*=":
+			return a.val != "" && strings.Contains(v, a.val)
+		}
+		return false
+	}
+	return false
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Namespace == "" && a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func contains(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+type parser struct {
+	s   string
+	pos int
+}
+
+func (p *parser) skipSpace() bool {
+	start := p.pos
+	for p.pos < len(p.s) && isSpace(p.s[p.pos]) {
+		p.pos++
+	}
+	return p.pos > start
+}
+
+func (p *parser) parseGroup() (Selector, error) {
+	var s Selector
+	for {
+		c, err := p.parseComplex()
+		if err != nil {
+			return nil, err
+		}
+		s = append(s, c)
+		if p.pos >= len(p.s) {
+			return s, nil
+		}
+		if p.s[p.pos] != ',' {
+			return nil, fmt.Errorf("unexpected %q at %d", p.s[p.pos], p.pos)
+		}
+		p.pos++
+	}
+}
+
+func (p *parser) parseComplex() (c complexSelector, err error) {
+	p.skipSpace()
+	combinator := byte(0)
+	for {
+		cp, err := p.parseCompound()
+		if err != nil {
+			return c, err
+		}
+		c.compounds = append(c.compounds, cp)
+		c.combinators = append(c.combinators, combinator)
+
+		space := p.skipSpace()
+		if p.pos >= len(p.s) || p.s[p.pos] == ',' {
+			return c, nil
+		}
+		if p.s[p.pos] == '>' {
+			combinator = '>'
+			p.pos++
+			p.skipSpace()
+		} else if space {
+			combinator = ' '
+		} else {
+			return c, fmt.Errorf("unexpected %q at %d", p.s[p.pos], p.pos)
+		}
+	}
+}
+
+func (p *parser) parseCompound() (c compound, err error) {
+	start := p.pos
+	if p.pos < len(p.s) && p.s[p.pos] == '*' {
+		c.tag = "*"
+		p.pos++
+	} else if ident := p.parseIdent(); ident != "" {
+		c.tag = strings.ToLower(ident)
+	}
+	for p.pos < len(p.s) {
+		switch p.s[p.pos] {
+		case '#':
+			p.pos++
+			if c.id = p.parseIdent(); c.id == "" {
+				return c, fmt.Errorf("expected id at %d", p.pos)
+			}
+		case '.':
+			p.pos++
+			class := p.parseIdent()
+			if class == "" {
+				return c, fmt.Errorf("expected class at %d", p.pos)
+			}
+			c.classes = append(c.classes, class)
+		case '[':
+			p.pos++
+			a, err := p.parseAttr()
+			if err != nil {
+				return c, err
+			}
+			c.attrs = append(c.attrs, a)
+		case ':':
+			p.pos++
+			pseudo := strings.ToLower(p.parseIdent())
+			if pseudo != "first-child" && pseudo != "last-child" {
+				return c, fmt.Errorf("unsupported pseudo-class %q", pseudo)
+			}
+			c.pseudoes = append(c.pseudoes, pseudo)
+		default:
+			if p.pos == start {
+				return c, fmt.Errorf("expected selector at %d", p.pos)
+			}
+			return c, nil
+		}
+	}
+	if p.pos == start {
+		return c, fmt.Errorf("expected selector at %d", p.pos)
+	}
+	return c, nil
+}
+
+func (p *parser) parseAttr() (a attrSelector, err error) {
+	p.skipSpace()
+	if a.key = strings.ToLower(p.parseIdent()); a.key == "" {
+		return a, fmt.Errorf("expected attribute name at %d", p.pos)
+	}
+	p.skipSpace()
+	if p.pos >= len(p.s) {
+		return a, fmt.Errorf("unclosed attribute selector")
+	}
+	if p.s[p.pos] == ']' {
+		p.pos++
+		return a, nil
+	}
+	for _, op := range []string{"=", "~=", "^=", "$=", "*="} {
+		if strings.HasPrefix(p.s[p.pos:], op) {
+			a.op = op
+			p.pos += len(op)
+			break
+		}
+	}
+	if a.op == "" {
+		return a, fmt.Errorf("unexpected %q at %d", p.s[p.pos], p.pos)
+	}
+	p.skipSpace()
+	if p.pos < len(p.s) && (p.s[p.pos] == '"' || p.s[p.pos] == '\'') {
+		quote := p.s[p.pos]
+		end := strings.IndexByte(p.s[p.pos+1:], quote)
+		if end < 0 {
+			return a, fmt.Errorf("unclosed quote at %d", p.pos)
+		}
+		a.val = p.s[p.pos+1 : p.pos+1+end]
+		p.pos += end + 2
+	} else {
+		a.val = p.parseIdent()
+	}
+	p.skipSpace()
+	if p.pos >= len(p.s) || p.s[p.pos] != ']' {
+		return a, fmt.Errorf("unclosed attribute selector")
+	}
+	p.pos++
+	return a, nil
+}
+
+func (p *parser) parseIdent() string {
+	start := p.pos
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		if c == '-' || c == '_' || c >= 0x80 ||
+			'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' {
+			p.pos++
+			continue
+		}
+		break
+	}
+	return p.s[start:p.pos]
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
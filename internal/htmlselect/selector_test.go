@@ -0,0 +1,74 @@
+package htmlselect
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/luoxk/restys/internal/tests"
+	"golang.org/x/net/html"
+)
+
+const testHTML = `<html><body>
+<div id="main" class="box content">
+  <ul>
+    <li class="item"><a href="https://example.com/a">A</a></li>
+    <li class="item active"><a href="/b" data-kind="x y">B</a></li>
+    <li class="item"><span><a href="/c.pdf">C</a></span></li>
+  </ul>
+</div>
+<p>outside <a href="/d">D</a></p>
+</body></html>`
+
+func text(nodes []*html.Node) string {
+	var ss []string
+	for _, n := range nodes {
+		var sb strings.Builder
+		var f func(*html.Node)
+		f = func(n *html.Node) {
+			if n.Type == html.TextNode {
+				sb.WriteString(n.Data)
+			}
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				f(c)
+			}
+		}
+		f(n)
+		ss = append(ss, sb.String())
+	}
+	return strings.Join(ss, ",")
+}
+
+func TestSelector(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(testHTML))
+	tests.AssertNoError(t, err)
+	cases := []struct {
+		sel, want string
+	}{
+		{"a", "A,B,C,D"},
+		{"#main a", "A,B,C"},
+		{"li > a", "A,B"},
+		{"div.box.content li.active a", "B"},
+		{"a[href^=https]", "A"},
+		{`a[href$=".pdf"]`, "C"},
+		{"a[href*=d]", "C,D"},
+		{"a[data-kind~=y]", "B"},
+		{"[data-kind]", "B"},
+		{"li:first-child, li:last-child", "A,C"},
+		{"p > *", "D"},
+		{"table", ""},
+	}
+	for _, c := range cases {
+		s, err := Compile(c.sel)
+		tests.AssertNoError(t, err)
+		tests.AssertEqual(t, c.want, text(s.MatchAll(doc)))
+	}
+
+	s, err := Compile("li.item a")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, "A", text([]*html.Node{s.MatchFirst(doc)}))
+
+	for _, sel := range []string{"", "a >", "div,", "[href", "a:hover", "a!b"} {
+		_, err = Compile(sel)
+		tests.AssertErrorContains(t, err, "invalid selector")
+	}
+}
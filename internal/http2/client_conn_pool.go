@@ -10,6 +10,9 @@ import (
 	"net"
 	"net/http"
 	"sync"
+	"sync/atomic"
+
+	"github.com/luoxk/restys/internal/netutil"
 )
 
 // ClientConnPool manages a pool of HTTP/2 client connections.
@@ -39,11 +42,23 @@ type ClientConnPool2 interface {
 	AddConnIfNeeded(key string, t *Http2Transport, c net.Conn) (used bool, err error)
 }
 
+// connPoolShards is the number of shards of clientConnPool, the hosts are
+// spread across the shards by the hash of key, so the requests to
+// different hosts rarely contend for the same lock.
+const connPoolShards = 32
+
 // TODO: use singleflight for dialing and addConnCalls?
 type clientConnPool struct {
 	t *Transport
 
-	mu sync.Mutex // TODO: maybe switch to RWMutex
+	shards [connPoolShards]connPoolShard
+	stats  connPoolStats
+}
+
+// connPoolShard is a shard of clientConnPool, which holds the conns of
+// the keys hashed to it.
+type connPoolShard struct {
+	mu sync.Mutex
 	// TODO: add support for sharing conns based on cert names
 	// (e.g. share conn for googleapis.com and appspot.com)
 	conns        map[string][]*ClientConn // key is host:port
@@ -52,21 +67,125 @@ type clientConnPool struct {
 	addConnCalls map[string]*addConnCall // in-flight addConnIfNeeded calls
 }
 
+// ConnPoolStats is the metrics of the default connection pool.
+type ConnPoolStats struct {
+	// Dials is the number of connections dialed by the pool.
+	Dials uint64
+	// CoalescedDials is the number of requests which waited for the
+	// in-flight dial to the same host instead of dialing a new one, and
+	// the connections from the HTTP/1 transport which are coalesced into
+	// the in-flight AddConnIfNeeded call.
+	CoalescedDials uint64
+	// ReservationMisses is the number of requests which found the pooled
+	// connections to the host but none of them could take a new request.
+	ReservationMisses uint64
+	// LockContentions is the number of times the shard lock was held by
+	// others when acquiring it.
+	LockContentions uint64
+}
+
+type connPoolStats struct {
+	dials             atomic.Uint64
+	coalescedDials    atomic.Uint64
+	reservationMisses atomic.Uint64
+	lockContentions   atomic.Uint64
+}
+
+// ConnPoolStats returns the metrics of the default connection pool, it's
+// empty if the custom ConnPool is used.
+func (t *Transport) ConnPoolStats() ConnPoolStats {
+	p, ok := t.connPool().(*clientConnPool)
+	if !ok {
+		return ConnPoolStats{}
+	}
+	return ConnPoolStats{
+		Dials:             p.stats.dials.Load(),
+		CoalescedDials:    p.stats.coalescedDials.Load(),
+		ReservationMisses: p.stats.reservationMisses.Load(),
+		LockContentions:   p.stats.lockContentions.Load(),
+	}
+}
+
+// HasConn reports whether the default connection pool has a connection to
+// addr which can take new requests, it's false if the custom ConnPool is
+// used.
+func (t *Transport) HasConn(addr string) bool {
+	p, ok := t.connPool().(*clientConnPool)
+	if !ok {
+		return false
+	}
+	s := p.shard(addr)
+	p.lock(s)
+	defer s.mu.Unlock()
+	for _, cc := range s.conns[addr] {
+		if cc.CanTakeNewRequest() {
+			return true
+		}
+	}
+	return false
+}
+
+// IdleConns returns the number of the idle connections per addr
+// ("host:port") in the default connection pool, it's empty if the custom
+// ConnPool is used.
+func (t *Transport) IdleConns() map[string]int {
+	p, ok := t.connPool().(*clientConnPool)
+	if !ok {
+		return nil
+	}
+	idle := make(map[string]int)
+	for i := range p.shards {
+		s := &p.shards[i]
+		p.lock(s)
+		for cc, keys := range s.keys {
+			if len(keys) == 0 || !cc.isIdle() {
+				continue
+			}
+			addr, _ := splitConnKey(keys[0])
+			addr, _ = netutil.SplitProxyConnKey(addr)
+			addr, _ = netutil.SplitConnKey(addr)
+			idle[addr]++
+		}
+		s.mu.Unlock()
+	}
+	return idle
+}
+
+// shard returns the shard of key with FNV-1a hash.
+func (p *clientConnPool) shard(key string) *connPoolShard {
+	h := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= 16777619
+	}
+	return &p.shards[h%connPoolShards]
+}
+
+// lock locks the shard, and counts the contention.
+func (p *clientConnPool) lock(s *connPoolShard) {
+	if !s.mu.TryLock() {
+		p.stats.lockContentions.Add(1)
+		s.mu.Lock()
+	}
+}
+
 func (p *clientConnPool) GetClientConn(req *http.Request, addr string, dialOnMiss bool) (*ClientConn, error) {
 	// TODO(dneil): Dial a new connection when t.DisableKeepAlives is set?
 	if isConnectionCloseRequest(req) && dialOnMiss {
 		// It gets its own connection.
 		traceGetConn(req, addr)
 		const singleUse = true
+		p.stats.dials.Add(1)
 		cc, err := p.t.dialClientConn(req.Context(), addr, singleUse)
 		if err != nil {
 			return nil, err
 		}
 		return cc, nil
 	}
+	s := p.shard(addr)
 	for {
-		p.mu.Lock()
-		for _, cc := range p.conns[addr] {
+		p.lock(s)
+		for _, cc := range s.conns[addr] {
 			if cc.ReserveNewRequest() {
 				// When a connection is presented to us by the net/http package,
 				// the GetConn hook has already been called.
@@ -75,17 +194,20 @@ func (p *clientConnPool) GetClientConn(req *http.Request, addr string, dialOnMis
 					traceGetConn(req, addr)
 				}
 				cc.getConnCalled = false
-				p.mu.Unlock()
+				s.mu.Unlock()
 				return cc, nil
 			}
 		}
+		if len(s.conns[addr]) > 0 {
+			p.stats.reservationMisses.Add(1)
+		}
 		if !dialOnMiss {
-			p.mu.Unlock()
+			s.mu.Unlock()
 			return nil, ErrNoCachedConn
 		}
 		traceGetConn(req, addr)
-		call := p.getStartDialLocked(req.Context(), addr)
-		p.mu.Unlock()
+		call := p.getStartDialLocked(s, req.Context(), addr)
+		s.mu.Unlock()
 		<-call.done
 		if shouldRetryDial(call, req) {
 			continue
@@ -102,17 +224,19 @@ func (p *clientConnPool) GetClientConn(req *http.Request, addr string, dialOnMis
 
 // dialCall is an in-flight Transport dial call to a host.
 
-// requires p.mu is held.
-func (p *clientConnPool) getStartDialLocked(ctx context.Context, addr string) *dialCall {
-	if call, ok := p.dialing[addr]; ok {
+// requires s.mu is held.
+func (p *clientConnPool) getStartDialLocked(s *connPoolShard, ctx context.Context, addr string) *dialCall {
+	if call, ok := s.dialing[addr]; ok {
 		// A dial is already in-flight. Don't start another.
+		p.stats.coalescedDials.Add(1)
 		return call
 	}
-	call := &dialCall{p: p, done: make(chan struct{}), ctx: ctx}
-	if p.dialing == nil {
-		p.dialing = make(map[string]*dialCall)
+	call := &dialCall{p: p, s: s, done: make(chan struct{}), ctx: ctx}
+	if s.dialing == nil {
+		s.dialing = make(map[string]*dialCall)
 	}
-	p.dialing[addr] = call
+	s.dialing[addr] = call
+	p.stats.dials.Add(1)
 	go call.dial(call.ctx, addr)
 	return call
 }
@@ -120,6 +244,7 @@ func (p *clientConnPool) getStartDialLocked(ctx context.Context, addr string) *d
 type dialCall struct {
 	_ incomparable
 	p *clientConnPool
+	s *connPoolShard
 	// the context associated with the request
 	// that created this dialCall
 	ctx  context.Context
@@ -133,12 +258,12 @@ func (c *dialCall) dial(ctx context.Context, addr string) {
 	const singleUse = false // shared conn
 	c.res, c.err = c.p.t.dialClientConn(ctx, addr, singleUse)
 
-	c.p.mu.Lock()
-	delete(c.p.dialing, addr)
+	c.p.lock(c.s)
+	delete(c.s.dialing, addr)
 	if c.err == nil {
-		c.p.addConnLocked(addr, c.res)
+		c.s.addConnLocked(addr, c.res)
 	}
-	c.p.mu.Unlock()
+	c.s.mu.Unlock()
 
 	close(c.done)
 }
@@ -152,26 +277,30 @@ func (c *dialCall) dial(ctx context.Context, addr string) {
 // The return value used is whether c was used.
 // c is never closed.
 func (p *clientConnPool) AddConnIfNeeded(key string, t *Transport, c net.Conn) (used bool, err error) {
-	p.mu.Lock()
-	for _, cc := range p.conns[key] {
+	s := p.shard(key)
+	p.lock(s)
+	for _, cc := range s.conns[key] {
 		if cc.CanTakeNewRequest() {
-			p.mu.Unlock()
+			s.mu.Unlock()
 			return false, nil
 		}
 	}
-	call, dup := p.addConnCalls[key]
+	call, dup := s.addConnCalls[key]
 	if !dup {
-		if p.addConnCalls == nil {
-			p.addConnCalls = make(map[string]*addConnCall)
+		if s.addConnCalls == nil {
+			s.addConnCalls = make(map[string]*addConnCall)
 		}
 		call = &addConnCall{
 			p:    p,
+			s:    s,
 			done: make(chan struct{}),
 		}
-		p.addConnCalls[key] = call
+		s.addConnCalls[key] = call
 		go call.run(t, key, c)
+	} else {
+		p.stats.coalescedDials.Add(1)
 	}
-	p.mu.Unlock()
+	s.mu.Unlock()
 
 	<-call.done
 	if call.err != nil {
@@ -183,74 +312,85 @@ func (p *clientConnPool) AddConnIfNeeded(key string, t *Transport, c net.Conn) (
 type addConnCall struct {
 	_    incomparable
 	p    *clientConnPool
+	s    *connPoolShard
 	done chan struct{} // closed when done
 	err  error
 }
 
 func (c *addConnCall) run(t *Transport, key string, tc net.Conn) {
-	cc, err := t.NewClientConn(tc)
+	_, settings := splitConnKey(key)
+	cc, err := t.newClientConn(tc, t.DisableKeepAlives, settings)
 
-	p := c.p
-	p.mu.Lock()
+	s := c.s
+	c.p.lock(s)
 	if err != nil {
 		c.err = err
 	} else {
 		cc.getConnCalled = true // already called by the net/http package
-		p.addConnLocked(key, cc)
+		s.addConnLocked(key, cc)
 	}
-	delete(p.addConnCalls, key)
-	p.mu.Unlock()
+	delete(s.addConnCalls, key)
+	s.mu.Unlock()
 	close(c.done)
 }
 
-// p.mu must be held
-func (p *clientConnPool) addConnLocked(key string, cc *ClientConn) {
-	for _, v := range p.conns[key] {
+// s.mu must be held
+func (s *connPoolShard) addConnLocked(key string, cc *ClientConn) {
+	for _, v := range s.conns[key] {
 		if v == cc {
 			return
 		}
 	}
-	if p.conns == nil {
-		p.conns = make(map[string][]*ClientConn)
+	if s.conns == nil {
+		s.conns = make(map[string][]*ClientConn)
 	}
-	if p.keys == nil {
-		p.keys = make(map[*ClientConn][]string)
+	if s.keys == nil {
+		s.keys = make(map[*ClientConn][]string)
 	}
-	p.conns[key] = append(p.conns[key], cc)
-	p.keys[cc] = append(p.keys[cc], key)
+	s.conns[key] = append(s.conns[key], cc)
+	s.keys[cc] = append(s.keys[cc], key)
+	cc.poolShard.Store(s)
 }
 
 func (p *clientConnPool) MarkDead(cc *ClientConn) {
-	p.mu.Lock()
-	defer p.mu.Unlock()
-	for _, key := range p.keys[cc] {
-		vv, ok := p.conns[key]
+	// only the shard which cc was added to holds its keys.
+	s := cc.poolShard.Load()
+	if s == nil {
+		return
+	}
+	p.lock(s)
+	defer s.mu.Unlock()
+	for _, key := range s.keys[cc] {
+		vv, ok := s.conns[key]
 		if !ok {
 			continue
 		}
 		newList := filterOutClientConn(vv, cc)
 		if len(newList) > 0 {
-			p.conns[key] = newList
+			s.conns[key] = newList
 		} else {
-			delete(p.conns, key)
+			delete(s.conns, key)
 		}
 	}
-	delete(p.keys, cc)
+	delete(s.keys, cc)
 }
 
 func (p *clientConnPool) CloseIdleConnections() {
-	p.mu.Lock()
-	defer p.mu.Unlock()
 	// TODO: don't close a cc if it was just added to the pool
 	// milliseconds ago and has never been used. There's currently
 	// a small race window with the HTTP/1 Transport's integration
 	// where it can add an idle conn just before using it, and
 	// somebody else can concurrently call CloseIdleConns and
 	// break some caller's RoundTrip.
-	for _, vv := range p.conns {
-		for _, cc := range vv {
-			cc.closeIfIdle()
+	for i := range p.shards {
+		s := &p.shards[i]
+		p.lock(s)
+		for _, vv := range s.conns {
+			for _, cc := range vv {
+				cc.closeIfIdle()
+			}
 		}
+		s.mu.Unlock()
 	}
 }
 
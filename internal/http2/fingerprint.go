@@ -0,0 +1,82 @@
+package http2
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/luoxk/restys/http2"
+)
+
+// FingerprintRecorder records the akamai fingerprint actually sent by the
+// connection and the HEADERS frame of the request, it's safe for concurrent
+// use.
+type FingerprintRecorder struct {
+	mu          sync.Mutex
+	fingerprint string
+}
+
+// Fingerprint returns the recorded akamai fingerprint, e.g.
+// "1:65536;2:0;4:6291456;6:262144|15663105|0|m,a,s,p", or "" if the request
+// was not sent over http2.
+func (r *FingerprintRecorder) Fingerprint() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.fingerprint
+}
+
+func (r *FingerprintRecorder) record(fingerprint string) {
+	r.mu.Lock()
+	r.fingerprint = fingerprint
+	r.mu.Unlock()
+}
+
+type fingerprintRecorderKey struct{}
+
+// WithFingerprintRecorder returns a copy of ctx which carries r, the akamai
+// fingerprint is recorded to r once the HEADERS frame of the request is
+// written.
+func WithFingerprintRecorder(ctx context.Context, r *FingerprintRecorder) context.Context {
+	return context.WithValue(ctx, fingerprintRecorderKey{}, r)
+}
+
+func fingerprintRecorderFromContext(ctx context.Context) *FingerprintRecorder {
+	r, _ := ctx.Value(fingerprintRecorderKey{}).(*FingerprintRecorder)
+	return r
+}
+
+// connFingerprint returns the "settings|window|priority" part of the akamai
+// fingerprint of the connection preface.
+func connFingerprint(settings []http2.Setting, connFlow uint32, frames []http2.PriorityFrame) string {
+	var b strings.Builder
+	for i, s := range settings {
+		if i > 0 {
+			b.WriteByte(';')
+		}
+		b.WriteString(strconv.FormatUint(uint64(s.ID), 10))
+		b.WriteByte(':')
+		b.WriteString(strconv.FormatUint(uint64(s.Val), 10))
+	}
+	b.WriteByte('|')
+	b.WriteString(strconv.FormatUint(uint64(connFlow), 10))
+	b.WriteByte('|')
+	if len(frames) == 0 {
+		b.WriteByte('0')
+	}
+	for i, f := range frames {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		exclusive := 0
+		if f.PriorityParam.Exclusive {
+			exclusive = 1
+		}
+		// streamID:exclusive:streamDep:weight, the weight is 1-256.
+		b.WriteString(strconv.FormatUint(uint64(f.StreamID), 10) + ":" +
+			strconv.Itoa(exclusive) + ":" +
+			strconv.FormatUint(uint64(f.PriorityParam.StreamDep), 10) + ":" +
+			strconv.Itoa(int(f.PriorityParam.Weight)+1))
+	}
+	return b.String()
+}
@@ -334,6 +334,11 @@ type Framer struct {
 	debugReadLoggerf  func(string, ...interface{})
 	debugWriteLoggerf func(string, ...interface{})
 
+	dumpFramer      *Framer // only use for dumping written frames
+	dumpFramerBuf   *bytes.Buffer
+	dumpHdec        *hpack.Decoder
+	dumpHeaderBlock []byte
+
 	frameCache *frameCache // nil if frames aren't reused (default)
 }
 
@@ -372,6 +377,9 @@ func (h2f *Framer) endWrite() error {
 	if h2f.logWrites {
 		h2f.logWrite()
 	}
+	if d := h2f.frameDumper(); d != nil {
+		h2f.dumpWrittenFrame(d)
+	}
 
 	n, err := h2f.w.Write(h2f.wbuf)
 	if err == nil && n != len(h2f.wbuf) {
@@ -578,8 +586,14 @@ func (h2f *Framer) ReadFrame() (Frame, error) {
 				dump.DumpResponseHeader([]byte("\r\n"))
 			}
 		}
+		if d := h2f.frameDumper(); d != nil && err == nil {
+			h2f.dumpReadFrame(d, hr)
+		}
 		return hr, err
 	}
+	if d := h2f.frameDumper(); d != nil {
+		h2f.dumpReadFrame(d, f)
+	}
 	return f, nil
 }
 
@@ -1481,6 +1495,10 @@ type MetaHeadersFrame struct {
 	// and Fields is incomplete. The hpack decoder state is still
 	// valid, however.
 	Truncated bool
+
+	// blockLen is the length of the HPACK encoded header block in
+	// the HEADERS and CONTINUATION frames, used by the frame dump.
+	blockLen int
 }
 
 // PseudoValue returns the given pseudo header field's value.
@@ -1658,6 +1676,7 @@ func (h2f *Framer) readMetaFrame(hf *HeadersFrame, dumps []*dump.Dumper) (Frame,
 		if _, err := hdec.Write(frag); err != nil {
 			return mh, ConnectionError(ErrCodeCompression)
 		}
+		mh.blockLen += len(frag)
 
 		if hc.HeadersEnded() {
 			break
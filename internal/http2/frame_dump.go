@@ -0,0 +1,117 @@
+package http2
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/luoxk/restys/http2"
+	"github.com/luoxk/restys/internal/dump"
+	"golang.org/x/net/http2/hpack"
+)
+
+// frameDumper returns the dumper which dumps the frames, only the
+// client-level dumper is used since frames belong to the connection.
+func (h2f *Framer) frameDumper() *dump.Dumper {
+	if h2f.cc == nil || h2f.cc.t == nil {
+		return nil
+	}
+	if d := h2f.cc.t.Dump; d != nil && d.HTTP2Frames() {
+		return d
+	}
+	return nil
+}
+
+// dumpFrame dumps the frame with direction prefix, ">" for written frames
+// and "<" for read frames. The header fields of MetaHeadersFrame are dumped
+// after HPACK decode with the encoded size of the header block (blockLen),
+// and the data of DataFrame is omitted since the body is dumped separately.
+func dumpFrame(d *dump.Dumper, prefix string, f Frame, fields []hpack.HeaderField, blockLen int) {
+	var buf bytes.Buffer
+	buf.WriteString("[h2] " + prefix + " ")
+	switch f := f.(type) {
+	case *DataFrame:
+		f.Header().writeDebug(&buf)
+	case *HeadersFrame:
+		buf.WriteString(summarizeFrame(f))
+		if f.HasPriority() {
+			writePriority(&buf, f.Priority)
+		}
+	case *PriorityFrame:
+		buf.WriteString(summarizeFrame(f))
+		writePriority(&buf, f.PriorityParam)
+	default:
+		buf.WriteString(summarizeFrame(f))
+	}
+	if fields != nil {
+		var listSize uint32
+		for _, hf := range fields {
+			listSize += hf.Size()
+		}
+		fmt.Fprintf(&buf, ", hpack: block=%d header_list=%d", blockLen, listSize)
+	}
+	buf.WriteString("\n")
+	for _, hf := range fields {
+		if hf.Sensitive {
+			fmt.Fprintf(&buf, "[h2] %s   %s: %s (never indexed)\n", prefix, hf.Name, hf.Value)
+		} else {
+			fmt.Fprintf(&buf, "[h2] %s   %s: %s\n", prefix, hf.Name, hf.Value)
+		}
+	}
+	d.DumpDefault(buf.Bytes())
+}
+
+// writePriority writes the priority of the HEADERS or PRIORITY frame, the
+// weight is written as 1-256 like the browsers and Wireshark.
+func writePriority(buf *bytes.Buffer, p http2.PriorityParam) {
+	fmt.Fprintf(buf, ", priority: stream_dep=%d weight=%d exclusive=%t", p.StreamDep, int(p.Weight)+1, p.Exclusive)
+}
+
+// dumpReadFrame dumps the frame just read.
+func (h2f *Framer) dumpReadFrame(d *dump.Dumper, f Frame) {
+	var fields []hpack.HeaderField
+	var blockLen int
+	if mh, ok := f.(*MetaHeadersFrame); ok {
+		fields, blockLen = mh.Fields, mh.blockLen
+		f = mh.HeadersFrame
+	}
+	dumpFrame(d, "<", f, fields, blockLen)
+}
+
+// dumpWrittenFrame dumps the frame in wbuf which is going to be written,
+// the header block is decoded with a mirror HPACK decoder once it's
+// completed (END_HEADERS).
+func (h2f *Framer) dumpWrittenFrame(d *dump.Dumper) {
+	if h2f.dumpFramer == nil {
+		h2f.dumpFramerBuf = new(bytes.Buffer)
+		h2f.dumpFramer = NewFramer(nil, h2f.dumpFramerBuf)
+		h2f.dumpFramer.logReads = false
+		h2f.dumpFramer.AllowIllegalReads = true
+		h2f.dumpHdec = hpack.NewDecoder(initialHeaderTableSize, nil)
+	}
+	h2f.dumpFramerBuf.Write(h2f.wbuf)
+	f, err := h2f.dumpFramer.ReadFrame()
+	if err != nil {
+		d.DumpDefault([]byte(fmt.Sprintf("[h2] > failed to decode frame: %v\n", err)))
+		return
+	}
+	var fields []hpack.HeaderField
+	var endHeaders bool
+	var blockLen int
+	switch f := f.(type) {
+	case *HeadersFrame:
+		h2f.dumpHeaderBlock = append(h2f.dumpHeaderBlock[:0], f.HeaderBlockFragment()...)
+		endHeaders = f.HeadersEnded()
+	case *ContinuationFrame:
+		h2f.dumpHeaderBlock = append(h2f.dumpHeaderBlock, f.HeaderBlockFragment()...)
+		endHeaders = f.HeadersEnded()
+	}
+	if endHeaders {
+		blockLen = len(h2f.dumpHeaderBlock)
+		fields, err = h2f.dumpHdec.DecodeFull(h2f.dumpHeaderBlock)
+		h2f.dumpHeaderBlock = h2f.dumpHeaderBlock[:0]
+		if err != nil {
+			fields = []hpack.HeaderField{{Name: "(hpack decode error)", Value: err.Error()}}
+		}
+	}
+	dumpFrame(d, ">", f, fields, blockLen)
+}
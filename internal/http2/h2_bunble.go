@@ -7720,7 +7720,7 @@ func (t *Http2Transport) RoundTripOpt(req *http.Request, opt http2RoundTripOpt)
 // connected from previous requests but are now sitting idle.
 // It does not interrupt any connections currently in use.
 func (t *Http2Transport) CloseIdleConnections() {
-	if cp, ok := t.connPool().(http2clientConnPoolIdleCloser); ok {
+	if cp, ok := t.connPool().(interface{ closeIdleConnections() }); ok {
 		cp.closeIdleConnections()
 	}
 }
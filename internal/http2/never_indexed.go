@@ -0,0 +1,24 @@
+package http2
+
+import (
+	"context"
+	"strings"
+)
+
+type neverIndexedKey struct{}
+
+// WithNeverIndexedHeaders returns a copy of ctx which carries the names of
+// the headers (case-insensitive) encoded as the HPACK never indexed literal
+// in the HEADERS frame of the request.
+func WithNeverIndexedHeaders(ctx context.Context, keys []string) context.Context {
+	names := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		names[strings.ToLower(key)] = true
+	}
+	return context.WithValue(ctx, neverIndexedKey{}, names)
+}
+
+func neverIndexedHeadersFromContext(ctx context.Context) map[string]bool {
+	names, _ := ctx.Value(neverIndexedKey{}).(map[string]bool)
+	return names
+}
@@ -0,0 +1,92 @@
+package http2
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/luoxk/restys/http2"
+)
+
+type settingsKey struct{}
+
+// WithSettings returns a copy of ctx which carries the SETTINGS frame sent
+// by the new connections of the request, which overrides Transport.Settings.
+func WithSettings(ctx context.Context, settings []http2.Setting) context.Context {
+	return context.WithValue(ctx, settingsKey{}, settings)
+}
+
+// SettingsFromContext returns the SETTINGS frame carried by ctx, or nil if
+// not set.
+func SettingsFromContext(ctx context.Context) []http2.Setting {
+	if ctx == nil {
+		return nil
+	}
+	settings, _ := ctx.Value(settingsKey{}).([]http2.Setting)
+	return settings
+}
+
+// EncodeSettings encodes settings as "id:val,id:val", which is appended to
+// the key of the cached connections by ConnKey, so the connections with
+// different SETTINGS frames are not shared.
+func EncodeSettings(settings []http2.Setting) string {
+	if len(settings) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	for i, s := range settings {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.FormatUint(uint64(s.ID), 10))
+		b.WriteByte(':')
+		b.WriteString(strconv.FormatUint(uint64(s.Val), 10))
+	}
+	return b.String()
+}
+
+// replaceSetting returns a copy of settings in which the value of the id is
+// val, the setting is appended if it's not present.
+func replaceSetting(settings []http2.Setting, id http2.SettingID, val uint32) []http2.Setting {
+	settings = append([]http2.Setting(nil), settings...)
+	for i := range settings {
+		if settings[i].ID == id {
+			settings[i].Val = val
+			return settings
+		}
+	}
+	return append(settings, http2.Setting{ID: id, Val: val})
+}
+
+func decodeSettings(s string) []http2.Setting {
+	var settings []http2.Setting
+	for _, kv := range strings.Split(s, ",") {
+		id, val, _ := strings.Cut(kv, ":")
+		i, err1 := strconv.ParseUint(id, 10, 16)
+		v, err2 := strconv.ParseUint(val, 10, 32)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		settings = append(settings, http2.Setting{ID: http2.SettingID(i), Val: uint32(v)})
+	}
+	return settings
+}
+
+// ConnKey returns the key of the cached connections to addr (see
+// netutil.ConnKey) which send the encoded SETTINGS frame.
+func ConnKey(addr, settings string) string {
+	if settings == "" {
+		return addr
+	}
+	return addr + "|" + settings
+}
+
+// splitConnKey splits the key returned by ConnKey into addr and the SETTINGS
+// frame, settings is nil if the key has no SETTINGS frame.
+func splitConnKey(key string) (addr string, settings []http2.Setting) {
+	addr, encoded, ok := strings.Cut(key, "|")
+	if !ok {
+		return key, nil
+	}
+	return addr, decodeSettings(encoded)
+}
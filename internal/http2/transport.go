@@ -144,6 +144,9 @@ type Transport struct {
 	ConnectionFlow uint32
 	HeaderPriority http2.PriorityParam
 	PriorityFrames []http2.PriorityFrame
+	// HeaderTableSize is the SETTINGS_HEADER_TABLE_SIZE sent to the server,
+	// which overrides the one in Settings, zero means not overridden.
+	HeaderTableSize uint32
 
 	connPoolOnce  sync.Once
 	connPoolOrDef ClientConnPool // non-nil version of ConnPool
@@ -173,6 +176,35 @@ func (t *Transport) maxHeaderListSize() uint32 {
 	return t.MaxHeaderListSize
 }
 
+// idleConnTimeout returns IdleConnTimeout, or the one of the shared
+// Options if not set.
+func (t *Transport) idleConnTimeout() time.Duration {
+	if t.IdleConnTimeout == 0 && t.Options != nil {
+		return t.Options.IdleConnTimeout
+	}
+	return t.IdleConnTimeout
+}
+
+func (t *Transport) maxConnAge() time.Duration {
+	if t.Options == nil {
+		return 0
+	}
+	return t.Options.MaxConnAge
+}
+
+// strictMaxConcurrentStreams reports whether the requests wait for the
+// streams of the existing connection instead of dialing a new one, which
+// is also the case if the shared Options limits the connections per host.
+func (t *Transport) strictMaxConcurrentStreams() bool {
+	return t.StrictMaxConcurrentStreams || (t.Options != nil && t.Options.MaxConnsPerHost > 0)
+}
+
+// closeIdleConns reports whether the connection is closed once it's idle,
+// which is the case if the shared Options keeps no idle connections.
+func (t *Transport) closeIdleConns() bool {
+	return t.Options != nil && t.Options.MaxIdleConnsPerHost < 0
+}
+
 func (t *Transport) pingTimeout() time.Duration {
 	if t.PingTimeout == 0 {
 		return 15 * time.Second
@@ -202,6 +234,11 @@ type ClientConn struct {
 	reused        uint32               // whether conn is being reused; atomic
 	singleUse     bool                 // whether being used for a single http.Request
 	getConnCalled bool                 // used by clientConnPool
+	fingerprint   string               // the "settings|window|priority" of the akamai fingerprint
+
+	// poolShard is the shard of clientConnPool holding cc, so MarkDead
+	// locks only that shard.
+	poolShard atomic.Pointer[connPoolShard]
 
 	// readLoop goroutine fields:
 	readerDone chan struct{} // closed on error
@@ -209,6 +246,9 @@ type ClientConn struct {
 
 	idleTimeout time.Duration // or 0 for never
 	idleTimer   timer
+	createdAt   time.Time
+	maxAge      time.Duration // or 0 for never
+	maxAgeTimer timer
 
 	mu              sync.Mutex // guards following
 	cond            *sync.Cond // hold mu; broadcast on flow/closed changes
@@ -459,7 +499,9 @@ func (t *Transport) RoundTripOpt(req *http.Request, opt RoundTripOpt) (*http.Res
 		return nil, errors.New("http2: unsupported scheme")
 	}
 
-	addr := netutil.AuthorityAddr(req.URL.Scheme, req.URL.Host)
+	addr := netutil.ConnKey(netutil.AuthorityAddr(req.URL.Scheme, req.URL.Host), netutil.ServerName(req.Context()))
+	addr = netutil.ProxyConnKey(addr, netutil.Proxy(req.Context()))
+	addr = ConnKey(addr, EncodeSettings(SettingsFromContext(req.Context())))
 	var cc *ClientConn
 	var err error
 	if opt.OnlyCachedConn {
@@ -558,6 +600,21 @@ func shouldRetryRequest(req *http.Request, err error) (*http.Request, error) {
 	return nil, fmt.Errorf("http2: Transport: cannot retry err [%v] after Request.Body was written; define Request.GetBody to avoid this error", err)
 }
 
+// IsUnprocessedError reports whether err indicates the request was not
+// processed by the server, e.g. the stream was refused or above the last
+// stream ID of the GOAWAY, so it can be safely sent on another connection.
+func IsUnprocessedError(err error) bool {
+	return canRetryError(err)
+}
+
+// IsConnClosedError reports whether err indicates the connection was closed
+// before the response headers were received, the request may have been
+// processed by the server.
+func IsConnClosedError(err error) bool {
+	var goAwayErr GoAwayError
+	return err == errClientConnClosed || err == io.ErrUnexpectedEOF || errors.As(err, &goAwayErr)
+}
+
 func canRetryError(err error) bool {
 	if err == errClientConnUnusable || err == errClientConnGotGoAway {
 		return true
@@ -573,15 +630,29 @@ func canRetryError(err error) bool {
 }
 
 func (t *Transport) dialClientConn(ctx context.Context, addr string, singleUse bool) (*ClientConn, error) {
+	addr, settings := splitConnKey(addr)
+	addr, proxy := netutil.SplitProxyConnKey(addr)
+	if proxy != "" {
+		// the connection through the proxy is only added by the HTTP/1
+		// transport, which dials it through the proxy.
+		return nil, ErrNoCachedConn
+	}
+	addr, serverName := netutil.SplitConnKey(addr)
 	host, _, err := net.SplitHostPort(addr)
 	if err != nil {
 		return nil, err
 	}
+	if serverName != "" {
+		host = serverName
+	}
 	tconn, err := t.dialTLS(ctx)("tcp", addr, t.newTLSConfig(host))
 	if err != nil {
 		return nil, err
 	}
-	return t.newClientConn(tconn, singleUse)
+	if t.Options != nil {
+		t.ConnStats.Dialed(addr)
+	}
+	return t.newClientConn(tconn, singleUse, settings)
 }
 
 func (t *Transport) newTLSConfig(host string) *tls.Config {
@@ -602,7 +673,7 @@ func (t *Transport) newTLSConfig(host string) *tls.Config {
 // connection.
 func (t *Transport) dialTLSWithContext(ctx context.Context, network, addr string, cfg *tls.Config) (reqtls.Conn, error) {
 	if t.TLSHandshakeContext != nil {
-		conn, err := zeroDialer.DialContext(ctx, network, addr)
+		conn, err := t.DialDefault(ctx, network, addr)
 		if err != nil {
 			return nil, err
 		}
@@ -610,6 +681,9 @@ func (t *Transport) dialTLSWithContext(ctx context.Context, network, addr string
 		if firstTLSHost, _, err = net.SplitHostPort(addr); err != nil {
 			return nil, err
 		}
+		if serverName := netutil.ServerName(ctx); serverName != "" {
+			firstTLSHost = serverName
+		}
 		trace := httptrace.ContextClientTrace(ctx)
 		errc := make(chan error, 2)
 		var timer *time.Timer // for canceling TLS handshake
@@ -622,7 +696,7 @@ func (t *Transport) dialTLSWithContext(ctx context.Context, network, addr string
 			if trace != nil && trace.TLSHandshakeStart != nil {
 				trace.TLSHandshakeStart()
 			}
-			tlsCn, tlsState, err := t.TLSHandshakeContext(ctx, firstTLSHost, conn)
+			tlsCn, tlsState, err := t.TLSHandshakeContext(ctx, firstTLSHost, t.NewTLSRecorder(conn, firstTLSHost))
 			if err != nil {
 				if timer != nil {
 					timer.Stop()
@@ -643,17 +717,28 @@ func (t *Transport) dialTLSWithContext(ctx context.Context, network, addr string
 			return nil, err
 		} else {
 			tlsCn := conn.(reqtls.Conn)
+			state := tlsCn.ConnectionState()
+			if err := t.CheckConnection(firstTLSHost, &state); err != nil {
+				conn.Close()
+				return nil, err
+			}
 			return tlsCn, nil
 		}
 	} else {
-		dialer := &tls.Dialer{
-			Config: cfg,
-		}
-		conn, err := dialer.DialContext(ctx, network, addr)
+		conn, err := t.DialDefault(ctx, network, addr)
 		if err != nil {
 			return nil, err
 		}
-		tlsCn := conn.(reqtls.Conn)
+		tlsCn := tls.Client(t.NewTLSRecorder(conn, cfg.ServerName), cfg)
+		if err := tlsCn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		state := tlsCn.ConnectionState()
+		if err := t.CheckConnection(cfg.ServerName, &state); err != nil {
+			conn.Close()
+			return nil, err
+		}
 		return tlsCn, nil
 	}
 }
@@ -684,14 +769,28 @@ func (t *Transport) dialTLS(ctx context.Context) func(string, string, *tls.Confi
 }
 
 func (t *Transport) NewClientConn(c net.Conn) (*ClientConn, error) {
-	return t.newClientConn(c, t.DisableKeepAlives)
+	return t.newClientConn(c, t.DisableKeepAlives, nil)
 }
 
-func (t *Transport) newClientConn(c net.Conn, singleUse bool) (*ClientConn, error) {
+// newClientConn creates the ClientConn of c, which sends the settings instead
+// of t.Settings if it's not empty.
+func (t *Transport) newClientConn(c net.Conn, singleUse bool, settings []http2.Setting) (*ClientConn, error) {
+	headerTableSize := t.HeaderTableSize
+	if len(settings) == 0 {
+		settings = t.Settings
+		if len(settings) > 0 && headerTableSize != 0 {
+			settings = replaceSetting(settings, http2.SettingHeaderTableSize, headerTableSize)
+		}
+	} else {
+		// the settings of the request take precedence.
+		headerTableSize = 0
+	}
 	cc := &ClientConn{
 		t:                     t,
 		tconn:                 c,
 		readerDone:            make(chan struct{}),
+		createdAt:             time.Now(),
+		maxAge:                t.maxConnAge(),
 		nextStreamID:          1,
 		maxFrameSize:          16 << 10,                    // spec default
 		initialWindowSize:     65535,                       // spec default
@@ -709,13 +808,16 @@ func (t *Transport) newClientConn(c net.Conn, singleUse bool) (*ClientConn, erro
 
 	cc.cond = sync.NewCond(&cc.mu)
 
-	var headerTableSize uint32 = initialHeaderTableSize
-	for _, setting := range t.Settings {
+	if headerTableSize == 0 {
+		headerTableSize = initialHeaderTableSize
+	}
+	maxHeaderListSize := t.maxHeaderListSize()
+	for _, setting := range settings {
 		switch setting.ID {
 		case http2.SettingMaxFrameSize:
 			cc.maxFrameSize = setting.Val
 		case http2.SettingMaxHeaderListSize:
-			t.MaxHeaderListSize = setting.Val
+			maxHeaderListSize = setting.Val
 		case http2.SettingHeaderTableSize:
 			headerTableSize = setting.Val
 		}
@@ -737,7 +839,7 @@ func (t *Transport) newClientConn(c net.Conn, singleUse bool) (*ClientConn, erro
 		cc.fr.countError = t.CountError
 	}
 	cc.fr.ReadMetaHeaders = hpack.NewDecoder(headerTableSize, nil)
-	cc.fr.MaxHeaderListSize = t.maxHeaderListSize()
+	cc.fr.MaxHeaderListSize = maxHeaderListSize
 
 	// TODO: SetMaxDynamicTableSize, SetMaxDynamicTableSizeLimit on
 	// henc in response to SETTINGS frames?
@@ -749,8 +851,8 @@ func (t *Transport) newClientConn(c net.Conn, singleUse bool) (*ClientConn, erro
 	}
 
 	var initialSettings []http2.Setting
-	if len(t.Settings) > 0 {
-		initialSettings = t.Settings
+	if len(settings) > 0 {
+		initialSettings = settings
 	} else {
 		initialSettings = []http2.Setting{
 			{ID: http2.SettingEnablePush, Val: 0},
@@ -759,6 +861,9 @@ func (t *Transport) newClientConn(c net.Conn, singleUse bool) (*ClientConn, erro
 		if max := t.maxHeaderListSize(); max != 0 {
 			initialSettings = append(initialSettings, http2.Setting{ID: http2.SettingMaxHeaderListSize, Val: max})
 		}
+		if t.HeaderTableSize != 0 {
+			initialSettings = append(initialSettings, http2.Setting{ID: http2.SettingHeaderTableSize, Val: t.HeaderTableSize})
+		}
 	}
 
 	cc.bw.Write(clientPreface)
@@ -773,6 +878,7 @@ func (t *Transport) newClientConn(c net.Conn, singleUse bool) (*ClientConn, erro
 		cc.fr.WritePriority(p.StreamID, p.PriorityParam)
 		cc.nextStreamID = p.StreamID + 2
 	}
+	cc.fingerprint = connFingerprint(initialSettings, connFlow, t.PriorityFrames)
 
 	cc.inflow.init(int32(connFlow) + initialWindowSize)
 	cc.bw.Flush()
@@ -782,10 +888,15 @@ func (t *Transport) newClientConn(c net.Conn, singleUse bool) (*ClientConn, erro
 	}
 
 	// Start the idle timer after the connection is fully initialized.
-	if d := t.IdleConnTimeout; d != 0 {
+	if d := t.idleConnTimeout(); d != 0 {
 		cc.idleTimeout = d
 		cc.idleTimer = t.afterFunc(d, cc.onIdleTimeout)
 	}
+	if cc.maxAge != 0 {
+		// close the connection once it's too old if it's idle, or it's
+		// closed by forgetStreamID after the last stream is done.
+		cc.maxAgeTimer = t.afterFunc(cc.maxAge, cc.closeIfIdle)
+	}
 
 	go cc.readLoop()
 	return cc, nil
@@ -924,7 +1035,7 @@ func (cc *ClientConn) idleStateLocked() (st clientConnIdleState) {
 		return
 	}
 	var maxConcurrentOkay bool
-	if cc.t.StrictMaxConcurrentStreams {
+	if cc.t.strictMaxConcurrentStreams() {
 		// We'll tell the caller we can take a new request to
 		// prevent the caller from dialing a new TCP
 		// connection, but then we'll block later before
@@ -937,7 +1048,7 @@ func (cc *ClientConn) idleStateLocked() (st clientConnIdleState) {
 	st.canTakeNewRequest = cc.goAway == nil && !cc.closed && !cc.closing && maxConcurrentOkay &&
 		!cc.doNotReuse &&
 		int64(cc.nextStreamID)+2*int64(cc.pendingRequests) < math.MaxInt32 &&
-		!cc.tooIdleLocked()
+		!cc.tooIdleLocked() && !cc.tooOld()
 	return
 }
 
@@ -956,6 +1067,11 @@ func (cc *ClientConn) tooIdleLocked() bool {
 	return cc.idleTimeout != 0 && !cc.lastIdle.IsZero() && time.Since(cc.lastIdle.Round(0)) > cc.idleTimeout
 }
 
+// tooOld reports whether this connection has exceeded the max age.
+func (cc *ClientConn) tooOld() bool {
+	return cc.maxAge != 0 && time.Since(cc.createdAt) > cc.maxAge
+}
+
 // onIdleTimeout is called from a time.AfterFunc goroutine. It will
 // only be called when we're idle, but because we're coming from a new
 // goroutine, there could be a new request coming in at the same time,
@@ -984,6 +1100,14 @@ func (cc *ClientConn) forceCloseConn() {
 	}
 }
 
+// isIdle reports whether cc has no active streams and can take new
+// requests.
+func (cc *ClientConn) isIdle() bool {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	return len(cc.streams) == 0 && cc.streamsReserved == 0 && cc.canTakeNewRequestLocked()
+}
+
 func (cc *ClientConn) closeIfIdle() {
 	cc.mu.Lock()
 	if len(cc.streams) > 0 || cc.streamsReserved > 0 {
@@ -1706,9 +1830,13 @@ func (cs *clientStream) writeRequestBody(req *http.Request, dumps []*dump.Dumper
 
 	writeData := cc.fr.WriteData
 	if len(dumps) > 0 {
+		dumpBody := make([]func([]byte), len(dumps))
+		for i, dump := range dumps {
+			dumpBody[i] = dump.RequestBodyDumper()
+		}
 		writeData = func(streamID uint32, endStream bool, data []byte) error {
-			for _, dump := range dumps {
-				dump.DumpRequestBody(data)
+			for _, dumpBody := range dumpBody {
+				dumpBody(data)
 			}
 			return cc.fr.WriteData(streamID, endStream, data)
 		}
@@ -2075,7 +2203,17 @@ func (cc *ClientConn) encodeHeaders(req *http.Request, addGzipHeader bool, trail
 	trace := httptrace.ContextClientTrace(req.Context())
 	traceHeaders := traceHasWroteHeaderField(trace)
 
-	writeHeader := cc.writeHeader
+	writeField := cc.writeHeader
+	if neverIndexed := neverIndexedHeadersFromContext(req.Context()); len(neverIndexed) > 0 {
+		writeField = func(name, value string) {
+			if neverIndexed[name] {
+				cc.writeNeverIndexedHeader(name, value)
+			} else {
+				cc.writeHeader(name, value)
+			}
+		}
+	}
+	writeHeader := writeField
 	headerDumps := []*dump.Dumper{}
 	if len(dumps) > 0 {
 		for _, dump := range dumps {
@@ -2088,12 +2226,14 @@ func (cc *ClientConn) encodeHeaders(req *http.Request, addGzipHeader bool, trail
 				for _, dump := range headerDumps {
 					dump.DumpRequestHeader([]byte(fmt.Sprintf("%s: %s\r\n", name, value)))
 				}
-				cc.writeHeader(name, value)
+				writeField(name, value)
 			}
 		}
 	}
 
 	// Header list size is ok. Write the headers.
+	recorder := fingerprintRecorderFromContext(req.Context())
+	var pseudoOrder []string
 	enumerateHeaders(func(name, value string) {
 		name, ascii := lowerHeader(name)
 		if !ascii {
@@ -2101,6 +2241,9 @@ func (cc *ClientConn) encodeHeaders(req *http.Request, addGzipHeader bool, trail
 			// field names have to be ASCII characters (just as in HTTP/1.x).
 			return
 		}
+		if recorder != nil && len(name) > 1 && name[0] == ':' {
+			pseudoOrder = append(pseudoOrder, name[1:2])
+		}
 		writeHeader(name, value)
 		if traceHeaders {
 			traceWroteHeaderField(trace, name, value)
@@ -2110,6 +2253,9 @@ func (cc *ClientConn) encodeHeaders(req *http.Request, addGzipHeader bool, trail
 	for _, dump := range headerDumps {
 		dump.DumpRequestHeader([]byte("\r\n"))
 	}
+	if recorder != nil {
+		recorder.record(cc.fingerprint + "|" + strings.Join(pseudoOrder, ","))
+	}
 
 	return cc.hbuf.Bytes(), nil
 }
@@ -2184,6 +2330,16 @@ func (cc *ClientConn) writeHeader(name, value string) {
 	cc.henc.WriteField(hpack.HeaderField{Name: name, Value: value})
 }
 
+// writeNeverIndexedHeader encodes the header as the never indexed literal,
+// which never enters the dynamic table of the encoder, the decoder and the
+// intermediaries.
+func (cc *ClientConn) writeNeverIndexedHeader(name, value string) {
+	if VerboseLogs {
+		log.Printf("http2: Transport encoding never indexed header %q = %q", name, value)
+	}
+	cc.henc.WriteField(hpack.HeaderField{Name: name, Value: value, Sensitive: true})
+}
+
 type resAndError struct {
 	_   incomparable
 	res *http.Response
@@ -2219,7 +2375,7 @@ func (cc *ClientConn) forgetStreamID(id uint32) {
 	// wake up RoundTrip if there is a pending request.
 	cc.cond.Broadcast()
 
-	closeOnIdle := cc.singleUse || cc.doNotReuse || cc.t.DisableKeepAlives || cc.goAway != nil
+	closeOnIdle := cc.singleUse || cc.doNotReuse || cc.t.DisableKeepAlives || cc.goAway != nil || cc.tooOld() || cc.t.closeIdleConns()
 	if closeOnIdle && cc.streamsReserved == 0 && len(cc.streams) == 0 {
 		if VerboseLogs {
 			cc.vlogf("http2: Transport closing idle conn %p (forSingleUse=%v, maxStream=%v)", cc, cc.singleUse, cc.nextStreamID-2)
@@ -2279,6 +2435,9 @@ func (rl *clientConnReadLoop) cleanup() {
 	if cc.idleTimer != nil {
 		cc.idleTimer.Stop()
 	}
+	if cc.maxAgeTimer != nil {
+		cc.maxAgeTimer.Stop()
+	}
 
 	// Close any response bodies if the server closes prematurely.
 	// TODO: also do this if we've written the headers but not
@@ -12,6 +12,7 @@ import (
 
 	"github.com/quic-go/qpack"
 	"github.com/quic-go/quic-go"
+	"golang.org/x/net/http/httpguts"
 
 	"github.com/luoxk/restys/internal/dump"
 	"github.com/luoxk/restys/internal/quic-go/quicvarint"
@@ -268,15 +269,74 @@ func (c *SingleDestinationRoundTripper) sendRequestBody(str Stream, body io.Read
 	return err
 }
 
-func (c *SingleDestinationRoundTripper) doRequest(req *http.Request, str *requestStream) (*http.Response, error) {
+func (c *SingleDestinationRoundTripper) responseHeaderTimeout() time.Duration {
+	if c.Options == nil {
+		return 0
+	}
+	return c.ResponseHeaderTimeout
+}
+
+func (c *SingleDestinationRoundTripper) expectContinueTimeout() time.Duration {
+	if c.Options == nil {
+		return 0
+	}
+	return c.ExpectContinueTimeout
+}
+
+func (c *SingleDestinationRoundTripper) doRequest(req *http.Request, str *requestStream) (_ *http.Response, err error) {
 	if err := str.SendRequestHeader(req); err != nil {
 		return nil, err
 	}
+	trace := httptrace.ContextClientTrace(req.Context())
+	headerTimer := &responseHeaderTimer{
+		timeout: c.responseHeaderTimeout(),
+		cancel: func() {
+			str.CancelWrite(quic.StreamErrorCode(ErrCodeRequestCanceled))
+			str.CancelRead(quic.StreamErrorCode(ErrCodeRequestCanceled))
+		},
+	}
+	defer func() {
+		if headerTimer.stop() {
+			err = errTimeout
+		}
+	}()
+	// continueCh receives whether to send the request body, it's only set
+	// if the request is waiting for "100 Continue".
+	var continueCh chan bool
 	if req.Body == nil {
 		str.Close()
+		headerTimer.start()
 	} else {
+		continueTimeout := c.expectContinueTimeout()
+		if continueTimeout > 0 && httpguts.HeaderValuesContainsToken(req.Header["Expect"], "100-continue") {
+			continueCh = make(chan bool, 1)
+			defer func() {
+				// the final response is received or the request failed
+				// before "100 Continue", don't send the body.
+				select {
+				case continueCh <- false:
+				default:
+				}
+			}()
+			if trace != nil && trace.Wait100Continue != nil {
+				trace.Wait100Continue()
+			}
+		}
 		// send the request body asynchronously
 		go func() {
+			if continueCh != nil {
+				timer := time.NewTimer(continueTimeout)
+				select {
+				case <-timer.C:
+				case send := <-continueCh:
+					timer.Stop()
+					if !send {
+						req.Body.Close()
+						str.CancelWrite(quic.StreamErrorCode(ErrCodeNoError))
+						return
+					}
+				}
+			}
 			dumps := dump.GetDumpers(req.Context(), c.Dump)
 			if err := c.sendRequestBody(str, req.Body, dumps); err != nil {
 				if c.Debugf != nil {
@@ -284,11 +344,11 @@ func (c *SingleDestinationRoundTripper) doRequest(req *http.Request, str *reques
 				}
 			}
 			str.Close()
+			headerTimer.start()
 		}()
 	}
 
 	// copy from net/http: support 1xx responses
-	trace := httptrace.ContextClientTrace(req.Context())
 	num1xx := 0               // number of informational 1xx headers received
 	const max1xxResponses = 5 // arbitrary bound on number of informational responses
 
@@ -313,6 +373,12 @@ func (c *SingleDestinationRoundTripper) doRequest(req *http.Request, str *reques
 					return nil, err
 				}
 			}
+			if resCode == http.StatusContinue && continueCh != nil {
+				select {
+				case continueCh <- true:
+				default:
+				}
+			}
 			continue
 		}
 		break
@@ -322,3 +388,57 @@ func (c *SingleDestinationRoundTripper) doRequest(req *http.Request, str *reques
 	res.Request = req
 	return res, nil
 }
+
+var errTimeout error = &timeoutError{"http3: timeout awaiting response headers"}
+
+type timeoutError struct {
+	err string
+}
+
+func (e *timeoutError) Error() string   { return e.err }
+func (e *timeoutError) Timeout() bool   { return true }
+func (e *timeoutError) Temporary() bool { return true }
+
+// responseHeaderTimer cancels the request stream if the response headers
+// are not received within the timeout after the request is fully written.
+type responseHeaderTimer struct {
+	timeout time.Duration
+	cancel  func()
+
+	mu      sync.Mutex
+	timer   *time.Timer
+	stopped bool
+	expired bool
+}
+
+func (t *responseHeaderTimer) start() {
+	if t.timeout <= 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.stopped {
+		return
+	}
+	t.timer = time.AfterFunc(t.timeout, func() {
+		t.mu.Lock()
+		if t.stopped {
+			t.mu.Unlock()
+			return
+		}
+		t.expired = true
+		t.mu.Unlock()
+		t.cancel()
+	})
+}
+
+// stop stops the timer, and reports whether it has expired.
+func (t *responseHeaderTimer) stop() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.stopped = true
+	if t.timer != nil {
+		t.timer.Stop()
+	}
+	return t.expired
+}
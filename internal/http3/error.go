@@ -56,3 +56,32 @@ func maybeReplaceError(err error) error {
 	}
 	return &e
 }
+
+// IsUnprocessedError reports whether err indicates the request was rejected
+// by the server without being processed (H3_REQUEST_REJECTED), so it can be
+// safely sent on another connection.
+func IsUnprocessedError(err error) bool {
+	var e *Error
+	return errors.As(err, &e) && e.Remote && e.ErrorCode == ErrCodeRequestRejected
+}
+
+// IsConnClosedError reports whether err indicates the connection was closed
+// before the response headers were received, the request may have been
+// processed by the server.
+func IsConnClosedError(err error) bool {
+	var (
+		e            *Error
+		idleErr      *quic.IdleTimeoutError
+		resetErr     *quic.StatelessResetError
+		transportErr *quic.TransportError
+	)
+	switch {
+	case errors.As(err, &e):
+		return e.Remote && e.ErrorCode == ErrCodeNoError
+	case errors.As(err, &idleErr), errors.As(err, &resetErr):
+		return true
+	case errors.As(err, &transportErr):
+		return transportErr.Remote && transportErr.ErrorCode == quic.NoError
+	}
+	return false
+}
@@ -0,0 +1,288 @@
+package http3
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/quic-go/quic-go"
+
+	"github.com/luoxk/restys/internal/quic-go/quicvarint"
+)
+
+// defaultMasqueTemplate is the default URI template of the UDP proxying
+// (RFC 9298), which is used if the path of the proxy URL doesn't contain
+// the "{target_host}" and "{target_port}" variables.
+const defaultMasqueTemplate = "/.well-known/masque/udp/{target_host}/{target_port}/"
+
+// masquePacketSize is the initial packet size of the connection to the
+// proxy, which must be large enough to carry the 1200 bytes QUIC packets of
+// the tunneled connection in the DATAGRAM frames.
+const masquePacketSize = 1350
+
+var masqueConnID atomic.Uint64
+
+// masqueTarget returns the URL of the CONNECT-UDP request to the target
+// addr ("host:port") through the proxy.
+func masqueTarget(proxy *url.URL, addr string) (*url.URL, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	// the braces of the variables are escaped by EscapedPath.
+	template := strings.NewReplacer("%7Btarget_host%7D", "{target_host}", "%7Btarget_port%7D", "{target_port}").Replace(proxy.EscapedPath())
+	if !strings.Contains(template, "{target_host}") || !strings.Contains(template, "{target_port}") {
+		template = defaultMasqueTemplate
+	}
+	// the colons of the IPv6 address are percent-encoded.
+	host = strings.ReplaceAll(url.PathEscape(host), ":", "%3A")
+	path := strings.NewReplacer("{target_host}", host, "{target_port}", url.PathEscape(port)).Replace(template)
+	u, err := url.Parse("https://" + proxy.Host + path)
+	if err != nil {
+		return nil, err
+	}
+	u.RawQuery = proxy.RawQuery
+	return u, nil
+}
+
+// dialMasque dials the QUIC connection to addr which is tunneled through
+// the HTTP/3 proxy by the CONNECT-UDP (RFC 9298).
+func (r *RoundTripper) dialMasque(ctx context.Context, addr string, tlsCfg *tls.Config, cfg *quic.Config) (quic.EarlyConnection, error) {
+	proxy := r.Proxy
+	target, err := masqueTarget(proxy, addr)
+	if err != nil {
+		return nil, err
+	}
+	proxyAddr := proxy.Host
+	if proxy.Port() == "" {
+		proxyAddr = net.JoinHostPort(proxy.Hostname(), "443")
+	}
+	udpAddr, err := r.ResolveUDPAddr(ctx, proxyAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	// the certificate of the proxy is verified with the same roots as the
+	// target, but the pinned certificates of the target are not applied.
+	proxyTLS := tlsCfg.Clone()
+	proxyTLS.ServerName = proxy.Hostname()
+	proxyTLS.VerifyConnection = nil
+	proxyCfg := cfg.Clone()
+	proxyCfg.EnableDatagrams = true
+	proxyCfg.InitialPacketSize = max(proxyCfg.InitialPacketSize, masquePacketSize)
+	proxyConn, err := r.transport.DialEarly(ctx, udpAddr, proxyTLS, proxyCfg)
+	if err != nil {
+		return nil, fmt.Errorf("http3: failed to dial proxy %s: %w", proxy.Host, err)
+	}
+	str, err := r.connectUDP(ctx, proxyConn, proxy, target)
+	if err != nil {
+		proxyConn.CloseWithError(quic.ApplicationErrorCode(ErrCodeNoError), "")
+		return nil, err
+	}
+
+	// quic-go requires the distinct local addresses of the packet conns.
+	localAddr := masqueAddr(fmt.Sprintf("%s#%d", proxyConn.LocalAddr(), masqueConnID.Add(1)))
+	pconn := newMasqueConn(str, localAddr, masqueAddr(addr), func() {
+		proxyConn.CloseWithError(quic.ApplicationErrorCode(ErrCodeNoError), "")
+	})
+	// the packets of the tunneled connection can't be larger than the
+	// DATAGRAM frames.
+	cfg = cfg.Clone()
+	cfg.InitialPacketSize = 1200
+	cfg.DisablePathMTUDiscovery = true
+	conn, err := quic.DialEarly(ctx, pconn, pconn.remoteAddr, tlsCfg, cfg)
+	if err != nil {
+		pconn.Close()
+		return nil, err
+	}
+	go func() {
+		<-conn.Context().Done()
+		pconn.Close()
+	}()
+	return conn, nil
+}
+
+// connectUDP sends the CONNECT-UDP request to the proxy and returns the
+// request stream which carries the UDP payloads in the HTTP datagrams.
+func (r *RoundTripper) connectUDP(ctx context.Context, proxyConn quic.EarlyConnection, proxy, target *url.URL) (RequestStream, error) {
+	rt := &SingleDestinationRoundTripper{
+		Options:         r.Options,
+		Connection:      proxyConn,
+		EnableDatagrams: true,
+	}
+	hconn := rt.Start()
+	select {
+	case <-hconn.ReceivedSettings():
+	case <-hconn.Context().Done():
+		return nil, fmt.Errorf("http3: proxy connection closed: %w", context.Cause(hconn.Context()))
+	case <-ctx.Done():
+		return nil, context.Cause(ctx)
+	}
+	settings := hconn.Settings()
+	if !settings.EnableExtendedConnect {
+		return nil, errors.New("http3: proxy doesn't support extended CONNECT")
+	}
+	if !settings.EnableDatagrams {
+		return nil, errors.New("http3: proxy doesn't support HTTP datagrams")
+	}
+
+	str, err := rt.OpenRequestStream(ctx)
+	if err != nil {
+		return nil, err
+	}
+	req := (&http.Request{
+		Method: http.MethodConnect,
+		Proto:  "connect-udp",
+		URL:    target,
+		Host:   proxy.Host,
+		Header: http.Header{"Capsule-Protocol": {"?1"}},
+	}).WithContext(ctx)
+	if u := proxy.User; u != nil {
+		password, _ := u.Password()
+		auth := base64.StdEncoding.EncodeToString([]byte(u.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+auth)
+	}
+	if err := str.SendRequestHeader(req); err != nil {
+		return nil, err
+	}
+	resp, err := str.ReadResponse()
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		str.CancelRead(quic.StreamErrorCode(ErrCodeRequestCanceled))
+		str.Close()
+		return nil, fmt.Errorf("http3: proxy responded with status %s to CONNECT-UDP %s", resp.Status, target.Path)
+	}
+	return str, nil
+}
+
+// masqueAddr is the address of the target tunneled through the proxy.
+type masqueAddr string
+
+func (a masqueAddr) Network() string { return "udp" }
+func (a masqueAddr) String() string  { return string(a) }
+
+// masqueConn is the net.PacketConn which sends and receives the UDP
+// payloads through the CONNECT-UDP request stream.
+type masqueConn struct {
+	str        RequestStream
+	localAddr  net.Addr
+	remoteAddr net.Addr
+	onClose    func()
+
+	mu           sync.Mutex
+	readDeadline time.Time
+	cancelRead   context.CancelFunc
+	closed       bool
+}
+
+var _ net.PacketConn = &masqueConn{}
+
+func newMasqueConn(str RequestStream, localAddr, remoteAddr net.Addr, onClose func()) *masqueConn {
+	c := &masqueConn{
+		str:        str,
+		localAddr:  localAddr,
+		remoteAddr: remoteAddr,
+		onClose:    onClose,
+	}
+	go func() {
+		// the capsules are not used, the tunnel is closed with the stream.
+		io.Copy(io.Discard, str)
+		c.Close()
+	}()
+	return c
+}
+
+func (c *masqueConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	for {
+		c.mu.Lock()
+		if c.closed {
+			c.mu.Unlock()
+			return 0, nil, net.ErrClosed
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		if deadline := c.readDeadline; !deadline.IsZero() {
+			cancel()
+			ctx, cancel = context.WithDeadline(context.Background(), deadline)
+		}
+		c.cancelRead = cancel
+		c.mu.Unlock()
+
+		data, err := c.str.ReceiveDatagram(ctx)
+		cancel()
+		if err != nil {
+			if ctx.Err() == nil {
+				return 0, nil, err
+			}
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return 0, nil, os.ErrDeadlineExceeded
+			}
+			// closed or the deadline is changed.
+			continue
+		}
+		contextID, n, err := quicvarint.Parse(data)
+		if err != nil || contextID != 0 {
+			// the unknown contexts are dropped (RFC 9298 Section 4).
+			continue
+		}
+		return copy(b, data[n:]), c.remoteAddr, nil
+	}
+}
+
+func (c *masqueConn) WriteTo(b []byte, _ net.Addr) (int, error) {
+	data := make([]byte, 0, 1+len(b))
+	data = quicvarint.Append(data, 0)
+	data = append(data, b...)
+	if err := c.str.SendDatagram(data); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func (c *masqueConn) Close() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	c.closed = true
+	if c.cancelRead != nil {
+		c.cancelRead()
+	}
+	c.mu.Unlock()
+	c.str.CancelRead(quic.StreamErrorCode(ErrCodeNoError))
+	err := c.str.Close()
+	c.onClose()
+	return err
+}
+
+func (c *masqueConn) LocalAddr() net.Addr { return c.localAddr }
+
+func (c *masqueConn) SetDeadline(t time.Time) error {
+	return c.SetReadDeadline(t)
+}
+
+func (c *masqueConn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	c.readDeadline = t
+	if c.cancelRead != nil {
+		c.cancelRead()
+	}
+	c.mu.Unlock()
+	return nil
+}
+
+// SetWriteDeadline is a no-op, the datagrams are sent without blocking.
+func (c *masqueConn) SetWriteDeadline(time.Time) error { return nil }
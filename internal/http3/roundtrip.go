@@ -8,9 +8,11 @@ import (
 	"io"
 	"net"
 	"net/http"
+	"net/url"
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/luoxk/restys/internal/transport"
 
@@ -48,7 +50,9 @@ type roundTripperWithCount struct {
 	conn    quic.EarlyConnection
 	rt      singleRoundTripper
 
-	useCount atomic.Int64
+	useCount  atomic.Int64
+	createdAt time.Time
+	idleTimer *time.Timer // guarded by RoundTripper.mutex
 }
 
 func (r *roundTripperWithCount) Close() error {
@@ -79,6 +83,22 @@ type RoundTripper struct {
 	// and will be reused for subsequent connections to other servers.
 	Dial func(ctx context.Context, addr string, tlsCfg *tls.Config, cfg *quic.Config) (quic.EarlyConnection, error)
 
+	// Proxy specifies an optional HTTP/3 proxy which supports the UDP
+	// proxying (CONNECT-UDP, RFC 9298), the QUIC connections are tunneled
+	// through it if Dial is nil. The path of the proxy URL is used as the
+	// URI template if it contains the "{target_host}" and "{target_port}"
+	// variables, default is "/.well-known/masque/udp/{target_host}/{target_port}/".
+	Proxy *url.URL
+
+	// ECHConfigList optionally returns the ECHConfigList of the host, the
+	// Encrypted Client Hello is used for the new connections to the host
+	// if it's not empty.
+	ECHConfigList func(ctx context.Context, host string) []byte
+
+	// OnECHRejection is called with the dial error if the ECH of the
+	// connection to the host is set by ECHConfigList.
+	OnECHRejection func(host string, err error)
+
 	// Enable support for HTTP/3 datagrams (RFC 9297).
 	// If a QUICConfig is set, datagram support also needs to be enabled on the QUIC layer by setting EnableDatagrams.
 	EnableDatagrams bool
@@ -87,6 +107,10 @@ type RoundTripper struct {
 	// It is invalid to specify any settings defined by RFC 9114 (HTTP/3) and RFC 9297 (HTTP Datagrams).
 	AdditionalSettings map[uint64]uint64
 
+	// CurvePreferences overrides the supported groups of the TLS ClientHello,
+	// the key share is sent for the first one.
+	CurvePreferences []tls.CurveID
+
 	initOnce sync.Once
 	initErr  error
 
@@ -164,8 +188,13 @@ func (r *RoundTripper) RoundTripOpt(req *http.Request, opt RoundTripOpt) (*http.
 		r.removeClient(hostname)
 		return nil, cl.dialErr
 	}
-	defer cl.useCount.Add(-1)
 	rsp, err := cl.rt.RoundTrip(req)
+	if err == nil && rsp.Body != nil {
+		// the connection is in use until the response body is read.
+		rsp.Body = &releaseBody{ReadCloser: rsp.Body, release: func() { r.release(hostname, cl) }}
+	} else {
+		r.release(hostname, cl)
+	}
 	if err != nil {
 		// non-nil errors on roundtrip are likely due to a problem with the connection
 		// so we remove the client from the cache so that subsequent trips reconnect
@@ -229,8 +258,88 @@ func (r *RoundTripper) AddConn(ctx context.Context, addr string) error {
 	addr = authorityAddr(addr)
 	cl, _, err := r.getClient(ctx, addr, false)
 	if err == nil {
-		cl.useCount.Add(-1)
+		r.release(addr, cl)
+	}
+	return err
+}
+
+// release decrements the use count of cl, the idle connection is closed
+// after the IdleConnTimeout or once it exceeds the MaxConnAge, or
+// immediately if the MaxIdleConnsPerHost is negative.
+func (r *RoundTripper) release(hostname string, cl *roundTripperWithCount) {
+	if cl.useCount.Add(-1) != 0 || r.Options == nil {
+		return
+	}
+	timeout := r.IdleConnTimeout
+	if r.MaxConnAge > 0 {
+		if left := r.MaxConnAge - time.Since(cl.createdAt); timeout <= 0 || left < timeout {
+			timeout = max(left, 0)
+		}
+	}
+	if r.MaxIdleConnsPerHost < 0 {
+		timeout = 0
+	} else if timeout <= 0 && r.MaxConnAge <= 0 {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if cl.idleTimer == nil {
+		cl.idleTimer = time.AfterFunc(timeout, func() { r.closeIfIdle(hostname, cl) })
+	} else {
+		cl.idleTimer.Reset(timeout)
+	}
+}
+
+// closeIfIdle closes cl and removes it from the cache if it's not in use.
+func (r *RoundTripper) closeIfIdle(hostname string, cl *roundTripperWithCount) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if cl.useCount.Load() != 0 {
+		return
+	}
+	if r.clients[hostname] == cl {
+		delete(r.clients, hostname)
+	}
+	cl.Close()
+}
+
+// IdleConns returns the number of the idle connections per addr
+// ("host:port").
+func (r *RoundTripper) IdleConns() map[string]int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	idle := make(map[string]int)
+	for hostname, cl := range r.clients {
+		select {
+		case <-cl.dialing:
+			if cl.dialErr == nil && cl.useCount.Load() == 0 {
+				idle[hostname]++
+			}
+		default:
+		}
+	}
+	return idle
+}
+
+// releaseBody calls release once the response body is read to the end or
+// closed.
+type releaseBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *releaseBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil {
+		b.once.Do(b.release)
 	}
+	return n, err
+}
+
+func (b *releaseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
 	return err
 }
 
@@ -243,14 +352,24 @@ func (r *RoundTripper) getClient(ctx context.Context, hostname string, onlyCache
 	}
 
 	cl, ok := r.clients[hostname]
+	if ok && r.connTooOld(cl) {
+		// not used for new requests, the one in use is closed by release
+		// once the in-flight requests are done.
+		delete(r.clients, hostname)
+		if cl.useCount.Load() == 0 {
+			cl.Close()
+		}
+		ok = false
+	}
 	if !ok {
 		if onlyCached {
 			return nil, false, ErrNoCachedConn
 		}
 		ctx, cancel := context.WithCancel(ctx)
 		cl = &roundTripperWithCount{
-			dialing: make(chan struct{}),
-			cancel:  cancel,
+			dialing:   make(chan struct{}),
+			cancel:    cancel,
+			createdAt: time.Now(),
 		}
 		go func() {
 			defer close(cl.dialing)
@@ -299,32 +418,86 @@ func (r *RoundTripper) dial(ctx context.Context, hostname string) (quic.EarlyCon
 	}
 	// Replace existing ALPNs by H3
 	tlsConf.NextProtos = []string{versionToALPN(r.QUICConfig.Versions[0])}
+	if len(r.CurvePreferences) > 0 {
+		tlsConf.CurvePreferences = r.CurvePreferences
+	}
+	if tlsConf.KeyLogWriter == nil && r.Options != nil && r.Options.TLSClientConfig != nil {
+		// the key log of the client's tls config also covers HTTP3.
+		tlsConf.KeyLogWriter = r.Options.TLSClientConfig.KeyLogWriter
+	}
+	if r.ECHConfigList != nil {
+		if configList := r.ECHConfigList(ctx, tlsConf.ServerName); len(configList) > 0 {
+			tlsConf.EncryptedClientHelloConfigList = configList
+			// ECH requires TLS 1.3.
+			tlsConf.MinVersion = tls.VersionTLS13
+		}
+	}
+	if r.Options != nil && r.VerifyConnection != nil {
+		verify, serverName := tlsConf.VerifyConnection, tlsConf.ServerName
+		tlsConf.VerifyConnection = func(state tls.ConnectionState) error {
+			if verify != nil {
+				if err := verify(state); err != nil {
+					return err
+				}
+			}
+			return r.CheckConnection(serverName, &state)
+		}
+	}
 
 	dial := r.Dial
 	if dial == nil {
 		if r.transport == nil {
-			udpConn, err := net.ListenUDP("udp", nil)
+			var udpConn net.PacketConn
+			var err error
+			if r.Options != nil {
+				udpConn, err = r.ListenUDP(ctx)
+			} else {
+				udpConn, err = net.ListenUDP("udp", nil)
+			}
 			if err != nil {
 				return nil, nil, err
 			}
 			r.transport = &quic.Transport{Conn: udpConn}
 		}
 		dial = func(ctx context.Context, addr string, tlsCfg *tls.Config, cfg *quic.Config) (quic.EarlyConnection, error) {
-			udpAddr, err := net.ResolveUDPAddr("udp", addr)
+			udpAddr, err := r.ResolveUDPAddr(ctx, addr)
 			if err != nil {
 				return nil, err
 			}
 			return r.transport.DialEarly(ctx, udpAddr, tlsCfg, cfg)
 		}
+		if r.Proxy != nil {
+			dial = r.dialMasque
+		}
 	}
 
 	conn, err := dial(ctx, hostname, tlsConf, r.QUICConfig)
 	if err != nil {
+		if tlsConf.EncryptedClientHelloConfigList != nil && r.OnECHRejection != nil {
+			r.OnECHRejection(tlsConf.ServerName, err)
+		}
 		return nil, nil, err
 	}
+	if r.Options != nil {
+		r.ConnStats.Dialed(hostname)
+	}
 	return conn, r.newClient(conn), nil
 }
 
+// connTooOld reports whether the established connection has exceeded the
+// MaxConnAge.
+func (r *RoundTripper) connTooOld(cl *roundTripperWithCount) bool {
+	if r.Options == nil || r.MaxConnAge <= 0 || time.Since(cl.createdAt) <= r.MaxConnAge {
+		return false
+	}
+	select {
+	case <-cl.dialing:
+		return cl.dialErr == nil
+	default:
+		return false
+	}
+}
+
 func (r *RoundTripper) removeClient(hostname string) {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
@@ -0,0 +1,73 @@
+package netutil
+
+import (
+	"context"
+	"strings"
+)
+
+type serverNameKey struct{}
+
+// WithServerName returns a copy of ctx which carries the server name of the
+// tls handshake, which overrides the host of the request URL.
+func WithServerName(ctx context.Context, serverName string) context.Context {
+	return context.WithValue(ctx, serverNameKey{}, serverName)
+}
+
+// ServerName returns the server name carried by ctx, or "" if not set.
+func ServerName(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	serverName, _ := ctx.Value(serverNameKey{}).(string)
+	return serverName
+}
+
+// ConnKey returns the key of the cached connections to addr, the connections
+// with different server names are not shared.
+func ConnKey(addr, serverName string) string {
+	if serverName == "" {
+		return addr
+	}
+	return addr + "#" + serverName
+}
+
+// SplitConnKey splits the key returned by ConnKey into addr and server name.
+func SplitConnKey(key string) (addr, serverName string) {
+	addr, serverName, _ = strings.Cut(key, "#")
+	return
+}
+
+type proxyKey struct{}
+
+// WithProxy returns a copy of ctx which carries the proxy the connection of
+// the request is tunneled through, see ProxyConnKey.
+func WithProxy(ctx context.Context, proxy string) context.Context {
+	return context.WithValue(ctx, proxyKey{}, proxy)
+}
+
+// Proxy returns the proxy carried by ctx, or "" if not set.
+func Proxy(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	proxy, _ := ctx.Value(proxyKey{}).(string)
+	return proxy
+}
+
+// ProxyConnKey returns the key (see ConnKey) of the cached connections
+// tunneled through the proxy, the connections through different proxies are
+// not shared.
+func ProxyConnKey(key, proxy string) string {
+	if proxy == "" {
+		return key
+	}
+	// the space is always escaped in the proxy URL.
+	return key + " " + proxy
+}
+
+// SplitProxyConnKey splits the key returned by ProxyConnKey into key and
+// proxy.
+func SplitProxyConnKey(key string) (string, string) {
+	key, proxy, _ := strings.Cut(key, " ")
+	return key, proxy
+}
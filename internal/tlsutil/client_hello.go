@@ -0,0 +1,98 @@
+package tlsutil
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+const (
+	extensionSupportedGroups = 10
+	extensionPointFormats    = 11
+)
+
+// ClientHello is the fields of the ClientHello message.
+type ClientHello struct {
+	Version      uint16
+	CipherSuites []uint16
+	// Extensions is the extension types in the order sent by the client.
+	Extensions   []uint16
+	Curves       []uint16
+	PointFormats []uint8
+}
+
+var errMalformedClientHello = errors.New("malformed ClientHello")
+
+// readVector reads the vector whose length is encoded in n bytes.
+func readVector(b []byte, n int) (vec, rest []byte, err error) {
+	if len(b) < n {
+		return nil, nil, errMalformedClientHello
+	}
+	var length int
+	for _, c := range b[:n] {
+		length = length<<8 | int(c)
+	}
+	b = b[n:]
+	if len(b) < length {
+		return nil, nil, errMalformedClientHello
+	}
+	return b[:length], b[length:], nil
+}
+
+func readUint16s(b []byte) []uint16 {
+	vals := make([]uint16, 0, len(b)/2)
+	for ; len(b) >= 2; b = b[2:] {
+		vals = append(vals, binary.BigEndian.Uint16(b))
+	}
+	return vals
+}
+
+// ParseClientHello parses the ClientHello handshake message (including the
+// 4 bytes header).
+func ParseClientHello(msg []byte) (*ClientHello, error) {
+	// msg_type(1) length(3) legacy_version(2) random(32)
+	if len(msg) < 38 || msg[0] != typeClientHello {
+		return nil, errMalformedClientHello
+	}
+	hello := &ClientHello{Version: binary.BigEndian.Uint16(msg[4:])}
+	b := msg[38:]
+	var vec []byte
+	var err error
+	if _, b, err = readVector(b, 1); err != nil { // legacy_session_id
+		return nil, err
+	}
+	if vec, b, err = readVector(b, 2); err != nil {
+		return nil, err
+	}
+	hello.CipherSuites = readUint16s(vec)
+	if _, b, err = readVector(b, 1); err != nil { // legacy_compression_methods
+		return nil, err
+	}
+	if len(b) == 0 {
+		return hello, nil
+	}
+	if b, _, err = readVector(b, 2); err != nil {
+		return nil, err
+	}
+	for len(b) > 0 {
+		if len(b) < 2 {
+			return nil, errMalformedClientHello
+		}
+		extType := binary.BigEndian.Uint16(b)
+		var data []byte
+		if data, b, err = readVector(b[2:], 2); err != nil {
+			return nil, err
+		}
+		hello.Extensions = append(hello.Extensions, extType)
+		switch extType {
+		case extensionSupportedGroups:
+			if vec, _, err = readVector(data, 2); err == nil {
+				hello.Curves = readUint16s(vec)
+			}
+		case extensionPointFormats:
+			if vec, _, err = readVector(data, 1); err == nil {
+				hello.PointFormats = vec
+			}
+		}
+	}
+	return hello, nil
+}
@@ -0,0 +1,255 @@
+// Package tlsutil records the ServerHello and ClientHello of the tls
+// handshake, which are not exposed by tls.ConnectionState.
+package tlsutil
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"sync/atomic"
+)
+
+const (
+	recordTypeCCS         = 20
+	recordTypeHandshake   = 22
+	typeClientHello       = 1
+	typeServerHello       = 2
+	extensionALPN         = 16
+	extensionSupportedVer = 43
+
+	// maxRecordedBytes is the limit of the bytes read before the ServerHello
+	// is found, the recording is given up if exceeded.
+	maxRecordedBytes = 64 << 10
+)
+
+// helloRetryRequestRandom is the random of the HelloRetryRequest, which is
+// a ServerHello message, see RFC 8446, Section 4.1.3.
+var helloRetryRequestRandom = []byte{
+	0xCF, 0x21, 0xAD, 0x74, 0xE5, 0x9A, 0x61, 0x11,
+	0xBE, 0x1D, 0x8C, 0x02, 0x1E, 0x65, 0xB8, 0x91,
+	0xC2, 0xA2, 0x11, 0x16, 0x7A, 0xBB, 0x8C, 0x5E,
+	0x07, 0x9E, 0x09, 0xE2, 0xC8, 0xA8, 0x33, 0x9C,
+}
+
+// ServerHello is the fields of the ServerHello message.
+type ServerHello struct {
+	// Version is the legacy_version field, which is always TLS 1.2 for
+	// TLS 1.3.
+	Version uint16
+	// SupportedVersion is the version selected by the supported_versions
+	// extension, zero if it's not present.
+	SupportedVersion uint16
+	CipherSuite      uint16
+	// Extensions is the extension types in the order sent by the server.
+	Extensions []uint16
+	// ALPN is the protocol selected by the ALPN extension.
+	ALPN string
+}
+
+// Recorder is the net.Conn which records the ServerHello read from the
+// underlying conn, it's wrapped under the tls conn.
+type Recorder struct {
+	net.Conn
+	// OnClientHello is called with the ClientHello handshake message
+	// written to the underlying conn if it's set.
+	OnClientHello func(msg []byte)
+
+	done      bool
+	records   []byte // the unparsed bytes of the records
+	handshake []byte // the payload of the handshake records
+	hello     atomic.Pointer[ServerHello]
+
+	sent          bool
+	sentRecords   []byte
+	sentHandshake []byte
+}
+
+// NewRecorder returns the Recorder of conn.
+func NewRecorder(conn net.Conn) *Recorder {
+	return &Recorder{Conn: conn}
+}
+
+func (r *Recorder) Read(p []byte) (int, error) {
+	n, err := r.Conn.Read(p)
+	if !r.done && n > 0 {
+		r.feed(p[:n])
+	}
+	return n, err
+}
+
+func (r *Recorder) Write(p []byte) (int, error) {
+	if r.OnClientHello != nil && !r.sent {
+		r.feedSent(p)
+	}
+	return r.Conn.Write(p)
+}
+
+func (r *Recorder) feedSent(b []byte) {
+	r.sentRecords = append(r.sentRecords, b...)
+	for len(r.sentRecords) >= 5 && !r.sent {
+		length := int(binary.BigEndian.Uint16(r.sentRecords[3:5]))
+		if len(r.sentRecords) < 5+length {
+			break
+		}
+		if r.sentRecords[0] != recordTypeHandshake {
+			r.finishSent(nil)
+			return
+		}
+		r.sentHandshake = append(r.sentHandshake, r.sentRecords[5:5+length]...)
+		r.sentRecords = r.sentRecords[5+length:]
+		if len(r.sentHandshake) < 4 {
+			continue
+		}
+		if r.sentHandshake[0] != typeClientHello {
+			r.finishSent(nil)
+			return
+		}
+		msgLen := int(r.sentHandshake[1])<<16 | int(r.sentHandshake[2])<<8 | int(r.sentHandshake[3])
+		if len(r.sentHandshake) >= 4+msgLen {
+			r.finishSent(r.sentHandshake[:4+msgLen])
+		}
+	}
+	if len(r.sentRecords)+len(r.sentHandshake) > maxRecordedBytes {
+		r.finishSent(nil)
+	}
+}
+
+func (r *Recorder) finishSent(msg []byte) {
+	r.sent = true
+	r.sentRecords, r.sentHandshake = nil, nil
+	if msg != nil {
+		r.OnClientHello(msg)
+	}
+}
+
+// NetConn returns the underlying conn.
+func (r *Recorder) NetConn() net.Conn {
+	return r.Conn
+}
+
+// ServerHello returns the recorded ServerHello, nil if it's not received.
+func (r *Recorder) ServerHello() *ServerHello {
+	return r.hello.Load()
+}
+
+func (r *Recorder) feed(b []byte) {
+	r.records = append(r.records, b...)
+	for len(r.records) >= 5 && !r.done {
+		length := int(binary.BigEndian.Uint16(r.records[3:5]))
+		if len(r.records) < 5+length {
+			break
+		}
+		if r.records[0] == recordTypeCCS {
+			// the compatibility ChangeCipherSpec after HelloRetryRequest.
+			r.records = r.records[5+length:]
+			continue
+		}
+		if r.records[0] != recordTypeHandshake {
+			// the ServerHello must be the first handshake message.
+			r.finish(nil)
+			return
+		}
+		r.handshake = append(r.handshake, r.records[5:5+length]...)
+		r.records = r.records[5+length:]
+		r.parseHandshake()
+	}
+	if len(r.records)+len(r.handshake) > maxRecordedBytes {
+		r.finish(nil)
+	}
+}
+
+func (r *Recorder) parseHandshake() {
+	for len(r.handshake) >= 4 && !r.done {
+		length := int(r.handshake[1])<<16 | int(r.handshake[2])<<8 | int(r.handshake[3])
+		if len(r.handshake) < 4+length {
+			return
+		}
+		msgType, msg := r.handshake[0], r.handshake[4:4+length]
+		r.handshake = r.handshake[4+length:]
+		if msgType != typeServerHello {
+			r.finish(nil)
+			return
+		}
+		hello, isHRR := parseServerHello(msg)
+		if hello == nil || !isHRR {
+			r.finish(hello)
+		}
+	}
+}
+
+func (r *Recorder) finish(hello *ServerHello) {
+	r.done = true
+	r.records, r.handshake = nil, nil
+	if hello != nil {
+		r.hello.Store(hello)
+	}
+}
+
+func parseServerHello(msg []byte) (hello *ServerHello, isHRR bool) {
+	// legacy_version(2) random(32) legacy_session_id<0..32>
+	if len(msg) < 35 {
+		return nil, false
+	}
+	hello = &ServerHello{Version: binary.BigEndian.Uint16(msg)}
+	isHRR = bytes.Equal(msg[2:34], helloRetryRequestRandom)
+	msg = msg[34:]
+	sessionIDLen := int(msg[0])
+	// cipher_suite(2) legacy_compression_method(1)
+	if len(msg) < 1+sessionIDLen+3 {
+		return nil, false
+	}
+	msg = msg[1+sessionIDLen:]
+	hello.CipherSuite = binary.BigEndian.Uint16(msg)
+	msg = msg[3:]
+	if len(msg) < 2 {
+		return hello, isHRR
+	}
+	extensionsLen := int(binary.BigEndian.Uint16(msg))
+	msg = msg[2:]
+	if len(msg) < extensionsLen {
+		return nil, false
+	}
+	msg = msg[:extensionsLen]
+	for len(msg) >= 4 {
+		extType := binary.BigEndian.Uint16(msg)
+		extLen := int(binary.BigEndian.Uint16(msg[2:]))
+		if len(msg) < 4+extLen {
+			return nil, false
+		}
+		data := msg[4 : 4+extLen]
+		msg = msg[4+extLen:]
+		hello.Extensions = append(hello.Extensions, extType)
+		switch extType {
+		case extensionSupportedVer:
+			if len(data) == 2 {
+				hello.SupportedVersion = binary.BigEndian.Uint16(data)
+			}
+		case extensionALPN:
+			// protocol_name_list<2..2^16-1> with a single protocol.
+			if len(data) >= 3 && int(data[2]) == len(data)-3 {
+				hello.ALPN = string(data[3:])
+			}
+		}
+	}
+	return hello, isHRR
+}
+
+type netConner interface {
+	NetConn() net.Conn
+}
+
+// ServerHelloOf returns the ServerHello recorded by the Recorder under conn
+// (e.g. *tls.Conn), nil if conn is not wrapped on a Recorder.
+func ServerHelloOf(conn net.Conn) *ServerHello {
+	for conn != nil {
+		if r, ok := conn.(*Recorder); ok {
+			return r.ServerHello()
+		}
+		nc, ok := conn.(netConner)
+		if !ok {
+			return nil
+		}
+		conn = nc.NetConn()
+	}
+	return nil
+}
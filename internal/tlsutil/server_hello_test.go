@@ -0,0 +1,80 @@
+package tlsutil
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+
+	"github.com/luoxk/restys/internal/tests"
+)
+
+func serverHelloMsg(random []byte, extensions ...[]byte) []byte {
+	body := []byte{0x03, 0x03}
+	body = append(body, random...)
+	body = append(body, 0)          // legacy_session_id
+	body = append(body, 0x13, 0x01) // cipher_suite
+	body = append(body, 0)          // legacy_compression_method
+	var exts []byte
+	for _, ext := range extensions {
+		exts = append(exts, ext...)
+	}
+	body = binary.BigEndian.AppendUint16(body, uint16(len(exts)))
+	body = append(body, exts...)
+	return append([]byte{typeServerHello, 0, byte(len(body) >> 8), byte(len(body))}, body...)
+}
+
+func extension(typ uint16, data ...byte) []byte {
+	b := binary.BigEndian.AppendUint16(nil, typ)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(data)))
+	return append(b, data...)
+}
+
+func record(typ byte, payload []byte) []byte {
+	return append([]byte{typ, 0x03, 0x03, byte(len(payload) >> 8), byte(len(payload))}, payload...)
+}
+
+func TestRecorder(t *testing.T) {
+	hrr := serverHelloMsg(helloRetryRequestRandom, extension(43, 0x03, 0x04))
+	hello := serverHelloMsg(make([]byte, 32),
+		extension(43, 0x03, 0x04),
+		extension(16, 0x00, 0x03, 0x02, 'h', '2'),
+		extension(51, 0x00, 0x1d))
+	var stream []byte
+	stream = append(stream, record(recordTypeHandshake, hrr)...)
+	stream = append(stream, record(recordTypeCCS, []byte{1})...)
+	// the ServerHello is split into two records.
+	stream = append(stream, record(recordTypeHandshake, hello[:10])...)
+	stream = append(stream, record(recordTypeHandshake, hello[10:])...)
+
+	client, server := net.Pipe()
+	defer client.Close()
+	go func() {
+		// write byte by byte to test the partial records.
+		for _, b := range stream {
+			server.Write([]byte{b})
+		}
+		server.Close()
+	}()
+	r := NewRecorder(client)
+	var got bytes.Buffer
+	buf := make([]byte, 3)
+	for {
+		n, err := r.Read(buf)
+		got.Write(buf[:n])
+		if err != nil {
+			break
+		}
+	}
+	tests.AssertEqual(t, stream, got.Bytes())
+
+	h := ServerHelloOf(r)
+	tests.AssertNotNil(t, h)
+	tests.AssertEqual(t, uint16(0x0303), h.Version)
+	tests.AssertEqual(t, uint16(0x0304), h.SupportedVersion)
+	tests.AssertEqual(t, uint16(0x1301), h.CipherSuite)
+	tests.AssertEqual(t, []uint16{43, 16, 51}, h.Extensions)
+	tests.AssertEqual(t, "h2", h.ALPN)
+
+	tests.AssertIsNil(t, ServerHelloOf(client))
+}
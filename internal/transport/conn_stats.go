@@ -0,0 +1,106 @@
+package transport
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// HostConnStats is the statistics of the requests and the dials to a host.
+type HostConnStats struct {
+	// Dials is the number of connections dialed to the host.
+	Dials uint64
+	// Requests is the number of requests sent to the host.
+	Requests uint64
+	// InFlight is the number of requests whose response body is not yet
+	// read to the end or closed.
+	InFlight int64
+}
+
+type hostConnStats struct {
+	dials    atomic.Uint64
+	requests atomic.Uint64
+	inFlight atomic.Int64
+}
+
+// maxConnStatsHosts is the number of the hosts tracked by ConnStats before
+// the ones without in-flight requests are evicted.
+const maxConnStatsHosts = 1024
+
+// ConnStats records the statistics of the connection pools per host
+// ("host:port"), which is shared by the HTTP/1, HTTP/2 and HTTP/3
+// transports, the methods are no-op on the nil ConnStats. Once more than
+// maxConnStatsHosts hosts are tracked, the hosts without in-flight
+// requests are evicted, so the memory is bounded if many hosts are
+// requested.
+type ConnStats struct {
+	mu    sync.Mutex
+	hosts map[string]*hostConnStats
+}
+
+// NewConnStats creates an empty ConnStats.
+func NewConnStats() *ConnStats {
+	return &ConnStats{hosts: make(map[string]*hostConnStats)}
+}
+
+// hostLocked returns the statistics of addr, s.mu must be held.
+func (s *ConnStats) hostLocked(addr string) *hostConnStats {
+	h, ok := s.hosts[addr]
+	if !ok {
+		if len(s.hosts) >= maxConnStatsHosts {
+			for a, hs := range s.hosts {
+				if hs.inFlight.Load() <= 0 {
+					delete(s.hosts, a)
+				}
+			}
+		}
+		h = &hostConnStats{}
+		s.hosts[addr] = h
+	}
+	return h
+}
+
+// Dialed records a connection dialed to addr.
+func (s *ConnStats) Dialed(addr string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hostLocked(addr).dials.Add(1)
+}
+
+// StartRequest records a request sent to addr, the returned done must be
+// called once the request is done.
+func (s *ConnStats) StartRequest(addr string) (done func()) {
+	if s == nil {
+		return func() {}
+	}
+	s.mu.Lock()
+	// counted in the lock so that h is not evicted in between.
+	h := s.hostLocked(addr)
+	h.requests.Add(1)
+	h.inFlight.Add(1)
+	s.mu.Unlock()
+	var once sync.Once
+	return func() {
+		once.Do(func() { h.inFlight.Add(-1) })
+	}
+}
+
+// Snapshot returns the statistics of the hosts.
+func (s *ConnStats) Snapshot() map[string]HostConnStats {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := make(map[string]HostConnStats, len(s.hosts))
+	for addr, h := range s.hosts {
+		stats[addr] = HostConnStats{
+			Dials:    h.dials.Load(),
+			Requests: h.requests.Load(),
+			InFlight: h.inFlight.Load(),
+		}
+	}
+	return stats
+}
@@ -3,12 +3,20 @@ package transport
 import (
 	"context"
 	"crypto/tls"
+	"errors"
+	"fmt"
 	"net"
 	"net/http"
+	"net/http/httptrace"
+	"net/netip"
 	"net/url"
+	"slices"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/luoxk/restys/internal/dump"
+	"github.com/luoxk/restys/internal/tlsutil"
 )
 
 // Options is transport's options.
@@ -54,6 +62,73 @@ type Options struct {
 	// past the TLS handshake.
 	DialTLSContext func(ctx context.Context, network, addr string) (net.Conn, error)
 
+	// Resolver optionally specifies the DNS resolver used to look up host
+	// names when dialing with the default dial functions (including HTTP3),
+	// if nil, the system resolver is used.
+	Resolver *net.Resolver
+
+	// LookupNetIP optionally specifies the function to look up the IP
+	// addresses of host when dialing with the default dial functions, it
+	// takes precedence over the default lookup of Resolver, e.g. to serve
+	// the addresses from a DNS cache.
+	LookupNetIP func(ctx context.Context, resolver *net.Resolver, host string) ([]netip.Addr, error)
+
+	// SelectAddrs optionally reorders or filters the resolved IP addresses
+	// of host before dialing with the default dial functions, the addresses
+	// are tried in the returned order. addrs is a copy which can be
+	// modified in place, the addresses cached by LookupNetIP are unchanged.
+	SelectAddrs func(host string, addrs []netip.Addr) []netip.Addr
+
+	// ForbiddenNetworks optionally specifies the networks that the default
+	// dial functions refuse to connect to, which is checked against the
+	// resolved IP address, unless it is in AllowedNetworks.
+	ForbiddenNetworks []netip.Prefix
+
+	// AllowedNetworks specifies the exceptions of ForbiddenNetworks.
+	AllowedNetworks []netip.Prefix
+
+	// DialTimeout is the maximum amount of time a dial of the default
+	// dial functions will wait for a connect to complete, zero means no
+	// timeout (the context deadline still applies).
+	DialTimeout time.Duration
+
+	// TCPKeepAlive specifies the interval between TCP keep-alive probes of
+	// the connections dialed by the default dial functions, zero means the
+	// default of package net (15 seconds), negative disables keep-alive.
+	TCPKeepAlive time.Duration
+
+	// DisableTCPNoDelay, if true, enables Nagle's algorithm on the TCP
+	// connections dialed by the default dial functions (TCP_NODELAY is
+	// set by default).
+	DisableTCPNoDelay bool
+
+	// EnableTCPFastOpen, if true, enables TCP Fast Open on the connections
+	// dialed by the default dial functions, where the platform supports it
+	// (Linux 4.11+), otherwise the regular handshake is used.
+	EnableTCPFastOpen bool
+
+	// EnableMultipathTCP, if true, uses Multipath TCP for the connections
+	// dialed by the default dial functions, where the platform and the
+	// server support it, otherwise it falls back to TCP.
+	EnableMultipathTCP bool
+
+	// LocalIP optionally specifies the local IP address of the TCP and
+	// UDP (HTTP3) sockets created by the default dial functions, for the
+	// hosts with multiple egress IP addresses, only the remote addresses
+	// of the same family are dialed.
+	LocalIP netip.Addr
+
+	// Interface optionally specifies the name of the network interface
+	// which the TCP and UDP (HTTP3) sockets created by the default dial
+	// functions are bound to (SO_BINDTODEVICE on Linux, IP_BOUND_IF on
+	// darwin), dialing fails on the other platforms.
+	Interface string
+
+	// Hosts optionally maps "host" or "host:port" to a fixed IP address
+	// when dialing with the default dial functions, which bypasses DNS.
+	// The keys are lower case.
+	Hosts map[string]netip.Addr
+
 	// TLSHandshakeContext specifies an optional dial function for tls handshake,
 	// it works even if a proxy is set, can be used to customize the tls fingerprint.
 	TLSHandshakeContext func(ctx context.Context, addr string, plainConn net.Conn) (conn net.Conn, tlsState *tls.ConnectionState, err error)
@@ -68,6 +143,17 @@ type Options struct {
 	// wait for a TLS handshake. Zero means no timeout.
 	TLSHandshakeTimeout time.Duration
 
+	// VerifyConnection optionally verifies the connection state of the tls
+	// connection to host after the handshake, including the custom tls
+	// handshake (TLSHandshakeContext) and HTTP3. If it returns an error,
+	// the connection is closed and the request fails with the error.
+	VerifyConnection func(host string, state *tls.ConnectionState) error
+
+	// OnClientHello optionally receives the ClientHello handshake message
+	// sent to host by the tls handshake, including the custom tls handshake
+	// (TLSHandshakeContext), it's not called for HTTP3.
+	OnClientHello func(host string, msg []byte)
+
 	// DisableKeepAlives, if true, disables HTTP keep-alives and
 	// will only use the connection to the server for a single
 	// HTTP request.
@@ -116,6 +202,15 @@ type Options struct {
 	// Zero means no limit.
 	IdleConnTimeout time.Duration
 
+	// MaxConnAge is the maximum amount of time a connection may be
+	// reused since it's established, the connection is not used for
+	// new requests after that, and is closed once the in-flight
+	// requests are done, which forces periodic reconnects (e.g. to
+	// re-randomize the TLS fingerprint and session tickets). It applies
+	// to HTTP/1.1, HTTP/2 and HTTP/3.
+	// Zero means no limit.
+	MaxConnAge time.Duration
+
 	// ResponseHeaderTimeout, if non-zero, specifies the amount of
 	// time to wait for a server's response headers after fully
 	// writing the request (including its body, if any). This
@@ -162,6 +257,10 @@ type Options struct {
 	// If zero, a default (currently 4KB) is used.
 	ReadBufferSize int
 
+	// ConnStats optionally records the statistics of the connection pools
+	// per host.
+	ConnStats *ConnStats
+
 	// Debugf is the optional debug function.
 	Debugf func(format string, v ...interface{})
 
@@ -177,5 +276,265 @@ func (o Options) Clone() Options {
 		oo.Dump = o.Dump.Clone()
 		go oo.Dump.Start()
 	}
+	if o.ConnStats != nil {
+		// the clone has its own connection pools.
+		oo.ConnStats = NewConnStats()
+	}
 	return oo
 }
+
+// Dialer returns the net.Dialer which is used to create TCP connections
+// when DialContext is nil.
+func (o *Options) Dialer() *net.Dialer {
+	d := &net.Dialer{
+		Timeout:   o.DialTimeout,
+		KeepAlive: o.TCPKeepAlive,
+		Resolver:  o.Resolver,
+	}
+	if o.EnableMultipathTCP {
+		d.SetMultipathTCP(true)
+	}
+	if o.LocalIP.IsValid() {
+		d.LocalAddr = &net.TCPAddr{IP: o.LocalIP.AsSlice(), Zone: o.LocalIP.Zone()}
+	}
+	if len(o.ForbiddenNetworks) > 0 || o.EnableTCPFastOpen || o.Interface != "" {
+		d.Control = func(network, address string, c syscall.RawConn) error {
+			if len(o.ForbiddenNetworks) > 0 {
+				addrport, err := netip.ParseAddrPort(address)
+				if err != nil {
+					return err
+				}
+				if err = o.CheckAddr(addrport.Addr()); err != nil {
+					return err
+				}
+			}
+			if o.Interface != "" {
+				if err := bindInterface(network, c, o.Interface); err != nil {
+					return err
+				}
+			}
+			if o.EnableTCPFastOpen {
+				setTCPFastOpen(c)
+			}
+			return nil
+		}
+	}
+	return d
+}
+
+// ListenUDP creates the UDP socket which is used to dial HTTP3
+// connections, it's bound to LocalIP and Interface if set.
+func (o *Options) ListenUDP(ctx context.Context) (net.PacketConn, error) {
+	var lc net.ListenConfig
+	if o.Interface != "" {
+		lc.Control = func(network, address string, c syscall.RawConn) error {
+			return bindInterface(network, c, o.Interface)
+		}
+	}
+	addr := ":0"
+	if o.LocalIP.IsValid() {
+		addr = net.JoinHostPort(o.LocalIP.String(), "0")
+	}
+	return lc.ListenPacket(ctx, "udp", addr)
+}
+
+// ErrForbiddenAddress is returned when dialing an address which is in the
+// ForbiddenNetworks.
+var ErrForbiddenAddress = errors.New("forbidden address")
+
+// CheckAddr returns ErrForbiddenAddress if ip is in ForbiddenNetworks and
+// not in AllowedNetworks.
+func (o *Options) CheckAddr(ip netip.Addr) error {
+	ip = ip.Unmap().WithZone("")
+	for _, prefix := range o.AllowedNetworks {
+		if prefix.Contains(ip) {
+			return nil
+		}
+	}
+	for _, prefix := range o.ForbiddenNetworks {
+		if prefix.Contains(ip) {
+			return fmt.Errorf("%w %s (in %s)", ErrForbiddenAddress, ip, prefix)
+		}
+	}
+	return nil
+}
+
+// CheckConnection verifies the connection state of the tls connection to
+// host with VerifyConnection if it is set.
+func (o *Options) CheckConnection(host string, state *tls.ConnectionState) error {
+	if o.VerifyConnection == nil || state == nil {
+		return nil
+	}
+	return o.VerifyConnection(host, state)
+}
+
+// NewTLSRecorder wraps the plain conn of the tls handshake with host, which
+// records the ServerHello, and the ClientHello if OnClientHello is set.
+func (o *Options) NewTLSRecorder(conn net.Conn, host string) net.Conn {
+	r := tlsutil.NewRecorder(conn)
+	if fn := o.OnClientHello; fn != nil {
+		r.OnClientHello = func(msg []byte) {
+			fn(host, msg)
+		}
+	}
+	return r
+}
+
+// DialDefault dials the TCP connection with the Dialer, the host name is
+// resolved with LookupNetIP if it is set, and the addresses (selected by
+// SelectAddrs if it is set) are tried in order until one of them can be
+// connected.
+func (o *Options) DialDefault(ctx context.Context, network, addr string) (net.Conn, error) {
+	conn, err := o.dialDefault(ctx, network, addr)
+	if err == nil && o.DisableTCPNoDelay {
+		if tc, ok := conn.(*net.TCPConn); ok {
+			tc.SetNoDelay(false)
+		}
+	}
+	return conn, err
+}
+
+func (o *Options) dialDefault(ctx context.Context, network, addr string) (net.Conn, error) {
+	d := o.Dialer()
+	if o.LookupNetIP == nil && o.SelectAddrs == nil && len(o.Hosts) == 0 {
+		return d.DialContext(ctx, network, addr)
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return d.DialContext(ctx, network, addr)
+	}
+	if _, err := netip.ParseAddr(host); err == nil {
+		return d.DialContext(ctx, network, addr)
+	}
+	ip, ok := o.lookupHosts(host, port)
+	if !ok && o.LookupNetIP == nil && o.SelectAddrs == nil {
+		return d.DialContext(ctx, network, addr)
+	}
+
+	// the lookup bypasses net.Dialer, report it to the trace by ourselves.
+	trace := httptrace.ContextClientTrace(ctx)
+	if trace != nil && trace.DNSStart != nil {
+		trace.DNSStart(httptrace.DNSStartInfo{Host: host})
+	}
+	var ips []netip.Addr
+	if ok {
+		ips = []netip.Addr{ip}
+	} else {
+		ips, err = o.lookupNetIP(ctx, host)
+	}
+	if trace != nil && trace.DNSDone != nil {
+		addrs := make([]net.IPAddr, len(ips))
+		for i, ip := range ips {
+			addrs[i] = net.IPAddr{IP: ip.AsSlice(), Zone: ip.Zone()}
+		}
+		trace.DNSDone(httptrace.DNSDoneInfo{Addrs: addrs, Err: err})
+	}
+	if err != nil {
+		return nil, err
+	}
+	if o.SelectAddrs != nil {
+		ips = o.SelectAddrs(host, slices.Clone(ips))
+	}
+	var firstErr error
+	for _, ip := range ips {
+		if network == "tcp4" && !ip.Is4() || network == "tcp6" && !ip.Is6() {
+			continue
+		}
+		if o.LocalIP.IsValid() && ip.Unmap().Is4() != o.LocalIP.Unmap().Is4() {
+			continue
+		}
+		conn, err := d.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	if firstErr == nil {
+		firstErr = &net.DNSError{Err: "no suitable address found", Name: host}
+	}
+	return nil, firstErr
+}
+
+// ResolveUDPAddr resolves the UDP address with LookupNetIP or Resolver,
+// it is used to dial HTTP3 connections.
+func (o *Options) ResolveUDPAddr(ctx context.Context, addr string) (*net.UDPAddr, error) {
+	if o.Resolver == nil && o.LookupNetIP == nil && o.SelectAddrs == nil && len(o.Hosts) == 0 && len(o.ForbiddenNetworks) == 0 && !o.LocalIP.IsValid() {
+		return net.ResolveUDPAddr("udp", addr)
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	resolver := o.Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	portnum, err := resolver.LookupPort(ctx, "udp", port)
+	if err != nil {
+		return nil, err
+	}
+	var ips []netip.Addr
+	var lookupErr error
+	if ip, ok := o.lookupHosts(host, port); ok {
+		ips = []netip.Addr{ip}
+	} else if ip, perr := netip.ParseAddr(host); perr == nil {
+		ips = []netip.Addr{ip}
+	} else {
+		ips, lookupErr = o.lookupNetIP(ctx, host)
+		if lookupErr == nil && o.SelectAddrs != nil {
+			ips = o.SelectAddrs(host, slices.Clone(ips))
+		}
+	}
+	if lookupErr != nil {
+		return nil, lookupErr
+	}
+	if o.LocalIP.IsValid() {
+		// the UDP socket bound to LocalIP can't send to the other family.
+		var matched []netip.Addr
+		for _, ip := range ips {
+			if ip.Unmap().Is4() == o.LocalIP.Unmap().Is4() {
+				matched = append(matched, ip)
+			}
+		}
+		ips = matched
+	}
+	if len(ips) == 0 {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	if len(o.ForbiddenNetworks) > 0 {
+		if err = o.CheckAddr(ips[0]); err != nil {
+			return nil, err
+		}
+	}
+	return net.UDPAddrFromAddrPort(netip.AddrPortFrom(ips[0].Unmap(), uint16(portnum))), nil
+}
+
+// lookupNetIP looks up the IP addresses of host with LookupNetIP or Resolver.
+func (o *Options) lookupNetIP(ctx context.Context, host string) ([]netip.Addr, error) {
+	if o.LookupNetIP != nil {
+		return o.LookupNetIP(ctx, o.Resolver, host)
+	}
+	resolver := o.Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	return resolver.LookupNetIP(ctx, "ip", host)
+}
+
+// lookupHosts returns the IP address of host in Hosts.
+func (o *Options) lookupHosts(host, port string) (netip.Addr, bool) {
+	if len(o.Hosts) == 0 {
+		return netip.Addr{}, false
+	}
+	host = strings.ToLower(host)
+	if ip, ok := o.Hosts[net.JoinHostPort(host, port)]; ok {
+		return ip, true
+	}
+	ip, ok := o.Hosts[host]
+	return ip, ok
+}
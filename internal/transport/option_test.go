@@ -0,0 +1,35 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/luoxk/restys/internal/tests"
+)
+
+func TestResolveUDPAddr(t *testing.T) {
+	var lookupErr error
+	o := &Options{
+		LookupNetIP: func(ctx context.Context, resolver *net.Resolver, host string) ([]netip.Addr, error) {
+			if lookupErr != nil {
+				return nil, lookupErr
+			}
+			return []netip.Addr{netip.MustParseAddr("127.0.0.2")}, nil
+		},
+	}
+	addr, err := o.ResolveUDPAddr(context.Background(), "example.test:443")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, "127.0.0.2:443", addr.String())
+
+	// the lookup error is returned as is.
+	lookupErr = context.DeadlineExceeded
+	_, err = o.ResolveUDPAddr(context.Background(), "example.test:443")
+	tests.AssertEqual(t, true, errors.Is(err, context.DeadlineExceeded))
+
+	addr, err = o.ResolveUDPAddr(context.Background(), "127.0.0.3:443")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, "127.0.0.3:443", addr.String())
+}
@@ -0,0 +1,41 @@
+//go:build darwin
+
+package transport
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"syscall"
+)
+
+const (
+	ipBoundIf   = 25  // IP_BOUND_IF
+	ipv6BoundIf = 125 // IPV6_BOUND_IF
+)
+
+// setTCPFastOpen is a no-op since TCP Fast Open on connect is only
+// supported on Linux.
+func setTCPFastOpen(c syscall.RawConn) {}
+
+// bindInterface binds the socket to the network interface with IP_BOUND_IF
+// or IPV6_BOUND_IF.
+func bindInterface(network string, c syscall.RawConn, name string) error {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return fmt.Errorf("failed to bind to interface %s: %w", name, err)
+	}
+	if cerr := c.Control(func(fd uintptr) {
+		if strings.HasSuffix(network, "6") {
+			err = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IPV6, ipv6BoundIf, iface.Index)
+		} else {
+			err = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, ipBoundIf, iface.Index)
+		}
+	}); cerr != nil {
+		return cerr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to bind to interface %s: %w", name, err)
+	}
+	return nil
+}
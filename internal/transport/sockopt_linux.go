@@ -0,0 +1,37 @@
+//go:build linux
+
+package transport
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// tcpFastOpenConnect is TCP_FASTOPEN_CONNECT (Linux 4.11+), which makes
+// connect() defer the SYN until the first write, so the data is sent in
+// the SYN if a Fast Open cookie is cached.
+const tcpFastOpenConnect = 30
+
+// setTCPFastOpen enables TCP Fast Open on the socket, the error is ignored
+// so the connection falls back to the regular handshake if the kernel does
+// not support it.
+func setTCPFastOpen(c syscall.RawConn) {
+	c.Control(func(fd uintptr) {
+		syscall.SetsockoptInt(int(fd), syscall.IPPROTO_TCP, tcpFastOpenConnect, 1)
+	})
+}
+
+// bindInterface binds the socket to the network interface with
+// SO_BINDTODEVICE, which requires CAP_NET_RAW before Linux 5.7.
+func bindInterface(network string, c syscall.RawConn, name string) error {
+	var err error
+	if cerr := c.Control(func(fd uintptr) {
+		err = syscall.SetsockoptString(int(fd), syscall.SOL_SOCKET, syscall.SO_BINDTODEVICE, name)
+	}); cerr != nil {
+		return cerr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to bind to interface %s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+//go:build !linux && !darwin
+
+package transport
+
+import (
+	"fmt"
+	"runtime"
+	"syscall"
+)
+
+// setTCPFastOpen is a no-op since TCP Fast Open on connect is only
+// supported on Linux.
+func setTCPFastOpen(c syscall.RawConn) {}
+
+// bindInterface fails since binding to the network interface is only
+// supported on Linux and darwin.
+func bindInterface(network string, c syscall.RawConn, name string) error {
+	return fmt.Errorf("failed to bind to interface %s: not supported on %s", name, runtime.GOOS)
+}
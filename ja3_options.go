@@ -0,0 +1,83 @@
+package restys
+
+import (
+	"sort"
+
+	utls "github.com/refraction-networking/utls"
+)
+
+// Ja3Options is the options of building the tls fingerprint from the ja3
+// string, see Client.SetJa3Options.
+type Ja3Options struct {
+	// DisableGREASE disables injecting the GREASE values into the cipher
+	// suites, curves and extensions, the GREASE values in the ja3 string are
+	// still sent.
+	DisableGREASE bool
+	// GREASEExtensionPositions is the indexes in the extensions of the ja3
+	// string where the GREASE extensions are injected, a negative index
+	// counts from the end (-1 means appending to the end), default is
+	// {0, -1} like Chrome. It's ignored if DisableGREASE is true.
+	GREASEExtensionPositions []int
+	// ShuffleExtensions shuffles the order of the extensions for each
+	// connection like Chrome's extension permutation, the GREASE, padding
+	// and pre_shared_key extensions are kept in place.
+	ShuffleExtensions bool
+}
+
+// defaultGREASEExtensionPositions injects the GREASE extensions at the
+// beginning and the end of the extensions like Chrome.
+var defaultGREASEExtensionPositions = []int{0, -1}
+
+func (opts *Ja3Options) greaseExtensionPositions() []int {
+	switch {
+	case opts == nil:
+		return defaultGREASEExtensionPositions
+	case opts.DisableGREASE:
+		return nil
+	case opts.GREASEExtensionPositions == nil:
+		return defaultGREASEExtensionPositions
+	}
+	return opts.GREASEExtensionPositions
+}
+
+func isGREASEExtension(ext utls.TLSExtension) bool {
+	_, ok := ext.(*utls.UtlsGREASEExtension)
+	return ok
+}
+
+// injectGREASEExtensions inserts the GREASE extensions at the positions of
+// exts, the position next to a GREASE extension already is skipped.
+func injectGREASEExtensions(exts []utls.TLSExtension, positions []int) []utls.TLSExtension {
+	n := len(exts)
+	if n == 0 {
+		return exts
+	}
+	var indexes []int
+	for _, pos := range positions {
+		if pos < 0 {
+			pos += n + 1
+		}
+		if pos < 0 || pos > n {
+			continue
+		}
+		if (pos < n && isGREASEExtension(exts[pos])) || (pos > 0 && isGREASEExtension(exts[pos-1])) {
+			continue
+		}
+		indexes = append(indexes, pos)
+	}
+	// insert from the end so that the indexes are not shifted.
+	sort.Sort(sort.Reverse(sort.IntSlice(indexes)))
+	for _, i := range indexes {
+		exts = append(exts[:i], append([]utls.TLSExtension{&utls.UtlsGREASEExtension{}}, exts[i:]...)...)
+	}
+	return exts
+}
+
+// SetJa3Options set the options of building the tls fingerprint from the
+// ja3 string, which controls the GREASE injection and the extension
+// permutation, it should be called before SetJa3WithStr. Pass nil to
+// restore the default options.
+func (c *Client) SetJa3Options(opts *Ja3Options) *Client {
+	c.ja3Options = opts
+	return c
+}
@@ -0,0 +1,64 @@
+package restys
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+
+	utls "github.com/refraction-networking/utls"
+
+	"github.com/luoxk/restys/internal/tests"
+)
+
+func TestJa3Options(t *testing.T) {
+	ja3 := "771,4865-4866-4867-49195-49199,0-23-65281-10-11-35-16-5-13-18-51-45-43,29-23-24,0"
+
+	spec, err := createSpecWithJa3Str(ja3, nil)
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, uint16(utls.GREASE_PLACEHOLDER), spec.CipherSuites[0])
+	tests.AssertEqual(t, 15, len(spec.Extensions))
+	tests.AssertEqual(t, true, isGREASEExtension(spec.Extensions[0]))
+	tests.AssertEqual(t, true, isGREASEExtension(spec.Extensions[14]))
+
+	spec, err = createSpecWithJa3Str(ja3, &Ja3Options{DisableGREASE: true})
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, uint16(4865), spec.CipherSuites[0])
+	tests.AssertEqual(t, 13, len(spec.Extensions))
+	for _, ext := range spec.Extensions {
+		tests.AssertEqual(t, false, isGREASEExtension(ext))
+	}
+
+	spec, err = createSpecWithJa3Str(ja3, &Ja3Options{GREASEExtensionPositions: []int{2, -2, 100}})
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, 15, len(spec.Extensions))
+	tests.AssertEqual(t, true, isGREASEExtension(spec.Extensions[2]))
+	tests.AssertEqual(t, true, isGREASEExtension(spec.Extensions[13]))
+
+	c := tc().SetJa3Options(&Ja3Options{ShuffleExtensions: true}).SetJa3WithStr(ja3)
+	tests.AssertEqual(t, true, len(ja3Orders(t, c, 5)) > 1)
+}
+
+// ja3Orders returns the distinct ja3 strings of n connections of c.
+func ja3Orders(t *testing.T, c *Client, n int) map[string]bool {
+	var buf bytes.Buffer
+	c.EnableClientHelloDump(&buf).DisableKeepAlives()
+	for i := 0; i < n; i++ {
+		resp, err := c.R().Get("/")
+		assertSuccess(t, resp, err)
+	}
+	orders := map[string]bool{}
+	for _, m := range regexp.MustCompile(`ja3: +(\S+)`).FindAllStringSubmatch(buf.String(), -1) {
+		orders[m[1]] = true
+	}
+	return orders
+}
+
+func TestEnableTLSExtensionShuffle(t *testing.T) {
+	ja3 := "771,4865-4866-4867-49195-49199,23-65281-10-11-35-16-5-13-18-51-45-43,29-23-24,0"
+	c := tc().SetJa3WithStr(ja3)
+	tests.AssertEqual(t, 1, len(ja3Orders(t, c, 3)))
+	c.EnableTLSExtensionShuffle()
+	tests.AssertEqual(t, true, len(ja3Orders(t, c, 5)) > 1)
+	c.DisableTLSExtensionShuffle()
+	tests.AssertEqual(t, 1, len(ja3Orders(t, c, 3)))
+}
@@ -0,0 +1,349 @@
+package restys
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	utls "github.com/refraction-networking/utls"
+)
+
+// ja4Presets is the client hellos which the hashed JA4 strings are resolved
+// against, as the hashes can't be reversed to the cipher suites and the
+// extensions.
+var ja4Presets = []utls.ClientHelloID{
+	utls.HelloChrome_120,
+	utls.HelloChrome_120_PQ,
+	utls.HelloChrome_115_PQ,
+	utls.HelloChrome_106_Shuffle,
+	utls.HelloChrome_102,
+	utls.HelloChrome_100,
+	utls.HelloChrome_96,
+	utls.HelloChrome_87,
+	utls.HelloChrome_83,
+	utls.HelloFirefox_120,
+	utls.HelloFirefox_105,
+	utls.HelloFirefox_102,
+	utls.HelloFirefox_99,
+	utls.HelloEdge_106,
+	utls.HelloEdge_85,
+	utls.HelloSafari_16_0,
+	utls.HelloIOS_14,
+	utls.HelloIOS_13,
+	utls.Hello360_11_0,
+	utls.Hello360_7_5,
+	utls.HelloQQ_11_1,
+	utls.HelloAndroid_11_OkHttp,
+}
+
+// ja4 is the parsed JA4 string.
+type ja4 struct {
+	version  uint16
+	sni      bool
+	ciphers  int
+	exts     int
+	alpn     string
+	hashed   bool
+	cipherB  string   // hash of the cipher suites if hashed
+	extC     string   // hash of the extensions and signature algorithms if hashed
+	cipherR  []uint16 // raw cipher suites if not hashed
+	extR     []uint16 // raw extensions if not hashed
+	sigAlgsR []uint16 // raw signature algorithms if not hashed
+}
+
+func parseJa4Hex(s string) ([]uint16, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var vals []uint16
+	for _, v := range strings.Split(s, ",") {
+		n, err := strconv.ParseUint(v, 16, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ja4 value %q", v)
+		}
+		vals = append(vals, uint16(n))
+	}
+	return vals, nil
+}
+
+// parseJa4 parses the JA4 string, both the hashed form (e.g.
+// "t13d1516h2_8daaf6152771_02713d6af862") and the raw form (JA4_r or
+// JA4_ro, e.g. "t13d1516h2_002f,0035,..._0005,000a,..._0403,0804,...")
+// are supported.
+func parseJa4(ja4Str string) (*ja4, error) {
+	parts := strings.Split(strings.TrimSpace(ja4Str), "_")
+	if len(parts) < 3 || len(parts) > 4 || len(parts[0]) != 10 {
+		return nil, errors.New("ja4Str format error")
+	}
+	a := parts[0]
+	if a[0] != 't' {
+		return nil, fmt.Errorf("ja4Str protocol %q not supported, only TLS over TCP (t) is supported", a[0])
+	}
+	f := &ja4{}
+	switch a[1:3] {
+	case "13":
+		f.version = utls.VersionTLS13
+	case "12":
+		f.version = utls.VersionTLS12
+	case "11":
+		f.version = utls.VersionTLS11
+	default:
+		return nil, fmt.Errorf("ja4Str tls version %q not supported", a[1:3])
+	}
+	switch a[3] {
+	case 'd':
+		f.sni = true
+	case 'i':
+	default:
+		return nil, fmt.Errorf("ja4Str sni %q error", a[3])
+	}
+	var err error
+	if f.ciphers, err = strconv.Atoi(a[4:6]); err != nil {
+		return nil, errors.New("ja4Str cipher count error")
+	}
+	if f.exts, err = strconv.Atoi(a[6:8]); err != nil {
+		return nil, errors.New("ja4Str extension count error")
+	}
+	f.alpn = a[8:10]
+
+	if len(parts) == 3 && len(parts[1]) == 12 && len(parts[2]) == 12 && !strings.Contains(parts[1], ",") {
+		f.hashed = true
+		f.cipherB, f.extC = strings.ToLower(parts[1]), strings.ToLower(parts[2])
+		return f, nil
+	}
+	if f.cipherR, err = parseJa4Hex(parts[1]); err != nil {
+		return nil, err
+	}
+	if f.extR, err = parseJa4Hex(parts[2]); err != nil {
+		return nil, err
+	}
+	if len(parts) == 4 {
+		if f.sigAlgsR, err = parseJa4Hex(parts[3]); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// alpnProtocols derives the ALPN protocols from the first and last
+// characters of the first ALPN value in the JA4 string.
+func (f *ja4) alpnProtocols() ([]string, error) {
+	switch f.alpn {
+	case "00":
+		return nil, nil
+	case "h2":
+		return []string{"h2", "http/1.1"}, nil
+	case "h1":
+		return []string{"http/1.1"}, nil
+	case "h3":
+		return []string{"h3"}, nil
+	}
+	return nil, fmt.Errorf("ja4Str alpn %q not supported", f.alpn)
+}
+
+func ja4Hash(s string) string {
+	if s == "" {
+		return "000000000000"
+	}
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+func ja4HexList(vals []uint16) string {
+	var b strings.Builder
+	for i, v := range vals {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, "%04x", v)
+	}
+	return b.String()
+}
+
+func isGREASE(v uint16) bool {
+	return v>>8 == v&0xff && v&0xf == 0xa
+}
+
+// ja4ExtensionID returns the id of the extension, or false if the id is
+// unknown.
+func ja4ExtensionID(ext utls.TLSExtension) (uint16, bool) {
+	switch e := ext.(type) {
+	case *utls.UtlsGREASEExtension:
+		return utls.GREASE_PLACEHOLDER, true
+	case *utls.SNIExtension:
+		return 0, true
+	case *utls.UtlsPaddingExtension:
+		return 21, true
+	case *utls.UtlsPreSharedKeyExtension, *utls.FakePreSharedKeyExtension:
+		return 41, true
+	case *utls.GenericExtension:
+		return e.Id, true
+	}
+	if ext.Len() < 4 {
+		return 0, false
+	}
+	b := make([]byte, ext.Len())
+	if _, err := ext.Read(b); err != nil && len(b) < 2 {
+		return 0, false
+	}
+	return uint16(b[0])<<8 | uint16(b[1]), true
+}
+
+// ja4Hashes returns the hashed cipher (b) and extension (c) sections of the
+// JA4 fingerprint of spec, the padding extension is excluded if
+// withPadding is false.
+func ja4Hashes(spec *utls.ClientHelloSpec, withPadding bool) (b, c string) {
+	var ciphers, exts []uint16
+	for _, cs := range spec.CipherSuites {
+		if !isGREASE(cs) {
+			ciphers = append(ciphers, cs)
+		}
+	}
+	var sigAlgs []uint16
+	for _, ext := range spec.Extensions {
+		if e, ok := ext.(*utls.SignatureAlgorithmsExtension); ok {
+			for _, s := range e.SupportedSignatureAlgorithms {
+				sigAlgs = append(sigAlgs, uint16(s))
+			}
+		}
+		id, ok := ja4ExtensionID(ext)
+		if !ok || isGREASE(id) || id == 0 || id == 16 || (id == 21 && !withPadding) {
+			continue
+		}
+		exts = append(exts, id)
+	}
+	sort.Slice(ciphers, func(i, j int) bool { return ciphers[i] < ciphers[j] })
+	sort.Slice(exts, func(i, j int) bool { return exts[i] < exts[j] })
+	cStr := ja4HexList(exts)
+	if len(sigAlgs) > 0 {
+		cStr += "_" + ja4HexList(sigAlgs)
+	}
+	return ja4Hash(ja4HexList(ciphers)), ja4Hash(cStr)
+}
+
+// presetSpec returns the spec of the preset client hello which matches the
+// hashed JA4.
+func (f *ja4) presetSpec() (utls.ClientHelloSpec, error) {
+	for _, id := range ja4Presets {
+		spec, err := utls.UTLSIdToSpec(id)
+		if err != nil {
+			continue
+		}
+		for _, withPadding := range []bool{false, true} {
+			if b, c := ja4Hashes(&spec, withPadding); b == f.cipherB && c == f.extC {
+				return spec, nil
+			}
+		}
+	}
+	return utls.ClientHelloSpec{}, errors.New("ja4Str hash matches no known client hello, use the raw form (JA4_r) instead")
+}
+
+// rawSpec builds the spec from the raw JA4.
+func (f *ja4) rawSpec() (spec utls.ClientHelloSpec, err error) {
+	tlsMaxVersion, tlsMinVersion, tlsExtension, err := createTlsVersion(f.version)
+	if err != nil {
+		return
+	}
+	spec.TLSVersMax = tlsMaxVersion
+	spec.TLSVersMin = tlsMinVersion
+	ciphers := make([]string, 0, len(f.cipherR))
+	for _, cs := range f.cipherR {
+		ciphers = append(ciphers, strconv.Itoa(int(cs)))
+	}
+	if spec.CipherSuites, err = createCiphers(ciphers, true); err != nil {
+		return
+	}
+	// JA4_r excludes SNI and ALPN from the extensions, while JA4_ro keeps them.
+	var hasSNI, hasALPN bool
+	for _, id := range f.extR {
+		hasSNI = hasSNI || id == 0
+		hasALPN = hasALPN || id == 16
+	}
+	var extensions []string
+	if f.sni && !hasSNI {
+		extensions = append(extensions, "0")
+	}
+	for _, id := range f.extR {
+		extensions = append(extensions, strconv.Itoa(int(id)))
+	}
+	if f.alpn != "00" && !hasALPN {
+		extensions = append(extensions, "16")
+	}
+	curvesExtension, _ := createCurves([]string{"29", "23", "24"}, true)
+	pointExtension, _ := createPointFormats([]string{"0"})
+	spec.CompressionMethods = []byte{0}
+	spec.GetSessionID = sha256.Sum256
+	spec.Extensions, err = createExtensions(extensions, tlsExtension, curvesExtension, pointExtension, defaultGREASEExtensionPositions)
+	return
+}
+
+// createSpecWithJa4Str builds the ClientHelloSpec from the JA4 string, the
+// ALPN protocols and the signature algorithms are derived from the JA4.
+func createSpecWithJa4Str(ja4Str string) (spec utls.ClientHelloSpec, err error) {
+	f, err := parseJa4(ja4Str)
+	if err != nil {
+		return
+	}
+	alpn, err := f.alpnProtocols()
+	if err != nil {
+		return
+	}
+	if f.hashed {
+		spec, err = f.presetSpec()
+	} else {
+		spec, err = f.rawSpec()
+	}
+	if err != nil {
+		return
+	}
+	for _, ext := range spec.Extensions {
+		switch e := ext.(type) {
+		case *utls.ALPNExtension:
+			e.AlpnProtocols = alpn
+		case *utls.SignatureAlgorithmsExtension:
+			if len(f.sigAlgsR) > 0 {
+				e.SupportedSignatureAlgorithms = make([]utls.SignatureScheme, 0, len(f.sigAlgsR))
+				for _, s := range f.sigAlgsR {
+					e.SupportedSignatureAlgorithms = append(e.SupportedSignatureAlgorithms, utls.SignatureScheme(s))
+				}
+			}
+		}
+	}
+	return
+}
+
+// SetJa4WithStr set the tls fingerprint with the JA4 string. The raw form
+// (JA4_r or JA4_ro) is built into the ClientHelloSpec directly, while the
+// hashed form (e.g. "t13d1516h2_8daaf6152771_02713d6af862") is resolved
+// against the known browser client hellos as the hashes can't be reversed.
+// The ALPN protocols are derived from the ALPN section (e.g. "h2"), and the
+// signature algorithms from the raw signature algorithms section if any.
+// The client is left unchanged and the error is logged if the JA4 string is
+// malformed, use TrySetJa4WithStr to get the error.
+func (c *Client) SetJa4WithStr(ja4Str string) *Client {
+	if _, err := c.TrySetJa4WithStr(ja4Str); err != nil {
+		c.log.Errorf("failed to create tls fingerprint from ja4: %v", err)
+	}
+	return c
+}
+
+// TrySetJa4WithStr is like SetJa4WithStr, but returns the error if the JA4
+// string is malformed, in which case the client is left unchanged.
+func (c *Client) TrySetJa4WithStr(ja4Str string) (*Client, error) {
+	spec, err := createSpecWithJa4Str(ja4Str)
+	if err != nil {
+		return c, err
+	}
+	return c.SetTLSFingerprintRaw(spec), nil
+}
+
+// ValidateJa4 reports the error if the JA4 string is malformed or can't be
+// resolved, see Client.SetJa4WithStr.
+func ValidateJa4(ja4Str string) error {
+	_, err := createSpecWithJa4Str(ja4Str)
+	return err
+}
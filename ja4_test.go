@@ -0,0 +1,54 @@
+package restys
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	utls "github.com/refraction-networking/utls"
+
+	"github.com/luoxk/restys/internal/tests"
+)
+
+func TestJa4(t *testing.T) {
+	spec, err := createSpecWithJa4Str("t13d1516h2_8daaf6152771_02713d6af862")
+	tests.AssertNoError(t, err)
+	b, c := ja4Hashes(&spec, false)
+	tests.AssertEqual(t, "8daaf6152771", b)
+	tests.AssertEqual(t, "02713d6af862", c)
+
+	raw := "t13d1516h2_002f,0035,009c,009d,1301,1302,1303,c013,c014,c02b,c02c,c02f,c030,cca8,cca9_0005,000a,000b,000d,0012,0017,001b,0023,002b,002d,0033,4469,fe0d,ff01_0403,0804,0401,0503,0805,0501,0806,0601"
+	spec, err = createSpecWithJa4Str(raw)
+	tests.AssertNoError(t, err)
+	b, c = ja4Hashes(&spec, false)
+	tests.AssertEqual(t, "8daaf6152771", b)
+	tests.AssertEqual(t, "02713d6af862", c)
+	tests.AssertEqual(t, uint16(utls.VersionTLS13), spec.TLSVersMax)
+	var alpn []string
+	var sigAlgs []utls.SignatureScheme
+	for _, ext := range spec.Extensions {
+		switch e := ext.(type) {
+		case *utls.ALPNExtension:
+			alpn = e.AlpnProtocols
+		case *utls.SignatureAlgorithmsExtension:
+			sigAlgs = e.SupportedSignatureAlgorithms
+		}
+	}
+	tests.AssertEqual(t, []string{"h2", "http/1.1"}, alpn)
+	tests.AssertEqual(t, 8, len(sigAlgs))
+	tests.AssertEqual(t, utls.ECDSAWithP256AndSHA256, sigAlgs[0])
+
+	_, err = createSpecWithJa4Str("t13d1516h2_000000000000_111111111111")
+	tests.AssertErrorContains(t, err, "matches no known client hello")
+	_, err = createSpecWithJa4Str("q13d0310h3_55b375c5d22e_cd85d2d88918")
+	tests.AssertErrorContains(t, err, "not supported")
+	_, err = createSpecWithJa4Str("t13d1516")
+	tests.AssertErrorContains(t, err, "format error")
+
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	}))
+	defer ts.Close()
+	resp, err := C().EnableInsecureSkipVerify().SetJa4WithStr(raw).R().Get(ts.URL)
+	assertSuccess(t, resp, err)
+}
@@ -0,0 +1,41 @@
+package restys
+
+import "encoding/json"
+
+// JSONEngine is the JSON implementation which is used consistently to
+// marshal the request body and unmarshal the success and error results.
+type JSONEngine struct {
+	// Name is the name of the engine, e.g. "encoding/json".
+	Name      string
+	Marshal   func(v interface{}) ([]byte, error)
+	Unmarshal func(data []byte, v interface{}) error
+}
+
+// StdJSONEngine is the JSON engine of the standard library encoding/json.
+var StdJSONEngine = &JSONEngine{
+	Name:      "encoding/json",
+	Marshal:   json.Marshal,
+	Unmarshal: json.Unmarshal,
+}
+
+// DefaultJSONEngine is the JSON engine of the new clients, which is
+// StdJSONEngine, or the high-performance engine selected by the build tag:
+//
+//	go build -tags sonic   // github.com/bytedance/sonic
+//	go build -tags go_json // github.com/goccy/go-json
+//
+// The module of the selected engine should be added to the go.mod of the
+// main module with "go get".
+var DefaultJSONEngine = presetJSONEngine
+
+// SetJSONEngine set the JSON engine which will be used to marshal request
+// body and unmarshal response body, it replaces the functions set by
+// SetJsonMarshal and SetJsonUnmarshal. A nil engine means DefaultJSONEngine.
+func (c *Client) SetJSONEngine(engine *JSONEngine) *Client {
+	if engine == nil {
+		engine = DefaultJSONEngine
+	}
+	c.jsonMarshal = engine.Marshal
+	c.jsonUnmarshal = engine.Unmarshal
+	return c
+}
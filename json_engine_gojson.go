@@ -0,0 +1,14 @@
+//go:build go_json
+
+package restys
+
+import gojson "github.com/goccy/go-json"
+
+// GoJSONEngine is the JSON engine of github.com/goccy/go-json.
+var GoJSONEngine = &JSONEngine{
+	Name:      "go-json",
+	Marshal:   gojson.Marshal,
+	Unmarshal: gojson.Unmarshal,
+}
+
+var presetJSONEngine = GoJSONEngine
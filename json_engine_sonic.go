@@ -0,0 +1,15 @@
+//go:build sonic && !go_json
+
+package restys
+
+import "github.com/bytedance/sonic"
+
+// SonicJSONEngine is the JSON engine of github.com/bytedance/sonic, which
+// is compatible with encoding/json.
+var SonicJSONEngine = &JSONEngine{
+	Name:      "sonic",
+	Marshal:   sonic.ConfigStd.Marshal,
+	Unmarshal: sonic.ConfigStd.Unmarshal,
+}
+
+var presetJSONEngine = SonicJSONEngine
@@ -0,0 +1,5 @@
+//go:build !sonic && !go_json
+
+package restys
+
+var presetJSONEngine = StdJSONEngine
@@ -0,0 +1,183 @@
+package restys
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// EnableFileScheme enables the "file:" scheme URLs, the files are served
+// from the root directory (disabled by default). The paths outside root
+// (including through symlinks) are forbidden, use "/" to allow any file.
+func (t *Transport) EnableFileScheme(root string) *Transport {
+	t.fileRoot = root
+	return t
+}
+
+// DisableFileScheme disables the "file:" scheme URLs.
+func (t *Transport) DisableFileScheme() *Transport {
+	t.fileRoot = ""
+	return t
+}
+
+// roundTripLocal serves the "data:" and "file:" scheme URLs with the
+// synthesized responses, ok is false if the URL is not of these schemes.
+func (t *Transport) roundTripLocal(req *http.Request) (resp *http.Response, ok bool, err error) {
+	scheme := strings.ToLower(req.URL.Scheme)
+	if scheme != "data" && scheme != "file" {
+		return nil, false, nil
+	}
+	closeBody(req)
+	// never follow the redirects from the network to the local resources.
+	if req.Response != nil {
+		return nil, true, fmt.Errorf("refusing to redirect to %s: URL", scheme)
+	}
+	if req.Method != "" && req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return nil, true, fmt.Errorf("unsupported method %s for %s: URL", req.Method, scheme)
+	}
+	if scheme == "data" {
+		resp, err = serveDataURL(req)
+	} else {
+		resp, err = t.serveFileURL(req)
+	}
+	return resp, true, err
+}
+
+func newLocalResponse(req *http.Request, status int, header http.Header, body []byte) *http.Response {
+	if header == nil {
+		header = make(http.Header)
+	}
+	header.Set("Content-Length", strconv.Itoa(len(body)))
+	if req.Method == http.MethodHead {
+		body = nil
+	}
+	return &http.Response{
+		Status:        strconv.Itoa(status) + " " + http.StatusText(status),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+// serveDataURL decodes the data URL (RFC 2397), e.g.
+// "data:text/plain;base64,SGVsbG8=".
+func serveDataURL(req *http.Request) (*http.Response, error) {
+	raw := req.URL.Opaque
+	if raw == "" {
+		raw = strings.TrimPrefix(req.URL.Path, "//")
+	}
+	if req.URL.RawQuery != "" {
+		raw += "?" + req.URL.RawQuery
+	}
+	meta, data, found := strings.Cut(raw, ",")
+	if !found {
+		return nil, errors.New("invalid data URL: missing comma")
+	}
+	isBase64 := false
+	if strings.HasSuffix(strings.ToLower(meta), ";base64") {
+		isBase64 = true
+		meta = meta[:len(meta)-len(";base64")]
+	}
+	if meta == "" {
+		meta = "text/plain;charset=US-ASCII"
+	} else if strings.HasPrefix(meta, ";") {
+		meta = "text/plain" + meta
+	}
+
+	var body []byte
+	unescaped, err := url.PathUnescape(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid data URL: %w", err)
+	}
+	if isBase64 {
+		unescaped = strings.Map(func(r rune) rune {
+			if r == ' ' || r == '\t' || r == '\r' || r == '\n' {
+				return -1
+			}
+			return r
+		}, unescaped)
+		if body, err = base64.StdEncoding.DecodeString(unescaped); err != nil {
+			if body, err = base64.RawStdEncoding.DecodeString(strings.TrimRight(unescaped, "=")); err != nil {
+				return nil, fmt.Errorf("invalid data URL: %w", err)
+			}
+		}
+	} else {
+		body = []byte(unescaped)
+	}
+	header := make(http.Header)
+	header.Set("Content-Type", meta)
+	return newLocalResponse(req, http.StatusOK, header, body), nil
+}
+
+func (t *Transport) serveFileURL(req *http.Request) (*http.Response, error) {
+	if t.fileRoot == "" {
+		return nil, errors.New(`file: URL is disabled, enable it with EnableFileScheme`)
+	}
+	if host := req.URL.Host; host != "" && host != "localhost" {
+		return nil, fmt.Errorf("unsupported host %q of file: URL", host)
+	}
+	root, err := filepath.Abs(t.fileRoot)
+	if err != nil {
+		return nil, err
+	}
+	if r, err := filepath.EvalSymlinks(root); err == nil {
+		root = r
+	}
+	name := filepath.Join(root, filepath.FromSlash(filepath.Clean("/"+req.URL.Path)))
+	status := http.StatusOK
+	var body []byte
+	header := make(http.Header)
+	if real, err := filepath.EvalSymlinks(name); err != nil {
+		status = fileErrorStatus(err)
+	} else if !withinDir(root, real) {
+		status = http.StatusForbidden
+	} else if fi, err := os.Stat(real); err != nil {
+		status = fileErrorStatus(err)
+	} else if fi.IsDir() {
+		status = http.StatusForbidden
+	} else if body, err = os.ReadFile(real); err != nil {
+		status = fileErrorStatus(err)
+	} else {
+		ct := mime.TypeByExtension(filepath.Ext(real))
+		if ct == "" {
+			ct = http.DetectContentType(body)
+		}
+		header.Set("Content-Type", ct)
+		header.Set("Last-Modified", fi.ModTime().UTC().Format(http.TimeFormat))
+	}
+	if status != http.StatusOK {
+		body = []byte(http.StatusText(status))
+		header.Set("Content-Type", "text/plain; charset=utf-8")
+	}
+	return newLocalResponse(req, status, header, body), nil
+}
+
+func fileErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return http.StatusNotFound
+	case errors.Is(err, fs.ErrPermission):
+		return http.StatusForbidden
+	}
+	return http.StatusInternalServerError
+}
+
+func withinDir(dir, name string) bool {
+	rel, err := filepath.Rel(dir, name)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,79 @@
+package restys
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/luoxk/restys/internal/tests"
+)
+
+func TestDataURL(t *testing.T) {
+	c := tc()
+	resp, err := c.R().Get("data:text/plain;base64,SGVsbG8sIFdvcmxkIQ==")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, http.StatusOK, resp.StatusCode)
+	tests.AssertEqual(t, "text/plain", resp.GetContentType())
+	tests.AssertEqual(t, "Hello, World!", resp.String())
+
+	resp, err = c.R().Get("data:,A%20brief%20note")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, "text/plain;charset=US-ASCII", resp.GetContentType())
+	tests.AssertEqual(t, "A brief note", resp.String())
+
+	var v struct{ Name string }
+	_, err = c.R().SetSuccessResult(&v).Get(`data:application/json,{"name":"restys"}`)
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, "restys", v.Name)
+
+	_, err = c.R().Get("data:text/plain")
+	tests.AssertErrorContains(t, err, "missing comma")
+	_, err = c.R().Post("data:,a")
+	tests.AssertErrorContains(t, err, "unsupported method")
+}
+
+func TestFileURL(t *testing.T) {
+	dir := t.TempDir()
+	tests.AssertNoError(t, os.WriteFile(filepath.Join(dir, "a.json"), []byte(`{"a":1}`), 0o644))
+	outside := t.TempDir()
+	tests.AssertNoError(t, os.WriteFile(filepath.Join(outside, "secret"), []byte("secret"), 0o644))
+	tests.AssertNoError(t, os.Symlink(filepath.Join(outside, "secret"), filepath.Join(dir, "link")))
+
+	c := tc()
+	_, err := c.R().Get("file://" + filepath.ToSlash(filepath.Join(dir, "a.json")))
+	tests.AssertErrorContains(t, err, "disabled")
+
+	c.EnableFileScheme(dir)
+	resp, err := c.R().Get("file:///a.json")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, http.StatusOK, resp.StatusCode)
+	tests.AssertEqual(t, "application/json", resp.GetContentType())
+	tests.AssertEqual(t, `{"a":1}`, resp.String())
+
+	resp, err = c.R().Get("file:///../" + filepath.Base(outside) + "/secret")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, http.StatusNotFound, resp.StatusCode)
+
+	resp, err = c.R().Get("file:///link")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, http.StatusForbidden, resp.StatusCode)
+
+	resp, err = c.R().Get("file:///missing")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, http.StatusNotFound, resp.StatusCode)
+
+	resp, err = c.R().Head("file:///a.json")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, "7", resp.Header.Get("Content-Length"))
+
+	c.DisableFileScheme()
+	_, err = c.R().Get("file:///a.json")
+	tests.AssertErrorContains(t, err, "disabled")
+}
+
+func TestRedirectToLocalScheme(t *testing.T) {
+	c := tc().EnableFileScheme("/")
+	_, err := c.R().SetQueryParam("url", "data:,secret").Get("/redirect-to")
+	tests.AssertErrorContains(t, err, "refusing to redirect")
+}
@@ -8,7 +8,6 @@ import (
 	"net/http"
 	"net/textproto"
 	"net/url"
-	"os"
 	"path/filepath"
 	"reflect"
 	"strings"
@@ -297,17 +296,7 @@ func unmarshalBody(c *Client, r *Response, v interface{}) (err error) {
 	if err != nil {
 		return
 	}
-	ct := r.GetContentType()
-	if util.IsJSONType(ct) {
-		return c.jsonUnmarshal(body, v)
-	} else if util.IsXMLType(ct) {
-		return c.xmlUnmarshal(body, v)
-	} else {
-		if c.DebugLog {
-			c.log.Debugf("cannot determine the unmarshal function with %q Content-Type, default to json", ct)
-		}
-		return c.jsonUnmarshal(body, v)
-	}
+	return c.getResponseDecoder(r)(body, v)
 }
 
 func defaultResultStateChecker(resp *Response) ResultState {
@@ -321,38 +310,77 @@ func defaultResultStateChecker(resp *Response) ResultState {
 }
 
 func parseResponseBody(c *Client, r *Response) (err error) {
-	if r.Response == nil {
+	if r.Response == nil || r.Request.stream || r.StatusCode == http.StatusNoContent {
 		return
 	}
 	req := r.Request
-	switch r.ResultState() {
+	state := r.ResultState()
+	switch state {
 	case SuccessState:
-		if req.Result != nil && r.StatusCode != http.StatusNoContent {
+		if req.Result != nil {
 			err = unmarshalBody(c, r, r.Request.Result)
 			if err == nil {
 				r.result = r.Request.Result
 			}
-		}
-	case ErrorState:
-		if r.StatusCode == http.StatusNoContent {
 			return
 		}
+	case ErrorState:
 		if req.Error != nil {
 			err = unmarshalBody(c, r, req.Error)
 			if err == nil {
 				r.error = req.Error
 			}
-		} else if c.commonErrorType != nil {
+			return
+		}
+	}
+	if t := routedResultType(c, r); t != nil {
+		v := reflect.New(t).Interface()
+		if err = unmarshalBody(c, r, v); err == nil {
+			if state == ErrorState {
+				r.error = v
+			} else {
+				r.result = v
+			}
+		}
+		return
+	}
+	switch state {
+	case ErrorState:
+		if c.commonErrorType != nil {
 			e := reflect.New(c.commonErrorType).Interface()
 			err = unmarshalBody(c, r, e)
 			if err == nil {
 				r.error = e
 			}
 		}
+	case UnknownState:
+		handler := c.unknownResultHandler
+		if req.unknownResultHandler != nil {
+			handler = req.unknownResultHandler
+		}
+		if handler != nil {
+			err = handler(r)
+		}
 	}
 	return
 }
 
+// routedResultType returns the result type routed by the status code or
+// the content type, see Client.SetResultForStatus and
+// Client.SetResultForContentType.
+func routedResultType(c *Client, r *Response) reflect.Type {
+	if t, ok := c.statusResultTypes[r.StatusCode]; ok {
+		return t
+	}
+	if len(c.contentTypeResultTypes) > 0 {
+		ct, _, _ := strings.Cut(r.GetContentType(), ";")
+		if t, ok := c.contentTypeResultTypes[strings.ToLower(strings.TrimSpace(ct))]; ok {
+			return t
+		}
+	}
+	return nil
+}
+
 type callbackWriter struct {
 	io.Writer
 	written   int64
@@ -419,22 +447,31 @@ func handleDownload(c *Client, r *Response) (err error) {
 		body = r.Body
 	}
 
+	if r.Request.resumeOutput && r.Request.outputFile != "" {
+		return handleResumeDownload(c, r, body)
+	}
+
 	var output io.Writer
-	if r.Request.outputFile != "" {
-		file := r.Request.outputFile
-		if c.outputDirectory != "" && !filepath.IsAbs(file) {
-			file = c.outputDirectory + string(filepath.Separator) + file
+	if r.Request.outputFile != "" || r.Request.outputFileFromHeader {
+		var file string
+		if r.Request.outputFileFromHeader {
+			file = c.outputFilePath(contentDispositionFilename(r))
+		} else {
+			file = c.outputFilePath(r.Request.outputFile)
 		}
-
-		file = filepath.Clean(file)
-
 		if err = util.CreateDirectory(filepath.Dir(file)); err != nil {
+			body.Close()
 			return err
 		}
-		output, err = os.Create(file)
+		if r.Request.atomicOutput {
+			return handleAtomicDownload(r, body, file)
+		}
+		output, file, err = createOutputFile(file, r.Request.collisionPolicy)
 		if err != nil {
+			body.Close()
 			return
 		}
+		r.outputFile = file
 	} else {
 		output = r.Request.output // must not nil
 	}
@@ -444,7 +481,7 @@ func handleDownload(c *Client, r *Response) (err error) {
 		closeq(output)
 	}()
 
-	_, err = io.Copy(output, body)
+	_, err = copyOutput(output, body)
 	r.setReceivedAt()
 	return
 }
@@ -521,6 +558,14 @@ func parseRequestURL(c *Client, r *Request) error {
 	}
 
 	reqURL.Host = removeEmptyPort(reqURL.Host)
+	if err = encodeIDNAHost(reqURL); err != nil {
+		return err
+	}
+	if c.urlNormalization != 0 {
+		if err = normalizeURL(reqURL, c.urlNormalization); err != nil {
+			return err
+		}
+	}
 	r.URL = reqURL
 	return nil
 }
@@ -529,12 +574,23 @@ func parseRequestHeader(c *Client, r *Request) error {
 	if c.Headers == nil {
 		return nil
 	}
-	if r.Headers == nil {
+	if len(r.Headers) < len(c.Headers) {
+		// presize to avoid growing the map header by header, which
+		// dominates the cost with dozens of common headers.
+		h := make(http.Header, len(c.Headers)+len(r.Headers)+4)
+		for k, vs := range r.Headers {
+			h[k] = vs
+		}
+		r.Headers = h
+	} else if r.Headers == nil {
 		r.Headers = make(http.Header)
 	}
 	for k, vs := range c.Headers {
 		if len(r.Headers[k]) == 0 {
-			r.Headers[k] = vs
+			// share the values with the client, the full slice expression
+			// makes them copy-on-write, so Request.Headers.Add never writes
+			// to the common headers.
+			r.Headers[k] = vs[:len(vs):len(vs)]
 		}
 	}
 	return nil
@@ -0,0 +1,72 @@
+package restys
+
+import (
+	"net/http"
+	"time"
+)
+
+// Observer observes the lifecycle events of requests fired from the client,
+// which is a single interface for middleware, metrics and custom loggers to
+// hook, embed NopObserver to implement only the events of interest, e.g.
+//
+//	type retryLogger struct {
+//		req.NopObserver
+//	}
+//
+//	func (retryLogger) OnRetryScheduled(resp *req.Response, err error, delay time.Duration) {
+//		log.Printf("retry %s in %v: %v", resp.Request.RawURL, delay, err)
+//	}
+//
+// The callbacks are invoked synchronously in the goroutine sending the
+// request, so they should return quickly.
+type Observer interface {
+	// OnRequestQueued is called when the request is about to be sent,
+	// before the request middlewares.
+	OnRequestQueued(req *Request)
+	// OnAttemptStart is called before each attempt (including retries) is
+	// sent, after the request middlewares.
+	OnAttemptStart(req *Request)
+	// OnRedirect is called when the redirect request is about to be sent,
+	// via is the requests made already, oldest first.
+	OnRedirect(req *http.Request, via []*http.Request)
+	// OnRetryScheduled is called when the attempt is going to be retried
+	// after delay, resp and err is the result of the attempt. The resp is
+	// reused by the retry, so it's only valid during the call.
+	OnRetryScheduled(resp *Response, err error, delay time.Duration)
+	// OnResponse is called when the response of each attempt is received.
+	OnResponse(resp *Response)
+	// OnError is called when the request finally fails, including the
+	// errors occur before the request is sent (e.g. invalid URL).
+	OnError(req *Request, resp *Response, err error)
+}
+
+// NopObserver is the Observer which does nothing, embed it in the custom
+// Observer to implement only the events of interest.
+type NopObserver struct{}
+
+// OnRequestQueued implements Observer.
+func (NopObserver) OnRequestQueued(req *Request) {}
+
+// OnAttemptStart implements Observer.
+func (NopObserver) OnAttemptStart(req *Request) {}
+
+// OnRedirect implements Observer.
+func (NopObserver) OnRedirect(req *http.Request, via []*http.Request) {}
+
+// OnRetryScheduled implements Observer.
+func (NopObserver) OnRetryScheduled(resp *Response, err error, delay time.Duration) {}
+
+// OnResponse implements Observer.
+func (NopObserver) OnResponse(resp *Response) {}
+
+// OnError implements Observer.
+func (NopObserver) OnError(req *Request, resp *Response, err error) {}
+
+// AddObserver add the observer of the lifecycle events of requests fired
+// from the client, the observers are called in the order they are added.
+func (c *Client) AddObserver(o Observer) *Client {
+	if o != nil {
+		c.observers = append(c.observers, o)
+	}
+	return c
+}
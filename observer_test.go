@@ -0,0 +1,66 @@
+package restys
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/luoxk/restys/internal/tests"
+)
+
+type recordObserver struct {
+	NopObserver
+	events []string
+}
+
+func (o *recordObserver) OnRequestQueued(req *Request) {
+	o.events = append(o.events, "queued")
+}
+
+func (o *recordObserver) OnAttemptStart(req *Request) {
+	o.events = append(o.events, "attempt")
+}
+
+func (o *recordObserver) OnRedirect(req *http.Request, via []*http.Request) {
+	o.events = append(o.events, "redirect "+req.URL.Path)
+}
+
+func (o *recordObserver) OnRetryScheduled(resp *Response, err error, delay time.Duration) {
+	o.events = append(o.events, "retry "+delay.String())
+}
+
+func (o *recordObserver) OnResponse(resp *Response) {
+	o.events = append(o.events, "response "+resp.Status)
+}
+
+func (o *recordObserver) OnError(req *Request, resp *Response, err error) {
+	o.events = append(o.events, "error")
+}
+
+func TestAddObserver(t *testing.T) {
+	o := &recordObserver{}
+	c := tc().AddObserver(o)
+	resp, err := c.R().
+		SetRetryCount(1).
+		SetRetryFixedInterval(time.Millisecond).
+		AddRetryCondition(func(resp *Response, err error) bool {
+			return resp.Request.RetryAttempt == 0
+		}).
+		Get("/unlimited-redirect")
+	tests.AssertNotNil(t, err)
+	tests.AssertNotNil(t, resp)
+	tests.AssertEqual(t, []string{"queued", "attempt"}, o.events[:2])
+	tests.AssertEqual(t, "redirect /unlimited-redirect", o.events[2])
+	tests.AssertEqual(t, "error", o.events[len(o.events)-1])
+	tests.AssertContains(t, strings.Join(o.events, ","), "retry 1ms,attempt", true)
+
+	o.events = nil
+	resp, err = c.R().Get("/")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, []string{"queued", "attempt", "response 200 OK"}, o.events)
+
+	o.events = nil
+	c.R().Get("http://invalid url")
+	tests.AssertEqual(t, []string{"queued", "error"}, o.events)
+}
@@ -3,36 +3,76 @@ package restys
 import (
 	"context"
 	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"hash"
 	"io"
 	"math"
+	"net/http"
 	urlpkg "net/url"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// ErrChecksumMismatch is returned by ParallelDownload.Do if the checksum
+// of the downloaded file does not match the expected one.
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// ParallelDownloadInfo is the information for each ParallelDownloadCallback call.
+type ParallelDownloadInfo struct {
+	// downloaded length in bytes, including the segments that
+	// have been downloaded before resume.
+	DownloadedSize int64
+	// total file length in bytes.
+	TotalSize int64
+	// average download rate in bytes per second.
+	Rate float64
+	// estimated time to finish the download, zero if unknown.
+	ETA time.Duration
+}
+
+// ParallelDownloadCallback is the callback which will be invoked during
+// parallel download.
+type ParallelDownloadCallback func(info ParallelDownloadInfo)
+
 type ParallelDownload struct {
-	url          string
-	client       *Client
-	concurrency  int
-	output       io.Writer
-	filename     string
-	segmentSize  int64
-	perm         os.FileMode
-	tempRootDir  string
-	tempDir      string
-	taskCh       chan *downloadTask
-	doneCh       chan struct{}
-	wgDoneCh     chan struct{}
-	errCh        chan error
-	wg           sync.WaitGroup
-	taskMap      map[int]*downloadTask
-	taskNotifyCh chan *downloadTask
-	mu           sync.Mutex
-	lastIndex    int
+	url              string
+	client           *Client
+	concurrency      int
+	output           io.Writer
+	filename         string
+	segmentSize      int64
+	perm             os.FileMode
+	tempRootDir      string
+	tempDir          string
+	taskCh           chan *downloadTask
+	doneCh           chan struct{}
+	wgDoneCh         chan struct{}
+	errCh            chan error
+	wg               sync.WaitGroup
+	taskMap          map[int]*downloadTask
+	taskNotifyCh     chan *downloadTask
+	mu               sync.Mutex
+	lastIndex        int
+	retryCount       int
+	retryInterval    GetRetryIntervalFunc
+	limiter          *rateLimiter
+	callback         ParallelDownloadCallback
+	callbackInterval time.Duration
+	checksumAlgo     string
+	checksum         string
+	headerChecksum   bool
+	totalSize        int64
+	downloaded       atomic.Int64
+	transferred      atomic.Int64
+	startTime        time.Time
 }
 
 func (pd *ParallelDownload) completeTask(task *downloadTask) {
@@ -56,16 +96,28 @@ func (pd *ParallelDownload) popTask(index int) *downloadTask {
 	}
 	pd.mu.Unlock()
 	for {
-		task := <-pd.taskNotifyCh
-		if task.index == index {
-			pd.mu.Lock()
-			delete(pd.taskMap, index)
-			pd.mu.Unlock()
-			return task
+		select {
+		case task := <-pd.taskNotifyCh:
+			if task.index == index {
+				pd.mu.Lock()
+				delete(pd.taskMap, index)
+				pd.mu.Unlock()
+				return task
+			}
+		case <-pd.doneCh:
+			return nil
 		}
 	}
 }
 
+// fail reports the error to Do, it gives up if the download is already finished.
+func (pd *ParallelDownload) fail(err error) {
+	select {
+	case pd.errCh <- err:
+	case <-pd.doneCh:
+	}
+}
+
 func md5Sum(s string) string {
 	sum := md5.Sum([]byte(s))
 	return hex.EncodeToString(sum[:])
@@ -84,6 +136,17 @@ func (pd *ParallelDownload) ensure() error {
 	if pd.tempRootDir == "" {
 		pd.tempRootDir = os.TempDir()
 	}
+	if pd.retryInterval == nil {
+		pd.retryInterval = defaultGetRetryInterval
+	}
+	if pd.callbackInterval <= 0 {
+		pd.callbackInterval = 200 * time.Millisecond
+	}
+	if pd.checksumAlgo != "" {
+		if _, err := newChecksumHash(pd.checksumAlgo); err != nil {
+			return err
+		}
+	}
 	pd.tempDir = filepath.Join(pd.tempRootDir, md5Sum(pd.url))
 	if pd.client.DebugLog {
 		pd.client.log.Debugf("use temporary directory %s", pd.tempDir)
@@ -100,6 +163,8 @@ func (pd *ParallelDownload) ensure() error {
 	pd.errCh = make(chan error)
 	pd.taskMap = make(map[int]*downloadTask)
 	pd.taskNotifyCh = make(chan *downloadTask)
+	pd.downloaded.Store(0)
+	pd.transferred.Store(0)
 	return nil
 }
 
@@ -135,6 +200,112 @@ func (pd *ParallelDownload) SetOutputFile(filename string) *ParallelDownload {
 	return pd
 }
 
+// SetRetryCount set the maximum retry count of each segment, a failed segment
+// is resumed from the bytes it has already downloaded. It will retry infinitely
+// if count is negative.
+func (pd *ParallelDownload) SetRetryCount(count int) *ParallelDownload {
+	pd.retryCount = count
+	return pd
+}
+
+// SetRetryFixedInterval set the segment retry to use a fixed interval.
+func (pd *ParallelDownload) SetRetryFixedInterval(interval time.Duration) *ParallelDownload {
+	pd.retryInterval = func(resp *Response, attempt int) time.Duration {
+		return interval
+	}
+	return pd
+}
+
+// SetRetryBackoffInterval set the segment retry to use a capped exponential
+// backoff with jitter.
+func (pd *ParallelDownload) SetRetryBackoffInterval(min, max time.Duration) *ParallelDownload {
+	pd.retryInterval = backoffInterval(min, max)
+	return pd
+}
+
+// SetRateLimit caps the total bandwidth of all segments to bytesPerSec,
+// zero or negative means no limit.
+func (pd *ParallelDownload) SetRateLimit(bytesPerSec int64) *ParallelDownload {
+	if bytesPerSec > 0 {
+		pd.limiter = newRateLimiter(bytesPerSec)
+	} else {
+		pd.limiter = nil
+	}
+	return pd
+}
+
+// SetProgressCallback set the ParallelDownloadCallback which will be invoked
+// at least every 200ms during download, usually used to show download progress.
+func (pd *ParallelDownload) SetProgressCallback(callback ParallelDownloadCallback) *ParallelDownload {
+	return pd.SetProgressCallbackWithInterval(callback, 200*time.Millisecond)
+}
+
+// SetProgressCallbackWithInterval set the ParallelDownloadCallback which will be
+// invoked at least every `minInterval` during download, usually used to show
+// download progress.
+func (pd *ParallelDownload) SetProgressCallbackWithInterval(callback ParallelDownloadCallback, minInterval time.Duration) *ParallelDownload {
+	pd.callback = callback
+	pd.callbackInterval = minInterval
+	return pd
+}
+
+// SetChecksum set the expected hex-encoded checksum of the downloaded file,
+// algorithm is "sha256" or "md5". Do returns ErrChecksumMismatch if the
+// checksum does not match.
+func (pd *ParallelDownload) SetChecksum(algorithm, checksum string) *ParallelDownload {
+	pd.checksumAlgo = algorithm
+	pd.checksum = strings.ToLower(checksum)
+	return pd
+}
+
+// EnableChecksumFromHeader verifies the downloaded file against the checksum
+// supplied by the server in `Repr-Digest`, `Digest` or `Content-MD5` header,
+// it is ignored if SetChecksum is called or the server supplies no checksum.
+func (pd *ParallelDownload) EnableChecksumFromHeader() *ParallelDownload {
+	pd.headerChecksum = true
+	return pd
+}
+
+func newChecksumHash(algorithm string) (hash.Hash, error) {
+	switch strings.ReplaceAll(strings.ToLower(algorithm), "-", "") {
+	case "sha256":
+		return sha256.New(), nil
+	case "md5":
+		return md5.New(), nil
+	}
+	return nil, fmt.Errorf("unsupported checksum algorithm %q", algorithm)
+}
+
+// checksumFromHeader returns the algorithm and hex-encoded checksum from
+// headers defined in RFC 9530, RFC 3230 and RFC 1864.
+func checksumFromHeader(h http.Header) (algorithm, checksum string) {
+	for _, key := range []string{"Repr-Digest", "Digest"} {
+		for _, v := range h.Values(key) {
+			for _, item := range strings.Split(v, ",") {
+				algo, value, ok := strings.Cut(strings.TrimSpace(item), "=")
+				if !ok {
+					continue
+				}
+				algo = strings.ToLower(algo)
+				if algo != "sha-256" && algo != "md5" {
+					continue
+				}
+				b, err := base64.StdEncoding.DecodeString(strings.Trim(value, ":"))
+				if err != nil {
+					continue
+				}
+				return algo, hex.EncodeToString(b)
+			}
+		}
+	}
+	if v := h.Get("Content-MD5"); v != "" {
+		if b, err := base64.StdEncoding.DecodeString(v); err == nil {
+			return "md5", hex.EncodeToString(b)
+		}
+	}
+	return "", ""
+}
+
 func getRangeTempFile(rangeStart, rangeEnd int64, workerDir string) string {
 	return filepath.Join(workerDir, fmt.Sprintf("temp-%d-%d", rangeStart, rangeEnd))
 }
@@ -143,32 +314,101 @@ type downloadTask struct {
 	index                int
 	rangeStart, rangeEnd int64
 	tempFilename         string
-	tempFile             *os.File
+	counted              int64 // bytes of the temp file counted in the progress
+}
+
+// segmentWriter writes the segment to the temp file and counts the progress.
+type segmentWriter struct {
+	file *os.File
+	task *downloadTask
+	pd   *ParallelDownload
+}
+
+func (w *segmentWriter) Write(p []byte) (n int, err error) {
+	n, err = w.file.Write(p)
+	w.task.counted += int64(n)
+	w.pd.downloaded.Add(int64(n))
+	w.pd.transferred.Add(int64(n))
+	return
 }
 
 func (pd *ParallelDownload) handleTask(t *downloadTask, ctx ...context.Context) {
 	pd.wg.Add(1)
 	defer pd.wg.Done()
 	t.tempFilename = getRangeTempFile(t.rangeStart, t.rangeEnd, pd.tempDir)
+	for attempt := 0; ; attempt++ {
+		resp, err := pd.downloadSegment(t, ctx...)
+		if err == nil {
+			break
+		}
+		if pd.retryCount >= 0 && attempt >= pd.retryCount {
+			pd.fail(err)
+			return
+		}
+		if pd.client.DebugLog {
+			pd.client.log.Debugf("retry segment %d-%d: %v", t.rangeStart, t.rangeEnd, err)
+		}
+		select {
+		case <-time.After(pd.retryInterval(resp, attempt+1)):
+		case <-pd.doneCh:
+			return
+		}
+	}
+	pd.completeTask(t)
+}
+
+// downloadSegment downloads the segment to its temp file, continues
+// from the bytes that already exist in the temp file.
+func (pd *ParallelDownload) downloadSegment(t *downloadTask, ctx ...context.Context) (*Response, error) {
+	size := t.rangeEnd - t.rangeStart + 1
+	flag := os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	var written int64
+	if info, err := os.Stat(t.tempFilename); err == nil {
+		written = info.Size()
+	}
+	if written > size {
+		flag |= os.O_TRUNC
+		written = 0
+	}
+	pd.downloaded.Add(written - t.counted)
+	t.counted = written
+	if written == size {
+		return nil, nil
+	}
 	if pd.client.DebugLog {
-		pd.client.log.Debugf("downloading segment %d-%d", t.rangeStart, t.rangeEnd)
+		pd.client.log.Debugf("downloading segment %d-%d", t.rangeStart+written, t.rangeEnd)
 	}
-	file, err := os.OpenFile(t.tempFilename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+	file, err := os.OpenFile(t.tempFilename, flag, 0666)
 	if err != nil {
-		pd.errCh <- err
-		return
+		return nil, err
 	}
-	err = pd.client.Get(pd.url).
-		SetHeader("Range", fmt.Sprintf("bytes=%d-%d", t.rangeStart, t.rangeEnd)).
-		SetOutput(file).
-		Do(ctx...).Err
+	defer file.Close()
 
+	resp := pd.client.Get(pd.url).
+		SetRetryCount(0).
+		DisableAutoReadResponse().
+		SetHeader("Range", fmt.Sprintf("bytes=%d-%d", t.rangeStart+written, t.rangeEnd)).
+		Do(ctx...)
+	if resp.Err != nil {
+		return resp, resp.Err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusPartialContent {
+		return resp, fmt.Errorf("bad status code for range request: %d", resp.StatusCode)
+	}
+
+	var w io.Writer = &segmentWriter{file: file, task: t, pd: pd}
+	if pd.limiter != nil {
+		w = &rateLimitWriter{Writer: w, limiter: pd.limiter, ctx: resp.Request.Context()}
+	}
+	n, err := copyLargeBuffer(w, resp.Body)
 	if err != nil {
-		pd.errCh <- err
-		return
+		return resp, err
 	}
-	t.tempFile = file
-	pd.completeTask(t)
+	if n != size-written {
+		return resp, fmt.Errorf("incomplete segment %d-%d: got %d bytes, expected %d", t.rangeStart, t.rangeEnd, n, size-written)
+	}
+	return resp, nil
 }
 
 func (pd *ParallelDownload) startWorker(ctx ...context.Context) {
@@ -182,24 +422,64 @@ func (pd *ParallelDownload) startWorker(ctx ...context.Context) {
 	}
 }
 
-func (pd *ParallelDownload) mergeFile() {
+func (pd *ParallelDownload) progress() ParallelDownloadInfo {
+	info := ParallelDownloadInfo{
+		DownloadedSize: pd.downloaded.Load(),
+		TotalSize:      pd.totalSize,
+	}
+	if elapsed := time.Since(pd.startTime).Seconds(); elapsed > 0 {
+		info.Rate = float64(pd.transferred.Load()) / elapsed
+	}
+	if info.Rate > 0 {
+		remaining := float64(info.TotalSize - info.DownloadedSize)
+		info.ETA = time.Duration(remaining / info.Rate * float64(time.Second))
+	}
+	return info
+}
+
+func (pd *ParallelDownload) reportProgress(stopped chan<- struct{}) {
+	defer close(stopped)
+	ticker := time.NewTicker(pd.callbackInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			pd.callback(pd.progress())
+		case <-pd.doneCh:
+			return
+		}
+	}
+}
+
+func (pd *ParallelDownload) mergeFile(checksumAlgo, checksum string) {
 	defer pd.wg.Done()
 	file, err := pd.getOutputFile()
 	if err != nil {
-		pd.errCh <- err
+		pd.fail(err)
 		return
 	}
+	if pd.output == nil {
+		defer closeq(file)
+	}
+	var h hash.Hash
+	if checksumAlgo != "" {
+		h, _ = newChecksumHash(checksumAlgo)
+		file = io.MultiWriter(file, h)
+	}
 	for i := 0; ; i++ {
 		task := pd.popTask(i)
+		if task == nil {
+			return
+		}
 		tempFile, err := os.Open(task.tempFilename)
 		if err != nil {
-			pd.errCh <- err
+			pd.fail(err)
 			return
 		}
-		_, err = io.Copy(file, tempFile)
+		_, err = copyOutput(file, tempFile)
 		tempFile.Close()
 		if err != nil {
-			pd.errCh <- err
+			pd.fail(err)
 			return
 		}
 		if i < pd.lastIndex {
@@ -207,23 +487,28 @@ func (pd *ParallelDownload) mergeFile() {
 		}
 		break
 	}
+	if h != nil {
+		if sum := hex.EncodeToString(h.Sum(nil)); sum != checksum {
+			pd.fail(fmt.Errorf("%w: expected %s %s, got %s", ErrChecksumMismatch, checksumAlgo, checksum, sum))
+			return
+		}
+	}
 	if pd.client.DebugLog {
 		pd.client.log.Debugf("removing temporary directory %s", pd.tempDir)
 	}
 	err = os.RemoveAll(pd.tempDir)
 	if err != nil {
-		pd.errCh <- err
+		pd.fail(err)
 	}
 }
 
+// Do starts the parallel download, segments left in the temporary directory
+// by an interrupted download of the same URL are resumed.
 func (pd *ParallelDownload) Do(ctx ...context.Context) error {
 	err := pd.ensure()
 	if err != nil {
 		return err
 	}
-	for i := 0; i < pd.concurrency; i++ {
-		go pd.startWorker(ctx...)
-	}
 	resp := pd.client.Head(pd.url).Do(ctx...)
 	if resp.Err != nil {
 		return resp.Err
@@ -231,13 +516,28 @@ func (pd *ParallelDownload) Do(ctx ...context.Context) error {
 	if resp.ContentLength <= 0 {
 		return fmt.Errorf("bad content length: %d", resp.ContentLength)
 	}
+	checksumAlgo, checksum := pd.checksumAlgo, pd.checksum
+	if checksumAlgo == "" && pd.headerChecksum {
+		checksumAlgo, checksum = checksumFromHeader(resp.Header)
+	}
+	pd.totalSize = resp.ContentLength
+	pd.startTime = time.Now()
+	for i := 0; i < pd.concurrency; i++ {
+		go pd.startWorker(ctx...)
+	}
 	pd.lastIndex = int(math.Ceil(float64(resp.ContentLength)/float64(pd.segmentSize))) - 1
 	pd.wg.Add(1)
-	go pd.mergeFile()
+	go pd.mergeFile(checksumAlgo, checksum)
 	go func() {
 		pd.wg.Wait()
 		close(pd.wgDoneCh)
 	}()
+	reportStopped := make(chan struct{})
+	if pd.callback != nil {
+		go pd.reportProgress(reportStopped)
+	} else {
+		close(reportStopped)
+	}
 	totalBytes := resp.ContentLength
 	start := int64(0)
 	for i := 0; ; i++ {
@@ -250,7 +550,12 @@ func (pd *ParallelDownload) Do(ctx ...context.Context) error {
 			rangeStart: start,
 			rangeEnd:   end,
 		}
-		pd.taskCh <- task
+		select {
+		case pd.taskCh <- task:
+		case err := <-pd.errCh:
+			close(pd.doneCh)
+			return err
+		}
 		if end < (totalBytes - 1) {
 			start = end + 1
 			continue
@@ -268,8 +573,13 @@ func (pd *ParallelDownload) Do(ctx ...context.Context) error {
 		}
 		close(pd.doneCh)
 	case err := <-pd.errCh:
+		close(pd.doneCh)
 		return err
 	}
+	if pd.callback != nil { // report the final progress after the periodic reports stopped
+		<-reportStopped
+		pd.callback(pd.progress())
+	}
 	return nil
 }
 
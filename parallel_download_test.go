@@ -0,0 +1,45 @@
+package restys
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/luoxk/restys/internal/tests"
+)
+
+func TestParallelDownload(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "range.txt")
+	sum := sha256.Sum256([]byte(rangeContent))
+	var last ParallelDownloadInfo
+	err := tc().NewParallelDownload("/range").
+		SetTempRootDir(dir).
+		SetSegmentSize(10).
+		SetConcurrency(3).
+		SetRetryCount(2).
+		SetRateLimit(1<<20).
+		SetChecksum("sha256", hex.EncodeToString(sum[:])).
+		SetProgressCallback(func(info ParallelDownloadInfo) {
+			last = info
+		}).
+		SetOutputFile(file).
+		Do()
+	tests.AssertNoError(t, err)
+	b, err := os.ReadFile(file)
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, rangeContent, string(b))
+	tests.AssertEqual(t, int64(len(rangeContent)), last.DownloadedSize)
+	tests.AssertEqual(t, int64(len(rangeContent)), last.TotalSize)
+
+	err = tc().NewParallelDownload("/range").
+		SetTempRootDir(dir).
+		SetSegmentSize(10).
+		SetChecksum("md5", "00000000000000000000000000000000").
+		SetOutputFile(file).
+		Do()
+	tests.AssertEqual(t, true, errors.Is(err, ErrChecksumMismatch))
+}
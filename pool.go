@@ -0,0 +1,69 @@
+package restys
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// maxPooledBufferSize is the max capacity of the buffer which is put back
+// to the pool, the larger ones are left to GC to avoid holding the memory
+// of the occasional large bodies.
+const maxPooledBufferSize = 1 << 20
+
+var bytesBufferPool = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+
+func getBytesBuffer() *bytes.Buffer {
+	return bytesBufferPool.Get().(*bytes.Buffer)
+}
+
+func putBytesBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBufferSize {
+		return
+	}
+	buf.Reset()
+	bytesBufferPool.Put(buf)
+}
+
+// maxPreallocSize is the max body size which is preallocated according to
+// the Content-Length, which guards against the bogus huge Content-Length.
+const maxPreallocSize = 32 << 20
+
+// readAll reads from r until EOF like io.ReadAll. If size (e.g. the
+// Content-Length) is known, the body is read into a slice of that
+// capacity, otherwise into a pooled buffer which is copied out once, both
+// avoid the reallocations of growing the slice.
+func readAll(r io.Reader, size int64) ([]byte, error) {
+	if size < 0 || size > maxPreallocSize {
+		buf := getBytesBuffer()
+		defer putBytesBuffer(buf)
+		_, err := buf.ReadFrom(r)
+		b := make([]byte, buf.Len())
+		copy(b, buf.Bytes())
+		return b, err
+	}
+	// one more byte to read EOF without growing.
+	b := make([]byte, 0, size+1)
+	for {
+		n, err := r.Read(b[len(b):cap(b)])
+		b = b[:len(b)+n]
+		if err != nil {
+			if err == io.EOF {
+				err = nil
+			}
+			return b, err
+		}
+		if len(b) == cap(b) { // longer than size
+			b = append(b, 0)[:len(b)]
+		}
+	}
+}
+
+// copyBuffer copies from src to dst with the pooled buffer. The
+// io.ReaderFrom of dst (e.g. *os.File) is hidden, which would fall back to
+// io.Copy with a new buffer for the response body.
+func copyBuffer(dst io.Writer, src io.Reader) (int64, error) {
+	buf := getCopyBuf()
+	defer putCopyBuf(buf)
+	return io.CopyBuffer(struct{ io.Writer }{dst}, src, buf)
+}
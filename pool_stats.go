@@ -0,0 +1,109 @@
+package restys
+
+import (
+	"io"
+	"net/http"
+)
+
+// HostPoolStats is the statistics of the connection pools to a host, see
+// Transport.PoolStats.
+type HostPoolStats struct {
+	// Idle is the number of the idle connections to the host in the
+	// HTTP/1, HTTP/2 and HTTP/3 pools.
+	Idle int `json:"idle"`
+	// InFlight is the number of requests whose response body is not yet
+	// read to the end or closed.
+	InFlight int64 `json:"in_flight"`
+	// Dials is the total number of connections dialed to the host.
+	Dials uint64 `json:"dials"`
+	// Requests is the total number of requests sent to the host.
+	Requests uint64 `json:"requests"`
+	// ReuseRatio is the ratio of the requests which reused a pooled
+	// connection instead of dialing a new one, between 0 and 1.
+	ReuseRatio float64 `json:"reuse_ratio"`
+}
+
+// PoolStats returns the statistics of the HTTP/1, HTTP/2 and HTTP/3
+// connection pools per host ("host:port"), which helps to tune the
+// SetMaxConnsPerHost, SetMaxIdleConnsPerHost and SetMaxIdleConnTimeout.
+// The counters of the hosts without in-flight requests are dropped once
+// more than 1024 hosts are requested, which keeps the memory bounded.
+func (t *Transport) PoolStats() map[string]HostPoolStats {
+	stats := make(map[string]HostPoolStats)
+	for addr, s := range t.ConnStats.Snapshot() {
+		hs := HostPoolStats{
+			InFlight: s.InFlight,
+			Dials:    s.Dials,
+			Requests: s.Requests,
+		}
+		if s.Requests > 0 && s.Dials < s.Requests {
+			hs.ReuseRatio = float64(s.Requests-s.Dials) / float64(s.Requests)
+		}
+		stats[addr] = hs
+	}
+	addIdle := func(idle map[string]int) {
+		for addr, n := range idle {
+			hs := stats[addr]
+			hs.Idle += n
+			stats[addr] = hs
+		}
+	}
+	addIdle(t.idleConns())
+	if t.t2 != nil {
+		addIdle(t.t2.IdleConns())
+	}
+	if t.t3 != nil {
+		addIdle(t.t3.IdleConns())
+	}
+	return stats
+}
+
+// idleConns returns the number of the idle HTTP/1 connections per host,
+// the HTTP/2 connections are counted by t2.
+func (t *Transport) idleConns() map[string]int {
+	t.idleMu.Lock()
+	defer t.idleMu.Unlock()
+	idle := make(map[string]int)
+	for key, pconns := range t.idleConn {
+		if key.addr == "" {
+			// shared by all hosts through the http proxy.
+			continue
+		}
+		for _, pconn := range pconns {
+			if pconn.alt == nil {
+				idle[key.addr]++
+			}
+		}
+	}
+	return idle
+}
+
+// startPoolRequest records the request in the pool statistics, the
+// returned done must be called once the response body is read or closed.
+func (t *Transport) startPoolRequest(req *http.Request) (done func()) {
+	if req.URL == nil || (req.URL.Scheme != "http" && req.URL.Scheme != "https") {
+		return func() {}
+	}
+	return t.ConnStats.StartRequest(canonicalAddr(req.URL))
+}
+
+// doneOnEOFBody calls done once the response body is read to the end or
+// closed.
+type doneOnEOFBody struct {
+	io.ReadCloser
+	done func()
+}
+
+func (b *doneOnEOFBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil {
+		b.done()
+	}
+	return n, err
+}
+
+func (b *doneOnEOFBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.done()
+	return err
+}
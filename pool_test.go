@@ -0,0 +1,201 @@
+package restys
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/luoxk/restys/internal/tests"
+)
+
+func TestReadAll(t *testing.T) {
+	content := strings.Repeat("restys", 10000)
+	for _, size := range []int64{-1, 0, 10, int64(len(content)), int64(len(content)) + 10} {
+		b, err := readAll(strings.NewReader(content), size)
+		tests.AssertNoError(t, err)
+		tests.AssertEqual(t, content, string(b))
+	}
+	b, err := readAll(strings.NewReader(""), -1)
+	tests.AssertNoError(t, err)
+	tests.AssertNotNil(t, b)
+	tests.AssertEqual(t, 0, len(b))
+
+	var buf bytes.Buffer
+	n, err := copyBuffer(&buf, strings.NewReader(content))
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, int64(len(content)), n)
+	tests.AssertEqual(t, content, buf.String())
+}
+
+// onlyWriter hides io.ReaderFrom of the underlying writer like *os.File
+// does for the response body.
+type onlyWriter struct{ io.Writer }
+
+// onlyReader hides io.WriterTo of the underlying reader like the response
+// body does.
+type onlyReader struct{ io.Reader }
+
+var benchmarkBody = bytes.Repeat([]byte("restys"), 20<<10)
+
+func BenchmarkReadAll(b *testing.B) {
+	b.Run("io.ReadAll", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			io.ReadAll(onlyReader{bytes.NewReader(benchmarkBody)})
+		}
+	})
+	b.Run("known size", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			readAll(onlyReader{bytes.NewReader(benchmarkBody)}, int64(len(benchmarkBody)))
+		}
+	})
+	b.Run("unknown size", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			readAll(onlyReader{bytes.NewReader(benchmarkBody)}, -1)
+		}
+	})
+}
+
+func BenchmarkCopyBuffer(b *testing.B) {
+	b.Run("io.Copy", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			io.Copy(onlyWriter{io.Discard}, onlyReader{bytes.NewReader(benchmarkBody)})
+		}
+	})
+	b.Run("pooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			copyBuffer(onlyWriter{io.Discard}, onlyReader{bytes.NewReader(benchmarkBody)})
+		}
+	})
+}
+
+// benchmarkClient returns a client of the server which replies
+// benchmarkBody, in chunked encoding if chunked is true.
+func benchmarkClient(b *testing.B, chunked bool) *Client {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !chunked {
+			w.Header().Set("Content-Length", strconv.Itoa(len(benchmarkBody)))
+		}
+		w.Write(benchmarkBody)
+	}))
+	b.Cleanup(server.Close)
+	return C().SetBaseURL(server.URL)
+}
+
+func BenchmarkAutoRead(b *testing.B) {
+	for _, chunked := range []bool{false, true} {
+		b.Run(fmt.Sprintf("chunked=%v", chunked), func(b *testing.B) {
+			c := benchmarkClient(b, chunked)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				resp, err := c.R().Get("/")
+				if err != nil {
+					b.Fatal(err)
+				}
+				resp.Bytes()
+			}
+		})
+	}
+}
+
+func BenchmarkAutoReadSpillThreshold(b *testing.B) {
+	c := benchmarkClient(b, false).SetResponseBodySpillThreshold(1 << 20)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		resp, err := c.R().Get("/")
+		if err != nil {
+			b.Fatal(err)
+		}
+		resp.Bytes()
+	}
+}
+
+func BenchmarkAutoReadTransformer(b *testing.B) {
+	c := benchmarkClient(b, false).SetResponseBodyTransformer(func(rawBody []byte, req *Request, resp *Response) ([]byte, error) {
+		for i, c := range rawBody { // in place
+			if 'a' <= c && c <= 'z' {
+				rawBody[i] = c - 'a' + 'A'
+			}
+		}
+		return rawBody, nil
+	})
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		resp, err := c.R().Get("/")
+		if err != nil {
+			b.Fatal(err)
+		}
+		resp.Bytes()
+	}
+}
+
+func BenchmarkParseRequestHeader(b *testing.B) {
+	c := C()
+	for i := 0; i < 30; i++ {
+		c.SetCommonHeader("X-Common-Header-"+strconv.Itoa(i), "value")
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r := c.R().SetHeader("X-Request", "value")
+		parseRequestHeader(c, r)
+		_ = r.Headers.Clone()
+	}
+}
+
+// staticRoundTripper replies 200 with body without the network, so the
+// benchmarks measure the client overhead only.
+type staticRoundTripper struct{ body []byte }
+
+func (rt staticRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"text/plain"}},
+		Body:          io.NopCloser(bytes.NewReader(rt.body)),
+		ContentLength: int64(len(rt.body)),
+		Request:       req,
+	}, nil
+}
+
+func BenchmarkRoundTrip(b *testing.B) {
+	newClient := func() *Client {
+		return C().SetBaseURL("bench://example.com").SetCommonHeader("User-Agent", "restys").
+			RegisterProtocol("bench", staticRoundTripper{body: []byte("restys")})
+	}
+	b.Run("single", func(b *testing.B) {
+		c := newClient()
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := c.R().Get("/"); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("retry", func(b *testing.B) {
+		c := newClient().SetCommonRetryCount(2).SetCommonRetryFixedInterval(0).SetCommonRetryCondition(func(resp *Response, err error) bool {
+			return resp.Request.RetryAttempt < 2
+		})
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := c.R().Get("/"); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,137 @@
+package restys
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	urlpkg "net/url"
+	"strings"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// proxyBypass matches the hosts which are connected directly without the
+// proxy, the patterns are in the NO_PROXY syntax.
+type proxyBypass struct {
+	all      bool
+	prefixes []netip.Prefix
+	hosts    []proxyBypassHost
+}
+
+type proxyBypassHost struct {
+	// host is the host name or IP address, which starts with "." if only
+	// the subdomains are matched.
+	host string
+	port string
+}
+
+func newProxyBypass(patterns []string) *proxyBypass {
+	b := &proxyBypass{}
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		switch {
+		case pattern == "":
+			continue
+		case pattern == "*":
+			b.all = true
+			continue
+		}
+		if prefix, err := netip.ParsePrefix(pattern); err == nil {
+			b.prefixes = append(b.prefixes, prefix.Masked())
+			continue
+		}
+		if addr, err := netip.ParseAddr(strings.Trim(pattern, "[]")); err == nil {
+			b.prefixes = append(b.prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		host, port := pattern, ""
+		if h, p, err := net.SplitHostPort(pattern); err == nil {
+			host, port = h, p
+		}
+		// "*.example.com" is the same as ".example.com".
+		host = strings.TrimPrefix(host, "*")
+		if addr, err := netip.ParseAddr(host); err == nil && port != "" {
+			host = addr.Unmap().String()
+		}
+		b.hosts = append(b.hosts, proxyBypassHost{host: host, port: port})
+	}
+	if !b.all && len(b.prefixes) == 0 && len(b.hosts) == 0 {
+		return nil
+	}
+	return b
+}
+
+// match reports whether the request to u bypasses the proxy.
+func (b *proxyBypass) match(u *urlpkg.URL) bool {
+	if b == nil {
+		return false
+	}
+	if b.all {
+		return true
+	}
+	host := strings.ToLower(u.Hostname())
+	port := u.Port()
+	if port == "" {
+		port = portMap[u.Scheme]
+	}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		addr = addr.Unmap()
+		for _, prefix := range b.prefixes {
+			if prefix.Contains(addr) {
+				return true
+			}
+		}
+		host = addr.String()
+	}
+	for _, h := range b.hosts {
+		if h.port != "" && h.port != port {
+			continue
+		}
+		if strings.HasPrefix(h.host, ".") {
+			if strings.HasSuffix(host, h.host) {
+				return true
+			}
+		} else if host == h.host || strings.HasSuffix(host, "."+h.host) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetProxyBypass set the hosts which are connected directly without the
+// proxy set by SetProxy, SetProxyURL, SetProxyFromEnvironment or
+// SetProxyPool, the patterns are in the NO_PROXY syntax:
+//
+//   - "*" bypasses the proxy for all hosts.
+//   - The IP address (e.g. "192.168.1.1") or CIDR (e.g. "10.0.0.0/8")
+//     matches the IP hosts in it.
+//   - The domain (e.g. "example.com") matches itself and its subdomains,
+//     the domain with a leading "." or "*." (e.g. ".example.com") matches
+//     only the subdomains.
+//   - The optional port (e.g. "example.com:8080") restricts the match to
+//     the port.
+//
+// It is applied to all requests fired from the client, but the proxy of
+// WithProxy takes precedence. Call it without patterns to clear the list.
+func (c *Client) SetProxyBypass(patterns ...string) *Client {
+	c.Transport.proxyBypass = newProxyBypass(patterns)
+	return c
+}
+
+// SetProxyFromEnvironment set the proxy from the HTTP_PROXY, HTTPS_PROXY
+// and NO_PROXY environment variables (or the lowercase versions), the
+// NO_PROXY supports the IP, CIDR and domain suffix rules like
+// SetProxyBypass, and the requests to localhost and the loopback addresses
+// are never proxied.
+//
+// The environment variables are read when it's called, unlike the default
+// http.ProxyFromEnvironment which reads them only once for the whole
+// process, so the same binary works inside and outside the corporate
+// network.
+func (c *Client) SetProxyFromEnvironment() *Client {
+	proxyFunc := httpproxy.FromEnvironment().ProxyFunc()
+	c.SetProxy(func(req *http.Request) (*urlpkg.URL, error) {
+		return proxyFunc(req.URL)
+	})
+	return c
+}
@@ -0,0 +1,91 @@
+package restys
+
+import (
+	"net/http"
+	"net/http/httptest"
+	urlpkg "net/url"
+	"testing"
+
+	"github.com/luoxk/restys/internal/tests"
+)
+
+func TestProxyBypassMatch(t *testing.T) {
+	b := newProxyBypass([]string{"10.0.0.0/8", "192.168.1.1", "example.com", ".internal.net", "*.corp.org", "api.test:8443", "[::1]:8080"})
+	for rawURL, want := range map[string]bool{
+		"http://10.1.2.3/":             true,
+		"http://11.1.2.3/":             false,
+		"http://192.168.1.1:8080/":     true,
+		"http://192.168.1.2/":          false,
+		"https://example.com/":         true,
+		"https://www.Example.com/":     true,
+		"https://notexample.com/":      false,
+		"https://a.internal.net/":      true,
+		"https://internal.net/":        false,
+		"https://b.corp.org/":          true,
+		"https://corp.org/":            false,
+		"https://api.test:8443/":       true,
+		"https://api.test/":            false,
+		"http://[::1]:8080/":           true,
+		"http://[::1]/":                false,
+		"http://[::ffff:10.0.0.1]:80/": true,
+	} {
+		u, err := urlpkg.Parse(rawURL)
+		tests.AssertNoError(t, err)
+		if b.match(u) != want {
+			t.Errorf("match(%s) = %v, want %v", rawURL, !want, want)
+		}
+	}
+	tests.AssertEqual(t, true, newProxyBypass([]string{"*"}).match(&urlpkg.URL{Host: "any.host"}))
+	tests.AssertIsNil(t, newProxyBypass([]string{"", " "}))
+}
+
+func TestSetProxyBypass(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("proxy"))
+	}))
+	defer proxy.Close()
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("direct"))
+	}))
+	defer target.Close()
+
+	c := C().SetProxyURL(proxy.URL)
+	resp, err := c.R().Get(target.URL)
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "proxy", resp.String())
+
+	c.SetProxyBypass("127.0.0.0/8")
+	resp, err = c.R().Get(target.URL)
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "direct", resp.String())
+	tests.AssertEqual(t, "direct", c.Clone().R().MustGet(target.URL).String())
+
+	c.SetProxyBypass()
+	resp, err = c.R().Get(target.URL)
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "proxy", resp.String())
+}
+
+func TestSetProxyFromEnvironment(t *testing.T) {
+	t.Setenv("HTTP_PROXY", "http://proxy.example.com:3128")
+	t.Setenv("HTTPS_PROXY", "http://secure-proxy.example.com:3129")
+	t.Setenv("NO_PROXY", "10.0.0.0/8,.internal.net")
+	c := C().SetProxyFromEnvironment()
+	for rawURL, want := range map[string]string{
+		"http://example.com/":      "http://proxy.example.com:3128",
+		"https://example.com/":     "http://secure-proxy.example.com:3129",
+		"http://10.1.2.3/":         "",
+		"https://api.internal.net": "",
+		"http://localhost:8080/":   "",
+	} {
+		req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+		tests.AssertNoError(t, err)
+		u, err := c.Proxy(req)
+		tests.AssertNoError(t, err)
+		got := ""
+		if u != nil {
+			got = u.String()
+		}
+		tests.AssertEqual(t, want, got)
+	}
+}
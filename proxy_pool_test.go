@@ -0,0 +1,171 @@
+package restys
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	urlpkg "net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/luoxk/restys/internal/tests"
+)
+
+func newTestProxy(t *testing.T, name string, status int) string {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte(name))
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func TestProxyPoolStrategies(t *testing.T) {
+	a, b := newTestProxy(t, "a", http.StatusOK), newTestProxy(t, "b", http.StatusOK)
+	get := func(c *Client, url string, n int) (names string) {
+		for i := 0; i < n; i++ {
+			resp, err := c.R().Get(url)
+			assertSuccess(t, resp, err)
+			names += resp.String()
+		}
+		return
+	}
+
+	pool := NewRotatingProxyPool(ProxyRoundRobin)
+	tests.AssertNoError(t, pool.AddProxy(a, 1))
+	tests.AssertNoError(t, pool.AddProxy(b, 1))
+	tests.AssertEqual(t, "ababa", get(C().SetProxyPool(pool), "http://example.com/", 5))
+
+	pool = NewRotatingProxyPool(ProxyWeighted)
+	tests.AssertNoError(t, pool.AddProxy(a, 3))
+	tests.AssertNoError(t, pool.AddProxy(b, 1))
+	tests.AssertEqual(t, "aabaaaba", get(C().SetProxyPool(pool), "http://example.com/", 8))
+	tests.AssertEqual(t, uint64(6), pool.Status()[0].Requests)
+
+	pool = NewRotatingProxyPool(ProxySticky)
+	tests.AssertNoError(t, pool.AddProxy(a, 1))
+	tests.AssertNoError(t, pool.AddProxy(b, 1))
+	c := C().SetProxyPool(pool)
+	for _, host := range []string{"a.example.com", "b.example.com", "c.example.com"} {
+		names := get(c, "http://"+host+"/", 3)
+		tests.AssertEqual(t, names[:1]+names[:1]+names[:1], names)
+	}
+
+	pool = NewRotatingProxyPool(ProxyRandom)
+	tests.AssertNoError(t, pool.AddProxy(a, 1))
+	names := get(C().SetProxyPool(pool), "http://example.com/", 3)
+	tests.AssertEqual(t, "aaa", names)
+
+	tests.AssertErrorContains(t, pool.AddProxy("ftp://127.0.0.1:21", 1), "unsupported proxy scheme")
+}
+
+func TestProxyPoolHealthCheck(t *testing.T) {
+	good, bad := newTestProxy(t, "good", http.StatusOK), newTestProxy(t, "bad", http.StatusBadGateway)
+	var ejected, readmitted []string
+	pool := NewRotatingProxyPool(ProxyRoundRobin).
+		SetHealthCheck(2, 50*time.Millisecond).
+		OnEject(func(proxy *urlpkg.URL, err error) {
+			ejected = append(ejected, proxy.String())
+			tests.AssertErrorContains(t, err, "bad status code 502")
+		}).
+		OnReadmit(func(proxy *urlpkg.URL) {
+			readmitted = append(readmitted, proxy.String())
+		})
+	tests.AssertNoError(t, pool.AddProxy(good, 1))
+	tests.AssertNoError(t, pool.AddProxy(bad, 1))
+	c := C().SetProxyPool(pool)
+
+	statuses := ""
+	for i := 0; i < 6; i++ {
+		resp, err := c.R().Get("http://example.com/")
+		tests.AssertNoError(t, err)
+		statuses += resp.String() + ","
+	}
+	tests.AssertEqual(t, "good,bad,good,bad,good,good,", statuses)
+	tests.AssertEqual(t, []string{bad}, ejected)
+	status := pool.Status()
+	tests.AssertEqual(t, uint64(2), status[1].Failures)
+	tests.AssertEqual(t, false, status[1].EjectedUntil.IsZero())
+
+	// re-admitted after the cooldown.
+	time.Sleep(60 * time.Millisecond)
+	resp, err := c.R().Get("http://example.com/")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, []string{bad}, readmitted)
+	tests.AssertEqual(t, true, pool.Status()[1].EjectedUntil.IsZero())
+	tests.AssertNotNil(t, resp)
+
+	// the dial failures eject the proxy too, and no proxy is available
+	// after all proxies are ejected.
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	pool = NewRotatingProxyPool(ProxyRoundRobin).SetHealthCheck(1, time.Hour)
+	tests.AssertNoError(t, pool.AddProxy(closed.URL, 1))
+	c = C().SetProxyPool(pool)
+	_, err = c.R().Get("http://example.com/")
+	tests.AssertNotNil(t, err)
+	_, err = c.R().Get("http://example.com/")
+	tests.AssertEqual(t, true, errors.Is(err, ErrNoProxyAvailable))
+}
+
+// newConnectProxy returns the http proxy which tunnels the CONNECT
+// requests, connects counts the tunnels.
+func newConnectProxy(t *testing.T, connects *atomic.Int32) string {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		connects.Add(1)
+		target, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		conn, brw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			target.Close()
+			return
+		}
+		go func() {
+			io.Copy(target, brw)
+			target.Close()
+		}()
+		io.Copy(conn, target)
+		conn.Close()
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func TestProxyPoolHTTP2(t *testing.T) {
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	}))
+	ts.EnableHTTP2 = true
+	ts.StartTLS()
+	defer ts.Close()
+
+	var connects1, connects2 atomic.Int32
+	proxy1, proxy2 := newConnectProxy(t, &connects1), newConnectProxy(t, &connects2)
+	pool := NewRotatingProxyPool(ProxyRoundRobin)
+	tests.AssertNoError(t, pool.AddProxy(proxy1, 1))
+	tests.AssertNoError(t, pool.AddProxy(proxy2, 1))
+	c := C().EnableInsecureSkipVerify().SetProxyPool(pool)
+	for i := 0; i < 4; i++ {
+		resp, err := c.R().Get(ts.URL)
+		assertSuccess(t, resp, err)
+		tests.AssertEqual(t, "HTTP/2.0", resp.String())
+	}
+	// the proxy is rotated for each request, and the HTTP/2 connection
+	// through each proxy is reused.
+	status := pool.Status()
+	tests.AssertEqual(t, uint64(2), status[0].Requests)
+	tests.AssertEqual(t, uint64(2), status[1].Requests)
+	tests.AssertEqual(t, int32(1), connects1.Load())
+	tests.AssertEqual(t, int32(1), connects2.Load())
+}
@@ -0,0 +1,202 @@
+package restys
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/quic-go/quic-go"
+
+	"github.com/luoxk/restys/internal/http3"
+)
+
+// QUICFingerprint is the client fingerprint of the HTTP/3 connections, see
+// Client.SetQUICFingerprint.
+//
+// The TLS handshake of QUIC is done by crypto/tls inside quic-go, so only
+// the supported groups of the ClientHello and the values of the QUIC
+// transport parameters can be customized, the cipher suites, the order of
+// the TLS extensions and the order of the transport parameters are always
+// the ones of quic-go.
+type QUICFingerprint struct {
+	// CurvePreferences is the supported groups of the ClientHello in order,
+	// the key share is sent for the first one.
+	CurvePreferences []tls.CurveID
+	// InitialStreamReceiveWindow is sent as the initial_max_stream_data_*
+	// transport parameters.
+	InitialStreamReceiveWindow uint64
+	// MaxStreamReceiveWindow is the max stream-level flow control window.
+	MaxStreamReceiveWindow uint64
+	// InitialConnectionReceiveWindow is sent as the initial_max_data
+	// transport parameter.
+	InitialConnectionReceiveWindow uint64
+	// MaxConnectionReceiveWindow is the max connection-level flow control
+	// window.
+	MaxConnectionReceiveWindow uint64
+	// MaxIncomingUniStreams is sent as the initial_max_streams_uni
+	// transport parameter.
+	MaxIncomingUniStreams int64
+	// MaxIdleTimeout is sent as the max_idle_timeout transport parameter.
+	MaxIdleTimeout time.Duration
+	// KeepAlivePeriod is the period of the keep-alive packets, default is
+	// 10 seconds.
+	KeepAlivePeriod time.Duration
+}
+
+func (fp *QUICFingerprint) quicConfig() *quic.Config {
+	cfg := &quic.Config{
+		Versions:                       []quic.Version{http3.SupportedVersions[0]},
+		MaxIncomingStreams:             -1, // don't allow the server to create bidirectional streams
+		KeepAlivePeriod:                10 * time.Second,
+		InitialStreamReceiveWindow:     fp.InitialStreamReceiveWindow,
+		MaxStreamReceiveWindow:         fp.MaxStreamReceiveWindow,
+		InitialConnectionReceiveWindow: fp.InitialConnectionReceiveWindow,
+		MaxConnectionReceiveWindow:     fp.MaxConnectionReceiveWindow,
+		MaxIncomingUniStreams:          fp.MaxIncomingUniStreams,
+		MaxIdleTimeout:                 fp.MaxIdleTimeout,
+	}
+	if fp.KeepAlivePeriod > 0 {
+		cfg.KeepAlivePeriod = fp.KeepAlivePeriod
+	}
+	return cfg
+}
+
+func (t *Transport) applyQUICFingerprint() {
+	if t.t3 == nil {
+		return
+	}
+	if t.quicFingerprint == nil {
+		t.t3.QUICConfig = nil
+		t.t3.CurvePreferences = nil
+		return
+	}
+	t.t3.QUICConfig = t.quicFingerprint.quicConfig()
+	t.t3.CurvePreferences = t.quicFingerprint.CurvePreferences
+}
+
+// SetQUICFingerprint set the client fingerprint of the HTTP/3 connections,
+// which also takes effect if HTTP/3 is enabled later (e.g.
+// EnableForceHTTP3), it should be set before sending requests. Pass nil to
+// restore the default one.
+func (t *Transport) SetQUICFingerprint(fp *QUICFingerprint) *Transport {
+	t.quicFingerprint = fp
+	t.applyQUICFingerprint()
+	return t
+}
+
+// SetQUICFingerprint set the client fingerprint of the HTTP/3 connections,
+// see Transport.SetQUICFingerprint.
+func (c *Client) SetQUICFingerprint(fp *QUICFingerprint) *Client {
+	c.Transport.SetQUICFingerprint(fp)
+	return c
+}
+
+// quicCurves is the groups of the ja3 string which are supported by
+// crypto/tls.
+var quicCurves = map[uint64]tls.CurveID{
+	uint64(tls.X25519):    tls.X25519,
+	uint64(tls.CurveP256): tls.CurveP256,
+	uint64(tls.CurveP384): tls.CurveP384,
+	uint64(tls.CurveP521): tls.CurveP521,
+}
+
+// quicCipherSuites is the cipher suites crypto/tls offers in QUIC, which
+// are the TLS 1.3 ones.
+var quicCipherSuites = map[uint64]bool{
+	uint64(tls.TLS_AES_128_GCM_SHA256):       true,
+	uint64(tls.TLS_AES_256_GCM_SHA384):       true,
+	uint64(tls.TLS_CHACHA20_POLY1305_SHA256): true,
+}
+
+// quicExtensions is the TLS extensions crypto/tls may send in the
+// ClientHello of QUIC.
+var quicExtensions = map[uint64]bool{
+	0:     true, // server_name
+	5:     true, // status_request
+	10:    true, // supported_groups
+	11:    true, // ec_point_formats
+	13:    true, // signature_algorithms
+	16:    true, // application_layer_protocol_negotiation
+	18:    true, // signed_certificate_timestamp
+	23:    true, // extended_master_secret
+	35:    true, // session_ticket
+	41:    true, // pre_shared_key
+	42:    true, // early_data
+	43:    true, // supported_versions
+	44:    true, // cookie
+	45:    true, // psk_key_exchange_modes
+	50:    true, // signature_algorithms_cert
+	51:    true, // key_share
+	57:    true, // quic_transport_parameters
+	65037: true, // encrypted_client_hello
+	65281: true, // renegotiation_info
+}
+
+// createQUICFingerprintWithJa3Str creates the QUICFingerprint with the
+// supported groups of the ja3 string, the groups not supported by
+// crypto/tls (e.g. GREASE and the post-quantum ones) are skipped. The
+// cipher suites and the extensions can't be customized, an error is
+// returned if the ja3 string contains the ones crypto/tls doesn't send in
+// QUIC.
+func createQUICFingerprintWithJa3Str(ja3Str string) (*QUICFingerprint, error) {
+	if err := ValidateJa3(ja3Str); err != nil {
+		return nil, err
+	}
+	fields := strings.Split(ja3Str, ",")
+	if err := checkQUICJa3Field(fields[1], quicCipherSuites, "cipher suite"); err != nil {
+		return nil, err
+	}
+	if err := checkQUICJa3Field(fields[2], quicExtensions, "extension"); err != nil {
+		return nil, err
+	}
+	fp := &QUICFingerprint{}
+	for _, curve := range strings.Split(fields[3], "-") {
+		n, _ := strconv.ParseUint(curve, 10, 16)
+		if id, ok := quicCurves[n]; ok {
+			fp.CurvePreferences = append(fp.CurvePreferences, id)
+		}
+	}
+	return fp, nil
+}
+
+// checkQUICJa3Field returns an error if the "-" separated values of the ja3
+// field are not in supported, the GREASE values are ignored.
+func checkQUICJa3Field(field string, supported map[uint64]bool, name string) error {
+	if field == "" {
+		return nil
+	}
+	for _, v := range strings.Split(field, "-") {
+		n, _ := strconv.ParseUint(v, 10, 16)
+		if !isGREASE(uint16(n)) && !supported[n] {
+			return fmt.Errorf("ja3Str %s %s is not supported by quic", name, v)
+		}
+	}
+	return nil
+}
+
+// SetJa3ForHTTP3 set the client fingerprint of the HTTP/3 connections with
+// the ja3 string, which is applied partially as the TLS handshake of QUIC
+// is done by crypto/tls: only the supported groups are applied, the cipher
+// suites and the extensions are checked to be the ones crypto/tls sends but
+// their order is not applied, see Transport.SetQUICFingerprint. The client
+// is left unchanged and the error is logged if the ja3 string is malformed
+// or can't be applied, use TrySetJa3ForHTTP3 to get the error.
+func (c *Client) SetJa3ForHTTP3(ja3Str string) *Client {
+	if _, err := c.TrySetJa3ForHTTP3(ja3Str); err != nil {
+		c.log.Errorf("failed to create quic fingerprint from ja3: %v", err)
+	}
+	return c
+}
+
+// TrySetJa3ForHTTP3 is like SetJa3ForHTTP3, but returns the error if the
+// ja3 string is malformed or can't be applied, in which case the client is
+// left unchanged.
+func (c *Client) TrySetJa3ForHTTP3(ja3Str string) (*Client, error) {
+	fp, err := createQUICFingerprintWithJa3Str(ja3Str)
+	if err != nil {
+		return c, err
+	}
+	return c.SetQUICFingerprint(fp), nil
+}
@@ -0,0 +1,47 @@
+package restys
+
+import (
+	"crypto/tls"
+	"testing"
+	"time"
+
+	"github.com/luoxk/restys/internal/http3"
+	"github.com/luoxk/restys/internal/tests"
+)
+
+func TestQUICFingerprint(t *testing.T) {
+	fp, err := createQUICFingerprintWithJa3Str("771,4865-4866-4867,0-23-65281-10-11-16-13-51-45-43,4588-29-23-24-2570,0")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, []tls.CurveID{tls.X25519, tls.CurveP256, tls.CurveP384}, fp.CurvePreferences)
+	_, err = createQUICFingerprintWithJa3Str("771,4865")
+	tests.AssertErrorContains(t, err, "ja3Str format error")
+
+	c := tc().SetQUICFingerprint(&QUICFingerprint{
+		CurvePreferences:               []tls.CurveID{tls.CurveP256},
+		InitialConnectionReceiveWindow: 15 << 20,
+		MaxIdleTimeout:                 30 * time.Second,
+	})
+	tests.AssertNotNil(t, c.quicFingerprint)
+	// HTTP/3 may be not supported by the go version.
+	c.t3 = &http3.RoundTripper{Options: &c.Transport.Options}
+	c.applyQUICFingerprint()
+	tests.AssertEqual(t, []tls.CurveID{tls.CurveP256}, c.t3.CurvePreferences)
+	tests.AssertEqual(t, uint64(15<<20), c.t3.QUICConfig.InitialConnectionReceiveWindow)
+	tests.AssertEqual(t, 30*time.Second, c.t3.QUICConfig.MaxIdleTimeout)
+	tests.AssertEqual(t, 10*time.Second, c.t3.QUICConfig.KeepAlivePeriod)
+	tests.AssertEqual(t, 1, len(c.t3.QUICConfig.Versions))
+	tests.AssertNotNil(t, c.Clone().quicFingerprint)
+
+	c.SetJa3ForHTTP3("771,4865,0-10,29,0")
+	tests.AssertEqual(t, []tls.CurveID{tls.X25519}, c.t3.CurvePreferences)
+	// the ciphers and the extensions crypto/tls doesn't send in QUIC.
+	_, err = c.TrySetJa3ForHTTP3("771,4865-49195,0-10,23,0")
+	tests.AssertErrorContains(t, err, "cipher suite 49195 is not supported by quic")
+	_, err = c.TrySetJa3ForHTTP3("771,2570-4865,2570-0-10-17513,23,0")
+	tests.AssertErrorContains(t, err, "extension 17513 is not supported by quic")
+	tests.AssertEqual(t, []tls.CurveID{tls.X25519}, c.t3.CurvePreferences)
+
+	c.SetQUICFingerprint(nil)
+	tests.AssertIsNil(t, c.t3.QUICConfig)
+	tests.AssertEqual(t, 0, len(c.t3.CurvePreferences))
+}
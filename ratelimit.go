@@ -0,0 +1,119 @@
+package restys
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// rateLimiter is a token bucket which limits the number of bytes per
+// second, it is safe for concurrent use, so it can be shared by multiple
+// readers or writers to cap their total bandwidth.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64 // bytes per second
+	burst  int
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(bytesPerSec int64) *rateLimiter {
+	burst := int(bytesPerSec)
+	if burst > 64<<10 {
+		burst = 64 << 10
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{
+		rate:   float64(bytesPerSec),
+		burst:  burst,
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// wait blocks until n bytes are allowed to be transferred, or ctx is done.
+func (l *rateLimiter) wait(ctx context.Context, n int) error {
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > float64(l.burst) {
+		l.tokens = float64(l.burst)
+	}
+	l.last = now
+	l.tokens -= float64(n)
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+	if delay <= 0 {
+		return nil
+	}
+	if ctx == nil {
+		time.Sleep(delay)
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// rateLimitWriter is an io.Writer that writes no faster than the limiter allows.
+type rateLimitWriter struct {
+	io.Writer
+	limiter *rateLimiter
+	ctx     context.Context
+}
+
+func (w *rateLimitWriter) Write(p []byte) (n int, err error) {
+	for len(p) > 0 {
+		chunk := p
+		if len(chunk) > w.limiter.burst {
+			chunk = chunk[:w.limiter.burst]
+		}
+		if err = w.limiter.wait(w.ctx, len(chunk)); err != nil {
+			return
+		}
+		var nn int
+		nn, err = w.Writer.Write(chunk)
+		n += nn
+		if err != nil {
+			return
+		}
+		p = p[nn:]
+	}
+	return
+}
+
+func (w *rateLimitWriter) Close() error {
+	closeq(w.Writer)
+	return nil
+}
+
+// rateLimitReader is an io.ReadCloser that reads no faster than the limiter allows.
+type rateLimitReader struct {
+	io.ReadCloser
+	limiter *rateLimiter
+	ctx     context.Context
+}
+
+func (r *rateLimitReader) Read(p []byte) (n int, err error) {
+	if len(p) > r.limiter.burst {
+		p = p[:r.limiter.burst]
+	}
+	n, err = r.ReadCloser.Read(p)
+	if n > 0 {
+		if e := r.limiter.wait(r.ctx, n); e != nil && err == nil {
+			err = e
+		}
+	}
+	return
+}
@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"net/url"
 	"strings"
+	"time"
 )
 
 // RedirectPolicy represents the redirect policy for Client.
@@ -132,3 +134,147 @@ func AlwaysCopyHeaderRedirectPolicy(headers ...string) RedirectPolicy {
 		return nil
 	}
 }
+
+// RedirectBehavior controls how the redirect request is made from the
+// previous request, the zero value is the default behavior of Go, which is
+// compatible with the browsers.
+type RedirectBehavior struct {
+	// KeepMethodOn301And302 keeps the method and re-sends the body on 301
+	// and 302 like 307 and 308, which is what RFC 9110 intends, instead of
+	// changing the method other than GET and HEAD to GET without body like
+	// the browsers. The method is always changed to GET on 303.
+	KeepMethodOn301And302 bool
+	// DropBodyOn307And308 keeps the method but doesn't re-send the body on
+	// 307 and 308.
+	DropBodyOn307And308 bool
+	// KeepContentHeaders keeps the headers of the request body
+	// (Content-Type, Content-Encoding, Content-Language and
+	// Content-Location) when the body is not re-sent, which are stripped
+	// by default.
+	KeepContentHeaders bool
+}
+
+// redirectContentHeaders is the headers of the request body which are
+// stripped by net/http if the body is not re-sent on redirect.
+var redirectContentHeaders = []string{"Content-Type", "Content-Encoding", "Content-Language", "Content-Location"}
+
+var errRedirectBodyNotReplayable = errors.New("redirect requires re-sending the request body which is not replayable")
+
+// apply rewrites the redirect request req which is made by net/http
+// according to the behavior.
+func (b *RedirectBehavior) apply(req *http.Request, via []*http.Request) error {
+	if req.Response == nil || len(via) == 0 {
+		return nil
+	}
+	prev, ireq := via[len(via)-1], via[0]
+	// prevBody reports whether the previous hop's request was sent with
+	// body, which may have been changed by the earlier redirects.
+	prevBody := prev.Body != nil && prev.Body != http.NoBody
+	method, body := req.Method, req.Body != nil
+	switch req.Response.StatusCode {
+	case http.StatusMovedPermanently, http.StatusFound:
+		if b.KeepMethodOn301And302 {
+			method, body = prev.Method, prevBody
+		}
+	case http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		body = prevBody && !b.DropBodyOn307And308
+	}
+	req.Method = method
+	if body && req.Body == nil {
+		if ireq.GetBody == nil {
+			return errRedirectBodyNotReplayable
+		}
+		rc, err := ireq.GetBody()
+		if err != nil {
+			return err
+		}
+		req.Body, req.GetBody, req.ContentLength = rc, ireq.GetBody, ireq.ContentLength
+	} else if !body && req.Body != nil {
+		req.Body.Close()
+		req.Body, req.GetBody, req.ContentLength = nil, nil, 0
+	}
+	for _, k := range redirectContentHeaders {
+		if body || b.KeepContentHeaders {
+			if vv, ok := ireq.Header[k]; ok && req.Header.Get(k) == "" {
+				req.Header[k] = vv
+			}
+		} else {
+			delete(req.Header, k)
+		}
+	}
+	return nil
+}
+
+// RedirectHop is the details of a redirect hop, see Response.RedirectHistory.
+type RedirectHop struct {
+	// Method is the method of the hop's request.
+	Method string
+	// URL is the URL of the hop's request.
+	URL *url.URL
+	// StatusCode is the status code of the hop's response, e.g. 302.
+	StatusCode int
+	// Status is the status of the hop's response, e.g. "302 Found".
+	Status string
+	// Header is the header of the hop's response.
+	Header http.Header
+	// Cookies is the cookies set by the hop's response.
+	Cookies []*http.Cookie
+	// StartTime is the time when the hop's request started, it's zero if
+	// the redirect is not followed by the redirect policy of the client
+	// (see Client.SetRedirectPolicy).
+	StartTime time.Time
+	// Duration is the time from the start of the hop's request until the
+	// response is received.
+	Duration time.Duration
+}
+
+type redirectResponseKeyType int
+
+// redirectResponseKey is the context key of the *Response, which records
+// the time of the redirects, and its Request provides the request level
+// redirect policy and hook.
+const redirectResponseKey redirectResponseKeyType = iota
+
+// redirectResponse returns the *Response of the redirect request req, it's
+// nil if req is not sent by the Client.
+func redirectResponse(req *http.Request) *Response {
+	resp, _ := req.Context().Value(redirectResponseKey).(*Response)
+	return resp
+}
+
+// RedirectHistory returns the redirect hops in order before the final
+// response, e.g. the login flows and tracking redirect chains, it's empty
+// if there is no redirect.
+func (r *Response) RedirectHistory() []RedirectHop {
+	if r.Response == nil || r.Response.Request == nil {
+		return nil
+	}
+	var resps []*http.Response
+	for prev := r.Response.Request.Response; prev != nil && prev.Request != nil; prev = prev.Request.Response {
+		resps = append(resps, prev)
+	}
+	if len(resps) == 0 {
+		return nil
+	}
+	hops := make([]RedirectHop, len(resps))
+	for i := range hops {
+		resp := resps[len(resps)-1-i]
+		hop := RedirectHop{
+			Method:     resp.Request.Method,
+			URL:        resp.Request.URL,
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			Header:     resp.Header,
+			Cookies:    resp.Cookies(),
+		}
+		if i < len(r.redirects) {
+			hop.StartTime = r.Request.StartTime
+			if i > 0 {
+				hop.StartTime = r.redirects[i-1]
+			}
+			hop.Duration = r.redirects[i].Sub(hop.StartTime)
+		}
+		hops[i] = hop
+	}
+	return hops
+}
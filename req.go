@@ -106,11 +106,11 @@ func cloneUrlValues(v url.Values) url.Values {
 	return vv
 }
 
-func cloneMap(h map[string]string) map[string]string {
+func cloneMap[K comparable, V any](h map[K]V) map[K]V {
 	if h == nil {
 		return nil
 	}
-	m := make(map[string]string)
+	m := make(map[K]V, len(h))
 	for k, v := range h {
 		m[k] = v
 	}
@@ -7,6 +7,7 @@ import (
 	"github.com/luoxk/restys/internal/header"
 	"github.com/luoxk/restys/internal/tests"
 	"go/token"
+	"golang.org/x/text/encoding/japanese"
 	"golang.org/x/text/encoding/simplifiedchinese"
 	"golang.org/x/text/transform"
 	"io"
@@ -20,6 +21,7 @@ import (
 	"strings"
 	"sync"
 	"testing"
+	"time"
 	"unsafe"
 )
 
@@ -46,7 +48,7 @@ func createTestServer() *httptest.Server {
 func handleHTTP(w http.ResponseWriter, r *http.Request) {
 	w.Header().Add("Method", r.Method)
 	switch r.Method {
-	case http.MethodGet:
+	case http.MethodGet, http.MethodHead:
 		handleGet(w, r)
 	case http.MethodPost:
 		handlePost(w, r)
@@ -222,6 +224,8 @@ func toGbk(s string) []byte {
 	return d
 }
 
+const rangeContent = "0123456789abcdefghijklmnopqrstuvwxyz"
+
 func handleGet(w http.ResponseWriter, r *http.Request) {
 	switch r.URL.Path {
 	case "/":
@@ -279,6 +283,9 @@ func handleGet(w http.ResponseWriter, r *http.Request) {
 	case "/unlimited-redirect":
 		w.Header().Set("Location", "/unlimited-redirect")
 		w.WriteHeader(http.StatusMovedPermanently)
+	case "/redirect-to":
+		w.Header().Set(header.Location, r.URL.Query().Get("url"))
+		w.WriteHeader(http.StatusFound)
 	case "/redirect-to-other":
 		w.Header().Set("Location", "http://dummy.local/test")
 		w.WriteHeader(http.StatusMovedPermanently)
@@ -290,6 +297,30 @@ func handleGet(w http.ResponseWriter, r *http.Request) {
 	case "/gbk":
 		w.Header().Set(header.ContentType, "text/plain; charset=gbk")
 		w.Write(toGbk("我是roc"))
+	case "/shift-jis-wrong-charset":
+		b, err := japanese.ShiftJIS.NewEncoder().Bytes([]byte("こんにちは"))
+		if err != nil {
+			panic(err)
+		}
+		w.Header().Set(header.ContentType, "text/plain; charset=iso-8859-1")
+		w.Write(b)
+	case "/partial-fail":
+		w.Header().Set("Content-Length", strconv.Itoa(len(rangeContent)))
+		w.Write([]byte(rangeContent[:10]))
+		w.(http.Flusher).Flush()
+		panic(http.ErrAbortHandler)
+	case "/early-hints":
+		w.Header().Set("Link", "</style.css>; rel=preload; as=style")
+		w.WriteHeader(http.StatusEarlyHints)
+		w.Header().Del("Link")
+		w.Write([]byte("early hints"))
+	case "/trailer":
+		w.Header().Set("Trailer", "X-Checksum")
+		w.Write([]byte("trailer"))
+		w.Header().Set("X-Checksum", "abc")
+	case "/csv":
+		w.Header().Set(header.ContentType, "text/csv; charset=utf-8")
+		w.Write([]byte("roc,18"))
 	case "/gbk-no-charset":
 		b, err := os.ReadFile(tests.GetTestFilePath("sample-gbk.html"))
 		if err != nil {
@@ -327,6 +358,13 @@ func handleGet(w http.ResponseWriter, r *http.Request) {
 			}
 			i += n
 		}
+	case "/range":
+		w.Header().Set("ETag", `"range-v1"`)
+		http.ServeContent(w, r, "range.txt", time.Unix(0, 0), strings.NewReader(rangeContent))
+	case "/content-disposition":
+		r.ParseForm()
+		w.Header().Set("Content-Disposition", r.FormValue("value"))
+		w.Write([]byte("content disposition"))
 	case "/protected":
 		auth := r.Header.Get("Authorization")
 		if auth == "Bearer goodtoken" {
@@ -16,9 +16,12 @@ import (
 
 	"github.com/hashicorp/go-multierror"
 
+	"github.com/luoxk/restys/http2"
+	"github.com/luoxk/restys/internal/charsets"
 	"github.com/luoxk/restys/internal/dump"
 	"github.com/luoxk/restys/internal/header"
 	"github.com/luoxk/restys/internal/util"
+	"golang.org/x/net/http/httpguts"
 )
 
 // Request struct is used to compose and fire individual request from
@@ -46,17 +49,46 @@ type Request struct {
 
 	isMultiPart              bool
 	disableAutoReadResponse  bool
+	stream                   bool
 	forceChunkedEncoding     bool
 	isSaveResponse           bool
+	resumeOutput             bool
+	resumeOffset             int64
+	outputFileFromHeader     bool
+	collisionPolicy          FileCollisionPolicy
+	atomicOutput             bool
+	keepPartFile             bool
+	onInformationalResponse  func(status int, header http.Header)
+	redirectPolicies         []RedirectPolicy
+	redirectBehavior         *RedirectBehavior
+	onRedirect               func(req *http.Request, via []*http.Request) error
 	close                    bool
+	chunked                  bool
+	contentLength            *int64
+	http10                   bool
+	http10KeepAlive          bool
+	hostHeader               string
+	sni                      string
+	h2Settings               []http2.Setting
+	neverIndexedHeaders      []string
+	challengeAttempt         int
+	unknownResultHandler     func(resp *Response) error
 	error                    error
 	client                   *Client
 	uploadCallback           UploadCallback
 	uploadCallbackInterval   time.Duration
 	downloadCallback         DownloadCallback
 	downloadCallbackInterval time.Duration
+	downloadRateLimit        int64
+	responseCharset          string
+	resultUnmarshal          func(data []byte, v interface{}) error
+	spillThreshold           int64
 	unReplayableBody         io.ReadCloser
 	retryOption              *retryOption
+	retryOptionModified      bool
+	hostConfig               *HostConfig
+	priority                 int
+	totalDeadline            time.Duration
 	bodyReadCloser           io.ReadCloser
 	dumpOptions              *DumpOptions
 	marshalBody              interface{}
@@ -66,6 +98,9 @@ type Request struct {
 	outputFile               string
 	output                   io.Writer
 	trace                    *clientTrace
+	traceHistory             []*clientTrace
+	lastAttempt              attemptState
+	requestID                string
 	dumpBuffer               *bytes.Buffer
 	responseReturnTime       time.Time
 	afterResponse            []ResponseMiddleware
@@ -73,6 +108,16 @@ type Request struct {
 
 type GetContentFunc func() (io.ReadCloser, error)
 
+// attemptState is the Response of the last attempt created by
+// Client.roundTrip, which is reset and reused by the retry instead of
+// reallocating.
+type attemptState struct {
+	resp *Response
+	// reusable is set before retrying if resp is not replaced by the
+	// client middleware.
+	reusable bool
+}
+
 func (r *Request) getHeader(key string) string {
 	if r.Headers == nil {
 		return ""
@@ -80,75 +125,33 @@ func (r *Request) getHeader(key string) string {
 	return r.Headers.Get(key)
 }
 
-// TraceInfo returns the trace information, only available if trace is enabled
-// (see Request.EnableTrace and Client.EnableTraceAll).
+// TraceInfo returns the trace information of the last attempt, only
+// available if trace is enabled (see Request.EnableTrace and
+// Client.EnableTraceAll).
 func (r *Request) TraceInfo() TraceInfo {
-	ct := r.trace
-
-	if ct == nil {
+	if r.trace == nil {
 		return TraceInfo{}
 	}
+	return r.trace.traceInfo(r.responseReturnTime)
+}
 
-	ti := TraceInfo{
-		IsConnReused:  ct.gotConnInfo.Reused,
-		IsConnWasIdle: ct.gotConnInfo.WasIdle,
-		ConnIdleTime:  ct.gotConnInfo.IdleTime,
-	}
-
-	endTime := ct.endTime
-	if endTime.IsZero() { // in case timeout
-		endTime = r.responseReturnTime
-	}
-
-	if !ct.tlsHandshakeStart.IsZero() {
-		if !ct.tlsHandshakeDone.IsZero() {
-			ti.TLSHandshakeTime = ct.tlsHandshakeDone.Sub(ct.tlsHandshakeStart)
-		} else {
-			ti.TLSHandshakeTime = endTime.Sub(ct.tlsHandshakeStart)
-		}
-	}
-
-	if ct.gotConnInfo.Reused {
-		ti.TotalTime = endTime.Sub(ct.getConn)
-	} else {
-		if ct.dnsStart.IsZero() {
-			ti.TotalTime = endTime.Sub(r.StartTime)
-		} else {
-			ti.TotalTime = endTime.Sub(ct.dnsStart)
-		}
-	}
-
-	dnsDone := ct.dnsDone
-	if dnsDone.IsZero() {
-		dnsDone = endTime
-	}
-
-	if !ct.dnsStart.IsZero() {
-		ti.DNSLookupTime = dnsDone.Sub(ct.dnsStart)
-	}
-
-	// Only calculate on successful connections
-	if !ct.connectDone.IsZero() {
-		ti.TCPConnectTime = ct.connectDone.Sub(dnsDone)
-	}
-
-	// Only calculate on successful connections
-	if !ct.gotConn.IsZero() {
-		ti.ConnectTime = ct.gotConn.Sub(ct.getConn)
-	}
-
-	// Only calculate on successful connections
-	if !ct.gotFirstResponseByte.IsZero() {
-		ti.FirstResponseTime = ct.gotFirstResponseByte.Sub(ct.gotConn)
-		ti.ResponseTime = endTime.Sub(ct.gotFirstResponseByte)
+// TraceInfos returns the trace information of every attempt in order,
+// including the retries, only available if trace is enabled.
+func (r *Request) TraceInfos() []TraceInfo {
+	if r.trace == nil {
+		return nil
 	}
-
-	// Capture remote address info when connection is non-nil
-	if ct.gotConnInfo.Conn != nil {
-		ti.RemoteAddr = ct.gotConnInfo.Conn.RemoteAddr()
+	infos := make([]TraceInfo, 0, len(r.traceHistory)+1)
+	for _, ct := range r.traceHistory {
+		infos = append(infos, ct.traceInfo(ct.endTime))
 	}
+	return append(infos, r.TraceInfo())
+}
 
-	return ti
+// RequestID returns the request ID stamped by Client.EnableRequestID,
+// empty if the request ID is not enabled or the request is not sent yet.
+func (r *Request) RequestID() string {
+	return r.requestID
 }
 
 // HeaderToString get all header as string.
@@ -348,6 +351,14 @@ func (r *Request) SetUploadCallbackWithInterval(callback UploadCallback, minInte
 	return r
 }
 
+// OnInformationalResponse set the callback which will be called for each
+// interim 1xx response (e.g. 100 Continue, 103 Early Hints) received before
+// the final response, works with HTTP/1.1, HTTP/2 and HTTP/3.
+func (r *Request) OnInformationalResponse(fn func(status int, header http.Header)) *Request {
+	r.onInformationalResponse = fn
+	return r
+}
+
 // SetDownloadCallback set the DownloadCallback which will be invoked at least
 // every 200ms during file upload, usually used to show download progress.
 func (r *Request) SetDownloadCallback(callback DownloadCallback) *Request {
@@ -389,6 +400,14 @@ func (r *Request) SetSuccessResult(result interface{}) *Request {
 	return r
 }
 
+// SetResultUnmarshal set the unmarshal function which will be used to
+// unmarshal response body into the success or error result for this
+// request, it overrides the decoder chosen by response `Content-Type`.
+func (r *Request) SetResultUnmarshal(fn func(data []byte, v interface{}) error) *Request {
+	r.resultUnmarshal = fn
+	return r
+}
+
 // SetError set the result that response body will be unmarshalled to if
 // no error occurs and Response.ResultState() returns ErrorState, by default
 // it requires HTTP status `code >= 400`, you can also use Request.SetResultStateCheckFunc
@@ -411,6 +430,14 @@ func (r *Request) SetErrorResult(err interface{}) *Request {
 	return r
 }
 
+// SetUnknownResultHandlerFunc set the handler which is invoked if no error
+// occurs but Response.ResultState returns UnknownState, it overrides
+// Client.SetCommonUnknownResultHandlerFunc.
+func (r *Request) SetUnknownResultHandlerFunc(fn func(resp *Response) error) *Request {
+	r.unknownResultHandler = fn
+	return r
+}
+
 // SetBearerAuthToken set bearer auth token for the request.
 func (r *Request) SetBearerAuthToken(token string) *Request {
 	return r.SetHeader(header.Authorization, "Bearer "+token)
@@ -461,6 +488,41 @@ func (r *Request) SetHeader(key, value string) *Request {
 	return r
 }
 
+// SetHostHeader set the host sent as the Host header on HTTP/1.1 and the
+// :authority pseudo header on HTTP/2 and HTTP/3, instead of the host of the
+// URL which is still used to dial, e.g. for vhost probing and CDN testing.
+// The non-ASCII host is converted to its IDNA ASCII form (punycode), and
+// the request fails if the host is invalid. It takes precedence over the
+// "Host" set by SetHeader.
+func (r *Request) SetHostHeader(host string) *Request {
+	h, err := httpguts.PunycodeHostPort(host)
+	if err == nil && (h == "" || !httpguts.ValidHostHeader(h)) {
+		err = errors.New("invalid characters")
+	}
+	if err != nil {
+		r.appendError(fmt.Errorf("invalid host header %q: %w", host, err))
+		return r
+	}
+	r.hostHeader = h
+	return r
+}
+
+// SetSNI set the server name sent in the tls handshake (SNI) of the request,
+// which is independent of the request URL and the Host header, e.g. the
+// domain fronting can be done with:
+//
+//	client.R().SetSNI("front.example.com").
+//		SetHostHeader("hidden.example.com").
+//		Get("https://front.example.com/")
+//
+// The server certificate is verified against the sni unless
+// InsecureSkipVerify is enabled. It overrides the client-level one (see
+// Client.SetCommonSNI), only valid for HTTP1 and HTTP2.
+func (r *Request) SetSNI(host string) *Request {
+	r.sni = host
+	return r
+}
+
 // SetHeadersNonCanonical set headers from a map for the request which key is a
 // non-canonical key (keep case unchanged), only valid for HTTP/1.1.
 func (r *Request) SetHeadersNonCanonical(hdrs map[string]string) *Request {
@@ -524,6 +586,28 @@ func (r *Request) SetPseudoHeaderOrder(keys ...string) *Request {
 	return r
 }
 
+// SetH2Settings set the SETTINGS frame sent by the http2 connection of the
+// request, which overrides the client-level one (see
+// Client.SetHTTP2SettingsFrame), so the requests to different targets on one
+// client can mimic different browsers. The connections with different
+// SETTINGS frames are never shared, the request reuses only the connection
+// which was created with the same SETTINGS frame.
+// Note this is only valid for http2.
+func (r *Request) SetH2Settings(settings ...http2.Setting) *Request {
+	r.h2Settings = settings
+	return r
+}
+
+// SetNeverIndexedHeaders set the headers (case-insensitive) which are encoded
+// as the HPACK never indexed literal in the http2 HEADERS frame, e.g. the
+// secret tokens, so their values never enter the dynamic table of the
+// client, the server and the intermediaries. The other headers are indexed
+// as usual. Note this is only valid for http2.
+func (r *Request) SetNeverIndexedHeaders(keys ...string) *Request {
+	r.neverIndexedHeaders = append(r.neverIndexedHeaders, keys...)
+	return r
+}
+
 // SetOutputFile set the file that response Body will be downloaded to.
 func (r *Request) SetOutputFile(file string) *Request {
 	r.isSaveResponse = true
@@ -531,6 +615,44 @@ func (r *Request) SetOutputFile(file string) *Request {
 	return r
 }
 
+// SetOutputFileResume set the file that response Body will be downloaded to,
+// and resume the download if a partial file already exists: the missing bytes
+// are requested with a `Range` header (guarded by `If-Range` when the ETag or
+// Last-Modified of the interrupted download is known) and appended to the file.
+// If the server does not honor the range, the file is downloaded again from
+// the beginning. A retry of the request also continues from where it stopped.
+func (r *Request) SetOutputFileResume(file string) *Request {
+	r.SetOutputFile(file)
+	r.resumeOutput = true
+	return r
+}
+
+// EnableAtomicOutputFile makes the download of SetOutputFile, SetOutputFileResume
+// and SetOutputFileFromContentDisposition atomic: the response Body is written
+// to a temporary file in the same directory, which is renamed to the output file
+// only if the download succeeds, so a failed download never leaves a truncated
+// output file behind. If keepPartFile is true, the temporary file is named
+// "<file>.part" and kept when the download fails, so SetOutputFileResume can
+// resume from it later.
+func (r *Request) EnableAtomicOutputFile(keepPartFile bool) *Request {
+	r.atomicOutput = true
+	r.keepPartFile = keepPartFile
+	return r
+}
+
+// SetOutputFileFromContentDisposition set the response Body to be downloaded
+// to a file named after the `Content-Disposition` header, falling back to the
+// last segment of the URL path, or "download" if neither is available. The
+// filename is sanitized so that it can not escape the output directory (see
+// Client.SetOutputDirectory), and the policy decides what to do if the file
+// already exists. Use Response.OutputFile to get the path of the saved file.
+func (r *Request) SetOutputFileFromContentDisposition(policy FileCollisionPolicy) *Request {
+	r.isSaveResponse = true
+	r.outputFileFromHeader = true
+	r.collisionPolicy = policy
+	return r
+}
+
 // SetOutput set the io.Writer that response Body will be downloaded to.
 func (r *Request) SetOutput(output io.Writer) *Request {
 	if output == nil {
@@ -542,6 +664,34 @@ func (r *Request) SetOutput(output io.Writer) *Request {
 	return r
 }
 
+// SetOutputWriter is the alias of SetOutput, the response Body is streamed
+// into the io.Writer (e.g. an uploader, a hash or a pipe) without being
+// buffered in memory or written to the filesystem.
+func (r *Request) SetOutputWriter(output io.Writer) *Request {
+	return r.SetOutput(output)
+}
+
+// SetDownloadRateLimit caps the rate that response Body is read at to
+// bytesPerSec, zero or negative means no limit. It is usually combined
+// with SetOutput or SetOutputFile to throttle large downloads.
+func (r *Request) SetDownloadRateLimit(bytesPerSec int64) *Request {
+	r.downloadRateLimit = bytesPerSec
+	return r
+}
+
+// SetResponseCharset forces the response body to be decoded from the
+// specified charset (e.g. "gbk", "shift_jis", "euc-kr", "windows-1251")
+// to utf-8, instead of auto-detecting it from the Content-Type header
+// or the body's meta.
+func (r *Request) SetResponseCharset(charset string) *Request {
+	if enc, _ := charsets.Lookup(charset); enc == nil {
+		r.appendError(fmt.Errorf("unsupported charset %q", charset))
+		return r
+	}
+	r.responseCharset = charset
+	return r
+}
+
 // SetQueryParams set URL query parameters from a map for the request.
 func (r *Request) SetQueryParams(params map[string]string) *Request {
 	for k, v := range params {
@@ -627,16 +777,93 @@ func (r *Request) Do(ctx ...context.Context) *Response {
 	defer func() {
 		r.responseReturnTime = time.Now()
 	}()
+	for _, o := range r.client.observers {
+		o.OnRequestQueued(r)
+	}
+	var resp *Response
+	if r.totalDeadline > 0 {
+		parent := r.Context()
+		ctx, cancel := context.WithTimeout(parent, r.totalDeadline)
+		r.ctx = ctx
+		defer func() {
+			r.ctx = parent
+			r.cancelOnBodyClose(resp, cancel)
+		}()
+	}
 	if r.error != nil {
-		return r.newErrorResponse(r.error)
+		resp = r.newErrorResponse(r.error)
+	} else if r.retryOption != nil && r.retryOption.MaxRetries != 0 && r.unReplayableBody != nil { // retryable request should not have unreplayable Body
+		resp = r.newErrorResponse(errRetryableWithUnReplayableBody)
+	} else {
+		resp, _ = r.do()
 	}
-	if r.retryOption != nil && r.retryOption.MaxRetries != 0 && r.unReplayableBody != nil { // retryable request should not have unreplayable Body
-		return r.newErrorResponse(errRetryableWithUnReplayableBody)
+	if resp.Err != nil {
+		for _, o := range r.client.observers {
+			o.OnError(r, resp, resp.Err)
+		}
 	}
-	resp, _ := r.do()
 	return resp
 }
 
+// cancelOnBodyClose calls cancel after the response body is closed if the
+// response body is not read automatically, otherwise calls it immediately.
+func (r *Request) cancelOnBodyClose(resp *Response, cancel context.CancelFunc) {
+	if resp != nil && resp.Response != nil && resp.Body != nil && (r.stream || r.disableAutoReadResponse || r.client.disableAutoReadResponse) {
+		resp.Body = &cancelReadCloser{ReadCloser: resp.Body, cancel: cancel}
+		return
+	}
+	cancel()
+}
+
+type cancelReadCloser struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (rc *cancelReadCloser) Close() error {
+	err := rc.ReadCloser.Close()
+	rc.cancel()
+	return err
+}
+
+// SetTotalDeadline set the total time limit of the request which
+// encompasses all retries (including the intervals between them),
+// redirects and auth round-trips (e.g. the digest auth re-send), distinct
+// from the per-attempt timeout (see Client.SetTimeout), so that a "give me
+// an answer in 10s" contract is enforceable end-to-end. When exceeded, the
+// request fails with an error that errors.Is context.DeadlineExceeded. If
+// the response body is not read automatically (see
+// DisableAutoReadResponse), the limit also applies to reading the body.
+func (r *Request) SetTotalDeadline(d time.Duration) *Request {
+	r.totalDeadline = d
+	return r
+}
+
+// SetRedirectPolicy set the RedirectPolicy for the request, which overrides
+// the redirect policy of the client (see Client.SetRedirectPolicy).
+func (r *Request) SetRedirectPolicy(policies ...RedirectPolicy) *Request {
+	r.redirectPolicies = policies
+	return r
+}
+
+// SetRedirectBehavior set how the redirect request is made from the
+// previous request, which overrides the behavior of the client (see
+// Client.SetRedirectBehavior).
+func (r *Request) SetRedirectBehavior(behavior RedirectBehavior) *Request {
+	r.redirectBehavior = &behavior
+	return r
+}
+
+// OnRedirect set the hook which is called when the redirect request is
+// about to be sent and it's allowed by the redirect policy, after the hook
+// of the client (see Client.OnRedirect). The hook can mutate req or stop
+// the chain by returning an error, e.g. http.ErrUseLastResponse returns the
+// redirect response as is.
+func (r *Request) OnRedirect(fn func(req *http.Request, via []*http.Request) error) *Request {
+	r.onRedirect = fn
+	return r
+}
+
 func (r *Request) do() (resp *Response, err error) {
 	defer func() {
 		if resp == nil {
@@ -647,6 +874,14 @@ func (r *Request) do() (resp *Response, err error) {
 		}
 	}()
 
+	inflightID := r.client.inflight.add(r)
+	defer r.client.inflight.remove(inflightID)
+	if r.client.IsClosed() {
+		err = ErrClientClosed
+		return
+	}
+
+	r.challengeAttempt = 0
 	for {
 		if r.Headers == nil {
 			r.Headers = make(http.Header)
@@ -661,12 +896,35 @@ func (r *Request) do() (resp *Response, err error) {
 				return
 			}
 		}
+		r.client.inflight.update(inflightID, r)
+		if r.client.schedule != nil {
+			if err = r.client.schedule.wait(r.Context(), r.URL.Hostname()); err != nil {
+				return
+			}
+		}
+		sched := r.client.scheduler
+		if sched != nil {
+			if err = sched.acquire(r.Context(), r.priority); err != nil {
+				return
+			}
+		}
+		for _, o := range r.client.observers {
+			o.OnAttemptStart(r)
+		}
 
 		if r.client.wrappedRoundTrip != nil {
 			resp, err = r.client.wrappedRoundTrip.RoundTrip(r)
 		} else {
 			resp, err = r.client.roundTrip(r)
 		}
+		if sched != nil {
+			sched.release()
+		}
+		if err == nil && resp.Response != nil {
+			for _, o := range r.client.observers {
+				o.OnResponse(resp)
+			}
+		}
 
 		// Determine if the error is from a canceled context.
 		// Store it here so it doesn't get lost when processing the AfterResponse middleware.
@@ -678,7 +936,24 @@ func (r *Request) do() (resp *Response, err error) {
 			}
 		}
 
-		if contextCanceled || r.retryOption == nil || (r.RetryAttempt >= r.retryOption.MaxRetries && r.retryOption.MaxRetries >= 0) { // absolutely cannot retry.
+		if err == nil && r.client.challenge != nil {
+			var reissue bool
+			if reissue, err = r.client.challenge.handle(r, resp); err != nil {
+				return
+			}
+			if reissue {
+				if r.trace != nil && r.trace.endTime.IsZero() {
+					r.trace.endTime = time.Now()
+				}
+				// numbered as an attempt, but not counted as a retry.
+				r.RetryAttempt++
+				r.cleanupAttempt(resp)
+				continue
+			}
+		}
+
+		retries := r.RetryAttempt - r.challengeAttempt
+		if contextCanceled || r.retryOption == nil || (retries >= r.retryOption.MaxRetries && r.retryOption.MaxRetries >= 0) { // absolutely cannot retry.
 			return
 		}
 
@@ -697,27 +972,44 @@ func (r *Request) do() (resp *Response, err error) {
 		}
 
 		// need retry, attempt to retry
+		if r.trace != nil && r.trace.endTime.IsZero() {
+			r.trace.endTime = time.Now()
+		}
 		r.RetryAttempt++
 		if l := len(r.retryOption.RetryHooks); l > 0 {
 			for i := l - 1; i >= 0; i-- { // run retry hooks in reverse order
 				r.retryOption.RetryHooks[i](resp, err)
 			}
 		}
-		time.Sleep(r.retryOption.GetRetryInterval(resp, r.RetryAttempt))
-
-		// clean up before retry
-		if r.dumpBuffer != nil {
-			r.dumpBuffer.Reset()
+		delay := r.retryOption.GetRetryInterval(resp, retries+1)
+		for _, o := range r.client.observers {
+			o.OnRetryScheduled(resp, err, delay)
 		}
-		if r.trace != nil {
-			r.trace = &clientTrace{}
+		if err = sleepContext(r.Context(), delay); err != nil {
+			return
 		}
-		resp.body = nil
-		resp.result = nil
-		resp.error = nil
+
+		r.cleanupAttempt(resp)
 	}
 }
 
+// cleanupAttempt cleans up the last attempt before re-issuing the request.
+func (r *Request) cleanupAttempt(resp *Response) {
+	if r.dumpBuffer != nil {
+		r.dumpBuffer.Reset()
+	}
+	if r.trace != nil {
+		r.traceHistory = append(r.traceHistory, r.trace)
+		r.trace = &clientTrace{}
+	}
+	reusable := resp == r.lastAttempt.resp
+	resp.body = nil
+	resp.Close()
+	resp.result = nil
+	resp.error = nil
+	r.lastAttempt.reusable = reusable
+}
+
 // Send fires http request with specified method and url, returns the
 // *Response which is always not nil, and the error is not nil if error occurs.
 func (r *Request) Send(method, url string) (*Response, error) {
@@ -885,6 +1177,44 @@ func (r *Request) SetBodyString(body string) *Request {
 	return r.SetBodyBytes([]byte(body))
 }
 
+// EnableChunkedEncoding sends the request body with the chunked transfer
+// encoding even if the body size is known, which only takes effect with
+// HTTP/1.1, HTTP/2 and HTTP/3 just omit the Content-Length header.
+func (r *Request) EnableChunkedEncoding() *Request {
+	r.chunked = true
+	r.contentLength = nil
+	return r
+}
+
+// SetContentLength sends the request body with the fixed length n even if
+// the body size is unknown (e.g. the body is an io.Reader), instead of the
+// chunked transfer encoding. The request fails if the body size is not n.
+func (r *Request) SetContentLength(n int64) *Request {
+	r.chunked = false
+	r.contentLength = &n
+	return r
+}
+
+// EnableHTTP10 sends the request as HTTP/1.0 over an HTTP/1 connection,
+// for the legacy devices (e.g. embedded firmware, printers) which mis-handle
+// the HTTP/1.1 features. The body of unknown size is buffered to send the
+// Content-Length since HTTP/1.0 has no chunked transfer encoding, and the
+// connection is closed after the response, see EnableHTTP10KeepAlive.
+func (r *Request) EnableHTTP10() *Request {
+	r.http10 = true
+	r.http10KeepAlive = false
+	return r
+}
+
+// EnableHTTP10KeepAlive is like EnableHTTP10, but sends the
+// "Connection: keep-alive" header to reuse the connection, the connection
+// is still closed if the server doesn't respond with keep-alive.
+func (r *Request) EnableHTTP10KeepAlive() *Request {
+	r.http10 = true
+	r.http10KeepAlive = true
+	return r
+}
+
 // SetBodyJsonString set the request Body as string and set Content-Type header
 // as "application/json; charset=utf-8"
 func (r *Request) SetBodyJsonString(body string) *Request {
@@ -973,6 +1303,15 @@ func (r *Request) GetContextData(key any) any {
 	return r.Context().Value(key)
 }
 
+// SetResponseBodySpillThreshold set the size threshold in bytes for the
+// request, response body larger than it is auto-read into a temporary file
+// instead of memory, it overrides Client.SetResponseBodySpillThreshold,
+// negative disables spilling for the request.
+func (r *Request) SetResponseBodySpillThreshold(threshold int64) *Request {
+	r.spillThreshold = threshold
+	return r
+}
+
 // DisableAutoReadResponse disable read response body automatically (enabled by default).
 func (r *Request) DisableAutoReadResponse() *Request {
 	r.disableAutoReadResponse = true
@@ -1133,6 +1472,7 @@ func (r *Request) getRetryOption() *retryOption {
 	if r.retryOption == nil {
 		r.retryOption = newDefaultRetryOption()
 	}
+	r.retryOptionModified = true
 	return r.retryOption
 }
 
@@ -0,0 +1,45 @@
+package restys
+
+import (
+	"crypto/rand"
+	"fmt"
+)
+
+// DefaultRequestIDHeader is the default header name of the request ID.
+const DefaultRequestIDHeader = "X-Request-Id"
+
+// RequestIDGenerator generates the unique ID of a request.
+type RequestIDGenerator func() string
+
+type requestIDOption struct {
+	header    string
+	generator RequestIDGenerator
+}
+
+// NewRequestID returns a random UUID (version 4), which is the default
+// RequestIDGenerator.
+func NewRequestID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// parseRequestID stamps the request with the request ID, the ID is kept
+// on retries, and the ID set in the request header takes precedence.
+func parseRequestID(c *Client, r *Request) error {
+	opt := c.requestIDOption
+	if opt == nil {
+		return nil
+	}
+	if r.requestID == "" {
+		if id := r.Headers.Get(opt.header); id != "" {
+			r.requestID = id
+		} else {
+			r.requestID = opt.generator()
+		}
+	}
+	r.Headers.Set(opt.header, r.requestID)
+	return nil
+}
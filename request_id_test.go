@@ -0,0 +1,48 @@
+package restys
+
+import (
+	"net/http"
+	"regexp"
+	"testing"
+
+	"github.com/luoxk/restys/internal/tests"
+)
+
+func TestEnableRequestID(t *testing.T) {
+	testWithAllTransport(t, func(t *testing.T, c *Client) {
+		c.EnableRequestID("", nil).EnableTraceAll()
+		var sent []string
+		resp, err := c.R().
+			SetRetryCount(2).
+			SetRetryCondition(func(resp *Response, err error) bool {
+				sent = append(sent, resp.Request.RawRequest.Header.Get(DefaultRequestIDHeader))
+				return resp.Request.RetryAttempt == 0
+			}).
+			Get("/header")
+		assertSuccess(t, resp, err)
+		id := resp.RequestID()
+		tests.AssertEqual(t, true, regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`).MatchString(id))
+		tests.AssertEqual(t, []string{id, id}, sent)
+		tests.AssertEqual(t, id, resp.TraceInfo().RequestID)
+
+		var h http.Header
+		tests.AssertNoError(t, resp.Unmarshal(&h))
+		tests.AssertEqual(t, id, h.Get(DefaultRequestIDHeader))
+
+		resp, err = c.R().SetHeader(DefaultRequestIDHeader, "my-id").Get("/")
+		assertSuccess(t, resp, err)
+		tests.AssertEqual(t, "my-id", resp.RequestID())
+
+		c.EnableRequestID("X-Correlation-Id", func() string { return "generated" })
+		resp, err = c.R().Get("/header")
+		assertSuccess(t, resp, err)
+		tests.AssertEqual(t, "generated", resp.RequestID())
+		tests.AssertNoError(t, resp.Unmarshal(&h))
+		tests.AssertEqual(t, "generated", h.Get("X-Correlation-Id"))
+
+		c.DisableRequestID()
+		resp, err = c.R().Get("/")
+		assertSuccess(t, resp, err)
+		tests.AssertEqual(t, "", resp.RequestID())
+	})
+}
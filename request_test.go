@@ -2,18 +2,25 @@ package restys
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/luoxk/restys/http2"
 	"github.com/luoxk/restys/internal/header"
 	"github.com/luoxk/restys/internal/tests"
 )
@@ -830,6 +837,32 @@ func TestTraceOnTimeout(t *testing.T) {
 	})
 }
 
+func TestTraceInfos(t *testing.T) {
+	testWithAllTransport(t, func(t *testing.T, c *Client) {
+		resp, err := c.R().
+			EnableTrace().
+			SetRetryCount(2).
+			SetRetryFixedInterval(time.Millisecond).
+			SetRetryCondition(func(resp *Response, err error) bool {
+				return true
+			}).
+			Get("/")
+		assertSuccess(t, resp, err)
+		infos := resp.TraceInfos()
+		tests.AssertEqual(t, 3, len(infos))
+		for i, ti := range infos {
+			tests.AssertEqual(t, i, ti.Attempt)
+			tests.AssertEqual(t, true, ti.TimeToFirstByte > 0)
+			tests.AssertEqual(t, true, ti.BodyReadTime >= 0)
+			tests.AssertEqual(t, resp.Proto, ti.Protocol)
+			tests.AssertNotNil(t, ti.RemoteAddr)
+			tests.AssertContains(t, ti.String(), "protocol", true)
+		}
+		tests.AssertEqual(t, true, infos[2].IsConnReused)
+		tests.AssertEqual(t, infos[2], resp.TraceInfo())
+	})
+}
+
 func TestAutoDetectRequestContentType(t *testing.T) {
 	c := tc()
 	resp, err := c.R().SetBody(getTestFileContent(t, "sample-image.png")).Post("/content-type")
@@ -997,6 +1030,155 @@ func TestDownloadCallback(t *testing.T) {
 	tests.AssertEqual(t, true, n > 0)
 }
 
+func TestSetOutputFileResume(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "range.txt")
+
+	// resume from a partial file without known validator.
+	tests.AssertNoError(t, os.WriteFile(file, []byte(rangeContent[:10]), 0644))
+	resp, err := tc().R().SetOutputFileResume(file).Get("/range")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, http.StatusPartialContent, resp.StatusCode)
+	tests.AssertEqual(t, "bytes=10-", resp.Request.Headers.Get("Range"))
+	b, err := os.ReadFile(file)
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, rangeContent, string(b))
+	_, err = os.Stat(file + resumeMetaSuffix)
+	tests.AssertEqual(t, true, os.IsNotExist(err))
+
+	// already completed.
+	resp, err = tc().R().SetOutputFileResume(file).Get("/range")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, http.StatusRequestedRangeNotSatisfiable, resp.StatusCode)
+	b, err = os.ReadFile(file)
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, rangeContent, string(b))
+
+	// validator changed, download again from the beginning.
+	tests.AssertNoError(t, os.WriteFile(file, []byte("xxxxx"), 0644))
+	tests.AssertNoError(t, os.WriteFile(file+resumeMetaSuffix, []byte(`"range-v0"`), 0644))
+	resp, err = tc().R().SetOutputFileResume(file).Get("/range")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, http.StatusOK, resp.StatusCode)
+	tests.AssertEqual(t, `"range-v0"`, resp.Request.Headers.Get("If-Range"))
+	b, err = os.ReadFile(file)
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, rangeContent, string(b))
+}
+
+func TestSetOutputFileFromContentDisposition(t *testing.T) {
+	dir := t.TempDir()
+	c := tc().SetOutputDirectory(dir)
+	get := func(value string, policy FileCollisionPolicy) (*Response, error) {
+		return c.R().
+			SetQueryParam("value", value).
+			SetOutputFileFromContentDisposition(policy).
+			Get("/content-disposition")
+	}
+
+	resp, err := get(`attachment; filename="../../evil.txt"`, CollisionOverwrite)
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, filepath.Join(dir, "evil.txt"), resp.OutputFile())
+	b, err := os.ReadFile(resp.OutputFile())
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, "content disposition", string(b))
+
+	resp, err = get(`attachment; filename*=UTF-8''%E6%8A%A5%E5%91%8A.txt`, CollisionOverwrite)
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, filepath.Join(dir, "报告.txt"), resp.OutputFile())
+
+	resp, err = get(`attachment; filename="evil.txt"`, CollisionRename)
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, filepath.Join(dir, "evil (1).txt"), resp.OutputFile())
+
+	_, err = get(`attachment; filename="evil.txt"`, CollisionFail)
+	tests.AssertErrorContains(t, err, "already exists")
+
+	resp, err = get("attachment", CollisionOverwrite)
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, filepath.Join(dir, "content-disposition"), resp.OutputFile())
+
+	tests.AssertEqual(t, "_CON.txt", sanitizeFilename("CON.txt"))
+	tests.AssertEqual(t, "a_b.txt", sanitizeFilename("a:b.txt "))
+	tests.AssertEqual(t, "", sanitizeFilename(".."))
+}
+
+func TestEnableAtomicOutputFile(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "atomic.txt")
+	tests.AssertNoError(t, os.WriteFile(file, []byte("old"), 0644))
+
+	// failed download leaves the existing file untouched.
+	_, err := tc().R().SetOutputFile(file).EnableAtomicOutputFile(false).Get("/partial-fail")
+	tests.AssertNotNil(t, err)
+	b, err := os.ReadFile(file)
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, "old", string(b))
+	entries, err := os.ReadDir(dir)
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, 1, len(entries))
+
+	// part file is kept for resuming.
+	tests.AssertNoError(t, os.Remove(file))
+	_, err = tc().R().SetOutputFile(file).EnableAtomicOutputFile(true).Get("/partial-fail")
+	tests.AssertNotNil(t, err)
+	_, err = os.Stat(file)
+	tests.AssertEqual(t, true, os.IsNotExist(err))
+	b, err = os.ReadFile(file + partFileSuffix)
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, rangeContent[:10], string(b))
+
+	resp, err := tc().R().SetOutputFileResume(file).EnableAtomicOutputFile(true).Get("/range")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, http.StatusPartialContent, resp.StatusCode)
+	tests.AssertEqual(t, file, resp.OutputFile())
+	b, err = os.ReadFile(file)
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, rangeContent, string(b))
+	_, err = os.Stat(file + partFileSuffix)
+	tests.AssertEqual(t, true, os.IsNotExist(err))
+
+	// successful download replaces the existing file.
+	resp, err = tc().R().SetOutputFile(file).EnableAtomicOutputFile(false).Get("/")
+	assertSuccess(t, resp, err)
+	b, err = os.ReadFile(file)
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, "TestGet: text response", string(b))
+	entries, err = os.ReadDir(dir)
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, 1, len(entries))
+}
+
+func TestOnInformationalResponse(t *testing.T) {
+	testWithAllTransport(t, func(t *testing.T, c *Client) {
+		var statuses []int
+		var link string
+		resp, err := c.R().OnInformationalResponse(func(status int, header http.Header) {
+			statuses = append(statuses, status)
+			link = header.Get("Link")
+		}).Get("/early-hints")
+		assertSuccess(t, resp, err)
+		tests.AssertEqual(t, "early hints", resp.String())
+		tests.AssertEqual(t, []int{http.StatusEarlyHints}, statuses)
+		tests.AssertEqual(t, "</style.css>; rel=preload; as=style", link)
+	})
+}
+
+func TestResponseTrailers(t *testing.T) {
+	testWithAllTransport(t, func(t *testing.T, c *Client) {
+		resp, err := c.R().Get("/trailer")
+		assertSuccess(t, resp, err)
+		tests.AssertEqual(t, "trailer", resp.String())
+		tests.AssertEqual(t, "abc", resp.GetTrailer("X-Checksum"))
+		tests.AssertEqual(t, []string{"abc"}, resp.Trailers().Values("X-Checksum"))
+
+		resp, err = c.R().DisableAutoReadResponse().Get("/trailer")
+		assertSuccess(t, resp, err)
+		_, err = io.ReadAll(resp.Body)
+		tests.AssertNoError(t, err)
+		tests.AssertEqual(t, "abc", resp.GetTrailer("X-Checksum"))
+	})
+}
+
 func TestRequestDisableAutoReadResponse(t *testing.T) {
 	testWithAllTransport(t, func(t *testing.T, c *Client) {
 		resp, err := c.R().DisableAutoReadResponse().Get("/")
@@ -1023,3 +1205,261 @@ func TestRestoreResponseBody(t *testing.T) {
 	tests.AssertNoError(t, err)
 	tests.AssertEqual(t, true, len(body) > 0)
 }
+
+func TestSetOutputWriterWithDownloadRateLimit(t *testing.T) {
+	buf := new(bytes.Buffer)
+	start := time.Now()
+	resp, err := tc().R().
+		SetOutputWriter(buf).
+		SetDownloadRateLimit(20).
+		Get("/range")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, http.StatusOK, resp.StatusCode)
+	tests.AssertEqual(t, rangeContent, buf.String())
+	// 36 bytes at 20 bytes/s with a burst of 20 bytes takes at least 0.8s.
+	if elapsed := time.Since(start); elapsed < 700*time.Millisecond {
+		t.Errorf("download finished too fast with rate limit: %v", elapsed)
+	}
+}
+
+func TestSetTotalDeadline(t *testing.T) {
+	c := tc()
+	start := time.Now()
+	resp, err := c.R().
+		SetTotalDeadline(300 * time.Millisecond).
+		SetRetryCount(10).
+		SetRetryFixedInterval(100 * time.Millisecond).
+		SetRetryCondition(func(resp *Response, err error) bool { return true }).
+		Get("/")
+	tests.AssertEqual(t, true, errors.Is(err, context.DeadlineExceeded))
+	tests.AssertEqual(t, true, time.Since(start) < time.Second)
+	tests.AssertEqual(t, true, resp.Request.RetryAttempt >= 2)
+
+	// the deadline is not kept in the request context.
+	tests.AssertEqual(t, false, hasDeadline(resp.Request.Context()))
+
+	resp, err = c.R().SetTotalDeadline(time.Second).Get("/")
+	assertSuccess(t, resp, err)
+
+	// body reading is covered if not read automatically.
+	resp, err = c.R().DisableAutoReadResponse().SetTotalDeadline(time.Second).Get("/")
+	assertSuccess(t, resp, err)
+	b, err := io.ReadAll(resp.Body)
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, "TestGet: text response", string(b))
+	resp.Body.Close()
+}
+
+func hasDeadline(ctx context.Context) bool {
+	_, ok := ctx.Deadline()
+	return ok
+}
+
+func TestStream(t *testing.T) {
+	c := tc().SetResponseBodyTransformer(func(rawBody []byte, req *Request, resp *Response) ([]byte, error) {
+		return []byte("transformed"), nil
+	})
+	body, resp, err := c.R().SetURL("/gbk").Stream()
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, http.StatusOK, resp.StatusCode)
+	b, err := io.ReadAll(body)
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, toGbk("我是roc"), b) // not decoded nor transformed
+	tests.AssertNoError(t, body.Close())
+	tests.AssertNoError(t, body.Close())
+	_, err = body.Read(make([]byte, 1))
+	tests.AssertEqual(t, http.ErrBodyReadAfterClose, err)
+
+	var result UserInfo
+	body, resp, err = c.R().SetSuccessResult(&result).SetURL("/search?username=imroc").Stream()
+	tests.AssertNoError(t, err)
+	tests.AssertIsNil(t, resp.SuccessResult())
+	var buf bytes.Buffer
+	_, err = io.Copy(&buf, body)
+	tests.AssertNoError(t, err)
+	tests.AssertContains(t, buf.String(), `"username":"imroc"`, true)
+	body.Close()
+
+	_, resp, err = c.R().SetURL("http://127.0.0.1:1").Stream()
+	tests.AssertNotNil(t, err)
+	tests.AssertNotNil(t, resp)
+}
+
+func TestBodyFraming(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		fmt.Fprintf(w, "%s %d %v", body, r.ContentLength, r.TransferEncoding)
+	}))
+	defer server.Close()
+	c := C().SetBaseURL(server.URL)
+
+	resp, err := c.R().SetBody("hello").Post("/")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "hello 5 []", resp.String())
+
+	resp, err = c.R().SetBody("hello").EnableChunkedEncoding().Post("/")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "hello -1 [chunked]", resp.String())
+
+	resp, err = c.R().SetBody(strings.NewReader("hello")).Post("/")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "hello -1 [chunked]", resp.String())
+
+	resp, err = c.R().SetBody(strings.NewReader("hello")).SetContentLength(5).Post("/")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "hello 5 []", resp.String())
+
+	_, err = c.R().SetBody(strings.NewReader("hello")).SetContentLength(3).Post("/")
+	tests.AssertErrorContains(t, err, "ContentLength=3")
+}
+
+func TestHTTP10(t *testing.T) {
+	var conns int32
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		fmt.Fprintf(w, "%s %s %d %v %s", r.Proto, body, r.ContentLength, r.TransferEncoding, r.Header.Get("Connection"))
+	})
+	server := httptest.NewUnstartedServer(handler)
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+	c := C().SetBaseURL(server.URL)
+
+	resp, err := c.R().EnableHTTP10().SetBody(strings.NewReader("hello")).Post("/")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "HTTP/1.0 hello 5 [] close", resp.String())
+	resp, err = c.R().EnableHTTP10().Get("/")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, int32(2), atomic.LoadInt32(&conns))
+
+	for i := 0; i < 2; i++ {
+		resp, err = c.R().EnableHTTP10KeepAlive().SetBody("hello").EnableChunkedEncoding().Post("/")
+		assertSuccess(t, resp, err)
+		tests.AssertEqual(t, "HTTP/1.0 hello 5 [] keep-alive", resp.String())
+	}
+	tests.AssertEqual(t, int32(3), atomic.LoadInt32(&conns))
+
+	// HTTP/1.0 is sent over HTTP/1 even if HTTP/2 is available.
+	tlsServer := httptest.NewUnstartedServer(handler)
+	tlsServer.EnableHTTP2 = true
+	tlsServer.StartTLS()
+	defer tlsServer.Close()
+	c = C().SetBaseURL(tlsServer.URL).EnableInsecureSkipVerify()
+	resp, err = c.R().Get("/")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "HTTP/2.0", resp.Proto)
+	resp, err = c.R().EnableHTTP10().Get("/")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "HTTP/1.0  0 [] close", resp.String())
+}
+
+func TestSetHostHeader(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Host))
+	})
+	h1 := httptest.NewServer(handler)
+	defer h1.Close()
+	h2 := httptest.NewUnstartedServer(handler)
+	h2.EnableHTTP2 = true
+	h2.StartTLS()
+	defer h2.Close()
+
+	for _, c := range []*Client{
+		C().SetBaseURL(h1.URL),
+		C().SetBaseURL(h2.URL).EnableInsecureSkipVerify().EnableForceHTTP2(),
+	} {
+		resp, err := c.R().SetHostHeader("vhost.example.com").Get("/")
+		assertSuccess(t, resp, err)
+		tests.AssertEqual(t, "vhost.example.com", resp.String())
+
+		resp, err = c.R().SetHeader("Host", "other.example.com").SetHostHeader("测试.example:8080").Get("/")
+		assertSuccess(t, resp, err)
+		tests.AssertEqual(t, "xn--0zwm56d.example:8080", resp.String())
+
+		_, err = c.R().SetHostHeader("bad host").Get("/")
+		tests.AssertErrorContains(t, err, "invalid host header")
+	}
+}
+
+func TestSetSNI(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.TLS.ServerName + "|" + r.Host))
+	})
+	h1 := httptest.NewTLSServer(handler)
+	defer h1.Close()
+	h2 := httptest.NewUnstartedServer(handler)
+	h2.EnableHTTP2 = true
+	h2.StartTLS()
+	defer h2.Close()
+
+	for _, c := range []*Client{
+		C().SetBaseURL(h1.URL).EnableInsecureSkipVerify(),
+		C().SetBaseURL(h2.URL).EnableInsecureSkipVerify(),
+		C().SetBaseURL(h2.URL).EnableInsecureSkipVerify().EnableForceHTTP2(),
+		C().SetBaseURL(h2.URL).EnableInsecureSkipVerify().SetTLSFingerprintChrome(),
+	} {
+		c.SetCommonSNI("front.example.com")
+		resp, err := c.R().SetHostHeader("hidden.example.com").Get("/")
+		assertSuccess(t, resp, err)
+		tests.AssertEqual(t, "front.example.com|hidden.example.com", resp.String())
+
+		// the connections of different sni are not shared.
+		resp, err = c.R().SetSNI("other.example.com").Get("/")
+		assertSuccess(t, resp, err)
+		tests.AssertEqual(t, "other.example.com", strings.Split(resp.String(), "|")[0])
+
+		resp, err = c.SetCommonSNI("").R().Get("/")
+		assertSuccess(t, resp, err)
+		tests.AssertEqual(t, "", strings.Split(resp.String(), "|")[0])
+	}
+}
+
+func TestSetH2Settings(t *testing.T) {
+	buf := new(bytes.Buffer)
+	c := tc().SetHTTP2SettingsFrame(
+		http2.Setting{ID: http2.SettingInitialWindowSize, Val: 6291456},
+	).SetCommonPseudoHeaderOder(":method", ":authority", ":scheme", ":path")
+	c.SetCommonDumpOptions(&DumpOptions{Output: buf, HTTP2Frames: true}).EnableDumpAll()
+
+	resp, err := c.R().Get("/")
+	assertSuccess(t, resp, err)
+	dump := buf.String()
+	tests.AssertContains(t, dump, "settings: initial_window_size=6291456\n", true)
+	tests.AssertEqual(t, true, strings.Index(dump, ":method:") < strings.Index(dump, ":path:"))
+
+	// the request-level settings creates a new connection.
+	buf.Reset()
+	resp, err = c.R().SetH2Settings(
+		http2.Setting{ID: http2.SettingHeaderTableSize, Val: 65536},
+		http2.Setting{ID: http2.SettingEnablePush, Val: 0},
+	).SetPseudoHeaderOrder(":path", ":scheme", ":authority", ":method").Get("/")
+	assertSuccess(t, resp, err)
+	dump = buf.String()
+	tests.AssertContains(t, dump, "settings: header_table_size=65536, enable_push=0\n", true)
+	tests.AssertEqual(t, true, strings.Index(dump, ":path:") < strings.Index(dump, ":method:"))
+
+	// the connection of the client-level settings is reused.
+	buf.Reset()
+	resp, err = c.R().Get("/")
+	assertSuccess(t, resp, err)
+	tests.AssertContains(t, buf.String(), "> settings len=", false)
+}
+
+func TestSetNeverIndexedHeaders(t *testing.T) {
+	buf := new(bytes.Buffer)
+	c := tc()
+	c.SetCommonDumpOptions(&DumpOptions{Output: buf, HTTP2Frames: true}).EnableDumpAll()
+	resp, err := c.R().
+		SetHeader("X-Token", "secret").
+		SetHeader("X-Public", "public").
+		SetNeverIndexedHeaders("x-token").
+		Get("/")
+	assertSuccess(t, resp, err)
+	dump := buf.String()
+	tests.AssertContains(t, dump, "[h2] >   x-token: secret (never indexed)\n", true)
+	tests.AssertContains(t, dump, "[h2] >   x-public: public\n", true)
+}
@@ -6,6 +6,8 @@ import (
 	"net/http"
 	"net/url"
 	"time"
+
+	"github.com/luoxk/restys/http2"
 )
 
 // SetURL is a global wrapper methods which delegated
@@ -108,6 +110,12 @@ func SetErrorResult(error interface{}) *Request {
 	return defaultClient.R().SetErrorResult(error)
 }
 
+// SetUnknownResultHandlerFunc is a global wrapper methods which delegated
+// to the default client, create a request and SetUnknownResultHandlerFunc for request.
+func SetUnknownResultHandlerFunc(fn func(resp *Response) error) *Request {
+	return defaultClient.R().SetUnknownResultHandlerFunc(fn)
+}
+
 // SetBearerAuthToken is a global wrapper methods which delegated
 // to the default client, create a request and SetBearerAuthToken for request.
 func SetBearerAuthToken(token string) *Request {
@@ -150,18 +158,84 @@ func SetPseudoHeaderOrder(keys ...string) *Request {
 	return defaultClient.R().SetPseudoHeaderOrder(keys...)
 }
 
+// SetH2Settings is a global wrapper methods which delegated
+// to the default client, create a request and SetH2Settings for request.
+func SetH2Settings(settings ...http2.Setting) *Request {
+	return defaultClient.R().SetH2Settings(settings...)
+}
+
+// SetNeverIndexedHeaders is a global wrapper methods which delegated
+// to the default client, create a request and SetNeverIndexedHeaders for request.
+func SetNeverIndexedHeaders(keys ...string) *Request {
+	return defaultClient.R().SetNeverIndexedHeaders(keys...)
+}
+
 // SetOutputFile is a global wrapper methods which delegated
 // to the default client, create a request and SetOutputFile for request.
 func SetOutputFile(file string) *Request {
 	return defaultClient.R().SetOutputFile(file)
 }
 
+// SetOutputFileResume is a global wrapper methods which delegated
+// to the default client, create a request and SetOutputFileResume for request.
+func SetOutputFileResume(file string) *Request {
+	return defaultClient.R().SetOutputFileResume(file)
+}
+
+// EnableAtomicOutputFile is a global wrapper methods which delegated
+// to the default client, create a request and EnableAtomicOutputFile for request.
+func EnableAtomicOutputFile(keepPartFile bool) *Request {
+	return defaultClient.R().EnableAtomicOutputFile(keepPartFile)
+}
+
+// SetOutputFileFromContentDisposition is a global wrapper methods which delegated
+// to the default client, create a request and SetOutputFileFromContentDisposition for request.
+func SetOutputFileFromContentDisposition(policy FileCollisionPolicy) *Request {
+	return defaultClient.R().SetOutputFileFromContentDisposition(policy)
+}
+
 // SetOutput is a global wrapper methods which delegated
 // to the default client, create a request and SetOutput for request.
 func SetOutput(output io.Writer) *Request {
 	return defaultClient.R().SetOutput(output)
 }
 
+// SetOutputWriter is a global wrapper methods which delegated
+// to the default client, create a request and SetOutputWriter for request.
+func SetOutputWriter(output io.Writer) *Request {
+	return defaultClient.R().SetOutputWriter(output)
+}
+
+// SetTotalDeadline is a global wrapper methods which delegated
+// to the default client, create a request and SetTotalDeadline for request.
+func SetTotalDeadline(d time.Duration) *Request {
+	return defaultClient.R().SetTotalDeadline(d)
+}
+
+// SetPriority is a global wrapper methods which delegated
+// to the default client, create a request and SetPriority for request.
+func SetPriority(priority int) *Request {
+	return defaultClient.R().SetPriority(priority)
+}
+
+// SetDownloadRateLimit is a global wrapper methods which delegated
+// to the default client, create a request and SetDownloadRateLimit for request.
+func SetDownloadRateLimit(bytesPerSec int64) *Request {
+	return defaultClient.R().SetDownloadRateLimit(bytesPerSec)
+}
+
+// SetResponseCharset is a global wrapper methods which delegated
+// to the default client, create a request and SetResponseCharset for request.
+func SetResponseCharset(charset string) *Request {
+	return defaultClient.R().SetResponseCharset(charset)
+}
+
+// SetResultUnmarshal is a global wrapper methods which delegated
+// to the default client, create a request and SetResultUnmarshal for request.
+func SetResultUnmarshal(fn func(data []byte, v interface{}) error) *Request {
+	return defaultClient.R().SetResultUnmarshal(fn)
+}
+
 // SetQueryParams is a global wrapper methods which delegated
 // to the default client, create a request and SetQueryParams for request.
 func SetQueryParams(params map[string]string) *Request {
@@ -510,6 +584,12 @@ func SetUploadCallbackWithInterval(callback UploadCallback, minInterval time.Dur
 	return defaultClient.R().SetUploadCallbackWithInterval(callback, minInterval)
 }
 
+// OnInformationalResponse is a global wrapper methods which delegated
+// to the default client, create a request and OnInformationalResponse for request.
+func OnInformationalResponse(fn func(status int, header http.Header)) *Request {
+	return defaultClient.R().OnInformationalResponse(fn)
+}
+
 // SetDownloadCallback is a global wrapper methods which delegated
 // to the default client, create a request and SetDownloadCallback for request.
 func SetDownloadCallback(callback DownloadCallback) *Request {
@@ -522,6 +602,12 @@ func SetDownloadCallbackWithInterval(callback DownloadCallback, minInterval time
 	return defaultClient.R().SetDownloadCallbackWithInterval(callback, minInterval)
 }
 
+// SetSNI is a global wrapper methods which delegated
+// to the default client, create a request and SetSNI for request.
+func SetSNI(host string) *Request {
+	return defaultClient.R().SetSNI(host)
+}
+
 // EnableCloseConnection is a global wrapper methods which delegated
 // to the default client, create a request and EnableCloseConnection for request.
 func EnableCloseConnection() *Request {
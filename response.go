@@ -1,12 +1,17 @@
 package restys
 
 import (
+	"bytes"
 	"io"
+	"net"
 	"net/http"
-	"strings"
+	"os"
+	"runtime"
 	"time"
 
 	"github.com/luoxk/restys/internal/header"
+	h2internal "github.com/luoxk/restys/internal/http2"
+	"github.com/luoxk/restys/internal/tlsutil"
 	"github.com/luoxk/restys/internal/util"
 )
 
@@ -21,9 +26,19 @@ type Response struct {
 	// Request is the Response's related Request.
 	Request    *Request
 	body       []byte
+	bodyFile   *spillFile
 	receivedAt time.Time
+	remoteAddr net.Addr
+	outputFile string
 	error      interface{}
 	result     interface{}
+	// redirects is the time that the response of each redirect hop is
+	// received, see Response.RedirectHistory.
+	redirects []time.Time
+	// serverHello is the ServerHello of the connection, see TLSInfo.
+	serverHello *tlsutil.ServerHello
+	// h2Fingerprint records the akamai fingerprint, see H2Fingerprint.
+	h2Fingerprint *h2internal.FingerprintRecorder
 }
 
 // IsSuccess method returns true if no error occurs and HTTP status `code >= 200 and <= 299`
@@ -127,6 +142,18 @@ func (r *Response) TraceInfo() TraceInfo {
 	return r.Request.TraceInfo()
 }
 
+// RequestID returns the request ID stamped by Client.EnableRequestID
+// (see Request.RequestID).
+func (r *Response) RequestID() string {
+	return r.Request.RequestID()
+}
+
+// TraceInfos returns the trace information of every attempt in order,
+// including the retries (see Request.TraceInfos).
+func (r *Response) TraceInfos() []TraceInfo {
+	return r.Request.TraceInfos()
+}
+
 // TotalTime returns the total time of the request, from request we sent to response we received.
 func (r *Response) TotalTime() time.Duration {
 	if r.Request.trace != nil {
@@ -138,6 +165,27 @@ func (r *Response) TotalTime() time.Duration {
 	return r.Request.responseReturnTime.Sub(r.Request.StartTime)
 }
 
+// RemoteAddr returns the remote network address of the connection which
+// the request was sent on, e.g. the IP address selected from the resolved
+// addresses, it is nil if the connection is not established or the
+// protocol does not report it (e.g. HTTP3).
+func (r *Response) RemoteAddr() net.Addr {
+	return r.remoteAddr
+}
+
+// H2Fingerprint returns the akamai http2 fingerprint actually sent on the
+// connection which the request was sent on, in the same format of
+// Client.SetAkamaiWithStr ("settings|window|priority|pseudo-order"), e.g.
+// "1:65536;2:0;4:6291456;6:262144|15663105|0|m,a,s,p", the pseudo header
+// order is the one of the HEADERS frame of this request. It is empty if the
+// request was not sent over HTTP2.
+func (r *Response) H2Fingerprint() string {
+	if r.h2Fingerprint == nil {
+		return ""
+	}
+	return r.h2Fingerprint.Fingerprint()
+}
+
 // ReceivedAt returns the timestamp that response we received.
 func (r *Response) ReceivedAt() time.Time {
 	return r.receivedAt
@@ -175,19 +223,18 @@ func (r *Response) UnmarshalXml(v interface{}) error {
 }
 
 // Unmarshal unmarshalls response body into the specified object according
-// to response `Content-Type`.
+// to response `Content-Type`, see Client.SetResponseDecoder and
+// Request.SetResultUnmarshal.
 func (r *Response) Unmarshal(v interface{}) error {
 	if r.Err != nil {
 		return r.Err
 	}
 	v = util.GetPointer(v)
-	contentType := r.Header.Get("Content-Type")
-	if strings.Contains(contentType, "json") {
-		return r.UnmarshalJson(v)
-	} else if strings.Contains(contentType, "xml") {
-		return r.UnmarshalXml(v)
+	b, err := r.ToBytes()
+	if err != nil {
+		return err
 	}
-	return r.UnmarshalJson(v)
+	return r.Request.client.getResponseDecoder(r)(b, v)
 }
 
 // Into unmarshalls response body into the specified object according
@@ -207,7 +254,8 @@ func (r *Response) SetBodyString(body string) {
 }
 
 // Bytes return the response body as []bytes that have already been read, could be
-// nil if not read, the following cases are already read:
+// nil if not read or spilled to disk (use ToBytes or BodyReader instead), the
+// following cases are already read:
 //  1. `Request.SetResult` or `Request.SetError` is called.
 //  2. `Client.DisableAutoReadResponse` and `Request.DisableAutoReadResponse` is not
 //     called, and also `Request.SetOutput` and `Request.SetOutputFile` is not called.
@@ -216,7 +264,8 @@ func (r *Response) Bytes() []byte {
 }
 
 // String returns the response body as string that have already been read, could be
-// nil if not read, the following cases are already read:
+// empty if not read or spilled to disk (use ToString or BodyReader instead), the
+// following cases are already read:
 //  1. `Request.SetResult` or `Request.SetError` is called.
 //  2. `Client.DisableAutoReadResponse` and `Request.DisableAutoReadResponse` is not
 //     called, and also `Request.SetOutput` and `Request.SetOutputFile` is not called.
@@ -238,6 +287,13 @@ func (r *Response) ToBytes() (body []byte, err error) {
 	if r.body != nil {
 		return r.body, nil
 	}
+	if r.bodyFile != nil { // spilled to disk, read it without caching in memory.
+		body = make([]byte, r.bodyFile.size)
+		if _, err = r.bodyFile.ReadAt(body, 0); err != nil {
+			return nil, err
+		}
+		return r.transformBody(body)
+	}
 	if r.Response == nil || r.Response.Body == nil {
 		return []byte{}, nil
 	}
@@ -248,14 +304,174 @@ func (r *Response) ToBytes() (body []byte, err error) {
 		}
 		r.body = body
 	}()
-	body, err = io.ReadAll(r.Body)
+	body, err = readAll(r.Body, r.ContentLength)
 	r.setReceivedAt()
-	if err == nil && r.Request.client.responseBodyTransformer != nil {
-		body, err = r.Request.client.responseBodyTransformer(body, r.Request, r)
+	if err == nil {
+		body, err = r.transformBody(body)
 	}
 	return
 }
 
+// transformBody applies the response body transformer to the raw body,
+// which is owned by the transformer, so it can be modified in place and
+// returned without copy.
+func (r *Response) transformBody(raw []byte) ([]byte, error) {
+	if fn := r.Request.client.responseBodyTransformer; fn != nil {
+		return fn(raw, r.Request, r)
+	}
+	return raw, nil
+}
+
+// ResponseBodyInfo is the metadata of the response body, see
+// Client.SetResponseBodyStreamTransformer.
+type ResponseBodyInfo struct {
+	// ContentType is the Content-Type of the response.
+	ContentType string
+	// ContentEncoding is the Content-Encoding of the response, it's empty
+	// if the body has been decompressed automatically.
+	ContentEncoding string
+	// ContentLength is the length of the body, -1 if unknown.
+	ContentLength int64
+}
+
+type transformedBody struct {
+	io.Reader
+	raw io.Closer
+}
+
+func (b *transformedBody) Close() error {
+	if c, ok := b.Reader.(io.Closer); ok {
+		c.Close()
+	}
+	return b.raw.Close()
+}
+
+// transformBodyStream applies the streaming response body transformer to
+// the response body.
+func (r *Response) transformBodyStream() error {
+	fn := r.Request.client.bodyStreamTransformer
+	if fn == nil || r.Response == nil || r.Body == nil {
+		return nil
+	}
+	info := ResponseBodyInfo{
+		ContentType:     r.GetContentType(),
+		ContentEncoding: r.GetHeader("Content-Encoding"),
+		ContentLength:   r.ContentLength,
+	}
+	body, err := fn(r.Body, info, r.Request, r)
+	if err != nil {
+		r.Body.Close()
+		return err
+	}
+	if body != r.Body {
+		r.Body = &transformedBody{Reader: body, raw: r.Body}
+		// the length of the transformed body is unknown.
+		r.ContentLength = -1
+	}
+	return nil
+}
+
+// BodyReader returns an io.ReadSeeker of the response body, which reads
+// from the temporary file if the body has been spilled to disk (see
+// Client.SetResponseBodySpillThreshold), otherwise from memory.
+func (r *Response) BodyReader() (io.ReadSeeker, error) {
+	if r.bodyFile != nil {
+		return io.NewSectionReader(r.bodyFile, 0, r.bodyFile.size), nil
+	}
+	b, err := r.ToBytes()
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(b), nil
+}
+
+// IsBodySpilled returns true if the response body is larger than the
+// spill threshold and has been written to a temporary file.
+func (r *Response) IsBodySpilled() bool {
+	return r.bodyFile != nil
+}
+
+// Close removes the temporary file of the spilled response body, it is
+// safe to call even if the body is not spilled. The temporary file is
+// also removed when the Response is garbage collected.
+func (r *Response) Close() error {
+	if r.bodyFile == nil {
+		return nil
+	}
+	err := r.bodyFile.remove()
+	r.bodyFile = nil
+	return err
+}
+
+// spillBody reads the response body into memory if its size does not
+// exceed threshold, otherwise writes it to a temporary file.
+func (r *Response) spillBody(threshold int64) {
+	if r.Response == nil || r.Response.Body == nil {
+		return
+	}
+	size := int64(-1)
+	if r.ContentLength >= 0 && r.ContentLength <= threshold {
+		size = r.ContentLength
+	}
+	buf, err := readAll(io.LimitReader(r.Body, threshold+1), size)
+	if err != nil {
+		r.Body.Close()
+		r.Err = err
+		return
+	}
+	if int64(len(buf)) <= threshold { // fits in memory, which is the body as is.
+		r.Body.Close()
+		r.setReceivedAt()
+		r.body, err = r.transformBody(buf)
+		if err != nil {
+			r.Err = err
+		}
+		return
+	}
+	defer r.Body.Close()
+	f, err := newSpillFile(io.MultiReader(bytes.NewReader(buf), r.Body))
+	r.setReceivedAt()
+	if err != nil {
+		r.Err = err
+		return
+	}
+	r.bodyFile = f
+}
+
+// spillFile is the temporary file which stores the spilled response body.
+type spillFile struct {
+	*os.File
+	size int64
+}
+
+func newSpillFile(body io.Reader) (*spillFile, error) {
+	f, err := os.CreateTemp("", "restys-body-*")
+	if err != nil {
+		return nil, err
+	}
+	size, err := copyToFile(f, body)
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	sf := &spillFile{File: f, size: size}
+	runtime.SetFinalizer(sf, (*spillFile).remove)
+	return sf, nil
+}
+
+func (f *spillFile) remove() error {
+	runtime.SetFinalizer(f, nil)
+	f.File.Close()
+	return os.Remove(f.Name())
+}
+
+// OutputFile returns the path of the file that response body has been
+// saved to, it is empty if the response body is not saved to a file.
+func (r *Response) OutputFile() string {
+	return r.outputFile
+}
+
 // Dump return the string content that have been dumped for the request.
 // `Request.Dump` or `Request.DumpXXX` MUST have been called.
 func (r *Response) Dump() string {
@@ -294,6 +510,29 @@ func (r *Response) GetHeaderValues(key string) []string {
 	return r.Header.Values(key)
 }
 
+// Trailers returns the response trailers, e.g. the checksum or gRPC status
+// sent after the body. Trailers are only available once the response body
+// has been read to EOF, which is guaranteed when the request returns if the
+// body is auto-read (the default) or downloaded with Request.SetOutput or
+// Request.SetOutputFile. If auto-read is disabled, call it after reading
+// Response.Body until io.EOF (or after ToBytes). It works with HTTP/1.1,
+// HTTP/2 and HTTP/3.
+func (r *Response) Trailers() http.Header {
+	if r.Response == nil {
+		return nil
+	}
+	return r.Trailer
+}
+
+// GetTrailer returns the response trailer value by key, see Trailers for
+// when it is available.
+func (r *Response) GetTrailer(key string) string {
+	if r.Response == nil {
+		return ""
+	}
+	return r.Trailer.Get(key)
+}
+
 // HeaderToString get all header as string.
 func (r *Response) HeaderToString() string {
 	if r.Response == nil {
@@ -0,0 +1,253 @@
+package restys
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/luoxk/restys/internal/util"
+)
+
+// DefaultFingerprintIgnoreHeaders is the headers ignored by the response
+// fingerprint by default, which usually change in every response.
+var DefaultFingerprintIgnoreHeaders = []string{
+	"Date", "Age", "Expires", "Set-Cookie", "Content-Length",
+	"X-Request-Id", "Cf-Ray", "X-Amz-Cf-Id", "Server-Timing",
+}
+
+// FingerprintOptions controls the normalization of the response before
+// fingerprinting and diffing, see Response.Fingerprint.
+type FingerprintOptions struct {
+	// IgnoreHeaders is the headers ignored, default is
+	// DefaultFingerprintIgnoreHeaders.
+	IgnoreHeaders []string
+	// IgnoreAllHeaders ignores all the headers, only the status code and
+	// the body are compared.
+	IgnoreAllHeaders bool
+	// IgnoreBodyPatterns is the patterns removed from the body, e.g. the
+	// timestamps and nonces embedded in the HTML.
+	IgnoreBodyPatterns []*regexp.Regexp
+	// IgnoreJSONFields is the fields removed from the JSON body, nested
+	// fields are separated by dot, e.g. "data.updatedAt". The JSON body is
+	// also canonicalized, so the key order and the whitespaces don't
+	// matter.
+	IgnoreJSONFields []string
+}
+
+type normalizedResponse struct {
+	status int
+	header http.Header
+	body   []byte
+	json   interface{} // decoded body if it's JSON
+}
+
+func (o *FingerprintOptions) normalize(r *Response) (*normalizedResponse, error) {
+	if o == nil {
+		o = &FingerprintOptions{}
+	}
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	body, err := r.ToBytes()
+	if err != nil {
+		return nil, err
+	}
+	n := &normalizedResponse{status: r.StatusCode, header: make(http.Header)}
+	if !o.IgnoreAllHeaders && r.Response != nil {
+		ignores := o.IgnoreHeaders
+		if ignores == nil {
+			ignores = DefaultFingerprintIgnoreHeaders
+		}
+		for k, v := range r.Header {
+			n.header[k] = v
+		}
+		for _, k := range ignores {
+			n.header.Del(k)
+		}
+	}
+	for _, p := range o.IgnoreBodyPatterns {
+		body = p.ReplaceAll(body, nil)
+	}
+	if util.IsJSONType(r.GetContentType()) {
+		d := json.NewDecoder(bytes.NewReader(body))
+		d.UseNumber()
+		var v interface{}
+		if d.Decode(&v) == nil {
+			for _, field := range o.IgnoreJSONFields {
+				deleteJSONField(v, strings.Split(field, "."))
+			}
+			if b, err := json.Marshal(v); err == nil {
+				n.json, body = v, b
+			}
+		}
+	}
+	n.body = body
+	return n, nil
+}
+
+func deleteJSONField(v interface{}, path []string) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		if a, ok := v.([]interface{}); ok { // apply to each element
+			for _, e := range a {
+				deleteJSONField(e, path)
+			}
+		}
+		return
+	}
+	if len(path) == 1 {
+		delete(m, path[0])
+		return
+	}
+	if child, ok := m[path[0]]; ok {
+		deleteJSONField(child, path[1:])
+	}
+}
+
+// Fingerprint returns the hex encoded SHA-256 hash of the normalized status
+// code, headers and body, which is the same for the responses with the same
+// content, e.g. to detect the content changes of a polled endpoint. opts
+// can be nil to use the default options, and the body is read if it has not
+// been read.
+func (r *Response) Fingerprint(opts *FingerprintOptions) (string, error) {
+	n, err := opts.normalize(r)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	h.Write([]byte(strconv.Itoa(n.status) + "\n"))
+	keys := make([]string, 0, len(n.header))
+	for k := range n.header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		h.Write([]byte(k + ": " + strings.Join(n.header[k], ", ") + "\n"))
+	}
+	h.Write([]byte("\n"))
+	h.Write(n.body)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// HeaderChange is a changed header in ResponseDiff, Old or New is nil if the
+// header is added or removed.
+type HeaderChange struct {
+	Key string
+	Old []string
+	New []string
+}
+
+// ResponseDiff is the differences of the normalized responses, see
+// DiffResponses.
+type ResponseDiff struct {
+	OldStatusCode int
+	NewStatusCode int
+	// Headers is the changed headers sorted by key.
+	Headers []HeaderChange
+	// BodyChanged reports whether the normalized body is changed.
+	BodyChanged bool
+	// JSONFields is the changed fields of the JSON body, nested fields are
+	// separated by dot and array elements are indexed, e.g.
+	// "data.items.0.price", it's only set if both bodies are JSON.
+	JSONFields []string
+}
+
+// Changed reports whether there is any difference.
+func (d *ResponseDiff) Changed() bool {
+	return d.OldStatusCode != d.NewStatusCode || len(d.Headers) > 0 || d.BodyChanged
+}
+
+// DiffResponses returns the differences between the normalized old and new
+// responses, opts can be nil to use the default options.
+func DiffResponses(old, new *Response, opts *FingerprintOptions) (*ResponseDiff, error) {
+	o, err := opts.normalize(old)
+	if err != nil {
+		return nil, err
+	}
+	n, err := opts.normalize(new)
+	if err != nil {
+		return nil, err
+	}
+	d := &ResponseDiff{
+		OldStatusCode: o.status,
+		NewStatusCode: n.status,
+		BodyChanged:   !bytes.Equal(o.body, n.body),
+	}
+	keys := make(map[string]bool)
+	for k := range o.header {
+		keys[k] = true
+	}
+	for k := range n.header {
+		keys[k] = true
+	}
+	for k := range keys {
+		if !reflect.DeepEqual(o.header[k], n.header[k]) {
+			d.Headers = append(d.Headers, HeaderChange{Key: k, Old: o.header[k], New: n.header[k]})
+		}
+	}
+	sort.Slice(d.Headers, func(i, j int) bool { return d.Headers[i].Key < d.Headers[j].Key })
+	if d.BodyChanged && o.json != nil && n.json != nil {
+		d.JSONFields = diffJSON("", o.json, n.json, nil)
+	}
+	return d, nil
+}
+
+func diffJSON(path string, old, new interface{}, fields []string) []string {
+	join := func(k string) string {
+		if path == "" {
+			return k
+		}
+		return path + "." + k
+	}
+	switch o := old.(type) {
+	case map[string]interface{}:
+		n, ok := new.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(o)+len(n))
+		for k := range o {
+			keys = append(keys, k)
+		}
+		for k := range n {
+			if _, ok := o[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fields = diffJSON(join(k), o[k], n[k], fields)
+		}
+		return fields
+	case []interface{}:
+		n, ok := new.([]interface{})
+		if !ok {
+			break
+		}
+		for i := 0; i < len(o) || i < len(n); i++ {
+			var ov, nv interface{}
+			if i < len(o) {
+				ov = o[i]
+			}
+			if i < len(n) {
+				nv = n[i]
+			}
+			fields = diffJSON(join(strconv.Itoa(i)), ov, nv, fields)
+		}
+		return fields
+	}
+	if !reflect.DeepEqual(old, new) {
+		if path == "" {
+			path = "."
+		}
+		fields = append(fields, path)
+	}
+	return fields
+}
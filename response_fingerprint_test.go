@@ -0,0 +1,65 @@
+package restys
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/luoxk/restys/internal/tests"
+)
+
+func TestResponseFingerprint(t *testing.T) {
+	var price, version int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-Id", fmt.Sprint(time.Now().UnixNano()))
+		w.Header().Set("X-Version", fmt.Sprint(version))
+		if r.URL.Path == "/html" {
+			fmt.Fprintf(w, "<p>%d</p><!-- generated at %d -->", price, time.Now().UnixNano())
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"updatedAt": %d, "data": {"price": %d, "items": [1, 2]}}`, time.Now().UnixNano(), price)
+	}))
+	defer server.Close()
+	c := C().SetBaseURL(server.URL)
+
+	opts := &FingerprintOptions{IgnoreJSONFields: []string{"updatedAt"}}
+	r1, err := c.R().Get("/")
+	assertSuccess(t, r1, err)
+	r2, err := c.R().Get("/")
+	assertSuccess(t, r2, err)
+	fp1, err := r1.Fingerprint(opts)
+	tests.AssertNoError(t, err)
+	fp2, err := r2.Fingerprint(opts)
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, fp1, fp2)
+	fp3, err := r2.Fingerprint(nil)
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, true, fp1 != fp3)
+
+	price, version = 1, 1
+	r3, err := c.R().Get("/")
+	assertSuccess(t, r3, err)
+	diff, err := DiffResponses(r1, r3, opts)
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, true, diff.Changed())
+	tests.AssertEqual(t, true, diff.BodyChanged)
+	tests.AssertEqual(t, []string{"data.price"}, diff.JSONFields)
+	tests.AssertEqual(t, []HeaderChange{{Key: "X-Version", Old: []string{"0"}, New: []string{"1"}}}, diff.Headers)
+
+	diff, err = DiffResponses(r1, r3, &FingerprintOptions{IgnoreAllHeaders: true, IgnoreJSONFields: []string{"updatedAt", "data.price"}})
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, false, diff.Changed())
+
+	opts = &FingerprintOptions{IgnoreBodyPatterns: []*regexp.Regexp{regexp.MustCompile(`<!--.*?-->`)}}
+	r1, err = c.R().Get("/html")
+	assertSuccess(t, r1, err)
+	r2, err = c.R().Get("/html")
+	assertSuccess(t, r2, err)
+	diff, err = DiffResponses(r1, r2, opts)
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, false, diff.Changed())
+}
@@ -1,6 +1,7 @@
 package restys
 
 import (
+	"context"
 	"math"
 	"math/rand"
 	"time"
@@ -14,7 +15,9 @@ func defaultGetRetryInterval(resp *Response, attempt int) time.Duration {
 // whether the request should retry.
 type RetryConditionFunc func(resp *Response, err error) bool
 
-// RetryHookFunc is a retry hook which will be executed before a retry.
+// RetryHookFunc is a retry hook which will be executed before a retry. The
+// resp is reused by the retry, so it's only valid during the call, copy the
+// fields needed later instead of keeping resp.
 type RetryHookFunc func(resp *Response, err error)
 
 // GetRetryIntervalFunc is a function that determines how long should
@@ -57,3 +60,19 @@ func (ro *retryOption) Clone() *retryOption {
 	o.RetryHooks = append(o.RetryHooks, ro.RetryHooks...)
 	return o
 }
+
+// sleepContext pauses for at least d, or returns ctx.Err() if ctx is done
+// before that.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -5,9 +5,11 @@ import (
 	"io"
 	"math"
 	"net/http"
+	"net/url"
 	"testing"
 	"time"
 
+	"github.com/luoxk/restys/internal/netutil"
 	"github.com/luoxk/restys/internal/tests"
 )
 
@@ -196,3 +198,45 @@ func TestRetryTurnedOffWhenRetryCountEqZero(t *testing.T) {
 	tests.AssertIsNil(t, resp.Response)
 	tests.AssertEqual(t, 0, resp.Request.RetryAttempt)
 }
+
+func TestRetryReuseAttempt(t *testing.T) {
+	var resps []*Response
+	var rawRequests []*http.Request
+	c := tc().SetCommonRetryCount(2).
+		SetCommonRetryFixedInterval(0).
+		SetCommonRetryCondition(func(resp *Response, err error) bool {
+			return resp.Request.RetryAttempt < 2
+		}).
+		SetCommonRetryHook(func(resp *Response, err error) {
+			resps = append(resps, resp)
+			rawRequests = append(rawRequests, resp.Request.RawRequest)
+			// the changes of the request in the hook apply to the retry.
+			resp.Request.SetSNI("retry.test")
+		})
+	u, _ := url.Parse(c.BaseURL)
+	c.httpClient.Jar.SetCookies(u, []*http.Cookie{{Name: "jar", Value: "jar"}})
+
+	headers := make(http.Header)
+	resp, err := c.R().
+		SetCookies(&http.Cookie{Name: "test", Value: "test"}).
+		SetResult(&headers).
+		Get("/header")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, 2, resp.Request.RetryAttempt)
+	tests.AssertEqual(t, 2, len(resps))
+	for i := range resps {
+		tests.AssertEqual(t, true, resps[i] == resp)
+		tests.AssertEqual(t, false, rawRequests[i] == resp.Request.RawRequest)
+	}
+	tests.AssertEqual(t, "retry.test", netutil.ServerName(resp.Request.RawRequest.Context()))
+	// the cookies are not accumulated across the attempts.
+	tests.AssertEqual(t, "test=test; jar=jar", headers.Get("Cookie"))
+	tests.AssertEqual(t, "", resp.Request.Headers.Get("Cookie"))
+
+	// the header is shared if it's not mutated.
+	resp, err = tc().R().SetHeader("X-Test", "test").Get("/")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "test", resp.Request.RawRequest.Header.Get("X-Test"))
+	resp.Request.Headers.Set("X-Test", "modified")
+	tests.AssertEqual(t, "modified", resp.Request.RawRequest.Header.Get("X-Test"))
+}
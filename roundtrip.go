@@ -7,6 +7,7 @@
 package restys
 
 import (
+	"io"
 	"net/http"
 )
 
@@ -18,12 +19,14 @@ import (
 // Like the RoundTripper interface, the error types returned
 // by RoundTrip are unspecified.
 func (t *Transport) RoundTrip(req *http.Request) (resp *http.Response, err error) {
+	done := t.startPoolRequest(req)
 	if t.wrappedRoundTrip != nil {
 		resp, err = t.wrappedRoundTrip.RoundTrip(req)
 	} else {
 		resp, err = t.roundTrip(req)
 	}
 	if err != nil {
+		done()
 		return
 	}
 	if resp.ProtoMajor != 3 && t.altSvcJar != nil {
@@ -32,5 +35,12 @@ func (t *Transport) RoundTrip(req *http.Request) (resp *http.Response, err error
 		}
 	}
 	t.handleResponseBody(resp, req)
+	if resp.Body == nil || resp.Body == http.NoBody || bodyIsWritable(resp) {
+		done()
+	} else {
+		t.wrapResponseBody(resp, func(rc io.ReadCloser) io.ReadCloser {
+			return &doneOnEOFBody{ReadCloser: rc, done: done}
+		})
+	}
 	return
 }
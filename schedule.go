@@ -0,0 +1,252 @@
+package restys
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrDeferred is matched by the error (see DeferredError) of the request
+// which is not allowed by the Schedule, use errors.Is to check it.
+var ErrDeferred = errors.New("request deferred by schedule")
+
+// DeferredError is the error of the request which is not allowed by the
+// Schedule in ScheduleDefer mode.
+type DeferredError struct {
+	// Host is the host of the request.
+	Host string
+	// Reason is why the request is not allowed, "window" or "quota".
+	Reason string
+	// Until is the time when the request may be allowed, it's zero if the
+	// request is never allowed by the time windows.
+	Until time.Time
+}
+
+func (e *DeferredError) Error() string {
+	if e.Until.IsZero() {
+		return fmt.Sprintf("request to %s deferred by schedule (%s): never allowed", e.Host, e.Reason)
+	}
+	return fmt.Sprintf("request to %s deferred by schedule (%s) until %s", e.Host, e.Reason, e.Until.Format(time.RFC3339))
+}
+
+// Is makes errors.Is(err, ErrDeferred) work.
+func (e *DeferredError) Is(target error) bool {
+	return target == ErrDeferred
+}
+
+// ScheduleMode is the way of handling the requests which are not allowed
+// by the Schedule.
+type ScheduleMode int
+
+const (
+	// ScheduleBlock blocks the requests until they are allowed or the
+	// request context is done, which is the default.
+	ScheduleBlock ScheduleMode = iota
+	// ScheduleDefer fails the requests immediately with a *DeferredError.
+	ScheduleDefer
+)
+
+// TimeWindow is a daily time window from Start to End (exclusive), which
+// are the offsets from the midnight, e.g. {Start: 22 * time.Hour, End:
+// 6 * time.Hour} is the night which crosses the midnight.
+type TimeWindow struct {
+	Start time.Duration
+	End   time.Duration
+	// Weekdays is the days the window starts on, empty means every day.
+	Weekdays []time.Weekday
+}
+
+func (w TimeWindow) onDay(d time.Weekday) bool {
+	if len(w.Weekdays) == 0 {
+		return true
+	}
+	for _, wd := range w.Weekdays {
+		if wd == d {
+			return true
+		}
+	}
+	return false
+}
+
+// contains reports whether t is in the window, midnight is the midnight of
+// the day of t.
+func (w TimeWindow) contains(t, midnight time.Time) bool {
+	offset := t.Sub(midnight)
+	day := t.Weekday()
+	switch {
+	case w.Start == w.End: // the whole day
+		return w.onDay(day)
+	case w.Start < w.End:
+		return offset >= w.Start && offset < w.End && w.onDay(day)
+	case offset >= w.Start:
+		return w.onDay(day)
+	case offset < w.End: // started on the previous day
+		return w.onDay((day + 6) % 7)
+	}
+	return false
+}
+
+// Schedule controls when the requests are allowed to be sent, for
+// complying with the crawl policies of the target sites, see
+// Client.SetSchedule.
+type Schedule struct {
+	// AllowedWindows is the time windows the requests are allowed in,
+	// empty means any time.
+	AllowedWindows []TimeWindow
+	// QuietHours is the time windows the requests are not allowed in.
+	QuietHours []TimeWindow
+	// HourlyQuota is the max number of requests per host in each clock
+	// hour (each retry attempt counts separately), 0 means no limit.
+	HourlyQuota int
+	// HostQuotas overrides HourlyQuota for the specified hosts (without
+	// port), 0 means no limit.
+	HostQuotas map[string]int
+	// Location is the time zone of the time windows and the clock hours,
+	// default is time.Local.
+	Location *time.Location
+	// Mode is the way of handling the requests which are not allowed,
+	// default is ScheduleBlock.
+	Mode ScheduleMode
+}
+
+type hostQuota struct {
+	hour  time.Time
+	count int
+}
+
+type schedulePolicy struct {
+	Schedule
+	now func() time.Time
+
+	mu     sync.Mutex
+	quotas map[string]*hostQuota
+}
+
+func newSchedulePolicy(s Schedule) *schedulePolicy {
+	if s.Location == nil {
+		s.Location = time.Local
+	}
+	return &schedulePolicy{Schedule: s, now: time.Now, quotas: make(map[string]*hostQuota)}
+}
+
+// SetSchedule set the Schedule which defers or throttles the requests
+// outside the allowed time windows or above the hourly quotas per host, the
+// requests are blocked until allowed, or fail with a *DeferredError (see
+// ErrDeferred) in ScheduleDefer mode. Pass nil to disable it.
+func (c *Client) SetSchedule(s *Schedule) *Client {
+	if s == nil {
+		c.schedule = nil
+		return c
+	}
+	c.schedule = newSchedulePolicy(*s)
+	return c
+}
+
+func midnight(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+func (p *schedulePolicy) inWindow(t time.Time) bool {
+	t = t.In(p.Location)
+	mid := midnight(t)
+	if len(p.AllowedWindows) > 0 {
+		allowed := false
+		for _, w := range p.AllowedWindows {
+			if w.contains(t, mid) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return false
+		}
+	}
+	for _, w := range p.QuietHours {
+		if w.contains(t, mid) {
+			return false
+		}
+	}
+	return true
+}
+
+// nextWindow returns the earliest time not before t which is in the
+// allowed time windows, or zero time if there is none in a week.
+func (p *schedulePolicy) nextWindow(t time.Time) time.Time {
+	if p.inWindow(t) {
+		return t
+	}
+	// the allowance only changes at the window boundaries.
+	var candidates []time.Time
+	mid := midnight(t.In(p.Location))
+	for i := 0; i <= 8; i++ {
+		day := mid.AddDate(0, 0, i)
+		for _, windows := range [][]TimeWindow{p.AllowedWindows, p.QuietHours} {
+			for _, w := range windows {
+				candidates = append(candidates, day.Add(w.Start), day.Add(w.End))
+			}
+		}
+		candidates = append(candidates, day)
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].Before(candidates[j]) })
+	for _, c := range candidates {
+		if c.After(t) && p.inWindow(c) {
+			return c
+		}
+	}
+	return time.Time{}
+}
+
+func (p *schedulePolicy) quota(host string) int {
+	if q, ok := p.HostQuotas[host]; ok {
+		return q
+	}
+	return p.HourlyQuota
+}
+
+// reserve takes a request of host from the quota if the request is allowed
+// now, otherwise returns the reason and the time when it may be allowed.
+func (p *schedulePolicy) reserve(host string) (reason string, until time.Time) {
+	now := p.now()
+	if next := p.nextWindow(now); !next.Equal(now) {
+		return "window", next
+	}
+	limit := p.quota(host)
+	if limit <= 0 {
+		return "", time.Time{}
+	}
+	local := now.In(p.Location)
+	hour := time.Date(local.Year(), local.Month(), local.Day(), local.Hour(), 0, 0, 0, p.Location)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	q := p.quotas[host]
+	if q == nil || !q.hour.Equal(hour) {
+		q = &hostQuota{hour: hour}
+		p.quotas[host] = q
+	}
+	if q.count >= limit {
+		return "quota", p.nextWindow(hour.Add(time.Hour))
+	}
+	q.count++
+	return "", time.Time{}
+}
+
+// wait blocks until the request of host is allowed, or returns a
+// *DeferredError in ScheduleDefer mode.
+func (p *schedulePolicy) wait(ctx context.Context, host string) error {
+	for {
+		reason, until := p.reserve(host)
+		if reason == "" {
+			return nil
+		}
+		if p.Mode == ScheduleDefer || until.IsZero() {
+			return &DeferredError{Host: host, Reason: reason, Until: until}
+		}
+		if err := sleepContext(ctx, until.Sub(p.now())); err != nil {
+			return err
+		}
+	}
+}
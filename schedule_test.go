@@ -0,0 +1,76 @@
+package restys
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/luoxk/restys/internal/tests"
+)
+
+func TestScheduleWindows(t *testing.T) {
+	p := newSchedulePolicy(Schedule{
+		AllowedWindows: []TimeWindow{{Start: 22 * time.Hour, End: 6 * time.Hour}},
+		QuietHours:     []TimeWindow{{Start: 2 * time.Hour, End: 3 * time.Hour, Weekdays: []time.Weekday{time.Sunday}}},
+		Location:       time.UTC,
+	})
+	at := func(day, hour, min int) time.Time { // 2026-10-18 is a Sunday.
+		return time.Date(2026, 10, day, hour, min, 0, 0, time.UTC)
+	}
+	cases := []struct {
+		now, want time.Time
+	}{
+		{at(17, 23, 0), at(17, 23, 0)},
+		{at(18, 1, 0), at(18, 1, 0)},
+		{at(18, 2, 30), at(18, 3, 0)},
+		{at(19, 2, 30), at(19, 2, 30)},
+		{at(18, 12, 0), at(18, 22, 0)},
+		{at(18, 6, 0), at(18, 22, 0)},
+	}
+	for _, c := range cases {
+		tests.AssertEqual(t, c.want, p.nextWindow(c.now))
+	}
+
+	p = newSchedulePolicy(Schedule{AllowedWindows: []TimeWindow{{Start: time.Hour, End: 2 * time.Hour, Weekdays: []time.Weekday{}}}, QuietHours: []TimeWindow{{}}})
+	tests.AssertEqual(t, true, p.nextWindow(time.Now()).IsZero())
+}
+
+func TestSchedule(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	now := time.Date(2026, 10, 18, 10, 59, 59, int(999*time.Millisecond), time.UTC)
+	c := C().SetBaseURL(server.URL).SetSchedule(&Schedule{
+		HourlyQuota: 2,
+		HostQuotas:  map[string]int{"localhost": 0},
+		Location:    time.UTC,
+		Mode:        ScheduleDefer,
+	})
+	c.schedule.now = func() time.Time { return now }
+	for i := 0; i < 2; i++ {
+		resp, err := c.R().Get("/")
+		assertSuccess(t, resp, err)
+	}
+	_, err := c.R().Get("/")
+	tests.AssertEqual(t, true, errors.Is(err, ErrDeferred))
+	var de *DeferredError
+	tests.AssertEqual(t, true, errors.As(err, &de))
+	tests.AssertEqual(t, "quota", de.Reason)
+	tests.AssertEqual(t, time.Date(2026, 10, 18, 11, 0, 0, 0, time.UTC), de.Until)
+
+	// the quota of other hosts is independent.
+	resp, err := c.R().Get("http://localhost:" + server.URL[len("http://127.0.0.1:"):])
+	assertSuccess(t, resp, err)
+
+	// blocks until the next hour or the request context is done.
+	c.schedule.Mode = ScheduleBlock
+	c.schedule.now = time.Now
+	c.schedule.quotas = map[string]*hostQuota{"127.0.0.1": {hour: time.Now().UTC().Truncate(time.Hour), count: 2}}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = c.R().SetContext(ctx).Get("/")
+	tests.AssertEqual(t, true, errors.Is(err, context.DeadlineExceeded))
+}
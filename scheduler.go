@@ -0,0 +1,201 @@
+package restys
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"time"
+)
+
+// SchedulerStats is the metrics of the request scheduler, see
+// Client.EnableScheduler.
+type SchedulerStats struct {
+	// MaxConcurrent is the max number of concurrent requests.
+	MaxConcurrent int `json:"max_concurrent"`
+	// Running is the number of requests which are executing.
+	Running int `json:"running"`
+	// QueueLength is the number of requests which are waiting.
+	QueueLength int `json:"queue_length"`
+	// Scheduled is the total number of requests which have been scheduled.
+	Scheduled uint64 `json:"scheduled"`
+	// TotalWaitTime is the total time the scheduled requests waited in the
+	// queue.
+	TotalWaitTime time.Duration `json:"total_wait_time"`
+	// MaxWaitTime is the max time a scheduled request waited in the queue.
+	MaxWaitTime time.Duration `json:"max_wait_time"`
+}
+
+// AvgWaitTime returns the average time the scheduled requests waited in
+// the queue.
+func (s SchedulerStats) AvgWaitTime() time.Duration {
+	if s.Scheduled == 0 {
+		return 0
+	}
+	return s.TotalWaitTime / time.Duration(s.Scheduled)
+}
+
+type schedulerWaiter struct {
+	priority int
+	seq      uint64
+	enqueued time.Time
+	ready    chan struct{}
+	index    int // index in the queue, -1 if dequeued
+}
+
+// schedulerQueue is a max-heap ordered by priority, and FIFO for the same
+// priority.
+type schedulerQueue []*schedulerWaiter
+
+func (q schedulerQueue) Len() int { return len(q) }
+
+func (q schedulerQueue) Less(i, j int) bool {
+	if q[i].priority != q[j].priority {
+		return q[i].priority > q[j].priority
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q schedulerQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *schedulerQueue) Push(x any) {
+	w := x.(*schedulerWaiter)
+	w.index = len(*q)
+	*q = append(*q, w)
+}
+
+func (q *schedulerQueue) Pop() any {
+	old := *q
+	n := len(old)
+	w := old[n-1]
+	old[n-1] = nil
+	w.index = -1
+	*q = old[:n-1]
+	return w
+}
+
+// scheduler limits the concurrent requests, the waiting requests with
+// higher priority are executed first.
+type scheduler struct {
+	mu            sync.Mutex
+	maxConcurrent int
+	running       int
+	seq           uint64
+	queue         schedulerQueue
+	scheduled     uint64
+	totalWaitTime time.Duration
+	maxWaitTime   time.Duration
+}
+
+func newScheduler(maxConcurrent int) *scheduler {
+	return &scheduler{maxConcurrent: maxConcurrent}
+}
+
+// acquire blocks until the request is allowed to execute, or ctx is done.
+func (s *scheduler) acquire(ctx context.Context, priority int) error {
+	s.mu.Lock()
+	if s.running < s.maxConcurrent && len(s.queue) == 0 {
+		s.running++
+		s.scheduled++
+		s.mu.Unlock()
+		return nil
+	}
+	s.seq++
+	w := &schedulerWaiter{
+		priority: priority,
+		seq:      s.seq,
+		enqueued: time.Now(),
+		ready:    make(chan struct{}),
+	}
+	heap.Push(&s.queue, w)
+	s.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		if w.index >= 0 {
+			heap.Remove(&s.queue, w.index)
+			s.mu.Unlock()
+			return ctx.Err()
+		}
+		s.mu.Unlock()
+		// already scheduled, give the slot to the next one.
+		s.release()
+		return ctx.Err()
+	}
+}
+
+// release hands the slot over to the waiting request with the highest
+// priority.
+func (s *scheduler) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.queue) == 0 {
+		s.running--
+		return
+	}
+	w := heap.Pop(&s.queue).(*schedulerWaiter)
+	wait := time.Since(w.enqueued)
+	s.scheduled++
+	s.totalWaitTime += wait
+	if wait > s.maxWaitTime {
+		s.maxWaitTime = wait
+	}
+	close(w.ready)
+}
+
+func (s *scheduler) stats() SchedulerStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return SchedulerStats{
+		MaxConcurrent: s.maxConcurrent,
+		Running:       s.running,
+		QueueLength:   len(s.queue),
+		Scheduled:     s.scheduled,
+		TotalWaitTime: s.totalWaitTime,
+		MaxWaitTime:   s.maxWaitTime,
+	}
+}
+
+// EnableScheduler limits the number of concurrent requests (each retry
+// attempt counts separately) fired from the client to maxConcurrent, the
+// exceeded requests wait in a queue, and those with higher priority (see
+// Request.SetPriority) are executed first, e.g. the token refresh and
+// health check requests can jump ahead of the bulk scraping traffic
+// sharing the same client.
+func (c *Client) EnableScheduler(maxConcurrent int) *Client {
+	if maxConcurrent <= 0 {
+		c.log.Warnf("ignore invalid max concurrent %d in EnableScheduler", maxConcurrent)
+		return c
+	}
+	c.scheduler = newScheduler(maxConcurrent)
+	return c
+}
+
+// DisableScheduler disables the scheduler (see Client.EnableScheduler).
+func (c *Client) DisableScheduler() *Client {
+	c.scheduler = nil
+	return c
+}
+
+// SchedulerStats returns the metrics of the scheduler, it's empty if the
+// scheduler is not enabled.
+func (c *Client) SchedulerStats() SchedulerStats {
+	if c.scheduler == nil {
+		return SchedulerStats{}
+	}
+	return c.scheduler.stats()
+}
+
+// SetPriority set the priority of the request which is used by the
+// scheduler (see Client.EnableScheduler), the requests with higher priority
+// are executed first, default is 0.
+func (r *Request) SetPriority(priority int) *Request {
+	r.priority = priority
+	return r
+}
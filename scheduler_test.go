@@ -0,0 +1,79 @@
+package restys
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/luoxk/restys/internal/tests"
+)
+
+func TestScheduler(t *testing.T) {
+	release := make(chan struct{})
+	var mu sync.Mutex
+	var order []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := r.URL.Query().Get("name")
+		if name == "first" {
+			<-release
+		}
+		mu.Lock()
+		order = append(order, name)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	c := tc().EnableScheduler(1)
+	var wg sync.WaitGroup
+	fire := func(name string, priority int) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.R().SetPriority(priority).SetQueryParam("name", name).Get(server.URL)
+		}()
+	}
+	waitQueue := func(n int) {
+		for i := 0; i < 100 && c.SchedulerStats().QueueLength < n; i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	fire("first", 0)
+	for i := 0; i < 100 && c.SchedulerStats().Running == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	fire("bulk1", 0)
+	waitQueue(1)
+	fire("bulk2", 0)
+	waitQueue(2)
+	fire("urgent", 10)
+	waitQueue(3)
+
+	// cancelled request leaves the queue.
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		_, err := c.R().SetContext(ctx).Get(server.URL)
+		done <- err
+	}()
+	waitQueue(4)
+	cancel()
+	tests.AssertEqual(t, context.Canceled, <-done)
+	tests.AssertEqual(t, 3, c.SchedulerStats().QueueLength)
+
+	close(release)
+	wg.Wait()
+	tests.AssertEqual(t, []string{"first", "urgent", "bulk1", "bulk2"}, order)
+
+	stats := c.SchedulerStats()
+	tests.AssertEqual(t, 1, stats.MaxConcurrent)
+	tests.AssertEqual(t, 0, stats.Running)
+	tests.AssertEqual(t, 0, stats.QueueLength)
+	tests.AssertEqual(t, uint64(4), stats.Scheduled)
+	tests.AssertEqual(t, true, stats.MaxWaitTime > 0)
+	tests.AssertEqual(t, true, stats.AvgWaitTime() > 0)
+	tests.AssertEqual(t, SchedulerStats{}, c.DisableScheduler().SchedulerStats())
+}
@@ -0,0 +1,283 @@
+package restys
+
+import (
+	"container/list"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SessionProfile is the profile to configure the Client of a session.
+type SessionProfile struct {
+	// Impersonate is the browser to impersonate, one of "chrome", "edge",
+	// "firefox" and "safari", empty means not impersonating.
+	Impersonate string
+	// ProxyURL is the proxy URL of the session.
+	ProxyURL string
+	// CookieJar is the cookie jar of the session, if nil the cookie jar is
+	// created by the cookie jar factory of the base Client (see
+	// Client.SetCookieJarFactory).
+	CookieJar http.CookieJar
+	// Headers is the common headers of the session.
+	Headers map[string]string
+	// Cookies is the common cookies of the session.
+	Cookies []*http.Cookie
+	// Configure optionally customizes the Client after the profile is
+	// applied.
+	Configure func(c *Client) error
+}
+
+func (p *SessionProfile) apply(c *Client) error {
+	switch strings.ToLower(p.Impersonate) {
+	case "":
+	case "chrome":
+		c.ImpersonateChrome()
+	case "edge":
+		c.ImpersonateEdge()
+	case "firefox":
+		c.ImpersonateFirefox()
+	case "safari":
+		c.ImpersonateSafari()
+	default:
+		return fmt.Errorf("unsupported impersonate browser %q", p.Impersonate)
+	}
+	if p.ProxyURL != "" {
+		u, err := url.Parse(p.ProxyURL)
+		if err != nil {
+			return fmt.Errorf("failed to parse proxy url %s: %w", p.ProxyURL, err)
+		}
+		c.SetProxy(http.ProxyURL(u))
+	}
+	if p.CookieJar != nil {
+		c.SetCookieJar(p.CookieJar)
+	}
+	if len(p.Headers) > 0 {
+		c.SetCommonHeaders(p.Headers)
+	}
+	if len(p.Cookies) > 0 {
+		c.SetCommonCookies(p.Cookies...)
+	}
+	if p.Configure != nil {
+		return p.Configure(c)
+	}
+	return nil
+}
+
+// SessionProfileFunc returns the profile of the session key.
+type SessionProfileFunc func(key string) (*SessionProfile, error)
+
+type session struct {
+	key      string
+	client   *Client
+	lastUsed time.Time
+	elem     *list.Element
+}
+
+// SessionManager manages the Clients of sessions keyed by profile or
+// account, each session has its own Client (and thus cookie jar and
+// connections) which is created on demand from the base Client and the
+// profile, the least recently used sessions are evicted if the number of
+// sessions exceeds the limit, and the idle sessions are expired.
+type SessionManager struct {
+	base        *Client
+	profileFunc SessionProfileFunc
+	maxSessions int
+	idleTimeout time.Duration
+	onCreated   func(key string, c *Client) error
+	onEvicted   func(key string, c *Client)
+
+	mu       sync.Mutex
+	sessions map[string]*session
+	lru      *list.List // front is the most recently used
+}
+
+// NewSessionManager create a SessionManager, the Client of each session
+// is cloned from base (a new Client if nil) and configured by the profile
+// returned by profileFunc (nil means no extra configuration).
+func NewSessionManager(base *Client, profileFunc SessionProfileFunc) *SessionManager {
+	if base == nil {
+		base = C()
+	}
+	return &SessionManager{
+		base:        base,
+		profileFunc: profileFunc,
+		sessions:    make(map[string]*session),
+		lru:         list.New(),
+	}
+}
+
+// SetMaxSessions set the max number of sessions, the least recently used
+// session is evicted when exceeded, zero means no limit.
+func (m *SessionManager) SetMaxSessions(n int) *SessionManager {
+	m.mu.Lock()
+	m.maxSessions = n
+	evicted := m.evictLocked(time.Now())
+	m.mu.Unlock()
+	m.closeSessions(evicted)
+	return m
+}
+
+// SetIdleTimeout set the duration after which the session is expired if
+// it's not used, zero means never expire.
+func (m *SessionManager) SetIdleTimeout(d time.Duration) *SessionManager {
+	m.mu.Lock()
+	m.idleTimeout = d
+	m.mu.Unlock()
+	return m
+}
+
+// OnSessionCreated set the hook which is called after the Client of the
+// session is created, e.g. to restore the persisted cookies, the session
+// is discarded if the hook returns error.
+func (m *SessionManager) OnSessionCreated(hook func(key string, c *Client) error) *SessionManager {
+	m.onCreated = hook
+	return m
+}
+
+// OnSessionEvicted set the hook which is called after the session is
+// evicted, expired or removed, e.g. to persist the cookies.
+func (m *SessionManager) OnSessionEvicted(hook func(key string, c *Client)) *SessionManager {
+	m.onEvicted = hook
+	return m
+}
+
+// GetSession returns the Client of the session key, which is created if
+// not exists.
+func (m *SessionManager) GetSession(key string) (*Client, error) {
+	now := time.Now()
+	m.mu.Lock()
+	evicted := m.expireLocked(now)
+	if s, ok := m.sessions[key]; ok {
+		s.lastUsed = now
+		m.lru.MoveToFront(s.elem)
+		m.mu.Unlock()
+		m.closeSessions(evicted)
+		return s.client, nil
+	}
+	m.mu.Unlock()
+	m.closeSessions(evicted)
+
+	c, err := m.newClient(key)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	if s, ok := m.sessions[key]; ok { // created concurrently
+		m.mu.Unlock()
+		c.GetTransport().CloseIdleConnections()
+		return s.client, nil
+	}
+	s := &session{key: key, client: c, lastUsed: now}
+	s.elem = m.lru.PushFront(s)
+	m.sessions[key] = s
+	evicted = m.evictLocked(now)
+	m.mu.Unlock()
+	m.closeSessions(evicted)
+	return c, nil
+}
+
+func (m *SessionManager) newClient(key string) (*Client, error) {
+	c := m.base.Clone()
+	if m.profileFunc != nil {
+		profile, err := m.profileFunc(key)
+		if err != nil {
+			return nil, err
+		}
+		if profile != nil {
+			if err = profile.apply(c); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if m.onCreated != nil {
+		if err := m.onCreated(key, c); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// RemoveSession removes the session key.
+func (m *SessionManager) RemoveSession(key string) {
+	m.mu.Lock()
+	s, ok := m.sessions[key]
+	if ok {
+		m.removeLocked(s)
+	}
+	m.mu.Unlock()
+	if ok {
+		m.closeSessions([]*session{s})
+	}
+}
+
+// Sessions returns the keys of all sessions, the most recently used first.
+func (m *SessionManager) Sessions() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	keys := make([]string, 0, m.lru.Len())
+	for e := m.lru.Front(); e != nil; e = e.Next() {
+		keys = append(keys, e.Value.(*session).key)
+	}
+	return keys
+}
+
+// Close removes all sessions.
+func (m *SessionManager) Close() {
+	m.mu.Lock()
+	var removed []*session
+	for e := m.lru.Front(); e != nil; e = e.Next() {
+		removed = append(removed, e.Value.(*session))
+	}
+	m.sessions = make(map[string]*session)
+	m.lru.Init()
+	m.mu.Unlock()
+	m.closeSessions(removed)
+}
+
+func (m *SessionManager) removeLocked(s *session) {
+	m.lru.Remove(s.elem)
+	delete(m.sessions, s.key)
+}
+
+// expireLocked removes the idle sessions.
+func (m *SessionManager) expireLocked(now time.Time) []*session {
+	if m.idleTimeout <= 0 {
+		return nil
+	}
+	var expired []*session
+	for e := m.lru.Back(); e != nil; {
+		s := e.Value.(*session)
+		if now.Sub(s.lastUsed) < m.idleTimeout {
+			break
+		}
+		e = e.Prev()
+		m.removeLocked(s)
+		expired = append(expired, s)
+	}
+	return expired
+}
+
+// evictLocked removes the idle sessions and the least recently used
+// sessions which exceed the limit.
+func (m *SessionManager) evictLocked(now time.Time) []*session {
+	evicted := m.expireLocked(now)
+	for m.maxSessions > 0 && m.lru.Len() > m.maxSessions {
+		s := m.lru.Back().Value.(*session)
+		m.removeLocked(s)
+		evicted = append(evicted, s)
+	}
+	return evicted
+}
+
+func (m *SessionManager) closeSessions(sessions []*session) {
+	for _, s := range sessions {
+		if m.onEvicted != nil {
+			m.onEvicted(s.key, s.client)
+		}
+		s.client.GetTransport().CloseIdleConnections()
+	}
+}
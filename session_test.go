@@ -0,0 +1,81 @@
+package restys
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/luoxk/restys/internal/tests"
+)
+
+func TestSessionManager(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("X-Account")))
+	}))
+	defer server.Close()
+
+	var created, evicted []string
+	m := NewSessionManager(nil, func(key string) (*SessionProfile, error) {
+		if key == "bad" {
+			return nil, errors.New("no profile")
+		}
+		return &SessionProfile{Headers: map[string]string{"X-Account": key}}, nil
+	}).SetMaxSessions(2).OnSessionCreated(func(key string, c *Client) error {
+		created = append(created, key)
+		return nil
+	}).OnSessionEvicted(func(key string, c *Client) {
+		evicted = append(evicted, key)
+	})
+
+	c1, err := m.GetSession("a")
+	tests.AssertNoError(t, err)
+	resp, err := c1.R().Get(server.URL)
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, "a", resp.String())
+
+	c2, err := m.GetSession("a")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, true, c1 == c2)
+
+	_, err = m.GetSession("bad")
+	tests.AssertEqual(t, true, err != nil)
+
+	m.GetSession("b")
+	m.GetSession("a")
+	m.GetSession("c") // evicts "b", the least recently used
+	tests.AssertEqual(t, []string{"a", "b", "c"}, created)
+	tests.AssertEqual(t, []string{"b"}, evicted)
+	tests.AssertEqual(t, []string{"c", "a"}, m.Sessions())
+
+	m.RemoveSession("a")
+	tests.AssertEqual(t, []string{"b", "a"}, evicted)
+	m.Close()
+	tests.AssertEqual(t, []string{"b", "a", "c"}, evicted)
+	tests.AssertEqual(t, 0, len(m.Sessions()))
+}
+
+func TestSessionManagerIdleTimeout(t *testing.T) {
+	var evicted []string
+	m := NewSessionManager(nil, nil).SetIdleTimeout(50 * time.Millisecond).
+		OnSessionEvicted(func(key string, c *Client) {
+			evicted = append(evicted, key)
+		})
+	c1, _ := m.GetSession("a")
+	time.Sleep(100 * time.Millisecond)
+	c2, _ := m.GetSession("a")
+	tests.AssertEqual(t, true, c1 != c2)
+	tests.AssertEqual(t, []string{"a"}, evicted)
+}
+
+func TestSessionProfile(t *testing.T) {
+	m := NewSessionManager(nil, func(key string) (*SessionProfile, error) {
+		return &SessionProfile{Impersonate: key, ProxyURL: "http://127.0.0.1:8080"}, nil
+	})
+	c, err := m.GetSession("chrome")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, true, c.GetTransport().Proxy != nil)
+	_, err = m.GetSession("netscape")
+	tests.AssertEqual(t, true, err != nil)
+}
@@ -0,0 +1,72 @@
+package restys
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+)
+
+// ErrClientClosed is returned by the requests fired from a closed client,
+// see Client.Close.
+var ErrClientClosed = errors.New("restys: client closed")
+
+// PersistentCookieJar is a http.CookieJar which can save the cookies to the
+// persistent storage, it is saved when the client is closed (see
+// Client.Close).
+type PersistentCookieJar interface {
+	http.CookieJar
+	Save() error
+}
+
+// Close gracefully shuts down the client: it stops accepting new requests
+// (which fail with ErrClientClosed) and the warm pool maintainer, waits
+// for the in-flight requests to complete until ctx is done, then closes
+// the idle HTTP/1.1 and HTTP/2 connections and the QUIC connections,
+// flushes the async dump, closes the tls key log file (see
+// EnableTLSKeyLog), and saves the cookie jar if it's a PersistentCookieJar.
+//
+// The resources are released even if ctx is done before the in-flight
+// requests complete, in which case ctx.Err() is returned.
+func (c *Client) Close(ctx context.Context) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	atomic.StoreInt32(&c.closed, 1)
+	c.DisableWarmPool()
+
+	var errs []error
+	if err := c.inflight.wait(ctx); err != nil {
+		errs = append(errs, err)
+	}
+
+	t := c.GetTransport()
+	t.CloseIdleConnections()
+	if t.t3 != nil {
+		if err := t.t3.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if t.Dump != nil && ctx.Err() == nil {
+		if err := t.Dump.Flush(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if err := c.closeTLSKeyLog(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if jar, ok := c.httpClient.Jar.(PersistentCookieJar); ok {
+		if err := jar.Save(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// IsClosed reports whether the client is closed, see Client.Close.
+func (c *Client) IsClosed() bool {
+	return atomic.LoadInt32(&c.closed) == 1
+}
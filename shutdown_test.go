@@ -0,0 +1,69 @@
+package restys
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/luoxk/restys/internal/tests"
+)
+
+type testPersistentJar struct {
+	*cookiejar.Jar
+	saved int
+}
+
+func (j *testPersistentJar) Save() error {
+	j.saved++
+	return nil
+}
+
+func TestClientClose(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			<-release
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	jar, _ := cookiejar.New(nil)
+	pj := &testPersistentJar{Jar: jar}
+	buf := new(bytes.Buffer)
+	c := C().SetCookieJar(pj).EnableDumpAllTo(buf).EnableDumpAllAsync()
+
+	done := make(chan *Response)
+	go func() {
+		resp, _ := c.R().Get(server.URL + "/slow")
+		done <- resp
+	}()
+	for i := 0; i < 100 && len(c.InflightRequests()) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := c.Close(ctx)
+	tests.AssertEqual(t, true, errors.Is(err, context.DeadlineExceeded))
+	tests.AssertEqual(t, true, c.IsClosed())
+	tests.AssertEqual(t, 1, pj.saved)
+
+	_, err = c.R().Get(server.URL)
+	tests.AssertEqual(t, ErrClientClosed, err)
+	tests.AssertEqual(t, false, c.Clone().IsClosed())
+
+	close(release)
+	resp := <-done
+	tests.AssertNoError(t, resp.Err)
+	tests.AssertEqual(t, "ok", resp.String())
+
+	tests.AssertNoError(t, c.Close(context.Background()))
+	tests.AssertEqual(t, 2, pj.saved)
+	tests.AssertContains(t, buf.String(), "get /slow", true)
+}
@@ -0,0 +1,88 @@
+package restys
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+
+	"github.com/luoxk/restys/internal/transport"
+)
+
+// ErrForbiddenAddress is returned when the request is refused to connect
+// to an address in the forbidden networks (see Client.SetForbiddenNetworks),
+// use errors.Is to check it.
+var ErrForbiddenAddress = transport.ErrForbiddenAddress
+
+// DefaultForbiddenNetworks contains the loopback, private, link-local (which
+// includes the cloud metadata endpoints like 169.254.169.254), shared and
+// unspecified address ranges, which should not be reachable by user-supplied
+// URLs.
+var DefaultForbiddenNetworks = []string{
+	"0.0.0.0/8",      // "this" network
+	"10.0.0.0/8",     // private
+	"100.64.0.0/10",  // shared address space (carrier-grade NAT)
+	"127.0.0.0/8",    // loopback
+	"169.254.0.0/16", // link-local, cloud metadata
+	"172.16.0.0/12",  // private
+	"192.0.0.0/24",   // IETF protocol assignments
+	"192.168.0.0/16", // private
+	"198.18.0.0/15",  // benchmarking
+	"240.0.0.0/4",    // reserved, broadcast
+	"::/128",         // unspecified
+	"::1/128",        // loopback
+	"64:ff9b::/96",   // IPv4/IPv6 translation
+	"fc00::/7",       // unique local, includes fd00:ec2::254 metadata
+	"fe80::/10",      // link-local
+}
+
+func parseNetworks(cidrs []string) ([]netip.Prefix, error) {
+	var errs []error
+	prefixes := make([]netip.Prefix, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			// a single ip address is allowed.
+			ip, e := netip.ParseAddr(cidr)
+			if e != nil {
+				errs = append(errs, fmt.Errorf("invalid network %q", cidr))
+				continue
+			}
+			prefix = netip.PrefixFrom(ip, ip.BitLen())
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, errors.Join(errs...)
+}
+
+// SetForbiddenNetworks set the networks (CIDR or single IP address) that the
+// client refuses to connect to, which protects services that fetch
+// user-supplied URLs from SSRF. It is checked against the resolved IP address
+// right before connecting, so DNS rebinding and every redirect hop are
+// covered. If no network is specified, DefaultForbiddenNetworks is used.
+// Invalid networks are logged and ignored.
+//
+// It does not take effect if a custom dial function is set by SetDial, and
+// the target of a request sent through a proxy is resolved by the proxy.
+func (c *Client) SetForbiddenNetworks(cidrs ...string) *Client {
+	if len(cidrs) == 0 {
+		cidrs = DefaultForbiddenNetworks
+	}
+	prefixes, err := parseNetworks(cidrs)
+	if err != nil {
+		c.log.Errorf("%s", err.Error())
+	}
+	c.Transport.ForbiddenNetworks = prefixes
+	return c
+}
+
+// SetAllowedNetworks set the networks (CIDR or single IP address) that are
+// exceptions of the forbidden networks (see SetForbiddenNetworks), e.g. to
+// allow a specific internal service. Invalid networks are logged and ignored.
+func (c *Client) SetAllowedNetworks(cidrs ...string) *Client {
+	prefixes, err := parseNetworks(cidrs)
+	if err != nil {
+		c.log.Errorf("%s", err.Error())
+	}
+	c.Transport.AllowedNetworks = prefixes
+	return c
+}
@@ -0,0 +1,36 @@
+package restys
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"testing"
+
+	"github.com/luoxk/restys/internal/tests"
+)
+
+func TestSetForbiddenNetworks(t *testing.T) {
+	u, err := url.Parse(getTestServerURL())
+	tests.AssertNoError(t, err)
+
+	c := tc().SetForbiddenNetworks()
+	tests.AssertEqual(t, len(DefaultForbiddenNetworks), len(c.ForbiddenNetworks))
+	_, err = c.R().Get("/")
+	tests.AssertEqual(t, true, errors.Is(err, ErrForbiddenAddress))
+
+	// the resolved address is checked.
+	c.SetHosts(map[string]string{"internal.test": "127.0.0.1"})
+	_, err = c.R().Get("https://" + net.JoinHostPort("internal.test", u.Port()) + "/")
+	tests.AssertEqual(t, true, errors.Is(err, ErrForbiddenAddress))
+
+	c = tc().SetForbiddenNetworks("127.0.0.0/8", "::ffff:7f00:2", "invalid").SetAllowedNetworks("127.0.0.1")
+	tests.AssertEqual(t, 2, len(c.ForbiddenNetworks))
+	resp, err := c.R().Get("/")
+	assertSuccess(t, resp, err)
+
+	// every redirect hop is checked.
+	target := "https://" + net.JoinHostPort("127.0.0.2", u.Port()) + "/"
+	_, err = c.R().SetQueryParam("url", target).Get("/redirect-to")
+	tests.AssertEqual(t, true, errors.Is(err, ErrForbiddenAddress))
+	tests.AssertErrorContains(t, err, "127.0.0.2")
+}
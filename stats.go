@@ -0,0 +1,227 @@
+package restys
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// latencyBuckets is the upper bounds of latency histogram buckets, which
+// are log-linear from 100µs to 100s, the last bucket is unbounded.
+var latencyBuckets = func() []time.Duration {
+	var buckets []time.Duration
+	for scale := 100 * time.Microsecond; scale < 1000*time.Second; scale *= 10 {
+		for _, m := range []float64{1, 1.5, 2, 3, 5, 7} {
+			buckets = append(buckets, time.Duration(float64(scale)*m))
+		}
+	}
+	return buckets
+}()
+
+// LatencySummary is the latency summary of requests, the percentiles are
+// estimated from the histogram.
+type LatencySummary struct {
+	Min  time.Duration `json:"min"`
+	Max  time.Duration `json:"max"`
+	Mean time.Duration `json:"mean"`
+	P50  time.Duration `json:"p50"`
+	P90  time.Duration `json:"p90"`
+	P95  time.Duration `json:"p95"`
+	P99  time.Duration `json:"p99"`
+}
+
+// HostStats is the statistics of requests to a host.
+type HostStats struct {
+	// Host is the host (with port if it's in the URL) of requests, empty
+	// for the total statistics.
+	Host string `json:"host,omitempty"`
+	// Requests is the number of request attempts, including retries.
+	Requests int64 `json:"requests"`
+	// Errors is the number of request attempts which failed with error
+	// or responded with 5xx status code.
+	Errors int64 `json:"errors"`
+	// ErrorRate is Errors divided by Requests.
+	ErrorRate float64 `json:"error_rate"`
+	// Latency is the latency summary of the request attempts, from the
+	// request sent to the response body read.
+	Latency LatencySummary `json:"latency"`
+}
+
+// Stats is the statistics snapshot of a client, see Client.EnableStats.
+type Stats struct {
+	// Since is the time when the statistics started or was reset.
+	Since time.Time `json:"since"`
+	// Total is the statistics of all hosts.
+	Total HostStats `json:"total"`
+	// Hosts is the statistics of each host, sorted by host.
+	Hosts []HostStats `json:"hosts"`
+}
+
+type histogram struct {
+	counts   []int64
+	requests int64
+	errors   int64
+	sum      time.Duration
+	min      time.Duration
+	max      time.Duration
+}
+
+func newHistogram() *histogram {
+	return &histogram{counts: make([]int64, len(latencyBuckets)+1)}
+}
+
+func (h *histogram) record(d time.Duration, isError bool) {
+	i := sort.Search(len(latencyBuckets), func(i int) bool {
+		return d <= latencyBuckets[i]
+	})
+	h.counts[i]++
+	if h.requests == 0 || d < h.min {
+		h.min = d
+	}
+	if d > h.max {
+		h.max = d
+	}
+	h.requests++
+	h.sum += d
+	if isError {
+		h.errors++
+	}
+}
+
+// percentile estimates the p-th percentile with linear interpolation in
+// the bucket.
+func (h *histogram) percentile(p float64) time.Duration {
+	rank := int64(math.Ceil(p * float64(h.requests)))
+	var seen int64
+	for i, n := range h.counts {
+		if n == 0 || seen+n < rank {
+			seen += n
+			continue
+		}
+		lower, upper := h.min, h.max
+		if i > 0 && latencyBuckets[i-1] > lower {
+			lower = latencyBuckets[i-1]
+		}
+		if i < len(latencyBuckets) && latencyBuckets[i] < upper {
+			upper = latencyBuckets[i]
+		}
+		return lower + time.Duration(float64(upper-lower)*float64(rank-seen)/float64(n))
+	}
+	return h.max
+}
+
+func (h *histogram) stats(host string) HostStats {
+	s := HostStats{
+		Host:     host,
+		Requests: h.requests,
+		Errors:   h.errors,
+	}
+	if h.requests == 0 {
+		return s
+	}
+	s.ErrorRate = float64(h.errors) / float64(h.requests)
+	s.Latency = LatencySummary{
+		Min:  h.min,
+		Max:  h.max,
+		Mean: h.sum / time.Duration(h.requests),
+		P50:  h.percentile(0.5),
+		P90:  h.percentile(0.9),
+		P95:  h.percentile(0.95),
+		P99:  h.percentile(0.99),
+	}
+	return s
+}
+
+type statsCollector struct {
+	mu    sync.Mutex
+	since time.Time
+	total *histogram
+	hosts map[string]*histogram
+}
+
+func newStatsCollector() *statsCollector {
+	return &statsCollector{
+		since: time.Now(),
+		total: newHistogram(),
+		hosts: make(map[string]*histogram),
+	}
+}
+
+func (sc *statsCollector) record(resp *Response) {
+	r := resp.Request
+	if r.URL == nil || r.StartTime.IsZero() {
+		return
+	}
+	d := time.Since(r.StartTime)
+	isError := resp.Err != nil || resp.Response == nil || resp.StatusCode >= 500
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	h := sc.hosts[r.URL.Host]
+	if h == nil {
+		h = newHistogram()
+		sc.hosts[r.URL.Host] = h
+	}
+	h.record(d, isError)
+	sc.total.record(d, isError)
+}
+
+func (sc *statsCollector) snapshot() Stats {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	s := Stats{
+		Since: sc.since,
+		Total: sc.total.stats(""),
+		Hosts: make([]HostStats, 0, len(sc.hosts)),
+	}
+	for host, h := range sc.hosts {
+		s.Hosts = append(s.Hosts, h.stats(host))
+	}
+	sort.Slice(s.Hosts, func(i, j int) bool {
+		return s.Hosts[i].Host < s.Hosts[j].Host
+	})
+	return s
+}
+
+func (sc *statsCollector) reset() {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.since = time.Now()
+	sc.total = newHistogram()
+	sc.hosts = make(map[string]*histogram)
+}
+
+// EnableStats enable the built-in statistics of requests (disabled by
+// default), which tracks the request counts, error rates and latency
+// percentiles per host with bounded memory, see Client.Stats.
+func (c *Client) EnableStats() *Client {
+	if c.stats == nil {
+		c.stats = newStatsCollector()
+	}
+	return c
+}
+
+// DisableStats disable the built-in statistics of requests, the collected
+// statistics are dropped.
+func (c *Client) DisableStats() *Client {
+	c.stats = nil
+	return c
+}
+
+// Stats returns the statistics snapshot of requests since the statistics
+// is enabled or reset, it's empty if the statistics is not enabled (see
+// Client.EnableStats).
+func (c *Client) Stats() Stats {
+	if c.stats == nil {
+		return Stats{}
+	}
+	return c.stats.snapshot()
+}
+
+// ResetStats reset the statistics of requests.
+func (c *Client) ResetStats() *Client {
+	if c.stats != nil {
+		c.stats.reset()
+	}
+	return c
+}
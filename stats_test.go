@@ -0,0 +1,64 @@
+package restys
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/luoxk/restys/internal/tests"
+)
+
+func TestHistogramPercentile(t *testing.T) {
+	h := newHistogram()
+	for i := 1; i <= 100; i++ {
+		h.record(time.Duration(i)*time.Millisecond, i > 95)
+	}
+	s := h.stats("example.com")
+	tests.AssertEqual(t, int64(100), s.Requests)
+	tests.AssertEqual(t, int64(5), s.Errors)
+	tests.AssertEqual(t, 0.05, s.ErrorRate)
+	tests.AssertEqual(t, time.Millisecond, s.Latency.Min)
+	tests.AssertEqual(t, 100*time.Millisecond, s.Latency.Max)
+	tests.AssertEqual(t, 50500*time.Microsecond, s.Latency.Mean)
+	for _, c := range []struct {
+		got, want time.Duration
+	}{
+		{s.Latency.P50, 50 * time.Millisecond},
+		{s.Latency.P90, 90 * time.Millisecond},
+		{s.Latency.P99, 99 * time.Millisecond},
+	} {
+		// the percentile is estimated within the bucket
+		tests.AssertEqual(t, true, c.got > c.want*7/10 && c.got < c.want*13/10)
+	}
+}
+
+func TestClientStats(t *testing.T) {
+	c := tc()
+	tests.AssertEqual(t, int64(0), c.Stats().Total.Requests)
+
+	c.EnableStats()
+	for i := 0; i < 3; i++ {
+		resp, err := c.R().Get("/")
+		assertSuccess(t, resp, err)
+	}
+	resp, err := c.R().Get("/status?code=503")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, 503, resp.StatusCode)
+
+	s := c.Stats()
+	tests.AssertEqual(t, int64(4), s.Total.Requests)
+	tests.AssertEqual(t, int64(1), s.Total.Errors)
+	tests.AssertEqual(t, 0.25, s.Total.ErrorRate)
+	tests.AssertEqual(t, true, s.Total.Latency.P50 > 0)
+	tests.AssertEqual(t, true, s.Total.Latency.Max >= s.Total.Latency.P99)
+	u, _ := url.Parse(getTestServerURL())
+	tests.AssertEqual(t, 1, len(s.Hosts))
+	tests.AssertEqual(t, u.Host, s.Hosts[0].Host)
+	tests.AssertEqual(t, int64(4), s.Hosts[0].Requests)
+
+	c.ResetStats()
+	tests.AssertEqual(t, int64(0), c.Stats().Total.Requests)
+	c.DisableStats()
+	c.R().Get("/")
+	tests.AssertEqual(t, int64(0), c.Stats().Total.Requests)
+}
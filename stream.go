@@ -0,0 +1,93 @@
+package restys
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// streamBufferSize is the buffer size of the stream reader, which matches
+// the buffer size of the http transport.
+const streamBufferSize = 4 << 10
+
+var streamReaderPool = sync.Pool{New: func() any { return bufio.NewReaderSize(nil, streamBufferSize) }}
+
+// Stream fires the request (the method and URL set by SetURL and
+// SetMethod-like setters, GET by default), and returns the response body as
+// a stream, which is the fast path for the proxy-style workloads:
+//
+//	body, resp, err := client.R().SetURL(upstream).Stream()
+//	if err != nil {
+//	    return err
+//	}
+//	defer body.Close()
+//	w.WriteHeader(resp.StatusCode)
+//	io.Copy(w, body)
+//
+// The body is never read automatically, the response body transformer,
+// the charset decoding and the unmarshal of SetSuccessResult and
+// SetErrorResult are skipped, and the body is read through a pooled
+// bufio.Reader which is put back on Close, so the body must be closed.
+// The error is the same as Response.Err, the body is nil if err is not nil.
+func (r *Request) Stream() (io.ReadCloser, *Response, error) {
+	r.stream = true
+	resp := r.Do()
+	if resp.Err != nil && r.client.onError != nil {
+		r.client.onError(r.client, r, resp, resp.Err)
+	}
+	if resp.Err != nil {
+		return nil, resp, resp.Err
+	}
+	if resp.Response == nil || resp.Body == nil {
+		return http.NoBody, resp, nil
+	}
+	br := streamReaderPool.Get().(*bufio.Reader)
+	br.Reset(resp.Body)
+	body := &streamBody{br: br, rc: resp.Body}
+	resp.Body = body
+	return body, resp, nil
+}
+
+// streamBody reads the response body through a pooled bufio.Reader, Close
+// may be called concurrently with Read to abort it like the http body.
+type streamBody struct {
+	mu   sync.Mutex
+	br   *bufio.Reader
+	rc   io.ReadCloser
+	once sync.Once
+	err  error
+}
+
+func (b *streamBody) Read(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.br == nil {
+		return 0, http.ErrBodyReadAfterClose
+	}
+	return b.br.Read(p)
+}
+
+// WriteTo implements io.WriterTo, so io.Copy copies without the
+// intermediate buffer of its own.
+func (b *streamBody) WriteTo(w io.Writer) (int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.br == nil {
+		return 0, http.ErrBodyReadAfterClose
+	}
+	return b.br.WriteTo(w)
+}
+
+func (b *streamBody) Close() error {
+	b.once.Do(func() {
+		// close the underlying body first to unblock the pending Read.
+		b.err = b.rc.Close()
+		b.mu.Lock()
+		b.br.Reset(nil)
+		streamReaderPool.Put(b.br)
+		b.br = nil
+		b.mu.Unlock()
+	})
+	return b.err
+}
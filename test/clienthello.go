@@ -0,0 +1,175 @@
+package test
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ClientHello is the TLS ClientHello sent by the client.
+type ClientHello struct {
+	// Version is the legacy version field, e.g. 0x0303 (771) for TLS 1.2
+	// and TLS 1.3.
+	Version         uint16
+	CipherSuites    []uint16
+	Extensions      []uint16 // extension types in the order sent
+	SupportedGroups []uint16
+	PointFormats    []uint8
+	ServerName      string
+	ALPN            []string
+	Raw             []byte // the handshake message
+}
+
+func isGREASE(v uint16) bool {
+	return v&0x0f0f == 0x0a0a && v>>8 == v&0xff
+}
+
+// JA3 returns the JA3 string of the ClientHello, GREASE values are ignored.
+func (ch *ClientHello) JA3() string {
+	join := func(vs []uint16) string {
+		s := make([]string, 0, len(vs))
+		for _, v := range vs {
+			if !isGREASE(v) {
+				s = append(s, strconv.Itoa(int(v)))
+			}
+		}
+		return strings.Join(s, "-")
+	}
+	formats := make([]uint16, len(ch.PointFormats))
+	for i, f := range ch.PointFormats {
+		formats[i] = uint16(f)
+	}
+	return strings.Join([]string{
+		strconv.Itoa(int(ch.Version)),
+		join(ch.CipherSuites),
+		join(ch.Extensions),
+		join(ch.SupportedGroups),
+		join(formats),
+	}, ",")
+}
+
+// JA3Hash returns the MD5 hash of the JA3 string.
+func (ch *ClientHello) JA3Hash() string {
+	sum := md5.Sum([]byte(ch.JA3()))
+	return hex.EncodeToString(sum[:])
+}
+
+const (
+	recordTypeHandshake      = 22
+	handshakeTypeClientHello = 1
+)
+
+var errNotClientHello = errors.New("test: not a TLS ClientHello")
+
+// readClientHello reads the TLS records which carry the ClientHello, it
+// returns the records (to be replayed to the TLS server) and the handshake
+// message.
+func readClientHello(r io.Reader) (records, msg []byte, err error) {
+	for {
+		var hdr [5]byte
+		if _, err = io.ReadFull(r, hdr[:]); err != nil {
+			return
+		}
+		if hdr[0] != recordTypeHandshake {
+			return nil, nil, errNotClientHello
+		}
+		payload := make([]byte, binary.BigEndian.Uint16(hdr[3:]))
+		if _, err = io.ReadFull(r, payload); err != nil {
+			return
+		}
+		records = append(append(records, hdr[:]...), payload...)
+		msg = append(msg, payload...)
+		if len(msg) >= 4 {
+			if msg[0] != handshakeTypeClientHello {
+				return nil, nil, errNotClientHello
+			}
+			n := 4 + (int(msg[1])<<16 | int(msg[2])<<8 | int(msg[3]))
+			if len(msg) >= n {
+				return records, msg[:n], nil
+			}
+		}
+	}
+}
+
+// reader is the cursor of the ClientHello message.
+type reader []byte
+
+func (r *reader) bytes(n int) []byte {
+	if n < 0 || len(*r) < n {
+		*r = nil
+		return nil
+	}
+	b := (*r)[:n]
+	*r = (*r)[n:]
+	return b
+}
+
+func (r *reader) u8() int {
+	b := r.bytes(1)
+	if b == nil {
+		return 0
+	}
+	return int(b[0])
+}
+
+func (r *reader) u16() int {
+	b := r.bytes(2)
+	if b == nil {
+		return 0
+	}
+	return int(binary.BigEndian.Uint16(b))
+}
+
+func (r *reader) u16s(n int) []uint16 {
+	b := r.bytes(n)
+	vs := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		vs = append(vs, binary.BigEndian.Uint16(b[i:]))
+	}
+	return vs
+}
+
+func parseClientHello(msg []byte) (*ClientHello, error) {
+	r := reader(msg)
+	r.bytes(4) // handshake header
+	ch := &ClientHello{Raw: msg}
+	ch.Version = uint16(r.u16())
+	r.bytes(32)     // random
+	r.bytes(r.u8()) // session id
+	ch.CipherSuites = r.u16s(r.u16())
+	r.bytes(r.u8()) // compression methods
+	if r == nil {
+		return nil, errNotClientHello
+	}
+	exts := reader(r.bytes(r.u16()))
+	for len(exts) > 0 {
+		typ := uint16(exts.u16())
+		data := reader(exts.bytes(exts.u16()))
+		ch.Extensions = append(ch.Extensions, typ)
+		switch typ {
+		case 0: // server_name
+			list := reader(data.bytes(data.u16()))
+			for len(list) > 0 {
+				nameType := list.u8()
+				name := list.bytes(list.u16())
+				if nameType == 0 {
+					ch.ServerName = string(name)
+				}
+			}
+		case 10: // supported_groups
+			ch.SupportedGroups = data.u16s(data.u16())
+		case 11: // ec_point_formats
+			ch.PointFormats = data.bytes(data.u8())
+		case 16: // application_layer_protocol_negotiation
+			list := reader(data.bytes(data.u16()))
+			for len(list) > 0 {
+				ch.ALPN = append(ch.ALPN, string(list.bytes(list.u8())))
+			}
+		}
+	}
+	return ch, nil
+}
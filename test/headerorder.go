@@ -0,0 +1,206 @@
+package test
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http/httputil"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+)
+
+// headerBlock is the header order of a request read from the wire.
+type headerBlock struct {
+	method      string
+	path        string
+	order       []string
+	pseudoOrder []string
+}
+
+// headerLog collects the header blocks of a connection, which are taken by
+// the handler of the matched request.
+type headerLog struct {
+	mu     sync.Mutex
+	blocks []*headerBlock
+	done   bool
+	wake   chan struct{}
+}
+
+func newHeaderLog() *headerLog {
+	return &headerLog{wake: make(chan struct{})}
+}
+
+func (l *headerLog) add(b *headerBlock) {
+	l.mu.Lock()
+	l.blocks = append(l.blocks, b)
+	close(l.wake)
+	l.wake = make(chan struct{})
+	l.mu.Unlock()
+}
+
+func (l *headerLog) finish() {
+	l.mu.Lock()
+	if !l.done {
+		l.done = true
+		close(l.wake)
+		l.wake = make(chan struct{})
+	}
+	l.mu.Unlock()
+}
+
+// take removes and returns the first header block of the request, the
+// server may dispatch the request before the header block is parsed from
+// the tapped stream, so it waits up to timeout.
+func (l *headerLog) take(method, path string, timeout time.Duration) *headerBlock {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		l.mu.Lock()
+		for i, b := range l.blocks {
+			if b.method == method && b.path == path {
+				l.blocks = append(l.blocks[:i], l.blocks[i+1:]...)
+				l.mu.Unlock()
+				return b
+			}
+		}
+		wake, done := l.wake, l.done
+		l.mu.Unlock()
+		if done {
+			return nil
+		}
+		select {
+		case <-wake:
+		case <-timer.C:
+			return nil
+		}
+	}
+}
+
+// tapConn copies the plaintext read by the server to the header parser.
+type tapConn struct {
+	net.Conn
+	w     *io.PipeWriter
+	state *connState
+}
+
+func (c *tapConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if n > 0 {
+		c.w.Write(p[:n])
+	}
+	// the server aborts the background read by deadline, which does not
+	// end the stream.
+	if ne, ok := err.(net.Error); err != nil && !(ok && ne.Timeout()) {
+		c.w.CloseWithError(err)
+	}
+	return n, err
+}
+
+func (c *tapConn) Close() error {
+	c.w.Close()
+	return c.Conn.Close()
+}
+
+// parseHTTP1Headers reads the HTTP/1.x requests from r and adds the header
+// names in the order and case as sent.
+func parseHTTP1Headers(r io.Reader, l *headerLog) {
+	defer io.Copy(io.Discard, r)
+	defer l.finish()
+	br := bufio.NewReader(r)
+	for {
+		line, err := readLine(br)
+		if err != nil {
+			return
+		}
+		if line == "" { // tolerate the empty lines between requests
+			continue
+		}
+		parts := strings.Fields(line)
+		if len(parts) < 2 {
+			return
+		}
+		b := &headerBlock{method: parts[0], path: parts[1]}
+		var chunked bool
+		var length int64
+		for {
+			if line, err = readLine(br); err != nil {
+				return
+			}
+			if line == "" {
+				break
+			}
+			name, value, _ := strings.Cut(line, ":")
+			b.order = append(b.order, name)
+			value = strings.TrimSpace(value)
+			switch strings.ToLower(name) {
+			case "transfer-encoding":
+				chunked = strings.EqualFold(value, "chunked")
+			case "content-length":
+				length, _ = strconv.ParseInt(value, 10, 64)
+			}
+		}
+		l.add(b)
+
+		// skip the body to the next request.
+		if chunked {
+			if _, err = io.Copy(io.Discard, httputil.NewChunkedReader(br)); err != nil {
+				return
+			}
+			for { // trailer
+				if line, err = readLine(br); err != nil || line == "" {
+					break
+				}
+			}
+		} else if length > 0 {
+			if _, err = io.CopyN(io.Discard, br, length); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func readLine(br *bufio.Reader) (string, error) {
+	line, err := br.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// parseHTTP2Headers reads the HTTP/2 frames from r and adds the header
+// names of the HEADERS frames in the order as sent.
+func parseHTTP2Headers(r io.Reader, l *headerLog) {
+	defer io.Copy(io.Discard, r)
+	defer l.finish()
+	preface := make([]byte, len(http2.ClientPreface))
+	if _, err := io.ReadFull(r, preface); err != nil || string(preface) != http2.ClientPreface {
+		return
+	}
+	fr := http2.NewFramer(io.Discard, r)
+	fr.SetMaxReadFrameSize(1<<24 - 1)
+	fr.ReadMetaHeaders = hpack.NewDecoder(4096, nil)
+	for {
+		f, err := fr.ReadFrame()
+		if err != nil {
+			return
+		}
+		mh, ok := f.(*http2.MetaHeadersFrame)
+		if !ok {
+			continue
+		}
+		b := &headerBlock{method: mh.PseudoValue("method"), path: mh.PseudoValue("path")}
+		for _, hf := range mh.Fields {
+			if hf.IsPseudo() {
+				b.pseudoOrder = append(b.pseudoOrder, hf.Name)
+			} else {
+				b.order = append(b.order, hf.Name)
+			}
+		}
+		l.add(b)
+	}
+}
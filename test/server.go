@@ -0,0 +1,354 @@
+// Package test provides a local HTTPS test server which records the
+// requests it receives, including the header order on the wire and the TLS
+// ClientHello (JA3) of the client, so that users can assert what the client
+// actually sends in their own test suites, e.g. the webhook callbacks:
+//
+//	srv := test.NewServer(nil)
+//	defer srv.Close()
+//	client := srv.Client().ImpersonateChrome()
+//	client.R().Get(srv.URL + "/callback")
+//	r := srv.LastRequest()
+//	fmt.Println(r.HeaderOrder, r.ClientHello.JA3())
+package test
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/luoxk/restys"
+	"github.com/luoxk/restys/internal/testcert"
+	"golang.org/x/net/http2"
+)
+
+// RecordedRequest is a request received by the Server.
+type RecordedRequest struct {
+	Method     string
+	URL        *url.URL // the request target, e.g. "/path?query"
+	Proto      string
+	Host       string
+	Header     http.Header
+	Body       []byte
+	RemoteAddr string
+	Time       time.Time
+	// HeaderOrder is the header names in the order and case as sent (lower
+	// case for HTTP/2), PseudoHeaderOrder is the pseudo header names of
+	// HTTP/2, e.g. [":method", ":authority", ":scheme", ":path"].
+	HeaderOrder       []string
+	PseudoHeaderOrder []string
+	// TLS is the state of the TLS connection, ClientHello is the TLS
+	// ClientHello sent by the client, which is nil if it is not parsed.
+	TLS         *tls.ConnectionState
+	ClientHello *ClientHello
+}
+
+// connState is the state of a client connection shared by its requests.
+type connState struct {
+	hello *ClientHello
+	tls   tls.ConnectionState
+	log   *headerLog
+}
+
+type connStateKey struct{}
+
+// headerOrderTimeout is how long the handler waits for the header order of
+// the request parsed from the wire.
+const headerOrderTimeout = time.Second
+
+// Server is the HTTPS test server, which serves both HTTP/1.1 and HTTP/2
+// according to the ALPN of the client.
+type Server struct {
+	// URL is the base URL of the server, e.g. "https://127.0.0.1:12345".
+	URL      string
+	Listener net.Listener
+
+	handler   http.Handler
+	tlsConfig *tls.Config
+	h1        *http.Server
+	h1conns   *connListener
+	h2        *http2.Server
+
+	mu       sync.Mutex
+	requests []*RecordedRequest
+	changed  chan struct{}
+	conns    map[net.Conn]struct{}
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+// NewServer starts and returns a new HTTPS server listening on 127.0.0.1,
+// handler handles the requests after they are recorded, nil means reply
+// "200 OK" with an empty body. The caller should call Close when finished.
+func NewServer(handler http.Handler) *Server {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic("test: failed to listen: " + err.Error())
+	}
+	cert, err := tls.X509KeyPair(testcert.LocalhostCert, testcert.LocalhostKey)
+	if err != nil {
+		panic("test: invalid certificate: " + err.Error())
+	}
+	if handler == nil {
+		handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	}
+	s := &Server{
+		URL:      "https://" + ln.Addr().String(),
+		Listener: ln,
+		handler:  handler,
+		tlsConfig: &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			NextProtos:   []string{"h2", "http/1.1"},
+		},
+		h2:      &http2.Server{},
+		changed: make(chan struct{}),
+		conns:   make(map[net.Conn]struct{}),
+	}
+	s.h1conns = &connListener{addr: ln.Addr(), conns: make(chan net.Conn), done: make(chan struct{})}
+	s.h1 = &http.Server{
+		Handler: http.HandlerFunc(s.serveHTTP),
+		ConnContext: func(ctx context.Context, c net.Conn) context.Context {
+			if tc, ok := c.(*tapConn); ok {
+				ctx = context.WithValue(ctx, connStateKey{}, tc.state)
+			}
+			return ctx
+		},
+	}
+	s.wg.Add(2)
+	go func() {
+		defer s.wg.Done()
+		s.h1.Serve(s.h1conns)
+	}()
+	go s.serve()
+	return s
+}
+
+// Certificate returns the certificate of the server.
+func (s *Server) Certificate() *x509.Certificate {
+	block, _ := pem.Decode(testcert.LocalhostCert)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		panic("test: invalid certificate: " + err.Error())
+	}
+	return cert
+}
+
+// Client returns a new client which trusts the certificate of the server.
+func (s *Server) Client() *restys.Client {
+	return restys.C().SetRootCertFromString(string(testcert.LocalhostCert))
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.Listener.Accept()
+		if err != nil {
+			return
+		}
+		if !s.trackConn(conn, true) {
+			conn.Close()
+			return
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.serveConn(conn)
+		}()
+	}
+}
+
+func (s *Server) trackConn(c net.Conn, add bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if add {
+		if s.closed {
+			return false
+		}
+		s.conns[c] = struct{}{}
+	} else {
+		delete(s.conns, c)
+	}
+	return true
+}
+
+// prefixConn replays the ClientHello records read by the server.
+type prefixConn struct {
+	net.Conn
+	r io.Reader
+}
+
+func (c *prefixConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	records, msg, err := readClientHello(conn)
+	if err != nil {
+		conn.Close()
+		s.trackConn(conn, false)
+		return
+	}
+	hello, _ := parseClientHello(msg)
+	tlsConn := tls.Server(&prefixConn{Conn: conn, r: io.MultiReader(bytes.NewReader(records), conn)}, s.tlsConfig)
+	if err = tlsConn.Handshake(); err != nil {
+		tlsConn.Close()
+		s.trackConn(conn, false)
+		return
+	}
+	conn.SetDeadline(time.Time{})
+
+	state := &connState{hello: hello, tls: tlsConn.ConnectionState(), log: newHeaderLog()}
+	pr, pw := io.Pipe()
+	tc := &tapConn{Conn: tlsConn, w: pw, state: state}
+	if state.tls.NegotiatedProtocol == http2.NextProtoTLS {
+		go parseHTTP2Headers(pr, state.log)
+		s.h2.ServeConn(tc, &http2.ServeConnOpts{
+			Context:    context.WithValue(context.Background(), connStateKey{}, state),
+			BaseConfig: s.h1,
+			Handler:    http.HandlerFunc(s.serveHTTP),
+		})
+		tc.Close()
+		s.trackConn(conn, false)
+		return
+	}
+	go parseHTTP1Headers(pr, state.log)
+	select {
+	case s.h1conns.conns <- tc:
+	case <-s.h1conns.done:
+		tc.Close()
+	}
+	// the HTTP/1.1 connection is tracked by the http.Server from now on.
+	s.trackConn(conn, false)
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	rec := &RecordedRequest{
+		Method:     r.Method,
+		Proto:      r.Proto,
+		Host:       r.Host,
+		Header:     r.Header.Clone(),
+		Body:       body,
+		RemoteAddr: r.RemoteAddr,
+		Time:       time.Now(),
+	}
+	rec.URL, _ = url.ParseRequestURI(r.RequestURI)
+	if state, ok := r.Context().Value(connStateKey{}).(*connState); ok {
+		tlsState := state.tls
+		rec.TLS = &tlsState
+		rec.ClientHello = state.hello
+		if b := state.log.take(r.Method, r.RequestURI, headerOrderTimeout); b != nil {
+			rec.HeaderOrder = b.order
+			rec.PseudoHeaderOrder = b.pseudoOrder
+		}
+		if r.TLS == nil {
+			r.TLS = rec.TLS
+		}
+	}
+
+	s.mu.Lock()
+	s.requests = append(s.requests, rec)
+	close(s.changed)
+	s.changed = make(chan struct{})
+	s.mu.Unlock()
+
+	s.handler.ServeHTTP(w, r)
+}
+
+// Requests returns the requests received so far in order.
+func (s *Server) Requests() []*RecordedRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*RecordedRequest(nil), s.requests...)
+}
+
+// LastRequest returns the last received request, or nil if there is none.
+func (s *Server) LastRequest() *RecordedRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.requests) == 0 {
+		return nil
+	}
+	return s.requests[len(s.requests)-1]
+}
+
+// Reset discards the received requests.
+func (s *Server) Reset() {
+	s.mu.Lock()
+	s.requests = nil
+	s.mu.Unlock()
+}
+
+// Wait waits until at least n requests are received, which is useful for
+// the requests sent asynchronously (e.g. webhook callbacks), and returns the
+// received requests.
+func (s *Server) Wait(ctx context.Context, n int) ([]*RecordedRequest, error) {
+	for {
+		s.mu.Lock()
+		if len(s.requests) >= n {
+			requests := append([]*RecordedRequest(nil), s.requests...)
+			s.mu.Unlock()
+			return requests, nil
+		}
+		changed := s.changed
+		s.mu.Unlock()
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Close shuts down the server and closes all connections.
+func (s *Server) Close() {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.closed = true
+	for c := range s.conns {
+		c.Close()
+	}
+	s.mu.Unlock()
+	s.Listener.Close()
+	s.h1.Close()
+	s.wg.Wait()
+}
+
+// connListener is the listener of the HTTP/1.x connections for http.Server.
+type connListener struct {
+	addr  net.Addr
+	conns chan net.Conn
+	done  chan struct{}
+	once  sync.Once
+}
+
+func (l *connListener) Accept() (net.Conn, error) {
+	select {
+	case c := <-l.conns:
+		return c, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *connListener) Close() error {
+	l.once.Do(func() { close(l.done) })
+	return nil
+}
+
+func (l *connListener) Addr() net.Addr {
+	return l.addr
+}
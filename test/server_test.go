@@ -0,0 +1,114 @@
+package test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/luoxk/restys/internal/tests"
+)
+
+func TestServerHTTP1(t *testing.T) {
+	srv := NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	}))
+	defer srv.Close()
+
+	c := srv.Client().EnableForceHTTP1()
+	for i := 0; i < 2; i++ {
+		resp, err := c.R().
+			SetHeaderNonCanonical("x-lower", "1").
+			SetHeader("X-Upper", "2").
+			SetHeaderOrder("x-upper", "x-lower").
+			SetBodyString("body").
+			Post(srv.URL + "/callback?n=1")
+		tests.AssertNoError(t, err)
+		tests.AssertEqual(t, "hello", resp.String())
+	}
+
+	requests := srv.Requests()
+	tests.AssertEqual(t, 2, len(requests))
+	r := srv.LastRequest()
+	tests.AssertEqual(t, "POST", r.Method)
+	tests.AssertEqual(t, "HTTP/1.1", r.Proto)
+	tests.AssertEqual(t, "/callback", r.URL.Path)
+	tests.AssertEqual(t, "n=1", r.URL.RawQuery)
+	tests.AssertEqual(t, "body", string(r.Body))
+	tests.AssertEqual(t, "1", r.Header.Get("X-Lower"))
+	order := strings.Join(r.HeaderOrder, ",")
+	if strings.Index(order, "X-Upper") > strings.Index(order, "x-lower") {
+		t.Errorf("unexpected header order %q", order)
+	}
+	tests.AssertNotNil(t, r.TLS)
+	tests.AssertNotNil(t, r.ClientHello)
+	tests.AssertEqual(t, 32, len(r.ClientHello.JA3Hash()))
+	if parts := strings.Split(r.ClientHello.JA3(), ","); len(parts) != 5 || parts[0] != "771" {
+		t.Errorf("unexpected JA3 %q", r.ClientHello.JA3())
+	}
+
+	srv.Reset()
+	tests.AssertIsNil(t, srv.LastRequest())
+}
+
+func TestServerHTTP2(t *testing.T) {
+	srv := NewServer(nil)
+	defer srv.Close()
+
+	resp, err := srv.Client().EnableForceHTTP2().R().
+		SetHeader("X-A", "1").
+		SetHeader("X-B", "2").
+		SetHeaderOrder("x-b", "x-a").
+		Get(srv.URL + "/h2")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, http.StatusOK, resp.StatusCode)
+
+	r := srv.LastRequest()
+	tests.AssertEqual(t, "HTTP/2.0", r.Proto)
+	tests.AssertEqual(t, "h2", r.TLS.NegotiatedProtocol)
+	tests.AssertEqual(t, 4, len(r.PseudoHeaderOrder))
+	order := strings.Join(r.HeaderOrder, ",")
+	if strings.Index(order, "x-b") > strings.Index(order, "x-a") {
+		t.Errorf("unexpected header order %q", order)
+	}
+	tests.AssertEqual(t, 2, len(r.ClientHello.ALPN))
+}
+
+func TestServerImpersonate(t *testing.T) {
+	srv := NewServer(nil)
+	defer srv.Close()
+
+	c := srv.Client().ImpersonateChrome()
+	_, err := c.R().Get(srv.URL)
+	tests.AssertNoError(t, err)
+	hello := srv.LastRequest().ClientHello
+	tests.AssertNotNil(t, hello)
+	for _, v := range strings.Split(strings.Split(hello.JA3(), ",")[2], "-") {
+		if v == "2570" { // GREASE
+			t.Errorf("GREASE is not ignored in JA3 %q", hello.JA3())
+		}
+	}
+}
+
+func TestServerWait(t *testing.T) {
+	srv := NewServer(nil)
+	defer srv.Close()
+
+	c := srv.Client()
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		c.R().Get(srv.URL + "/a")
+		c.R().Get(srv.URL + "/b")
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	requests, err := srv.Wait(ctx, 2)
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, "/b", requests[1].URL.Path)
+
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = srv.Wait(ctx, 3)
+	tests.AssertEqual(t, context.DeadlineExceeded, err)
+}
@@ -0,0 +1,156 @@
+package restys
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/luoxk/restys/internal/tlsutil"
+)
+
+// TLSInfo represents the negotiated TLS details of a response.
+type TLSInfo struct {
+	// Version is the TLS version, e.g. tls.VersionTLS13.
+	Version uint16
+	// VersionName is the name of Version, e.g. "TLS 1.3".
+	VersionName string
+	// CipherSuite is the cipher suite, e.g. tls.TLS_AES_128_GCM_SHA256.
+	CipherSuite uint16
+	// CipherSuiteName is the name of CipherSuite.
+	CipherSuiteName string
+	// ALPN is the protocol negotiated with ALPN, e.g. "h2", empty if
+	// ALPN is not negotiated.
+	ALPN string
+	// ServerName is the server name sent in SNI.
+	ServerName string
+	// Resumed is whether the session is resumed from a previous one.
+	Resumed bool
+	// ECHAccepted is whether the Encrypted Client Hello is accepted by
+	// the server.
+	ECHAccepted bool
+	// PeerCertificates is the summary of certificate chain sent by the
+	// server, the leaf certificate is the first one.
+	PeerCertificates []CertificateInfo
+	// JA3S is the MD5 hash of JA3SString, which fingerprints the server
+	// (or the TLS-terminating middlebox in front of it).
+	JA3S string
+	// JA3SString is the JA3S string of the ServerHello, which is
+	// "SSLVersion,Cipher,Extensions", e.g. "771,4865,51-43".
+	JA3SString string
+	// JA4S is the JA4S fingerprint of the ServerHello, e.g.
+	// "t130200_1301_234ea6891581".
+	//
+	// JA3S and JA4S are empty if the ServerHello is not available, e.g. for
+	// HTTP3 or the connections created by the custom DialTLS.
+	JA4S string
+}
+
+// CertificateInfo is the summary of a certificate.
+type CertificateInfo struct {
+	Subject           string
+	Issuer            string
+	DNSNames          []string
+	SerialNumber      string
+	NotBefore         time.Time
+	NotAfter          time.Time
+	SHA256Fingerprint string
+}
+
+func newTLSInfo(state *tls.ConnectionState) *TLSInfo {
+	info := &TLSInfo{
+		Version:         state.Version,
+		VersionName:     tls.VersionName(state.Version),
+		CipherSuite:     state.CipherSuite,
+		CipherSuiteName: tls.CipherSuiteName(state.CipherSuite),
+		ALPN:            state.NegotiatedProtocol,
+		ServerName:      state.ServerName,
+		Resumed:         state.DidResume,
+		ECHAccepted:     state.ECHAccepted,
+	}
+	for _, cert := range state.PeerCertificates {
+		fingerprint := sha256.Sum256(cert.Raw)
+		info.PeerCertificates = append(info.PeerCertificates, CertificateInfo{
+			Subject:           cert.Subject.String(),
+			Issuer:            cert.Issuer.String(),
+			DNSNames:          cert.DNSNames,
+			SerialNumber:      cert.SerialNumber.String(),
+			NotBefore:         cert.NotBefore,
+			NotAfter:          cert.NotAfter,
+			SHA256Fingerprint: hex.EncodeToString(fingerprint[:]),
+		})
+	}
+	return info
+}
+
+// ja4sVersions is the version part of JA4S.
+var ja4sVersions = map[uint16]string{
+	tls.VersionTLS13: "13",
+	tls.VersionTLS12: "12",
+	tls.VersionTLS11: "11",
+	tls.VersionTLS10: "10",
+	0x0300:           "s3",
+}
+
+func (info *TLSInfo) setServerHello(hello *tlsutil.ServerHello) {
+	exts := make([]string, len(hello.Extensions))
+	for i, ext := range hello.Extensions {
+		exts[i] = strconv.Itoa(int(ext))
+	}
+	info.JA3SString = fmt.Sprintf("%d,%d,%s", hello.Version, hello.CipherSuite, strings.Join(exts, "-"))
+	sum := md5.Sum([]byte(info.JA3SString))
+	info.JA3S = hex.EncodeToString(sum[:])
+
+	version := hello.Version
+	if hello.SupportedVersion != 0 {
+		version = hello.SupportedVersion
+	}
+	versionStr, ok := ja4sVersions[version]
+	if !ok {
+		versionStr = "00"
+	}
+	alpn := "00"
+	if n := len(hello.ALPN); n > 0 {
+		alpn = hello.ALPN[:1] + hello.ALPN[n-1:]
+	}
+	info.JA4S = fmt.Sprintf("t%s%02d%s_%04x_%s", versionStr, min(len(hello.Extensions), 99), alpn,
+		hello.CipherSuite, ja4Hash(ja4HexList(hello.Extensions)))
+}
+
+// TLSInfo returns the negotiated TLS details of the response, nil if the
+// response is not received over TLS.
+func (r *Response) TLSInfo() *TLSInfo {
+	if r.Response == nil || r.TLS == nil {
+		return nil
+	}
+	info := newTLSInfo(r.TLS)
+	if r.serverHello != nil {
+		info.setServerHello(r.serverHello)
+	}
+	return info
+}
+
+// HTTPVersion returns the normalized HTTP version of the response, which
+// is one of "HTTP/1.0", "HTTP/1.1", "HTTP/2" and "HTTP/3", empty if the
+// response is not received.
+func (r *Response) HTTPVersion() string {
+	if r.Response == nil {
+		return ""
+	}
+	switch r.ProtoMajor {
+	case 1:
+		if r.ProtoMinor == 0 {
+			return "HTTP/1.0"
+		}
+		return "HTTP/1.1"
+	case 2:
+		return "HTTP/2"
+	case 3:
+		return "HTTP/3"
+	}
+	return r.Proto
+}
@@ -0,0 +1,42 @@
+package restys
+
+import (
+	"crypto/tls"
+	"strings"
+	"testing"
+
+	"github.com/luoxk/restys/internal/tests"
+)
+
+func TestResponseTLSInfo(t *testing.T) {
+	resp, err := tc().R().Get("/")
+	assertSuccess(t, resp, err)
+	info := resp.TLSInfo()
+	tests.AssertNotNil(t, info)
+	tests.AssertEqual(t, uint16(tls.VersionTLS13), info.Version)
+	tests.AssertEqual(t, "TLS 1.3", info.VersionName)
+	tests.AssertEqual(t, "h2", info.ALPN)
+	tests.AssertEqual(t, "HTTP/2", resp.HTTPVersion())
+	tests.AssertEqual(t, true, len(info.PeerCertificates) > 0)
+	tests.AssertEqual(t, 64, len(info.PeerCertificates[0].SHA256Fingerprint))
+	tests.AssertEqual(t, 32, len(info.JA3S))
+	tests.AssertEqual(t, "771,4865,43-51", info.JA3SString)
+	tests.AssertEqual(t, true, strings.HasPrefix(info.JA4S, "t13"))
+	// the ALPN is sent in EncryptedExtensions rather than ServerHello in
+	// TLS 1.3.
+	tests.AssertEqual(t, "t130200_1301_a56c5b993250", info.JA4S)
+
+	resp, err = tc().EnableForceHTTP1().R().Get("/")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "HTTP/1.1", resp.HTTPVersion())
+	tests.AssertNotNil(t, resp.TLSInfo())
+	tests.AssertEqual(t, info.JA4S, resp.TLSInfo().JA4S)
+
+	resp, err = tc().SetTLSFingerprintChrome().R().Get("/")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, true, strings.HasPrefix(resp.TLSInfo().JA4S, "t13"))
+
+	resp = &Response{}
+	tests.AssertIsNil(t, resp.TLSInfo())
+	tests.AssertEqual(t, "", resp.HTTPVersion())
+}
@@ -0,0 +1,85 @@
+package restys
+
+import (
+	"os"
+	"sync"
+)
+
+// keyLogFile is the KeyLogWriter which appends the key log lines to a file,
+// it's safe for concurrent use by the connections, and the lines written
+// after it's closed are discarded instead of failing the handshakes in
+// progress.
+type keyLogFile struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+func (w *keyLogFile) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return len(p), nil
+	}
+	return w.file.Write(p)
+}
+
+func (w *keyLogFile) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+// EnableTLSKeyLog appends the tls session keys of all connections to the
+// file in the NSS key log format, which can be used by Wireshark to decrypt
+// the traffic of HTTP1, HTTP2 and HTTP3, including the tls fingerprint
+// (e.g. SetTLSFingerprint). The SSLKEYLOGFILE environment variable is used
+// if path is empty. The file is closed by DisableTLSKeyLog or Close, and
+// the error is logged if it can't be opened.
+//
+// It should only be used for debugging, as anyone who can read the file can
+// decrypt the traffic.
+func (c *Client) EnableTLSKeyLog(path string) *Client {
+	if path == "" {
+		path = os.Getenv("SSLKEYLOGFILE")
+	}
+	if path == "" {
+		c.log.Errorf("failed to enable tls key log: no path specified")
+		return c
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		c.log.Errorf("failed to open tls key log file: %v", err)
+		return c
+	}
+	if err = c.closeTLSKeyLog(); err != nil {
+		c.log.Errorf("failed to close tls key log file: %v", err)
+	}
+	c.GetTLSClientConfig().KeyLogWriter = &keyLogFile{file: file}
+	return c
+}
+
+// DisableTLSKeyLog stops writing the tls session keys and closes the file
+// opened by EnableTLSKeyLog.
+func (c *Client) DisableTLSKeyLog() *Client {
+	if err := c.closeTLSKeyLog(); err != nil {
+		c.log.Errorf("failed to close tls key log file: %v", err)
+	}
+	return c
+}
+
+func (c *Client) closeTLSKeyLog() error {
+	if c.TLSClientConfig == nil {
+		return nil
+	}
+	w, ok := c.TLSClientConfig.KeyLogWriter.(*keyLogFile)
+	if !ok {
+		return nil
+	}
+	c.TLSClientConfig.KeyLogWriter = nil
+	return w.Close()
+}
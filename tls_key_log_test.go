@@ -0,0 +1,48 @@
+package restys
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/luoxk/restys/internal/tests"
+)
+
+func TestEnableTLSKeyLog(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	h1 := httptest.NewTLSServer(handler)
+	defer h1.Close()
+	h2 := httptest.NewUnstartedServer(handler)
+	h2.EnableHTTP2 = true
+	h2.StartTLS()
+	defer h2.Close()
+
+	path := filepath.Join(t.TempDir(), "keylog.txt")
+	for _, c := range []*Client{
+		C().SetBaseURL(h1.URL),
+		C().SetBaseURL(h2.URL).EnableForceHTTP2(),
+		C().SetBaseURL(h2.URL).SetTLSFingerprintChrome(),
+	} {
+		c.EnableInsecureSkipVerify().EnableTLSKeyLog(path)
+		resp, err := c.R().Get("/")
+		assertSuccess(t, resp, err)
+		tests.AssertNoError(t, c.Close(context.Background()))
+		tests.AssertIsNil(t, c.TLSClientConfig.KeyLogWriter)
+	}
+
+	data, err := os.ReadFile(path)
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, 3, strings.Count(string(data), "CLIENT_TRAFFIC_SECRET_0 "))
+
+	t.Setenv("SSLKEYLOGFILE", filepath.Join(t.TempDir(), "env.txt"))
+	c := tc().EnableTLSKeyLog("")
+	tests.AssertNotNil(t, c.TLSClientConfig.KeyLogWriter)
+	c.DisableTLSKeyLog()
+	tests.AssertIsNil(t, c.TLSClientConfig.KeyLogWriter)
+}
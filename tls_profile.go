@@ -0,0 +1,152 @@
+package restys
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// TLSProfile is the client fingerprint profile captured by the fingerprint
+// services like tls.peet.ws and browserleaks, see ParseTLSProfileJSON and
+// Client.ApplyTLSProfile.
+type TLSProfile struct {
+	// Ja3 is the ja3 string, e.g. "771,4865-4866-...,0-23-...,29-23-24,0".
+	Ja3 string
+	// Ja4 is the JA4 string, which is used if Ja3 is empty.
+	Ja4 string
+	// Akamai is the akamai http2 fingerprint string, e.g.
+	// "1:65536;2:0;4:6291456;6:262144|15663105|0|m,a,s,p".
+	Akamai string
+	// UserAgent is the User-Agent sent by the captured client.
+	UserAgent string
+	// HeaderOrder is the order of the request headers (lowercase, not
+	// including the pseudo headers) sent by the captured client.
+	HeaderOrder []string
+}
+
+// peetProfile is the JSON output of tls.peet.ws (/api/all).
+type peetProfile struct {
+	UserAgent string `json:"user_agent"`
+	TLS       *struct {
+		Ja3 string `json:"ja3"`
+		Ja4 string `json:"ja4"`
+	} `json:"tls"`
+	HTTP1 *struct {
+		Headers []string `json:"headers"`
+	} `json:"http1"`
+	HTTP2 *struct {
+		AkamaiFingerprint string `json:"akamai_fingerprint"`
+		SentFrames        []struct {
+			FrameType string   `json:"frame_type"`
+			Headers   []string `json:"headers"`
+		} `json:"sent_frames"`
+	} `json:"http2"`
+}
+
+// browserleaksProfile is the JSON output of browserleaks (tls.browserleaks.com/json).
+type browserleaksProfile struct {
+	UserAgent  string `json:"user_agent"`
+	Ja3Text    string `json:"ja3_text"`
+	Ja4        string `json:"ja4"`
+	AkamaiText string `json:"akamai_text"`
+}
+
+// headerNames returns the names of the headers in the "name: value" lines,
+// the pseudo headers are skipped.
+func headerNames(lines []string) []string {
+	var names []string
+	for _, line := range lines {
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+		name, _, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		names = append(names, strings.ToLower(strings.TrimSpace(name)))
+	}
+	return names
+}
+
+// ParseTLSProfileJSON parses the JSON output of tls.peet.ws (/api/all) or
+// browserleaks (tls.browserleaks.com/json) into the TLSProfile, the ja3 (or
+// JA4) and akamai fingerprint strings are validated.
+func ParseTLSProfileJSON(data []byte) (*TLSProfile, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	profile := &TLSProfile{}
+	if _, ok := raw["tls"]; ok {
+		var p peetProfile
+		if err := json.Unmarshal(data, &p); err != nil {
+			return nil, err
+		}
+		profile.UserAgent = p.UserAgent
+		if p.TLS != nil {
+			profile.Ja3, profile.Ja4 = p.TLS.Ja3, p.TLS.Ja4
+		}
+		if p.HTTP2 != nil {
+			profile.Akamai = p.HTTP2.AkamaiFingerprint
+			for _, frame := range p.HTTP2.SentFrames {
+				if frame.FrameType == "HEADERS" {
+					profile.HeaderOrder = headerNames(frame.Headers)
+					break
+				}
+			}
+		} else if p.HTTP1 != nil {
+			profile.HeaderOrder = headerNames(p.HTTP1.Headers)
+		}
+	} else {
+		var p browserleaksProfile
+		if err := json.Unmarshal(data, &p); err != nil {
+			return nil, err
+		}
+		profile.UserAgent = p.UserAgent
+		profile.Ja3, profile.Ja4, profile.Akamai = p.Ja3Text, p.Ja4, p.AkamaiText
+	}
+
+	switch {
+	case profile.Ja3 != "":
+		if err := ValidateJa3(profile.Ja3); err != nil {
+			return nil, err
+		}
+	case profile.Ja4 != "":
+		if err := ValidateJa4(profile.Ja4); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("no tls fingerprint found in the profile")
+	}
+	if profile.Akamai != "" {
+		if err := ValidateAkamai(profile.Akamai); err != nil {
+			return nil, err
+		}
+	}
+	return profile, nil
+}
+
+// ApplyTLSProfile configures the tls fingerprint (ja3, or JA4 if ja3 is
+// empty), the http2 settings frame, connection flow and pseudo header order
+// (akamai), the header order and the User-Agent of the profile in one call,
+// the empty fields of the profile are skipped.
+func (c *Client) ApplyTLSProfile(profile *TLSProfile) *Client {
+	if profile == nil {
+		return c
+	}
+	if profile.Ja3 != "" {
+		c.SetJa3WithStr(profile.Ja3)
+	} else if profile.Ja4 != "" {
+		c.SetJa4WithStr(profile.Ja4)
+	}
+	if profile.Akamai != "" {
+		c.SetAkamaiWithStr(profile.Akamai)
+	}
+	if len(profile.HeaderOrder) > 0 {
+		c.SetCommonHeaderOrder(profile.HeaderOrder...)
+	}
+	if profile.UserAgent != "" {
+		c.SetUserAgent(profile.UserAgent)
+	}
+	return c
+}
@@ -0,0 +1,75 @@
+package restys
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/luoxk/restys/internal/tests"
+)
+
+const peetProfileJSON = `{
+  "http_version": "h2",
+  "user_agent": "Mozilla/5.0 Chrome/120.0.0.0",
+  "tls": {
+    "ja3": "771,4865-4866-4867-49195-49199,0-23-65281-10-11-35-16-5-13-18-51-45-43-27-17513,29-23-24,0",
+    "ja4": "t13d1516h2_8daaf6152771_02713d6af862"
+  },
+  "http2": {
+    "akamai_fingerprint": "1:65536;2:0;4:6291456;6:262144|15663105|0|m,a,s,p",
+    "sent_frames": [
+      {"frame_type": "SETTINGS", "length": 24},
+      {"frame_type": "WINDOW_UPDATE", "increment": 15663105},
+      {"frame_type": "HEADERS", "headers": [":method: GET", ":authority: tls.peet.ws", ":scheme: https", ":path: /api/all", "sec-ch-ua: \"Chromium\";v=\"120\"", "user-agent: Mozilla/5.0 Chrome/120.0.0.0", "accept: */*", "accept-language: en-US"]}
+    ]
+  }
+}`
+
+const browserleaksProfileJSON = `{
+  "user_agent": "Mozilla/5.0 Firefox/120.0",
+  "ja3_hash": "579ccef312d18482fc42e2b822ca2430",
+  "ja3_text": "771,4865-4867-4866-49195-49199,0-23-65281-10-11-16-5-34-51-43-13-45-28-65037,29-23-24-25,0",
+  "ja4": "t13d1715h2_5b57614c22b0_5c2c66f702b0",
+  "akamai_text": "1:65536;4:131072;5:16384|12517377|3:0:0:201,5:0:0:101|m,p,a,s"
+}`
+
+func TestParseTLSProfileJSON(t *testing.T) {
+	p, err := ParseTLSProfileJSON([]byte(peetProfileJSON))
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, "Mozilla/5.0 Chrome/120.0.0.0", p.UserAgent)
+	tests.AssertEqual(t, "t13d1516h2_8daaf6152771_02713d6af862", p.Ja4)
+	tests.AssertEqual(t, "1:65536;2:0;4:6291456;6:262144|15663105|0|m,a,s,p", p.Akamai)
+	tests.AssertEqual(t, []string{"sec-ch-ua", "user-agent", "accept", "accept-language"}, p.HeaderOrder)
+
+	p, err = ParseTLSProfileJSON([]byte(browserleaksProfileJSON))
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, "Mozilla/5.0 Firefox/120.0", p.UserAgent)
+	tests.AssertEqual(t, "1:65536;4:131072;5:16384|12517377|3:0:0:201,5:0:0:101|m,p,a,s", p.Akamai)
+	tests.AssertEqual(t, 0, len(p.HeaderOrder))
+
+	_, err = ParseTLSProfileJSON([]byte(`{"user_agent": "test"}`))
+	tests.AssertErrorContains(t, err, "no tls fingerprint")
+	_, err = ParseTLSProfileJSON([]byte(`{"ja3_text": "771,1"}`))
+	tests.AssertErrorContains(t, err, "ja3Str format error")
+	_, err = ParseTLSProfileJSON([]byte(`not json`))
+	tests.AssertNotNil(t, err)
+}
+
+func TestApplyTLSProfile(t *testing.T) {
+	p, err := ParseTLSProfileJSON([]byte(peetProfileJSON))
+	tests.AssertNoError(t, err)
+	c := tc()
+	var order []string
+	c.Transport.WrapRoundTripFunc(func(rt http.RoundTripper) HttpRoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			order = req.Header[HeaderOderKey]
+			return rt.RoundTrip(req)
+		}
+	})
+	c.ApplyTLSProfile(p)
+	tests.AssertNotNil(t, c.TLSHandshakeContext)
+	tests.AssertEqual(t, p.UserAgent, c.Headers.Get("User-Agent"))
+
+	resp, err := c.R().Get("/")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, p.HeaderOrder, order)
+}
@@ -14,15 +14,20 @@ const (
 DNSLookupTime     : %v
 TCPConnectTime    : %v
 TLSHandshakeTime  : %v
+IsTLSResumed      : %v
 FirstResponseTime : %v
 ResponseTime      : %v
+BodyReadTime      : %v
 IsConnReused:     : false
-RemoteAddr        : %v`
+RemoteAddr        : %v
+Protocol          : %v`
 	traceReusedFmt = `TotalTime         : %v
 FirstResponseTime : %v
 ResponseTime      : %v
+BodyReadTime      : %v
 IsConnReused:     : true
-RemoteAddr        : %v`
+RemoteAddr        : %v
+Protocol          : %v`
 )
 
 // Blame return the human-readable reason of why request is slowing.
@@ -57,9 +62,9 @@ func (t TraceInfo) String() string {
 		return "trace is not enabled"
 	}
 	if t.IsConnReused {
-		return fmt.Sprintf(traceReusedFmt, t.TotalTime, t.FirstResponseTime, t.ResponseTime, t.RemoteAddr)
+		return fmt.Sprintf(traceReusedFmt, t.TotalTime, t.FirstResponseTime, t.ResponseTime, t.BodyReadTime, t.RemoteAddr, t.Protocol)
 	}
-	return fmt.Sprintf(traceFmt, t.TotalTime, t.DNSLookupTime, t.TCPConnectTime, t.TLSHandshakeTime, t.FirstResponseTime, t.ResponseTime, t.RemoteAddr)
+	return fmt.Sprintf(traceFmt, t.TotalTime, t.DNSLookupTime, t.TCPConnectTime, t.TLSHandshakeTime, t.IsTLSResumed, t.FirstResponseTime, t.ResponseTime, t.BodyReadTime, t.RemoteAddr, t.Protocol)
 }
 
 // TraceInfo represents the trace information.
@@ -77,6 +82,9 @@ type TraceInfo struct {
 	// TLSHandshakeTime is a duration that TLS handshake took place.
 	TLSHandshakeTime time.Duration
 
+	// IsTLSResumed is whether the TLS handshake resumed a previous session.
+	IsTLSResumed bool
+
 	// FirstResponseTime is a duration that server took to respond first byte since
 	// connection ready (after tls handshake if it's tls and not a reused connection).
 	FirstResponseTime time.Duration
@@ -85,6 +93,15 @@ type TraceInfo struct {
 	// request completion.
 	ResponseTime time.Duration
 
+	// TimeToFirstByte is a duration since the request started (the same
+	// as TotalTime) to the first response byte from server, including DNS
+	// lookup, connect and TLS handshake.
+	TimeToFirstByte time.Duration
+
+	// BodyReadTime is a duration that took to read the response body, since
+	// the response header is received.
+	BodyReadTime time.Duration
+
 	// TotalTime is a duration that total request took end-to-end.
 	TotalTime time.Duration
 
@@ -102,9 +119,28 @@ type TraceInfo struct {
 
 	// RemoteAddr returns the remote network address.
 	RemoteAddr net.Addr
+
+	// ResolvedAddrs is the IP addresses of the DNS lookup, RemoteAddr
+	// is selected from them, it is empty if the connection is reused or
+	// the host is an IP address.
+	ResolvedAddrs []net.IPAddr
+
+	// Protocol is the negotiated protocol of the response, e.g. "HTTP/1.1"
+	// and "HTTP/2.0".
+	Protocol string
+
+	// Attempt is the retry attempt of the trace, zero means the first
+	// request (see Request.TraceInfos).
+	Attempt int
+
+	// RequestID is the request ID stamped by Client.EnableRequestID.
+	RequestID string
 }
 
 type clientTrace struct {
+	attempt              int
+	requestID            string
+	startTime            time.Time
 	getConn              time.Time
 	dnsStart             time.Time
 	dnsDone              time.Time
@@ -113,8 +149,12 @@ type clientTrace struct {
 	tlsHandshakeDone     time.Time
 	gotConn              time.Time
 	gotFirstResponseByte time.Time
+	gotHeader            time.Time
 	endTime              time.Time
+	tlsResumed           bool
+	proto                string
 	gotConnInfo          httptrace.GotConnInfo
+	resolvedAddrs        []net.IPAddr
 }
 
 func (t *clientTrace) createContext(ctx context.Context) context.Context {
@@ -124,8 +164,9 @@ func (t *clientTrace) createContext(ctx context.Context) context.Context {
 			DNSStart: func(_ httptrace.DNSStartInfo) {
 				t.dnsStart = time.Now()
 			},
-			DNSDone: func(_ httptrace.DNSDoneInfo) {
+			DNSDone: func(info httptrace.DNSDoneInfo) {
 				t.dnsDone = time.Now()
+				t.resolvedAddrs = info.Addrs
 			},
 			ConnectStart: func(_, _ string) {
 				if t.dnsDone.IsZero() {
@@ -151,9 +192,83 @@ func (t *clientTrace) createContext(ctx context.Context) context.Context {
 			TLSHandshakeStart: func() {
 				t.tlsHandshakeStart = time.Now()
 			},
-			TLSHandshakeDone: func(_ tls.ConnectionState, _ error) {
+			TLSHandshakeDone: func(state tls.ConnectionState, _ error) {
 				t.tlsHandshakeDone = time.Now()
+				t.tlsResumed = state.DidResume
 			},
 		},
 	)
 }
+
+// traceInfo calculates the trace information, returnTime is used as the
+// end time if the response is not completely received (e.g. timeout).
+func (t *clientTrace) traceInfo(returnTime time.Time) TraceInfo {
+	ti := TraceInfo{
+		IsConnReused:  t.gotConnInfo.Reused,
+		IsConnWasIdle: t.gotConnInfo.WasIdle,
+		ConnIdleTime:  t.gotConnInfo.IdleTime,
+		IsTLSResumed:  t.tlsResumed,
+		ResolvedAddrs: t.resolvedAddrs,
+		Protocol:      t.proto,
+		Attempt:       t.attempt,
+		RequestID:     t.requestID,
+	}
+
+	endTime := t.endTime
+	if endTime.IsZero() { // in case timeout
+		endTime = returnTime
+	}
+
+	if !t.tlsHandshakeStart.IsZero() {
+		if !t.tlsHandshakeDone.IsZero() {
+			ti.TLSHandshakeTime = t.tlsHandshakeDone.Sub(t.tlsHandshakeStart)
+		} else {
+			ti.TLSHandshakeTime = endTime.Sub(t.tlsHandshakeStart)
+		}
+	}
+
+	start := t.startTime
+	if t.gotConnInfo.Reused {
+		start = t.getConn
+	} else if !t.dnsStart.IsZero() {
+		start = t.dnsStart
+	}
+	ti.TotalTime = endTime.Sub(start)
+
+	dnsDone := t.dnsDone
+	if dnsDone.IsZero() {
+		dnsDone = endTime
+	}
+
+	if !t.dnsStart.IsZero() {
+		ti.DNSLookupTime = dnsDone.Sub(t.dnsStart)
+	}
+
+	// Only calculate on successful connections
+	if !t.connectDone.IsZero() {
+		ti.TCPConnectTime = t.connectDone.Sub(dnsDone)
+	}
+
+	// Only calculate on successful connections
+	if !t.gotConn.IsZero() {
+		ti.ConnectTime = t.gotConn.Sub(t.getConn)
+	}
+
+	// Only calculate on successful connections
+	if !t.gotFirstResponseByte.IsZero() {
+		ti.FirstResponseTime = t.gotFirstResponseByte.Sub(t.gotConn)
+		ti.ResponseTime = endTime.Sub(t.gotFirstResponseByte)
+		ti.TimeToFirstByte = t.gotFirstResponseByte.Sub(start)
+	}
+
+	if !t.gotHeader.IsZero() && !t.endTime.IsZero() {
+		ti.BodyReadTime = t.endTime.Sub(t.gotHeader)
+	}
+
+	// Capture remote address info when connection is non-nil
+	if t.gotConnInfo.Conn != nil {
+		ti.RemoteAddr = t.gotConnInfo.Conn.RemoteAddr()
+	}
+
+	return ti
+}
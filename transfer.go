@@ -100,7 +100,7 @@ func newTransferWriter(r *http.Request) (t *transferWriter, err error) {
 		t.FlushHeaders = true
 	}
 
-	atLeastHTTP11 = true // Transport requests are always 1.1 or 2.0
+	atLeastHTTP11 = !requestIsHTTP10(r) // Transport requests are 1.1 or 2.0 unless HTTP/1.0 is enabled
 
 	// Sanitize Body,ContentLength,TransferEncoding
 	if !atLeastHTTP11 || t.Body == nil {
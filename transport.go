@@ -23,18 +23,21 @@ import (
 	"net"
 	"net/http"
 	"net/http/httptrace"
+	"net/netip"
 	"net/textproto"
 	"net/url"
 	"runtime"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 	_ "unsafe"
 
 	"github.com/luoxk/restys/http2"
 	"github.com/luoxk/restys/internal/altsvcutil"
 	"github.com/luoxk/restys/internal/ascii"
+	"github.com/luoxk/restys/internal/charsets"
 	"github.com/luoxk/restys/internal/common"
 	"github.com/luoxk/restys/internal/compress"
 	"github.com/luoxk/restys/internal/dump"
@@ -47,8 +50,6 @@ import (
 	"github.com/luoxk/restys/internal/util"
 	"github.com/luoxk/restys/pkg/altsvc"
 	reqtls "github.com/luoxk/restys/pkg/tls"
-	htmlcharset "golang.org/x/net/html/charset"
-	"golang.org/x/text/encoding/ianaindex"
 
 	"golang.org/x/net/http/httpguts"
 )
@@ -129,6 +130,12 @@ type Transport struct {
 	// Force using specific http version
 	forceHttpVersion httpVersion
 
+	// fileRoot is the root directory of the file: URLs, empty means disabled.
+	fileRoot string
+
+	altProtoMu sync.Mutex
+	altProto   map[string]http.RoundTripper // registered by RegisterProtocol
+
 	transport.Options
 
 	t2 *h2internal.Transport // non-nil if http2 wired up
@@ -146,6 +153,23 @@ type Transport struct {
 	autoDecodeContentType func(contentType string) bool
 	wrappedRoundTrip      http.RoundTripper
 	httpRoundTripWrappers []HttpRoundTripWrapper
+
+	// disableAutoRedispatch, if true, prevents re-dispatching the requests
+	// failed on the cached HTTP/2 and HTTP/3 connections.
+	disableAutoRedispatch bool
+	onRedispatch          func(req *http.Request, err error)
+
+	// quicFingerprint is applied to the HTTP/3 round tripper.
+	quicFingerprint *QUICFingerprint
+
+	// ech is the Encrypted Client Hello handler, nil if disabled.
+	ech *echHandler
+
+	// proxyBypass is the hosts connected without the proxy, nil if none.
+	proxyBypass *proxyBypass
+
+	// http3Proxy is the CONNECT-UDP proxy of the HTTP/3 connections.
+	http3Proxy *url.URL
 }
 
 // NewTransport is an alias of T
@@ -163,6 +187,7 @@ func T() *Transport {
 			TLSHandshakeTimeout:   10 * time.Second,
 			ExpectContinueTimeout: 1 * time.Second,
 			TLSClientConfig:       &tls.Config{NextProtos: []string{"http/1.1", "h2"}},
+			ConnStats:             transport.NewConnStats(),
 		},
 	}
 	//t.t2 = &h2internal.Transport{Options: &t.Options}
@@ -236,6 +261,34 @@ func (t *Transport) EnableAutoDecode() *Transport {
 	return t
 }
 
+// DisableAutoRedispatch disable re-dispatching the requests failed on the
+// cached HTTP/2 and HTTP/3 connections (enabled by default), see
+// EnableAutoRedispatch.
+func (t *Transport) DisableAutoRedispatch() *Transport {
+	t.disableAutoRedispatch = true
+	return t
+}
+
+// EnableAutoRedispatch enable re-dispatching the requests failed on the
+// cached HTTP/2 and HTTP/3 connections on another connection (enabled by
+// default). The requests not processed by the server (e.g. above the last
+// stream ID of the GOAWAY, or refused) are always re-dispatched, and the
+// idempotent requests (see Transport) are also re-dispatched if the
+// connection is closed before the response headers are received. The
+// request body must be rewindable, and each request is re-dispatched at
+// most 3 times.
+func (t *Transport) EnableAutoRedispatch() *Transport {
+	t.disableAutoRedispatch = false
+	return t
+}
+
+// OnRedispatch set the hook which is called before re-dispatching the
+// request with the error it failed on, see EnableAutoRedispatch.
+func (t *Transport) OnRedispatch(fn func(req *http.Request, err error)) *Transport {
+	t.onRedispatch = fn
+	return t
+}
+
 // SetAutoDecodeContentTypeFunc set the function that determines whether the
 // specified `Content-Type` should be auto-detected and decode to utf-8.
 func (t *Transport) SetAutoDecodeContentTypeFunc(fn func(contentType string) bool) *Transport {
@@ -270,9 +323,33 @@ func (t *Transport) SetMaxIdleConns(max int) *Transport {
 	return t
 }
 
+// SetMaxIdleConnsPerHost set the MaxIdleConnsPerHost, which controls the
+// maximum idle (keep-alive) connections to keep per-host. Zero means
+// using the default value 2, negative means no idle connections are kept,
+// the HTTP/2 and HTTP/3 connections are closed once they're idle.
+func (t *Transport) SetMaxIdleConnsPerHost(max int) *Transport {
+	t.MaxIdleConnsPerHost = max
+	return t
+}
+
+// SetMaxConnAge set the MaxConnAge, which is the maximum amount of time a
+// connection may be reused since it's established, the connection is
+// closed once the in-flight requests are done, which forces periodic
+// reconnects to re-randomize the TLS fingerprint (e.g. GREASE) and
+// session tickets. It applies to HTTP/1.1, HTTP/2 and HTTP/3.
+//
+// Zero means no limit.
+func (t *Transport) SetMaxConnAge(age time.Duration) *Transport {
+	t.MaxConnAge = age
+	return t
+}
+
 // SetMaxConnsPerHost set the MaxConnsPerHost, optionally limits the
 // total number of connections per host, including connections in the
 // dialing, active, and idle states. On limit violation, dials will block.
+// The HTTP/2 requests wait for the streams of the existing connection
+// instead of dialing more connections once the server's max concurrent
+// streams is reached, and HTTP/3 always uses one connection per host.
 //
 // Zero means no limit.
 func (t *Transport) SetMaxConnsPerHost(max int) *Transport {
@@ -282,7 +359,7 @@ func (t *Transport) SetMaxConnsPerHost(max int) *Transport {
 
 // SetIdleConnTimeout set the IdleConnTimeout, which  is the maximum
 // amount of time an idle (keep-alive) connection will remain idle before
-// closing itself.
+// closing itself, it applies to HTTP/1.1, HTTP/2 and HTTP/3.
 //
 // Zero means no limit.
 func (t *Transport) SetIdleConnTimeout(timeout time.Duration) *Transport {
@@ -290,6 +367,12 @@ func (t *Transport) SetIdleConnTimeout(timeout time.Duration) *Transport {
 	return t
 }
 
+// SetMaxIdleConnTimeout is an alias of SetIdleConnTimeout, which names it
+// together with SetMaxConnsPerHost and SetMaxIdleConnsPerHost.
+func (t *Transport) SetMaxIdleConnTimeout(timeout time.Duration) *Transport {
+	return t.SetIdleConnTimeout(timeout)
+}
+
 // SetTLSHandshakeTimeout set the TLSHandshakeTimeout, which specifies the
 // maximum amount of time waiting to wait for a TLS handshake.
 //
@@ -444,6 +527,47 @@ func (t *Transport) SetHTTP2PriorityFrames(frames ...http2.PriorityFrame) *Trans
 	return t
 }
 
+// SetHTTP2HeaderTableSize set the SETTINGS_HEADER_TABLE_SIZE sent in the
+// http2 settings frame, which is the max size of the HPACK dynamic table the
+// server can use to encode the response headers, see
+// Client.SetHTTP2HeaderTableSize.
+func (t *Transport) SetHTTP2HeaderTableSize(size uint32) *Transport {
+	t.t2.HeaderTableSize = size
+	return t
+}
+
+// HTTP2ConnPoolStats is the metrics of the HTTP/2 connection pool, see
+// Transport.HTTP2ConnPoolStats.
+type HTTP2ConnPoolStats struct {
+	// Dials is the number of HTTP/2 connections dialed by the pool.
+	Dials uint64 `json:"dials"`
+	// CoalescedDials is the number of requests which waited for the
+	// in-flight dial to the same host instead of dialing a new connection.
+	CoalescedDials uint64 `json:"coalesced_dials"`
+	// ReservationMisses is the number of requests which found the pooled
+	// connections to the host but none of them could take a new stream,
+	// e.g. the max concurrent streams is reached.
+	ReservationMisses uint64 `json:"reservation_misses"`
+	// LockContentions is the number of times the pool lock was contended,
+	// the pool is sharded by host, so it's mostly the requests to the
+	// same host.
+	LockContentions uint64 `json:"lock_contentions"`
+}
+
+// HTTP2ConnPoolStats returns the metrics of the HTTP/2 connection pool.
+func (t *Transport) HTTP2ConnPoolStats() HTTP2ConnPoolStats {
+	if t.t2 == nil {
+		return HTTP2ConnPoolStats{}
+	}
+	s := t.t2.ConnPoolStats()
+	return HTTP2ConnPoolStats{
+		Dials:             s.Dials,
+		CoalescedDials:    s.CoalescedDials,
+		ReservationMisses: s.ReservationMisses,
+		LockContentions:   s.LockContentions,
+	}
+}
+
 // SetTLSClientConfig set the custom TLSClientConfig, which specifies the TLS configuration to
 // use with tls.Client.
 // If nil, the default configuration is used.
@@ -485,6 +609,228 @@ func (t *Transport) SetDial(fn func(ctx context.Context, network, addr string) (
 	return t
 }
 
+// SetDialTimeout set the maximum amount of time that dialing a TCP
+// connection can take, it keeps the default dial behavior, and does not
+// take effect if a custom dial function is set by SetDial.
+func (t *Transport) SetDialTimeout(d time.Duration) *Transport {
+	t.DialTimeout = d
+	return t
+}
+
+// SetTCPKeepAlive set the interval between TCP keep-alive probes, zero
+// means the default (15 seconds), negative disables TCP keep-alive.
+func (t *Transport) SetTCPKeepAlive(interval time.Duration) *Transport {
+	t.TCPKeepAlive = interval
+	return t
+}
+
+// SetTCPNoDelay set whether TCP_NODELAY is set on the TCP connections
+// (true by default), false enables Nagle's algorithm.
+func (t *Transport) SetTCPNoDelay(noDelay bool) *Transport {
+	t.DisableTCPNoDelay = !noDelay
+	return t
+}
+
+// EnableTCPFastOpen enables TCP Fast Open on connect where the platform
+// supports it (Linux 4.11+), which saves a round trip when reconnecting to
+// a server that supports it.
+func (t *Transport) EnableTCPFastOpen() *Transport {
+	t.Options.EnableTCPFastOpen = true
+	return t
+}
+
+// DisableTCPFastOpen disables TCP Fast Open (disabled by default).
+func (t *Transport) DisableTCPFastOpen() *Transport {
+	t.Options.EnableTCPFastOpen = false
+	return t
+}
+
+// EnableMultipathTCP uses Multipath TCP where the platform and the server
+// support it, otherwise it falls back to TCP.
+func (t *Transport) EnableMultipathTCP() *Transport {
+	t.Options.EnableMultipathTCP = true
+	return t
+}
+
+// DisableMultipathTCP disables Multipath TCP (disabled by default).
+func (t *Transport) DisableMultipathTCP() *Transport {
+	t.Options.EnableMultipathTCP = false
+	return t
+}
+
+// SetLocalAddr set the local IP address of the TCP and UDP (HTTP3) sockets,
+// only the remote addresses of the same family are dialed, the zero
+// netip.Addr clears it. It does not take effect if a custom dial function
+// is set by SetDial, and should be set before sending HTTP3 requests.
+func (t *Transport) SetLocalAddr(ip netip.Addr) *Transport {
+	t.Options.LocalIP = ip
+	return t
+}
+
+// SetInterface set the name of the network interface which the TCP and UDP
+// (HTTP3) sockets are bound to, the empty name clears it. It's supported on
+// Linux (SO_BINDTODEVICE) and darwin (IP_BOUND_IF), dialing fails on the
+// other platforms. It does not take effect if a custom dial function is set
+// by SetDial, and should be set before sending HTTP3 requests.
+func (t *Transport) SetInterface(name string) *Transport {
+	t.Options.Interface = name
+	return t
+}
+
+// SetHosts set the static host mapping from hostname (or "host:port") to
+// IP address, like `curl --resolve`, the connections to the host are dialed
+// to the IP address while TLS SNI and `Host` header still use the hostname.
+// It does not take effect if a custom dial function is set by SetDial.
+// Entries with invalid IP address are ignored, which are logged by the
+// debug function (see SetDebug).
+func (t *Transport) SetHosts(hosts map[string]string) *Transport {
+	m, err := parseHosts(hosts)
+	if err != nil && t.Debugf != nil {
+		t.Debugf("%s", err.Error())
+	}
+	t.Hosts = m
+	return t
+}
+
+// AddrSelectorFunc reorders or filters the resolved IP addresses of host,
+// the returned addresses are tried in order when dialing. addrs is a copy
+// which can be modified in place (e.g. sorted).
+type AddrSelectorFunc func(host string, addrs []netip.Addr) []netip.Addr
+
+// SetAddrSelector set the function to reorder or filter the resolved IP
+// addresses of host before dialing, e.g. to prefer a specific CDN POP.
+// It does not take effect if a custom dial function is set by SetDial.
+func (t *Transport) SetAddrSelector(fn AddrSelectorFunc) *Transport {
+	t.SelectAddrs = fn
+	return t
+}
+
+func parseHosts(hosts map[string]string) (map[string]netip.Addr, error) {
+	var errs []error
+	m := make(map[string]netip.Addr, len(hosts))
+	for host, ip := range hosts {
+		addr, err := netip.ParseAddr(strings.Trim(ip, "[]"))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid ip address %q of host %s", ip, host))
+			continue
+		}
+		if h, port, err := net.SplitHostPort(host); err == nil {
+			host = net.JoinHostPort(strings.ToLower(h), port)
+		} else {
+			host = strings.ToLower(host)
+		}
+		m[host] = addr
+	}
+	return m, errors.Join(errs...)
+}
+
+// SetDNSResolver set the DNS resolver used to look up host names when
+// dialing (HTTP1, HTTP2 and HTTP3), instead of the system resolver. It
+// does not take effect if a custom dial function is set by SetDial.
+func (t *Transport) SetDNSResolver(resolver *net.Resolver) *Transport {
+	t.Resolver = resolver
+	return t
+}
+
+// SetDNSServers set the DNS servers (e.g. "1.1.1.1:53", "8.8.8.8", port
+// 53 is used if omitted) used to look up host names when dialing, which
+// bypasses the system resolver and /etc/resolv.conf. The servers are used
+// in order, the next one is used once a query to the current one fails or
+// times out, the number of tries and the timeout of a query follow
+// /etc/resolv.conf (2 tries of 5 seconds per name server by default).
+func (t *Transport) SetDNSServers(servers ...string) *Transport {
+	if len(servers) == 0 {
+		t.Resolver = nil
+		return t
+	}
+	s := &dnsServers{addrs: make([]string, len(servers))}
+	for i, server := range servers {
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			server = net.JoinHostPort(strings.Trim(server, "[]"), "53")
+		}
+		s.addrs[i] = server
+	}
+	t.Resolver = &net.Resolver{
+		PreferGo: true,
+		Dial:     s.dial,
+	}
+	return t
+}
+
+// dnsServers is the DNS servers of SetDNSServers, which switches to the
+// next server once the current one fails.
+type dnsServers struct {
+	addrs []string
+	cur   atomic.Uint32
+}
+
+// dial connects to the current server, or the following ones if it can't
+// be connected. The resolver dials again to retry a failed query, so the
+// current server is advanced once a read or write of the conn fails.
+func (s *dnsServers) dial(ctx context.Context, network, _ string) (conn net.Conn, err error) {
+	var d net.Dialer
+	cur := s.cur.Load()
+	for i := range uint32(len(s.addrs)) {
+		n := cur + i
+		if conn, err = d.DialContext(ctx, network, s.addrs[n%uint32(len(s.addrs))]); err != nil {
+			continue
+		}
+		s.cur.CompareAndSwap(cur, n)
+		dc := &dnsConn{Conn: conn, fail: func() { s.cur.CompareAndSwap(n, n+1) }}
+		if pc, ok := conn.(net.PacketConn); ok {
+			// the resolver sends the query over UDP only if the conn is a
+			// net.PacketConn.
+			return &dnsPacketConn{dnsConn: dc, pc: pc}, nil
+		}
+		return dc, nil
+	}
+	s.cur.CompareAndSwap(cur, cur+1)
+	return
+}
+
+// dnsConn calls fail once a read or write fails (e.g. the query timed out).
+type dnsConn struct {
+	net.Conn
+	fail func()
+}
+
+func (c *dnsConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if err != nil {
+		c.fail()
+	}
+	return n, err
+}
+
+func (c *dnsConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	if err != nil {
+		c.fail()
+	}
+	return n, err
+}
+
+type dnsPacketConn struct {
+	*dnsConn
+	pc net.PacketConn
+}
+
+func (c *dnsPacketConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	n, addr, err := c.pc.ReadFrom(b)
+	if err != nil {
+		c.fail()
+	}
+	return n, addr, err
+}
+
+func (c *dnsPacketConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	n, err := c.pc.WriteTo(b, addr)
+	if err != nil {
+		c.fail()
+	}
+	return n, err
+}
+
 // SetDialTLS set the custom DialTLSContext function, only valid for HTTP1 and HTTP2, which specifies
 // an optional dial function for creating TLS connections for non-proxied HTTPS requests (proxy will
 // not work if set).
@@ -514,6 +860,38 @@ type pendingAltSvc struct {
 	Transport    http.RoundTripper
 }
 
+// RegisterProtocol registers a new protocol with scheme, the Transport
+// will pass requests using the given scheme to rt, e.g. the "ftp" and
+// "sftp" protocols provided by the ftp package. It is rt's responsibility
+// to simulate HTTP request semantics.
+func (t *Transport) RegisterProtocol(scheme string, rt http.RoundTripper) *Transport {
+	scheme = strings.ToLower(scheme)
+	if scheme == "http" || scheme == "https" {
+		panic("restys: protocol " + scheme + " can not be registered")
+	}
+	t.altProtoMu.Lock()
+	defer t.altProtoMu.Unlock()
+	protos := cloneMap(t.altProto)
+	if protos == nil {
+		protos = make(map[string]http.RoundTripper)
+	}
+	protos[scheme] = rt
+	t.altProto = protos
+	return t
+}
+
+func (t *Transport) getAltProtos() map[string]http.RoundTripper {
+	t.altProtoMu.Lock()
+	defer t.altProtoMu.Unlock()
+	return cloneMap(t.altProto)
+}
+
+func (t *Transport) getAltProto(scheme string) http.RoundTripper {
+	t.altProtoMu.Lock()
+	defer t.altProtoMu.Unlock()
+	return t.altProto[strings.ToLower(scheme)]
+}
+
 // EnableForceHTTP1 enable force using HTTP1 (disabled by default).
 func (t *Transport) EnableForceHTTP1() *Transport {
 	t.forceHttpVersion = h1
@@ -606,6 +984,9 @@ func (t *Transport) EnableHTTP3() {
 		Options: &t.Options,
 	}
 	t.t3 = t3
+	t.applyQUICFingerprint()
+	t.t3.Proxy = t.http3Proxy
+	t.applyHTTP3ECH()
 }
 
 type wrapResponseBodyKeyType int
@@ -614,11 +995,26 @@ const wrapResponseBodyKey wrapResponseBodyKeyType = iota
 
 type wrapResponseBodyFunc func(rc io.ReadCloser) io.ReadCloser
 
+type responseCharsetKeyType int
+
+const responseCharsetKey responseCharsetKeyType = iota
+
+type rawResponseBodyKeyType int
+
+// rawResponseBodyKey skips decoding the response body, e.g. Request.Stream.
+const rawResponseBodyKey rawResponseBodyKeyType = iota
+
 func (t *Transport) handleResponseBody(res *http.Response, req *http.Request) {
 	if wrap, ok := req.Context().Value(wrapResponseBodyKey).(wrapResponseBodyFunc); ok {
 		t.wrapResponseBody(res, wrap)
 	}
-	t.autoDecodeResponseBody(res)
+	if raw, _ := req.Context().Value(rawResponseBodyKey).(bool); raw {
+		// keep the body as is.
+	} else if charset, ok := req.Context().Value(responseCharsetKey).(string); ok {
+		t.decodeResponseBody(res, charset)
+	} else {
+		t.autoDecodeResponseBody(res)
+	}
 	dump.WrapResponseBodyIfNeeded(res, req, t.Dump)
 }
 
@@ -719,15 +1115,12 @@ func (t *Transport) autoDecodeResponseBody(res *http.Response) {
 		if strings.Contains(charset, "utf-8") || strings.Contains(charset, "utf8") { // do not decode utf-8
 			return
 		}
-		enc, _ := htmlcharset.Lookup(charset)
+		enc, _ := charsets.Lookup(charset)
 		if enc == nil {
-			enc, err = ianaindex.MIME.Encoding(charset)
-			if err != nil || enc == nil {
-				if t.Debugf != nil {
-					t.Debugf("ignore charset %s which is detected in Content-Type but not supported", charset)
-				}
-				return
+			if t.Debugf != nil {
+				t.Debugf("ignore charset %s which is detected in Content-Type but not supported", charset)
 			}
+			return
 		}
 		if t.Debugf != nil {
 			t.Debugf("charset %s detected in Content-Type, auto-decode to utf-8", charset)
@@ -739,6 +1132,20 @@ func (t *Transport) autoDecodeResponseBody(res *http.Response) {
 	res.Body = newAutoDecodeReadCloser(res.Body, t)
 }
 
+// decodeResponseBody decodes the response body from the specified charset
+// to utf-8, regardless of the Content-Type.
+func (t *Transport) decodeResponseBody(res *http.Response, charset string) {
+	enc, name := charsets.Lookup(charset)
+	if enc == nil || name == "utf-8" {
+		return
+	}
+	if t.Debugf != nil {
+		t.Debugf("charset %s is specified, decode to utf-8", name)
+	}
+	decodeReader := enc.NewDecoder().Reader(res.Body)
+	res.Body = &decodeReaderCloser{res.Body, decodeReader}
+}
+
 func (t *Transport) writeBufferSize() int {
 	if t.WriteBufferSize > 0 {
 		return t.WriteBufferSize
@@ -762,7 +1169,15 @@ func (t *Transport) Clone() *Transport {
 		disableAutoDecode:     t.disableAutoDecode,
 		autoDecodeContentType: t.autoDecodeContentType,
 		forceHttpVersion:      t.forceHttpVersion,
+		fileRoot:              t.fileRoot,
+		altProto:              t.getAltProtos(),
 		httpRoundTripWrappers: t.httpRoundTripWrappers,
+		disableAutoRedispatch: t.disableAutoRedispatch,
+		onRedispatch:          t.onRedispatch,
+		quicFingerprint:       t.quicFingerprint,
+		ech:                   t.ech,
+		proxyBypass:           t.proxyBypass,
+		http3Proxy:            t.http3Proxy,
 	}
 	if len(tt.httpRoundTripWrappers) > 0 { // clone transport middleware
 		fn := func(req *http.Request) (*http.Response, error) {
@@ -798,6 +1213,7 @@ func (t *Transport) Clone() *Transport {
 			Settings:                   cloneSlice(t.t2.Settings),
 			HeaderPriority:             t.t2.HeaderPriority,
 			PriorityFrames:             cloneSlice(t.t2.PriorityFrames),
+			HeaderTableSize:            t.t2.HeaderTableSize,
 		}
 	}
 	if t.t3 != nil {
@@ -903,6 +1319,53 @@ func (t *Transport) checkAltSvc(req *http.Request) (resp *http.Response, err err
 	return
 }
 
+const maxRedispatch = 3
+
+// roundTripCachedConn sends req with the cached connection round tripper rt,
+// and re-dispatches the request if it fails on the connection (see
+// Transport.EnableAutoRedispatch). done is false if there's no cached
+// connection, and req is rewound to be sent on a new connection.
+func (t *Transport) roundTripCachedConn(req *http.Request, rt func(*http.Request) (*http.Response, error), errNoCachedConn error) (_ *http.Request, resp *http.Response, done bool, err error) {
+	for i := 0; ; i++ {
+		resp, err = rt(req)
+		if err == nil {
+			return req, resp, true, nil
+		}
+		if err != errNoCachedConn && (i >= maxRedispatch || !t.shouldRedispatch(req, err)) {
+			return req, nil, true, err
+		}
+		roundTripErr := err
+		newReq, err := rewindBody(req)
+		if err != nil {
+			if roundTripErr == errNoCachedConn {
+				return req, nil, true, err
+			}
+			// the body can't be sent again.
+			return req, nil, true, roundTripErr
+		}
+		req = newReq
+		if roundTripErr == errNoCachedConn {
+			return req, nil, false, nil
+		}
+		if t.Debugf != nil {
+			t.Debugf("re-dispatch %s %s after connection failure: %v", req.Method, req.URL.String(), roundTripErr)
+		}
+		if t.onRedispatch != nil {
+			t.onRedispatch(req, roundTripErr)
+		}
+	}
+}
+
+func (t *Transport) shouldRedispatch(req *http.Request, err error) bool {
+	if t.disableAutoRedispatch || req.Context().Err() != nil {
+		return false
+	}
+	if h2internal.IsUnprocessedError(err) || http3.IsUnprocessedError(err) {
+		return true
+	}
+	return isReplayable(req) && (h2internal.IsConnClosedError(err) || http3.IsConnClosedError(err))
+}
+
 func validateHeaders(hdrs http.Header) string {
 	for k, vv := range hdrs {
 		if !httpguts.ValidHeaderFieldName(k) {
@@ -929,9 +1392,20 @@ func (t *Transport) roundTrip(req *http.Request) (resp *http.Response, err error
 		return nil, errors.New("http: nil Request.URL")
 	}
 
-	resp, err = t.checkAltSvc(req)
-	if err != nil || resp != nil {
-		return
+	if resp, ok, err := t.roundTripLocal(req); ok {
+		return resp, err
+	}
+	if rt := t.getAltProto(req.URL.Scheme); rt != nil {
+		return rt.RoundTrip(req)
+	}
+
+	// HTTP/1.0 requests are always sent over HTTP/1.
+	http10 := requestIsHTTP10(req)
+	if !http10 {
+		resp, err = t.checkAltSvc(req)
+		if err != nil || resp != nil {
+			return
+		}
 	}
 
 	scheme := req.URL.Scheme
@@ -955,7 +1429,7 @@ func (t *Transport) roundTrip(req *http.Request) (resp *http.Response, err error
 		req.Header = make(http.Header)
 	}
 
-	if t.forceHttpVersion != "" {
+	if t.forceHttpVersion != "" && !http10 {
 		switch t.forceHttpVersion {
 		case h3:
 			return t.t3.RoundTrip(req)
@@ -967,24 +1441,34 @@ func (t *Transport) roundTrip(req *http.Request) (resp *http.Response, err error
 	origReq := req
 	req = setupRewindBody(req)
 
-	if scheme == "https" && t.forceHttpVersion != h1 {
-		resp, err := t.t2.RoundTripOnlyCachedConn(req)
-		if err != h2internal.ErrNoCachedConn {
-			return resp, err
-		}
-		req, err = rewindBody(req)
-		if err != nil {
+	// the proxy is selected once for the request, so the cached HTTP/2
+	// connection and the dialed one are through the same proxy.
+	var proxyURL *url.URL
+	if isHTTP {
+		if proxyURL, err = t.proxyForRequest(req); err != nil {
+			closeBody(req)
 			return nil, err
 		}
+	}
+
+	if scheme == "https" && t.forceHttpVersion != h1 && !http10 {
+		var done bool
+		rt := t.t2.RoundTripOnlyCachedConn
+		if proxyURL != nil {
+			proxy := proxyURL.String()
+			rt = func(req *http.Request) (*http.Response, error) {
+				return t.t2.RoundTripOnlyCachedConn(req.WithContext(netutil.WithProxy(req.Context(), proxy)))
+			}
+		}
+		req, resp, done, err = t.roundTripCachedConn(req, rt, h2internal.ErrNoCachedConn)
+		if done {
+			return resp, err
+		}
 		if t.t3 != nil {
-			resp, err = t.t3.RoundTripOnlyCachedConn(req)
-			if err != http3.ErrNoCachedConn {
+			req, resp, done, err = t.roundTripCachedConn(req, t.t3.RoundTripOnlyCachedConn, http3.ErrNoCachedConn)
+			if done {
 				return resp, err
 			}
-			req, err = rewindBody(req)
-			if err != nil {
-				return nil, err
-			}
 		}
 	}
 
@@ -1039,11 +1523,7 @@ func (t *Transport) roundTrip(req *http.Request) (resp *http.Response, err error
 
 		// treq gets modified by roundTrip, so we need to recreate for each retry.
 		treq := &transportRequest{Request: req, trace: trace, ctx: ctx, cancel: cancel}
-		cm, err := t.connectMethodForRequest(treq)
-		if err != nil {
-			closeBody(req)
-			return nil, err
-		}
+		cm := t.connectMethodForRequest(treq, proxyURL)
 
 		// Get the cached or newly-created connection to either the
 		// host (for http or https), the http proxy, or the http proxy
@@ -1057,8 +1537,12 @@ func (t *Transport) roundTrip(req *http.Request) (resp *http.Response, err error
 
 		var resp *http.Response
 		if t.forceHttpVersion != h1 && pconn.alt != nil {
-			// HTTP/2 path.
-			resp, err = pconn.alt.RoundTrip(req)
+			// HTTP/2 path, the connection is cached by the proxy.
+			altReq := req
+			if proxy := cm.proxyKey(); proxy != "" {
+				altReq = req.WithContext(netutil.WithProxy(req.Context(), proxy))
+			}
+			resp, err = pconn.alt.RoundTrip(altReq)
 		} else {
 			resp, err = pconn.roundTrip(treq)
 		}
@@ -1291,14 +1775,28 @@ func (t *Transport) CancelRequest(req *http.Request) {
 func resetProxyConfig() {
 }
 
-func (t *Transport) connectMethodForRequest(treq *transportRequest) (cm connectMethod, err error) {
+// proxyForRequest returns the proxy of req, which is the one of WithProxy,
+// or the one selected by t.Proxy unless the host bypasses the proxy.
+func (t *Transport) proxyForRequest(req *http.Request) (*url.URL, error) {
+	if o := getContextOptions(req.Context()); o != nil && o.proxyURL != nil {
+		return o.proxyURL, nil
+	}
+	if t.Proxy != nil && !t.proxyBypass.match(req.URL) {
+		return t.Proxy(req)
+	}
+	return nil, nil
+}
+
+func (t *Transport) connectMethodForRequest(treq *transportRequest, proxyURL *url.URL) (cm connectMethod) {
 	cm.targetScheme = treq.URL.Scheme
 	cm.targetAddr = canonicalAddr(treq.URL)
-	if t.Proxy != nil {
-		cm.proxyURL, err = t.Proxy(treq.Request)
+	cm.proxyURL = proxyURL
+	if cm.targetScheme == "https" {
+		cm.sni = netutil.ServerName(treq.Context())
 	}
+	cm.h2Settings = h2internal.EncodeSettings(h2internal.SettingsFromContext(treq.Context()))
 	cm.onlyH1 = t.forceHttpVersion == h1 || requestRequiresHTTP1(treq.Request)
-	return cm, err
+	return cm
 }
 
 // proxyAuth returns the Proxy-Authorization header to set
@@ -1322,6 +1820,7 @@ var (
 	errCloseIdle          = errors.New("http: putIdleConn: CloseIdleConnections was called")
 	errTooManyIdle        = errors.New("http: putIdleConn: too many idle connections")
 	errTooManyIdleHost    = errors.New("http: putIdleConn: too many idle connections for host")
+	errConnTooOld         = errors.New("http: putIdleConn: connection exceeded max age")
 	errCloseIdleConns     = errors.New("http: CloseIdleConnections called")
 	errReadLoopExiting    = errors.New("http: persistConn.readLoop exiting")
 	errIdleConnTimeout    = errors.New("http: idle connection timeout")
@@ -1376,6 +1875,9 @@ func (t *Transport) tryPutIdleConn(pconn *persistConn) error {
 	if pconn.isBroken() {
 		return errConnBroken
 	}
+	if pconn.alt == nil && t.connTooOld(pconn) {
+		return errConnTooOld
+	}
 	pconn.markReused()
 
 	t.idleMu.Lock()
@@ -1500,7 +2002,8 @@ func (t *Transport) queueForIdleConn(w *wantConn) (delivered bool) {
 			// See whether this connection has been idle too long, considering
 			// only the wall time (the Round(0)), in case this is a laptop or VM
 			// coming out of suspend with previously cached idle connections.
-			tooOld := !oldTime.IsZero() && pconn.idleAt.Round(0).Before(oldTime)
+			tooOld := !oldTime.IsZero() && pconn.idleAt.Round(0).Before(oldTime) ||
+				pconn.alt == nil && t.connTooOld(pconn)
 			if tooOld {
 				// Async cleanup. Launch in its own goroutine (as if a
 				// time.AfterFunc called it); it acquires idleMu, which we're
@@ -1551,6 +2054,12 @@ func (t *Transport) queueForIdleConn(w *wantConn) (delivered bool) {
 	return false
 }
 
+// connTooOld reports whether the HTTP/1 connection has exceeded the
+// MaxConnAge, the HTTP/2 connections are managed by the HTTP/2 transport.
+func (t *Transport) connTooOld(pconn *persistConn) bool {
+	return t.MaxConnAge > 0 && time.Since(pconn.createdAt) > t.MaxConnAge
+}
+
 // removeIdleConn marks pconn as dead.
 func (t *Transport) removeIdleConn(pconn *persistConn) bool {
 	t.idleMu.Lock()
@@ -1591,8 +2100,6 @@ func (t *Transport) removeIdleConnLocked(pconn *persistConn) bool {
 	return removed
 }
 
-var zeroDialer net.Dialer
-
 func (t *Transport) dial(ctx context.Context, network, addr string) (net.Conn, error) {
 	if t.DialContext != nil {
 		c, err := t.DialContext(ctx, network, addr)
@@ -1601,7 +2108,7 @@ func (t *Transport) dial(ctx context.Context, network, addr string) (net.Conn, e
 		}
 		return c, err
 	}
-	return zeroDialer.DialContext(ctx, network, addr)
+	return t.DialDefault(ctx, network, addr)
 }
 
 // A wantConn records state about a wanted connection
@@ -1997,8 +2504,11 @@ func (pc *persistConn) addTLS(ctx context.Context, name string, trace *httptrace
 	if pc.cacheKey.onlyH1 {
 		cfg.NextProtos = nil
 	}
+	if pc.t.ech != nil && !forProxy {
+		pc.t.applyECH(ctx, cfg, name)
+	}
 	plainConn := pc.conn
-	tlsConn := tls.Client(plainConn, cfg)
+	tlsConn := tls.Client(pc.t.NewTLSRecorder(plainConn, cfg.ServerName), cfg)
 	errc := make(chan error, 2)
 	var timer *time.Timer // for canceling TLS handshake
 	if d := pc.t.TLSHandshakeTimeout; d != 0 {
@@ -2023,6 +2533,9 @@ func (pc *persistConn) addTLS(ctx context.Context, name string, trace *httptrace
 			// wait for the call to HandshakeContext to return.
 			<-errc
 		}
+		if cfg.EncryptedClientHelloConfigList != nil {
+			pc.t.handleECHRejection(name, err)
+		}
 		if trace != nil && trace.TLSHandshakeDone != nil {
 			trace.TLSHandshakeDone(tls.ConnectionState{}, err)
 		}
@@ -2032,6 +2545,10 @@ func (pc *persistConn) addTLS(ctx context.Context, name string, trace *httptrace
 	if trace != nil && trace.TLSHandshakeDone != nil {
 		trace.TLSHandshakeDone(cs, nil)
 	}
+	if err := pc.t.CheckConnection(cfg.ServerName, &cs); err != nil {
+		tlsConn.Close()
+		return err
+	}
 	pc.tlsState = &cs
 	pc.conn = tlsConn
 	if !forProxy && pc.t.forceHttpVersion == h2 && cs.NegotiatedProtocol != h2internal.NextProtoTLS {
@@ -2060,7 +2577,7 @@ func (t *Transport) customTlsHandshake(ctx context.Context, trace *httptrace.Cli
 		if trace != nil && trace.TLSHandshakeStart != nil {
 			trace.TLSHandshakeStart()
 		}
-		conn, tlsState, err := t.TLSHandshakeContext(ctx, addr, pconn.conn)
+		conn, tlsState, err := t.TLSHandshakeContext(ctx, addr, t.NewTLSRecorder(pconn.conn, addr))
 		if err != nil {
 			if timer != nil {
 				timer.Stop()
@@ -2081,12 +2598,21 @@ func (t *Transport) customTlsHandshake(ctx context.Context, trace *httptrace.Cli
 		pconn.conn.Close()
 		return err
 	}
+	if err := t.CheckConnection(addr, pconn.tlsState); err != nil {
+		pconn.conn.Close()
+		return err
+	}
 	return nil
 }
 
 var testHookProxyConnectTimeout = context.WithTimeout
 
 func (t *Transport) dialConn(ctx context.Context, cm connectMethod) (pconn *persistConn, err error) {
+	defer func() {
+		if err == nil {
+			t.ConnStats.Dialed(cm.targetAddr)
+		}
+	}()
 	pconn = &persistConn{
 		t:             t,
 		cacheKey:      cm.key(),
@@ -2095,6 +2621,7 @@ func (t *Transport) dialConn(ctx context.Context, cm connectMethod) (pconn *pers
 		closech:       make(chan struct{}),
 		writeErrCh:    make(chan error, 1),
 		writeLoopDone: make(chan struct{}),
+		createdAt:     time.Now(),
 	}
 	trace := httptrace.ContextClientTrace(ctx)
 	wrapErr := func(err error) error {
@@ -2143,6 +2670,9 @@ func (t *Transport) dialConn(ctx context.Context, cm connectMethod) (pconn *pers
 			if firstTLSHost, _, err = net.SplitHostPort(cm.addr()); err != nil {
 				return nil, wrapErr(err)
 			}
+			if cm.proxyURL == nil && cm.sni != "" {
+				firstTLSHost = cm.sni
+			}
 			if t.TLSHandshakeContext != nil && cm.proxyURL == nil {
 				err = t.customTlsHandshake(ctx, trace, firstTLSHost, pconn)
 				if err != nil {
@@ -2288,7 +2818,8 @@ func (t *Transport) dialConn(ctx context.Context, cm connectMethod) (pconn *pers
 
 	if s := pconn.tlsState; t.forceHttpVersion != h1 && s != nil && s.NegotiatedProtocolIsMutual && s.NegotiatedProtocol != "" {
 		if s.NegotiatedProtocol == h2internal.NextProtoTLS {
-			if used, err := t.t2.AddConn(pconn.conn, cm.targetAddr); err != nil {
+			key := netutil.ProxyConnKey(netutil.ConnKey(cm.targetAddr, cm.sni), cm.proxyKey())
+			if used, err := t.t2.AddConn(pconn.conn, h2internal.ConnKey(key, cm.h2Settings)); err != nil {
 				go pconn.conn.Close()
 				return nil, err
 			} else if !used {
@@ -2357,7 +2888,9 @@ type connectMethod struct {
 	// then targetAddr is not included in the connect method key, because the socket can
 	// be reused for different targetAddr values.
 	targetAddr string
-	onlyH1     bool // whether to disable HTTP/2 and force HTTP/1
+	onlyH1     bool   // whether to disable HTTP/2 and force HTTP/1
+	sni        string // the server name of the tls handshake with the target if not empty
+	h2Settings string // the encoded SETTINGS frame of the http2 connection if not empty
 }
 
 func (cm *connectMethod) key() connectMethodKey {
@@ -2370,13 +2903,24 @@ func (cm *connectMethod) key() connectMethodKey {
 		}
 	}
 	return connectMethodKey{
-		proxy:  proxyStr,
-		scheme: cm.targetScheme,
-		addr:   targetAddr,
-		onlyH1: cm.onlyH1,
+		proxy:      proxyStr,
+		scheme:     cm.targetScheme,
+		addr:       targetAddr,
+		onlyH1:     cm.onlyH1,
+		sni:        cm.sni,
+		h2Settings: cm.h2Settings,
 	}
 }
 
+// proxyKey returns the proxy of the cached HTTP/2 connections, see
+// netutil.ProxyConnKey.
+func (cm *connectMethod) proxyKey() string {
+	if cm.proxyURL == nil {
+		return ""
+	}
+	return cm.proxyURL.String()
+}
+
 // scheme returns the first hop scheme: http, https, or socks5
 func (cm *connectMethod) scheme() string {
 	if cm.proxyURL != nil {
@@ -2396,6 +2940,9 @@ func (cm *connectMethod) addr() string {
 // tlsHost returns the host name to match against the peer's
 // TLS certificate.
 func (cm *connectMethod) tlsHost() string {
+	if cm.sni != "" {
+		return cm.sni
+	}
 	h := cm.targetAddr
 	if hasPort(h) {
 		h = h[:strings.LastIndex(h, ":")]
@@ -2409,6 +2956,7 @@ func (cm *connectMethod) tlsHost() string {
 type connectMethodKey struct {
 	proxy, scheme, addr string
 	onlyH1              bool
+	sni, h2Settings     string
 }
 
 func (k connectMethodKey) String() string {
@@ -2449,6 +2997,8 @@ type persistConn struct {
 
 	writeLoopDone chan struct{} // closed when write loop ends
 
+	createdAt time.Time // time it was established, see MaxConnAge
+
 	// Both guarded by Transport.idleMu:
 	idleAt    time.Time   // time it last become idle
 	idleTimer *time.Timer // holding an AfterFunc to close it
@@ -3164,7 +3714,11 @@ func (pc *persistConn) writeRequest(r *http.Request, w io.Writer, usingProxy boo
 		}
 	}
 
-	_, err = fmt.Fprintf(w, "%s %s HTTP/1.1\r\n", valueOrDefault(r.Method, "GET"), ruri)
+	proto := "HTTP/1.1"
+	if requestIsHTTP10(r) {
+		proto = "HTTP/1.0"
+	}
+	_, err = fmt.Fprintf(w, "%s %s %s\r\n", valueOrDefault(r.Method, "GET"), ruri, proto)
 	if err != nil {
 		return err
 	}
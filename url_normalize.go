@@ -0,0 +1,157 @@
+package restys
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// URLNormalization is the flags of the URL normalizations applied to the
+// request URL before sending, see Client.SetURLNormalization.
+type URLNormalization int
+
+const (
+	// NormalizePercentEncoding uppercases the hex digits of the
+	// percent-encodings (e.g. "%2f" to "%2F") and decodes the
+	// percent-encoded unreserved characters (e.g. "%7E" to "~") in the
+	// path and query.
+	NormalizePercentEncoding URLNormalization = 1 << iota
+	// NormalizeDefaultPort removes the default port of the scheme from the
+	// host, e.g. "https://example.com:443" to "https://example.com".
+	NormalizeDefaultPort
+	// NormalizeDotSegments resolves the "." and ".." segments of the path,
+	// e.g. "/a/./b/../c" to "/a/c".
+	NormalizeDotSegments
+
+	// NormalizeAll enables all the URL normalizations.
+	NormalizeAll = NormalizePercentEncoding | NormalizeDefaultPort | NormalizeDotSegments
+)
+
+// encodeIDNAHost converts the non-ASCII hostname of u to its IDNA ASCII
+// form (punycode), so the dial, the SNI, the Host header and the cookies
+// all use the same host.
+func encodeIDNAHost(u *url.URL) error {
+	host := u.Hostname()
+	ascii, err := idnaASCII(host)
+	if err != nil {
+		return fmt.Errorf("invalid host %q: %w", host, err)
+	}
+	if ascii == host {
+		return nil
+	}
+	if port := u.Port(); port != "" {
+		ascii = net.JoinHostPort(ascii, port)
+	}
+	u.Host = ascii
+	return nil
+}
+
+func normalizeURL(u *url.URL, n URLNormalization) error {
+	if n&NormalizeDefaultPort != 0 {
+		if host, port, err := net.SplitHostPort(u.Host); err == nil &&
+			(u.Scheme == "http" && port == "80" || u.Scheme == "https" && port == "443") {
+			if strings.Contains(host, ":") {
+				host = "[" + host + "]"
+			}
+			u.Host = host
+		}
+	}
+	if n&NormalizePercentEncoding != 0 {
+		if u.RawPath != "" {
+			u.RawPath = normalizePercentEncoding(u.RawPath)
+		}
+		u.RawQuery = normalizePercentEncoding(u.RawQuery)
+	}
+	if n&NormalizeDotSegments != 0 {
+		if u.RawPath != "" {
+			rawPath := removeDotSegments(u.RawPath)
+			path, err := url.PathUnescape(rawPath)
+			if err != nil {
+				return err
+			}
+			u.Path, u.RawPath = path, rawPath
+		} else {
+			u.Path = removeDotSegments(u.Path)
+		}
+	}
+	return nil
+}
+
+// normalizePercentEncoding uppercases the hex digits of the
+// percent-encodings in s, and decodes the percent-encoded unreserved
+// characters.
+func normalizePercentEncoding(s string) string {
+	if !strings.Contains(s, "%") {
+		return s
+	}
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' || i+2 >= len(s) || !ishex(s[i+1]) || !ishex(s[i+2]) {
+			b.WriteByte(s[i])
+			continue
+		}
+		c := unhex(s[i+1])<<4 | unhex(s[i+2])
+		if isUnreserved(c) {
+			b.WriteByte(c)
+		} else {
+			b.WriteByte('%')
+			b.WriteString(strings.ToUpper(s[i+1 : i+3]))
+		}
+		i += 2
+	}
+	return b.String()
+}
+
+func isUnreserved(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+// removeDotSegments resolves the "." and ".." segments of the path
+// according to RFC 3986 section 5.2.4.
+func removeDotSegments(p string) string {
+	if !strings.Contains(p, ".") {
+		return p
+	}
+	segs := strings.Split(p, "/")
+	out := make([]string, 0, len(segs))
+	for i, seg := range segs {
+		last := i == len(segs)-1
+		switch seg {
+		case ".":
+			if last {
+				out = append(out, "")
+			}
+		case "..":
+			if len(out) > 1 || (len(out) == 1 && out[0] != "") {
+				out = out[:len(out)-1]
+			}
+			if last {
+				out = append(out, "")
+			}
+		default:
+			out = append(out, seg)
+		}
+	}
+	if strings.HasPrefix(p, "/") && (len(out) == 0 || out[0] != "") {
+		out = append([]string{""}, out...)
+	}
+	return strings.Join(out, "/")
+}
+
+func ishex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
@@ -0,0 +1,67 @@
+package restys
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/luoxk/restys/internal/tests"
+)
+
+func TestIDNAHost(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Host))
+	}))
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	var dialAddr string
+	c := C().SetDial(func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dialAddr = addr
+		return net.Dial(network, server.Listener.Addr().String())
+	})
+	resp, err := c.R().Get("http://测试.example:" + port + "/")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "xn--0zwm56d.example:"+port, resp.String())
+	tests.AssertEqual(t, "xn--0zwm56d.example:"+port, dialAddr)
+	tests.AssertEqual(t, "xn--0zwm56d.example:"+port, resp.Request.URL.Host)
+}
+
+func TestNormalizeURL(t *testing.T) {
+	cases := []struct {
+		n        URLNormalization
+		in, want string
+	}{
+		{0, "http://example.com:80/a/./b/%7e?q=%2f", "http://example.com:80/a/./b/%7e?q=%2f"},
+		{NormalizeDefaultPort, "http://example.com:80/a", "http://example.com/a"},
+		{NormalizeDefaultPort, "https://[::1]:443/a", "https://[::1]/a"},
+		{NormalizeDefaultPort, "https://example.com:80/a", "https://example.com:80/a"},
+		{NormalizePercentEncoding, "http://example.com/a%2fb/%7e?q=%2f%41", "http://example.com/a%2Fb/~?q=%2FA"},
+		{NormalizeDotSegments, "http://example.com/a/./b/../c", "http://example.com/a/c"},
+		{NormalizeDotSegments, "http://example.com/a/b/..", "http://example.com/a/"},
+		{NormalizeDotSegments, "http://example.com/../../a/.", "http://example.com/a/"},
+		{NormalizeDotSegments, "http://example.com/a%2f../b/../c", "http://example.com/a%2f../c"},
+		{NormalizeAll, "https://example.com:443/a/%7e/../b%2f", "https://example.com/a/b%2F"},
+	}
+	for _, c := range cases {
+		u, err := url.Parse(c.in)
+		tests.AssertNoError(t, err)
+		tests.AssertNoError(t, normalizeURL(u, c.n))
+		tests.AssertEqual(t, c.want, u.String())
+	}
+
+	var path string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.RawPath
+		if path == "" {
+			path = r.URL.Path
+		}
+	}))
+	defer server.Close()
+	resp, err := C().SetURLNormalization(NormalizeAll).R().Get(server.URL + "/a/./b/../c%2f")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "/a/c%2F", path)
+}
@@ -0,0 +1,248 @@
+package restys
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// WarmPoolOptions controls the warm connection pool, see
+// Client.EnableWarmPool.
+type WarmPoolOptions struct {
+	// Conns is the number of warm idle connections kept per origin,
+	// default is 1. It's capped by the max idle connections per host (see
+	// Client.SetMaxIdleConnsPerHost), and HTTP/2 and HTTP/3 keep only one
+	// connection per origin since the requests are multiplexed.
+	Conns int
+	// Interval is the interval of checking the warm connections, default
+	// is 10s.
+	Interval time.Duration
+	// MaxConcurrentDials is the max number of dials (including the TLS
+	// handshakes) in progress at the same time, which bounds the CPU
+	// budget of the maintenance, default is 2.
+	MaxConcurrentDials int
+	// MaxDialsPerInterval is the max number of dials in each interval,
+	// which bounds the network budget of the maintenance, default is 10.
+	MaxDialsPerInterval int
+	// DialTimeout is the timeout of each dial, default is 10s.
+	DialTimeout time.Duration
+}
+
+type warmPool struct {
+	c       *Client
+	origins []*url.URL
+	opts    WarmPoolOptions
+	stop    chan struct{}
+	done    chan struct{}
+	once    sync.Once
+}
+
+// EnableWarmPool starts a background maintainer which keeps warm
+// connections to the origins (e.g. "https://api.example.com"), the missing
+// and aged out (see Client.SetMaxConnAge) connections are re-dialed in
+// each interval, so the bursty requests never queue behind the DNS
+// resolution and handshakes. The maintainer is stopped by DisableWarmPool
+// or Client.Close, opts can be nil to use the default options. The
+// connection lifecycle hooks (e.g. Client.OnConnectDone) are also called
+// for the warm connections.
+func (c *Client) EnableWarmPool(origins []string, opts *WarmPoolOptions) *Client {
+	var urls []*url.URL
+	for _, origin := range origins {
+		u, err := url.Parse(origin)
+		if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+			c.log.Warnf("ignore invalid origin %q in EnableWarmPool", origin)
+			continue
+		}
+		urls = append(urls, &url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/"})
+	}
+	c.DisableWarmPool()
+	if len(urls) == 0 {
+		return c
+	}
+	p := &warmPool{
+		c:       c,
+		origins: urls,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	if opts != nil {
+		p.opts = *opts
+	}
+	if p.opts.Conns <= 0 {
+		p.opts.Conns = 1
+	}
+	if p.opts.Interval <= 0 {
+		p.opts.Interval = 10 * time.Second
+	}
+	if p.opts.MaxConcurrentDials <= 0 {
+		p.opts.MaxConcurrentDials = 2
+	}
+	if p.opts.MaxDialsPerInterval <= 0 {
+		p.opts.MaxDialsPerInterval = 10
+	}
+	if p.opts.DialTimeout <= 0 {
+		p.opts.DialTimeout = 10 * time.Second
+	}
+	c.warmPool = p
+	go p.run()
+	return c
+}
+
+// DisableWarmPool stops the warm connection pool maintainer (see
+// Client.EnableWarmPool), the warm connections are kept as the normal idle
+// connections.
+func (c *Client) DisableWarmPool() *Client {
+	if c.warmPool != nil {
+		c.warmPool.close()
+		c.warmPool = nil
+	}
+	return c
+}
+
+func (p *warmPool) close() {
+	p.once.Do(func() { close(p.stop) })
+	<-p.done
+}
+
+func (p *warmPool) run() {
+	defer close(p.done)
+	ticker := time.NewTicker(p.opts.Interval)
+	defer ticker.Stop()
+	for {
+		p.maintain()
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// maintain dials the missing warm connections of all origins within the
+// budgets.
+func (p *warmPool) maintain() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-p.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	t := p.c.GetTransport()
+	sem := make(chan struct{}, p.opts.MaxConcurrentDials)
+	var wg sync.WaitGroup
+	budget := p.opts.MaxDialsPerInterval
+	for _, u := range p.origins {
+		n := t.missingWarmConns(u, p.opts.Conns)
+		for ; n > 0 && budget > 0; n-- {
+			budget--
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				wg.Wait()
+				return
+			}
+			wg.Add(1)
+			go func(u *url.URL) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				dialCtx, cancel := context.WithTimeout(ctx, p.opts.DialTimeout)
+				defer cancel()
+				dialCtx = p.c.connHooks.withConnHooks(dialCtx)
+				if err := t.dialWarmConn(dialCtx, u); err != nil && t.Debugf != nil {
+					t.Debugf("failed to dial warm connection to %s: %v", u.Host, err)
+				}
+			}(u)
+		}
+	}
+	wg.Wait()
+}
+
+// missingWarmConns returns the number of connections to dial to keep want
+// warm connections to the origin u, the HTTP/1.1 idle connections which
+// exceeded the max age are closed.
+func (t *Transport) missingWarmConns(u *url.URL, want int) int {
+	if t.forceHttpVersion == h3 {
+		// the HTTP/3 connection is dialed only if it's not cached.
+		return 1
+	}
+	cm, err := t.warmConnectMethod(u)
+	if err != nil {
+		return 0
+	}
+	mayH2 := !cm.onlyH1 && cm.targetScheme == "https" && t.t2 != nil
+	if mayH2 && t.t2.HasConn(cm.targetAddr) {
+		return 0
+	}
+	if max := t.maxIdleConnsPerHost(); want > max {
+		want = max
+	}
+	idle := 0
+	t.idleMu.Lock()
+	for _, pconn := range t.idleConn[cm.key()] {
+		if pconn.alt != nil || pconn.isBroken() {
+			continue
+		}
+		if t.connTooOld(pconn) {
+			go pconn.closeConnIfStillIdle()
+			continue
+		}
+		idle++
+	}
+	t.idleMu.Unlock()
+	if idle == 0 && mayH2 {
+		// the protocol is unknown before the TLS handshake, dial one
+		// connection first to avoid the redundant HTTP/2 connections.
+		return 1
+	}
+	return want - idle
+}
+
+func (t *Transport) warmConnectMethod(u *url.URL) (connectMethod, error) {
+	req := (&http.Request{Method: http.MethodGet, URL: u, Host: u.Host, Header: make(http.Header)}).WithContext(context.Background())
+	proxyURL, err := t.proxyForRequest(req)
+	if err != nil {
+		return connectMethod{}, err
+	}
+	return t.connectMethodForRequest(&transportRequest{Request: req, ctx: req.Context()}, proxyURL), nil
+}
+
+// dialWarmConn dials a new connection to the origin u, and puts it into the
+// idle connection pool, or hands it to the request waiting for connection.
+func (t *Transport) dialWarmConn(ctx context.Context, u *url.URL) error {
+	if t.forceHttpVersion == h3 {
+		return t.t3.AddConn(ctx, u.Host)
+	}
+	cm, err := t.warmConnectMethod(u)
+	if err != nil {
+		return err
+	}
+	dialCtx, dialCancel := context.WithCancel(ctx)
+	w := &wantConn{
+		cm:         cm,
+		key:        cm.key(),
+		ctx:        dialCtx,
+		cancelCtx:  dialCancel,
+		result:     make(chan connOrError, 1),
+		beforeDial: func() {},
+		afterDial:  func() {},
+	}
+	t.queueForDial(w)
+	select {
+	case r := <-w.result:
+		if r.err != nil {
+			return r.err
+		}
+		if r.pc.alt == nil { // HTTP/2 connection is already pooled.
+			t.putOrCloseIdleConn(r.pc)
+		}
+		return nil
+	case <-ctx.Done():
+		w.cancel(t, ctx.Err())
+		return ctx.Err()
+	}
+}